@@ -0,0 +1,113 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 层压缩包格式的魔数
+var (
+	zipMagic  = []byte("PK")       // ZIP 本地文件头（PK\x03\x04）或空包的目录结束记录（PK\x05\x06）
+	gzipMagic = []byte{0x1f, 0x8b} // gzip 文件头
+)
+
+// extractArchive 根据内容开头的魔数识别层压缩包格式（ZIP 或 tar.gz）并解压到目标目录
+//
+// 参数:
+//   - content: 压缩包内容
+//   - destDir: 目标目录
+//
+// 返回:
+//   - error: 格式不支持或解压错误
+func extractArchive(content []byte, destDir string) error {
+	switch {
+	case bytes.HasPrefix(content, gzipMagic):
+		return extractTarGz(content, destDir)
+	case bytes.HasPrefix(content, zipMagic):
+		return extractZip(content, destDir)
+	default:
+		return fmt.Errorf("unsupported layer archive format, expected zip or tar.gz")
+	}
+}
+
+// safeExtractPath 返回压缩包条目在目标目录中的路径
+// 条目路径清理后落在目标目录之外（如包含 ../）时返回 false，调用方应跳过该条目
+func safeExtractPath(destDir, name string) (string, bool) {
+	path := filepath.Join(destDir, name)
+	cleanDest := filepath.Clean(destDir)
+	if !strings.HasPrefix(path, cleanDest+string(os.PathSeparator)) && path != cleanDest {
+		return "", false
+	}
+	return path, true
+}
+
+// extractTarGz 将 tar.gz 内容解压到目标目录
+// 与 extractZip 一样跳过目标目录之外的条目；只解压普通文件和目录，
+// 符号链接、硬链接和设备文件可能指向层目录之外，同样跳过。
+//
+// 参数:
+//   - content: tar.gz 文件内容
+//   - destDir: 目标目录
+//
+// 返回:
+//   - error: 解压错误
+func extractTarGz(content []byte, destDir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar entry: %w", err)
+		}
+
+		path, ok := safeExtractPath(destDir, header.Name)
+		if !ok {
+			fmt.Printf("Warning: skipping potentially unsafe path: %s\n", header.Name)
+			continue
+		}
+		mode := os.FileMode(header.Mode).Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, mode); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", path, err)
+			}
+		case tar.TypeReg:
+			// 确保父目录存在
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("failed to create parent directory for %s: %w", path, err)
+			}
+			outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+			if err != nil {
+				return fmt.Errorf("failed to create file %s: %w", path, err)
+			}
+			_, err = io.Copy(outFile, tr)
+			outFile.Close()
+			if err != nil {
+				return fmt.Errorf("failed to write file %s: %w", path, err)
+			}
+		case tar.TypeXGlobalHeader:
+			// PAX 全局头只携带元数据
+		default:
+			fmt.Printf("Warning: skipping unsupported tar entry %s (type %c)\n", header.Name, header.Typeflag)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarGzEntry 是测试用 tar.gz 包中的一个条目
+type tarGzEntry struct {
+	name     string
+	typeflag byte
+	mode     int64
+	content  string
+	linkname string
+}
+
+// tarGzLayer 构造包含给定条目的层 tar.gz 包
+func tarGzLayer(entries []tarGzEntry) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Mode:     e.mode,
+			Size:     int64(len(e.content)),
+			Linkname: e.linkname,
+		})
+		tw.Write([]byte(e.content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestExtractTarGzLayer(t *testing.T) {
+	root := t.TempDir()
+	dest := filepath.Join(root, "layer")
+	content := tarGzLayer([]tarGzEntry{
+		{name: "python/", typeflag: tar.TypeDir, mode: 0755},
+		{name: "python/pkg/__init__.py", typeflag: tar.TypeReg, mode: 0644, content: "VALUE = 1\n"},
+		{name: "bin/tool", typeflag: tar.TypeReg, mode: 0755, content: "#!/bin/sh\n"},
+		{name: "../escape.txt", typeflag: tar.TypeReg, mode: 0644, content: "pwned"},
+		{name: "python/../../escape2.txt", typeflag: tar.TypeReg, mode: 0644, content: "pwned"},
+		{name: "python/passwd", typeflag: tar.TypeSymlink, mode: 0777, linkname: "/etc/passwd"},
+	})
+
+	if err := extractArchive(content, dest); err != nil {
+		t.Fatalf("extractArchive() error = %v", err)
+	}
+
+	// 嵌套目录中的文件（父目录自动创建）
+	if data, err := os.ReadFile(filepath.Join(dest, "python", "pkg", "__init__.py")); err != nil || string(data) != "VALUE = 1\n" {
+		t.Errorf("python/pkg/__init__.py = %q, %v", data, err)
+	}
+	// 保留文件权限
+	if info, err := os.Stat(filepath.Join(dest, "bin", "tool")); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("bin/tool should keep its executable mode, got %v (err %v)", info.Mode(), err)
+	}
+	// 路径遍历条目被跳过
+	for _, name := range []string{"escape.txt", "escape2.txt"} {
+		if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was written outside the layer directory", name)
+		}
+	}
+	// 符号链接被跳过
+	if _, err := os.Lstat(filepath.Join(dest, "python", "passwd")); !os.IsNotExist(err) {
+		t.Errorf("symlink entry should be skipped")
+	}
+}
+
+func TestExtractArchiveDetectsFormat(t *testing.T) {
+	zipDest, tarDest := t.TempDir(), t.TempDir()
+	if err := extractArchive(zipLayer(map[string]string{"nodejs/node_modules/a/index.js": "1"}), zipDest); err != nil {
+		t.Fatalf("extractArchive(zip) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(zipDest, "nodejs", "node_modules", "a", "index.js")); err != nil {
+		t.Errorf("zip layer not extracted: %v", err)
+	}
+	tarContent := tarGzLayer([]tarGzEntry{{name: "nodejs/node_modules/a/index.js", typeflag: tar.TypeReg, mode: 0644, content: "1"}})
+	if err := extractArchive(tarContent, tarDest); err != nil {
+		t.Fatalf("extractArchive(tar.gz) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tarDest, "nodejs", "node_modules", "a", "index.js")); err != nil {
+		t.Errorf("tar.gz layer not extracted: %v", err)
+	}
+	if err := extractArchive([]byte("plain text"), t.TempDir()); err == nil {
+		t.Errorf("extractArchive() should reject unknown formats")
+	}
+}
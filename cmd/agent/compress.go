@@ -0,0 +1,101 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// 消息压缩相关常量
+const (
+	// EncodingGzip 是 gzip 压缩编码的名称，用于初始化时的压缩协商
+	EncodingGzip = "gzip"
+
+	// frameFlagGzip 是长度前缀的最高位，置位表示消息体经过 gzip 压缩。
+	// 消息体长度受 MaxMessageBytes 限制，远小于 2^31，最高位不会与长度冲突。
+	frameFlagGzip = uint32(1) << 31
+
+	// CompressionThreshold 是启用压缩后消息体需要达到的最小字节数，小消息压缩收益不足以抵消开销
+	CompressionThreshold = 64 * 1024
+)
+
+// InitResult 是初始化成功响应中 Output 的内容
+type InitResult struct {
+	Encoding string `json:"encoding,omitempty"`  // 双方协商一致的消息压缩编码，为空表示不压缩
+	WarmupMs int64  `json:"warmup_ms,omitempty"` // 预热函数的耗时（毫秒），未声明预热函数时为 0
+}
+
+// acceptsEncoding 判断对端声明支持的编码中是否包含 encoding
+func acceptsEncoding(accepted []string, encoding string) bool {
+	for _, e := range accepted {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiatedGzip 判断初始化响应是否确认启用 gzip 压缩
+func negotiatedGzip(resp *Message) bool {
+	var payload ResponsePayload
+	if err := json.Unmarshal(resp.Payload, &payload); err != nil || !payload.Success {
+		return false
+	}
+	var result InitResult
+	if err := json.Unmarshal(payload.Output, &result); err != nil {
+		return false
+	}
+	return result.Encoding == EncodingGzip
+}
+
+// gzipFrame 压缩消息体，压缩后没有变小时返回 ok=false
+// 先使用默认压缩级别；缺少重复片段的数据（如 JSON 中 base64 编码的 ZIP 层）在默认级别下几乎不会变小，
+// 此时改用只做 Huffman 编码的级别，仍能去掉 base64 编码约四分之一的冗余。
+func gzipFrame(data []byte) ([]byte, bool) {
+	var best []byte
+	for _, level := range []int{gzip.DefaultCompression, gzip.HuffmanOnly} {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, level)
+		if _, err := gz.Write(data); err != nil {
+			return nil, false
+		}
+		if err := gz.Close(); err != nil {
+			return nil, false
+		}
+		if best == nil || buf.Len() < len(best) {
+			best = buf.Bytes()
+		}
+		// 默认级别已节省 10% 以上时不再尝试
+		if len(best) <= len(data)*9/10 {
+			break
+		}
+	}
+	if len(best) >= len(data) {
+		return nil, false
+	}
+	return best, true
+}
+
+// gunzipFrame 解压消息体，解压后超过 MaxMessageBytes 时返回 ErrMessageTooLarge
+func gunzipFrame(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip frame: %w", err)
+	}
+	defer gz.Close()
+
+	// 多读一个字节用于判断是否超过上限，避免压缩炸弹耗尽内存
+	out, err := io.ReadAll(io.LimitReader(gz, int64(MaxMessageBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip frame: %w", err)
+	}
+	if len(out) > MaxMessageBytes {
+		return nil, fmt.Errorf("%w: decompressed frame exceeds %d", ErrMessageTooLarge, MaxMessageBytes)
+	}
+	return out, nil
+}
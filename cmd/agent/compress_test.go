@@ -0,0 +1,149 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"testing"
+)
+
+// countingConn 只统计写入的字节数，用于计算消息在线路上的大小
+type countingConn struct {
+	net.Conn
+	n int
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	c.n += len(p)
+	return len(p), nil
+}
+
+// layerInitMessage 构造携带一个 size 字节层内容的初始化消息
+// 层内容为随机字节，模拟已经压缩过的 ZIP 包
+func layerInitMessage(size int) *Message {
+	content := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(content)
+	payload, _ := json.Marshal(&InitPayload{
+		FunctionID: "fn-1",
+		Runtime:    "python3.11",
+		Layers:     []LayerInfo{{LayerID: "layer-1", Version: 1, Content: content}},
+	})
+	return &Message{Type: MessageTypeInit, RequestID: "init-1", Payload: payload}
+}
+
+func TestWriteFrameCompressesLargeMessages(t *testing.T) {
+	msg := layerInitMessage(256 * 1024)
+
+	plain, compressed := &countingConn{}, &countingConn{}
+	if err := writeFrame(plain, msg, false); err != nil {
+		t.Fatalf("writeFrame(compress=false) error = %v", err)
+	}
+	if err := writeFrame(compressed, msg, true); err != nil {
+		t.Fatalf("writeFrame(compress=true) error = %v", err)
+	}
+	if compressed.n >= plain.n {
+		t.Errorf("compressed frame is %d bytes, want fewer than uncompressed %d", compressed.n, plain.n)
+	}
+
+	// 压缩后的消息被透明解压
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		writeFrame(server, msg, true)
+	}()
+	got, err := readMessage(client)
+	if err != nil {
+		t.Fatalf("readMessage() error = %v", err)
+	}
+	if got.RequestID != msg.RequestID || !bytes.Equal(got.Payload, msg.Payload) {
+		t.Errorf("readMessage() returned a different message after decompression")
+	}
+
+	// 小于阈值的消息不压缩
+	small := &Message{Type: MessageTypePing, RequestID: "ping-1"}
+	data, _ := json.Marshal(small)
+	conn := &countingConn{}
+	if err := writeFrame(conn, small, true); err != nil || conn.n != 4+len(data) {
+		t.Errorf("small frame = %d bytes (err %v), want uncompressed %d", conn.n, err, 4+len(data))
+	}
+}
+
+func TestReadMessageRejectsGzipBomb(t *testing.T) {
+	defer func(max int) { MaxMessageBytes = max }(MaxMessageBytes)
+	MaxMessageBytes = 1024
+
+	// 压缩后很小但解压后超过上限的消息体
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(make([]byte, 1<<20))
+	gz.Close()
+	conn := rawFrame(uint32(buf.Len())|frameFlagGzip, buf.Bytes())
+	defer conn.Close()
+
+	if _, err := readMessage(conn); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("readMessage() error = %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func TestInitNegotiatesGzip(t *testing.T) {
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+	tests := []struct {
+		name     string
+		accepted []string
+		want     bool
+	}{
+		{"host supports gzip", []string{"br", EncodingGzip}, true},
+		{"legacy host", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, _ := json.Marshal(&InitPayload{
+				Handler:        "handler.handler",
+				Code:           "def handler(event):\n    return event\n",
+				Runtime:        "python3.11",
+				AcceptEncoding: tt.accepted,
+			})
+			resp := (&Agent{}).handleInit(&Message{Type: MessageTypeInit, RequestID: "init-1", Payload: payload})
+			if got := negotiatedGzip(resp); got != tt.want {
+				t.Errorf("negotiatedGzip() = %v, want %v (response %s)", got, tt.want, resp.Payload)
+			}
+		})
+	}
+	if negotiatedGzip(errorResponse("init-1", "runtime init failed")) {
+		t.Errorf("failed init must not enable compression")
+	}
+}
+
+// BenchmarkLayerInitFrame 比较携带 5MB 层的初始化消息在压缩前后的线路字节数
+func BenchmarkLayerInitFrame(b *testing.B) {
+	msg := layerInitMessage(5 * 1024 * 1024)
+	for _, bc := range []struct {
+		name     string
+		compress bool
+	}{
+		{"identity", false},
+		{"gzip", true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var wire int
+			for i := 0; i < b.N; i++ {
+				conn := &countingConn{}
+				if err := writeFrame(conn, msg, bc.compress); err != nil {
+					b.Fatal(err)
+				}
+				wire = conn.n
+			}
+			b.ReportMetric(float64(wire), "wire-bytes")
+		})
+	}
+}
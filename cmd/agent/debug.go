@@ -150,12 +150,15 @@ func (m *DebugManager) IsDebugging() bool {
 
 // newDebugAdapter 根据运行时创建调试适配器
 func newDebugAdapter(runtime string) (DebugAdapter, error) {
-	switch runtime {
-	case "python3.11":
-		return NewPythonDebugAdapter(), nil
-	case "nodejs20":
+	if !Interpreters.supports(runtime) {
+		return nil, fmt.Errorf("debugging not supported for runtime: %s", runtime)
+	}
+	switch runtimeLanguage(runtime) {
+	case languagePython:
+		return NewPythonDebugAdapter(Interpreters.interpreter(runtime, "python3")), nil
+	case languageNode:
 		return nil, fmt.Errorf("Node.js debugging not yet implemented")
-	case "go1.24":
+	case languageGo:
 		return nil, fmt.Errorf("Go debugging not yet implemented")
 	default:
 		return nil, fmt.Errorf("debugging not supported for runtime: %s", runtime)
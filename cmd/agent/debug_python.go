@@ -31,12 +31,18 @@ type PythonDebugAdapter struct {
 	writer    io.Writer
 	port      int
 	writeMu   sync.Mutex
+
+	interpreter string // 运行 debugpy 的 Python 解释器
 }
 
 // NewPythonDebugAdapter 创建 Python 调试适配器
-func NewPythonDebugAdapter() *PythonDebugAdapter {
+//
+// 参数:
+//   - interpreter: 运行 debugpy 的 Python 解释器
+func NewPythonDebugAdapter(interpreter string) *PythonDebugAdapter {
 	return &PythonDebugAdapter{
 		BaseDebugAdapter: NewBaseDebugAdapter(),
+		interpreter:      interpreter,
 	}
 }
 
@@ -56,7 +62,7 @@ func (a *PythonDebugAdapter) Start(config *DebugConfig) error {
 	}
 
 	// 启动带 debugpy 的 Python 进程
-	cmd := exec.CommandContext(a.Context(), "python3", "-m", "debugpy",
+	cmd := exec.CommandContext(a.Context(), a.interpreter, "-m", "debugpy",
 		"--listen", fmt.Sprintf("127.0.0.1:%d", a.port),
 		"--wait-for-client",
 		filepath.Join(FunctionDir, "_debug_wrapper.py"),
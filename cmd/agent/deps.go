@@ -0,0 +1,248 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 依赖安装相关常量
+const (
+	// depsManifestFile 是记录已安装依赖的清单文件名，位于函数代码目录下
+	depsManifestFile = ".nimbus-deps.json"
+	// pythonDepsDir 是 pip install --target 的安装目录名，位于函数代码目录下
+	pythonDepsDir = ".python_packages"
+	// DefaultInstallDepsTimeoutSec 是初始化载荷未指定超时时安装依赖的超时时间（秒）
+	DefaultInstallDepsTimeoutSec = 300
+	// installOutputTailBytes 是安装失败时错误信息中保留的安装输出末尾字节数
+	installOutputTailBytes = 2000
+)
+
+// depsManifest 记录每种依赖清单当前安装的内容，键为清单文件名，值为 depsCacheKey
+type depsManifest map[string]string
+
+// depsInstaller 描述一种依赖清单的安装方式
+type depsInstaller struct {
+	manifest string   // 依赖清单文件名，如 requirements.txt
+	lockfile string   // 参与缓存键的锁文件名（可选）
+	target   string   // 依赖安装目录
+	command  []string // 在函数代码目录中执行的安装命令
+}
+
+// depsInstallerFor 返回运行时使用的依赖安装方式，只有 Python 和 Node.js 支持安装依赖
+//
+// 参数:
+//   - runtime: 运行时名称
+//   - dir: 函数代码目录
+//
+// 返回:
+//   - *depsInstaller: 安装方式，运行时不支持安装依赖时返回 nil
+func depsInstallerFor(runtime, dir string) *depsInstaller {
+	switch runtimeLanguage(runtime) {
+	case languagePython:
+		target := filepath.Join(dir, pythonDepsDir)
+		return &depsInstaller{
+			manifest: "requirements.txt",
+			target:   target,
+			command: []string{Interpreters.interpreter(runtime, "python3"), "-m", "pip", "install",
+				"--no-cache-dir", "--disable-pip-version-check", "--target", target, "-r", "requirements.txt"},
+		}
+	case languageNode:
+		// npm ci 要求 package-lock.json，按锁文件安装确定的依赖版本
+		return &depsInstaller{
+			manifest: "package.json",
+			lockfile: "package-lock.json",
+			target:   filepath.Join(dir, "node_modules"),
+			command:  []string{"npm", "ci", "--omit=dev", "--no-audit", "--no-fund"},
+		}
+	}
+	return nil
+}
+
+// depsCacheKey 返回依赖的缓存键：运行时名称和依赖清单（及锁文件）内容的 SHA-256 哈希（十六进制）
+// 运行时参与缓存键，同一份 requirements.txt 在不同 Python 版本下需要重新安装
+func depsCacheKey(runtime string, files ...[]byte) string {
+	h := sha256.New()
+	h.Write([]byte(runtime))
+	for _, data := range files {
+		fmt.Fprintf(h, "\x00%d\x00", len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadDepsManifest 读取函数代码目录下的已安装依赖清单，文件不存在或损坏时返回空清单（重新安装）
+func loadDepsManifest(dir string) depsManifest {
+	m := make(depsManifest)
+	data, err := os.ReadFile(filepath.Join(dir, depsManifestFile))
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return make(depsManifest)
+	}
+	return m
+}
+
+// save 将清单写入函数代码目录，先写临时文件再重命名，避免中途失败留下损坏的清单
+func (m depsManifest) save(dir string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, depsManifestFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// installDeps 按依赖清单安装依赖，安装目录中已是相同清单安装的依赖时跳过
+// 需要安装时先从清单中移除该项并清空安装目录，安装成功后再记录新的缓存键，
+// 这样安装中途失败或超时不会让清单指向不完整的目录。
+//
+// 参数:
+//   - m: 已安装依赖清单
+//   - dir: 函数代码目录
+//   - key: 依赖的缓存键
+//   - inst: 安装方式
+//   - install: 执行安装的函数
+//
+// 返回:
+//   - bool: 是否命中缓存（未做安装）
+//   - error: 清理、安装或写清单失败
+func installDeps(m depsManifest, dir, key string, inst *depsInstaller, install func() error) (bool, error) {
+	if m[inst.manifest] == key {
+		if info, err := os.Stat(inst.target); err == nil && info.IsDir() {
+			return true, nil
+		}
+	}
+
+	delete(m, inst.manifest)
+	if err := m.save(dir); err != nil {
+		return false, fmt.Errorf("failed to update deps manifest: %w", err)
+	}
+	if err := os.RemoveAll(inst.target); err != nil {
+		return false, fmt.Errorf("failed to clean %s: %w", inst.target, err)
+	}
+	if err := install(); err != nil {
+		return false, err
+	}
+
+	m[inst.manifest] = key
+	if err := m.save(dir); err != nil {
+		return false, fmt.Errorf("failed to update deps manifest: %w", err)
+	}
+	return false, nil
+}
+
+// runInstallCommand 在 dir 中执行安装命令，超时后终止命令
+// 失败时错误信息包含安装输出的末尾一段，便于定位无法安装的依赖
+func runInstallCommand(ctx context.Context, dir string, command []string) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out: %w", ctx.Err())
+	}
+	if len(output) > installOutputTailBytes {
+		output = output[len(output)-installOutputTailBytes:]
+	}
+	return fmt.Errorf("%s failed: %w\n%s", strings.Join(command[:2], " "), err, bytes.TrimSpace(output))
+}
+
+// setupDependencies 在初始化时安装函数代码包中声明的依赖（requirements.txt 或 package.json），
+// 相同运行时和依赖清单已安装过时跳过；Python 依赖目录加入 PYTHONPATH，
+// Node.js 依赖安装在函数目录的 node_modules 中，无需额外配置。
+//
+// 参数:
+//   - payload: 初始化载荷，InstallDeps 为 false 时不做任何事
+//   - dir: 函数代码目录
+//
+// 返回:
+//   - error: 安装失败或超时
+func setupDependencies(payload *InitPayload, dir string) error {
+	if !payload.InstallDeps {
+		return nil
+	}
+	inst := depsInstallerFor(payload.Runtime, dir)
+	if inst == nil {
+		return nil
+	}
+	manifest, err := os.ReadFile(filepath.Join(dir, inst.manifest))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", inst.manifest, err)
+	}
+	files := [][]byte{manifest}
+	if inst.lockfile != "" {
+		// 锁文件缺失时由 npm ci 报错
+		lock, _ := os.ReadFile(filepath.Join(dir, inst.lockfile))
+		files = append(files, lock)
+	}
+
+	timeout := time.Duration(payload.InstallDepsTimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultInstallDepsTimeoutSec * time.Second
+	}
+	key := depsCacheKey(payload.Runtime, files...)
+	cached, err := installDeps(loadDepsManifest(dir), dir, key, inst, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return runInstallCommand(ctx, dir, inst.command)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install %s dependencies: %w", inst.manifest, err)
+	}
+	if cached {
+		fmt.Printf("Dependencies from %s already installed in %s\n", inst.manifest, inst.target)
+	} else {
+		fmt.Printf("Dependencies from %s installed in %s\n", inst.manifest, inst.target)
+	}
+
+	if runtimeLanguage(payload.Runtime) == languagePython {
+		paths := inst.target
+		if existing := os.Getenv("PYTHONPATH"); existing != "" {
+			paths += ":" + existing
+		}
+		os.Setenv("PYTHONPATH", paths)
+	}
+	return nil
+}
+
+// codeBundle 判断 Python 或 Node.js 函数的代码是否为 base64 编码的 ZIP 代码包
+// 明文源码通常不是合法的 base64，即使恰好可以解码，内容也不会以 ZIP 魔数开头
+//
+// 参数:
+//   - code: 初始化载荷中的函数代码
+//
+// 返回:
+//   - []byte: 解码后的 ZIP 内容
+//   - bool: 是否为代码包
+func codeBundle(code string) ([]byte, bool) {
+	data, err := base64.StdEncoding.DecodeString(code)
+	if err != nil || !bytes.HasPrefix(data, zipMagic) {
+		return nil, false
+	}
+	return data, true
+}
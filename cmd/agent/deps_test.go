@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// installOnce 按 requirements.txt 的内容执行一次 installDeps，返回是否命中缓存和安装函数被调用的次数
+func installOnce(t *testing.T, dir, runtime, requirements string, fail error) (bool, int, error) {
+	t.Helper()
+	inst := depsInstallerFor(runtime, dir)
+	calls := 0
+	cached, err := installDeps(loadDepsManifest(dir), dir, depsCacheKey(runtime, []byte(requirements)), inst, func() error {
+		calls++
+		if fail != nil {
+			return fail
+		}
+		return os.MkdirAll(inst.target, 0755)
+	})
+	return cached, calls, err
+}
+
+func TestInstallDepsSkipsUnchangedManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	if cached, calls, err := installOnce(t, dir, "python3.11", "requests==2.31.0\n", nil); err != nil || cached || calls != 1 {
+		t.Fatalf("first install: cached=%v calls=%d err=%v, want one install", cached, calls, err)
+	}
+
+	// 相同运行时和依赖清单：重新初始化时跳过安装
+	if cached, calls, err := installOnce(t, dir, "python3.11", "requests==2.31.0\n", nil); err != nil || !cached || calls != 0 {
+		t.Errorf("unchanged requirements: cached=%v calls=%d err=%v, want cache hit", cached, calls, err)
+	}
+
+	// 依赖清单变化时重新安装
+	if cached, calls, _ := installOnce(t, dir, "python3.11", "requests==2.32.0\n", nil); cached || calls != 1 {
+		t.Errorf("changed requirements: cached=%v calls=%d, want reinstall", cached, calls)
+	}
+
+	// 运行时变化时重新安装
+	if cached, calls, _ := installOnce(t, dir, "python3.12", "requests==2.32.0\n", nil); cached || calls != 1 {
+		t.Errorf("changed runtime: cached=%v calls=%d, want reinstall", cached, calls)
+	}
+
+	// 安装目录被删除时即使清单一致也重新安装
+	os.RemoveAll(filepath.Join(dir, pythonDepsDir))
+	if cached, calls, _ := installOnce(t, dir, "python3.12", "requests==2.32.0\n", nil); cached || calls != 1 {
+		t.Errorf("missing target dir: cached=%v calls=%d, want reinstall", cached, calls)
+	}
+}
+
+func TestInstallDepsFailureIsNotCached(t *testing.T) {
+	dir := t.TempDir()
+	installOnce(t, dir, "nodejs20", `{"dependencies":{}}`, nil)
+
+	// 安装失败后清单中不再记录该项，下次初始化重新安装
+	wantErr := errors.New("npm ci failed")
+	if _, _, err := installOnce(t, dir, "nodejs20", `{"dependencies":{"left-pad":"1.3.0"}}`, wantErr); !errors.Is(err, wantErr) {
+		t.Fatalf("installDeps() error = %v, want %v", err, wantErr)
+	}
+	if _, ok := loadDepsManifest(dir)["package.json"]; ok {
+		t.Errorf("failed install left an entry in the deps manifest")
+	}
+	if cached, calls, _ := installOnce(t, dir, "nodejs20", `{"dependencies":{"left-pad":"1.3.0"}}`, nil); cached || calls != 1 {
+		t.Errorf("retry after failure: cached=%v calls=%d, want reinstall", cached, calls)
+	}
+}
+
+func TestDepsCacheKeyIncludesLockfile(t *testing.T) {
+	pkg := []byte(`{"name":"fn"}`)
+	if depsCacheKey("nodejs20", pkg, []byte("lock-v1")) == depsCacheKey("nodejs20", pkg, []byte("lock-v2")) {
+		t.Errorf("lockfile change did not change the cache key")
+	}
+	// 文件边界参与哈希，内容拼接相同的不同文件组合不会冲突
+	if depsCacheKey("nodejs20", []byte("ab"), []byte("c")) == depsCacheKey("nodejs20", []byte("a"), []byte("bc")) {
+		t.Errorf("cache key ignores file boundaries")
+	}
+}
+
+func TestSetupDependenciesRequiresFlag(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "requirements.txt"), []byte("requests\n"), 0644)
+	if err := setupDependencies(&InitPayload{Runtime: "python3.11"}, dir); err != nil {
+		t.Fatalf("setupDependencies() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, depsManifestFile)); !os.IsNotExist(err) {
+		t.Errorf("dependencies were installed without install_deps")
+	}
+}
+
+func TestCodeBundleDetection(t *testing.T) {
+	bundle := zipLayer(map[string]string{"handler.py": "def main(e): return e", "requirements.txt": "requests"})
+	if data, ok := codeBundle(base64.StdEncoding.EncodeToString(bundle)); !ok || len(data) != len(bundle) {
+		t.Errorf("base64 zip was not detected as a code bundle")
+	}
+	for _, code := range []string{"def main(event):\n    return event\n", "aGVsbG8=", ""} {
+		if _, ok := codeBundle(code); ok {
+			t.Errorf("codeBundle(%q) detected a bundle", code)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// 引用传递输入相关常量
+const (
+	// DefaultMaxInputRefBytes 是按引用下载的输入的默认最大字节数（512MB）
+	DefaultMaxInputRefBytes = 512 * 1024 * 1024
+	// EnvMaxInputRefBytes 是覆盖按引用下载的输入大小上限的 Agent 环境变量（字节数）
+	EnvMaxInputRefBytes = "NIMBUS_MAX_INPUT_REF_BYTES"
+)
+
+// MaxInputRefBytes 是按引用下载的输入允许的最大字节数，启动时可由 EnvMaxInputRefBytes 覆盖
+var MaxInputRefBytes int64 = DefaultMaxInputRefBytes
+
+// inputFile 是按引用下载到本地的函数输入
+type inputFile struct {
+	file *os.File
+	size int64
+}
+
+// inputFileKey 是上下文中保存 *inputFile 的键
+type inputFileKey struct{}
+
+// withInputFile 将按引用下载的输入放入上下文，运行时从中读取输入而不是使用内联的 Input
+func withInputFile(ctx context.Context, in *inputFile) context.Context {
+	return context.WithValue(ctx, inputFileKey{}, in)
+}
+
+// inputFileFrom 返回上下文中按引用下载的输入，输入内联传递时返回 nil
+func inputFileFrom(ctx context.Context) *inputFile {
+	in, _ := ctx.Value(inputFileKey{}).(*inputFile)
+	return in
+}
+
+// inputReader 返回子进程的标准输入：输入按引用传递时从下载的文件流式读取，否则读取内联的 JSON。
+// 每次调用都从文件开头读取，常驻进程不可用时改用子进程执行也能读到完整输入。
+func inputReader(ctx context.Context, input json.RawMessage) io.Reader {
+	if in := inputFileFrom(ctx); in != nil {
+		return io.NewSectionReader(in.file, 0, in.size)
+	}
+	return jsonReader(input)
+}
+
+// loadInput 返回完整的输入，供不经过子进程标准输入的运行时（WebAssembly、常驻进程模式的 Python）使用
+func loadInput(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+	in := inputFileFrom(ctx)
+	if in == nil {
+		return input, nil
+	}
+	data, err := io.ReadAll(io.NewSectionReader(in.file, 0, in.size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	return data, nil
+}
+
+// fetchInputRef 下载按引用传递的输入。
+// http(s) 引用（如对象存储的预签名 URL）下载到已删除目录项的临时文件，关闭后自动释放磁盘空间；
+// file 引用直接打开 Agent 可见的本地文件（如 Docker 模式挂载的目录）。
+//
+// 参数:
+//   - ctx: 上下文，取消时中止下载
+//   - ref: 输入的 URI
+//
+// 返回:
+//   - *inputFile: 本地输入文件，调用方负责关闭
+//   - error: 引用无效、下载失败或超过 MaxInputRefBytes
+func fetchInputRef(ctx context.Context, ref string) (*inputFile, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input_ref: %w", err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input_ref: %w", err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open input_ref: %w", err)
+		}
+		if info.Size() > MaxInputRefBytes {
+			f.Close()
+			return nil, fmt.Errorf("input_ref too large: %d > %d bytes", info.Size(), MaxInputRefBytes)
+		}
+		return &inputFile{file: f, size: info.Size()}, nil
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported input_ref scheme %q", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input_ref: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch input_ref: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch input_ref: status %d", resp.StatusCode)
+	}
+
+	f, err := os.CreateTemp("", "nimbus-input-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input file: %w", err)
+	}
+	os.Remove(f.Name())
+	size, err := io.Copy(f, io.LimitReader(resp.Body, MaxInputRefBytes+1))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to fetch input_ref: %w", err)
+	}
+	if size > MaxInputRefBytes {
+		f.Close()
+		return nil, fmt.Errorf("input_ref too large: exceeds %d bytes", MaxInputRefBytes)
+	}
+	return &inputFile{file: f, size: size}, nil
+}
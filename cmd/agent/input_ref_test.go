@@ -0,0 +1,119 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// stdinRuntime 以标准输入运行 shell 命令，模拟从标准输入读取输入的子进程运行时
+type stdinRuntime struct{ script string }
+
+func (r *stdinRuntime) Init(*InitPayload) error { return nil }
+
+func (r *stdinRuntime) Execute(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", r.script)
+	cmd.Stdin = inputReader(ctx, input)
+	out, err := runCommand(ctx, cmd, "cat error")
+	return json.RawMessage(out), err
+}
+
+// execPayload 通过连接发送一次执行请求并返回响应
+func execPayload(t *testing.T, rt Runtime, payload *ExecPayload) *ResponsePayload {
+	t.Helper()
+	agent := &Agent{initialized: true, runtime: rt, config: &InitPayload{TimeoutSec: 5}}
+	host, guest := net.Pipe()
+	defer host.Close()
+	go agent.handleConnection(context.Background(), guest)
+
+	data, _ := json.Marshal(payload)
+	if err := writeMessage(host, &Message{Type: MessageTypeExec, RequestID: "req-1", Payload: data}); err != nil {
+		t.Fatalf("writeMessage() error = %v", err)
+	}
+	msg, err := readMessage(host)
+	if err != nil {
+		t.Fatalf("readMessage() error = %v", err)
+	}
+	var resp ResponsePayload
+	if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return &resp
+}
+
+func TestExecFetchesInputRef(t *testing.T) {
+	// 输入大于消息大小上限，只能按引用传递
+	defer func(max int) { MaxMessageBytes = max }(MaxMessageBytes)
+	MaxMessageBytes = 1 << 20
+	input := `{"data":"` + strings.Repeat("x", 2<<20) + `"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/inputs/abc" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(input))
+	}))
+	defer srv.Close()
+
+	// 函数输出标准输入的字节数
+	rt := &stdinRuntime{script: "wc -c | tr -d ' \\n'"}
+	resp := execPayload(t, rt, &ExecPayload{InputRef: srv.URL + "/inputs/abc"})
+	if !resp.Success || string(resp.Output) != strconv.Itoa(len(input)) {
+		t.Fatalf("response success=%v output=%s error=%q, want the %d-byte referenced input on stdin",
+			resp.Success, resp.Output, resp.Error, len(input))
+	}
+
+	// 下载失败时调用失败，不会以空输入执行函数
+	resp = execPayload(t, rt, &ExecPayload{InputRef: srv.URL + "/inputs/missing"})
+	if resp.Success || !strings.Contains(resp.Error, "status 404") {
+		t.Errorf("missing ref response = %+v, want fetch failure", resp)
+	}
+}
+
+func TestExecInputInlineFallback(t *testing.T) {
+	resp := execPayload(t, &stdinRuntime{script: "cat"}, &ExecPayload{Input: json.RawMessage(`{"n":1}`)})
+	if !resp.Success || string(resp.Output) != `{"n":1}` {
+		t.Errorf("inline response = %+v, want inline input on stdin", resp)
+	}
+}
+
+func TestFetchInputRefFileAndLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.json")
+	os.WriteFile(path, []byte(`{"from":"file"}`), 0644)
+
+	in, err := fetchInputRef(context.Background(), "file://"+path)
+	if err != nil {
+		t.Fatalf("fetchInputRef(file) error = %v", err)
+	}
+	defer in.file.Close()
+	// 不经过标准输入的运行时读取完整输入，可以重复读取
+	ctx := withInputFile(context.Background(), in)
+	for i := 0; i < 2; i++ {
+		if data, err := loadInput(ctx, nil); err != nil || string(data) != `{"from":"file"}` {
+			t.Errorf("loadInput() = %s, %v, want file contents", data, err)
+		}
+	}
+	if data, _ := loadInput(context.Background(), json.RawMessage(`{}`)); string(data) != `{}` {
+		t.Errorf("loadInput() without ref = %s, want inline input", data)
+	}
+
+	defer func(max int64) { MaxInputRefBytes = max }(MaxInputRefBytes)
+	MaxInputRefBytes = 4
+	if _, err := fetchInputRef(context.Background(), "file://"+path); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("fetchInputRef() over limit error = %v, want too large", err)
+	}
+	if _, err := fetchInputRef(context.Background(), "ftp://example.com/input"); err == nil {
+		t.Errorf("fetchInputRef(ftp) succeeded, want unsupported scheme")
+	}
+}
@@ -0,0 +1,189 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 解释器配置相关常量
+const (
+	// InterpretersFile 是 rootfs 中的解释器配置文件，每行一个 运行时=解释器路径，# 开头的行为注释
+	InterpretersFile = "/etc/nimbus/interpreters"
+	// EnvInterpreters 是覆盖解释器配置的 Agent 环境变量，格式同配置文件，条目也可以用逗号分隔，
+	// 如 "python3.12=/opt/python3.12/bin/python3,nodejs22=/opt/node22/bin/node"
+	EnvInterpreters = "NIMBUS_INTERPRETERS"
+)
+
+// 运行时所属的语言，同一语言的不同版本共用包装脚本和代码文件名
+const (
+	languagePython = "python"
+	languageNode   = "nodejs"
+	languageRuby   = "ruby"
+	languageJava   = "java"
+	languageGo     = "go"
+	languageWasm   = "wasm"
+)
+
+// interpreterRegistry 是运行时名称（如 python3.12）到解释器可执行文件的映射，
+// 不含 / 的值在 PATH 中查找。只有登记的运行时可以创建，Go 和 WebAssembly 不需要解释器。
+type interpreterRegistry map[string]string
+
+// defaultInterpreters 是 rootfs 未配置时的解释器，与运行时镜像中安装的版本一致
+func defaultInterpreters() interpreterRegistry {
+	return interpreterRegistry{
+		"python3.11": "python3",
+		"nodejs20":   "node",
+		"ruby3.2":    "ruby",
+		"java17":     "java",
+	}
+}
+
+// Interpreters 是 Agent 使用的解释器映射，启动时由 loadInterpreters 加载
+var Interpreters = defaultInterpreters()
+
+// runtimeLanguage 返回运行时名称所属的语言，无法识别时返回空字符串
+//
+// 参数:
+//   - runtime: 运行时名称，如 python3.12、nodejs22、ruby3.3、java21
+//
+// 返回:
+//   - string: 语言标识（language* 常量）
+func runtimeLanguage(runtime string) string {
+	switch {
+	case runtime == "go1.24":
+		return languageGo
+	case runtime == "wasm":
+		return languageWasm
+	case strings.HasPrefix(runtime, "python3."):
+		return languagePython
+	case strings.HasPrefix(runtime, "nodejs"):
+		return languageNode
+	case strings.HasPrefix(runtime, "ruby"):
+		return languageRuby
+	case strings.HasPrefix(runtime, "java"):
+		return languageJava
+	}
+	return ""
+}
+
+// supports 判断运行时是否可以在本 Agent 上创建
+func (reg interpreterRegistry) supports(runtime string) bool {
+	switch runtimeLanguage(runtime) {
+	case languageGo, languageWasm:
+		return true
+	case "":
+		return false
+	}
+	_, ok := reg[runtime]
+	return ok
+}
+
+// interpreter 返回执行运行时函数的解释器，未登记的运行时返回 fallback
+//
+// 参数:
+//   - runtime: 运行时名称
+//   - fallback: 未登记时使用的默认命令，如 python3
+//
+// 返回:
+//   - string: 解释器路径或命令名
+func (reg interpreterRegistry) interpreter(runtime, fallback string) string {
+	if path, ok := reg[runtime]; ok {
+		return path
+	}
+	return fallback
+}
+
+// parseInterpreters 解析解释器配置，条目以换行或逗号分隔，空行和 # 开头的行被忽略
+//
+// 参数:
+//   - spec: 配置内容
+//
+// 返回:
+//   - map[string]string: 运行时名称到解释器路径的映射
+//   - error: 条目格式错误或运行时语言无法识别时返回错误
+func parseInterpreters(spec string) (map[string]string, error) {
+	entries := make(map[string]string)
+	for _, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			name, path, ok := strings.Cut(entry, "=")
+			name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+			if !ok || name == "" || path == "" {
+				return nil, fmt.Errorf("invalid interpreter entry %q, expected runtime=path", entry)
+			}
+			switch runtimeLanguage(name) {
+			case languagePython, languageNode, languageRuby, languageJava:
+			default:
+				return nil, fmt.Errorf("invalid interpreter entry %q: %s is not an interpreted runtime", entry, name)
+			}
+			entries[name] = path
+		}
+	}
+	return entries, nil
+}
+
+// loadInterpreters 在默认解释器的基础上依次应用配置文件和环境变量中的条目，
+// 后者覆盖前者；配置文件不存在时跳过。
+//
+// 参数:
+//   - file: 配置文件路径
+//   - env: 环境变量 EnvInterpreters 的值
+//
+// 返回:
+//   - interpreterRegistry: 合并后的解释器映射
+//   - error: 读取或解析失败时返回错误，此时映射只包含解析成功的部分
+func loadInterpreters(file, env string) (interpreterRegistry, error) {
+	reg := defaultInterpreters()
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return reg, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	for _, spec := range []string{string(data), env} {
+		entries, err := parseInterpreters(spec)
+		if err != nil {
+			return reg, err
+		}
+		for name, path := range entries {
+			reg[name] = path
+		}
+	}
+	return reg, nil
+}
+
+// String 按运行时名称排序输出映射，用于启动日志
+func (reg interpreterRegistry) String() string {
+	names := make([]string, 0, len(reg))
+	for name := range reg {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + reg[name]
+	}
+	return strings.Join(parts, ",")
+}
+
+// interpreter 返回执行 Python 函数的解释器
+func (r *PythonRuntime) interpreter() string { return Interpreters.interpreter(r.runtime, "python3") }
+
+// interpreter 返回执行 Node.js 函数的解释器
+func (r *NodeRuntime) interpreter() string { return Interpreters.interpreter(r.runtime, "node") }
+
+// interpreter 返回执行 Ruby 函数的解释器
+func (r *RubyRuntime) interpreter() string { return Interpreters.interpreter(r.runtime, "ruby") }
+
+// interpreter 返回执行 Java 函数的 JVM
+func (r *JavaRuntime) interpreter() string { return Interpreters.interpreter(r.runtime, "java") }
@@ -0,0 +1,109 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withInterpreters 在测试期间替换全局解释器映射
+func withInterpreters(t *testing.T, reg interpreterRegistry) {
+	t.Helper()
+	saved := Interpreters
+	Interpreters = reg
+	t.Cleanup(func() { Interpreters = saved })
+}
+
+func TestLoadInterpretersFileAndEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "interpreters")
+	config := "# 镜像内安装的解释器\npython3.12=/opt/python3.12/bin/python3\n\nnodejs22 = /opt/node22/bin/node\n"
+	if err := os.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg, err := loadInterpreters(file, "python3.12=/venv/bin/python,python3.11=/usr/bin/python3.11")
+	if err != nil {
+		t.Fatalf("loadInterpreters() error = %v", err)
+	}
+	want := map[string]string{
+		"python3.11": "/usr/bin/python3.11",  // 环境变量覆盖默认值
+		"python3.12": "/venv/bin/python",     // 环境变量覆盖配置文件
+		"nodejs22":   "/opt/node22/bin/node", // 来自配置文件
+		"nodejs20":   "node",                 // 默认值
+		"java17":     "java",
+	}
+	for name, path := range want {
+		if reg[name] != path {
+			t.Errorf("%s = %q, want %q", name, reg[name], path)
+		}
+	}
+
+	// 配置文件不存在时只使用默认值
+	reg, err = loadInterpreters(filepath.Join(t.TempDir(), "missing"), "")
+	if err != nil || reg.String() != defaultInterpreters().String() {
+		t.Errorf("missing file: %v, %v; want defaults", reg, err)
+	}
+
+	for _, spec := range []string{"python3.12", "python3.12=", "go1.25=/usr/bin/go", "perl5=/usr/bin/perl"} {
+		if _, err := parseInterpreters(spec); err == nil {
+			t.Errorf("parseInterpreters(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestNewRuntimeResolvesRegisteredVersions(t *testing.T) {
+	withInterpreters(t, interpreterRegistry{"python3.11": "python3", "python3.12": "/opt/python3.12/bin/python3", "nodejs22": "/opt/node22/bin/node"})
+
+	rt, err := newRuntime("python3.12")
+	if err != nil {
+		t.Fatalf("newRuntime(python3.12) error = %v", err)
+	}
+	if py, ok := rt.(*PythonRuntime); !ok || py.interpreter() != "/opt/python3.12/bin/python3" {
+		t.Errorf("python3.12 runtime = %#v, want PythonRuntime using /opt/python3.12/bin/python3", rt)
+	}
+	rt, _ = newRuntime("nodejs22")
+	if node, ok := rt.(*NodeRuntime); !ok || node.interpreter() != "/opt/node22/bin/node" {
+		t.Errorf("nodejs22 runtime = %#v, want NodeRuntime using /opt/node22/bin/node", rt)
+	}
+	if _, err := newRuntime("go1.24"); err != nil {
+		t.Errorf("newRuntime(go1.24) error = %v, compiled runtimes need no interpreter", err)
+	}
+	for _, name := range []string{"python3.13", "nodejs20", "perl5"} {
+		if _, err := newRuntime(name); err == nil {
+			t.Errorf("newRuntime(%s) succeeded, want unsupported runtime", name)
+		}
+	}
+	if err := (&Agent{}).writeCode(&InitPayload{Runtime: "python3.13", Code: "x"}); err == nil {
+		t.Errorf("writeCode(python3.13) succeeded, want unsupported runtime")
+	}
+}
+
+func TestPythonRuntimeExecutesConfiguredInterpreter(t *testing.T) {
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+	// 假解释器忽略包装脚本参数，直接输出自己的标识
+	fake := filepath.Join(t.TempDir(), "python3.12")
+	if err := os.WriteFile(fake, []byte("#!/bin/sh\necho '{\"interpreter\":\"python3.12\"}'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	withInterpreters(t, interpreterRegistry{"python3.12": fake})
+
+	rt, err := newRuntime("python3.12")
+	if err != nil {
+		t.Fatalf("newRuntime() error = %v", err)
+	}
+	output, err := rt.Execute(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(output, &got); err != nil || got["interpreter"] != "python3.12" {
+		t.Errorf("Execute() output = %s, want output of the configured interpreter", output)
+	}
+}
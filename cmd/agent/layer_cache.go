@@ -0,0 +1,99 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// layerManifestFile 是记录已解压层的清单文件名，位于 LayersDir 下
+const layerManifestFile = ".nimbus-layers.json"
+
+// layerManifest 记录每个层目录当前解压的内容，键为层 ID，值为 layerCacheKey
+type layerManifest map[string]string
+
+// layerContentHash 计算层内容的 SHA-256 哈希（十六进制）
+func layerContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// layerCacheKey 返回层的缓存键 layer_id:version:content_hash
+// 哈希按实际内容计算，宿主机未提供 ContentHash 时缓存同样有效
+func layerCacheKey(layer *LayerInfo) string {
+	return fmt.Sprintf("%s:%d:%s", layer.LayerID, layer.Version, layerContentHash(layer.Content))
+}
+
+// loadLayerManifest 读取已解压层清单，文件不存在或损坏时返回空清单（所有层重新解压）
+func loadLayerManifest() layerManifest {
+	m := make(layerManifest)
+	data, err := os.ReadFile(filepath.Join(LayersDir, layerManifestFile))
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return make(layerManifest)
+	}
+	return m
+}
+
+// save 将清单写入磁盘，先写临时文件再重命名，避免中途失败留下损坏的清单
+func (m layerManifest) save() error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(LayersDir, layerManifestFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// extractLayer 将层解压到 layerDir，目录中已是相同内容时跳过
+// 需要解压时先从清单中移除该层并清空目录，解压成功后再记录新的缓存键，
+// 这样中途失败不会让清单指向不完整的目录。
+//
+// 参数:
+//   - m: 已解压层清单
+//   - layer: 层信息
+//   - layerDir: 层解压目录
+//
+// 返回:
+//   - bool: 是否命中缓存（未做解压）
+//   - error: 清理、解压或写清单失败
+func extractLayer(m layerManifest, layer *LayerInfo, layerDir string) (bool, error) {
+	key := layerCacheKey(layer)
+	if m[layer.LayerID] == key {
+		if info, err := os.Stat(layerDir); err == nil && info.IsDir() {
+			return true, nil
+		}
+	}
+
+	delete(m, layer.LayerID)
+	if err := m.save(); err != nil {
+		return false, fmt.Errorf("failed to update layer manifest: %w", err)
+	}
+	if err := os.RemoveAll(layerDir); err != nil {
+		return false, fmt.Errorf("failed to clean layer directory %s: %w", layer.LayerID, err)
+	}
+	if err := os.MkdirAll(layerDir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create layer directory %s: %w", layer.LayerID, err)
+	}
+	if err := extractArchive(layer.Content, layerDir); err != nil {
+		return false, fmt.Errorf("failed to extract layer %s: %w", layer.LayerID, err)
+	}
+
+	m[layer.LayerID] = key
+	if err := m.save(); err != nil {
+		return false, fmt.Errorf("failed to update layer manifest: %w", err)
+	}
+	return false, nil
+}
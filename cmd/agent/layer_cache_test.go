@@ -0,0 +1,92 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// zipLayer 构造包含给定文件的层 ZIP 包
+func zipLayer(files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+// setupTestLayer 初始化只包含一个层的函数，返回层目录
+func setupTestLayer(tb testing.TB, layer LayerInfo) string {
+	tb.Helper()
+	if err := (&Agent{}).setupLayers(&InitPayload{Runtime: "go1.24", Layers: []LayerInfo{layer}}); err != nil {
+		tb.Fatalf("setupLayers() error = %v", err)
+	}
+	return filepath.Join(LayersDir, layer.LayerID)
+}
+
+func TestSetupLayersReusesExtractedLayer(t *testing.T) {
+	if err := os.MkdirAll(LayersDir, 0755); err != nil {
+		t.Skipf("layers dir %s is not writable: %v", LayersDir, err)
+	}
+	layer := LayerInfo{LayerID: "test-layer-cache", Version: 1, Content: zipLayer(map[string]string{"lib/a.txt": "v1"})}
+	t.Cleanup(func() { os.RemoveAll(filepath.Join(LayersDir, layer.LayerID)) })
+
+	dir := setupTestLayer(t, layer)
+	if data, err := os.ReadFile(filepath.Join(dir, "lib", "a.txt")); err != nil || string(data) != "v1" {
+		t.Fatalf("first init did not extract the layer: %q, %v", data, err)
+	}
+
+	// 删除解压出的文件：第二次初始化如果重新解压会把它恢复
+	os.Remove(filepath.Join(dir, "lib", "a.txt"))
+	setupTestLayer(t, layer)
+	if _, err := os.Stat(filepath.Join(dir, "lib", "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("second init of an identical layer extracted it again")
+	}
+
+	// 内容变化时清空目录并重新解压
+	layer.Content = zipLayer(map[string]string{"lib/b.txt": "v1-rebuilt"})
+	setupTestLayer(t, layer)
+	if data, err := os.ReadFile(filepath.Join(dir, "lib", "b.txt")); err != nil || string(data) != "v1-rebuilt" {
+		t.Errorf("changed layer content was not extracted: %q, %v", data, err)
+	}
+	if m := loadLayerManifest(); m[layer.LayerID] != layerCacheKey(&layer) {
+		t.Errorf("manifest entry = %q, want %q", m[layer.LayerID], layerCacheKey(&layer))
+	}
+}
+
+// BenchmarkSetupLayers 比较层首次解压和重新初始化（命中缓存）的耗时
+func BenchmarkSetupLayers(b *testing.B) {
+	if err := os.MkdirAll(LayersDir, 0755); err != nil {
+		b.Skipf("layers dir %s is not writable: %v", LayersDir, err)
+	}
+	files := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		files[fmt.Sprintf("python/pkg/mod%03d.py", i)] = strings.Repeat("x = 1\n", 500)
+	}
+	layer := LayerInfo{LayerID: "bench-layer-cache", Version: 1, Content: zipLayer(files)}
+	b.Cleanup(func() { os.RemoveAll(filepath.Join(LayersDir, layer.LayerID)) })
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			os.Remove(filepath.Join(LayersDir, layerManifestFile))
+			setupTestLayer(b, layer)
+		}
+	})
+	b.Run("reinit", func(b *testing.B) {
+		setupTestLayer(b, layer)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			setupTestLayer(b, layer)
+		}
+	})
+}
@@ -0,0 +1,254 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 函数日志转发相关常量
+const (
+	// logQueueSize 是等待发送的日志行队列长度，队列满时丢弃新的日志行，避免宿主机读取缓慢时阻塞函数
+	logQueueSize = 1024
+	// maxLogLineBytes 是单行日志的最大字节数，超出部分拆分为多行
+	maxLogLineBytes = 16 * 1024
+	// logLevelScanBytes 是推断日志级别时检查的行首字节数
+	logLevelScanBytes = 48
+)
+
+// LogPayload 定义函数日志消息的载荷结构
+type LogPayload struct {
+	Timestamp time.Time `json:"timestamp"` // 读到该行的时间
+	Stream    string    `json:"stream"`    // 来源：stdout 或 stderr
+	Level     string    `json:"level"`     // 推断的日志级别：debug、info、warn、error
+	Message   string    `json:"message"`   // 日志内容（不含换行符）
+}
+
+// logForwarder 把一次执行中函数的输出按行转发给宿主机
+// 子进程的输出经 lineWriter 按行切分后进入队列，由独立的协程逐条以 MessageTypeLog 发送，
+// 函数的执行不会因宿主机读取缓慢而阻塞；队列满时丢弃新的日志行，并在结束时补发一条丢弃提示。
+type logForwarder struct {
+	requestID string
+	send      func(*Message) error
+	queue     chan *LogPayload
+	done      chan struct{}
+
+	mu      sync.Mutex
+	writers []*lineWriter
+	dropped int
+	closed  bool
+}
+
+// logForwarderKey 是 logForwarder 在上下文中的键
+type logForwarderKey struct{}
+
+// newLogForwarder 创建日志转发器并启动发送协程
+//
+// 参数:
+//   - requestID: 执行请求 ID，日志消息使用相同的请求 ID
+//   - send: 发送消息，与流式输出分片共用连接
+func newLogForwarder(requestID string, send func(*Message) error) *logForwarder {
+	f := &logForwarder{
+		requestID: requestID,
+		send:      send,
+		queue:     make(chan *LogPayload, logQueueSize),
+		done:      make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+// withLogForwarder 返回携带日志转发器的上下文
+// 运行时通过 runCommand/streamCommand 执行子进程时，输出会按行转发
+func withLogForwarder(ctx context.Context, f *logForwarder) context.Context {
+	return context.WithValue(ctx, logForwarderKey{}, f)
+}
+
+// logForwarderFrom 返回上下文中的日志转发器，没有时返回 nil
+func logForwarderFrom(ctx context.Context) *logForwarder {
+	f, _ := ctx.Value(logForwarderKey{}).(*logForwarder)
+	return f
+}
+
+// teeLogs 在上下文携带日志转发器时，让子进程的输出同时写入转发器
+func teeLogs(ctx context.Context, w io.Writer, stream string) io.Writer {
+	f := logForwarderFrom(ctx)
+	if f == nil {
+		return w
+	}
+	return io.MultiWriter(w, f.writer(stream))
+}
+
+// run 逐条发送队列中的日志，发送失败后丢弃剩余日志（连接已不可用）
+func (f *logForwarder) run() {
+	defer close(f.done)
+	var sendErr error
+	for entry := range f.queue {
+		if sendErr != nil {
+			continue
+		}
+		payload, _ := json.Marshal(entry)
+		sendErr = f.send(&Message{Type: MessageTypeLog, RequestID: f.requestID, Payload: payload})
+	}
+}
+
+// writer 返回指定输出流的按行缓冲写入器
+func (f *logForwarder) writer(stream string) *lineWriter {
+	w := &lineWriter{f: f, stream: stream}
+	f.mu.Lock()
+	f.writers = append(f.writers, w)
+	f.mu.Unlock()
+	return w
+}
+
+// enqueue 将一行日志放入发送队列，转发器关闭后忽略
+func (f *logForwarder) enqueue(stream, line string) {
+	entry := &LogPayload{Timestamp: time.Now(), Stream: stream, Level: inferLogLevel(line), Message: line}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	select {
+	case f.queue <- entry:
+	default:
+		f.dropped++
+	}
+}
+
+// splitStdout 从子进程的完整标准输出中分离函数日志和结果
+// 包装脚本在最后一行输出 JSON 结果，函数自己打印的内容位于其前，这些行作为 stdout 日志转发；
+// 整个输出本身是合法 JSON（如多行格式化的结果）或最后一行不是合法 JSON 时不做拆分，原样返回。
+//
+// 参数:
+//   - stdout: 子进程的完整标准输出
+//
+// 返回:
+//   - []byte: 函数结果
+func (f *logForwarder) splitStdout(stdout []byte) []byte {
+	trimmed := bytes.TrimRight(stdout, "\r\n")
+	if json.Valid(trimmed) {
+		return stdout
+	}
+	i := bytes.LastIndexByte(trimmed, '\n')
+	if i < 0 || !json.Valid(trimmed[i+1:]) {
+		return stdout
+	}
+	f.writer("stdout").Write(trimmed[:i+1])
+	return trimmed[i+1:]
+}
+
+// Close 转发各输出流末尾未换行的内容，并等待队列中的日志全部发送
+// 必须在发送最终响应之前调用，保证日志消息先于响应到达宿主机
+func (f *logForwarder) Close() {
+	f.mu.Lock()
+	writers := f.writers
+	f.mu.Unlock()
+	for _, w := range writers {
+		w.flush()
+	}
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	if f.dropped > 0 {
+		// 发送协程持续消费队列，这里阻塞等待空位不会死锁
+		f.queue <- &LogPayload{
+			Timestamp: time.Now(),
+			Stream:    "stderr",
+			Level:     "warn",
+			Message:   fmt.Sprintf("[nimbus] %d log lines dropped", f.dropped),
+		}
+	}
+	close(f.queue)
+	f.mu.Unlock()
+	<-f.done
+}
+
+// lineWriter 将写入的内容按行切分后交给日志转发器，空行被忽略
+type lineWriter struct {
+	f      *logForwarder
+	stream string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write 追加输出并转发其中完整的行，总是返回 len(p)
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	// 超长的行按上限拆分，避免无换行的输出占用过多内存
+	for len(w.buf) >= maxLogLineBytes {
+		w.emit(w.buf[:maxLogLineBytes])
+		w.buf = w.buf[maxLogLineBytes:]
+	}
+	return len(p), nil
+}
+
+// flush 转发末尾未换行的内容
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.emit(w.buf)
+	w.buf = nil
+}
+
+// emit 转发一行日志
+func (w *lineWriter) emit(line []byte) {
+	s := strings.TrimRight(string(line), "\r")
+	if strings.TrimSpace(s) == "" {
+		return
+	}
+	w.f.enqueue(w.stream, s)
+}
+
+// inferLogLevel 根据行首的级别关键字推断日志级别，无法识别时为 info
+// 只检查行首附近，避免日志正文中偶然出现的单词影响级别；Python 异常堆栈的首行（Traceback）视为 error
+func inferLogLevel(line string) string {
+	head := line
+	if len(head) > logLevelScanBytes {
+		head = head[:logLevelScanBytes]
+	}
+	head = strings.ToUpper(head)
+	switch {
+	case containsAny(head, "ERROR", "FATAL", "CRITICAL", "PANIC", "EXCEPTION", "TRACEBACK"):
+		return "error"
+	case strings.Contains(head, "WARN"):
+		return "warn"
+	case containsAny(head, "DEBUG", "TRACE"):
+		return "debug"
+	default:
+		return "info"
+	}
+}
+
+// containsAny 判断 s 是否包含任一子串
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
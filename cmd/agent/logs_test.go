@@ -0,0 +1,132 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// shellRuntime 通过 sh 执行脚本，模拟包装脚本在最后一行输出结果的运行时
+type shellRuntime struct{ script string }
+
+func (r *shellRuntime) Init(*InitPayload) error { return nil }
+
+func (r *shellRuntime) Execute(ctx context.Context, _ json.RawMessage) (json.RawMessage, error) {
+	out, err := runCommand(ctx, exec.CommandContext(ctx, "sh", "-c", r.script), "sh error")
+	return json.RawMessage(out), err
+}
+
+func TestExecForwardsLogLines(t *testing.T) {
+	const n = 5
+	script := fmt.Sprintf(`i=1; while [ $i -le %d ]; do echo "line $i"; i=$((i+1)); done; echo "WARNING: low disk" >&2; echo '{"ok":true}'`, n)
+	agent := &Agent{initialized: true, runtime: &shellRuntime{script: script}, config: &InitPayload{TimeoutSec: 5}}
+	host, guest := net.Pipe()
+	defer host.Close()
+	go agent.handleConnection(context.Background(), guest)
+
+	payload, _ := json.Marshal(&ExecPayload{Input: json.RawMessage(`{}`), ForwardLogs: true})
+	if err := writeMessage(host, &Message{Type: MessageTypeExec, RequestID: "req-1", Payload: payload}); err != nil {
+		t.Fatalf("writeMessage() error = %v", err)
+	}
+
+	var stdout, stderr []LogPayload
+	var resp ResponsePayload
+	for done := false; !done; {
+		msg, err := readMessage(host)
+		if err != nil {
+			t.Fatalf("readMessage() error = %v", err)
+		}
+		if msg.RequestID != "req-1" {
+			t.Fatalf("message request id = %q, want req-1", msg.RequestID)
+		}
+		switch msg.Type {
+		case MessageTypeLog:
+			var entry LogPayload
+			if err := json.Unmarshal(msg.Payload, &entry); err != nil {
+				t.Fatalf("invalid log payload: %v", err)
+			}
+			if entry.Stream == "stdout" {
+				stdout = append(stdout, entry)
+			} else {
+				stderr = append(stderr, entry)
+			}
+		case MessageTypeResp:
+			if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			done = true
+		default:
+			t.Fatalf("unexpected message type %d", msg.Type)
+		}
+	}
+
+	if len(stdout) != n {
+		t.Fatalf("received %d stdout log lines, want %d", len(stdout), n)
+	}
+	for i, entry := range stdout {
+		if want := fmt.Sprintf("line %d", i+1); entry.Message != want || entry.Level != "info" {
+			t.Errorf("stdout line %d = %+v, want info %q", i, entry, want)
+		}
+	}
+	if len(stderr) != 1 || stderr[0].Level != "warn" {
+		t.Errorf("stderr lines = %+v, want one warn line", stderr)
+	}
+	// 函数打印的内容不再混入结果
+	if !resp.Success || string(resp.Output) != `{"ok":true}` {
+		t.Errorf("response = %+v, want success with output {\"ok\":true}", resp)
+	}
+}
+
+func TestSplitStdoutKeepsWholeJSON(t *testing.T) {
+	f := newLogForwarder("req-1", func(*Message) error { return nil })
+	defer f.Close()
+	pretty := "{\n  \"ok\": true\n}\n"
+	if got := string(f.splitStdout([]byte(pretty))); got != pretty {
+		t.Errorf("splitStdout(pretty JSON) = %q, want unchanged", got)
+	}
+	plain := "hello\nworld\n"
+	if got := string(f.splitStdout([]byte(plain))); got != plain {
+		t.Errorf("splitStdout(non-JSON result) = %q, want unchanged", got)
+	}
+}
+
+func TestLineWriterSplitsLongLines(t *testing.T) {
+	var lines []string
+	f := newLogForwarder("req-1", func(m *Message) error {
+		var entry LogPayload
+		json.Unmarshal(m.Payload, &entry)
+		lines = append(lines, entry.Message)
+		return nil
+	})
+	w := f.writer("stderr")
+	w.Write([]byte("first\r\n\nsec"))
+	w.Write([]byte("ond\n" + strings.Repeat("x", maxLogLineBytes+10)))
+	f.Close()
+
+	if len(lines) != 4 || lines[0] != "first" || lines[1] != "second" || len(lines[2]) != maxLogLineBytes || len(lines[3]) != 10 {
+		t.Errorf("lines = %d %q..., want first, second and a split long line", len(lines), lines[:min(len(lines), 2)])
+	}
+}
+
+func TestInferLogLevel(t *testing.T) {
+	tests := map[string]string{
+		"Traceback (most recent call last):": "error",
+		"ERROR:root:connection refused":      "error",
+		"[warn] cache miss":                  "warn",
+		"2024-01-01 DEBUG loading":           "debug",
+		"processing order 42":                "info",
+		strings.Repeat("a", logLevelScanBytes) + " error later in the line": "info",
+	}
+	for line, want := range tests {
+		if got := inferLogLevel(line); got != want {
+			t.Errorf("inferLogLevel(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
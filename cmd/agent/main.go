@@ -3,25 +3,31 @@
 
 // Package main 是函数执行代理的入口点
 // Agent 运行在 Firecracker 虚拟机内部，负责接收和执行函数调用
-// 它通过 vsock 与宿主机通信，支持多种运行时（Python、Node.js、Go、WebAssembly）
+// 它通过 vsock 与宿主机通信，支持多种运行时（Python、Node.js、Ruby、Go、Java、WebAssembly）
 package main
 
 import (
 	"archive/zip"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -33,20 +39,39 @@ import (
 
 // 常量定义
 const (
-	VsockPort        = 9999 // vsock 监听端口，用于与宿主机通信
-	MessageTypeInit  = 1    // 消息类型：初始化
-	MessageTypeExec  = 2    // 消息类型：执行函数
-	MessageTypeResp  = 3    // 消息类型：响应
-	MessageTypePing  = 4    // 消息类型：心跳检测
-	MessageTypePong  = 5    // 消息类型：心跳响应
-	MessageTypeDebug = 6    // 消息类型：调试
-	MessageTypeState = 7    // 消息类型：状态操作
+	VsockPort            = 9999 // vsock 监听端口，用于与宿主机通信
+	MessageTypeInit      = 1    // 消息类型：初始化
+	MessageTypeExec      = 2    // 消息类型：执行函数
+	MessageTypeResp      = 3    // 消息类型：响应
+	MessageTypePing      = 4    // 消息类型：心跳检测
+	MessageTypePong      = 5    // 消息类型：心跳响应
+	MessageTypeDebug     = 6    // 消息类型：调试
+	MessageTypeState     = 7    // 消息类型：状态操作
+	MessageTypeRespChunk = 8    // 消息类型：流式输出分片（最终仍以 MessageTypeResp 结束）
+	MessageTypeLog       = 9    // 消息类型：函数日志（执行期间推送，每条一行，最终仍以 MessageTypeResp 结束）
 
 	FunctionDir = "/var/function" // 函数代码存储目录
 	LayersDir   = "/opt/layers"   // 层内容存储目录
 	StateAPIPort = 9998           // 状态 API 监听端口（HTTP）
+	StateVsockPort = 9997         // 宿主机状态服务的 vsock 端口，状态 API 请求经此转发
 )
 
+// 消息大小限制
+const (
+	// DefaultMaxMessageBytes 是单条消息体的默认最大字节数（32MB）
+	DefaultMaxMessageBytes = 32 * 1024 * 1024
+	// EnvMaxMessageBytes 是覆盖消息大小上限的 Agent 环境变量（字节数）
+	EnvMaxMessageBytes = "NIMBUS_MAX_MESSAGE_BYTES"
+)
+
+// MaxMessageBytes 是读写单条消息体允许的最大字节数。
+// 读取时在分配缓冲区之前检查长度前缀，防止异常的长度值导致内存耗尽；
+// 写入时同样检查，避免发出对端会拒绝的消息。
+var MaxMessageBytes = DefaultMaxMessageBytes
+
+// ErrMessageTooLarge 表示消息体超过 MaxMessageBytes
+var ErrMessageTooLarge = errors.New("message too large")
+
 // Message 定义 Agent 与宿主机之间的通信消息格式
 // 所有通信都通过 JSON 序列化的消息进行
 type Message struct {
@@ -58,50 +83,78 @@ type Message struct {
 // InitPayload 定义函数初始化请求的载荷结构
 // 宿主机发送此载荷来配置 Agent 执行特定函数
 type InitPayload struct {
-	FunctionID    string            `json:"function_id"`              // 函数唯一标识
-	Handler       string            `json:"handler"`                  // 处理函数入口点（如 handler.main）
-	Code          string            `json:"code"`                     // 函数代码（base64 编码或明文）
-	Runtime       string            `json:"runtime"`                  // 运行时类型（python3.11、nodejs20、go1.24、wasm）
-	EnvVars       map[string]string `json:"env_vars,omitempty"`       // 环境变量
-	MemoryLimitMB int               `json:"memory_limit_mb"`          // 内存限制（MB）
-	TimeoutSec    int               `json:"timeout_sec"`              // 执行超时时间（秒）
-	Layers        []LayerInfo       `json:"layers,omitempty"`         // 函数层列表（可选）
-	StateEnabled  bool              `json:"state_enabled,omitempty"`  // 是否启用状态功能
-	SessionKey    string            `json:"session_key,omitempty"`    // 会话标识（有状态函数）
+	FunctionID     string            `json:"function_id"`               // 函数唯一标识
+	Handler        string            `json:"handler"`                   // 处理函数入口点（如 handler.main）
+	Code           string            `json:"code"`                      // 函数代码（base64 编码或明文）
+	CodeHash       string            `json:"code_hash,omitempty"`       // 代码的 SHA-256 哈希，相同函数的重复初始化据此复用运行时
+	Runtime        string            `json:"runtime"`                   // 运行时类型（python3.11、nodejs20、ruby3.2、go1.24、java17、wasm）
+	EnvVars        map[string]string `json:"env_vars,omitempty"`        // 环境变量
+	MemoryLimitMB  int               `json:"memory_limit_mb"`           // 内存限制（MB）
+	TimeoutSec     int               `json:"timeout_sec"`               // 执行超时时间（秒）
+	Layers         []LayerInfo       `json:"layers,omitempty"`          // 函数层列表（可选）
+	StateEnabled   bool              `json:"state_enabled,omitempty"`   // 是否启用状态功能
+	SessionKey     string            `json:"session_key,omitempty"`     // 会话标识（有状态函数）
+	AcceptEncoding []string          `json:"accept_encoding,omitempty"` // 宿主机支持的消息压缩编码（如 gzip）
+
+	// InstallDeps 为 true 时，Python/Node.js 代码包中有 requirements.txt 或 package.json 则在初始化时安装依赖
+	InstallDeps bool `json:"install_deps,omitempty"`
+	// InstallDepsTimeoutSec 是安装依赖的超时时间（秒），不大于 0 时使用 DefaultInstallDepsTimeoutSec
+	InstallDepsTimeoutSec int `json:"install_deps_timeout_sec,omitempty"`
+
+	// WarmupHandler 是初始化后、报告成功前调用的预热函数（如 handler.warmup，只写函数名时与 Handler 同模块）
+	WarmupHandler string `json:"warmup_handler,omitempty"`
+	// WarmupTimeoutSec 是预热的超时时间（秒），不大于 0 时使用 DefaultWarmupTimeoutSec
+	WarmupTimeoutSec int `json:"warmup_timeout_sec,omitempty"`
 }
 
 // LayerInfo 表示函数层的信息
 // 包含层的标识、版本、内容和加载顺序
 type LayerInfo struct {
-	LayerID string `json:"layer_id"` // 层唯一标识符
-	Version int    `json:"version"`  // 层版本号
-	Content []byte `json:"content"`  // 层内容（ZIP 压缩包）
-	Order   int    `json:"order"`    // 加载顺序（小的先加载）
+	LayerID     string `json:"layer_id"`               // 层唯一标识符
+	Version     int    `json:"version"`                // 层版本号
+	Content     []byte `json:"content"`                // 层内容（ZIP 或 tar.gz 压缩包）
+	Order       int    `json:"order"`                  // 加载顺序（小的先加载）
+	ContentHash string `json:"content_hash,omitempty"` // 层内容的 SHA-256 哈希（十六进制），为空时不校验
 }
 
 // ExecPayload 定义函数执行请求的载荷结构
 type ExecPayload struct {
-	Input      json.RawMessage `json:"input"`                  // 函数输入参数，作为 JSON 传递给函数
-	SessionKey string          `json:"session_key,omitempty"`  // 会话标识（有状态函数）
+	Input       json.RawMessage `json:"input"`                  // 函数输入参数，作为 JSON 传递给函数
+	SessionKey  string          `json:"session_key,omitempty"`  // 会话标识（有状态函数）
+	Stream      bool            `json:"stream,omitempty"`       // 是否以 MessageTypeRespChunk 分片流式返回输出
+	ForwardLogs bool            `json:"forward_logs,omitempty"` // 是否以 MessageTypeLog 消息转发函数的标准输出/标准错误
+
+	// InputRef 是按引用传递的输入（对象存储的预签名 URL 或 file:// 路径），设置时忽略 Input，
+	// Agent 下载后以标准输入流的方式交给函数，大体积输入不再经过消息通道
+	InputRef string `json:"input_ref,omitempty"`
 }
 
 // StatePayload 定义状态操作请求的载荷结构
 type StatePayload struct {
-	Operation string          `json:"operation"`           // 操作类型: get, set, delete, incr, exists, keys, expire
-	Scope     string          `json:"scope"`               // 作用域: session, function, invocation
-	Key       string          `json:"key"`                 // 状态键
-	Value     json.RawMessage `json:"value,omitempty"`     // 状态值（set 时使用）
-	TTL       int             `json:"ttl,omitempty"`       // 过期时间（秒）
-	Delta     int64           `json:"delta,omitempty"`     // 增量（incr 时使用）
-	Version   int64           `json:"version,omitempty"`   // 版本号（乐观锁）
+	FunctionID      string          `json:"function_id,omitempty"`      // 函数 ID（为空时使用初始化时的函数 ID）
+	SessionKey      string          `json:"session_key,omitempty"`      // 会话标识（为空时使用初始化时的会话标识）
+	InvocationID    string          `json:"invocation_id,omitempty"`    // 调用 ID（invocation 作用域使用）
+	Operation       string          `json:"operation"`                  // 操作类型: get, set, delete, incr, exists, keys, expire
+	Scope           string          `json:"scope"`                      // 作用域: session, function, invocation
+	Key             string          `json:"key"`                        // 状态键
+	Value           json.RawMessage `json:"value,omitempty"`            // 状态值（set 时使用）
+	TTL             int             `json:"ttl,omitempty"`              // 过期时间（秒）
+	Delta           int64           `json:"delta,omitempty"`            // 增量（incr 时使用）
+	Version         int64           `json:"version,omitempty"`          // 旧版期望版本号（乐观锁，0 表示不检查）
+	ExpectedVersion *int64          `json:"expected_version,omitempty"` // 期望版本号（compare-and-set，0 表示键尚不存在）
+	Cursor          string          `json:"cursor,omitempty"`           // keys 分页游标（为空时从头开始）
+	Count           int             `json:"count,omitempty"`            // keys 每页最大数量（为 0 时使用宿主机默认值）
 }
 
 // StateResponsePayload 定义状态操作响应的载荷结构
 type StateResponsePayload struct {
-	Success bool            `json:"success"`
-	Value   json.RawMessage `json:"value,omitempty"`
-	Version int64           `json:"version,omitempty"`
-	Error   string          `json:"error,omitempty"`
+	Success   bool            `json:"success"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Version   int64           `json:"version,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	ErrorCode string          `json:"error_code,omitempty"` // 错误码：backend_unavailable（Redis 不可用）或 operation_error
+	Degraded  bool            `json:"degraded,omitempty"`   // 结果是否来自降级路径（读为默认值，写已缓冲）
+	Cursor    string          `json:"cursor,omitempty"`     // keys 的下一页游标，为空表示已遍历完成
 }
 
 // ResponsePayload 定义函数执行响应的载荷结构
@@ -111,21 +164,32 @@ type ResponsePayload struct {
 	Error        string          `json:"error,omitempty"`        // 错误信息（如果执行失败）
 	DurationMs   int64           `json:"duration_ms"`            // 执行耗时（毫秒）
 	MemoryUsedMB int             `json:"memory_used_mb"`         // 内存使用量（MB）
+	Chunks       int             `json:"chunks,omitempty"`       // 流式执行时已发送的输出分片数
+	Stderr       string          `json:"stderr,omitempty"`       // 子进程的标准错误输出（超过 MaxStderrBytes 时截断）
+	OutputPrefix string          `json:"output_prefix,omitempty"` // 输出超过 MaxOutputBytes 时输出开头的一段，用于排查
 }
 
 // Agent 是函数执行代理的核心结构
 // 它管理运行时初始化和函数执行
 type Agent struct {
+	mu           sync.RWMutex  // 保护 initialized、config 和 runtime，初始化与执行、状态请求可能来自不同连接
 	initialized  bool          // 是否已初始化
 	config       *InitPayload  // 当前函数配置
 	runtime      Runtime       // 当前使用的运行时
 	debugManager *DebugManager // 调试管理器
 	stateConn    net.Conn      // 状态操作连接（与宿主机通信）
 	sessionKey   string        // 当前会话标识
+
+	stateMu     sync.Mutex               // 保护 stateConn，状态请求在连接上逐个往返
+	stateDial   func() (net.Conn, error) // 建立状态连接，为空时通过 vsock 连接宿主机
+	stateSeq    uint64                   // 状态请求序号，用于生成请求 ID
+	stateServer *http.Server             // 本地状态 HTTP API 服务（启用状态功能后启动）
+
+	pythonWarmWorker bool // Python 函数是否使用常驻进程模式（由 NIMBUS_PYTHON_WARM_WORKER 启用）
 }
 
 // Runtime 定义运行时接口
-// 所有支持的运行时（Python、Node.js、Go、WebAssembly）都必须实现此接口
+// 所有支持的运行时（Python、Node.js、Ruby、Go、Java、WebAssembly）都必须实现此接口
 type Runtime interface {
 	// Init 初始化运行时环境
 	// 包括创建包装脚本、编译代码等准备工作
@@ -141,8 +205,33 @@ type Runtime interface {
 func main() {
 	fmt.Println("Function Agent starting...")
 
+	warmWorker, _ := strconv.ParseBool(os.Getenv(EnvPythonWarmWorker))
+	if v, err := strconv.Atoi(os.Getenv(EnvMaxMessageBytes)); err == nil && v > 0 {
+		MaxMessageBytes = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv(EnvMaxInputRefBytes), 10, 64); err == nil && v > 0 {
+		MaxInputRefBytes = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(EnvMaxStderrBytes)); err == nil && v > 0 {
+		MaxStderrBytes = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(EnvMaxOutputBytes)); err == nil && v > 0 {
+		MaxOutputBytes = v
+	}
+	interpreters, err := loadInterpreters(InterpretersFile, os.Getenv(EnvInterpreters))
+	if err != nil {
+		fmt.Printf("Invalid interpreter configuration: %v\n", err)
+	}
+	Interpreters = interpreters
+	fmt.Printf("Interpreters: %s\n", Interpreters)
 	agent := &Agent{
-		debugManager: NewDebugManager(),
+		debugManager:     NewDebugManager(),
+		pythonWarmWorker: warmWorker,
+	}
+
+	// IPv6 配置失败不影响 vsock 通信，函数仍可通过 IPv4 访问网络
+	if err := configureIPv6(); err != nil {
+		fmt.Printf("Failed to configure IPv6: %v\n", err)
 	}
 
 	// 在 vsock 端口上监听连接
@@ -195,6 +284,11 @@ func main() {
 func (a *Agent) handleConnection(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
+	// 是否向该连接发送压缩消息，初始化时与宿主机协商
+	compress := false
+	// 流式输出分片和日志消息可能由不同协程发送，写入连接时需要串行
+	var sendMu sync.Mutex
+
 	// 循环处理消息
 	for {
 		// 读取下一条消息
@@ -206,12 +300,25 @@ func (a *Agent) handleConnection(ctx context.Context, conn net.Conn) {
 			return
 		}
 
-		// 处理消息并发送响应
-		resp := a.handleMessage(ctx, msg)
-		if err := writeMessage(conn, resp); err != nil {
+		// 处理消息并发送响应，流式执行的中间分片通过 send 直接写入连接
+		send := func(m *Message) error {
+			sendMu.Lock()
+			defer sendMu.Unlock()
+			return writeFrame(conn, m, compress)
+		}
+		resp := a.handleMessage(ctx, msg, send)
+		err = writeFrame(conn, resp, compress)
+		if errors.Is(err, ErrMessageTooLarge) {
+			// 响应超过大小上限时改为返回错误，避免宿主机一直等待到超时
+			err = writeMessage(conn, errorResponse(msg.RequestID, err.Error()))
+		}
+		if err != nil {
 			fmt.Printf("Write error: %v\n", err)
 			return
 		}
+		if msg.Type == MessageTypeInit {
+			compress = negotiatedGzip(resp)
+		}
 	}
 }
 
@@ -220,10 +327,11 @@ func (a *Agent) handleConnection(ctx context.Context, conn net.Conn) {
 // 参数:
 //   - ctx: 上下文
 //   - msg: 接收到的消息
+//   - send: 在最终响应之前发送中间消息（如流式输出分片）
 //
 // 返回:
 //   - *Message: 响应消息
-func (a *Agent) handleMessage(ctx context.Context, msg *Message) *Message {
+func (a *Agent) handleMessage(ctx context.Context, msg *Message, send func(*Message) error) *Message {
 	switch msg.Type {
 	case MessageTypePing:
 		// 心跳检测，直接返回 Pong
@@ -238,7 +346,7 @@ func (a *Agent) handleMessage(ctx context.Context, msg *Message) *Message {
 
 	case MessageTypeExec:
 		// 执行请求，运行函数
-		return a.handleExec(ctx, msg)
+		return a.handleExec(ctx, msg, send)
 
 	case MessageTypeDebug:
 		// 调试请求，处理 DAP 消息
@@ -269,6 +377,15 @@ func (a *Agent) handleInit(msg *Message) *Message {
 		return errorResponse(msg.RequestID, fmt.Sprintf("invalid init payload: %v", err))
 	}
 
+	// 同一函数的同一份代码重复初始化时（如宿主机重启后重新下发）复用现有运行时，
+	// 常驻进程和预热加载的内容得以保留，只更新会话等不影响函数环境的配置
+	if current, _, ok := a.current(); ok && sameFunction(current, &payload) {
+		a.mu.Lock()
+		a.config = &payload
+		a.mu.Unlock()
+		return initResponse(msg.RequestID, &payload, 0)
+	}
+
 	// 创建函数代码目录
 	os.MkdirAll(FunctionDir, 0755)
 
@@ -283,36 +400,118 @@ func (a *Agent) handleInit(msg *Message) *Message {
 		return errorResponse(msg.RequestID, fmt.Sprintf("failed to write code: %v", err))
 	}
 
+	// 载荷要求时安装代码包中声明的依赖，相同依赖清单已安装过时跳过
+	if err := setupDependencies(&payload, FunctionDir); err != nil {
+		return errorResponse(msg.RequestID, err.Error())
+	}
+
+	// 启用状态功能时启动本地状态 API，函数代码通过 nimbus 模块访问
+	if payload.StateEnabled {
+		if err := a.startStateAPI(); err != nil {
+			return errorResponse(msg.RequestID, fmt.Sprintf("failed to start state API: %v", err))
+		}
+	}
+
 	// 创建并初始化运行时
 	rt, err := newRuntime(payload.Runtime)
 	if err != nil {
 		return errorResponse(msg.RequestID, fmt.Sprintf("failed to create runtime: %v", err))
 	}
 
+	if py, ok := rt.(*PythonRuntime); ok {
+		// 声明了预热函数时总是使用常驻进程，预热加载的内容（如模型）才能留给后续调用
+		py.warm = a.pythonWarmWorker || payload.WarmupHandler != ""
+	}
+	if wasm, ok := rt.(*WasmRuntime); ok && payload.StateEnabled {
+		wasm.state = a.forwardState
+	}
+
 	if err := rt.Init(&payload); err != nil {
 		return errorResponse(msg.RequestID, fmt.Sprintf("runtime init failed: %v", err))
 	}
 
-	// 保存运行时和配置
+	// 声明了预热函数时等待其返回后才报告初始化成功，宿主机据此只把真正就绪的实例视为预热完成
+	warmup, err := runWarmup(rt, payload.WarmupHandler, warmupTimeout(&payload))
+	if err != nil {
+		if closer, ok := rt.(io.Closer); ok {
+			closer.Close()
+		}
+		return errorResponse(msg.RequestID, err.Error())
+	}
+
+	// 保存运行时和配置，重新初始化时释放旧运行时持有的常驻进程
+	a.mu.Lock()
+	old := a.runtime
 	a.runtime = rt
 	a.config = &payload
 	a.initialized = true
+	a.mu.Unlock()
+	if closer, ok := old.(io.Closer); ok {
+		closer.Close()
+	}
+	return initResponse(msg.RequestID, &payload, warmup)
+}
+
+// initResponse 构建初始化成功的响应
+// 宿主机声明支持 gzip 时确认启用，此后该连接上的大消息双向压缩；声明了预热函数时带上预热耗时
+func initResponse(requestID string, payload *InitPayload, warmup time.Duration) *Message {
+	var result InitResult
+	if acceptsEncoding(payload.AcceptEncoding, EncodingGzip) {
+		result.Encoding = EncodingGzip
+	}
+	if payload.WarmupHandler != "" {
+		result.WarmupMs = warmup.Milliseconds()
+	}
+	if result == (InitResult{}) {
+		return successResponse(requestID, nil)
+	}
+	return successResponse(requestID, &result)
+}
+
+// sameFunction 判断新的初始化载荷是否与当前载荷建立相同的函数环境：
+// 同一函数、相同且非空的代码哈希、相同的入口点，环境变量、层、依赖安装、预热函数和状态功能也都未变化
+// （常驻进程启动时已带上环境变量并导入了层中的模块）
+func sameFunction(current, next *InitPayload) bool {
+	if current.FunctionID != next.FunctionID || current.CodeHash == "" || current.CodeHash != next.CodeHash ||
+		current.Handler != next.Handler || current.Runtime != next.Runtime ||
+		current.InstallDeps != next.InstallDeps || current.WarmupHandler != next.WarmupHandler ||
+		current.StateEnabled != next.StateEnabled || !maps.Equal(current.EnvVars, next.EnvVars) ||
+		len(current.Layers) != len(next.Layers) {
+		return false
+	}
+	for i, layer := range current.Layers {
+		other := next.Layers[i]
+		if layer.LayerID != other.LayerID || layer.Version != other.Version || layer.ContentHash != other.ContentHash {
+			return false
+		}
+	}
+	return true
+}
 
-	return successResponse(msg.RequestID, nil)
+// current 返回当前的函数配置和运行时，未初始化时 ok 为 false
+func (a *Agent) current() (config *InitPayload, rt Runtime, ok bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.config, a.runtime, a.initialized
 }
 
 // handleExec 处理函数执行请求
 // 在配置的超时时间内执行函数并返回结果
+// 请求要求流式返回时，输出通过 send 以 MessageTypeRespChunk 分片发送，最终响应不含 Output；
+// 请求要求转发日志时，函数输出的各行通过 send 以 MessageTypeLog 发送，全部发送后才返回最终响应
+// 非流式执行的输出超过 MaxOutputBytes 时调用失败，响应只带输出开头的一段（OutputPrefix）
 //
 // 参数:
 //   - ctx: 上下文
 //   - msg: 执行请求消息
+//   - send: 发送输出分片和日志
 //
 // 返回:
 //   - *Message: 包含执行结果的响应消息
-func (a *Agent) handleExec(ctx context.Context, msg *Message) *Message {
+func (a *Agent) handleExec(ctx context.Context, msg *Message, send func(*Message) error) *Message {
 	// 检查是否已初始化
-	if !a.initialized {
+	config, rt, ok := a.current()
+	if !ok {
 		return errorResponse(msg.RequestID, "agent not initialized")
 	}
 
@@ -324,24 +523,64 @@ func (a *Agent) handleExec(ctx context.Context, msg *Message) *Message {
 
 	// 创建带超时的上下文
 	// 确保函数不会无限期运行
-	timeout := time.Duration(a.config.TimeoutSec) * time.Second
+	timeout := time.Duration(config.TimeoutSec) * time.Second
 	execCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	// 输入按引用传递时先下载，下载时间计入函数超时
+	if payload.InputRef != "" {
+		in, err := fetchInputRef(execCtx, payload.InputRef)
+		if err != nil {
+			return errorResponse(msg.RequestID, err.Error())
+		}
+		defer in.file.Close()
+		execCtx = withInputFile(execCtx, in)
+	}
+
+	// 收集子进程的标准错误输出，无论成败都随响应返回
+	execCtx, stderr := withStderrCapture(execCtx)
+
+	// 按行转发函数输出（常驻进程模式的 Python 函数的输出都写在标准错误上，按 stderr 转发）
+	var logs *logForwarder
+	if payload.ForwardLogs {
+		logs = newLogForwarder(msg.RequestID, send)
+		execCtx = withLogForwarder(execCtx, logs)
+	}
+
 	// 执行函数并记录耗时
 	start := time.Now()
-	output, err := a.runtime.Execute(execCtx, payload.Input)
+	var output json.RawMessage
+	var chunks int
+	var err error
+	if payload.Stream {
+		chunks, err = executeStream(execCtx, rt, msg.RequestID, payload.Input, send)
+	} else {
+		output, err = rt.Execute(execCtx, payload.Input)
+		if err == nil {
+			// 不经过 runCommand 的运行时（WebAssembly、常驻进程模式的 Python）在这里统一检查
+			err = checkOutputSize(output)
+		}
+	}
 	duration := time.Since(start)
+	if logs != nil {
+		logs.Close()
+	}
 
 	// 构建响应
 	resp := &ResponsePayload{
 		DurationMs:   duration.Milliseconds(),
 		MemoryUsedMB: getMemoryUsage(),
+		Chunks:       chunks,
+		Stderr:       stderr.String(),
 	}
 
 	if err != nil {
 		resp.Success = false
 		resp.Error = err.Error()
+		var tooLarge *outputTooLargeError
+		if errors.As(err, &tooLarge) {
+			resp.OutputPrefix = string(tooLarge.prefix)
+		}
 	} else {
 		resp.Success = true
 		resp.Output = output
@@ -381,7 +620,7 @@ type DebugResponsePayload struct {
 //   - *Message: 响应消息
 func (a *Agent) handleDebug(ctx context.Context, msg *Message) *Message {
 	// 检查是否已初始化
-	if !a.initialized {
+	if _, _, ok := a.current(); !ok {
 		return a.debugErrorResponse(msg.RequestID, "agent not initialized")
 	}
 
@@ -413,12 +652,13 @@ func (a *Agent) handleDebugStart(requestID string, config *DebugConfig) *Message
 	}
 
 	// 设置默认配置
-	config.FunctionID = a.config.FunctionID
-	config.Handler = a.config.Handler
+	fn, _, _ := a.current()
+	config.FunctionID = fn.FunctionID
+	config.Handler = fn.Handler
 	config.CodePath = FunctionDir
-	config.Runtime = a.config.Runtime
-	config.EnvVars = a.config.EnvVars
-	config.TimeoutSec = a.config.TimeoutSec
+	config.Runtime = fn.Runtime
+	config.EnvVars = fn.EnvVars
+	config.TimeoutSec = fn.TimeoutSec
 
 	// 启动调试器
 	if err := a.debugManager.StartDebug(config); err != nil {
@@ -430,7 +670,7 @@ func (a *Agent) handleDebugStart(requestID string, config *DebugConfig) *Message
 
 	resp := &DebugResponsePayload{
 		Success:   true,
-		SessionID: a.config.FunctionID,
+		SessionID: fn.FunctionID,
 	}
 
 	data, _ := json.Marshal(resp)
@@ -494,12 +734,13 @@ func (a *Agent) handleDebugDAP(requestID string, dapMsg json.RawMessage) *Messag
 //   - *Message: 响应消息
 func (a *Agent) handleState(ctx context.Context, msg *Message) *Message {
 	// 检查是否已初始化
-	if !a.initialized {
+	config, _, ok := a.current()
+	if !ok {
 		return a.stateErrorResponse(msg.RequestID, "agent not initialized")
 	}
 
 	// 检查状态功能是否启用
-	if !a.config.StateEnabled {
+	if !config.StateEnabled {
 		return a.stateErrorResponse(msg.RequestID, "state feature not enabled for this function")
 	}
 
@@ -510,29 +751,12 @@ func (a *Agent) handleState(ctx context.Context, msg *Message) *Message {
 	}
 
 	// 验证操作类型
-	validOps := map[string]bool{
-		"get": true, "get_with_version": true,
-		"set": true, "set_with_version": true,
-		"delete": true, "incr": true,
-		"exists": true, "keys": true, "expire": true,
-	}
-	if !validOps[payload.Operation] {
+	if !stateOperations[payload.Operation] {
 		return a.stateErrorResponse(msg.RequestID, fmt.Sprintf("unknown operation: %s", payload.Operation))
 	}
 
-	// 这里的状态操作实际上是在宿主机侧处理的
-	// Agent 只是作为代理，将请求转发给宿主机
-	// 在当前实现中，状态请求直接通过 vsock 消息处理
-	// 宿主机收到 MessageTypeState 消息后会处理并返回结果
-
-	// 对于 Agent 内部的状态请求（如从函数代码调用），
-	// 需要通过本地 HTTP API 或 Unix socket 转发
-	// 此处返回一个占位响应，实际逻辑在宿主机处理
-
-	resp := &StateResponsePayload{
-		Success: true,
-	}
-
+	// 状态操作在宿主机侧处理，Agent 只负责转发
+	resp := a.forwardState(ctx, &payload)
 	data, _ := json.Marshal(resp)
 	return &Message{
 		Type:      MessageTypeResp,
@@ -542,10 +766,12 @@ func (a *Agent) handleState(ctx context.Context, msg *Message) *Message {
 }
 
 // stateErrorResponse 创建状态操作错误响应
+// Agent 侧产生的错误都属于操作错误，后端不可用由宿主机返回 backend_unavailable
 func (a *Agent) stateErrorResponse(requestID, errMsg string) *Message {
 	resp := &StateResponsePayload{
-		Success: false,
-		Error:   errMsg,
+		Success:   false,
+		Error:     errMsg,
+		ErrorCode: "operation_error",
 	}
 	data, _ := json.Marshal(resp)
 	return &Message{
@@ -589,15 +815,41 @@ func (a *Agent) debugErrorResponse(requestID, errMsg string) *Message {
 // 返回:
 //   - error: 写入错误
 func (a *Agent) writeCode(payload *InitPayload) error {
+	if !Interpreters.supports(payload.Runtime) {
+		return fmt.Errorf("unsupported runtime: %s", payload.Runtime)
+	}
+
+	// Python 和 Node.js 函数的代码也可以是 base64 编码的 ZIP 代码包，
+	// 包含多个源文件和 requirements.txt / package.json，解压到函数代码目录
+	language := runtimeLanguage(payload.Runtime)
+	if language == languagePython || language == languageNode {
+		if bundle, ok := codeBundle(payload.Code); ok {
+			if err := extractZip(bundle, FunctionDir); err != nil {
+				return fmt.Errorf("failed to extract %s code bundle: %w", payload.Runtime, err)
+			}
+			return nil
+		}
+	}
+
+	// 同一语言的不同版本使用相同的文件名
 	var filename string
-	switch payload.Runtime {
-	case "python3.11":
+	switch language {
+	case languagePython:
 		filename = "handler.py"
-	case "nodejs20":
+	case languageNode:
 		filename = "handler.js"
-	case "go1.24":
+	case languageRuby:
+		filename = "handler.rb"
+	case languageGo:
 		filename = "handler.go"
-	case "wasm":
+	case languageJava:
+		// Java 函数的代码是 base64 编码的 JAR
+		jar, err := base64.StdEncoding.DecodeString(payload.Code)
+		if err != nil {
+			return fmt.Errorf("invalid %s code, expected a base64-encoded JAR: %w", payload.Runtime, err)
+		}
+		return os.WriteFile(filepath.Join(FunctionDir, "handler.jar"), jar, 0644)
+	case languageWasm:
 		filename = "handler.wasm"
 	default:
 		return fmt.Errorf("unsupported runtime: %s", payload.Runtime)
@@ -620,6 +872,13 @@ func (a *Agent) setupLayers(payload *InitPayload) error {
 		return nil
 	}
 
+	// 解压任何层之前先校验全部层的内容哈希，传输损坏时不留下部分解压的层
+	for _, layer := range payload.Layers {
+		if err := verifyLayerContent(&layer); err != nil {
+			return err
+		}
+	}
+
 	// 创建层目录
 	if err := os.MkdirAll(LayersDir, 0755); err != nil {
 		return fmt.Errorf("failed to create layers directory: %w", err)
@@ -630,33 +889,44 @@ func (a *Agent) setupLayers(payload *InitPayload) error {
 		return payload.Layers[i].Order < payload.Layers[j].Order
 	})
 
-	var pythonPaths, nodePaths []string
+	var pythonPaths, nodePaths, javaPaths []string
+
+	// 重新初始化时相同内容的层已经解压过，按清单跳过
+	manifest := loadLayerManifest()
 
 	for _, layer := range payload.Layers {
 		layerDir := filepath.Join(LayersDir, layer.LayerID)
-		if err := os.MkdirAll(layerDir, 0755); err != nil {
-			return fmt.Errorf("failed to create layer directory %s: %w", layer.LayerID, err)
-		}
 
-		// 解压 ZIP 内容
-		if err := extractZip(layer.Content, layerDir); err != nil {
-			return fmt.Errorf("failed to extract layer %s: %w", layer.LayerID, err)
+		// 解压层内容（ZIP 或 tar.gz）
+		cached, err := extractLayer(manifest, &layer, layerDir)
+		if err != nil {
+			return err
 		}
 
 		// 根据运行时构建路径
-		switch payload.Runtime {
-		case "python3.11":
+		switch runtimeLanguage(payload.Runtime) {
+		case languagePython:
+			// 运行时名称即 site-packages 所在的版本目录，如 python3.12
 			pythonPaths = append(pythonPaths,
 				filepath.Join(layerDir, "python"),
-				filepath.Join(layerDir, "python", "lib", "python3.11", "site-packages"),
+				filepath.Join(layerDir, "python", "lib", payload.Runtime, "site-packages"),
 			)
-		case "nodejs20":
+		case languageNode:
 			nodePaths = append(nodePaths,
 				filepath.Join(layerDir, "nodejs", "node_modules"),
 			)
+		case languageJava:
+			// java/lib 下的所有 JAR（JVM 类路径通配符）
+			javaPaths = append(javaPaths,
+				filepath.Join(layerDir, "java", "lib", "*"),
+			)
 		}
 
-		fmt.Printf("Layer %s (v%d) extracted to %s\n", layer.LayerID, layer.Version, layerDir)
+		if cached {
+			fmt.Printf("Layer %s (v%d) already extracted to %s\n", layer.LayerID, layer.Version, layerDir)
+		} else {
+			fmt.Printf("Layer %s (v%d) extracted to %s\n", layer.LayerID, layer.Version, layerDir)
+		}
 	}
 
 	// 设置环境变量
@@ -676,10 +946,38 @@ func (a *Agent) setupLayers(payload *InitPayload) error {
 		os.Setenv("NODE_PATH", strings.Join(nodePaths, ":"))
 		fmt.Printf("NODE_PATH set to: %s\n", os.Getenv("NODE_PATH"))
 	}
+	if len(javaPaths) > 0 {
+		existing := os.Getenv("CLASSPATH")
+		if existing != "" {
+			javaPaths = append(javaPaths, existing)
+		}
+		os.Setenv("CLASSPATH", strings.Join(javaPaths, ":"))
+		fmt.Printf("CLASSPATH set to: %s\n", os.Getenv("CLASSPATH"))
+	}
 
 	return nil
 }
 
+// verifyLayerContent 校验层内容的 SHA-256 哈希是否与宿主机给出的一致
+// 宿主机未提供哈希（旧版本）时跳过校验
+//
+// 参数:
+//   - layer: 层信息
+//
+// 返回:
+//   - error: 哈希不一致时返回包含期望值和实际值的错误
+func verifyLayerContent(layer *LayerInfo) error {
+	if layer.ContentHash == "" {
+		return nil
+	}
+	actual := layerContentHash(layer.Content)
+	if !strings.EqualFold(actual, layer.ContentHash) {
+		return fmt.Errorf("layer %s (v%d) content hash mismatch: expected %s, got %s",
+			layer.LayerID, layer.Version, layer.ContentHash, actual)
+	}
+	return nil
+}
+
 // extractZip 将 ZIP 内容解压到目标目录
 //
 // 参数:
@@ -695,12 +993,9 @@ func extractZip(content []byte, destDir string) error {
 	}
 
 	for _, file := range reader.File {
-		path := filepath.Join(destDir, file.Name)
-
 		// 防止路径遍历攻击
-		cleanPath := filepath.Clean(path)
-		cleanDest := filepath.Clean(destDir)
-		if !strings.HasPrefix(cleanPath, cleanDest+string(os.PathSeparator)) && cleanPath != cleanDest {
+		path, ok := safeExtractPath(destDir, file.Name)
+		if !ok {
 			fmt.Printf("Warning: skipping potentially unsafe path: %s\n", file.Name)
 			continue
 		}
@@ -742,6 +1037,7 @@ func extractZip(content []byte, destDir string) error {
 }
 
 // newRuntime 根据运行时名称创建对应的运行时实例
+// 解释型语言的运行时必须在 Interpreters 中登记了解释器，如 python3.12
 //
 // 参数:
 //   - name: 运行时名称
@@ -750,17 +1046,22 @@ func extractZip(content []byte, destDir string) error {
 //   - Runtime: 运行时实例
 //   - error: 创建错误
 func newRuntime(name string) (Runtime, error) {
-	switch name {
-	case "python3.11":
-		return &PythonRuntime{}, nil
-	case "nodejs20":
-		return &NodeRuntime{}, nil
-	case "go1.24":
+	if !Interpreters.supports(name) {
+		return nil, fmt.Errorf("unsupported runtime: %s", name)
+	}
+	switch runtimeLanguage(name) {
+	case languagePython:
+		return &PythonRuntime{runtime: name}, nil
+	case languageNode:
+		return &NodeRuntime{runtime: name}, nil
+	case languageRuby:
+		return &RubyRuntime{runtime: name}, nil
+	case languageGo:
 		return &GoRuntime{}, nil
-	case "wasm":
-		return &WasmRuntime{}, nil
+	case languageJava:
+		return &JavaRuntime{runtime: name}, nil
 	default:
-		return nil, fmt.Errorf("unsupported runtime: %s", name)
+		return &WasmRuntime{}, nil
 	}
 }
 
@@ -770,7 +1071,17 @@ func newRuntime(name string) (Runtime, error) {
 
 // PythonRuntime 实现 Python 函数的执行
 type PythonRuntime struct {
-	handler string // 处理函数入口点
+	runtime string   // 运行时名称，用于查找解释器
+	handler string   // 处理函数入口点
+	env     []string // 函数配置的环境变量（KEY=VALUE）
+
+	// warm 为 true 时使用常驻解释器进程执行，避免每次调用的解释器启动和导入开销
+	warm           bool
+	mu             sync.Mutex    // 保护常驻进程，调用串行执行
+	worker         *pythonWorker // 常驻进程，未启用或不可用时为 nil
+	workerStarts   int           // 常驻进程启动次数
+	workerRestarts int           // 常驻进程退出后的重启次数
+	rewarm         *warmupTarget // 常驻进程重启后需要重新调用的预热函数，未预热时为 nil
 }
 
 // Init 初始化 Python 运行时
@@ -782,7 +1093,9 @@ type PythonRuntime struct {
 // 返回:
 //   - error: 初始化错误
 func (r *PythonRuntime) Init(config *InitPayload) error {
+	r.runtime = config.Runtime
 	r.handler = config.Handler
+	r.env = formatEnv(config.EnvVars)
 
 	// 创建 Python 包装脚本
 	// 这个脚本负责：
@@ -834,8 +1147,18 @@ class StateError(Exception):
     """状态操作错误"""
     pass
 
-def _state_request(operation, scope, key, **kwargs):
-    """发送状态请求到 Agent"""
+class StateBackendUnavailable(StateError):
+    """状态存储后端不可用（Redis 宕机或状态 API 不可达），操作未执行，可稍后重试"""
+    pass
+
+class StateVersionConflict(StateError):
+    """乐观锁写入时版本不一致，current_version 为当前版本号"""
+    def __init__(self, current_version):
+        super().__init__('version conflict')
+        self.current_version = current_version
+
+def _state_call(operation, scope, key, **kwargs):
+    """发送状态请求到 Agent，返回完整的响应"""
     payload = {
         'function_id': _FUNCTION_ID,
         'session_key': _SESSION_KEY,
@@ -852,10 +1175,18 @@ def _state_request(operation, scope, key, **kwargs):
         with urllib.request.urlopen(req, timeout=5) as resp:
             result = json.loads(resp.read().decode('utf-8'))
             if not result.get('success'):
+                if result.get('error_code') == 'backend_unavailable':
+                    raise StateBackendUnavailable(result.get('error', 'state backend unavailable'))
+                if result.get('error') == 'version conflict':
+                    raise StateVersionConflict(result.get('version', 0))
                 raise StateError(result.get('error', 'Unknown error'))
-            return result.get('value')
+            return result
     except urllib.error.URLError as e:
-        raise StateError(f'State API unavailable: {e}')
+        raise StateBackendUnavailable(f'State API unavailable: {e}')
+
+def _state_request(operation, scope, key, **kwargs):
+    """发送状态请求到 Agent，返回状态值"""
+    return _state_call(operation, scope, key, **kwargs).get('value')
 
 class State:
     """状态操作类"""
@@ -877,6 +1208,12 @@ class State:
         except (StateError, json.JSONDecodeError):
             return default
 
+    def get_with_version(self, key, default=None):
+        """获取状态值及其版本号，返回 (value, version)，键不存在时版本号为 0"""
+        result = _state_call('get_with_version', self.scope, key)
+        value = result.get('value')
+        return (json.loads(value) if value else default), result.get('version', 0)
+
     def set(self, key, value, ttl=None):
         """设置状态值"""
         kwargs = {'value': json.dumps(value)}
@@ -884,6 +1221,17 @@ class State:
             kwargs['ttl'] = ttl
         _state_request('set', self.scope, key, **kwargs)
 
+    def set_with_version(self, key, value, expected_version, ttl=None):
+        """
+        乐观锁写入（compare-and-set）：当前版本号与 expected_version 一致时写入并返回新版本号，
+        否则抛出 StateVersionConflict。expected_version 为 0 表示键尚不存在，
+        与 get_with_version 配合实现读-改-写，冲突时重新读取后重试
+        """
+        kwargs = {'value': json.dumps(value), 'expected_version': expected_version}
+        if ttl:
+            kwargs['ttl'] = ttl
+        return _state_call('set_with_version', self.scope, key, **kwargs).get('version', 0)
+
     def delete(self, key):
         """删除状态"""
         _state_request('delete', self.scope, key)
@@ -891,17 +1239,28 @@ class State:
     def incr(self, key, delta=1):
         """原子递增"""
         result = _state_request('incr', self.scope, key, delta=delta)
-        return json.loads(result) if result else 0
+        return result if result is not None else 0
 
     def exists(self, key):
         """检查键是否存在"""
         result = _state_request('exists', self.scope, key)
-        return json.loads(result) if result else False
+        return bool(result)
 
     def keys(self, pattern='*'):
-        """列出匹配的键"""
-        result = _state_request('keys', self.scope, pattern)
-        return json.loads(result) if result else []
+        """列出匹配的键，按游标分页向宿主机请求直到取完所有结果"""
+        found = []
+        seen = set()
+        cursor = ''
+        while True:
+            kwargs = {'cursor': cursor} if cursor else {}
+            result = _state_call('keys', self.scope, pattern, **kwargs)
+            for key in result.get('value') or []:
+                if key not in seen:
+                    seen.add(key)
+                    found.append(key)
+            cursor = result.get('cursor') or ''
+            if not cursor:
+                return found
 
     def expire(self, key, ttl):
         """设置过期时间"""
@@ -925,11 +1284,30 @@ def get_function_id():
 		return fmt.Errorf("failed to write nimbus module: %w", err)
 	}
 
-	return os.WriteFile(filepath.Join(FunctionDir, "_wrapper.py"), []byte(wrapper), 0644)
+	if err := os.WriteFile(filepath.Join(FunctionDir, "_wrapper.py"), []byte(wrapper), 0644); err != nil {
+		return err
+	}
+	if !r.warm {
+		return nil
+	}
+
+	// 常驻进程模式：写入常驻包装脚本并启动进程，启动失败时回退到逐次启动模式
+	workerScript := fmt.Sprintf(pythonWorkerScript, FunctionDir, config.Handler)
+	if err := os.WriteFile(filepath.Join(FunctionDir, "_worker.py"), []byte(workerScript), 0644); err != nil {
+		return fmt.Errorf("failed to write python worker: %w", err)
+	}
+	worker, err := startPythonWorker(r.interpreter(), r.env)
+	if err != nil {
+		fmt.Printf("Python worker start failed, falling back to per-invocation processes: %v\n", err)
+		return nil
+	}
+	r.worker = worker
+	r.workerStarts++
+	return nil
 }
 
 // Execute 执行 Python 函数
-// 通过子进程运行包装脚本
+// 启用常驻进程时通过常驻进程执行，否则（或常驻进程不可用时）为每次调用启动子进程运行包装脚本
 //
 // 参数:
 //   - ctx: 上下文，用于超时控制
@@ -939,19 +1317,24 @@ def get_function_id():
 //   - json.RawMessage: 函数输出
 //   - error: 执行错误
 func (r *PythonRuntime) Execute(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+	if r.warm {
+		output, err := r.executeWarm(ctx, input)
+		if err != errPythonWorkerUnavailable {
+			return output, err
+		}
+	}
+
 	// 使用上下文创建可取消的命令
-	cmd := exec.CommandContext(ctx, "python3", filepath.Join(FunctionDir, "_wrapper.py"))
-	cmd.Stdin = jsonReader(input)
+	cmd := exec.CommandContext(ctx, r.interpreter(), filepath.Join(FunctionDir, "_wrapper.py"))
+	cmd.Env = append(os.Environ(), r.env...)
+	cmd.Stdin = inputReader(ctx, input)
 
-	output, err := cmd.Output()
+	output, err := runCommand(ctx, cmd, "python error")
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("python error: %s", string(exitErr.Stderr))
-		}
 		return nil, err
 	}
 
-	return json.RawMessage(output), nil
+	return jsonOutput(output)
 }
 
 // ============================================================================
@@ -960,7 +1343,9 @@ func (r *PythonRuntime) Execute(ctx context.Context, input json.RawMessage) (jso
 
 // NodeRuntime 实现 Node.js 函数的执行
 type NodeRuntime struct {
-	handler string // 处理函数入口点
+	runtime string   // 运行时名称，用于查找解释器
+	handler string   // 处理函数入口点
+	env     []string // 函数配置的环境变量（KEY=VALUE）
 }
 
 // Init 初始化 Node.js 运行时
@@ -972,7 +1357,9 @@ type NodeRuntime struct {
 // 返回:
 //   - error: 初始化错误
 func (r *NodeRuntime) Init(config *InitPayload) error {
+	r.runtime = config.Runtime
 	r.handler = config.Handler
+	r.env = formatEnv(config.EnvVars)
 
 	// 创建 Node.js 包装脚本
 	// 支持异步处理函数
@@ -1013,13 +1400,32 @@ const SESSION_KEY = process.env.NIMBUS_SESSION_KEY || '%s';
 const STATE_API_URL = 'http://127.0.0.1:9998/state';
 
 class StateError extends Error {
-    constructor(message) {
+    constructor(message, code = 'operation_error') {
         super(message);
         this.name = 'StateError';
+        this.code = code;
     }
 }
 
-async function stateRequest(operation, scope, key, options = {}) {
+// 状态存储后端不可用（Redis 宕机或状态 API 不可达），操作未执行，可稍后重试
+class StateBackendUnavailable extends StateError {
+    constructor(message) {
+        super(message, 'backend_unavailable');
+        this.name = 'StateBackendUnavailable';
+    }
+}
+
+// 乐观锁写入时版本不一致，currentVersion 为当前版本号
+class StateVersionConflict extends StateError {
+    constructor(currentVersion) {
+        super('version conflict');
+        this.name = 'StateVersionConflict';
+        this.currentVersion = currentVersion;
+    }
+}
+
+// 发送状态请求到 Agent，返回完整的响应
+async function stateCall(operation, scope, key, options = {}) {
     const payload = {
         function_id: FUNCTION_ID,
         session_key: SESSION_KEY,
@@ -1050,9 +1456,15 @@ async function stateRequest(operation, scope, key, options = {}) {
                 try {
                     const result = JSON.parse(body);
                     if (!result.success) {
-                        reject(new StateError(result.error || 'Unknown error'));
+                        if (result.error_code === 'backend_unavailable') {
+                            reject(new StateBackendUnavailable(result.error || 'state backend unavailable'));
+                        } else if (result.error === 'version conflict') {
+                            reject(new StateVersionConflict(result.version || 0));
+                        } else {
+                            reject(new StateError(result.error || 'Unknown error'));
+                        }
                     } else {
-                        resolve(result.value);
+                        resolve(result);
                     }
                 } catch (e) {
                     reject(new StateError('Invalid response'));
@@ -1061,7 +1473,7 @@ async function stateRequest(operation, scope, key, options = {}) {
         });
 
         req.on('error', (e) => {
-            reject(new StateError('State API unavailable: ' + e.message));
+            reject(new StateBackendUnavailable('State API unavailable: ' + e.message));
         });
 
         req.write(data);
@@ -1069,6 +1481,12 @@ async function stateRequest(operation, scope, key, options = {}) {
     });
 }
 
+// 发送状态请求到 Agent，返回状态值
+async function stateRequest(operation, scope, key, options = {}) {
+    const result = await stateCall(operation, scope, key, options);
+    return result.value;
+}
+
 class State {
     constructor(scope = 'session') {
         this.scope = scope;
@@ -1083,29 +1501,56 @@ class State {
         }
     }
 
+    // 获取状态值及其版本号，返回 { value, version }，键不存在时版本号为 0
+    async getWithVersion(key, defaultValue = null) {
+        const result = await stateCall('get_with_version', this.scope, key);
+        return {
+            value: result.value ? JSON.parse(result.value) : defaultValue,
+            version: result.version || 0
+        };
+    }
+
     async set(key, value, ttl = null) {
         const options = { value: JSON.stringify(value) };
         if (ttl) options.ttl = ttl;
         await stateRequest('set', this.scope, key, options);
     }
 
+    // 乐观锁写入（compare-and-set）：当前版本号与 expectedVersion 一致时写入并返回新版本号，
+    // 否则抛出 StateVersionConflict。expectedVersion 为 0 表示键尚不存在，
+    // 与 getWithVersion 配合实现读-改-写，冲突时重新读取后重试
+    async setWithVersion(key, value, expectedVersion, ttl = null) {
+        const options = { value: JSON.stringify(value), expected_version: expectedVersion };
+        if (ttl) options.ttl = ttl;
+        const result = await stateCall('set_with_version', this.scope, key, options);
+        return result.version || 0;
+    }
+
     async delete(key) {
         await stateRequest('delete', this.scope, key);
     }
 
     async incr(key, delta = 1) {
         const result = await stateRequest('incr', this.scope, key, { delta });
-        return result ? JSON.parse(result) : 0;
+        return result ?? 0;
     }
 
     async exists(key) {
         const result = await stateRequest('exists', this.scope, key);
-        return result ? JSON.parse(result) : false;
+        return Boolean(result);
     }
 
+    // 列出匹配的键，按游标分页向宿主机请求直到取完所有结果
     async keys(pattern = '*') {
-        const result = await stateRequest('keys', this.scope, pattern);
-        return result ? JSON.parse(result) : [];
+        const seen = new Set();
+        let cursor = '';
+        do {
+            const options = cursor ? { cursor } : {};
+            const result = await stateCall('keys', this.scope, pattern, options);
+            for (const key of result.value || []) seen.add(key);
+            cursor = result.cursor || '';
+        } while (cursor);
+        return [...seen];
     }
 
     async expire(key, ttl) {
@@ -1128,6 +1573,8 @@ function getFunctionId() {
 module.exports = {
     State,
     StateError,
+    StateBackendUnavailable,
+    StateVersionConflict,
     session,
     function: func,
     getSessionKey,
@@ -1154,18 +1601,258 @@ module.exports = {
 //   - json.RawMessage: 函数输出
 //   - error: 执行错误
 func (r *NodeRuntime) Execute(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
-	cmd := exec.CommandContext(ctx, "node", filepath.Join(FunctionDir, "_wrapper.js"))
-	cmd.Stdin = jsonReader(input)
+	cmd := exec.CommandContext(ctx, r.interpreter(), filepath.Join(FunctionDir, "_wrapper.js"))
+	cmd.Env = append(os.Environ(), r.env...)
+	cmd.Stdin = inputReader(ctx, input)
 
-	output, err := cmd.Output()
+	output, err := runCommand(ctx, cmd, "node error")
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonOutput(output)
+}
+
+// ============================================================================
+// Ruby 运行时
+// ============================================================================
+
+// RubyRuntime 实现 Ruby 函数的执行
+type RubyRuntime struct {
+	runtime string   // 运行时名称，用于查找解释器
+	handler string   // 处理函数入口点
+	env     []string // 函数配置的环境变量（KEY=VALUE）
+}
+
+// Init 初始化 Ruby 运行时
+// 创建一个包装脚本，用于加载和执行用户函数
+//
+// 参数:
+//   - config: 初始化配置
+//
+// 返回:
+//   - error: 初始化错误
+func (r *RubyRuntime) Init(config *InitPayload) error {
+	r.runtime = config.Runtime
+	r.handler = config.Handler
+	r.env = formatEnv(config.EnvVars)
+
+	// 创建 Ruby 包装脚本
+	// 处理函数入口格式为 文件名.方法名，如 handler.handler 表示 handler.rb 中的 handler 方法
+	wrapper := fmt.Sprintf(`
+require 'json'
+$LOAD_PATH.unshift('%s')
+
+# 加载处理函数
+parts = '%s'.split('.', 2)
+file_name, method_name = parts.length == 2 ? parts : ['handler', parts[0]]
+require file_name
+
+# 从标准输入读取输入数据
+input = JSON.parse($stdin.read)
+
+# 执行处理函数
+result = send(method_name.to_sym, input)
+
+# 将结果输出到标准输出
+puts JSON.generate(result)
+`, FunctionDir, config.Handler)
+
+	// 创建 nimbus 状态 API 模块
+	nimbusModule := fmt.Sprintf(`
+# Nimbus State API - 为有状态函数提供状态管理能力
+require 'json'
+require 'net/http'
+
+module Nimbus
+  FUNCTION_ID = '%s'
+  SESSION_KEY = ENV.fetch('NIMBUS_SESSION_KEY', '%s')
+  STATE_API_URL = URI('http://127.0.0.1:9998/state')
+
+  # 状态操作错误
+  class StateError < StandardError; end
+
+  # 状态存储后端不可用（Redis 宕机或状态 API 不可达），操作未执行，可稍后重试
+  class StateBackendUnavailable < StateError; end
+
+  # 乐观锁写入时版本不一致，current_version 为当前版本号
+  class StateVersionConflict < StateError
+    attr_reader :current_version
+
+    def initialize(current_version)
+      super('version conflict')
+      @current_version = current_version
+    end
+  end
+
+  # 发送状态请求到 Agent，返回完整的响应
+  def self.state_call(operation, scope, key, **kwargs)
+    payload = {
+      function_id: FUNCTION_ID,
+      session_key: SESSION_KEY,
+      operation: operation,
+      scope: scope,
+      key: key
+    }.merge(kwargs)
+
+    begin
+      http = Net::HTTP.new(STATE_API_URL.host, STATE_API_URL.port)
+      http.open_timeout = 5
+      http.read_timeout = 5
+      resp = http.post(STATE_API_URL.path, JSON.generate(payload), 'Content-Type' => 'application/json')
+      result = JSON.parse(resp.body)
+    rescue SystemCallError, IOError, Net::OpenTimeout, Net::ReadTimeout => e
+      raise StateBackendUnavailable, "State API unavailable: #{e.message}"
+    end
+
+    unless result['success']
+      if result['error_code'] == 'backend_unavailable'
+        raise StateBackendUnavailable, result.fetch('error', 'state backend unavailable')
+      end
+      raise StateVersionConflict, result.fetch('version', 0) if result['error'] == 'version conflict'
+
+      raise StateError, result.fetch('error', 'Unknown error')
+    end
+    result
+  end
+
+  # 发送状态请求到 Agent，返回状态值
+  def self.state_request(operation, scope, key, **kwargs)
+    state_call(operation, scope, key, **kwargs)['value']
+  end
+
+  # 状态操作类
+  # scope: 'session'(会话级), 'function'(函数级), 'invocation'(调用级)
+  class State
+    def initialize(scope = 'session')
+      @scope = scope
+    end
+
+    # 获取状态值
+    def get(key, default = nil)
+      value = Nimbus.state_request('get', @scope, key)
+      value ? JSON.parse(value) : default
+    rescue StateError, JSON::ParserError
+      default
+    end
+
+    # 获取状态值及其版本号，返回 [value, version]，键不存在时版本号为 0
+    def get_with_version(key, default = nil)
+      result = Nimbus.state_call('get_with_version', @scope, key)
+      value = result['value']
+      [value ? JSON.parse(value) : default, result.fetch('version', 0)]
+    end
+
+    # 设置状态值
+    def set(key, value, ttl: nil)
+      kwargs = { value: JSON.generate(value) }
+      kwargs[:ttl] = ttl if ttl
+      Nimbus.state_request('set', @scope, key, **kwargs)
+      nil
+    end
+
+    # 乐观锁写入（compare-and-set）：当前版本号与 expected_version 一致时写入并返回新版本号，
+    # 否则抛出 StateVersionConflict。expected_version 为 0 表示键尚不存在，
+    # 与 get_with_version 配合实现读-改-写，冲突时重新读取后重试
+    def set_with_version(key, value, expected_version, ttl: nil)
+      kwargs = { value: JSON.generate(value), expected_version: expected_version }
+      kwargs[:ttl] = ttl if ttl
+      Nimbus.state_call('set_with_version', @scope, key, **kwargs).fetch('version', 0)
+    end
+
+    # 删除状态
+    def delete(key)
+      Nimbus.state_request('delete', @scope, key)
+      nil
+    end
+
+    # 原子递增
+    def incr(key, delta = 1)
+      result = Nimbus.state_request('incr', @scope, key, delta: delta)
+      result.nil? ? 0 : result
+    end
+
+    # 检查键是否存在
+    def exists?(key)
+      result = Nimbus.state_request('exists', @scope, key)
+      result == true
+    end
+
+    # 列出匹配的键，按游标分页向宿主机请求直到取完所有结果
+    def keys(pattern = '*')
+      found = []
+      cursor = ''
+      loop do
+        kwargs = cursor.empty? ? {} : { cursor: cursor }
+        result = Nimbus.state_call('keys', @scope, pattern, **kwargs)
+        found.concat(result['value'] || [])
+        cursor = result['cursor'] || ''
+        break if cursor.empty?
+      end
+      # SCAN 可能重复返回同一个键
+      found.uniq
+    end
+
+    # 设置过期时间
+    def expire(key, ttl)
+      Nimbus.state_request('expire', @scope, key, ttl: ttl)
+      nil
+    end
+  end
+
+  # 预创建的状态实例
+  SESSION = State.new('session')    # 会话级状态
+  FUNCTION = State.new('function')  # 函数级状态（全局）
+
+  def self.session
+    SESSION
+  end
+
+  def self.function
+    FUNCTION
+  end
+
+  # 获取当前会话标识
+  def self.session_key
+    SESSION_KEY
+  end
+
+  # 获取当前函数 ID
+  def self.function_id
+    FUNCTION_ID
+  end
+end
+`, config.FunctionID, config.SessionKey)
+
+	// 写入 nimbus 模块
+	if err := os.WriteFile(filepath.Join(FunctionDir, "nimbus.rb"), []byte(nimbusModule), 0644); err != nil {
+		return fmt.Errorf("failed to write nimbus module: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(FunctionDir, "_wrapper.rb"), []byte(wrapper), 0644)
+}
+
+// Execute 执行 Ruby 函数
+// 通过子进程运行包装脚本
+//
+// 参数:
+//   - ctx: 上下文，用于超时控制
+//   - input: JSON 格式的输入参数
+//
+// 返回:
+//   - json.RawMessage: 函数输出
+//   - error: 执行错误
+func (r *RubyRuntime) Execute(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+	cmd := exec.CommandContext(ctx, r.interpreter(), filepath.Join(FunctionDir, "_wrapper.rb"))
+	cmd.Env = append(os.Environ(), r.env...)
+	cmd.Stdin = inputReader(ctx, input)
+
+	output, err := runCommand(ctx, cmd, "ruby error")
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("node error: %s", string(exitErr.Stderr))
-		}
 		return nil, err
 	}
 
-	return json.RawMessage(output), nil
+	return jsonOutput(output)
 }
 
 // ============================================================================
@@ -1174,7 +1861,9 @@ func (r *NodeRuntime) Execute(ctx context.Context, input json.RawMessage) (json.
 
 // GoRuntime 实现预编译 Go 二进制的执行
 // Go 函数需要预先编译为二进制文件
-type GoRuntime struct{}
+type GoRuntime struct {
+	env []string // 函数配置的环境变量（KEY=VALUE）
+}
 
 // Init 初始化 Go 运行时
 // 对于 Go，代码应该是预编译的二进制文件
@@ -1186,7 +1875,8 @@ type GoRuntime struct{}
 //   - error: 初始化错误
 func (r *GoRuntime) Init(config *InitPayload) error {
 	// Go 代码期望是预编译的二进制文件
-	// 无需额外的初始化步骤
+	// 只需记录环境变量
+	r.env = formatEnv(config.EnvVars)
 	return nil
 }
 
@@ -1203,17 +1893,136 @@ func (r *GoRuntime) Init(config *InitPayload) error {
 func (r *GoRuntime) Execute(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
 	binaryPath := filepath.Join(FunctionDir, "handler")
 	cmd := exec.CommandContext(ctx, binaryPath)
-	cmd.Stdin = jsonReader(input)
+	cmd.Env = append(os.Environ(), r.env...)
+	cmd.Stdin = inputReader(ctx, input)
 
-	output, err := cmd.Output()
+	output, err := runCommand(ctx, cmd, "go error")
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("go error: %s", string(exitErr.Stderr))
+		return nil, err
+	}
+
+	return jsonOutput(output)
+}
+
+// ============================================================================
+// Java 运行时
+// ============================================================================
+
+// javaBootstrapClass 是 Java 函数的启动类名
+const javaBootstrapClass = "NimbusBootstrap"
+
+// javaBootstrapSource 是启动类的源码。
+// 启动类从标准输入读取 JSON 字符串，通过反射调用 handler 指定的 String handle(String) 方法，
+// 将返回的 JSON 字符串写到标准输出。用户代码的 System.out 输出被重定向到标准错误，避免破坏结果。
+// Docker 运行时镜像使用同一份启动类（deployments/docker/runtimes/NimbusBootstrap.java），修改时需同步。
+const javaBootstrapSource = `
+import java.io.PrintStream;
+import java.lang.reflect.InvocationTargetException;
+import java.lang.reflect.Method;
+import java.lang.reflect.Modifier;
+import java.nio.charset.StandardCharsets;
+
+public class NimbusBootstrap {
+    public static void main(String[] args) throws Exception {
+        String spec = args[0];
+        int sep = spec.indexOf("::");
+        String className = sep >= 0 ? spec.substring(0, sep) : spec;
+        String methodName = sep >= 0 ? spec.substring(sep + 2) : "handle";
+
+        String input = new String(System.in.readAllBytes(), StandardCharsets.UTF_8);
+        Class<?> cls = Class.forName(className);
+        Method method = cls.getMethod(methodName, String.class);
+        Object target = Modifier.isStatic(method.getModifiers()) ? null : cls.getDeclaredConstructor().newInstance();
+
+        PrintStream out = System.out;
+        System.setOut(System.err);
+        Object result;
+        try {
+            result = method.invoke(target, input);
+        } catch (InvocationTargetException e) {
+            e.getCause().printStackTrace();
+            System.exit(1);
+            return;
+        }
+        out.print(result == null ? "null" : result.toString());
+        out.flush();
+    }
+}
+`
+
+// JavaRuntime 实现预构建 JAR 的执行
+// handler 格式为 "类名::方法名"（如 com.example.Handler::handle），方法签名为 String handle(String)，
+// 入参和返回值都是 JSON 字符串；方法可以是静态方法，也可以是带无参构造函数的类的实例方法。
+type JavaRuntime struct {
+	runtime   string   // 运行时名称，用于查找解释器
+	handler   string   // 处理函数入口点（类名::方法名）
+	classpath string   // 启动 JVM 使用的类路径
+	env       []string // 函数配置的环境变量（KEY=VALUE）
+}
+
+// Init 初始化 Java 运行时
+// 编译启动类（已编译时跳过），并将函数 JAR、启动类和 Java 层组成类路径
+//
+// 参数:
+//   - config: 初始化配置
+//
+// 返回:
+//   - error: 初始化错误
+func (r *JavaRuntime) Init(config *InitPayload) error {
+	if config.Handler == "" || strings.HasSuffix(config.Handler, "::") {
+		return fmt.Errorf("invalid java handler %q, expected com.example.Handler::handle", config.Handler)
+	}
+	r.runtime = config.Runtime
+	r.handler = config.Handler
+	r.env = formatEnv(config.EnvVars)
+
+	bootstrapDir := filepath.Join(FunctionDir, "_bootstrap")
+	if _, err := os.Stat(filepath.Join(bootstrapDir, javaBootstrapClass+".class")); err != nil {
+		if err := os.MkdirAll(bootstrapDir, 0755); err != nil {
+			return err
+		}
+		source := filepath.Join(bootstrapDir, javaBootstrapClass+".java")
+		if err := os.WriteFile(source, []byte(javaBootstrapSource), 0644); err != nil {
+			return err
+		}
+		if output, err := exec.Command("javac", "-d", bootstrapDir, source).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to compile java bootstrap: %v: %s", err, output)
 		}
+	}
+
+	// 类路径：函数 JAR、启动类、Java 层（由 setupLayers 写入 CLASSPATH）
+	paths := []string{filepath.Join(FunctionDir, "handler.jar"), bootstrapDir}
+	if layers := os.Getenv("CLASSPATH"); layers != "" {
+		paths = append(paths, layers)
+	}
+	r.classpath = strings.Join(paths, ":")
+	return nil
+}
+
+// Execute 执行 Java 函数
+// 每次调用启动一个 JVM，通过标准输入输出传递 JSON
+//
+// 参数:
+//   - ctx: 上下文，用于超时控制
+//   - input: JSON 格式的输入参数
+//
+// 返回:
+//   - json.RawMessage: 函数输出
+//   - error: 执行错误
+func (r *JavaRuntime) Execute(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+	// 函数进程生命周期短：只使用 C1 编译器并使用串行 GC，缩短 JVM 启动时间
+	cmd := exec.CommandContext(ctx, r.interpreter(),
+		"-XX:TieredStopAtLevel=1", "-XX:+UseSerialGC",
+		"-cp", r.classpath, javaBootstrapClass, r.handler)
+	cmd.Env = append(os.Environ(), r.env...)
+	cmd.Stdin = inputReader(ctx, input)
+
+	output, err := runCommand(ctx, cmd, "java error")
+	if err != nil {
 		return nil, err
 	}
 
-	return json.RawMessage(output), nil
+	return jsonOutput(output)
 }
 
 // ============================================================================
@@ -1228,10 +2037,18 @@ func (r *GoRuntime) Execute(ctx context.Context, input json.RawMessage) (json.Ra
 //
 // 可选导出：
 //   - dealloc(ptr: i32, size: i32)     : 释放内存
+//
+// 每次调用读取输出后，导出了 dealloc 的模块依次释放输出 (ptr, len) 和输入 (ptr, len)，
+// 即按分配的逆序释放，实例在多次调用间复用；未导出 dealloc 的模块无法释放内存，
+// 调用结束后丢弃实例，下一次调用从已编译的模块重新实例化，线性内存和全局变量随之重置。
+//
+// 模块可以从 nimbus_state 导入状态宿主函数，调用约定见 wasmStateModuleName
 type WasmRuntime struct {
-	runtime  wazero.Runtime         // wazero 运行时
-	module   wazero.CompiledModule  // 编译后的 WASM 模块
-	instance api.Module             // 模块实例
+	runtime      wazero.Runtime        // wazero 运行时
+	module       wazero.CompiledModule // 编译后的 WASM 模块
+	moduleConfig wazero.ModuleConfig   // 实例化配置，重新实例化时复用
+	instance     api.Module            // 模块实例，为 nil 时在下一次调用前重新实例化
+	state        wasmStateForwarder    // 状态操作转发函数，未启用状态功能时为 nil
 }
 
 // Init 初始化 WebAssembly 运行时
@@ -1258,26 +2075,49 @@ func (r *WasmRuntime) Init(config *InitPayload) error {
 		return fmt.Errorf("failed to instantiate WASI: %w", err)
 	}
 
+	// 注册状态宿主模块，函数通过导入 nimbus_state 访问状态 API
+	if err := instantiateWasmStateModule(ctx, r.runtime, r.state); err != nil {
+		return fmt.Errorf("failed to instantiate %s host module: %w", wasmStateModuleName, err)
+	}
+
 	// 编译模块
 	r.module, err = r.runtime.CompileModule(ctx, wasmBytes)
 	if err != nil {
 		return fmt.Errorf("failed to compile wasm module: %w", err)
 	}
 
-	// 实例化模块（一次实例化，多次调用）
-	r.instance, err = r.runtime.InstantiateModule(ctx, r.module, wazero.NewModuleConfig())
+	// 函数环境变量通过 WASI environ 提供
+	r.moduleConfig = wazero.NewModuleConfig()
+	for key, value := range config.EnvVars {
+		r.moduleConfig = r.moduleConfig.WithEnv(key, value)
+	}
+	return r.instantiate(ctx)
+}
+
+// instantiate 从已编译的模块创建新实例并验证必需的导出函数
+//
+// 参数:
+//   - ctx: 上下文
+//
+// 返回:
+//   - error: 实例化错误或缺少必需的导出函数
+func (r *WasmRuntime) instantiate(ctx context.Context) error {
+	instance, err := r.runtime.InstantiateModule(ctx, r.module, r.moduleConfig)
 	if err != nil {
 		return fmt.Errorf("failed to instantiate wasm module: %w", err)
 	}
 
 	// 验证必需的导出函数存在
-	if r.instance.ExportedFunction("alloc") == nil {
+	if instance.ExportedFunction("alloc") == nil {
+		instance.Close(ctx)
 		return fmt.Errorf("wasm module must export 'alloc(size: i32) -> i32' function")
 	}
-	if r.instance.ExportedFunction("handle") == nil {
+	if instance.ExportedFunction("handle") == nil {
+		instance.Close(ctx)
 		return fmt.Errorf("wasm module must export 'handle(ptr: i32, len: i32) -> i64' function")
 	}
 
+	r.instance = instance
 	return nil
 }
 
@@ -1292,12 +2132,22 @@ func (r *WasmRuntime) Init(config *InitPayload) error {
 //   - json.RawMessage: 函数输出
 //   - error: 执行错误
 func (r *WasmRuntime) Execute(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+	// 上一次调用后丢弃了实例时重新实例化
+	if r.instance == nil {
+		if err := r.instantiate(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	// 获取导出的函数
 	alloc := r.instance.ExportedFunction("alloc")
 	handle := r.instance.ExportedFunction("handle")
 	dealloc := r.instance.ExportedFunction("dealloc") // 可选
 
-	inputBytes := []byte(input)
+	inputBytes, err := loadInput(ctx, input)
+	if err != nil {
+		return nil, err
+	}
 	inputLen := uint64(len(inputBytes))
 
 	// 在 WASM 内存中分配空间存储输入
@@ -1327,22 +2177,47 @@ func (r *WasmRuntime) Execute(ctx context.Context, input json.RawMessage) (json.
 	outputPtr := uint32(packedResult >> 32)
 	outputLen := uint32(packedResult & 0xFFFFFFFF)
 
-	// 从 WASM 内存读取输出
+	// 从 WASM 内存读取输出，返回的是线性内存的视图，释放内存前需要复制
 	output, ok := memory.Read(outputPtr, outputLen)
 	if !ok {
 		return nil, fmt.Errorf("failed to read output from wasm memory")
 	}
+	output = append([]byte(nil), output...)
 
-	// 如果有 dealloc 函数，释放输入内存
-	if dealloc != nil {
-		_, _ = dealloc.Call(ctx, uint64(inputPtr), inputLen)
-	}
+	r.release(ctx, dealloc, inputPtr, uint32(inputLen), outputPtr, outputLen)
 
 	if len(output) == 0 {
 		return json.RawMessage("null"), nil
 	}
 
-	return json.RawMessage(output), nil
+	return jsonOutput(output)
+}
+
+// release 释放一次调用分配的输入和输出内存
+// 模块导出了 dealloc 时按分配的逆序释放输出和输入；未导出 dealloc 或释放失败时丢弃实例，
+// 下一次调用重新实例化，避免长期存活的实例线性内存无限增长。
+//
+// 参数:
+//   - ctx: 上下文
+//   - dealloc: 模块导出的 dealloc 函数，可能为 nil
+//   - inputPtr, inputLen: 输入内存区域
+//   - outputPtr, outputLen: handle 返回的输出内存区域
+func (r *WasmRuntime) release(ctx context.Context, dealloc api.Function, inputPtr, inputLen, outputPtr, outputLen uint32) {
+	if dealloc != nil {
+		var err error
+		// 输出与输入是同一块内存时只释放一次
+		if outputLen > 0 && outputPtr != inputPtr {
+			_, err = dealloc.Call(ctx, uint64(outputPtr), uint64(outputLen))
+		}
+		if err == nil {
+			_, err = dealloc.Call(ctx, uint64(inputPtr), uint64(inputLen))
+		}
+		if err == nil {
+			return
+		}
+	}
+	r.instance.Close(ctx)
+	r.instance = nil
 }
 
 // ============================================================================
@@ -1351,6 +2226,8 @@ func (r *WasmRuntime) Execute(ctx context.Context, input json.RawMessage) (json.
 
 // readMessage 从连接读取一条消息
 // 消息格式：4 字节长度（大端序）+ JSON 数据
+// 长度超过 MaxMessageBytes 时不读取消息体，直接返回 ErrMessageTooLarge
+// 长度前缀最高位置位时消息体经过 gzip 压缩，读取后透明解压
 //
 // 参数:
 //   - conn: 网络连接
@@ -1364,13 +2241,24 @@ func readMessage(conn net.Conn) (*Message, error) {
 	if _, err := io.ReadFull(conn, lenBuf); err != nil {
 		return nil, err
 	}
-	length := binary.BigEndian.Uint32(lenBuf)
+	header := binary.BigEndian.Uint32(lenBuf)
+	compressed := header&frameFlagGzip != 0
+	length := header &^ frameFlagGzip
+	if uint64(length) > uint64(MaxMessageBytes) {
+		return nil, fmt.Errorf("%w: %d > %d", ErrMessageTooLarge, length, MaxMessageBytes)
+	}
 
 	// 读取消息体
 	data := make([]byte, length)
 	if _, err := io.ReadFull(conn, data); err != nil {
 		return nil, err
 	}
+	if compressed {
+		var err error
+		if data, err = gunzipFrame(data); err != nil {
+			return nil, err
+		}
+	}
 
 	// 解析 JSON
 	var msg Message
@@ -1381,8 +2269,9 @@ func readMessage(conn net.Conn) (*Message, error) {
 	return &msg, nil
 }
 
-// writeMessage 向连接写入一条消息
+// writeMessage 向连接写入一条不压缩的消息
 // 消息格式：4 字节长度（大端序）+ JSON 数据
+// 序列化后超过 MaxMessageBytes 时不写入任何数据，返回 ErrMessageTooLarge
 //
 // 参数:
 //   - conn: 网络连接
@@ -1391,15 +2280,42 @@ func readMessage(conn net.Conn) (*Message, error) {
 // 返回:
 //   - error: 写入错误
 func writeMessage(conn net.Conn, msg *Message) error {
+	return writeFrame(conn, msg, false)
+}
+
+// writeFrame 向连接写入一条消息
+// compress 为 true 且消息体不小于 CompressionThreshold 时使用 gzip 压缩，并置位长度前缀最高位。
+// 只有在初始化时双方协商启用压缩后才能传入 compress=true。
+//
+// 参数:
+//   - conn: 网络连接
+//   - msg: 要发送的消息
+//   - compress: 是否允许压缩
+//
+// 返回:
+//   - error: 写入错误
+func writeFrame(conn net.Conn, msg *Message, compress bool) error {
 	// 序列化消息
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
+	// 按未压缩大小检查上限，保证对端解压后也不会超限
+	if len(data) > MaxMessageBytes {
+		return fmt.Errorf("%w: %d > %d", ErrMessageTooLarge, len(data), MaxMessageBytes)
+	}
+
+	header := uint32(len(data))
+	if compress && len(data) >= CompressionThreshold {
+		if gz, ok := gzipFrame(data); ok {
+			data = gz
+			header = uint32(len(data)) | frameFlagGzip
+		}
+	}
 
 	// 写入长度前缀
 	lenBuf := make([]byte, 4)
-	binary.BigEndian.PutUint32(lenBuf, uint32(len(data)))
+	binary.BigEndian.PutUint32(lenBuf, header)
 	if _, err := conn.Write(lenBuf); err != nil {
 		return err
 	}
@@ -1483,6 +2399,27 @@ func (r *jsonBytesReader) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+// formatEnv 将环境变量映射转换为 KEY=VALUE 列表，按键排序以保证结果稳定
+//
+// 参数:
+//   - envVars: 函数配置的环境变量
+//
+// 返回:
+//   - []string: 可追加到 exec.Cmd.Env 的环境变量列表
+func formatEnv(envVars map[string]string) []string {
+	keys := make([]string, 0, len(envVars))
+	for k := range envVars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+envVars[k])
+	}
+	return env
+}
+
 // getMemoryUsage 获取当前进程的内存使用量（MB）
 //
 // 返回:
@@ -0,0 +1,593 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFormatEnv(t *testing.T) {
+	got := formatEnv(map[string]string{"B": "2", "A": "1=one"})
+	want := []string{"A=1=one", "B=2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("formatEnv() = %v, want %v", got, want)
+	}
+	if got := formatEnv(nil); len(got) != 0 {
+		t.Errorf("formatEnv(nil) = %v, want empty", got)
+	}
+}
+
+// rawFrame 返回一个连接，对端写入指定的长度前缀和消息体后关闭
+func rawFrame(length uint32, body []byte) net.Conn {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		var header [4]byte
+		binary.BigEndian.PutUint32(header[:], length)
+		server.Write(append(header[:], body...))
+	}()
+	return client
+}
+
+func TestMessageSizeLimit(t *testing.T) {
+	defer func(max int) { MaxMessageBytes = max }(MaxMessageBytes)
+
+	msg := &Message{Type: MessageTypeExec, RequestID: "req-1", Payload: json.RawMessage(`{"input":"hello"}`)}
+	data, _ := json.Marshal(msg)
+	MaxMessageBytes = len(data)
+
+	// 恰好等于上限的消息可以正常读写
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		if err := writeMessage(server, msg); err != nil {
+			t.Errorf("writeMessage() at limit error = %v", err)
+		}
+	}()
+	got, err := readMessage(client)
+	if err != nil || got.RequestID != "req-1" {
+		t.Fatalf("readMessage() at limit = %+v, %v", got, err)
+	}
+
+	// 超过上限一个字节时读写都拒绝，且写入方不发送任何数据
+	MaxMessageBytes = len(data) - 1
+	if err := writeMessage(nil, msg); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("writeMessage() over limit error = %v, want ErrMessageTooLarge", err)
+	}
+	conn := rawFrame(uint32(len(data)), data)
+	defer conn.Close()
+	if _, err := readMessage(conn); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("readMessage() over limit error = %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func TestReadMessageGarbageLength(t *testing.T) {
+	// 异常的长度前缀在分配缓冲区之前被拒绝
+	conn := rawFrame(0xFFFFFFFF, []byte("abc"))
+	defer conn.Close()
+	if _, err := readMessage(conn); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("readMessage() with 4GiB length error = %v, want ErrMessageTooLarge", err)
+	}
+
+	// 长度在上限内但连接提前关闭
+	conn = rawFrame(100, []byte(`{"type":1}`))
+	defer conn.Close()
+	if _, err := readMessage(conn); err != io.ErrUnexpectedEOF {
+		t.Errorf("readMessage() on short connection error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+// TestRuntimesPassEnvVars 验证函数配置的环境变量对运行时子进程可见
+func TestRuntimesPassEnvVars(t *testing.T) {
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+
+	tests := []struct {
+		name     string
+		binary   string
+		runtime  string
+		filename string
+		code     string
+		rt       Runtime
+	}{
+		{
+			name:     "python",
+			binary:   "python3",
+			runtime:  "python3.11",
+			filename: "handler.py",
+			code:     "import os\n\ndef handler(event):\n    return {'greeting': os.environ.get('GREETING')}\n",
+			rt:       &PythonRuntime{},
+		},
+		{
+			name:     "node",
+			binary:   "node",
+			runtime:  "nodejs20",
+			filename: "handler.js",
+			code:     "exports.handler = async () => ({ greeting: process.env.GREETING });\n",
+			rt:       &NodeRuntime{},
+		},
+		{
+			name:     "ruby",
+			binary:   "ruby",
+			runtime:  "ruby3.2",
+			filename: "handler.rb",
+			code:     "def handler(event)\n  { 'greeting' => ENV['GREETING'] }\nend\n",
+			rt:       &RubyRuntime{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := exec.LookPath(tt.binary); err != nil {
+				t.Skipf("%s not installed", tt.binary)
+			}
+			agent := &Agent{}
+			payload := &InitPayload{
+				Handler: "handler.handler",
+				Code:    tt.code,
+				Runtime: tt.runtime,
+				EnvVars: map[string]string{"GREETING": "hello from env"},
+			}
+			if err := agent.writeCode(payload); err != nil {
+				t.Skipf("cannot write function code: %v", err)
+			}
+			if err := tt.rt.Init(payload); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			output, err := tt.rt.Execute(context.Background(), json.RawMessage(`{}`))
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			var result struct {
+				Greeting string `json:"greeting"`
+			}
+			if err := json.Unmarshal(output, &result); err != nil {
+				t.Fatalf("invalid output %s: %v", output, err)
+			}
+			if result.Greeting != "hello from env" {
+				t.Errorf("greeting = %q, want %q", result.Greeting, "hello from env")
+			}
+		})
+	}
+}
+
+// initWarmPython 写入返回进程 PID 的 Python 函数，并以常驻进程模式初始化运行时
+func initWarmPython(t *testing.T) *PythonRuntime {
+	t.Helper()
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not installed")
+	}
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+	payload := &InitPayload{
+		Handler: "handler.handler",
+		Code:    "import os\n\ndef handler(event):\n    return {'pid': os.getpid(), 'n': event['n']}\n",
+		Runtime: "python3.11",
+	}
+	if err := (&Agent{}).writeCode(payload); err != nil {
+		t.Skipf("cannot write function code: %v", err)
+	}
+	rt := &PythonRuntime{warm: true}
+	if err := rt.Init(payload); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	t.Cleanup(func() { rt.Close() })
+	return rt
+}
+
+// executePID 执行一次调用并返回处理函数所在进程的 PID
+func executePID(t *testing.T, rt *PythonRuntime, n int) int {
+	t.Helper()
+	output, err := rt.Execute(context.Background(), json.RawMessage(`{"n":`+strconv.Itoa(n)+`}`))
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	var result struct {
+		PID int `json:"pid"`
+		N   int `json:"n"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil || result.N != n {
+		t.Fatalf("Execute() output = %s, want n=%d", output, n)
+	}
+	return result.PID
+}
+
+func TestPythonWarmWorkerReusesProcess(t *testing.T) {
+	rt := initWarmPython(t)
+
+	pids := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		pids[executePID(t, rt, i)] = true
+	}
+	if len(pids) != 1 || rt.workerStarts != 1 {
+		t.Errorf("100 invocations ran in %d processes (%d worker starts), want 1", len(pids), rt.workerStarts)
+	}
+}
+
+func TestPythonWarmWorkerRecovers(t *testing.T) {
+	rt := initWarmPython(t)
+	first := executePID(t, rt, 1)
+
+	// 常驻进程退出后自动重启
+	rt.worker.kill()
+	if pid := executePID(t, rt, 2); pid == first || rt.workerStarts != 2 {
+		t.Errorf("worker was not restarted after exit (pid %d, starts %d)", pid, rt.workerStarts)
+	}
+
+	// 超过重启次数后回退到逐次启动模式，每次调用使用新进程
+	rt.worker.kill()
+	rt.workerRestarts = pythonWorkerMaxRestarts
+	if a, b := executePID(t, rt, 3), executePID(t, rt, 4); a == b {
+		t.Errorf("fallback invocations shared process %d, want per-invocation processes", a)
+	}
+}
+
+// TestPythonWarmWorkerForwardsStderr 验证常驻进程模式下函数的输出随响应返回并按行转发
+func TestPythonWarmWorkerForwardsStderr(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not installed")
+	}
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+	payload := &InitPayload{
+		Handler: "handler.handler",
+		Code:    "import sys\n\ndef handler(event):\n    print('hello')\n    sys.stderr.write('WARNING: low disk\\n')\n    return {'ok': True}\n",
+		Runtime: "python3.11",
+	}
+	agent := &Agent{initialized: true, config: &InitPayload{TimeoutSec: 5}}
+	if err := agent.writeCode(payload); err != nil {
+		t.Skipf("cannot write function code: %v", err)
+	}
+	rt := &PythonRuntime{warm: true}
+	if err := rt.Init(payload); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	t.Cleanup(func() { rt.Close() })
+	agent.runtime = rt
+
+	host, guest := net.Pipe()
+	defer host.Close()
+	go agent.handleConnection(context.Background(), guest)
+
+	for i := 0; i < 3; i++ {
+		requestID := fmt.Sprintf("req-%d", i)
+		data, _ := json.Marshal(&ExecPayload{Input: json.RawMessage(`{}`), ForwardLogs: true})
+		if err := writeMessage(host, &Message{Type: MessageTypeExec, RequestID: requestID, Payload: data}); err != nil {
+			t.Fatalf("writeMessage() error = %v", err)
+		}
+		var logs []string
+		var resp ResponsePayload
+		for done := false; !done; {
+			msg, err := readMessage(host)
+			if err != nil {
+				t.Fatalf("readMessage() error = %v", err)
+			}
+			switch msg.Type {
+			case MessageTypeLog:
+				var entry LogPayload
+				json.Unmarshal(msg.Payload, &entry)
+				logs = append(logs, entry.Message)
+			case MessageTypeResp:
+				json.Unmarshal(msg.Payload, &resp)
+				done = true
+			}
+		}
+		if !resp.Success || rt.workerStarts != 1 {
+			t.Fatalf("response = %+v (worker starts %d), want success from the warm worker", resp, rt.workerStarts)
+		}
+		// 每次调用只收到自己的输出，标记行不出现在输出中
+		if want := "hello\nWARNING: low disk\n"; resp.Stderr != want {
+			t.Errorf("invocation %d stderr = %q, want %q", i, resp.Stderr, want)
+		}
+		if !reflect.DeepEqual(logs, []string{"hello", "WARNING: low disk"}) {
+			t.Errorf("invocation %d forwarded logs = %q, want the function's two lines", i, logs)
+		}
+	}
+}
+
+// TestInitReusesRuntimeForSameCode 验证同一函数的同一份代码重复初始化时复用现有运行时
+func TestInitReusesRuntimeForSameCode(t *testing.T) {
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+	agent := &Agent{}
+	initWith := func(codeHash string, env map[string]string) Runtime {
+		t.Helper()
+		payload, _ := json.Marshal(&InitPayload{
+			FunctionID: "fn-1",
+			Handler:    "handler.handler",
+			Code:       "def handler(event):\n    return event\n",
+			CodeHash:   codeHash,
+			Runtime:    "python3.11",
+			EnvVars:    env,
+		})
+		resp := agent.handleInit(&Message{Type: MessageTypeInit, RequestID: "init", Payload: payload})
+		var result ResponsePayload
+		if json.Unmarshal(resp.Payload, &result) == nil && result.Error != "" {
+			t.Fatalf("handleInit() error = %s", result.Error)
+		}
+		_, rt, _ := agent.current()
+		return rt
+	}
+
+	rt := initWith("hash-1", map[string]string{"A": "1"})
+	if next := initWith("hash-1", map[string]string{"A": "1"}); next != rt {
+		t.Error("re-init with the same code rebuilt the runtime, want it reused")
+	}
+	tests := []struct {
+		name     string
+		codeHash string
+		env      map[string]string
+	}{
+		{"new env vars", "hash-1", map[string]string{"A": "2"}},
+		{"new code", "hash-2", map[string]string{"A": "2"}},
+		// 没有代码哈希时无法确认代码未变，总是重建
+		{"no code hash", "", map[string]string{"A": "2"}},
+	}
+	for _, tt := range tests {
+		if next := initWith(tt.codeHash, tt.env); next == rt {
+			t.Errorf("re-init with %s reused the runtime, want it rebuilt", tt.name)
+		} else {
+			rt = next
+		}
+	}
+}
+
+// TestExecReturnsStderr 验证函数成功执行时标准错误输出也随响应返回给宿主机
+func TestExecReturnsStderr(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not installed")
+	}
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+	payload := &InitPayload{
+		Handler: "handler.handler",
+		Code:    "import sys\n\ndef handler(event):\n    sys.stderr.write('loading model\\n')\n    return {'ok': True}\n",
+		Runtime: "python3.11",
+	}
+	agent := &Agent{initialized: true, config: &InitPayload{TimeoutSec: 5}}
+	if err := agent.writeCode(payload); err != nil {
+		t.Skipf("cannot write function code: %v", err)
+	}
+	rt := &PythonRuntime{}
+	if err := rt.Init(payload); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	agent.runtime = rt
+
+	host, guest := net.Pipe()
+	defer host.Close()
+	go agent.handleConnection(context.Background(), guest)
+
+	data, _ := json.Marshal(&ExecPayload{Input: json.RawMessage(`{}`)})
+	if err := writeMessage(host, &Message{Type: MessageTypeExec, RequestID: "req-1", Payload: data}); err != nil {
+		t.Fatalf("writeMessage() error = %v", err)
+	}
+	msg, err := readMessage(host)
+	if err != nil {
+		t.Fatalf("readMessage() error = %v", err)
+	}
+	var resp ResponsePayload
+	if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("response = %+v, want success", resp)
+	}
+	if resp.Stderr != "loading model\n" {
+		t.Errorf("stderr = %q, want %q", resp.Stderr, "loading model\n")
+	}
+}
+
+func TestStderrCaptureTruncates(t *testing.T) {
+	c := &stderrCapture{limit: 8}
+	c.Write([]byte("12345"))
+	c.Write([]byte("67890"))
+	if got, want := c.String(), "12345678"+stderrTruncatedSuffix; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSetupLayersRejectsTamperedContent(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("python/util.py")
+	f.Write([]byte("VALUE = 1\n"))
+	zw.Close()
+	content := buf.Bytes()
+	sum := sha256.Sum256(content)
+
+	layer := LayerInfo{LayerID: "layer-1", Version: 2, Content: content, ContentHash: hex.EncodeToString(sum[:])}
+	if err := verifyLayerContent(&layer); err != nil {
+		t.Fatalf("verifyLayerContent() on intact layer error = %v", err)
+	}
+
+	// 传输中被篡改的一个字节
+	tampered := layer
+	tampered.Content = append([]byte(nil), content...)
+	tampered.Content[len(tampered.Content)/2] ^= 0xff
+	err := (&Agent{}).setupLayers(&InitPayload{Runtime: "python3.11", Layers: []LayerInfo{tampered}})
+	if err == nil || !strings.Contains(err.Error(), "layer layer-1 (v2) content hash mismatch") {
+		t.Errorf("setupLayers() error = %v, want content hash mismatch", err)
+	}
+}
+
+// processAlive 判断进程是否仍在运行（僵尸进程视为已退出）
+func processAlive(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// 格式为 "pid (comm) state ..."，comm 中可能含空格，从最后一个右括号之后取状态
+	fields := strings.Fields(string(data[bytes.LastIndexByte(data, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestTimeoutKillsProcessTree(t *testing.T) {
+	for _, bin := range []string{"python3", "sleep"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not installed", bin)
+		}
+	}
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+	payload := &InitPayload{
+		Handler: "handler.handler",
+		Code: "import subprocess, time\n\n" +
+			"def handler(event):\n" +
+			"    child = subprocess.Popen(['sleep', '30'], stdout=subprocess.DEVNULL, stderr=subprocess.DEVNULL)\n" +
+			"    with open(event['pid_file'], 'w') as f:\n" +
+			"        f.write(str(child.pid))\n" +
+			"    time.sleep(30)\n",
+		Runtime: "python3.11",
+	}
+	if err := (&Agent{}).writeCode(payload); err != nil {
+		t.Skipf("cannot write function code: %v", err)
+	}
+	rt := &PythonRuntime{}
+	if err := rt.Init(payload); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	input, _ := json.Marshal(map[string]string{"pid_file": pidFile})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := rt.Execute(ctx, input); err == nil {
+		t.Fatal("Execute() succeeded, want timeout error")
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("handler did not start its child process: %v", err)
+	}
+	pid, _ := strconv.Atoi(string(data))
+	deadline := time.Now().Add(3 * time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("child process %d survived the invocation timeout", pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestJavaRuntimeRejectsInvalidJar(t *testing.T) {
+	err := (&Agent{}).writeCode(&InitPayload{Runtime: "java17", Handler: "com.example.Handler::handle", Code: "not base64!"})
+	if err == nil || !strings.Contains(err.Error(), "base64-encoded JAR") {
+		t.Errorf("writeCode() error = %v, want invalid JAR error", err)
+	}
+	if err := (&JavaRuntime{}).Init(&InitPayload{Runtime: "java17", Handler: "com.example.Handler::"}); err == nil {
+		t.Errorf("Init() with empty method name should fail")
+	}
+}
+
+func TestJavaRuntimeExecutesJar(t *testing.T) {
+	for _, bin := range []string{"java", "javac", "jar"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not installed", bin)
+		}
+	}
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+
+	// 构建只包含 com.example.Handler 的 JAR
+	src := t.TempDir()
+	pkg := filepath.Join(src, "com", "example")
+	os.MkdirAll(pkg, 0755)
+	handler := `package com.example;
+
+public class Handler {
+    public String handle(String input) {
+        System.out.println("debug output");
+        return "{\"greeting\":\"" + System.getenv("GREETING") + "\",\"input\":" + input + "}";
+    }
+}
+`
+	if err := os.WriteFile(filepath.Join(pkg, "Handler.java"), []byte(handler), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("javac", "-d", src, filepath.Join(pkg, "Handler.java")).CombinedOutput(); err != nil {
+		t.Fatalf("javac: %v: %s", err, out)
+	}
+	jarPath := filepath.Join(src, "handler.jar")
+	if out, err := exec.Command("jar", "cf", jarPath, "-C", src, "com").CombinedOutput(); err != nil {
+		t.Fatalf("jar: %v: %s", err, out)
+	}
+	jar, err := os.ReadFile(jarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := &InitPayload{
+		Handler: "com.example.Handler::handle",
+		Code:    base64.StdEncoding.EncodeToString(jar),
+		Runtime: "java17",
+		EnvVars: map[string]string{"GREETING": "hello from env"},
+	}
+	if err := (&Agent{}).writeCode(payload); err != nil {
+		t.Skipf("cannot write function code: %v", err)
+	}
+	rt := &JavaRuntime{}
+	if err := rt.Init(payload); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	output, err := rt.Execute(context.Background(), json.RawMessage(`{"n":1}`))
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	var result struct {
+		Greeting string `json:"greeting"`
+		Input    struct {
+			N int `json:"n"`
+		} `json:"input"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("invalid output %s: %v", output, err)
+	}
+	if result.Greeting != "hello from env" || result.Input.N != 1 {
+		t.Errorf("Execute() output = %s", output)
+	}
+}
+
+func TestParseIPv6BootArgs(t *testing.T) {
+	cmdline := "console=ttyS0 reboot=k ip=172.20.0.2::172.20.0.1:255.255.0.0::eth0:off nimbus.ipv6=fd00:172:20:1::1/64 nimbus.gw6=fd00:172:20::1\n"
+	addr, gw := parseIPv6BootArgs(cmdline)
+	if addr != "fd00:172:20:1::1/64" || gw != "fd00:172:20::1" {
+		t.Errorf("parseIPv6BootArgs = (%q, %q)", addr, gw)
+	}
+
+	addr, gw = parseIPv6BootArgs("console=ttyS0 init=/init")
+	if addr != "" || gw != "" {
+		t.Errorf("parseIPv6BootArgs without IPv6 = (%q, %q), want empty", addr, gw)
+	}
+}
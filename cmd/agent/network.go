@@ -0,0 +1,61 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// 宿主机通过内核启动参数下发的 IPv6 配置。
+// 内核 ip= 参数只支持 IPv4，IPv6 由 agent 在启动时自行配置。
+const (
+	bootArgIPv6       = "nimbus.ipv6" // 虚拟机 IPv6 地址及前缀长度，如 fd00:172:20:1::1/64
+	bootArgIPv6Gate   = "nimbus.gw6"  // IPv6 网关地址
+	guestNetworkIface = "eth0"
+)
+
+// parseIPv6BootArgs 从内核命令行中解析 IPv6 地址和网关，未下发时返回空字符串
+func parseIPv6BootArgs(cmdline string) (addr, gateway string) {
+	for _, field := range strings.Fields(cmdline) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case bootArgIPv6:
+			addr = value
+		case bootArgIPv6Gate:
+			gateway = value
+		}
+	}
+	return addr, gateway
+}
+
+// configureIPv6 按内核启动参数为 eth0 配置 IPv6 地址和默认路由。
+// 网关位于网桥保留的前缀中，不在虚拟机前缀内，因此默认路由需标记为 onlink。
+// 未启用 IPv6 时不做任何事。
+func configureIPv6() error {
+	cmdline, err := os.ReadFile("/proc/cmdline")
+	if err != nil {
+		return err
+	}
+	addr, gateway := parseIPv6BootArgs(string(cmdline))
+	if addr == "" {
+		return nil
+	}
+
+	if out, err := exec.Command("ip", "-6", "addr", "add", addr, "dev", guestNetworkIface).CombinedOutput(); err != nil {
+		return fmt.Errorf("add IPv6 address %s: %w: %s", addr, err, out)
+	}
+	if gateway == "" {
+		return nil
+	}
+	if out, err := exec.Command("ip", "-6", "route", "replace", "default", "via", gateway, "dev", guestNetworkIface, "onlink").CombinedOutput(); err != nil {
+		return fmt.Errorf("add IPv6 default route via %s: %w: %s", gateway, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// invalidOutputPreviewBytes 是输出不是合法 JSON 时错误信息中展示的输出开头字节数
+const invalidOutputPreviewBytes = 200
+
+// ErrInvalidOutput 表示函数写到标准输出的结果不是合法 JSON
+var ErrInvalidOutput = errors.New("function output is not valid JSON")
+
+// jsonOutput 校验运行时子进程写到标准输出的结果，返回可直接作为调用输出的 JSON。
+// 处理函数在返回结果前用 print/console.log 打印了日志时，标准输出不再是单个 JSON 值，
+// 错误信息包含输出开头的一段，便于定位多余的打印；首尾空白（如末尾换行）不影响校验。
+// 空输出表示函数没有返回值，原样返回。
+//
+// 参数:
+//   - output: 子进程的标准输出
+//
+// 返回:
+//   - json.RawMessage: 函数输出
+//   - error: 输出不是合法 JSON 时返回包装 ErrInvalidOutput 的错误
+func jsonOutput(output []byte) (json.RawMessage, error) {
+	if len(output) == 0 || json.Valid(output) {
+		return json.RawMessage(output), nil
+	}
+	preview := output
+	if len(preview) > invalidOutputPreviewBytes {
+		preview = preview[:invalidOutputPreviewBytes]
+	}
+	return nil, fmt.Errorf("%w (%d bytes, starts with %q); write logs to stderr instead of stdout",
+		ErrInvalidOutput, len(output), preview)
+}
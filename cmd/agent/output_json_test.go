@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONOutput(t *testing.T) {
+	for _, output := range []string{`{"ok":true}`, "{\"ok\":true}\n", `[1,2]`, `"text"`, ""} {
+		got, err := jsonOutput([]byte(output))
+		if err != nil || string(got) != output {
+			t.Errorf("jsonOutput(%q) = %q, %v; want unchanged", output, got, err)
+		}
+	}
+
+	// 处理函数在返回结果前打印了日志
+	_, err := jsonOutput([]byte("loading model...\n{\"ok\":true}\n"))
+	if !errors.Is(err, ErrInvalidOutput) {
+		t.Fatalf("log-prefixed output: error = %v, want ErrInvalidOutput", err)
+	}
+	if !strings.Contains(err.Error(), `starts with "loading model...\n{\"ok\":true}\n"`) {
+		t.Errorf("error = %q, want it to show the output prefix", err)
+	}
+
+	// 过长的输出只展示开头部分
+	_, err = jsonOutput([]byte("debug: " + strings.Repeat("x", 1000)))
+	if !errors.Is(err, ErrInvalidOutput) || strings.Count(err.Error(), "x") > invalidOutputPreviewBytes || !strings.Contains(err.Error(), "1007 bytes") {
+		t.Errorf("long output: error = %q, want truncated preview and total size", err)
+	}
+}
@@ -0,0 +1,99 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 函数输出大小限制相关常量
+const (
+	// DefaultMaxOutputBytes 是单次非流式执行的函数输出默认最大字节数（6MB）
+	DefaultMaxOutputBytes = 6 * 1024 * 1024
+	// EnvMaxOutputBytes 是覆盖函数输出上限的 Agent 环境变量（字节数）
+	EnvMaxOutputBytes = "NIMBUS_MAX_OUTPUT_BYTES"
+	// outputPrefixBytes 是输出超限时随响应返回、用于排查的输出前缀字节数
+	outputPrefixBytes = 1024
+)
+
+// MaxOutputBytes 是非流式执行时函数输出允许的最大字节数，超过时调用失败。
+// 应小于 MaxMessageBytes，保证失败响应（含输出前缀和标准错误）仍能发出。
+var MaxOutputBytes = DefaultMaxOutputBytes
+
+// ErrOutputTooLarge 表示函数输出超过 MaxOutputBytes
+var ErrOutputTooLarge = errors.New("output too large")
+
+// outputTooLargeError 描述超限的函数输出，保留输出开头的一段用于排查
+type outputTooLargeError struct {
+	size   int64  // 输出的实际字节数
+	limit  int    // 超过的上限
+	prefix []byte // 输出开头最多 outputPrefixBytes 字节
+}
+
+// Error 实现 error 接口
+func (e *outputTooLargeError) Error() string {
+	return fmt.Sprintf("%v: %d bytes exceeds limit of %d bytes", ErrOutputTooLarge, e.size, e.limit)
+}
+
+// Is 使 errors.Is(err, ErrOutputTooLarge) 成立
+func (e *outputTooLargeError) Is(target error) bool {
+	return target == ErrOutputTooLarge
+}
+
+// newOutputTooLargeError 根据超限的输出（或其已保留的开头部分）构造错误
+func newOutputTooLargeError(size int64, limit int, head []byte) *outputTooLargeError {
+	if len(head) > outputPrefixBytes {
+		head = head[:outputPrefixBytes]
+	}
+	return &outputTooLargeError{size: size, limit: limit, prefix: append([]byte(nil), head...)}
+}
+
+// checkOutputSize 检查运行时返回的完整输出是否超过 MaxOutputBytes
+func checkOutputSize(output []byte) error {
+	if len(output) > MaxOutputBytes {
+		return newOutputTooLargeError(int64(len(output)), MaxOutputBytes, output)
+	}
+	return nil
+}
+
+// outputBuffer 收集子进程的标准输出，最多保留 limit 字节，超出部分只计数不保存，
+// 避免失控的函数输出耗尽虚拟机内存
+type outputBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+	size  int64
+}
+
+// newOutputBuffer 创建上限为 MaxOutputBytes 的输出缓冲区
+func newOutputBuffer() *outputBuffer {
+	return &outputBuffer{limit: MaxOutputBytes}
+}
+
+// Write 追加输出，超过上限的部分被丢弃，总是返回 len(p)
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	b.size += int64(n)
+	if remaining := b.limit - len(b.buf); remaining > 0 {
+		if n > remaining {
+			p = p[:remaining]
+		}
+		b.buf = append(b.buf, p...)
+	}
+	return n, nil
+}
+
+// Bytes 返回收集到的输出，超过上限时返回已保留的部分和 *outputTooLargeError
+func (b *outputBuffer) Bytes() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.size > int64(b.limit) {
+		return b.buf, newOutputTooLargeError(b.size, b.limit, b.buf)
+	}
+	return b.buf, nil
+}
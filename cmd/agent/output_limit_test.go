@@ -0,0 +1,70 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+)
+
+// execOnce 通过连接发送一次非流式执行请求并返回响应
+func execOnce(t *testing.T, rt Runtime) *ResponsePayload {
+	t.Helper()
+	agent := &Agent{initialized: true, runtime: rt, config: &InitPayload{TimeoutSec: 5}}
+	host, guest := net.Pipe()
+	defer host.Close()
+	go agent.handleConnection(context.Background(), guest)
+
+	data, _ := json.Marshal(&ExecPayload{Input: json.RawMessage(`{}`)})
+	if err := writeMessage(host, &Message{Type: MessageTypeExec, RequestID: "req-1", Payload: data}); err != nil {
+		t.Fatalf("writeMessage() error = %v", err)
+	}
+	msg, err := readMessage(host)
+	if err != nil {
+		t.Fatalf("readMessage() error = %v", err)
+	}
+	var resp ResponsePayload
+	if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return &resp
+}
+
+func TestExecRejectsOversizedOutput(t *testing.T) {
+	defer func(max int) { MaxOutputBytes = max }(MaxOutputBytes)
+	MaxOutputBytes = 4096
+
+	tests := []struct {
+		name string
+		rt   Runtime
+		size string
+	}{
+		// 子进程运行时：标准输出在收集时即被截断
+		{name: "subprocess", rt: &shellRuntime{script: `printf '"'; head -c 200000 /dev/zero | tr '\0' a; printf '"'`}, size: "200002 bytes"},
+		// 不经过 runCommand 的运行时：在 handleExec 中检查完整输出
+		{name: "in-process", rt: &fakeRuntime{output: `"` + strings.Repeat("a", 5000) + `"`}, size: "5002 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := execOnce(t, tt.rt)
+			if resp.Success || len(resp.Output) != 0 {
+				t.Fatalf("response success=%v output=%d bytes, want failure without output", resp.Success, len(resp.Output))
+			}
+			if !strings.Contains(resp.Error, "output too large") || !strings.Contains(resp.Error, tt.size) {
+				t.Errorf("error = %q, want output too large with %s", resp.Error, tt.size)
+			}
+			if want := `"` + strings.Repeat("a", outputPrefixBytes-1); resp.OutputPrefix != want {
+				t.Errorf("output prefix = %d bytes, want first %d bytes of output", len(resp.OutputPrefix), outputPrefixBytes)
+			}
+		})
+	}
+
+	resp := execOnce(t, &shellRuntime{script: `echo '{"ok":true}'`})
+	if !resp.Success || resp.OutputPrefix != "" {
+		t.Errorf("small output response = %+v, want success", resp)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 让子进程成为新进程组的组长，上下文取消时终止整个进程组
+// exec.CommandContext 默认只向直接子进程发送 SIGKILL，函数代码自己启动的子进程会残留在虚拟机中占用内存。
+// 必须在 cmd.Start 之前调用。
+//
+// 参数:
+//   - cmd: 尚未启动的命令
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+}
+
+// killProcessGroup 向子进程所在的进程组发送 SIGKILL
+// 子进程未通过 setProcessGroup 启动时只终止子进程本身
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd.Process.Kill()
+}
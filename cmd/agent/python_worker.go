@@ -0,0 +1,439 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Python 常驻进程相关常量
+const (
+	// EnvPythonWarmWorker 是启用 Python 常驻进程模式的 Agent 环境变量（true/1 启用）
+	EnvPythonWarmWorker = "NIMBUS_PYTHON_WARM_WORKER"
+
+	// pythonWorkerStartTimeout 是常驻进程导入处理函数并报告就绪的最长等待时间
+	pythonWorkerStartTimeout = 30 * time.Second
+	// pythonWorkerMaxRestarts 是常驻进程退出后的最大重启次数，超过后固定使用逐次启动模式
+	pythonWorkerMaxRestarts = 3
+	// pythonWorkerMaxFrame 是常驻进程协议单帧的最大字节数
+	pythonWorkerMaxFrame = 64 * 1024 * 1024
+	// pythonWorkerFlagWarmup 是请求帧长度前缀的最高位，置位表示预热请求（帧内容为预热函数）
+	pythonWorkerFlagWarmup = uint32(1) << 31
+	// pythonWorkerFlushMark 是常驻进程每次写响应帧前在标准错误上写出的标记行前缀，后跟帧序号
+	pythonWorkerFlushMark = "\x00nimbus-flush "
+	// pythonWorkerFlushTimeout 是收到响应后等待标准错误读到对应标记的最长时间
+	pythonWorkerFlushTimeout = time.Second
+)
+
+// pythonWorkerScript 是常驻进程的包装脚本。
+// 处理函数只导入一次，之后循环从标准输入读取长度前缀（4 字节大端序）的 JSON 请求，
+// 向标准输出写入长度前缀的 JSON 响应。用户代码的 print 输出被重定向到标准错误，避免破坏协议。
+// 每次写响应帧前在标准错误上写出带帧序号的标记行并刷新，Agent 据此确认该次调用的输出已全部读到。
+// 长度前缀最高位置位的是预热请求，调用其中指定的预热函数，预热加载的内容留在进程中供后续调用使用。
+const pythonWorkerScript = `
+import sys
+import json
+import struct
+import traceback
+sys.path.insert(0, '%s')
+
+_in = sys.stdin.buffer
+_out = sys.stdout.buffer
+_err = sys.stderr
+sys.stdout = sys.stderr
+_frames = 0
+
+def _read_exact(n):
+    buf = b''
+    while len(buf) < n:
+        chunk = _in.read(n - len(buf))
+        if not chunk:
+            return None
+        buf += chunk
+    return buf
+
+def _write(resp):
+    global _frames
+    _frames += 1
+    _err.write('\x00nimbus-flush %%d\n' %% _frames)
+    _err.flush()
+    data = json.dumps(resp).encode()
+    _out.write(struct.pack('>I', len(data)))
+    _out.write(data)
+    _out.flush()
+
+try:
+    parts = '%s'.rsplit('.', 1)
+    if len(parts) == 2:
+        module_name, func_name = parts
+    else:
+        module_name, func_name = 'handler', parts[0]
+    handler = getattr(__import__(module_name), func_name)
+except Exception:
+    _write({'ok': False, 'error': traceback.format_exc()})
+    sys.exit(1)
+
+# 导入成功，通知 Agent 已就绪
+_write({'ok': True})
+
+while True:
+    header = _read_exact(4)
+    if header is None:
+        break
+    size = struct.unpack('>I', header)[0]
+    body = _read_exact(size & 0x7fffffff)
+    if body is None:
+        break
+    try:
+        if size & 0x80000000:
+            target = json.loads(body)
+            getattr(__import__(target['module']), target['func'])()
+            _write({'ok': True})
+            continue
+        resp = {'ok': True, 'result': handler(json.loads(body))}
+        _write(resp)
+    except Exception:
+        _write({'ok': False, 'error': traceback.format_exc()})
+`
+
+// pythonWorkerResponse 是常驻进程返回的单帧响应
+type pythonWorkerResponse struct {
+	OK     bool            `json:"ok"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// pythonWorker 管理一个常驻的 Python 解释器进程
+type pythonWorker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *workerOutput
+	frames int           // 已读到的响应帧数
+	done   chan struct{} // 进程退出后关闭
+}
+
+// workerOutput 是常驻进程标准错误的写入端，按行切分输出：
+// 调用期间的输出写入该次调用的去向（标准错误收集器和日志转发器），调用之间的输出写入 Agent 的标准错误；
+// 读到常驻进程写出的标记行时记录帧序号并通知等待的调用方
+type workerOutput struct {
+	mu      sync.Mutex
+	pending []byte    // 尚未读到换行的部分
+	sink    io.Writer // 当前调用的输出去向，为 nil 时写入 os.Stderr
+	marked  int       // 最近读到的标记行中的帧序号
+	notify  chan struct{}
+}
+
+func newWorkerOutput() *workerOutput {
+	return &workerOutput{notify: make(chan struct{}, 1)}
+}
+
+// Write 按行处理输出，总是返回 len(p)
+func (o *workerOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending = append(o.pending, p...)
+	for {
+		i := bytes.IndexByte(o.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := o.pending[:i+1]
+		if j := bytes.Index(line, []byte(pythonWorkerFlushMark)); j >= 0 {
+			// 标记行前可能有函数写出的不带换行的内容
+			o.target().Write(line[:j])
+			if n, err := strconv.Atoi(string(line[j+len(pythonWorkerFlushMark) : i])); err == nil {
+				o.marked = n
+				select {
+				case o.notify <- struct{}{}:
+				default:
+				}
+			}
+		} else {
+			o.target().Write(line)
+		}
+		o.pending = append(o.pending[:0], o.pending[i+1:]...)
+	}
+	// 过长的单行不再等待换行，直接写出
+	if len(o.pending) > maxLogLineBytes {
+		o.target().Write(o.pending)
+		o.pending = o.pending[:0]
+	}
+	return len(p), nil
+}
+
+// target 返回当前的输出去向，调用方需持有 o.mu
+func (o *workerOutput) target() io.Writer {
+	if o.sink != nil {
+		return o.sink
+	}
+	return os.Stderr
+}
+
+// begin 将此后的输出写入 sink
+func (o *workerOutput) begin(sink io.Writer) {
+	o.mu.Lock()
+	o.sink = sink
+	o.mu.Unlock()
+}
+
+// end 等待读到第 frame 帧的标记行（进程退出或超时时不再等待），之后的输出恢复写入 Agent 的标准错误
+func (o *workerOutput) end(frame int, done <-chan struct{}) {
+	defer o.begin(nil)
+	timer := time.NewTimer(pythonWorkerFlushTimeout)
+	defer timer.Stop()
+	for o.markedFrame() < frame {
+		select {
+		case <-o.notify:
+		case <-done:
+			return
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+// markedFrame 返回最近读到的标记行中的帧序号
+func (o *workerOutput) markedFrame() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.marked
+}
+
+// startPythonWorker 启动常驻进程并等待处理函数导入完成
+//
+// 参数:
+//   - interpreter: Python 解释器
+//   - env: 函数配置的环境变量（KEY=VALUE）
+//
+// 返回:
+//   - *pythonWorker: 已就绪的常驻进程
+//   - error: 启动失败或处理函数导入失败
+func startPythonWorker(interpreter string, env []string) (*pythonWorker, error) {
+	cmd := exec.Command(interpreter, filepath.Join(FunctionDir, "_worker.py"))
+	cmd.Env = append(os.Environ(), env...)
+	// 独立进程组，终止常驻进程时一并终止函数代码启动的子进程
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	output := newWorkerOutput()
+	cmd.Stderr = output
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start python worker: %w", err)
+	}
+
+	w := &pythonWorker{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		stderr: output,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		cmd.Wait()
+		close(w.done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pythonWorkerStartTimeout)
+	defer cancel()
+	ready, err := w.receive(ctx)
+	if err != nil {
+		w.kill()
+		return nil, fmt.Errorf("python worker did not become ready: %w", err)
+	}
+	if !ready.OK {
+		w.kill()
+		return nil, fmt.Errorf("python error: %s", ready.Error)
+	}
+	return w, nil
+}
+
+// alive 判断常驻进程是否仍在运行
+func (w *pythonWorker) alive() bool {
+	select {
+	case <-w.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// call 发送一次调用请求并等待响应，调用期间进程的标准错误输出写入 output。
+// 上下文取消（如执行超时）时进程状态不可知，会被终止。
+func (w *pythonWorker) call(ctx context.Context, input json.RawMessage, output io.Writer) (json.RawMessage, error) {
+	if len(input) == 0 {
+		input = json.RawMessage("null")
+	}
+	w.stderr.begin(output)
+	resp, err := w.roundTrip(ctx, 0, input)
+	w.stderr.end(w.frames, w.done)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("python error: %s", resp.Error)
+	}
+	return resp.Result, nil
+}
+
+// warmup 在常驻进程中调用预热函数并等待其返回
+func (w *pythonWorker) warmup(ctx context.Context, target warmupTarget) error {
+	body, err := json.Marshal(map[string]string{"module": target.module, "func": target.fn})
+	if err != nil {
+		return err
+	}
+	resp, err := w.roundTrip(ctx, pythonWorkerFlagWarmup, body)
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("python warmup error: %s", resp.Error)
+	}
+	return nil
+}
+
+// roundTrip 发送一帧请求（长度前缀带上 flags）并等待响应
+func (w *pythonWorker) roundTrip(ctx context.Context, flags uint32, body []byte) (*pythonWorkerResponse, error) {
+	frame := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(len(body))|flags)
+	copy(frame[4:], body)
+	if _, err := w.stdin.Write(frame); err != nil {
+		w.kill()
+		return nil, fmt.Errorf("python worker write failed: %w", err)
+	}
+	return w.receive(ctx)
+}
+
+// receive 读取一帧响应，超时、读取失败或进程退出时终止进程并返回错误
+func (w *pythonWorker) receive(ctx context.Context) (*pythonWorkerResponse, error) {
+	type result struct {
+		resp *pythonWorkerResponse
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		resp, err := w.readFrame()
+		ch <- result{resp, err}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			w.kill()
+			return nil, fmt.Errorf("python worker exited: %w", r.err)
+		}
+		w.frames++
+		return r.resp, nil
+	case <-ctx.Done():
+		w.kill()
+		return nil, ctx.Err()
+	}
+}
+
+// readFrame 从标准输出读取一个长度前缀的 JSON 帧
+func (w *pythonWorker) readFrame() (*pythonWorkerResponse, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(w.stdout, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > pythonWorkerMaxFrame {
+		return nil, fmt.Errorf("response too large: %d bytes", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(w.stdout, data); err != nil {
+		return nil, err
+	}
+	var resp pythonWorkerResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
+
+// kill 终止常驻进程（连同其进程组）并等待其退出
+func (w *pythonWorker) kill() {
+	killProcessGroup(w.cmd)
+	<-w.done
+}
+
+// close 关闭标准输入使常驻进程正常退出，超时后强制终止
+func (w *pythonWorker) close() {
+	w.stdin.Close()
+	select {
+	case <-w.done:
+	case <-time.After(time.Second):
+		w.kill()
+	}
+}
+
+// errPythonWorkerUnavailable 表示常驻进程不可用，需要使用逐次启动模式
+var errPythonWorkerUnavailable = errors.New("python worker unavailable")
+
+// executeWarm 通过常驻进程执行函数。
+// 常驻进程在调用前已退出时会尝试重启，超过重启次数或重启失败返回 errPythonWorkerUnavailable，
+// 此时请求尚未发送给任何进程，调用方可以安全地改用逐次启动模式。
+func (r *PythonRuntime) executeWarm(ctx context.Context, input json.RawMessage) (json.RawMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.worker == nil || !r.worker.alive() {
+		if r.workerRestarts >= pythonWorkerMaxRestarts {
+			return nil, errPythonWorkerUnavailable
+		}
+		r.workerRestarts++
+		worker, err := startPythonWorker(r.interpreter(), r.env)
+		if err != nil {
+			fmt.Printf("Python worker restart failed: %v\n", err)
+			return nil, errPythonWorkerUnavailable
+		}
+		// 重启的进程需要重新预热，预热失败时不使用该进程
+		if r.rewarm != nil {
+			if err := worker.warmup(ctx, *r.rewarm); err != nil {
+				fmt.Printf("Python worker warmup after restart failed: %v\n", err)
+				worker.kill()
+				return nil, errPythonWorkerUnavailable
+			}
+		}
+		r.worker = worker
+		r.workerStarts++
+	}
+	input, err := loadInput(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	// 函数的输出（包括被重定向的 print）与逐次启动模式一样进入标准错误收集器，并按需转发
+	return r.worker.call(ctx, input, teeLogs(ctx, stderrCaptureFrom(ctx), "stderr"))
+}
+
+// Close 停止常驻进程（如果有）
+func (r *PythonRuntime) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.worker != nil {
+		r.worker.close()
+		r.worker = nil
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mdlayher/vsock"
+)
+
+// stateRequestTimeout 是单个状态请求在宿主机往返的最长时间（与 nimbus 模块的 HTTP 超时一致）
+const stateRequestTimeout = 5 * time.Second
+
+// stateOperations 是 Agent 接受的状态操作类型
+var stateOperations = map[string]bool{
+	"get": true, "get_with_version": true,
+	"set": true, "set_with_version": true,
+	"delete": true, "incr": true,
+	"exists": true, "keys": true, "expire": true,
+}
+
+// dialHostState 通过 vsock 连接宿主机的状态服务
+func dialHostState() (net.Conn, error) {
+	return vsock.Dial(vsock.Host, StateVsockPort, nil)
+}
+
+// startStateAPI 在 127.0.0.1:StateAPIPort 上启动状态 HTTP API
+// 重复初始化时复用已启动的服务
+//
+// 返回:
+//   - error: 端口监听失败
+func (a *Agent) startStateAPI() error {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	if a.stateServer != nil {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", StateAPIPort))
+	if err != nil {
+		return err
+	}
+	a.stateServer = &http.Server{Handler: a.stateAPIHandler()}
+	go a.stateServer.Serve(listener)
+	fmt.Printf("State API listening on 127.0.0.1:%d\n", StateAPIPort)
+	return nil
+}
+
+// stateAPIHandler 返回状态 HTTP API 的处理器
+// POST /state 接收 StatePayload，转发给宿主机后原样返回 StateResponsePayload。
+// 操作结果（包括失败和版本冲突）都以 200 返回，由 nimbus 模块根据 success 和 error_code 区分；
+// 只有请求本身无法解析时才返回 4xx。
+func (a *Agent) stateAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var payload StatePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, fmt.Sprintf("invalid state payload: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		config, _, _ := a.current()
+		var resp *StateResponsePayload
+		switch {
+		case config == nil || !config.StateEnabled:
+			resp = &StateResponsePayload{Error: "state feature not enabled for this function", ErrorCode: "operation_error"}
+		case !stateOperations[payload.Operation]:
+			resp = &StateResponsePayload{Error: fmt.Sprintf("unknown operation: %s", payload.Operation), ErrorCode: "operation_error"}
+		default:
+			resp = a.forwardState(r.Context(), &payload)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
+
+// forwardState 将状态操作作为 MessageTypeState 消息发送给宿主机并等待响应
+// 状态连接按需建立，读写失败后关闭，下一次请求重新连接。
+// 连接不可用时返回 backend_unavailable，函数代码会得到 StateBackendUnavailable。
+//
+// 参数:
+//   - ctx: 上下文，用于超时控制
+//   - payload: 状态操作载荷，函数 ID 和会话标识为空时使用初始化配置
+//
+// 返回:
+//   - *StateResponsePayload: 宿主机返回的状态操作结果
+func (a *Agent) forwardState(ctx context.Context, payload *StatePayload) *StateResponsePayload {
+	if config, _, _ := a.current(); config != nil {
+		if payload.FunctionID == "" {
+			payload.FunctionID = config.FunctionID
+		}
+		if payload.SessionKey == "" {
+			payload.SessionKey = config.SessionKey
+		}
+	}
+	data, _ := json.Marshal(payload)
+	msg := &Message{
+		Type:      MessageTypeState,
+		RequestID: fmt.Sprintf("state-%d", atomic.AddUint64(&a.stateSeq, 1)),
+		Payload:   data,
+	}
+
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+
+	if a.stateConn == nil {
+		dial := a.stateDial
+		if dial == nil {
+			dial = dialHostState
+		}
+		conn, err := dial()
+		if err != nil {
+			return stateUnavailable(fmt.Errorf("failed to connect to host: %w", err))
+		}
+		a.stateConn = conn
+	}
+
+	deadline := time.Now().Add(stateRequestTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	a.stateConn.SetDeadline(deadline)
+
+	resp, err := a.roundTripState(msg)
+	if err != nil {
+		a.stateConn.Close()
+		a.stateConn = nil
+		return stateUnavailable(err)
+	}
+	return resp
+}
+
+// roundTripState 在状态连接上发送一条请求并读取对应的响应
+// 调用方需持有 stateMu
+func (a *Agent) roundTripState(msg *Message) (*StateResponsePayload, error) {
+	if err := writeMessage(a.stateConn, msg); err != nil {
+		return nil, fmt.Errorf("failed to send state request: %w", err)
+	}
+	reply, err := readMessage(a.stateConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state response: %w", err)
+	}
+	if reply.RequestID != msg.RequestID {
+		return nil, fmt.Errorf("unexpected state response %q for request %q", reply.RequestID, msg.RequestID)
+	}
+
+	var resp StateResponsePayload
+	if err := json.Unmarshal(reply.Payload, &resp); err != nil {
+		return nil, fmt.Errorf("invalid state response: %w", err)
+	}
+	return &resp, nil
+}
+
+// stateUnavailable 创建宿主机状态服务不可达时的响应
+func stateUnavailable(err error) *StateResponsePayload {
+	return &StateResponsePayload{
+		Success:   false,
+		Error:     fmt.Sprintf("state backend unavailable: %v", err),
+		ErrorCode: "backend_unavailable",
+	}
+}
@@ -0,0 +1,180 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// fakeStateHost 模拟宿主机状态服务，在内存中实现 get/set/incr 和带版本的读写
+type fakeStateHost struct {
+	values   map[string]json.RawMessage
+	versions map[string]int64
+	requests []StatePayload
+}
+
+func (h *fakeStateHost) handle(p *StatePayload) *StateResponsePayload {
+	h.requests = append(h.requests, *p)
+	key := p.Scope + ":" + p.Key
+	switch p.Operation {
+	case "get":
+		return &StateResponsePayload{Success: true, Value: h.values[key]}
+	case "get_with_version":
+		return &StateResponsePayload{Success: true, Value: h.values[key], Version: h.versions[key]}
+	case "set":
+		h.values[key] = p.Value
+		return &StateResponsePayload{Success: true}
+	case "set_with_version":
+		if p.Version > 0 && h.versions[key] != p.Version {
+			return &StateResponsePayload{Error: "version conflict", ErrorCode: "operation_error", Version: h.versions[key]}
+		}
+		h.values[key] = p.Value
+		h.versions[key]++
+		return &StateResponsePayload{Success: true, Version: h.versions[key]}
+	case "incr":
+		var n int64
+		json.Unmarshal(h.values[key], &n)
+		n += p.Delta
+		h.values[key] = json.RawMessage(strconv.FormatInt(n, 10))
+		return &StateResponsePayload{Success: true, Value: h.values[key]}
+	}
+	return &StateResponsePayload{Error: "unsupported", ErrorCode: "operation_error"}
+}
+
+// serve 在连接上逐条处理 MessageTypeState 请求
+func (h *fakeStateHost) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		var p StatePayload
+		json.Unmarshal(msg.Payload, &p)
+		data, _ := json.Marshal(h.handle(&p))
+		if err := writeMessage(conn, &Message{Type: MessageTypeResp, RequestID: msg.RequestID, Payload: data}); err != nil {
+			return
+		}
+	}
+}
+
+// postState 向状态 HTTP API 发送一次请求
+func postState(t *testing.T, url string, payload map[string]interface{}) *StateResponsePayload {
+	t.Helper()
+	body, _ := json.Marshal(payload)
+	resp, err := http.Post(url+"/state", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /state error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /state status = %d, want 200", resp.StatusCode)
+	}
+	var result StateResponsePayload
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode response error = %v", err)
+	}
+	return &result
+}
+
+func TestStateAPIRoundTrip(t *testing.T) {
+	host := &fakeStateHost{values: map[string]json.RawMessage{}, versions: map[string]int64{}}
+	dials := 0
+	agent := &Agent{
+		initialized: true,
+		config:      &InitPayload{FunctionID: "fn-1", SessionKey: "sess-1", StateEnabled: true},
+		stateDial: func() (net.Conn, error) {
+			dials++
+			hostConn, guestConn := net.Pipe()
+			go host.serve(hostConn)
+			return guestConn, nil
+		},
+	}
+	srv := httptest.NewServer(agent.stateAPIHandler())
+	defer srv.Close()
+
+	// set → get
+	if r := postState(t, srv.URL, map[string]interface{}{"operation": "set", "scope": "session", "key": "user", "value": `{"name":"alice"}`}); !r.Success {
+		t.Fatalf("set failed: %+v", r)
+	}
+	r := postState(t, srv.URL, map[string]interface{}{"operation": "get", "scope": "session", "key": "user"})
+	var stored string
+	if err := json.Unmarshal(r.Value, &stored); err != nil || stored != `{"name":"alice"}` {
+		t.Fatalf("get value = %s, want the stored JSON string", r.Value)
+	}
+
+	// incr
+	for want := 2; want <= 4; want += 2 {
+		r := postState(t, srv.URL, map[string]interface{}{"operation": "incr", "scope": "function", "key": "count", "delta": 2})
+		if !r.Success || string(r.Value) != strconv.Itoa(want) {
+			t.Fatalf("incr = %+v, want value %d", r, want)
+		}
+	}
+
+	// 乐观锁：按读到的版本写入成功，使用过期版本写入返回冲突和当前版本
+	r = postState(t, srv.URL, map[string]interface{}{"operation": "set_with_version", "scope": "session", "key": "cart", "value": `[1]`})
+	if !r.Success || r.Version != 1 {
+		t.Fatalf("first set_with_version = %+v, want version 1", r)
+	}
+	r = postState(t, srv.URL, map[string]interface{}{"operation": "get_with_version", "scope": "session", "key": "cart"})
+	if r.Version != 1 {
+		t.Fatalf("get_with_version version = %d, want 1", r.Version)
+	}
+	r = postState(t, srv.URL, map[string]interface{}{"operation": "set_with_version", "scope": "session", "key": "cart", "value": `[1,2]`, "version": 1})
+	if !r.Success || r.Version != 2 {
+		t.Fatalf("set_with_version with current version = %+v, want version 2", r)
+	}
+	r = postState(t, srv.URL, map[string]interface{}{"operation": "set_with_version", "scope": "session", "key": "cart", "value": `[3]`, "version": 1})
+	if r.Success || r.Error != "version conflict" || r.Version != 2 {
+		t.Fatalf("set_with_version with stale version = %+v, want conflict at version 2", r)
+	}
+
+	if dials != 1 {
+		t.Errorf("dialed host %d times, want a single reused connection", dials)
+	}
+	for _, req := range host.requests {
+		if req.FunctionID != "fn-1" || req.SessionKey != "sess-1" {
+			t.Fatalf("forwarded request %+v missing function id or session key", req)
+		}
+	}
+}
+
+func TestStateAPIRejectsInvalidRequests(t *testing.T) {
+	agent := &Agent{
+		initialized: true,
+		config:      &InitPayload{FunctionID: "fn-1", StateEnabled: true},
+		stateDial: func() (net.Conn, error) {
+			return nil, errors.New("connection refused")
+		},
+	}
+	srv := httptest.NewServer(agent.stateAPIHandler())
+	defer srv.Close()
+
+	r := postState(t, srv.URL, map[string]interface{}{"operation": "drop", "scope": "session", "key": "k"})
+	if r.Success || r.ErrorCode != "operation_error" {
+		t.Errorf("unknown operation = %+v, want operation_error", r)
+	}
+
+	// 宿主机不可达时返回 backend_unavailable，函数代码据此抛出 StateBackendUnavailable
+	r = postState(t, srv.URL, map[string]interface{}{"operation": "get", "scope": "session", "key": "k"})
+	if r.Success || r.ErrorCode != "backend_unavailable" {
+		t.Errorf("unreachable host = %+v, want backend_unavailable", r)
+	}
+
+	resp, err := http.Get(srv.URL + "/state")
+	if err != nil {
+		t.Fatalf("GET /state error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /state status = %d, want 405", resp.StatusCode)
+	}
+}
@@ -0,0 +1,130 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// 标准错误输出收集相关常量
+const (
+	// DefaultMaxStderrBytes 是单次执行返回给宿主机的标准错误输出的默认最大字节数（64KB）
+	DefaultMaxStderrBytes = 64 * 1024
+	// EnvMaxStderrBytes 是覆盖标准错误输出上限的 Agent 环境变量（字节数）
+	EnvMaxStderrBytes = "NIMBUS_MAX_STDERR_BYTES"
+)
+
+// MaxStderrBytes 是 ResponsePayload.Stderr 的最大字节数，超出部分被截断
+var MaxStderrBytes = DefaultMaxStderrBytes
+
+// stderrTruncatedSuffix 追加在被截断的标准错误输出末尾
+const stderrTruncatedSuffix = "\n...[stderr truncated]"
+
+// stderrCapture 收集一次执行中子进程的标准错误输出，超过上限的部分被丢弃
+type stderrCapture struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// stderrCaptureKey 是 stderrCapture 在上下文中的键
+type stderrCaptureKey struct{}
+
+// withStderrCapture 返回携带标准错误收集器的上下文
+// 运行时通过 runCommand/streamCommand 执行子进程时，标准错误输出会写入该收集器
+func withStderrCapture(ctx context.Context) (context.Context, *stderrCapture) {
+	c := &stderrCapture{limit: MaxStderrBytes}
+	return context.WithValue(ctx, stderrCaptureKey{}, c), c
+}
+
+// recordStderr 将子进程的标准错误输出写入上下文中的收集器（如果有）
+func recordStderr(ctx context.Context, data []byte) {
+	if c, ok := ctx.Value(stderrCaptureKey{}).(*stderrCapture); ok {
+		c.Write(data)
+	}
+}
+
+// stderrCaptureFrom 返回上下文中的标准错误收集器，没有时返回 io.Discard
+func stderrCaptureFrom(ctx context.Context) io.Writer {
+	if c, ok := ctx.Value(stderrCaptureKey{}).(*stderrCapture); ok {
+		return c
+	}
+	return io.Discard
+}
+
+// Write 追加标准错误输出，超过上限的部分被丢弃，总是返回 len(p)
+func (c *stderrCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if remaining := c.limit - c.buf.Len(); len(p) > remaining {
+		if remaining > 0 {
+			c.buf.Write(p[:remaining])
+		}
+		c.truncated = true
+	} else {
+		c.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// String 返回收集到的标准错误输出，被截断时带有截断标记
+func (c *stderrCapture) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.truncated {
+		return c.buf.String() + stderrTruncatedSuffix
+	}
+	return c.buf.String()
+}
+
+// runCommand 运行子进程并返回其标准输出
+// 标准错误输出无论成败都会记录到上下文中的收集器；上下文携带日志转发器时，
+// 标准错误按行实时转发，标准输出在子进程结束后拆分出函数打印的日志行；
+// 子进程非零退出时错误信息包含完整的标准错误输出（如 "python error: ..."）
+// 标准输出最多保留 MaxOutputBytes 字节，超过时返回 ErrOutputTooLarge
+// 上下文取消（执行超时）时终止子进程所在的整个进程组
+//
+// 参数:
+//   - ctx: 执行上下文，可携带标准错误收集器
+//   - cmd: 尚未启动的命令
+//   - errPrefix: 子进程失败时的错误前缀
+//
+// 返回:
+//   - []byte: 标准输出
+//   - error: 执行错误
+func runCommand(ctx context.Context, cmd *exec.Cmd, errPrefix string) ([]byte, error) {
+	setProcessGroup(cmd)
+	var stderr bytes.Buffer
+	stdout := newOutputBuffer()
+	cmd.Stdout = stdout
+	cmd.Stderr = teeLogs(ctx, &stderr, "stderr")
+
+	err := cmd.Run()
+	recordStderr(ctx, stderr.Bytes())
+	output, outputErr := stdout.Bytes()
+	logs := logForwarderFrom(ctx)
+	if err != nil {
+		// 失败时没有结果，标准输出全部作为日志转发
+		if logs != nil {
+			logs.writer("stdout").Write(output)
+		}
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%s: %s", errPrefix, stderr.String())
+		}
+		return nil, err
+	}
+	if outputErr != nil {
+		return nil, outputErr
+	}
+	if logs != nil {
+		return logs.splitStdout(output), nil
+	}
+	return output, nil
+}
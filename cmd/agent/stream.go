@@ -0,0 +1,166 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// streamChunkSize 是流式输出时单个分片的最大字节数
+const streamChunkSize = 32 * 1024
+
+// ChunkPayload 定义流式输出分片的载荷结构
+type ChunkPayload struct {
+	Seq  int    `json:"seq"`  // 分片序号，从 0 开始递增
+	Data []byte `json:"data"` // 分片内容（JSON 中为 base64 编码）
+}
+
+// StreamingRuntime 是支持流式输出的运行时可选实现的接口
+// 未实现此接口的运行时在流式请求中先完整执行，再将输出作为单个分片发送
+type StreamingRuntime interface {
+	// ExecuteStream 执行函数，每产生一段输出就调用 emit
+	// emit 返回错误时应停止执行并返回该错误
+	ExecuteStream(ctx context.Context, input json.RawMessage, emit func([]byte) error) error
+}
+
+// executeStream 以流式方式执行函数，将输出按序号分片发送给宿主机
+//
+// 参数:
+//   - ctx: 上下文，用于超时控制
+//   - rt: 执行函数的运行时
+//   - requestID: 执行请求 ID，分片消息使用相同的请求 ID
+//   - input: JSON 格式的输入参数
+//   - send: 发送分片消息
+//
+// 返回:
+//   - int: 已发送的分片数
+//   - error: 执行错误或发送错误
+func executeStream(ctx context.Context, rt Runtime, requestID string, input json.RawMessage, send func(*Message) error) (int, error) {
+	seq := 0
+	emit := func(data []byte) error {
+		payload, _ := json.Marshal(&ChunkPayload{Seq: seq, Data: data})
+		if err := send(&Message{Type: MessageTypeRespChunk, RequestID: requestID, Payload: payload}); err != nil {
+			return fmt.Errorf("failed to send output chunk: %w", err)
+		}
+		seq++
+		return nil
+	}
+
+	if sr, ok := rt.(StreamingRuntime); ok {
+		err := sr.ExecuteStream(ctx, input, emit)
+		return seq, err
+	}
+
+	output, err := rt.Execute(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+	if len(output) > 0 {
+		if err := emit(output); err != nil {
+			return seq, err
+		}
+	}
+	return seq, nil
+}
+
+// streamCommand 运行子进程并将标准输出按读取到的片段依次交给 emit
+// 标准输出即函数的流式结果，上下文携带日志转发器时只转发标准错误
+// 子进程非零退出时错误信息包含其标准错误输出，错误前缀与非流式执行一致
+// 上下文取消或发送失败时终止子进程所在的整个进程组
+//
+// 参数:
+//   - ctx: 执行上下文，可携带标准错误收集器
+//   - cmd: 尚未启动的命令
+//   - errPrefix: 子进程失败时的错误前缀（如 "python error"）
+//   - emit: 输出片段回调
+//
+// 返回:
+//   - error: 启动、执行或发送错误
+func streamCommand(ctx context.Context, cmd *exec.Cmd, errPrefix string, emit func([]byte) error) error {
+	setProcessGroup(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = teeLogs(ctx, &stderr, "stderr")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	buf := make([]byte, streamChunkSize)
+	var emitErr error
+	for {
+		n, readErr := stdout.Read(buf)
+		if n > 0 && emitErr == nil {
+			// 分片内容需要复制，buf 会被下一次读取覆盖
+			emitErr = emit(append([]byte(nil), buf[:n]...))
+			if emitErr != nil {
+				killProcessGroup(cmd)
+			}
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				emitErr = readErr
+			}
+			break
+		}
+	}
+
+	waitErr := cmd.Wait()
+	recordStderr(ctx, stderr.Bytes())
+	if emitErr != nil {
+		return emitErr
+	}
+	if waitErr != nil {
+		if _, ok := waitErr.(*exec.ExitError); ok {
+			return fmt.Errorf("%s: %s", errPrefix, stderr.String())
+		}
+		return waitErr
+	}
+	return nil
+}
+
+// ExecuteStream 流式执行 Python 函数
+// 常驻进程模式只能返回完整结果，此时将结果作为单个分片发送
+func (r *PythonRuntime) ExecuteStream(ctx context.Context, input json.RawMessage, emit func([]byte) error) error {
+	if r.warm {
+		output, err := r.executeWarm(ctx, input)
+		if err == nil {
+			return emit(output)
+		}
+		if err != errPythonWorkerUnavailable {
+			return err
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, r.interpreter(), filepath.Join(FunctionDir, "_wrapper.py"))
+	// 关闭 Python 的标准输出缓冲，使 print 的内容立即到达宿主机
+	cmd.Env = append(append(os.Environ(), r.env...), "PYTHONUNBUFFERED=1")
+	cmd.Stdin = inputReader(ctx, input)
+	return streamCommand(ctx, cmd, "python error", emit)
+}
+
+// ExecuteStream 流式执行 Node.js 函数
+func (r *NodeRuntime) ExecuteStream(ctx context.Context, input json.RawMessage, emit func([]byte) error) error {
+	cmd := exec.CommandContext(ctx, r.interpreter(), filepath.Join(FunctionDir, "_wrapper.js"))
+	cmd.Env = append(os.Environ(), r.env...)
+	cmd.Stdin = inputReader(ctx, input)
+	return streamCommand(ctx, cmd, "node error", emit)
+}
+
+// ExecuteStream 流式执行 Go 函数
+func (r *GoRuntime) ExecuteStream(ctx context.Context, input json.RawMessage, emit func([]byte) error) error {
+	cmd := exec.CommandContext(ctx, filepath.Join(FunctionDir, "handler"))
+	cmd.Env = append(os.Environ(), r.env...)
+	cmd.Stdin = inputReader(ctx, input)
+	return streamCommand(ctx, cmd, "go error", emit)
+}
@@ -0,0 +1,108 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeRuntime 模拟运行时：Execute 返回固定输出，ExecuteStream 由 streamingRuntime 实现
+type fakeRuntime struct{ output string }
+
+func (r *fakeRuntime) Init(*InitPayload) error { return nil }
+
+func (r *fakeRuntime) Execute(context.Context, json.RawMessage) (json.RawMessage, error) {
+	return json.RawMessage(r.output), nil
+}
+
+// streamingRuntime 按顺序输出若干分片，每个分片之间间隔一段时间
+type streamingRuntime struct {
+	fakeRuntime
+	chunks []string
+}
+
+func (r *streamingRuntime) ExecuteStream(ctx context.Context, _ json.RawMessage, emit func([]byte) error) error {
+	for _, c := range r.chunks {
+		time.Sleep(5 * time.Millisecond)
+		if err := emit([]byte(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamExec 通过连接发送流式执行请求，返回收到的分片和最终响应
+func streamExec(t *testing.T, rt Runtime) ([]ChunkPayload, *ResponsePayload) {
+	t.Helper()
+	agent := &Agent{initialized: true, runtime: rt, config: &InitPayload{TimeoutSec: 5}}
+	host, guest := net.Pipe()
+	defer host.Close()
+	go agent.handleConnection(context.Background(), guest)
+
+	payload, _ := json.Marshal(&ExecPayload{Input: json.RawMessage(`{}`), Stream: true})
+	if err := writeMessage(host, &Message{Type: MessageTypeExec, RequestID: "req-1", Payload: payload}); err != nil {
+		t.Fatalf("writeMessage() error = %v", err)
+	}
+
+	var chunks []ChunkPayload
+	for {
+		msg, err := readMessage(host)
+		if err != nil {
+			t.Fatalf("readMessage() error = %v", err)
+		}
+		if msg.RequestID != "req-1" {
+			t.Fatalf("message request id = %q, want req-1", msg.RequestID)
+		}
+		switch msg.Type {
+		case MessageTypeRespChunk:
+			var chunk ChunkPayload
+			if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+				t.Fatalf("invalid chunk: %v", err)
+			}
+			chunks = append(chunks, chunk)
+		case MessageTypeResp:
+			var resp ResponsePayload
+			if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			return chunks, &resp
+		default:
+			t.Fatalf("unexpected message type %d", msg.Type)
+		}
+	}
+}
+
+func TestExecStreamSendsOrderedChunks(t *testing.T) {
+	want := []string{"data: 1\n", "data: 2\n", "data: 3\n"}
+	chunks, resp := streamExec(t, &streamingRuntime{chunks: want})
+
+	if len(chunks) != len(want) {
+		t.Fatalf("received %d chunks, want %d", len(chunks), len(want))
+	}
+	for i, c := range chunks {
+		if c.Seq != i || string(c.Data) != want[i] {
+			t.Errorf("chunk %d = {seq %d, %q}, want {seq %d, %q}", i, c.Seq, c.Data, i, want[i])
+		}
+	}
+	if !resp.Success || resp.Chunks != len(want) || len(resp.Output) != 0 {
+		t.Errorf("final response = %+v, want success with %d chunks and no output", resp, len(want))
+	}
+	if resp.DurationMs < 15 {
+		t.Errorf("final response DurationMs = %d, want >= 15", resp.DurationMs)
+	}
+}
+
+func TestExecStreamFallsBackToSingleChunk(t *testing.T) {
+	chunks, resp := streamExec(t, &fakeRuntime{output: `{"ok":true}`})
+	if len(chunks) != 1 || string(chunks[0].Data) != `{"ok":true}` {
+		t.Fatalf("chunks = %+v, want the whole output in one chunk", chunks)
+	}
+	if !resp.Success || resp.Chunks != 1 {
+		t.Errorf("final response = %+v, want success with 1 chunk", resp)
+	}
+}
@@ -0,0 +1,32 @@
+;; echo_bump 与 echo_dealloc 相同但不导出 dealloc，分配的内存只能通过重新实例化回收。
+;; alloc 为栈式分配，空间不足时按页扩展线性内存；echo_bump.wasm 由本文件编译而来：wat2wasm echo_bump.wat -o echo_bump.wasm
+(module
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; alloc(size: i32) -> i32
+  (func $alloc (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local $end i32)
+    (local.set $ptr (global.get $heap))
+    (local.set $end (i32.add (local.get $ptr) (local.get $size)))
+    (if (i32.gt_u (local.get $end) (i32.mul (memory.size) (i32.const 65536)))
+      (then
+        (drop (memory.grow
+          (i32.add
+            (i32.shr_u (i32.sub (local.get $end) (i32.mul (memory.size) (i32.const 65536))) (i32.const 16))
+            (i32.const 1))))))
+    (global.set $heap (local.get $end))
+    (local.get $ptr)
+  )
+
+  ;; handle(ptr: i32, len: i32) -> i64
+  (func (export "handle") (param $ptr i32) (param $len i32) (result i64)
+    (local $out i32)
+    (local.set $out (call $alloc (local.get $len)))
+    (memory.copy (local.get $out) (local.get $ptr) (local.get $len))
+    (i64.or
+      (i64.shl (i64.extend_i32_u (local.get $out)) (i64.const 32))
+      (i64.extend_i32_u (local.get $len)))
+  )
+)
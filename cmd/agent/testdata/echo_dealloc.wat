@@ -0,0 +1,38 @@
+;; echo_dealloc 原样返回输入的副本，导出 dealloc，宿主每次调用后释放输入和输出。
+;; alloc 为栈式分配，空间不足时按页扩展线性内存；echo_dealloc.wasm 由本文件编译而来：wat2wasm echo_dealloc.wat -o echo_dealloc.wasm
+(module
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; alloc(size: i32) -> i32
+  (func $alloc (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local $end i32)
+    (local.set $ptr (global.get $heap))
+    (local.set $end (i32.add (local.get $ptr) (local.get $size)))
+    (if (i32.gt_u (local.get $end) (i32.mul (memory.size) (i32.const 65536)))
+      (then
+        (drop (memory.grow
+          (i32.add
+            (i32.shr_u (i32.sub (local.get $end) (i32.mul (memory.size) (i32.const 65536))) (i32.const 16))
+            (i32.const 1))))))
+    (global.set $heap (local.get $end))
+    (local.get $ptr)
+  )
+
+  ;; dealloc(ptr: i32, size: i32)，只能释放最后分配的区域
+  (func (export "dealloc") (param $ptr i32) (param $size i32)
+    (if (i32.eq (i32.add (local.get $ptr) (local.get $size)) (global.get $heap))
+      (then (global.set $heap (local.get $ptr))))
+  )
+
+  ;; handle(ptr: i32, len: i32) -> i64
+  (func (export "handle") (param $ptr i32) (param $len i32) (result i64)
+    (local $out i32)
+    (local.set $out (call $alloc (local.get $len)))
+    (memory.copy (local.get $out) (local.get $ptr) (local.get $len))
+    (i64.or
+      (i64.shl (i64.extend_i32_u (local.get $out)) (i64.const 32))
+      (i64.extend_i32_u (local.get $len)))
+  )
+)
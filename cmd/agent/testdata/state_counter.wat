@@ -0,0 +1,35 @@
+;; state_counter 通过 nimbus_state 宿主模块递增函数作用域的计数器并返回新值。
+;; 每次调用执行 state_incr("counter", 1) 后用 state_get 读取计数器，输出其 JSON 值；
+;; 任一宿主函数返回非 0 状态时输出 {"error":"state unavailable"}。
+;; state_counter.wasm 由本文件编译而来：wat2wasm state_counter.wat -o state_counter.wasm
+(module
+  ;; state_incr(scope, key_ptr, key_len, delta, out_ptr) -> status
+  (import "nimbus_state" "state_incr" (func $state_incr (param i32 i32 i32 i64 i32) (result i32)))
+  ;; state_get(scope, key_ptr, key_len, out_ptr) -> status
+  (import "nimbus_state" "state_get" (func $state_get (param i32 i32 i32 i32) (result i32)))
+
+  (memory (export "memory") 1)
+  (global $heap_ptr (mut i32) (i32.const 1024))
+
+  (data (i32.const 0) "counter")
+  (data (i32.const 8) "{\"error\":\"state unavailable\"}")
+
+  ;; alloc(size: i32) -> i32
+  (func $alloc (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $heap_ptr))
+    (global.set $heap_ptr (i32.add (global.get $heap_ptr) (local.get $size)))
+    (local.get $ptr)
+  )
+
+  ;; handle(ptr: i32, len: i32) -> i64
+  (func (export "handle") (param $ptr i32) (param $len i32) (result i64)
+    ;; scope 1 = function，结果写入偏移 64 处的 8 字节缓冲区
+    (if (call $state_incr (i32.const 1) (i32.const 0) (i32.const 7) (i64.const 1) (i32.const 64))
+      (then (return (i64.const 0x80000001d))))
+    (if (call $state_get (i32.const 1) (i32.const 0) (i32.const 7) (i32.const 64))
+      (then (return (i64.const 0x80000001d))))
+    ;; state_get 写入的 (ptr << 32) | len 与 handle 返回值的编码相同
+    (i64.load (i32.const 64))
+  )
+)
@@ -0,0 +1,145 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultWarmupTimeoutSec 是初始化载荷未指定超时时预热函数的超时时间（秒）
+const DefaultWarmupTimeoutSec = 60
+
+// ErrWarmupTimeout 表示预热函数未在超时时间内返回
+var ErrWarmupTimeout = errors.New("warmup timed out")
+
+// warmer 是支持预热函数的运行时实现的可选接口
+type warmer interface {
+	// Warmup 调用预热函数（入口点格式见 resolveWarmup）并等待其返回，ctx 取消时终止预热
+	Warmup(ctx context.Context, handler string) error
+}
+
+// warmupTarget 是预热函数所在的模块和函数名
+type warmupTarget struct {
+	module string
+	fn     string
+}
+
+// resolveWarmup 解析预热函数入口点，格式同处理函数（模块.函数）；
+// 只写函数名时使用处理函数所在的模块
+//
+// 参数:
+//   - handler: 处理函数入口点，如 handler.main
+//   - warmup: 预热函数入口点，如 model.load 或 warmup
+//
+// 返回:
+//   - warmupTarget: 预热函数的模块和函数名
+func resolveWarmup(handler, warmup string) warmupTarget {
+	if i := strings.LastIndex(warmup, "."); i >= 0 {
+		return warmupTarget{module: warmup[:i], fn: warmup[i+1:]}
+	}
+	module := "handler"
+	if i := strings.LastIndex(handler, "."); i >= 0 {
+		module = handler[:i]
+	}
+	return warmupTarget{module: module, fn: warmup}
+}
+
+// warmupTimeout 返回初始化载荷的预热超时时间
+func warmupTimeout(payload *InitPayload) time.Duration {
+	if payload.WarmupTimeoutSec > 0 {
+		return time.Duration(payload.WarmupTimeoutSec) * time.Second
+	}
+	return DefaultWarmupTimeoutSec * time.Second
+}
+
+// runWarmup 调用运行时的预热函数并等待其返回
+//
+// 参数:
+//   - rt: 已初始化的运行时
+//   - handler: 预热函数入口点，为空时不预热
+//   - timeout: 预热超时时间
+//
+// 返回:
+//   - time.Duration: 预热耗时
+//   - error: 运行时不支持预热、预热函数失败，或超时（包装 ErrWarmupTimeout）
+func runWarmup(rt Runtime, handler string, timeout time.Duration) (time.Duration, error) {
+	if handler == "" {
+		return 0, nil
+	}
+	w, ok := rt.(warmer)
+	if !ok {
+		return 0, fmt.Errorf("warmup handler is not supported by %T", rt)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	err := w.Warmup(ctx, handler)
+	elapsed := time.Since(start)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return elapsed, fmt.Errorf("%w after %s", ErrWarmupTimeout, timeout)
+		}
+		return elapsed, fmt.Errorf("warmup failed: %w", err)
+	}
+	fmt.Printf("Warmup %s completed in %s\n", handler, elapsed)
+	return elapsed, nil
+}
+
+// pythonWarmupScript 在独立进程中导入模块并调用预热函数
+const pythonWarmupScript = `
+import sys
+sys.path.insert(0, '%s')
+getattr(__import__('%s'), '%s')()
+`
+
+// Warmup 调用 Python 预热函数。
+// 常驻进程模式下在常驻进程中调用，预热加载的内容（如模型）留在进程中供后续调用使用，
+// 进程重启后会重新预热；逐次启动模式下在独立进程中调用，适合下载文件等落盘的准备工作。
+func (r *PythonRuntime) Warmup(ctx context.Context, handler string) error {
+	target := resolveWarmup(r.handler, handler)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.worker != nil && r.worker.alive() {
+		if err := r.worker.warmup(ctx, target); err != nil {
+			return err
+		}
+		r.rewarm = &target
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, r.interpreter(), "-c", fmt.Sprintf(pythonWarmupScript, FunctionDir, target.module, target.fn))
+	cmd.Env = append(os.Environ(), r.env...)
+	_, err := runCommand(ctx, cmd, "python warmup error")
+	return err
+}
+
+// nodeWarmupScript 在独立进程中加载模块并等待（可能是异步的）预热函数完成
+const nodeWarmupScript = `
+const path = require('path');
+(async () => {
+    try {
+        await require(path.join('%s', '%s.js'))['%s']();
+    } catch (err) {
+        console.error(err.stack || err.message);
+        process.exit(1);
+    }
+})();
+`
+
+// Warmup 在独立进程中调用 Node.js 预热函数
+// 每次调用都启动新进程，预热适合下载文件等落盘的准备工作
+func (r *NodeRuntime) Warmup(ctx context.Context, handler string) error {
+	target := resolveWarmup(r.handler, handler)
+	cmd := exec.CommandContext(ctx, r.interpreter(), "-e", fmt.Sprintf(nodeWarmupScript, FunctionDir, target.module, target.fn))
+	cmd.Env = append(os.Environ(), r.env...)
+	_, err := runCommand(ctx, cmd, "node warmup error")
+	return err
+}
@@ -0,0 +1,125 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// slowWarmupRuntime 的预热函数耗时 delay，ctx 取消时提前返回
+type slowWarmupRuntime struct {
+	delay   time.Duration
+	handler string
+}
+
+func (r *slowWarmupRuntime) Init(*InitPayload) error { return nil }
+
+func (r *slowWarmupRuntime) Execute(context.Context, json.RawMessage) (json.RawMessage, error) {
+	return nil, nil
+}
+
+func (r *slowWarmupRuntime) Warmup(ctx context.Context, handler string) error {
+	r.handler = handler
+	select {
+	case <-time.After(r.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRunWarmupCompletes(t *testing.T) {
+	rt := &slowWarmupRuntime{delay: 50 * time.Millisecond}
+	elapsed, err := runWarmup(rt, "warmup", time.Second)
+	if err != nil {
+		t.Fatalf("runWarmup() error = %v", err)
+	}
+	if rt.handler != "warmup" || elapsed < rt.delay {
+		t.Errorf("runWarmup() handler = %q elapsed = %v, want warmup to run for at least %v", rt.handler, elapsed, rt.delay)
+	}
+}
+
+func TestRunWarmupTimesOut(t *testing.T) {
+	rt := &slowWarmupRuntime{delay: 5 * time.Second}
+	start := time.Now()
+	_, err := runWarmup(rt, "warmup", 50*time.Millisecond)
+	if !errors.Is(err, ErrWarmupTimeout) {
+		t.Fatalf("runWarmup() error = %v, want ErrWarmupTimeout", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("runWarmup() did not stop at the timeout")
+	}
+}
+
+func TestRunWarmupSkipsAndRejects(t *testing.T) {
+	// 未声明预热函数时不调用
+	if _, err := runWarmup(&RubyRuntime{}, "", time.Second); err != nil {
+		t.Errorf("runWarmup() without handler error = %v", err)
+	}
+	// 不支持预热的运行时声明了预热函数时初始化失败
+	if _, err := runWarmup(&RubyRuntime{}, "warmup", time.Second); err == nil {
+		t.Errorf("runWarmup() on ruby succeeded, want unsupported error")
+	}
+}
+
+func TestResolveWarmup(t *testing.T) {
+	tests := []struct {
+		handler, warmup string
+		want            warmupTarget
+	}{
+		{"handler.main", "warmup", warmupTarget{"handler", "warmup"}},
+		{"app.main", "model.load", warmupTarget{"model", "load"}},
+		{"main", "warmup", warmupTarget{"handler", "warmup"}},
+	}
+	for _, tt := range tests {
+		if got := resolveWarmup(tt.handler, tt.warmup); got != tt.want {
+			t.Errorf("resolveWarmup(%q, %q) = %+v, want %+v", tt.handler, tt.warmup, got, tt.want)
+		}
+	}
+}
+
+// TestPythonWarmupInWorker 验证常驻进程模式下预热在常驻进程中执行，加载的内容对后续调用可见，
+// 预热超时时报告 ErrWarmupTimeout
+func TestPythonWarmupInWorker(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not installed")
+	}
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+	payload := &InitPayload{
+		Handler: "handler.handler",
+		Code: "import time\n\nmodel = None\n\n" +
+			"def warmup():\n    global model\n    model = 'loaded'\n\n" +
+			"def slow_warmup():\n    time.sleep(5)\n\n" +
+			"def handler(event):\n    return {'model': model}\n",
+		Runtime: "python3.11",
+	}
+	if err := (&Agent{}).writeCode(payload); err != nil {
+		t.Skipf("cannot write function code: %v", err)
+	}
+	rt := &PythonRuntime{warm: true}
+	if err := rt.Init(payload); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	t.Cleanup(func() { rt.Close() })
+
+	if _, err := runWarmup(rt, "warmup", 10*time.Second); err != nil {
+		t.Fatalf("runWarmup() error = %v", err)
+	}
+	output, err := rt.Execute(context.Background(), json.RawMessage(`{}`))
+	if err != nil || string(output) != `{"model": "loaded"}` {
+		t.Errorf("Execute() after warmup = %s, %v, want model loaded", output, err)
+	}
+
+	if _, err := runWarmup(rt, "slow_warmup", 200*time.Millisecond); !errors.Is(err, ErrWarmupTimeout) {
+		t.Errorf("runWarmup(slow_warmup) error = %v, want ErrWarmupTimeout", err)
+	}
+}
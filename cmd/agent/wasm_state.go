@@ -0,0 +1,147 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// wasmStateModuleName 是 WASM 函数导入状态宿主函数时使用的模块名
+//
+// 宿主函数与 Python/Node.js 的 nimbus 模块一样，将状态操作作为 MessageTypeState 消息转发给宿主机。
+// 调用约定：指针和长度都是模块线性内存中的 i32 偏移量和字节数，键为 UTF-8 字节，值为 JSON 字节，
+// 返回值为 i32 状态码（见 wasmState* 常量），结果写入调用方提供的 8 字节缓冲区 out_ptr：
+//
+//   - state_get(scope, key_ptr, key_len, out_ptr) -> status
+//     值通过模块导出的 alloc 分配，在 out_ptr 写入小端 u64 (ptr << 32) | len，编码与 handle 的返回值相同；
+//     键不存在时写入 0
+//   - state_set(scope, key_ptr, key_len, value_ptr, value_len, ttl) -> status
+//     写入 JSON 值，ttl 为过期秒数，0 表示不过期
+//   - state_incr(scope, key_ptr, key_len, delta: i64, out_ptr) -> status
+//     原子地增加计数器，在 out_ptr 写入小端 i64 新值
+//
+// scope 为 0 表示会话作用域，1 表示函数作用域。
+const wasmStateModuleName = "nimbus_state"
+
+// 状态宿主函数的返回码
+const (
+	wasmStateOK             int32 = 0  // 操作成功
+	wasmStateErrOperation   int32 = -1 // 操作失败：作用域无效、状态功能未启用或宿主机返回错误
+	wasmStateErrUnavailable int32 = -2 // 宿主机状态后端不可用
+	wasmStateErrMemory      int32 = -3 // 读写模块内存失败或模块未导出 alloc
+)
+
+// wasmStateScopes 是 scope 参数到状态作用域的映射
+var wasmStateScopes = map[uint32]string{0: "session", 1: "function"}
+
+// wasmStateForwarder 将状态操作发送给宿主机，Agent.forwardState 满足该签名
+type wasmStateForwarder func(ctx context.Context, payload *StatePayload) *StateResponsePayload
+
+// instantiateWasmStateModule 在 wazero 运行时中注册 nimbus_state 宿主模块
+// 未启用状态功能时 forward 为 nil，模块仍然注册以便导入它的函数可以实例化，调用时返回 wasmStateErrOperation。
+//
+// 参数:
+//   - ctx: 上下文
+//   - rt: wazero 运行时，需在实例化函数模块之前调用
+//   - forward: 状态操作转发函数
+//
+// 返回:
+//   - error: 模块注册错误
+func instantiateWasmStateModule(ctx context.Context, rt wazero.Runtime, forward wasmStateForwarder) error {
+	_, err := rt.NewHostModuleBuilder(wasmStateModuleName).
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, scope, keyPtr, keyLen, outPtr uint32) int32 {
+			resp, status := callWasmState(ctx, m, forward, &StatePayload{Operation: "get"}, scope, keyPtr, keyLen)
+			if status != wasmStateOK {
+				return status
+			}
+			var packed uint64
+			if len(resp.Value) > 0 && string(resp.Value) != "null" {
+				ptr, ok := writeWasmBytes(ctx, m, resp.Value)
+				if !ok {
+					return wasmStateErrMemory
+				}
+				packed = uint64(ptr)<<32 | uint64(len(resp.Value))
+			}
+			if !m.Memory().WriteUint64Le(outPtr, packed) {
+				return wasmStateErrMemory
+			}
+			return wasmStateOK
+		}).
+		Export("state_get").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, scope, keyPtr, keyLen, valuePtr, valueLen, ttl uint32) int32 {
+			value, ok := m.Memory().Read(valuePtr, valueLen)
+			if !ok || !json.Valid(value) {
+				return wasmStateErrOperation
+			}
+			payload := &StatePayload{Operation: "set", Value: append(json.RawMessage(nil), value...), TTL: int(ttl)}
+			_, status := callWasmState(ctx, m, forward, payload, scope, keyPtr, keyLen)
+			return status
+		}).
+		Export("state_set").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, scope, keyPtr, keyLen uint32, delta int64, outPtr uint32) int32 {
+			resp, status := callWasmState(ctx, m, forward, &StatePayload{Operation: "incr", Delta: delta}, scope, keyPtr, keyLen)
+			if status != wasmStateOK {
+				return status
+			}
+			var value int64
+			if err := json.Unmarshal(resp.Value, &value); err != nil {
+				return wasmStateErrOperation
+			}
+			if !m.Memory().WriteUint64Le(outPtr, uint64(value)) {
+				return wasmStateErrMemory
+			}
+			return wasmStateOK
+		}).
+		Export("state_incr").
+		Instantiate(ctx)
+	return err
+}
+
+// callWasmState 从模块内存读取键，补全作用域后转发状态操作并将结果映射为返回码
+func callWasmState(ctx context.Context, m api.Module, forward wasmStateForwarder, payload *StatePayload, scope, keyPtr, keyLen uint32) (*StateResponsePayload, int32) {
+	if forward == nil {
+		return nil, wasmStateErrOperation
+	}
+	scopeName, ok := wasmStateScopes[scope]
+	if !ok {
+		return nil, wasmStateErrOperation
+	}
+	key, ok := m.Memory().Read(keyPtr, keyLen)
+	if !ok {
+		return nil, wasmStateErrMemory
+	}
+	payload.Scope = scopeName
+	payload.Key = string(key)
+
+	resp := forward(ctx, payload)
+	switch {
+	case resp.Success:
+		return resp, wasmStateOK
+	case resp.ErrorCode == "backend_unavailable":
+		return resp, wasmStateErrUnavailable
+	default:
+		return resp, wasmStateErrOperation
+	}
+}
+
+// writeWasmBytes 通过模块导出的 alloc 分配内存并写入 data，返回写入的指针
+func writeWasmBytes(ctx context.Context, m api.Module, data []byte) (uint32, bool) {
+	alloc := m.ExportedFunction("alloc")
+	if alloc == nil {
+		return 0, false
+	}
+	results, err := alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, false
+	}
+	ptr := uint32(results[0])
+	return ptr, m.Memory().Write(ptr, data)
+}
@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestWasmStateHostFunctions(t *testing.T) {
+	host := &fakeStateHost{values: map[string]json.RawMessage{}, versions: map[string]int64{}}
+	agent := &Agent{
+		initialized: true,
+		config:      &InitPayload{FunctionID: "fn-1", SessionKey: "sess-1", StateEnabled: true},
+		stateDial: func() (net.Conn, error) {
+			hostConn, guestConn := net.Pipe()
+			go host.serve(hostConn)
+			return guestConn, nil
+		},
+	}
+	rt := initTestWasm(t, "state_counter.wasm", agent.forwardState)
+
+	for want := 1; want <= 3; want++ {
+		output, err := rt.Execute(context.Background(), json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if string(output) != strconv.Itoa(want) {
+			t.Fatalf("Execute() = %s, want counter %d", output, want)
+		}
+	}
+
+	// 每次调用依次转发 incr 和 get，使用函数作用域和初始化时的函数 ID
+	if len(host.requests) != 6 {
+		t.Fatalf("forwarded %d state requests, want 6", len(host.requests))
+	}
+	for i, req := range host.requests {
+		wantOp := "incr"
+		if i%2 == 1 {
+			wantOp = "get"
+		}
+		if req.Operation != wantOp || req.Scope != "function" || req.Key != "counter" || req.FunctionID != "fn-1" {
+			t.Errorf("request %d = %+v, want %s of function-scoped counter", i, req, wantOp)
+		}
+	}
+}
+
+func TestWasmStateHostFunctionsWithoutState(t *testing.T) {
+	// 未启用状态功能时模块仍能实例化，宿主函数返回错误码
+	rt := initTestWasm(t, "state_counter.wasm", nil)
+	output, err := rt.Execute(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if string(output) != `{"error":"state unavailable"}` {
+		t.Errorf("Execute() = %s, want the fixture's error output", output)
+	}
+}
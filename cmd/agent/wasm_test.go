@@ -0,0 +1,88 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initTestWasm 将 testdata 中的 WASM 模块写入函数目录并初始化 WASM 运行时
+func initTestWasm(t *testing.T, fixture string, state wasmStateForwarder) *WasmRuntime {
+	t.Helper()
+	if err := os.MkdirAll(FunctionDir, 0755); err != nil {
+		t.Skipf("function dir %s is not writable: %v", FunctionDir, err)
+	}
+	wasm, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(FunctionDir, "handler.wasm"), wasm, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(filepath.Join(FunctionDir, "handler.wasm")) })
+
+	rt := &WasmRuntime{state: state}
+	if err := rt.Init(&InitPayload{Runtime: "wasm"}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	t.Cleanup(func() { rt.runtime.Close(context.Background()) })
+	return rt
+}
+
+func TestWasmRepeatedInvocationsKeepMemoryBounded(t *testing.T) {
+	const invocations = 5000
+	// 每次调用分配约 8KB（输入和输出各 4KB），不释放时 5000 次调用需要约 40MB 线性内存
+	input := json.RawMessage(`"` + strings.Repeat("x", 4094) + `"`)
+
+	t.Run("dealloc", func(t *testing.T) {
+		rt := initTestWasm(t, "echo_dealloc.wasm", nil)
+		instance := rt.instance
+		pages := instance.Memory().Size()
+		for i := 0; i < invocations; i++ {
+			output, err := rt.Execute(context.Background(), input)
+			if err != nil {
+				t.Fatalf("invocation %d: Execute() error = %v", i, err)
+			}
+			if !bytes.Equal(output, input) {
+				t.Fatalf("invocation %d: Execute() returned %d bytes, want the echoed input", i, len(output))
+			}
+		}
+		if rt.instance != instance {
+			t.Error("instance was re-created although the module exports dealloc")
+		}
+		if got := rt.instance.Memory().Size(); got != pages {
+			t.Errorf("memory size after %d invocations = %d bytes, want %d", invocations, got, pages)
+		}
+	})
+
+	t.Run("no dealloc", func(t *testing.T) {
+		rt := initTestWasm(t, "echo_bump.wasm", nil)
+		pages := rt.instance.Memory().Size()
+		for i := 0; i < invocations; i++ {
+			output, err := rt.Execute(context.Background(), input)
+			if err != nil {
+				t.Fatalf("invocation %d: Execute() error = %v", i, err)
+			}
+			if !bytes.Equal(output, input) {
+				t.Fatalf("invocation %d: Execute() returned %d bytes, want the echoed input", i, len(output))
+			}
+			// 无法释放内存的模块在调用后丢弃实例，下一次调用使用新实例
+			if rt.instance != nil {
+				t.Fatalf("invocation %d: instance kept although the module cannot free memory", i)
+			}
+		}
+		if err := rt.instantiate(context.Background()); err != nil {
+			t.Fatalf("instantiate() error = %v", err)
+		}
+		if got := rt.instance.Memory().Size(); got != pages {
+			t.Errorf("memory size of a fresh instance = %d bytes, want %d", got, pages)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"time"
+
+	"github.com/oriys/nimbus/internal/scheduler"
+	"github.com/oriys/nimbus/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// startLeaderElection 启动主节点选举，避免多节点部署中单例任务在每个节点上重复执行：
+// 死信重试、保留策略清理、金丝雀发布评估和停滞调用对账只在成为主节点期间运行；CronManager 在所有节点运行（定时预热针对本节点），
+// 但只有主节点触发定时调用
+func startLeaderElection(store *storage.PostgresStore, interval time.Duration, cronMgr *scheduler.CronManager, dlqRetry *scheduler.RetryWorker, retentionMgr *scheduler.RetentionManager, canaryCtrl *scheduler.CanaryController, reconciler *scheduler.InvocationReconciler, logger *logrus.Logger) *scheduler.LeaderElector {
+	elector := scheduler.NewLeaderElector(store.DB(), scheduler.LeaderLockName, interval, logger)
+	elector.OnLeadership(func() {
+		dlqRetry.Start()
+		retentionMgr.Start()
+		canaryCtrl.Start()
+		reconciler.Start()
+	}, func() {
+		dlqRetry.Stop()
+		retentionMgr.Stop()
+		canaryCtrl.Stop()
+		reconciler.Stop()
+	})
+	cronMgr.SetLeaderCheck(elector.IsLeader)
+	elector.Start()
+	return elector
+}
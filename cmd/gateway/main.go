@@ -12,12 +12,14 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"syscall"
 	"time"
 
 	"github.com/oriys/nimbus/internal/api"
 	"github.com/oriys/nimbus/internal/config"
 	"github.com/oriys/nimbus/internal/docker"
+	"github.com/oriys/nimbus/internal/events"
 	"github.com/oriys/nimbus/internal/firecracker"
 	"github.com/oriys/nimbus/internal/metrics"
 	"github.com/oriys/nimbus/internal/scheduler"
@@ -74,6 +76,9 @@ func main() {
 		if err != nil {
 			// 遥测初始化失败不影响主服务运行，仅记录警告
 			logger.WithError(err).Warn("Failed to initialize telemetry, continuing without tracing")
+		} else if !tel.IsEnabled() {
+			// 未配置 OTLP 端点时追踪为空操作
+			logger.Info("Telemetry endpoint not configured, tracing disabled")
 		} else {
 			// 确保在服务关闭时正确清理遥测资源
 			defer tel.Shutdown(context.Background())
@@ -93,6 +98,15 @@ func main() {
 		logger.WithError(err).Fatal("Failed to connect to PostgreSQL")
 	}
 	defer pgStore.Close()
+	verifySchema(pgStore, cfg.Storage.Postgres.SchemaCheck, logger)
+
+	// 初始化层内容存储
+	// 层内容按哈希保存在 PostgreSQL 的 layer_blobs 表或 S3 兼容对象存储中
+	layerBlobs, err := storage.NewLayerBlobStore(cfg.Storage.LayerBlobs, pgStore.DB())
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize layer blob store")
+	}
+	pgStore.SetLayerBlobStore(layerBlobs)
 
 	// 初始化 Redis 存储
 	// Redis 用于缓存、会话管理和分布式锁等场景
@@ -151,11 +165,31 @@ func main() {
 	// 根据配置选择使用 Docker 模式或 Firecracker 模式
 	var sched api.Scheduler
 	var dockerMgr *docker.Manager
+	// 虚拟机池统计来源，仅 Firecracker 模式下存在，用于控制台系统状态
+	var poolStats func() []api.PoolStats
+	// 虚拟机运行指标来源，仅 Firecracker 模式下存在
+	var vmMetrics func() []api.VMMetrics
+	// 关闭时排空虚拟机，仅 Firecracker 模式下存在
+	var drainVMs func(context.Context) error
+	// 有状态函数的状态处理器，未启用状态功能时为 nil
+	// 健康检查在 Redis 不可用后持续探测，恢复时清除降级标记并重放缓冲的写操作
+	stateHandler, stateRedis := newStateHandler(cfg, logger)
+	// 会话路由器，仅在 Firecracker 模式下启用状态功能时存在
+	var sessionRouter *scheduler.SessionRouter
+	if stateHandler != nil {
+		defer stateRedis.Close()
+		stateCtx, stateCancel := context.WithCancel(context.Background())
+		defer stateCancel()
+		stateHandler.Start(stateCtx)
+	}
 
 	if cfg.Runtime.Mode == "docker" {
 		// Docker 模式 - 设置更简单，不需要 KVM 支持
 		// 适用于开发环境和不支持 KVM 的平台
 		dockerMgr = docker.NewManager(cfg.Docker, m, logger)
+		if m != nil {
+			m.SetWarmVMsSource(dockerMgr.WarmContainers)
+		}
 		sched = scheduler.NewDockerScheduler(cfg.Scheduler, pgStore, redisStore, dockerMgr, m, logger)
 		logger.Info("Using Docker runtime mode")
 	} else {
@@ -172,6 +206,7 @@ func main() {
 		// 初始化 Firecracker 虚拟机管理器
 		machinesMgr := firecracker.NewMachineManager(cfg.Firecracker, networkMgr, logger)
 		defer machinesMgr.Shutdown(context.Background())
+		drainVMs = machinesMgr.DrainAndShutdown
 
 		// 初始化虚拟机池
 		// 预热的虚拟机池可以显著降低函数冷启动时间
@@ -180,9 +215,36 @@ func main() {
 			logger.WithError(err).Fatal("Failed to start VM pool")
 		}
 		defer pool.Stop()
+		// 回收空闲过久或复用次数达到上限的虚拟机，保留每个运行时的最小预热数
+		machinesMgr.StartReaper(pool.MinWarm())
+		poolStats = vmPoolStats(pool)
+
+		// 启用状态功能时在宿主机上接收 Agent 转发的状态操作
+		if stateHandler != nil {
+			stateServer := firecracker.NewStateServer(stateHandler, machinesMgr, logger)
+			if err := stateServer.Start(); err != nil {
+				logger.WithError(err).Fatal("Failed to start state service")
+			}
+			defer stateServer.Close()
+			sessionRouter = scheduler.NewSessionRouter(stateRedis, pool, logger)
+		}
+		vmMetrics = firecrackerVMMetrics(machinesMgr)
+		if m != nil {
+			m.SetWarmVMsSource(vmWarmCounts(pool))
+		}
 
 		// 创建基于 Firecracker 的调度器
-		sched = scheduler.NewScheduler(cfg.Scheduler, pgStore, redisStore, pool, m, logger)
+		fcSched := scheduler.NewScheduler(cfg.Scheduler, pgStore, redisStore, pool, m, logger)
+		// 超过阈值的函数输入上传到对象存储，Agent 按引用下载
+		if cfg.Storage.InputRefs.ThresholdBytes > 0 {
+			inputs, err := storage.NewS3InputStore(cfg.Storage.InputRefs.S3, cfg.Storage.InputRefs.URLExpiry)
+			if err != nil {
+				logger.WithError(err).Warn("Object storage unavailable, function inputs are always sent inline")
+			} else {
+				fcSched.SetInputStore(inputs, cfg.Storage.InputRefs.ThresholdBytes)
+			}
+		}
+		sched = fcSched
 		logger.Info("Using Firecracker runtime mode")
 	}
 
@@ -201,11 +263,48 @@ func main() {
 	// 初始化定时任务管理器
 	// CronManager 负责处理函数的定时触发
 	cronMgr := scheduler.NewCronManager(pgStore, sched.InvokeAsync, logger)
+	// 调度器支持预热时，由 CronManager 执行函数的 on_deploy/scheduled 预热策略
+	if prewarmer, ok := sched.(scheduler.Prewarmer); ok {
+		cronMgr.SetPrewarmer(prewarmer)
+	}
 	if err := cronMgr.Start(); err != nil {
 		logger.WithError(err).Error("Failed to start cron manager")
 	}
 	defer cronMgr.Stop()
 
+	// 初始化金丝雀发布控制器
+	// 周期性对比别名上金丝雀版本与稳定版本的指标，自动放量或回滚
+	canaryCtrl := scheduler.NewCanaryController(pgStore, cfg.Scheduler.CanaryInterval, logger)
+
+	// 初始化停滞调用对账任务
+	// 将宿主崩溃后停留在 pending/running 的调用标记为失败，保持统计和计费准确
+	reconciler := scheduler.NewInvocationReconciler(pgStore, cfg.Scheduler.StaleInvocationThreshold, cfg.Scheduler.StaleInvocationInterval, m, logger)
+
+	// 初始化死信自动重试任务
+	// 按指数退避用原始载荷重新调用函数，退避时间和最大次数在系统设置中配置
+	dlqRetry := scheduler.NewRetryWorker(pgStore, sched.Invoke, cfg.Scheduler.DLQRetryInterval, logger)
+
+	// 初始化保留策略管理器
+	// 按系统设置中的保留天数和清理间隔自动清理过期数据，也可通过管理接口立即执行
+	retentionMgr := scheduler.NewRetentionManager(pgStore, cfg.Scheduler.RetentionCheckInterval, logger)
+
+	// 初始化主节点选举
+	// 定时触发、死信重试、保留策略清理、金丝雀发布评估和停滞调用对账只在持有数据库咨询锁的节点上执行
+	elector := startLeaderElection(pgStore, cfg.Scheduler.LeaderElectionInterval, cronMgr, dlqRetry, retentionMgr, canaryCtrl, reconciler, logger)
+	defer elector.Stop()
+
+	// 初始化 Kafka 事件触发器
+	// 消费配置的主题，每条消息同步调用映射的函数，失败的消息写入死信队列后再提交偏移量
+	if cfg.Events.Kafka.Enabled {
+		kafkaTrigger, err := events.NewKafkaTrigger(cfg.Events.Kafka, sched.Invoke, pgStore, logger)
+		if err != nil {
+			logger.WithError(err).Error("Failed to create Kafka trigger")
+		} else {
+			kafkaTrigger.Start()
+			defer kafkaTrigger.Stop()
+		}
+	}
+
 	// 初始化工作流引擎
 	var workflowEngine *workflow.Engine
 	var workflowHandler *api.WorkflowHandler
@@ -229,7 +328,8 @@ func main() {
 
 	// 初始化 API 处理器和路由
 	// 处理器包含所有 API 端点的业务逻辑
-	handler := api.NewHandler(pgStore, redisStore, sched, cronMgr, logger)
+	handler := api.NewHandler(pgStore, redisStore, sched, cronMgr, retentionMgr, logger)
+	handler.SetLeaderStatus(elector.IsLeader)
 
 	// 恢复未完成的编译任务
 	// 在服务重启时，检查并重新触发所有处于 creating/updating/building 状态的函数编译
@@ -238,11 +338,24 @@ func main() {
 	// 加载默认函数模板
 	api.SeedDefaultTemplates(pgStore, logger)
 
+	// 函数调用限流，令牌桶保存在 Redis 中，限流参数来自系统设置和函数配置
+	rateLimiter := api.NewRateLimiter(redisStore, pgStore, m, logger)
+
+	// 状态管理接口，控制台系统状态同时展示状态存储后端的健康状况
+	var stateAPI *api.StateHandler
+	if stateHandler != nil {
+		stateAPI = api.NewStateHandler(handler, stateHandler, sessionRouter)
+	}
+
 	router := api.NewRouter(&api.RouterConfig{
 		Handler:         handler,
 		WorkflowHandler: workflowHandler,
+		StateHandler:    stateAPI,
+		PoolStats:       poolStats,
+		VMMetrics:       vmMetrics,
 		Logger:          logger,
 		WebFS:           nil, // 前端静态文件，可通过 embed 嵌入
+		RateLimiter:     rateLimiter,
 	})
 
 	// 如果指标端口与主服务端口不同，单独启动指标服务器
@@ -303,6 +416,13 @@ func main() {
 		logger.WithError(err).Error("Server shutdown error")
 	}
 
+	// 等待虚拟机中仍在执行的调用完成后停止所有虚拟机
+	if drainVMs != nil {
+		if err := drainVMs(ctx); err != nil {
+			logger.WithError(err).Error("VM drain error")
+		}
+	}
+
 	// 清理 Docker 资源（如果使用 Docker 模式）
 	if dockerMgr != nil {
 		if err := dockerMgr.Cleanup(ctx); err != nil {
@@ -324,3 +444,62 @@ func main() {
 
 	logger.Info("Server stopped")
 }
+
+// vmPoolStats 将虚拟机池的各运行时统计转换为控制台系统状态使用的格式，按运行时名称排序
+func vmPoolStats(pool *vmpool.Pool) func() []api.PoolStats {
+	return func() []api.PoolStats {
+		stats := pool.GetStats()
+		result := make([]api.PoolStats, 0, len(stats))
+		for runtime, st := range stats {
+			result = append(result, api.PoolStats{
+				Runtime:  runtime,
+				WarmVMs:  st.WarmVMs,
+				BusyVMs:  st.BusyVMs,
+				TotalVMs: st.TotalVMs,
+				MaxVMs:   st.MaxVMs,
+			})
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Runtime < result[j].Runtime })
+		return result
+	}
+}
+
+// vmWarmCounts 返回各运行时预热虚拟机数量的读取函数，供 warm_vms 指标采集时调用
+func vmWarmCounts(pool *vmpool.Pool) func() map[string]int {
+	return func() map[string]int {
+		stats := pool.GetStats()
+		counts := make(map[string]int, len(stats))
+		for runtime, st := range stats {
+			counts[runtime] = st.WarmVMs
+		}
+		return counts
+	}
+}
+
+// firecrackerVMMetrics 将各虚拟机的 Firecracker 累计指标转换为控制台使用的格式，按虚拟机 ID 排序
+func firecrackerVMMetrics(machinesMgr *firecracker.MachineManager) func() []api.VMMetrics {
+	return func() []api.VMMetrics {
+		metrics := machinesMgr.ListVMMetrics()
+		result := make([]api.VMMetrics, 0, len(metrics))
+		for _, vm := range metrics {
+			result = append(result, api.VMMetrics{
+				VMID:            vm.VMID,
+				Runtime:         vm.Runtime,
+				CPUTimeMs:       vm.CPUTimeMs,
+				VCPUExits:       vm.VCPUExits,
+				BlockReadBytes:  vm.BlockReadBytes,
+				BlockWriteBytes: vm.BlockWriteBytes,
+				BlockReadCount:  vm.BlockReadCount,
+				BlockWriteCount: vm.BlockWriteCount,
+				NetRxBytes:      vm.NetRxBytes,
+				NetTxBytes:      vm.NetTxBytes,
+				NetRxPackets:    vm.NetRxPackets,
+				NetTxPackets:    vm.NetTxPackets,
+				ThrottledEvents: vm.ThrottledEvents,
+				UpdatedAt:       vm.UpdatedAt,
+			})
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].VMID < result[j].VMID })
+		return result
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/oriys/nimbus/internal/api"
 	"github.com/oriys/nimbus/internal/config"
 	"github.com/oriys/nimbus/internal/docker"
+	"github.com/oriys/nimbus/internal/events"
 	"github.com/oriys/nimbus/internal/metrics"
 	"github.com/oriys/nimbus/internal/scheduler"
 	"github.com/oriys/nimbus/internal/storage"
@@ -55,6 +56,13 @@ func main() {
 		logger.WithError(err).Fatal("Failed to connect to PostgreSQL")
 	}
 	defer pgStore.Close()
+	verifySchema(pgStore, cfg.Storage.Postgres.SchemaCheck, logger)
+
+	layerBlobs, err := storage.NewLayerBlobStore(cfg.Storage.LayerBlobs, pgStore.DB())
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize layer blob store")
+	}
+	pgStore.SetLayerBlobStore(layerBlobs)
 
 	redisStore, err := storage.NewRedisStore(cfg.Storage.Redis)
 	if err != nil {
@@ -101,6 +109,9 @@ func main() {
 
 	// Docker mode - simpler setup, no KVM required
 	dockerMgr := docker.NewManager(cfg.Docker, m, logger)
+	if m != nil {
+		m.SetWarmVMsSource(dockerMgr.WarmContainers)
+	}
 	sched := scheduler.NewDockerScheduler(cfg.Scheduler, pgStore, redisStore, dockerMgr, m, logger)
 	logger.Info("Using Docker runtime mode")
 
@@ -112,11 +123,39 @@ func main() {
 
 	// Initialize cron manager
 	cronMgr := scheduler.NewCronManager(pgStore, sched.InvokeAsync, logger)
+	cronMgr.SetPrewarmer(sched)
 	if err := cronMgr.Start(); err != nil {
 		logger.WithError(err).Error("Failed to start cron manager")
 	}
 	defer cronMgr.Stop()
 
+	// Initialize canary controller
+	canaryCtrl := scheduler.NewCanaryController(pgStore, cfg.Scheduler.CanaryInterval, logger)
+
+	// Initialize stale invocation reconciler
+	reconciler := scheduler.NewInvocationReconciler(pgStore, cfg.Scheduler.StaleInvocationThreshold, cfg.Scheduler.StaleInvocationInterval, m, logger)
+
+	// Initialize DLQ retry worker
+	dlqRetry := scheduler.NewRetryWorker(pgStore, sched.Invoke, cfg.Scheduler.DLQRetryInterval, logger)
+
+	// Initialize retention manager
+	retentionMgr := scheduler.NewRetentionManager(pgStore, cfg.Scheduler.RetentionCheckInterval, logger)
+
+	// Run cron, DLQ retry, retention, canary evaluation and reconciliation only on the elected leader node
+	elector := startLeaderElection(pgStore, cfg.Scheduler.LeaderElectionInterval, cronMgr, dlqRetry, retentionMgr, canaryCtrl, reconciler, logger)
+	defer elector.Stop()
+
+	// Initialize Kafka event trigger
+	if cfg.Events.Kafka.Enabled {
+		kafkaTrigger, err := events.NewKafkaTrigger(cfg.Events.Kafka, sched.Invoke, pgStore, logger)
+		if err != nil {
+			logger.WithError(err).Error("Failed to create Kafka trigger")
+		} else {
+			kafkaTrigger.Start()
+			defer kafkaTrigger.Stop()
+		}
+	}
+
 	// Initialize workflow engine
 	var workflowEngine *workflow.Engine
 	var workflowHandler *api.WorkflowHandler
@@ -139,7 +178,8 @@ func main() {
 	}
 
 	// Initialize API handler
-	handler := api.NewHandler(pgStore, redisStore, sched, cronMgr, logger)
+	handler := api.NewHandler(pgStore, redisStore, sched, cronMgr, retentionMgr, logger)
+	handler.SetLeaderStatus(elector.IsLeader)
 
 	// 恢复未完成的编译任务
 	handler.RecoverPendingCompileTasks()
@@ -147,11 +187,15 @@ func main() {
 	// 加载默认函数模板
 	api.SeedDefaultTemplates(pgStore, logger)
 
+	// 函数调用限流，令牌桶保存在 Redis 中，限流参数来自系统设置和函数配置
+	rateLimiter := api.NewRateLimiter(redisStore, pgStore, m, logger)
+
 	router := api.NewRouter(&api.RouterConfig{
 		Handler:         handler,
 		WorkflowHandler: workflowHandler,
 		Logger:          logger,
 		WebFS:           nil, // 前端静态文件，可通过 embed 嵌入
+		RateLimiter:     rateLimiter,
 	})
 
 	var metricsServer *http.Server
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/oriys/nimbus/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// schemaCheckTimeout 启动时表结构检查的超时时间
+const schemaCheckTimeout = 10 * time.Second
+
+// verifySchema 按配置检查数据库表结构漂移。
+// mode 为 off 时跳过；warn 时逐条记录差异；fail 时发现差异即终止启动。
+// 查询系统表失败只记录警告，不影响启动。
+func verifySchema(store *storage.PostgresStore, mode string, logger *logrus.Logger) {
+	if mode == "off" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), schemaCheckTimeout)
+	defer cancel()
+
+	issues, err := store.VerifySchema(ctx)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to verify database schema")
+		return
+	}
+	if len(issues) == 0 {
+		logger.Debug("Database schema verified")
+		return
+	}
+
+	for _, issue := range issues {
+		logger.WithField("kind", issue.Kind).Warnf("Schema drift: %s", issue)
+	}
+	if mode == "fail" {
+		logger.WithField("issues", len(issues)).Fatal("Database schema does not match expected layout")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/state"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// newStateHandler 按配置创建有状态函数的状态处理器及其 Redis 客户端，未启用状态功能时均返回 nil。
+// 状态数据使用独立的 Redis DB，与虚拟机池、缓存等数据隔离；Redis 不可用时按配置的降级策略处理写操作。
+func newStateHandler(cfg *config.Config, logger *logrus.Logger) (*state.Handler, *redis.Client) {
+	if !cfg.State.Enabled {
+		return nil, nil
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Storage.Redis.Address,
+		Password: cfg.Storage.Redis.Password,
+		DB:       cfg.State.RedisDB,
+	})
+
+	stateCfg := domain.DefaultStateConfig()
+	stateCfg.Enabled = true
+	if cfg.State.DefaultTTL > 0 {
+		stateCfg.DefaultTTL = cfg.State.DefaultTTL
+	}
+	if cfg.State.MaxStateSize > 0 {
+		stateCfg.MaxStateSize = cfg.State.MaxStateSize
+	}
+	if cfg.State.MaxKeysPerSession > 0 {
+		stateCfg.MaxKeys = cfg.State.MaxKeysPerSession
+	}
+	if cfg.State.SessionTimeout > 0 {
+		stateCfg.SessionTimeout = cfg.State.SessionTimeout
+	}
+	stateCfg.SessionAffinity = cfg.State.SessionAffinityEnabled
+
+	handler := state.NewHandler(client, stateCfg, logger)
+	handler.ConfigureDegradation(state.DegradationConfig{
+		Mode:                state.DegradationMode(cfg.State.DegradationMode),
+		WriteBufferSize:     cfg.State.WriteBufferSize,
+		HealthCheckInterval: cfg.State.HealthCheckInterval,
+	})
+	return handler, client
+}
@@ -0,0 +1,4 @@
+package main
+
+// 嵌入 IANA 时区数据库，函数的 cron_timezone 在不含 tzdata 的镜像（如 alpine）中同样可用
+import _ "time/tzdata"
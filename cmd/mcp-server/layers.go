@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/oriys/nimbus/internal/gatewayclient"
+)
+
+// ============================================================================
+// 层列表工具
+// ============================================================================
+
+// newToolLayerList 创建层列表工具定义
+func newToolLayerList() mcp.Tool {
+	return mcp.NewTool(
+		"layer_list",
+		mcp.WithDescription("列出共享依赖层及其兼容运行时和最新版本号（支持 offset/limit 分页）"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithNumber("offset", mcp.Description("分页偏移，从 0 开始"), mcp.Min(0), mcp.MultipleOf(1), mcp.DefaultNumber(0)),
+		mcp.WithNumber("limit", mcp.Description("分页大小，1-100"), mcp.Min(1), mcp.Max(100), mcp.MultipleOf(1), mcp.DefaultNumber(20)),
+	)
+}
+
+// handleLayerList 返回层列表工具的处理函数
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleLayerList(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		resp, err := client.ListLayers(ctx, request.GetInt("offset", 0), request.GetInt("limit", 20))
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("list layers failed", err), nil
+		}
+		out, err := mcp.NewToolResultJSON(resp)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
+
+// ============================================================================
+// 层创建工具
+// ============================================================================
+
+// newToolLayerCreate 创建层创建工具定义
+// 层不存在时先创建层，再把内容发布为新版本；层已存在时只发布新版本
+func newToolLayerCreate() mcp.Tool {
+	return mcp.NewTool(
+		"layer_create",
+		mcp.WithDescription("创建共享依赖层并上传 ZIP 内容作为新版本；同名层已存在时为其发布新版本（兼容运行时保持不变）"),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(false), // 每次调用都会发布新版本
+		mcp.WithString("name", mcp.Description("层名称"), mcp.Required(), mcp.MinLength(1), mcp.MaxLength(128)),
+		mcp.WithString("description", mcp.Description("层描述（可选，仅新建层时使用）")),
+		mcp.WithArray("compatible_runtimes",
+			mcp.Description("兼容的运行时列表，如 [\"python3.11\"]，新建层时必填"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("content_base64", mcp.Description("层内容：ZIP 压缩包的 base64 编码"), mcp.Required(), mcp.MinLength(1)),
+	)
+}
+
+// layerCreateResult 层创建结果，用于 MCP 响应
+type layerCreateResult struct {
+	Layer   *gatewayclient.Layer        `json:"layer"`   // 层信息（latest_version 已更新为新版本）
+	Version *gatewayclient.LayerVersion `json:"version"` // 新发布的版本
+	Created bool                        `json:"created"` // 本次调用是否新建了层
+}
+
+// handleLayerCreate 返回层创建工具的处理函数
+// 内容必须是合法的 ZIP 压缩包，避免发布运行时无法解压的版本
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleLayerCreate(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing name", err), nil
+		}
+		name = strings.TrimSpace(name)
+		encoded, err := request.RequireString("content_base64")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing content_base64", err), nil
+		}
+		content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("content_base64 is not valid base64", err), nil
+		}
+		if _, err := zip.NewReader(bytes.NewReader(content), int64(len(content))); err != nil {
+			return mcp.NewToolResultErrorFromErr("content is not a valid zip archive", err), nil
+		}
+
+		result := &layerCreateResult{}
+		if detail, err := client.GetLayer(ctx, name); err == nil {
+			result.Layer = &detail.Layer
+		} else {
+			runtimes := request.GetStringSlice("compatible_runtimes", nil)
+			if len(runtimes) == 0 {
+				return mcp.NewToolResultError("compatible_runtimes is required when creating a new layer"), nil
+			}
+			layer, err := client.CreateLayer(ctx, &gatewayclient.CreateLayerRequest{
+				Name:               name,
+				Description:        request.GetString("description", ""),
+				CompatibleRuntimes: runtimes,
+			})
+			if err != nil {
+				return mcp.NewToolResultErrorFromErr("create layer failed", err), nil
+			}
+			result.Layer = layer
+			result.Created = true
+		}
+
+		version, err := client.CreateLayerVersion(ctx, result.Layer.ID, content)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("create layer version failed", err), nil
+		}
+		result.Layer.LatestVersion = version.Version
+		result.Version = version
+
+		out, err := mcp.NewToolResultJSON(result)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
+
+// ============================================================================
+// 函数挂载层工具
+// ============================================================================
+
+// newToolFunctionAttachLayers 创建函数挂载层工具定义
+// 按给定顺序整体替换函数使用的层，挂载前检查每个层都兼容函数的运行时
+func newToolFunctionAttachLayers() mcp.Tool {
+	return mcp.NewTool(
+		"function_attach_layers",
+		mcp.WithDescription("设置函数（id 或 name）使用的层，按列表顺序加载并整体替换原有的层；传空列表移除全部层。每个层都必须兼容函数的运行时"),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true), // 重复设置同一列表结果相同
+		mcp.WithString("id_or_name", mcp.Description("函数 ID 或函数名"), mcp.Required()),
+		mcp.WithArray("layers",
+			mcp.Description("按加载顺序排列的层列表，每项为 {\"layer\": 层 ID 或名称, \"version\": 版本号（可选，默认最新版本）}"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"layer":   map[string]any{"type": "string", "description": "层 ID 或名称"},
+					"version": map[string]any{"type": "integer", "minimum": 1, "description": "层版本号，默认最新版本"},
+				},
+				"required": []string{"layer"},
+			}),
+			mcp.Required(),
+		),
+	)
+}
+
+// attachLayersResult 函数挂载层结果，用于 MCP 响应
+type attachLayersResult struct {
+	Function string                        `json:"function"` // 函数名
+	Runtime  string                        `json:"runtime"`  // 函数运行时
+	Layers   []gatewayclient.FunctionLayer `json:"layers"`   // 挂载后的层，按加载顺序排列
+}
+
+// handleFunctionAttachLayers 返回函数挂载层工具的处理函数
+// 网关不校验运行时兼容性和版本是否存在，因此在提交前逐个解析并检查层，任一层不满足时不做任何修改
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleFunctionAttachLayers(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		idOrName, err := request.RequireString("id_or_name")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing id_or_name", err), nil
+		}
+		items, ok := request.GetArguments()["layers"].([]any)
+		if !ok {
+			return mcp.NewToolResultError("layers must be an array"), nil
+		}
+
+		fn, err := client.GetFunction(ctx, idOrName)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("get function failed", err), nil
+		}
+
+		layers := make([]gatewayclient.FunctionLayer, 0, len(items))
+		seen := make(map[string]bool, len(items))
+		for i, item := range items {
+			fl, err := resolveFunctionLayer(ctx, client, item, fn.Runtime)
+			if err != nil {
+				return mcp.NewToolResultErrorFromErr(fmt.Sprintf("invalid layers[%d]", i), err), nil
+			}
+			if seen[fl.LayerID] {
+				return mcp.NewToolResultError(fmt.Sprintf("invalid layers[%d]: layer %s is listed more than once", i, fl.LayerName)), nil
+			}
+			seen[fl.LayerID] = true
+			layers = append(layers, *fl)
+		}
+
+		attached, err := client.SetFunctionLayers(ctx, fn.ID, layers)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("attach layers failed", err), nil
+		}
+		out, err := mcp.NewToolResultJSON(&attachLayersResult{Function: fn.Name, Runtime: fn.Runtime, Layers: attached})
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
+
+// resolveFunctionLayer 解析 layers 列表中的一项，检查层兼容函数运行时且指定的版本存在
+//
+// 参数:
+//   - ctx: 上下文
+//   - client: 网关客户端
+//   - item: 列表项，形如 {"layer": "...", "version": 2}
+//   - runtime: 函数运行时
+//
+// 返回:
+//   - *gatewayclient.FunctionLayer: 待挂载的层，未指定版本时使用最新版本
+//   - error: 参数无效、层不存在、运行时不兼容或版本不存在
+func resolveFunctionLayer(ctx context.Context, client *gatewayclient.Client, item any, runtime string) (*gatewayclient.FunctionLayer, error) {
+	obj, ok := item.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("must be an object with layer and optional version")
+	}
+	ref, _ := obj["layer"].(string)
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("layer is required")
+	}
+
+	detail, err := client.GetLayer(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("get layer %s: %w", ref, err)
+	}
+	layer := detail.Layer
+	compatible := false
+	for _, rt := range layer.CompatibleRuntimes {
+		if rt == runtime {
+			compatible = true
+			break
+		}
+	}
+	if !compatible {
+		return nil, fmt.Errorf("layer %s is not compatible with runtime %s (compatible runtimes: %s)",
+			layer.Name, runtime, strings.Join(layer.CompatibleRuntimes, ", "))
+	}
+
+	version := layer.LatestVersion
+	if v, ok := obj["version"]; ok && v != nil {
+		n, ok := asInt(v)
+		if !ok || n < 1 {
+			return nil, fmt.Errorf("version must be a positive integer")
+		}
+		version = n
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("layer %s has no versions, upload content with layer_create first", layer.Name)
+	}
+	for _, lv := range detail.Versions {
+		if lv.Version == version {
+			return &gatewayclient.FunctionLayer{LayerID: layer.ID, LayerName: layer.Name, LayerVersion: version, ContentHash: lv.ContentHash}, nil
+		}
+	}
+	return nil, fmt.Errorf("layer %s has no version %d (latest is %d)", layer.Name, version, layer.LatestVersion)
+}
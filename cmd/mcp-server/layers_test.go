@@ -0,0 +1,193 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oriys/nimbus/internal/gatewayclient"
+)
+
+// fakeLayerGateway 在内存中模拟网关的层接口和一个 python3.11 函数 hello
+type fakeLayerGateway struct {
+	mu       sync.Mutex
+	layers   map[string]*gatewayclient.Layer
+	versions map[string][]gatewayclient.LayerVersion
+	attached []gatewayclient.FunctionLayer
+	puts     int
+}
+
+func newFakeLayerGateway() *fakeLayerGateway {
+	return &fakeLayerGateway{
+		layers:   map[string]*gatewayclient.Layer{},
+		versions: map[string][]gatewayclient.LayerVersion{},
+	}
+}
+
+func (g *fakeLayerGateway) lookup(ref string) *gatewayclient.Layer {
+	for _, l := range g.layers {
+		if l.ID == ref || l.Name == ref {
+			return l
+		}
+	}
+	return nil
+}
+
+func (g *fakeLayerGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodGet && path == "/api/v1/functions/hello":
+		json.NewEncoder(w).Encode(gatewayclient.Function{ID: "fn-1", Name: "hello", Runtime: "python3.11"})
+	case r.Method == http.MethodPut && path == "/api/v1/functions/fn-1/layers":
+		g.puts++
+		json.NewDecoder(r.Body).Decode(&g.attached)
+		json.NewEncoder(w).Encode(map[string]any{"layers": g.attached, "total": len(g.attached)})
+	case r.Method == http.MethodPost && path == "/api/v1/layers":
+		var req gatewayclient.CreateLayerRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		layer := &gatewayclient.Layer{ID: "layer-" + req.Name, Name: req.Name, CompatibleRuntimes: req.CompatibleRuntimes}
+		g.layers[layer.ID] = layer
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(layer)
+	case strings.HasPrefix(path, "/api/v1/layers/"):
+		ref := strings.TrimPrefix(path, "/api/v1/layers/")
+		ref, isVersions := strings.CutSuffix(ref, "/versions")
+		layer := g.lookup(ref)
+		if layer == nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"layer not found"}`))
+			return
+		}
+		if !isVersions {
+			json.NewEncoder(w).Encode(map[string]any{"layer": layer, "versions": g.versions[layer.ID]})
+			return
+		}
+		file, _, err := r.FormFile("content")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"content file is required"}`))
+			return
+		}
+		content, _ := io.ReadAll(file)
+		hash := sha256.Sum256(content)
+		layer.LatestVersion++
+		lv := gatewayclient.LayerVersion{LayerID: layer.ID, Version: layer.LatestVersion, ContentHash: hex.EncodeToString(hash[:]), SizeBytes: int64(len(content))}
+		g.versions[layer.ID] = append(g.versions[layer.ID], lv)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(lv)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// testLayerZip 返回包含一个文件的 ZIP 压缩包的 base64 编码
+func testLayerZip(t *testing.T) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("python/helpers.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("def greet(name):\n    return 'hi ' + name\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func callTool(t *testing.T, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Arguments = args
+	result, err := handler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("tool handler error = %v", err)
+	}
+	return result
+}
+
+func toolText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range result.Content {
+		if text, ok := c.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func TestLayerCreateThenAttachToFunction(t *testing.T) {
+	gw := newFakeLayerGateway()
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+	client := gatewayclient.New(srv.URL)
+
+	// 第一次调用新建层并发布版本 1，第二次为已有层发布版本 2
+	for want := 1; want <= 2; want++ {
+		result := callTool(t, handleLayerCreate(client), map[string]any{
+			"name":                "helpers",
+			"compatible_runtimes": []any{"python3.11"},
+			"content_base64":      testLayerZip(t),
+		})
+		if result.IsError {
+			t.Fatalf("layer_create returned error: %s", toolText(result))
+		}
+		var created layerCreateResult
+		if err := json.Unmarshal([]byte(toolText(result)), &created); err != nil {
+			t.Fatalf("layer_create result is not JSON: %v", err)
+		}
+		if created.Version.Version != want || created.Layer.LatestVersion != want || created.Created != (want == 1) {
+			t.Errorf("layer_create #%d = %+v, version %+v", want, created, created.Version)
+		}
+	}
+	callTool(t, handleLayerCreate(client), map[string]any{
+		"name":                "node-utils",
+		"compatible_runtimes": []any{"nodejs20"},
+		"content_base64":      testLayerZip(t),
+	})
+
+	// 不兼容函数运行时的层不会挂载，网关上的层列表保持不变
+	result := callTool(t, handleFunctionAttachLayers(client), map[string]any{
+		"id_or_name": "hello",
+		"layers":     []any{map[string]any{"layer": "helpers"}, map[string]any{"layer": "node-utils"}},
+	})
+	if !result.IsError || !strings.Contains(toolText(result), "not compatible with runtime python3.11") {
+		t.Errorf("attaching incompatible layer = %q, want compatibility error", toolText(result))
+	}
+	if gw.puts != 0 {
+		t.Fatalf("incompatible attach sent %d PUT requests", gw.puts)
+	}
+
+	result = callTool(t, handleFunctionAttachLayers(client), map[string]any{
+		"id_or_name": "hello",
+		"layers":     []any{map[string]any{"layer": "helpers", "version": float64(3)}},
+	})
+	if !result.IsError || !strings.Contains(toolText(result), "no version 3") {
+		t.Errorf("attaching missing version = %q, want version error", toolText(result))
+	}
+
+	result = callTool(t, handleFunctionAttachLayers(client), map[string]any{
+		"id_or_name": "hello",
+		"layers":     []any{map[string]any{"layer": "helpers", "version": float64(1)}},
+	})
+	if result.IsError {
+		t.Fatalf("function_attach_layers returned error: %s", toolText(result))
+	}
+	if len(gw.attached) != 1 || gw.attached[0].LayerID != "layer-helpers" || gw.attached[0].LayerVersion != 1 {
+		t.Errorf("gateway received layers %+v, want helpers@1", gw.attached)
+	}
+}
@@ -1,6 +1,6 @@
 // Package main 是 MCP (Model Context Protocol) 服务器的入口点
 // MCP 服务器允许 AI 模型（如 Claude）通过标准化协议管理函数计算平台
-// 它提供了一组工具，使 AI 能够创建、查询、更新和删除函数
+// 它提供了一组工具，使 AI 能够创建、查询、更新、删除、回滚、调用函数并查看函数日志，以及管理共享依赖层和工作流
 package main
 
 import (
@@ -11,6 +11,7 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -54,7 +55,7 @@ func main() {
 		serverName,
 		serverVersion,
 		server.WithInstructions(fmt.Sprintf(
-			"管理 Nimbus 平台（%s）的函数：创建/列出/查询/更新/删除，并支持通过自然语言描述生成基础函数模板。",
+			"管理 Nimbus 平台（%s）的函数：创建/列出/查询/更新/删除/回滚/调用/查看日志，管理共享依赖层并挂载到函数，创建并运行工作流，并支持通过自然语言描述生成基础函数模板。",
 			*apiURL,
 		)),
 		server.WithToolCapabilities(false), // 禁用工具能力自动发现
@@ -68,6 +69,16 @@ func main() {
 	s.AddTool(newToolFunctionCreateFromDescription(), handleFunctionCreateFromDescription(client)) // 从描述创建函数
 	s.AddTool(newToolFunctionUpdate(), handleFunctionUpdate(client))                       // 更新函数
 	s.AddTool(newToolFunctionDelete(), handleFunctionDelete(client))                       // 删除函数
+	s.AddTool(newToolFunctionRollback(), handleFunctionRollback(client))                   // 回滚函数版本
+	s.AddTool(newToolFunctionInvoke(), handleFunctionInvoke(client))                       // 调用函数
+	s.AddTool(newToolFunctionInvokeBatch(), handleFunctionInvokeBatch(client))             // 批量调用函数
+	s.AddTool(newToolFunctionLogs(), handleFunctionLogs(client))                           // 查询函数日志
+	s.AddTool(newToolLayerList(), handleLayerList(client))                                 // 列出层
+	s.AddTool(newToolLayerCreate(), handleLayerCreate(client))                             // 创建层并上传新版本
+	s.AddTool(newToolFunctionAttachLayers(), handleFunctionAttachLayers(client))           // 设置函数使用的层
+	s.AddTool(newToolWorkflowCreate(), handleWorkflowCreate(client))                       // 创建工作流
+	s.AddTool(newToolWorkflowStart(), handleWorkflowStart(client))                         // 启动工作流执行
+	s.AddTool(newToolWorkflowExecutionGet(), handleWorkflowExecutionGet(client))           // 查询工作流执行
 
 	// 启动 MCP 服务器，通过标准输入输出通信
 	if err := server.ServeStdio(s, server.WithErrorLogger(stderrLogger)); err != nil {
@@ -295,12 +306,13 @@ func newToolFunctionCreate() mcp.Tool {
 		mcp.WithDestructiveHintAnnotation(false),
 		mcp.WithString("name", mcp.Description("函数名，1-64 字符"), mcp.Required(), mcp.MinLength(1), mcp.MaxLength(64)),
 		mcp.WithString("description", mcp.Description("函数描述（可选）")),
-		mcp.WithString("runtime", mcp.Description("运行时"), mcp.Required(), mcp.Enum("python3.11", "nodejs20", "go1.24", "wasm")),
+		mcp.WithString("runtime", mcp.Description("运行时"), mcp.Required(), mcp.Enum("python3.11", "nodejs20", "ruby3.2", "go1.24", "java17", "wasm")),
 		mcp.WithString("handler", mcp.Description("处理器入口，例如 handler.main / handler.handler"), mcp.Required()),
 		mcp.WithString("code", mcp.Description("函数代码内容"), mcp.Required(), mcp.MinLength(1)),
 		mcp.WithNumber("memory_mb", mcp.Description("内存，128-3072"), mcp.Min(128), mcp.Max(3072), mcp.MultipleOf(1)),
 		mcp.WithNumber("timeout_sec", mcp.Description("超时秒数，1-300"), mcp.Min(1), mcp.Max(300), mcp.MultipleOf(1)),
 		mcp.WithObject("env_vars", mcp.Description("环境变量键值对"), mcp.AdditionalProperties(map[string]any{"type": "string"})),
+		mcp.WithBoolean("dry_run", mcp.Description("只预检不创建：返回将要创建的函数定义、校验错误和配额警告（默认 false）")),
 	)
 }
 
@@ -340,8 +352,7 @@ func handleFunctionCreate(client *gatewayclient.Client) server.ToolHandlerFunc {
 			return mcp.NewToolResultErrorFromErr("invalid env_vars", err), nil
 		}
 
-		// 调用网关 API 创建函数
-		fn, err := client.CreateFunction(ctx, &gatewayclient.CreateFunctionRequest{
+		req := &gatewayclient.CreateFunctionRequest{
 			Name:        name,
 			Description: description,
 			Runtime:     runtime,
@@ -350,7 +361,13 @@ func handleFunctionCreate(client *gatewayclient.Client) server.ToolHandlerFunc {
 			MemoryMB:    memoryMB,
 			TimeoutSec:  timeoutSec,
 			EnvVars:     envVars,
-		})
+		}
+		if request.GetBool("dry_run", false) {
+			return validateFunctionResult(ctx, client, req, "")
+		}
+
+		// 调用网关 API 创建函数
+		fn, err := client.CreateFunction(ctx, req)
 		if err != nil {
 			return mcp.NewToolResultErrorFromErr("create function failed", err), nil
 		}
@@ -368,15 +385,15 @@ func handleFunctionCreate(client *gatewayclient.Client) server.ToolHandlerFunc {
 // ============================================================================
 
 // newToolFunctionCreateFromDescription 创建从自然语言描述生成函数的工具定义
-// 支持 python3.11 和 nodejs20 运行时的模板生成
+// 支持 python3.11、nodejs20、ruby3.2 和 go1.24 运行时的模板生成
 func newToolFunctionCreateFromDescription() mcp.Tool {
 	return mcp.NewTool(
 		"function_create_from_description",
-		mcp.WithDescription("通过自然语言描述生成基础函数模板并创建（目前模板仅覆盖 python3.11 / nodejs20）"),
+		mcp.WithDescription("通过自然语言描述生成基础函数模板并创建（目前模板仅覆盖 python3.11 / nodejs20 / ruby3.2 / go1.24，Go 源码由平台编译）"),
 		mcp.WithDestructiveHintAnnotation(false),
 		mcp.WithString("description", mcp.Description("自然语言描述（会写入函数 description，并用于生成示例代码）"), mcp.Required(), mcp.MinLength(1)),
 		mcp.WithString("name", mcp.Description("函数名（可选；不填则自动生成），1-64 字符"), mcp.MinLength(1), mcp.MaxLength(64)),
-		mcp.WithString("runtime", mcp.Description("运行时（可选，默认 python3.11）"), mcp.Enum("python3.11", "nodejs20", "go1.24", "wasm")),
+		mcp.WithString("runtime", mcp.Description("运行时（可选，默认 python3.11）"), mcp.Enum("python3.11", "nodejs20", "ruby3.2", "go1.24", "java17", "wasm")),
 		mcp.WithString("handler", mcp.Description("处理器入口（可选；不填则按运行时给默认值）")),
 		mcp.WithNumber("memory_mb", mcp.Description("内存，128-3072"), mcp.Min(128), mcp.Max(3072), mcp.MultipleOf(1)),
 		mcp.WithNumber("timeout_sec", mcp.Description("超时秒数，1-300"), mcp.Min(1), mcp.Max(300), mcp.MultipleOf(1)),
@@ -436,7 +453,11 @@ func handleFunctionCreateFromDescription(client *gatewayclient.Client) server.To
 			return mcp.NewToolResultErrorFromErr("create function failed", err), nil
 		}
 
-		return mcp.NewToolResultStructured(fn, fmt.Sprintf("created function %s (%s)", fn.Name, fn.ID)), nil
+		text := fmt.Sprintf("created function %s (%s)", fn.Name, fn.ID)
+		if gen.Note != "" {
+			text += "; " + gen.Note
+		}
+		return mcp.NewToolResultStructured(fn, text), nil
 	}
 }
 
@@ -459,6 +480,7 @@ func newToolFunctionUpdate() mcp.Tool {
 		mcp.WithNumber("memory_mb", mcp.Description("内存，128-3072"), mcp.Min(128), mcp.Max(3072), mcp.MultipleOf(1)),
 		mcp.WithNumber("timeout_sec", mcp.Description("超时秒数，1-300"), mcp.Min(1), mcp.Max(300), mcp.MultipleOf(1)),
 		mcp.WithObject("env_vars", mcp.Description("环境变量键值对（可选，会整体覆盖）"), mcp.AdditionalProperties(map[string]any{"type": "string"})),
+		mcp.WithBoolean("dry_run", mcp.Description("只预检不更新：返回更新后的函数定义、校验错误和配额警告（默认 false）")),
 	)
 }
 
@@ -529,6 +551,30 @@ func handleFunctionUpdate(client *gatewayclient.Client) server.ToolHandlerFunc {
 			return mcp.NewToolResultError("no fields to update"), nil
 		}
 
+		// 预检时只发送要修改的字段，网关用原函数的当前值补全其余字段
+		if request.GetBool("dry_run", false) {
+			preview := &gatewayclient.CreateFunctionRequest{}
+			if req.Description != nil {
+				preview.Description = *req.Description
+			}
+			if req.Handler != nil {
+				preview.Handler = *req.Handler
+			}
+			if req.Code != nil {
+				preview.Code = *req.Code
+			}
+			if req.MemoryMB != nil {
+				preview.MemoryMB = *req.MemoryMB
+			}
+			if req.TimeoutSec != nil {
+				preview.TimeoutSec = *req.TimeoutSec
+			}
+			if req.EnvVars != nil {
+				preview.EnvVars = *req.EnvVars
+			}
+			return validateFunctionResult(ctx, client, preview, idOrName)
+		}
+
 		// 调用网关 API 更新函数
 		fn, err := client.UpdateFunction(ctx, idOrName, &req)
 		if err != nil {
@@ -543,6 +589,34 @@ func handleFunctionUpdate(client *gatewayclient.Client) server.ToolHandlerFunc {
 	}
 }
 
+// validateFunctionResult 调用网关预检函数定义并转换为工具结果，existing 非空时按更新该函数预检。
+// 校验失败时不返回工具错误，而是在结构化结果中返回 valid=false 和错误列表，便于调用方修正后重试。
+//
+// 参数:
+//   - ctx: 上下文
+//   - client: 网关客户端
+//   - req: 待预检的函数定义
+//   - existing: 被更新函数的 ID 或函数名，新建时为空
+//
+// 返回:
+//   - *mcp.CallToolResult: 预检结果
+//   - error: 始终为 nil，网关错误以工具错误结果返回
+func validateFunctionResult(ctx context.Context, client *gatewayclient.Client, req *gatewayclient.CreateFunctionRequest, existing string) (*mcp.CallToolResult, error) {
+	result, err := client.ValidateFunction(ctx, req, existing)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("validate function failed", err), nil
+	}
+
+	text := "dry run: function definition is valid, nothing was written"
+	if !result.Valid {
+		text = "dry run: function definition is invalid: " + strings.Join(result.Errors, "; ")
+	}
+	if len(result.Warnings) > 0 {
+		text += "; warnings: " + strings.Join(result.Warnings, "; ")
+	}
+	return mcp.NewToolResultStructured(result, text), nil
+}
+
 // ============================================================================
 // 函数删除工具
 // ============================================================================
@@ -578,6 +652,317 @@ func handleFunctionDelete(client *gatewayclient.Client) server.ToolHandlerFunc {
 	}
 }
 
+// ============================================================================
+// 函数回滚工具
+// ============================================================================
+
+// newToolFunctionRollback 创建函数回滚工具定义
+// 将函数的 handler/code 恢复为历史版本，回滚后函数版本号递增
+func newToolFunctionRollback() mcp.Tool {
+	return mcp.NewTool(
+		"function_rollback",
+		mcp.WithDescription("将函数（id 或 name）回滚到指定历史版本，恢复该版本的 handler/code"),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true), // 重复回滚到同一版本结果相同
+		mcp.WithString("id_or_name", mcp.Description("函数 ID 或函数名"), mcp.Required()),
+		mcp.WithNumber("version", mcp.Description("目标版本号（从 1 开始）"), mcp.Required(), mcp.Min(1), mcp.MultipleOf(1)),
+	)
+}
+
+// handleFunctionRollback 返回函数回滚工具的处理函数
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleFunctionRollback(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		idOrName, err := request.RequireString("id_or_name")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing id_or_name", err), nil
+		}
+		version, err := request.RequireInt("version")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing version", err), nil
+		}
+		if version < 1 {
+			return mcp.NewToolResultError("version must be >= 1"), nil
+		}
+
+		// 网关在版本不存在时返回 version not found
+		fn, err := client.RollbackFunction(ctx, idOrName, version)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("rollback function failed", err), nil
+		}
+		out, err := mcp.NewToolResultJSON(fn)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
+
+// ============================================================================
+// 函数调用工具
+// ============================================================================
+
+// newToolFunctionInvoke 创建函数调用工具定义
+// 同步调用函数并返回输出，便于创建后立即验证函数行为
+func newToolFunctionInvoke() mcp.Tool {
+	return mcp.NewTool(
+		"function_invoke",
+		mcp.WithDescription("同步调用函数（id 或 name），返回函数输出、状态码、耗时和是否冷启动"),
+		mcp.WithReadOnlyHintAnnotation(false),    // 会执行函数代码，可能产生副作用
+		mcp.WithDestructiveHintAnnotation(false), // 非破坏性
+		mcp.WithIdempotentHintAnnotation(false),  // 每次调用都会执行一次函数
+		mcp.WithString("id_or_name", mcp.Description("函数 ID 或函数名"), mcp.Required()),
+		mcp.WithAny("input", mcp.Description("函数输入，任意 JSON 值（对象直接传入，不要序列化为字符串），默认 {}")),
+	)
+}
+
+// invokeResult 函数调用结果，用于 MCP 响应
+type invokeResult struct {
+	RequestID  string          `json:"request_id"`       // 调用请求 ID
+	StatusCode int             `json:"status_code"`      // 函数返回的状态码
+	Output     json.RawMessage `json:"output,omitempty"` // 函数输出
+	Error      string          `json:"error,omitempty"`  // 函数执行错误
+	DurationMs int64           `json:"duration_ms"`      // 执行耗时（毫秒）
+	ColdStart  bool            `json:"cold_start"`       // 是否冷启动
+}
+
+// handleFunctionInvoke 返回调用函数工具的处理函数
+// 函数执行失败（非 2xx 状态码）时仍返回结构化结果，便于查看错误信息
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleFunctionInvoke(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		idOrName, err := request.RequireString("id_or_name")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing id_or_name", err), nil
+		}
+
+		// 未提供输入时使用空对象
+		input := json.RawMessage("{}")
+		if v, ok := request.GetArguments()["input"]; ok && v != nil {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return mcp.NewToolResultErrorFromErr("invalid input", err), nil
+			}
+			input = data
+		}
+
+		resp, err := client.InvokeFunction(ctx, idOrName, input)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("invoke function failed", err), nil
+		}
+
+		out, err := mcp.NewToolResultJSON(&invokeResult{
+			RequestID:  resp.RequestID,
+			StatusCode: resp.StatusCode,
+			Output:     resp.Body,
+			Error:      resp.Error,
+			DurationMs: resp.DurationMs,
+			ColdStart:  resp.ColdStart,
+		})
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
+
+// maxBatchInputs 是批量调用单次允许的最大输入数，与网关的限制一致
+const maxBatchInputs = 100
+
+// newToolFunctionInvokeBatch 创建函数批量调用工具定义
+// 对一组输入各调用一次函数，适合数据处理场景，避免逐个调用的往返开销
+func newToolFunctionInvokeBatch() mcp.Tool {
+	return mcp.NewTool(
+		"function_invoke_batch",
+		mcp.WithDescription("对一组输入批量同步调用函数（id 或 name），网关有限并行执行，按输入顺序返回每次调用的输出或错误；单个输入失败不影响其他输入"),
+		mcp.WithReadOnlyHintAnnotation(false),    // 会执行函数代码，可能产生副作用
+		mcp.WithDestructiveHintAnnotation(false), // 非破坏性
+		mcp.WithIdempotentHintAnnotation(false),  // 每个输入都会执行一次函数
+		mcp.WithString("id_or_name", mcp.Description("函数 ID 或函数名"), mcp.Required()),
+		mcp.WithArray("inputs",
+			mcp.Description("函数输入列表，每个元素为任意 JSON 值（对象直接传入，不要序列化为字符串），1-100 个"),
+			mcp.Items(map[string]any{}),
+			mcp.MinItems(1),
+			mcp.MaxItems(maxBatchInputs),
+			mcp.Required(),
+		),
+	)
+}
+
+// batchInvokeItem 批量调用中单个输入的结果，用于 MCP 响应
+type batchInvokeItem struct {
+	Index int `json:"index"` // 输入下标
+	invokeResult
+}
+
+// batchInvokeResult 批量调用结果，用于 MCP 响应
+type batchInvokeResult struct {
+	Results   []batchInvokeItem `json:"results"`   // 与输入顺序一致的调用结果
+	Succeeded int               `json:"succeeded"` // 成功的调用数
+	Failed    int               `json:"failed"`    // 失败的调用数
+}
+
+// handleFunctionInvokeBatch 返回批量调用函数工具的处理函数
+// 部分输入失败时仍返回结构化结果，失败信息在对应结果中
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleFunctionInvokeBatch(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		idOrName, err := request.RequireString("id_or_name")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing id_or_name", err), nil
+		}
+		values, ok := request.GetArguments()["inputs"].([]any)
+		if !ok || len(values) == 0 {
+			return mcp.NewToolResultError("inputs must be a non-empty array"), nil
+		}
+		if len(values) > maxBatchInputs {
+			return mcp.NewToolResultError(fmt.Sprintf("too many inputs: at most %d per batch", maxBatchInputs)), nil
+		}
+
+		inputs := make([]json.RawMessage, len(values))
+		for i, v := range values {
+			if v == nil {
+				v = map[string]any{}
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return mcp.NewToolResultErrorFromErr(fmt.Sprintf("invalid input %d", i), err), nil
+			}
+			inputs[i] = data
+		}
+
+		resp, err := client.InvokeBatch(ctx, idOrName, inputs)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("invoke function batch failed", err), nil
+		}
+
+		result := &batchInvokeResult{
+			Results:   make([]batchInvokeItem, 0, len(resp.Results)),
+			Succeeded: resp.Succeeded,
+			Failed:    resp.Failed,
+		}
+		for _, item := range resp.Results {
+			result.Results = append(result.Results, batchInvokeItem{
+				Index: item.Index,
+				invokeResult: invokeResult{
+					RequestID:  item.RequestID,
+					StatusCode: item.StatusCode,
+					Output:     item.Body,
+					Error:      item.Error,
+					DurationMs: item.DurationMs,
+					ColdStart:  item.ColdStart,
+				},
+			})
+		}
+		out, err := mcp.NewToolResultJSON(result)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
+
+// ============================================================================
+// 函数日志工具
+// ============================================================================
+
+// maxFunctionLogs 是单次返回的最大日志条数，避免占满模型上下文
+const maxFunctionLogs = 200
+
+// newToolFunctionLogs 创建函数日志查询工具定义
+func newToolFunctionLogs() mcp.Tool {
+	return mcp.NewTool(
+		"function_logs",
+		mcp.WithDescription("查询函数日志（最新在前），用于排查函数调用失败的原因"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("id_or_name", mcp.Description("函数 ID 或函数名"), mcp.Required()),
+		mcp.WithString("level", mcp.Description("日志级别过滤（可选），如 INFO / ERROR")),
+		mcp.WithString("request_id", mcp.Description("只返回指定调用请求 ID 的日志（可选）")),
+		mcp.WithNumber("limit", mcp.Description("返回条数，1-200"), mcp.Min(1), mcp.Max(maxFunctionLogs), mcp.MultipleOf(1), mcp.DefaultNumber(50)),
+	)
+}
+
+// functionLogItem 函数日志项，用于 MCP 响应
+type functionLogItem struct {
+	Timestamp  time.Time `json:"timestamp"`             // 日志时间
+	Level      string    `json:"level"`                 // 日志级别
+	RequestID  string    `json:"request_id,omitempty"`  // 调用请求 ID
+	Message    string    `json:"message"`               // 日志消息
+	Error      string    `json:"error,omitempty"`       // 错误信息
+	DurationMs int64     `json:"duration_ms,omitempty"` // 调用耗时（毫秒）
+}
+
+// functionLogsResult 函数日志响应结构
+type functionLogsResult struct {
+	Logs  []functionLogItem `json:"logs"`  // 日志列表
+	Count int               `json:"count"` // 返回条数
+}
+
+// handleFunctionLogs 返回函数日志查询工具的处理函数
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleFunctionLogs(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		idOrName, err := request.RequireString("id_or_name")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing id_or_name", err), nil
+		}
+
+		// 限制返回条数，避免输出过大
+		limit := request.GetInt("limit", 50)
+		if limit <= 0 || limit > maxFunctionLogs {
+			limit = maxFunctionLogs
+		}
+
+		entries, err := client.GetFunctionLogs(ctx, idOrName, &gatewayclient.LogQuery{
+			Level:     strings.ToUpper(strings.TrimSpace(request.GetString("level", ""))),
+			RequestID: strings.TrimSpace(request.GetString("request_id", "")),
+			Limit:     limit,
+		})
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("get function logs failed", err), nil
+		}
+
+		items := make([]functionLogItem, 0, len(entries))
+		for _, e := range entries {
+			items = append(items, functionLogItem{
+				Timestamp:  e.Timestamp,
+				Level:      e.Level,
+				RequestID:  e.RequestID,
+				Message:    e.Message,
+				Error:      e.Error,
+				DurationMs: e.DurationMs,
+			})
+		}
+		out, err := mcp.NewToolResultJSON(&functionLogsResult{Logs: items, Count: len(items)})
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
+
 // ============================================================================
 // 辅助函数
 // ============================================================================
@@ -691,8 +1076,44 @@ func defaultFunctionName(description string) string {
 type generatedTemplate struct {
 	Handler string // 处理函数入口
 	Code    string // 函数代码
+	Note    string // 附加说明（可选），随创建结果返回给调用方
 }
 
+// goTemplateSource 是 Go 函数模板的源码，%s 处填入描述的 Go 字符串字面量。
+// GoRuntime 以标准输入传入调用输入、读取标准输出作为结果，因此模板是一个完整的 main 包。
+const goTemplateSource = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Handler 处理一次调用，event 为调用输入
+func Handler(event map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{"message": %s, "input": event}, nil
+}
+
+func main() {
+	var event map[string]interface{}
+	if err := json.NewDecoder(os.Stdin).Decode(&event); err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "invalid input: %%v\n", err)
+		os.Exit(1)
+	}
+
+	result, err := Handler(event)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write output: %%v\n", err)
+		os.Exit(1)
+	}
+}
+`
+
 // generateFunctionTemplate 根据运行时和描述生成函数代码模板
 //
 // 参数:
@@ -731,9 +1152,34 @@ func generateFunctionTemplate(runtime, description, handlerOverride string) (*ge
 `, string(descLit))
 		return &generatedTemplate{Handler: handler, Code: code}, nil
 
+	case "ruby3.2":
+		// Ruby 模板，JSON 字符串字面量中的 #{ 需要转义，避免被 Ruby 当作插值
+		handler := handlerOverride
+		if handler == "" {
+			handler = "handler.handler"
+		}
+		rubyLit := strings.ReplaceAll(string(descLit), "#{", `\#{`)
+		code := fmt.Sprintf(`def handler(event)
+  { message: %s, input: event }
+end
+`, rubyLit)
+		return &generatedTemplate{Handler: handler, Code: code}, nil
+
 	case "go1.24":
-		// Go 不支持模板生成，需要预编译二进制
-		return nil, fmt.Errorf("runtime go1.24 template generation is not supported yet; use function_create and pass a base64-encoded linux binary in code (see deployments/docker/runtimes/runtime-go.go)")
+		// Go 模板生成源码，创建函数时由平台识别为源代码并在服务端编译
+		handler := handlerOverride
+		if handler == "" {
+			handler = "main.Handler"
+		}
+		return &generatedTemplate{
+			Handler: handler,
+			Code:    fmt.Sprintf(goTemplateSource, strconv.Quote(description)),
+			Note:    "Go source is compiled by the platform; check function_get until status is active (to build locally: CGO_ENABLED=0 GOOS=linux go build -o handler main.go)",
+		}, nil
+
+	case "java17":
+		// Java 不支持模板生成，需要预构建 JAR
+		return nil, fmt.Errorf("runtime java17 template generation is not supported yet; use function_create and pass a base64-encoded JAR in code (handler like com.example.Handler::handle)")
 
 	case "wasm":
 		// WebAssembly 不支持模板生成，需要预编译 WASM
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oriys/nimbus/internal/gatewayclient"
+)
+
+func TestGenerateGoTemplateCompiles(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found in PATH")
+	}
+
+	// 描述中的引号、反斜杠和格式化动词都必须原样出现在输出中
+	description := "say \"hi\" to C:\\users, 100% done"
+	gen, err := generateFunctionTemplate("go1.24", description, "")
+	if err != nil {
+		t.Fatalf("generateFunctionTemplate(go1.24) error = %v", err)
+	}
+	if gen.Handler != "main.Handler" || gen.Note == "" {
+		t.Errorf("generateFunctionTemplate(go1.24) handler = %q, note = %q", gen.Handler, gen.Note)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(gen.Code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module handler\n\ngo 1.24\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	build := exec.Command(goBin, "build", "-o", "handler", "main.go")
+	build.Dir = dir
+	build.Env = append(os.Environ(), "CGO_ENABLED=0")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("generated source does not compile: %v\n%s\n%s", err, out, gen.Code)
+	}
+
+	// 与 GoRuntime 一致：输入从标准输入读取，结果写到标准输出
+	run := exec.Command(filepath.Join(dir, "handler"))
+	run.Stdin = strings.NewReader(`{"name":"nimbus"}`)
+	out, err := run.Output()
+	if err != nil {
+		t.Fatalf("generated handler failed: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("generated handler output %q is not JSON: %v", out, err)
+	}
+	want := map[string]interface{}{"message": description, "input": map[string]interface{}{"name": "nimbus"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("generated handler output = %v, want %v", got, want)
+	}
+}
+
+func TestFunctionCreateDryRunReportsQuotaWarning(t *testing.T) {
+	var writes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v1/functions/validate" {
+			w.Write([]byte(`{"valid":true,"function":{"name":"hello","runtime":"python3.11","handler":"handler.handler","memory_mb":256,"timeout_sec":30},"warnings":["quota exceeded: max functions (101/100)"]}`))
+			return
+		}
+		writes = append(writes, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	var request mcp.CallToolRequest
+	request.Params.Arguments = map[string]any{
+		"name":    "hello",
+		"runtime": "python3.11",
+		"handler": "handler.handler",
+		"code":    "def handler(event, context):\n    return event\n",
+		"dry_run": true,
+	}
+	result, err := handleFunctionCreate(gatewayclient.New(srv.URL))(context.Background(), request)
+	if err != nil {
+		t.Fatalf("handleFunctionCreate() error = %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("handleFunctionCreate() returned tool error: %+v", result.Content)
+	}
+	if len(writes) != 0 {
+		t.Errorf("dry run sent write requests %v", writes)
+	}
+
+	validation, ok := result.StructuredContent.(*gatewayclient.ValidationResult)
+	if !ok {
+		t.Fatalf("StructuredContent = %T, want *gatewayclient.ValidationResult", result.StructuredContent)
+	}
+	if !validation.Valid || validation.Function == nil || validation.Function.Name != "hello" {
+		t.Errorf("validation = %+v", validation)
+	}
+	if len(validation.Warnings) != 1 || !strings.Contains(validation.Warnings[0], "quota exceeded") {
+		t.Errorf("warnings = %v, want quota exceeded warning", validation.Warnings)
+	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok || !strings.Contains(text.Text, "quota exceeded") {
+		t.Errorf("text content = %+v, want quota warning", result.Content)
+	}
+}
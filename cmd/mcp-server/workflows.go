@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/oriys/nimbus/internal/gatewayclient"
+)
+
+// ============================================================================
+// 工作流创建工具
+// ============================================================================
+
+// newToolWorkflowCreate 创建工作流创建工具定义
+func newToolWorkflowCreate() mcp.Tool {
+	return mcp.NewTool(
+		"workflow_create",
+		mcp.WithDescription("创建工作流。definition 为状态机定义：{\"start_at\": 起始状态名, \"states\": {状态名: {\"type\": \"Task|Choice|Wait|Parallel|Map|Pass|Succeed|Fail\", \"function_id\": Task 调用的函数 ID, \"next\": 下一状态, \"end\": 是否结束}}}，提交前会检查结构"),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(false),
+		mcp.WithString("name", mcp.Description("工作流名称（唯一）"), mcp.Required(), mcp.MinLength(1), mcp.MaxLength(64)),
+		mcp.WithString("description", mcp.Description("工作流描述（可选）")),
+		mcp.WithObject("definition", mcp.Description("工作流定义（JSON 对象，不要序列化为字符串）"), mcp.Required()),
+		mcp.WithNumber("timeout_sec", mcp.Description("整体超时秒数，1-86400，默认 3600"), mcp.Min(1), mcp.Max(86400), mcp.MultipleOf(1)),
+	)
+}
+
+// handleWorkflowCreate 返回工作流创建工具的处理函数
+// 定义结构不合法时 CreateWorkflow 不会请求网关，错误信息指出具体的状态
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleWorkflowCreate(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing name", err), nil
+		}
+
+		// 兼容把定义序列化成字符串传入的调用方
+		var definition json.RawMessage
+		switch v := request.GetArguments()["definition"].(type) {
+		case string:
+			definition = json.RawMessage(v)
+		case map[string]any:
+			definition, err = json.Marshal(v)
+			if err != nil {
+				return mcp.NewToolResultErrorFromErr("invalid definition", err), nil
+			}
+		default:
+			return mcp.NewToolResultError("definition must be a JSON object"), nil
+		}
+
+		wf, err := client.CreateWorkflow(ctx, &gatewayclient.CreateWorkflowRequest{
+			Name:        strings.TrimSpace(name),
+			Description: request.GetString("description", ""),
+			Definition:  definition,
+			TimeoutSec:  request.GetInt("timeout_sec", 0),
+		})
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("create workflow failed", err), nil
+		}
+		out, err := mcp.NewToolResultJSON(wf)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
+
+// ============================================================================
+// 工作流启动工具
+// ============================================================================
+
+// newToolWorkflowStart 创建工作流启动工具定义
+func newToolWorkflowStart() mcp.Tool {
+	return mcp.NewTool(
+		"workflow_start",
+		mcp.WithDescription("启动工作流（id 或 name）的一次执行并立即返回执行 ID；执行在后台推进，用 workflow_execution_get 查询状态和结果"),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(false), // 每次调用都会启动新的执行
+		mcp.WithString("name", mcp.Description("工作流 ID 或名称"), mcp.Required()),
+		mcp.WithAny("input", mcp.Description("执行输入，任意 JSON 值（对象直接传入，不要序列化为字符串），默认 {}")),
+	)
+}
+
+// workflowExecutionResult 工作流执行信息，用于 MCP 响应
+type workflowExecutionResult struct {
+	ExecutionID  string          `json:"execution_id"`            // 执行 ID
+	Workflow     string          `json:"workflow"`                // 工作流名称
+	Status       string          `json:"status"`                  // 执行状态
+	Done         bool            `json:"done"`                    // 执行是否已结束
+	CurrentState string          `json:"current_state,omitempty"` // 当前所在状态
+	Output       json.RawMessage `json:"output,omitempty"`        // 执行输出（成功结束后）
+	Error        string          `json:"error,omitempty"`         // 错误信息（失败时）
+	ErrorCode    string          `json:"error_code,omitempty"`    // 错误代码（失败时）
+}
+
+// toWorkflowExecutionResult 将网关返回的执行转换为 MCP 响应结构
+//
+// 参数:
+//   - exec: 网关返回的执行
+//
+// 返回:
+//   - *workflowExecutionResult: MCP 响应结构
+func toWorkflowExecutionResult(exec *gatewayclient.WorkflowExecution) *workflowExecutionResult {
+	return &workflowExecutionResult{
+		ExecutionID:  exec.ID,
+		Workflow:     exec.WorkflowName,
+		Status:       exec.Status,
+		Done:         exec.IsTerminal(),
+		CurrentState: exec.CurrentState,
+		Output:       exec.Output,
+		Error:        exec.Error,
+		ErrorCode:    exec.ErrorCode,
+	}
+}
+
+// handleWorkflowStart 返回工作流启动工具的处理函数
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleWorkflowStart(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing name", err), nil
+		}
+
+		// 未提供输入时使用空对象
+		input := json.RawMessage(`{}`)
+		if v, ok := request.GetArguments()["input"]; ok && v != nil {
+			input, err = json.Marshal(v)
+			if err != nil {
+				return mcp.NewToolResultErrorFromErr("invalid input", err), nil
+			}
+		}
+
+		exec, err := client.StartWorkflowExecution(ctx, name, input)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("start workflow failed", err), nil
+		}
+		out, err := mcp.NewToolResultJSON(toWorkflowExecutionResult(exec))
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
+
+// ============================================================================
+// 工作流执行查询工具
+// ============================================================================
+
+// newToolWorkflowExecutionGet 创建工作流执行查询工具定义
+func newToolWorkflowExecutionGet() mcp.Tool {
+	return mcp.NewTool(
+		"workflow_execution_get",
+		mcp.WithDescription("查询工作流执行的状态、当前所在状态以及输出或错误；done 为 false 时执行仍在进行，可稍后再次查询"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("execution_id", mcp.Description("workflow_start 返回的执行 ID"), mcp.Required()),
+	)
+}
+
+// handleWorkflowExecutionGet 返回工作流执行查询工具的处理函数
+//
+// 参数:
+//   - client: 网关客户端
+//
+// 返回:
+//   - server.ToolHandlerFunc: 工具处理函数
+func handleWorkflowExecutionGet(client *gatewayclient.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		executionID, err := request.RequireString("execution_id")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("missing execution_id", err), nil
+		}
+
+		exec, err := client.GetWorkflowExecution(ctx, executionID)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr(fmt.Sprintf("get workflow execution %s failed", executionID), err), nil
+		}
+		out, err := mcp.NewToolResultJSON(toWorkflowExecutionResult(exec))
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("encode result failed", err), nil
+		}
+		return out, nil
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/gatewayclient"
+)
+
+// fakeWorkflowGateway 模拟网关的工作流接口：执行在被查询 runningPolls 次后成功结束，输出等于输入
+type fakeWorkflowGateway struct {
+	mu           sync.Mutex
+	runningPolls int
+	workflow     *gatewayclient.CreateWorkflowRequest
+	executions   map[string]*gatewayclient.WorkflowExecution
+	polls        map[string]int
+	requests     int
+}
+
+func (g *fakeWorkflowGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests++
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/workflows":
+		var req gatewayclient.CreateWorkflowRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		g.workflow = &req
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(gatewayclient.Workflow{ID: "wf-1", Name: req.Name, Version: 1, Status: "active", Definition: req.Definition})
+	case r.Method == http.MethodPost && g.workflow != nil && r.URL.Path == "/api/v1/workflows/"+g.workflow.Name+"/executions":
+		var req struct {
+			Input json.RawMessage `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		exec := &gatewayclient.WorkflowExecution{ID: "exec-1", WorkflowID: "wf-1", WorkflowName: g.workflow.Name, Status: "running", Input: req.Input, CurrentState: "Done"}
+		g.executions[exec.ID] = exec
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(exec)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/v1/executions/"):
+		exec, ok := g.executions[strings.TrimPrefix(r.URL.Path, "/api/v1/executions/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"execution not found"}`))
+			return
+		}
+		g.polls[exec.ID]++
+		if g.polls[exec.ID] > g.runningPolls {
+			exec.Status = "succeeded"
+			exec.CurrentState = ""
+			exec.Output = exec.Input
+		}
+		json.NewEncoder(w).Encode(exec)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestWorkflowCreateStartAndPollToCompletion(t *testing.T) {
+	gw := &fakeWorkflowGateway{runningPolls: 2, executions: map[string]*gatewayclient.WorkflowExecution{}, polls: map[string]int{}}
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+	client := gatewayclient.New(srv.URL)
+
+	// 结构不合法的定义不会提交到网关
+	result := callTool(t, handleWorkflowCreate(client), map[string]any{
+		"name":       "passthrough",
+		"definition": map[string]any{"start_at": "Done", "states": map[string]any{"Done": map[string]any{"type": "Pass"}}},
+	})
+	if !result.IsError || !strings.Contains(toolText(result), "states.Done: state requires next or end") {
+		t.Errorf("workflow_create with invalid definition = %q, want next/end error", toolText(result))
+	}
+	if gw.requests != 0 {
+		t.Fatalf("invalid definition sent %d requests to the gateway", gw.requests)
+	}
+
+	result = callTool(t, handleWorkflowCreate(client), map[string]any{
+		"name":       "passthrough",
+		"definition": map[string]any{"start_at": "Done", "states": map[string]any{"Done": map[string]any{"type": "Pass", "end": true}}},
+	})
+	if result.IsError {
+		t.Fatalf("workflow_create returned error: %s", toolText(result))
+	}
+
+	result = callTool(t, handleWorkflowStart(client), map[string]any{"name": "passthrough", "input": map[string]any{"n": 1}})
+	if result.IsError {
+		t.Fatalf("workflow_start returned error: %s", toolText(result))
+	}
+	var exec workflowExecutionResult
+	if err := json.Unmarshal([]byte(toolText(result)), &exec); err != nil {
+		t.Fatalf("workflow_start result is not JSON: %v", err)
+	}
+	if exec.ExecutionID != "exec-1" || exec.Done {
+		t.Fatalf("workflow_start = %+v, want running exec-1", exec)
+	}
+
+	polls := 0
+	for !exec.Done {
+		if polls++; polls > 5 {
+			t.Fatalf("execution still %s after %d polls", exec.Status, polls)
+		}
+		result = callTool(t, handleWorkflowExecutionGet(client), map[string]any{"execution_id": exec.ExecutionID})
+		if result.IsError {
+			t.Fatalf("workflow_execution_get returned error: %s", toolText(result))
+		}
+		exec = workflowExecutionResult{}
+		if err := json.Unmarshal([]byte(toolText(result)), &exec); err != nil {
+			t.Fatalf("workflow_execution_get result is not JSON: %v", err)
+		}
+		if !exec.Done && (exec.Status != "running" || exec.CurrentState != "Done") {
+			t.Errorf("running execution = %+v, want status running at state Done", exec)
+		}
+	}
+	if polls != 3 || exec.Status != "succeeded" || string(exec.Output) != `{"n":1}` {
+		t.Errorf("finished execution after %d polls = %+v, output %s", polls, exec, exec.Output)
+	}
+}
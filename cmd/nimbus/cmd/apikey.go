@@ -4,7 +4,9 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -12,7 +14,7 @@ import (
 var apikeyCmd = &cobra.Command{
 	Use:   "apikey",
 	Short: "Manage API keys",
-	Long:  `Create, list, and delete API keys for accessing the platform.`, // Note: Backticks are correctly used here for multi-line strings.
+	Long:  `Create, list, rotate, and delete API keys for accessing the platform.`, // Note: Backticks are correctly used here for multi-line strings.
 }
 
 var apikeyListCmd = &cobra.Command{
@@ -35,11 +37,23 @@ var apikeyDeleteCmd = &cobra.Command{
 	RunE:  runApiKeyDelete,
 }
 
+var apikeyRotateCmd = &cobra.Command{
+	Use:   "rotate <id>",
+	Short: "Rotate an API key",
+	Long:  `Create a replacement for an API key. The old key keeps working until the grace period ends.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runApiKeyRotate,
+}
+
 func init() {
 	rootCmd.AddCommand(apikeyCmd)
 	apikeyCmd.AddCommand(apikeyListCmd)
 	apikeyCmd.AddCommand(apikeyCreateCmd)
 	apikeyCmd.AddCommand(apikeyDeleteCmd)
+	apikeyCmd.AddCommand(apikeyRotateCmd)
+
+	apikeyCreateCmd.Flags().StringSlice("scope", nil, "Restrict the key to scopes, e.g. functions:read,functions:invoke (default: unrestricted)")
+	apikeyRotateCmd.Flags().Duration("grace", 24*time.Hour, "How long the old key stays valid (0 revokes it immediately)")
 }
 
 func runApiKeyList(cmd *cobra.Command, args []string) error {
@@ -55,18 +69,23 @@ func runApiKeyList(cmd *cobra.Command, args []string) error {
 	}
 
 	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tNAME\tCREATED")
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED")
 	for _, key := range keys {
-		fmt.Fprintf(w, "%s\t%s\t%s\n",
-			key.ID, key.Name, key.CreatedAt.Format("2006-01-02 15:04:05"))
+		scopes := "*"
+		if len(key.Scopes) > 0 {
+			scopes = strings.Join(key.Scopes, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			key.ID, key.Name, scopes, key.CreatedAt.Format("2006-01-02 15:04:05"))
 	}
 	return w.Flush()
 }
 
 func runApiKeyCreate(cmd *cobra.Command, args []string) error {
 	name := args[0]
+	scopes, _ := cmd.Flags().GetStringSlice("scope")
 	client := NewClient()
-	resp, err := client.CreateApiKey(name)
+	resp, err := client.CreateApiKey(name, scopes)
 	if err != nil {
 		return err
 	}
@@ -77,6 +96,25 @@ func runApiKeyCreate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runApiKeyRotate(cmd *cobra.Command, args []string) error {
+	grace, _ := cmd.Flags().GetDuration("grace")
+	client := NewClient()
+	resp, err := client.RotateApiKey(args[0], int(grace/time.Second))
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("✅ API Key '%s' rotated, new key ID: %s\n", resp.RotatedFrom, resp.ID)
+	cmd.Printf("Key: %s\n", resp.ApiKey)
+	if resp.GraceSec > 0 {
+		cmd.Printf("The old key stays valid for %s.\n", time.Duration(resp.GraceSec)*time.Second)
+	} else {
+		cmd.Println("The old key has been revoked.")
+	}
+	cmd.Println("⚠️  Save this key! It will only be shown once.")
+	return nil
+}
+
 func runApiKeyDelete(cmd *cobra.Command, args []string) error {
 	client := NewClient()
 	if err := client.DeleteApiKey(args[0]); err != nil {
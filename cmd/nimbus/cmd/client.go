@@ -612,6 +612,7 @@ func (c *Client) DeleteEnvironment(id string) error {
 type ApiKeyInfo struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -622,6 +623,13 @@ type CreateApiKeyResponse struct {
 	ApiKey string `json:"api_key"`
 }
 
+type RotateApiKeyResponse struct {
+	ID          string `json:"id"`
+	ApiKey      string `json:"api_key"`
+	RotatedFrom string `json:"rotated_from"`
+	GraceSec    int    `json:"grace_sec"`
+}
+
 // ====== 统计与系统状态方法 ======
 
 func (c *Client) GetStatus() (*SystemStatus, error) {
@@ -662,8 +670,11 @@ func (c *Client) ListApiKeys() ([]ApiKeyInfo, error) {
 	return result.ApiKeys, nil
 }
 
-func (c *Client) CreateApiKey(name string) (*CreateApiKeyResponse, error) {
-	req := map[string]string{"name": name}
+func (c *Client) CreateApiKey(name string, scopes []string) (*CreateApiKeyResponse, error) {
+	req := map[string]interface{}{"name": name}
+	if len(scopes) > 0 {
+		req["scopes"] = scopes
+	}
 	var resp CreateApiKeyResponse
 	if err := c.do("POST", "/api/console/apikeys", req, &resp); err != nil {
 		return nil, err
@@ -675,6 +686,15 @@ func (c *Client) DeleteApiKey(id string) error {
 	return c.do("DELETE", "/api/console/apikeys/"+id, nil, nil)
 }
 
+func (c *Client) RotateApiKey(id string, graceSec int) (*RotateApiKeyResponse, error) {
+	req := map[string]int{"grace_sec": graceSec}
+	var resp RotateApiKeyResponse
+	if err := c.do("POST", "/api/console/apikeys/"+id+"/rotate", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 // ====== 模板操作方法 ======
 type Template struct {
 	ID          string   `json:"id"`
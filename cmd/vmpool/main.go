@@ -73,6 +73,10 @@ func main() {
 		logger.WithError(err).Fatal("Failed to start VM pool")
 	}
 
+	// 启动空闲虚拟机回收
+	// 停止空闲过久或复用次数达到上限的虚拟机，每个运行时保留池配置的最小预热数
+	machinesMgr.StartReaper(pool.MinWarm())
+
 	// 启动状态监控 HTTP 服务器
 	// 提供健康检查和统计信息接口
 	srv := startStatsServer(pool, logger)
@@ -95,8 +99,14 @@ func main() {
 	// 关闭状态服务器
 	srv.Shutdown(ctx)
 
+	// 排空虚拟机管理器
+	// 不再交付新的虚拟机，等待执行中的调用完成后停止所有虚拟机并清理网络和 socket 文件
+	if err := machinesMgr.DrainAndShutdown(ctx); err != nil {
+		logger.WithError(err).Warn("VM drain did not finish before deadline")
+	}
+
 	// 停止虚拟机池
-	// 这会销毁所有虚拟机并释放资源
+	// 停止后台任务并关闭与 agent 的连接
 	pool.Stop()
 
 	logger.Info("VM pool stopped")
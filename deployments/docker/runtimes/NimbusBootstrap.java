@@ -0,0 +1,35 @@
+// Bootstrap for Java 17 functions: reads the JSON input from stdin, invokes the
+// String handle(String) method named by the handler argument and prints the result.
+// Keep in sync with javaBootstrapSource in cmd/agent/main.go.
+import java.io.PrintStream;
+import java.lang.reflect.InvocationTargetException;
+import java.lang.reflect.Method;
+import java.lang.reflect.Modifier;
+import java.nio.charset.StandardCharsets;
+
+public class NimbusBootstrap {
+    public static void main(String[] args) throws Exception {
+        String spec = args[0];
+        int sep = spec.indexOf("::");
+        String className = sep >= 0 ? spec.substring(0, sep) : spec;
+        String methodName = sep >= 0 ? spec.substring(sep + 2) : "handle";
+
+        String input = new String(System.in.readAllBytes(), StandardCharsets.UTF_8);
+        Class<?> cls = Class.forName(className);
+        Method method = cls.getMethod(methodName, String.class);
+        Object target = Modifier.isStatic(method.getModifiers()) ? null : cls.getDeclaredConstructor().newInstance();
+
+        PrintStream out = System.out;
+        System.setOut(System.err);
+        Object result;
+        try {
+            result = method.invoke(target, input);
+        } catch (InvocationTargetException e) {
+            e.getCause().printStackTrace();
+            System.exit(1);
+            return;
+        }
+        out.print(result == null ? "null" : result.toString());
+        out.flush();
+    }
+}
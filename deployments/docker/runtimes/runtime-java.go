@@ -0,0 +1,79 @@
+//go:build docker_runtime_java
+// +build docker_runtime_java
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// bootstrapDir contains the compiled NimbusBootstrap class
+const bootstrapDir = "/app/bootstrap"
+
+type Input struct {
+	Handler string            `json:"handler"` // com.example.Handler::handle
+	Code    string            `json:"code"`    // base64 encoded JAR
+	Payload json.RawMessage   `json:"payload"`
+	Env     map[string]string `json:"env"`
+}
+
+func main() {
+	// Read input from stdin
+	inputBytes, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fatal("failed to read stdin: " + err.Error())
+	}
+
+	var input Input
+	if err := json.Unmarshal(inputBytes, &input); err != nil {
+		fatal("failed to parse input: " + err.Error())
+	}
+
+	// Set environment variables
+	for key, value := range input.Env {
+		os.Setenv(key, value)
+	}
+
+	// Decode JAR
+	jar, err := base64.StdEncoding.DecodeString(input.Code)
+	if err != nil {
+		fatal("failed to decode jar: " + err.Error())
+	}
+
+	// Write JAR to temp file
+	tmpFile, err := os.CreateTemp("", "handler-*.jar")
+	if err != nil {
+		fatal("failed to create temp file: " + err.Error())
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(jar); err != nil {
+		fatal("failed to write jar: " + err.Error())
+	}
+	tmpFile.Close()
+
+	// Execute (short-lived JVM: C1 only and serial GC for faster startup)
+	cmd := exec.Command("java",
+		"-XX:TieredStopAtLevel=1", "-XX:+UseSerialGC",
+		"-cp", tmpFile.Name()+":"+bootstrapDir, "NimbusBootstrap", input.Handler)
+	cmd.Stdin = bytes.NewReader(input.Payload)
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		fatal("execution failed: " + err.Error())
+	}
+
+	fmt.Print(string(output))
+}
+
+func fatal(msg string) {
+	fmt.Fprintf(os.Stderr, `{"error":%q}`, msg)
+	os.Exit(1)
+}
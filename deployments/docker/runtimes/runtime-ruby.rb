@@ -0,0 +1,43 @@
+#!/usr/bin/env ruby
+# Function runtime for Ruby 3.2
+# Reads function code and payload from stdin, executes, outputs result to stdout.
+require 'json'
+
+begin
+  # Read input from stdin
+  input_data = JSON.parse($stdin.read)
+
+  handler_path = input_data['handler'] || 'handler'
+  code = input_data['code'] || ''
+  payload = input_data['payload'] || {}
+  env_vars = input_data['env'] || {}
+
+  # Set environment variables
+  env_vars.each { |key, value| ENV[key] = value }
+
+  # Parse handler (file.method format)
+  func_name = handler_path.include?('.') ? handler_path.split('.', 2).last : handler_path
+
+  # Execute the code in an isolated namespace
+  namespace = Module.new
+  namespace.module_eval(code)
+
+  # Get the handler method (top-level methods or module methods)
+  handler =
+    if namespace.private_method_defined?(func_name) || namespace.method_defined?(func_name)
+      Object.new.extend(namespace).method(func_name)
+    elsif namespace.respond_to?(func_name)
+      namespace.method(func_name)
+    else
+      raise ArgumentError, "Handler method '#{func_name}' not found in code"
+    end
+
+  # Execute the handler
+  result = handler.call(payload)
+
+  # Output result
+  puts JSON.generate(result)
+rescue StandardError, ScriptError => e
+  $stderr.puts JSON.generate({ error: e.message, backtrace: e.backtrace })
+  exit 1
+end
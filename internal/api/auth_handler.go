@@ -38,14 +38,15 @@ type APIKeyStore interface {
 	//   - keyHash: 密钥的哈希值（安全存储）
 	//   - userID: 所属用户的ID
 	//   - role: 用户角色
+	//   - scopes: 权限范围（为空表示不受限制）
 	// 返回值: 可能的错误
-	CreateAPIKey(id, name, keyHash, userID, role string) error
+	CreateAPIKey(id, name, keyHash, userID, role string, scopes []string) error
 
 	// GetAPIKeyByHash 通过哈希值获取API密钥信息
 	// 参数：
 	//   - keyHash: 密钥的哈希值
-	// 返回值: 用户ID、角色、密钥ID和可能的错误
-	GetAPIKeyByHash(keyHash string) (string, string, string, error)
+	// 返回值: 密钥ID、用户ID、角色、权限范围和可能的错误
+	GetAPIKeyByHash(keyHash string) (string, string, string, []string, error)
 
 	// DeleteAPIKey 删除指定的API密钥
 	// 参数：
@@ -62,12 +63,13 @@ type APIKeyStore interface {
 
 // APIKeyInfo API密钥信息（不包含敏感数据）
 type APIKeyInfo struct {
-	ID        string  `json:"id"`
-	Name      string  `json:"name"`
-	UserID    string  `json:"user_id"`
-	Role      string  `json:"role"`
-	CreatedAt string  `json:"created_at"`
-	ExpiresAt *string `json:"expires_at,omitempty"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	UserID    string   `json:"user_id"`
+	Role      string   `json:"role"`
+	Scopes    []string `json:"scopes,omitempty"`
+	CreatedAt string   `json:"created_at"`
+	ExpiresAt *string  `json:"expires_at,omitempty"`
 }
 
 // NewAuthHandler 创建并返回一个新的AuthHandler实例。
@@ -86,8 +88,10 @@ func NewAuthHandler(jwt *auth.JWTManager, store APIKeyStore) *AuthHandler {
 //
 // 字段说明：
 //   - Name: API密钥的名称，用于标识该密钥的用途（如"production-key"、"test-key"）
+//   - Scopes: 权限范围（如"functions:read"），为空表示不受限制
 type CreateAPIKeyRequest struct {
-	Name string `json:"name"` // API密钥的名称
+	Name   string   `json:"name"`             // API密钥的名称
+	Scopes []string `json:"scopes,omitempty"` // 权限范围（可选）
 }
 
 // CreateAPIKeyResponse 定义了创建API密钥的响应结构。
@@ -137,6 +141,16 @@ func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "name is required")
 		return
 	}
+	if err := auth.ValidateScopes(req.Scopes); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// 通过受限密钥认证时，新密钥的权限范围不能超过当前密钥
+	scopes, err := auth.NarrowScopes(user.Scopes, req.Scopes)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
 
 	// 生成新的API密钥和对应的哈希值
 	key, hash, err := auth.GenerateAPIKey()
@@ -147,7 +161,7 @@ func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 
 	// 生成唯一ID并保存API密钥记录
 	id := uuid.New().String()
-	if err := h.store.CreateAPIKey(id, req.Name, hash, user.UserID, user.Role); err != nil {
+	if err := h.store.CreateAPIKey(id, req.Name, hash, user.UserID, user.Role, scopes); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create key")
 		return
 	}
@@ -245,6 +259,9 @@ func (h *AuthHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 			"name":       key.Name,
 			"created_at": key.CreatedAt,
 		}
+		if len(key.Scopes) > 0 {
+			result[i]["scopes"] = key.Scopes
+		}
 		if key.ExpiresAt != nil {
 			result[i]["expires_at"] = key.ExpiresAt
 		}
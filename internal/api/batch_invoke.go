@@ -0,0 +1,172 @@
+// Package api 提供了函数即服务(FaaS)平台的HTTP API处理程序。
+// 本文件实现批量调用 API：对一组输入并行同步调用同一个函数，按输入顺序返回每次调用的结果。
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxBatchInvokeInputs 是单次批量调用允许的最大输入数
+	maxBatchInvokeInputs = 100
+	// maxBatchInvokeParallelism 是批量调用同时执行的最大调用数，请求未指定时也使用该值
+	maxBatchInvokeParallelism = 10
+)
+
+// InvokeFunctionBatch 处理批量同步调用函数的请求。
+// HTTP端点: POST /api/v1/functions/{id}/invoke-batch
+//
+// 功能说明：
+//   - 对 inputs 中的每个元素各调用一次函数，最多同时执行 parallelism 个调用
+//   - 并行度不超过函数的 max_concurrency，避免批量调用自身触发并发限制
+//   - 单个调用失败不影响其他调用，结果按输入顺序返回
+//
+// 请求体：domain.BatchInvokeRequest
+//
+// 返回值：
+//   - 200: 返回 domain.BatchInvokeResponse，部分调用失败时也返回 200，失败信息在对应结果中
+//   - 400: 输入为空、超过上限或函数不可调用
+//   - 404: 函数不存在
+func (h *Handler) InvokeFunctionBatch(w http.ResponseWriter, r *http.Request) {
+	idOrName := chi.URLParam(r, "id")
+	if idOrName == "" {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "function id or name required")
+		return
+	}
+
+	fn, err := h.store.GetFunctionByID(idOrName)
+	if err == domain.ErrFunctionNotFound {
+		fn, err = h.store.GetFunctionByName(idOrName)
+	}
+	if err == domain.ErrFunctionNotFound {
+		writeErrorWithContext(w, r, http.StatusNotFound, "function not found: "+idOrName)
+		return
+	}
+	if err != nil {
+		h.logError(r, "InvokeFunctionBatch", "查询函数失败", err, logrus.Fields{"function": idOrName})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get function: "+err.Error())
+		return
+	}
+	if !fn.Status.CanInvoke() {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "function is not active, current status: "+string(fn.Status))
+		return
+	}
+
+	var req domain.BatchInvokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Inputs) == 0 {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "inputs must not be empty")
+		return
+	}
+	if len(req.Inputs) > maxBatchInvokeInputs {
+		writeErrorWithContext(w, r, http.StatusBadRequest, fmt.Sprintf("too many inputs: at most %d per batch", maxBatchInvokeInputs))
+		return
+	}
+
+	// 任一输入不符合函数的 input_schema 时整批拒绝，错误以 inputs[i] 标明位置
+	inputs := normalizeBatchInputs(req.Inputs)
+	if !h.checkBatchInvokeInput(w, r, fn, inputs) {
+		return
+	}
+
+	base := domain.InvokeRequest{
+		FunctionID:  fn.ID,
+		SessionKey:  r.URL.Query().Get("session_key"),
+		Environment: r.URL.Query().Get("environment"),
+		Identity:    requestIdentity(r),
+		CallChain:   requestCallChain(r),
+		Context:     r.Context(),
+	}
+	results := invokeBatch(h.scheduler, base, inputs, batchParallelism(req.Parallelism, fn.MaxConcurrency))
+
+	resp := &domain.BatchInvokeResponse{FunctionID: fn.ID, Results: results}
+	for _, item := range results {
+		if item.Error == "" && item.StatusCode < 400 {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+	h.logInfo(r, "InvokeFunctionBatch", "批量调用完成", logrus.Fields{
+		"function":  fn.Name,
+		"total":     len(results),
+		"succeeded": resp.Succeeded,
+		"failed":    resp.Failed,
+	})
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// normalizeBatchInputs 返回批量调用的输入副本，空输入和 null 替换为 {}，与单次调用的默认输入一致
+func normalizeBatchInputs(raw []json.RawMessage) []json.RawMessage {
+	inputs := make([]json.RawMessage, len(raw))
+	for i, input := range raw {
+		if len(input) == 0 || string(input) == "null" {
+			input = json.RawMessage("{}")
+		}
+		inputs[i] = input
+	}
+	return inputs
+}
+
+// batchParallelism 返回批量调用的并行度：请求值（未指定时为上限）不超过服务端上限，
+// 函数设置了 max_concurrency 时也不超过该值
+func batchParallelism(requested, maxConcurrency int) int {
+	n := requested
+	if n <= 0 || n > maxBatchInvokeParallelism {
+		n = maxBatchInvokeParallelism
+	}
+	if maxConcurrency > 0 && n > maxConcurrency {
+		n = maxConcurrency
+	}
+	return n
+}
+
+// invokeBatch 以 base 为模板对每个输入同步调用一次函数，最多同时执行 parallelism 个调用。
+// inputs 应已由 normalizeBatchInputs 处理。
+// 返回的结果与 inputs 顺序一致；调度器返回错误时记录在对应结果中，不影响其他调用。
+func invokeBatch(sched Scheduler, base domain.InvokeRequest, inputs []json.RawMessage, parallelism int) []domain.BatchInvokeItem {
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	results := make([]domain.BatchInvokeItem, len(inputs))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		req := base
+		req.Payload = input
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, req *domain.InvokeRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resp, err := sched.Invoke(req)
+			if err != nil {
+				results[i] = domain.BatchInvokeItem{Index: i, StatusCode: invokeErrorStatus(err), Error: err.Error()}
+				return
+			}
+			results[i] = domain.BatchInvokeItem{
+				Index:      i,
+				RequestID:  resp.RequestID,
+				StatusCode: resp.StatusCode,
+				Body:       resp.Body,
+				Error:      resp.Error,
+				DurationMs: resp.DurationMs,
+				ColdStart:  resp.ColdStart,
+			}
+		}(i, &req)
+	}
+	wg.Wait()
+	return results
+}
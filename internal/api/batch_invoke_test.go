@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// batchScheduler 按输入内容返回结果，并记录同时执行的最大调用数
+type batchScheduler struct {
+	running, peak int32
+}
+
+func (s *batchScheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeResponse, error) {
+	n := atomic.AddInt32(&s.running, 1)
+	defer atomic.AddInt32(&s.running, -1)
+	for {
+		p := atomic.LoadInt32(&s.peak)
+		if n <= p || atomic.CompareAndSwapInt32(&s.peak, p, n) {
+			break
+		}
+	}
+	var input struct {
+		N    int    `json:"n"`
+		Fail string `json:"fail"`
+	}
+	json.Unmarshal(req.Payload, &input)
+	// 靠前的输入执行得更久，确保结果顺序不依赖完成顺序
+	time.Sleep(time.Duration(10-input.N) * time.Millisecond)
+
+	switch input.Fail {
+	case "error":
+		return nil, fmt.Errorf("%w: function fn-1 is at max_concurrency 2", domain.ErrConcurrencyLimitExceeded)
+	case "function":
+		return &domain.InvokeResponse{RequestID: fmt.Sprintf("req-%d", input.N), StatusCode: 500, Error: "boom"}, nil
+	}
+	return &domain.InvokeResponse{
+		RequestID:  fmt.Sprintf("req-%d", input.N),
+		StatusCode: 200,
+		Body:       json.RawMessage(fmt.Sprintf(`{"double":%d}`, input.N*2)),
+	}, nil
+}
+
+func (s *batchScheduler) InvokeAsync(req *domain.InvokeRequest) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func TestInvokeBatchKeepsOrderAndIsolatesFailures(t *testing.T) {
+	sched := &batchScheduler{}
+	var inputs []json.RawMessage
+	for i := 0; i < 10; i++ {
+		switch i {
+		case 3:
+			inputs = append(inputs, json.RawMessage(`{"n":3,"fail":"error"}`))
+		case 6:
+			inputs = append(inputs, json.RawMessage(`{"n":6,"fail":"function"}`))
+		default:
+			inputs = append(inputs, json.RawMessage(fmt.Sprintf(`{"n":%d}`, i)))
+		}
+	}
+
+	results := invokeBatch(sched, domain.InvokeRequest{FunctionID: "fn-1"}, inputs, 3)
+
+	if len(results) != len(inputs) {
+		t.Fatalf("results = %d, want %d", len(results), len(inputs))
+	}
+	for i, item := range results {
+		if item.Index != i {
+			t.Errorf("results[%d].Index = %d", i, item.Index)
+		}
+		switch i {
+		case 3:
+			if item.StatusCode != 429 || !strings.Contains(item.Error, "concurrency") || item.RequestID != "" {
+				t.Errorf("results[3] = %+v, want 429 scheduler error", item)
+			}
+		case 6:
+			if item.StatusCode != 500 || item.Error != "boom" || item.RequestID != "req-6" {
+				t.Errorf("results[6] = %+v, want function error", item)
+			}
+		default:
+			want := fmt.Sprintf(`{"double":%d}`, i*2)
+			if item.StatusCode != 200 || string(item.Body) != want || item.RequestID != fmt.Sprintf("req-%d", i) {
+				t.Errorf("results[%d] = %+v, want body %s", i, item, want)
+			}
+		}
+	}
+	if sched.peak > 3 {
+		t.Errorf("peak parallelism = %d, want <= 3", sched.peak)
+	}
+}
+
+func TestNormalizeBatchInputsDefaultsEmptyInput(t *testing.T) {
+	raw := []json.RawMessage{nil, json.RawMessage("null"), json.RawMessage(`{"n":1}`)}
+	inputs := normalizeBatchInputs(raw)
+	want := []string{"{}", "{}", `{"n":1}`}
+	for i, input := range inputs {
+		if string(input) != want[i] {
+			t.Errorf("inputs[%d] = %q, want %s", i, input, want[i])
+		}
+	}
+	if raw[0] != nil {
+		t.Errorf("raw inputs modified: %q", raw[0])
+	}
+}
+
+func TestBatchParallelism(t *testing.T) {
+	tests := []struct {
+		requested, maxConcurrency, want int
+	}{
+		{0, 0, maxBatchInvokeParallelism},
+		{4, 0, 4},
+		{50, 0, maxBatchInvokeParallelism},
+		{0, 3, 3},
+		{8, 2, 2},
+		{2, 8, 2},
+	}
+	for _, tt := range tests {
+		if got := batchParallelism(tt.requested, tt.maxConcurrency); got != tt.want {
+			t.Errorf("batchParallelism(%d, %d) = %d, want %d", tt.requested, tt.maxConcurrency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// cancellingScheduler 只能取消预设的正在执行的调用
+type cancellingScheduler struct {
+	MockScheduler
+	running   map[string]bool
+	cancelled []string
+}
+
+func (s *cancellingScheduler) CancelInvocation(invocationID string) bool {
+	if !s.running[invocationID] {
+		return false
+	}
+	s.cancelled = append(s.cancelled, invocationID)
+	return true
+}
+
+// newCancelRouter 返回挂在 /invocations/{id}/cancel 上的取消路由
+func newCancelRouter(store *MockStore, sched Scheduler) http.Handler {
+	h := &Handler{scheduler: sched}
+	r := chi.NewRouter()
+	r.Post("/invocations/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		h.cancelInvocation(w, r, store)
+	})
+	return r
+}
+
+func TestCancelInvocation(t *testing.T) {
+	store := NewMockStore()
+	store.invocations["done"] = &domain.Invocation{ID: "done", Status: domain.InvocationStatusSuccess}
+	store.invocations["queued"] = &domain.Invocation{ID: "queued", Status: domain.InvocationStatusPending}
+	sched := &cancellingScheduler{running: map[string]bool{"inv-1": true}}
+
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{"inv-1", http.StatusAccepted},
+		{"done", http.StatusConflict},
+		{"queued", http.StatusConflict},
+		{"missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		newCancelRouter(store, sched).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/invocations/"+tt.id+"/cancel", nil))
+		if rec.Code != tt.want {
+			t.Errorf("cancel %s status = %d, want %d: %s", tt.id, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+	if len(sched.cancelled) != 1 || sched.cancelled[0] != "inv-1" {
+		t.Errorf("cancelled = %v, want [inv-1]", sched.cancelled)
+	}
+
+	// 调度器不支持取消时返回 501
+	rec := httptest.NewRecorder()
+	newCancelRouter(store, &MockScheduler{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/invocations/inv-1/cancel", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("status without canceller = %d, want 501", rec.Code)
+	}
+}
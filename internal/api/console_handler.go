@@ -6,7 +6,9 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -15,7 +17,10 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
+	"github.com/oriys/nimbus/internal/auth"
 	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/snapshot"
+	"github.com/oriys/nimbus/internal/state"
 	"github.com/oriys/nimbus/internal/storage"
 	"github.com/sirupsen/logrus"
 )
@@ -94,6 +99,18 @@ type ConsoleHandler struct {
 	store   *storage.PostgresStore
 	logger  *logrus.Logger
 
+	// 状态存储后端（可选），用于在系统状态中展示 Redis 健康状况
+	stateHandler *state.Handler
+
+	// 快照管理器（可选），用于展示快照命中率
+	snapshotMgr *snapshot.Manager
+
+	// 虚拟机池统计来源（可选），未设置时系统状态中不展示池统计
+	poolStats func() []PoolStats
+
+	// 虚拟机运行指标来源（可选），Docker 模式下未设置
+	vmMetrics func() []VMMetrics
+
 	// WebSocket 升级器
 	upgrader websocket.Upgrader
 
@@ -131,6 +148,26 @@ func NewConsoleHandler(h *Handler, store *storage.PostgresStore, logger *logrus.
 	}
 }
 
+// SetStateHandler 设置状态处理器，用于在系统状态中展示状态存储后端的健康状况
+func (c *ConsoleHandler) SetStateHandler(sh *state.Handler) {
+	c.stateHandler = sh
+}
+
+// SetSnapshotManager 设置快照管理器，用于展示快照统计和命中率
+func (c *ConsoleHandler) SetSnapshotManager(mgr *snapshot.Manager) {
+	c.snapshotMgr = mgr
+}
+
+// SetPoolStatsSource 设置虚拟机池统计来源，用于在系统状态中展示各运行时的池状态
+func (c *ConsoleHandler) SetPoolStatsSource(source func() []PoolStats) {
+	c.poolStats = source
+}
+
+// SetVMMetricsSource 设置虚拟机运行指标来源，用于展示各虚拟机的 CPU、磁盘和网络计数
+func (c *ConsoleHandler) SetVMMetricsSource(source func() []VMMetrics) {
+	c.vmMetrics = source
+}
+
 // RegisterRoutes 注册控制台路由
 func (c *ConsoleHandler) RegisterRoutes(r chi.Router) {
 	r.Route("/console", func(r chi.Router) {
@@ -138,11 +175,18 @@ func (c *ConsoleHandler) RegisterRoutes(r chi.Router) {
 		r.Get("/dashboard/stats", c.GetDashboardStats)
 		r.Get("/dashboard/trends", c.GetInvocationTrends)
 		r.Get("/dashboard/top-functions", c.GetTopFunctions)
+		r.Get("/dashboard/top-cost-invocations", c.GetTopCostInvocations)
 		r.Get("/dashboard/recent-invocations", c.GetRecentInvocations)
 
 		// 系统状态
 		r.Get("/system/status", c.GetSystemStatus)
 
+		// 快照统计
+		r.Get("/snapshots/stats", c.GetSnapshotStats)
+
+		// 虚拟机运行指标
+		r.Get("/vms/metrics", c.GetVMMetrics)
+
 		// 函数测试
 		r.Post("/functions/{id}/test", c.TestFunction)
 
@@ -150,6 +194,7 @@ func (c *ConsoleHandler) RegisterRoutes(r chi.Router) {
 		r.Get("/functions/{id}/stats", c.GetFunctionStats)
 		r.Get("/functions/{id}/trends", c.GetFunctionTrends)
 		r.Get("/functions/{id}/latency-distribution", c.GetFunctionLatencyDistribution)
+		r.Get("/functions/{id}/latency-samples", c.GetFunctionLatencySamples)
 
 		// 实时日志 WebSocket
 		r.Get("/logs", c.ListLogs)
@@ -162,6 +207,7 @@ func (c *ConsoleHandler) RegisterRoutes(r chi.Router) {
 		r.Get("/apikeys", c.ListAPIKeys)
 		r.Post("/apikeys", c.CreateAPIKey)
 		r.Delete("/apikeys/{id}", c.DeleteAPIKey)
+		r.Post("/apikeys/{id}/rotate", c.RotateAPIKey)
 	})
 }
 
@@ -382,6 +428,35 @@ func (c *ConsoleHandler) GetTopFunctions(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// GetTopCostInvocations 获取资源消耗（GB-秒）最高的单次调用
+func (c *ConsoleHandler) GetTopCostInvocations(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	periodHours := parsePeriodHours(period)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	to := time.Now()
+	from := to.Add(-time.Duration(periodHours) * time.Hour)
+	data, err := c.store.GetTopCostInvocations(from, to, limit)
+	if err != nil {
+		c.logger.WithError(err).Error("Failed to get top cost invocations")
+		data = []storage.CostlyInvocation{}
+	}
+	if data == nil {
+		data = []storage.CostlyInvocation{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": data,
+	})
+}
+
 // RecentInvocation 最近调用
 type RecentInvocation struct {
 	ID           string `json:"id"`
@@ -438,10 +513,11 @@ type PoolStats struct {
 
 // SystemStatusResponse 系统状态响应
 type SystemStatusResponse struct {
-	Status    string      `json:"status"`
-	Version   string      `json:"version"`
-	Uptime    string      `json:"uptime"`
-	PoolStats []PoolStats `json:"pool_stats"`
+	Status       string               `json:"status"`
+	Version      string               `json:"version"`
+	Uptime       string               `json:"uptime"`
+	PoolStats    []PoolStats          `json:"pool_stats"`
+	StateBackend *state.BackendHealth `json:"state_backend,omitempty"`
 }
 
 // startTime 记录服务启动时间
@@ -459,11 +535,10 @@ func (c *ConsoleHandler) GetSystemStatus(w http.ResponseWriter, r *http.Request)
 		status = "degraded"
 	}
 
-	// 构建虚拟机池统计（示例数据，实际应从 VM 池管理器获取）
-	poolStats := []PoolStats{
-		{Runtime: "python3.11", WarmVMs: 2, BusyVMs: 1, TotalVMs: 3, MaxVMs: 10},
-		{Runtime: "nodejs20", WarmVMs: 1, BusyVMs: 0, TotalVMs: 1, MaxVMs: 10},
-		{Runtime: "go1.24", WarmVMs: 1, BusyVMs: 0, TotalVMs: 1, MaxVMs: 10},
+	// 虚拟机池统计（Docker 模式没有虚拟机池，返回空列表）
+	poolStats := []PoolStats{}
+	if c.poolStats != nil {
+		poolStats = c.poolStats()
 	}
 
 	response := SystemStatusResponse{
@@ -473,10 +548,103 @@ func (c *ConsoleHandler) GetSystemStatus(w http.ResponseWriter, r *http.Request)
 		PoolStats: poolStats,
 	}
 
+	// 状态存储后端（Redis）不可用时，有状态函数处于降级模式
+	if c.stateHandler != nil {
+		response.StateBackend = c.stateHandler.Health()
+		if !response.StateBackend.Available {
+			response.Status = "degraded"
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetSnapshotStats 获取快照统计，包括快照数量、大小和命中率。
+// 命中率反映冷启动中有多少比例是从快照恢复的，用于判断快照是否真正缩短了冷启动。
+// GET /api/console/snapshots/stats
+func (c *ConsoleHandler) GetSnapshotStats(w http.ResponseWriter, r *http.Request) {
+	if c.snapshotMgr == nil {
+		writeErrorWithContext(w, r, http.StatusServiceUnavailable, "snapshot manager not configured")
+		return
+	}
+
+	stats, err := c.snapshotMgr.GetStats(r.Context())
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get snapshot stats: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// VMMetrics 单个虚拟机启动以来的累计运行指标，字段与 Firecracker metrics 汇总一致
+type VMMetrics struct {
+	VMID            string    `json:"vm_id,omitempty"`
+	Runtime         string    `json:"runtime,omitempty"`
+	CPUTimeMs       int64     `json:"cpu_time_ms"`
+	VCPUExits       int64     `json:"vcpu_exits"`
+	BlockReadBytes  int64     `json:"block_read_bytes"`
+	BlockWriteBytes int64     `json:"block_write_bytes"`
+	BlockReadCount  int64     `json:"block_read_count"`
+	BlockWriteCount int64     `json:"block_write_count"`
+	NetRxBytes      int64     `json:"net_rx_bytes"`
+	NetTxBytes      int64     `json:"net_tx_bytes"`
+	NetRxPackets    int64     `json:"net_rx_packets"`
+	NetTxPackets    int64     `json:"net_tx_packets"`
+	ThrottledEvents int64     `json:"throttled_events"`
+	UpdatedAt       time.Time `json:"updated_at,omitempty"`
+}
+
+// VMMetricsResponse 虚拟机运行指标响应
+type VMMetricsResponse struct {
+	VMCount int         `json:"vm_count"`
+	Total   VMMetrics   `json:"total"`
+	VMs     []VMMetrics `json:"vms"`
+}
+
+// aggregateVMMetrics 汇总所有虚拟机的指标，UpdatedAt 取最近一次更新时间
+func aggregateVMMetrics(vms []VMMetrics) VMMetrics {
+	var total VMMetrics
+	for _, vm := range vms {
+		total.CPUTimeMs += vm.CPUTimeMs
+		total.VCPUExits += vm.VCPUExits
+		total.BlockReadBytes += vm.BlockReadBytes
+		total.BlockWriteBytes += vm.BlockWriteBytes
+		total.BlockReadCount += vm.BlockReadCount
+		total.BlockWriteCount += vm.BlockWriteCount
+		total.NetRxBytes += vm.NetRxBytes
+		total.NetTxBytes += vm.NetTxBytes
+		total.NetRxPackets += vm.NetRxPackets
+		total.NetTxPackets += vm.NetTxPackets
+		total.ThrottledEvents += vm.ThrottledEvents
+		if vm.UpdatedAt.After(total.UpdatedAt) {
+			total.UpdatedAt = vm.UpdatedAt
+		}
+	}
+	return total
+}
+
+// GetVMMetrics 获取所有运行中虚拟机的累计运行指标及汇总值。
+// 指标来自 Firecracker metrics，Docker 模式下没有虚拟机指标，返回 503。
+// GET /api/console/vms/metrics
+func (c *ConsoleHandler) GetVMMetrics(w http.ResponseWriter, r *http.Request) {
+	if c.vmMetrics == nil {
+		writeErrorWithContext(w, r, http.StatusServiceUnavailable, "vm metrics not available")
+		return
+	}
+
+	vms := c.vmMetrics()
+	if vms == nil {
+		vms = []VMMetrics{}
+	}
+	writeJSON(w, http.StatusOK, VMMetricsResponse{
+		VMCount: len(vms),
+		Total:   aggregateVMMetrics(vms),
+		VMs:     vms,
+	})
+}
+
 // formatUptime 格式化运行时间
 func formatUptime(d time.Duration) string {
 	days := int(d.Hours()) / 24
@@ -604,6 +772,30 @@ func (c *ConsoleHandler) GetFunctionLatencyDistribution(w http.ResponseWriter, r
 	})
 }
 
+// GetFunctionLatencySamples 获取函数调用耗时的随机样本（毫秒，升序），
+// 用于在控制台按任意分桶绘制直方图。limit 为最大样本数，上限见 storage.MaxLatencySamples。
+func (c *ConsoleHandler) GetFunctionLatencySamples(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "function id required", http.StatusBadRequest)
+		return
+	}
+
+	periodHours := parsePeriodHours(r.URL.Query().Get("period"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	samples, err := c.store.GetFunctionLatencySamples(id, periodHours, limit)
+	if err != nil {
+		c.logger.WithError(err).Error("Failed to get latency samples")
+		samples = []int64{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": samples,
+	})
+}
+
 // LogStream 实时日志流 WebSocket
 func (c *ConsoleHandler) LogStream(w http.ResponseWriter, r *http.Request) {
 	// 获取可选的过滤参数
@@ -742,6 +934,9 @@ func (c *ConsoleHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 			"name":       key.Name,
 			"created_at": key.CreatedAt.Format(time.RFC3339),
 		}
+		if len(key.Scopes) > 0 {
+			result[i]["scopes"] = key.Scopes
+		}
 		if key.ExpiresAt != nil {
 			result[i]["expires_at"] = key.ExpiresAt.Format(time.RFC3339)
 		}
@@ -754,7 +949,8 @@ func (c *ConsoleHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 // CreateAPIKey 创建新的 API Key
 func (c *ConsoleHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name string `json:"name"`
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"` // 权限范围（可选），为空表示不受限制
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
@@ -765,6 +961,10 @@ func (c *ConsoleHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "name is required", http.StatusBadRequest)
 		return
 	}
+	if err := auth.ValidateScopes(req.Scopes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// 使用默认用户（控制台无认证）
 	userID := "console-user"
@@ -782,7 +982,7 @@ func (c *ConsoleHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	id := randomUUID()
 
 	// 保存到数据库
-	if err := c.store.CreateAPIKey(id, req.Name, hash, userID, role); err != nil {
+	if err := c.store.CreateAPIKey(id, req.Name, hash, userID, role, req.Scopes); err != nil {
 		c.logger.WithError(err).Error("Failed to create API key")
 		http.Error(w, "failed to create api key", http.StatusInternalServerError)
 		return
@@ -793,6 +993,7 @@ func (c *ConsoleHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"id":      id,
 		"name":    req.Name,
+		"scopes":  req.Scopes,
 		"api_key": key, // 仅返回一次
 	})
 }
@@ -821,6 +1022,91 @@ func (c *ConsoleHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// API Key 轮换的宽限期
+const (
+	defaultAPIKeyRotationGraceSec = 24 * 60 * 60      // 未指定 grace_sec 时旧密钥继续有效 1 天
+	maxAPIKeyRotationGraceSec     = 30 * 24 * 60 * 60 // 宽限期最长 30 天
+)
+
+// RotateAPIKey 轮换 API Key：生成同名的新密钥，旧密钥在宽限期内仍然有效，之后自动失效。
+// HTTP端点: POST /api/console/apikeys/{id}/rotate
+//
+// 请求体（可选）: {"grace_sec": 3600}，默认 86400，为 0 时旧密钥立即失效
+func (c *ConsoleHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		GraceSec *int `json:"grace_sec"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	graceSec := defaultAPIKeyRotationGraceSec
+	if req.GraceSec != nil {
+		graceSec = *req.GraceSec
+	}
+	if graceSec < 0 || graceSec > maxAPIKeyRotationGraceSec {
+		http.Error(w, fmt.Sprintf("grace_sec must be between 0 and %d", maxAPIKeyRotationGraceSec), http.StatusBadRequest)
+		return
+	}
+
+	// 使用默认用户（控制台无认证），只能轮换控制台用户自己的密钥
+	userID := "console-user"
+	keys, err := c.store.ListAPIKeysByUser(userID)
+	if err != nil {
+		c.logger.WithError(err).Error("Failed to list API keys")
+		http.Error(w, "failed to rotate api key", http.StatusInternalServerError)
+		return
+	}
+	owned := false
+	for _, key := range keys {
+		if key.ID == id {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		http.Error(w, "api key not found", http.StatusNotFound)
+		return
+	}
+
+	key, err := c.store.RotateAPIKey(id, time.Duration(graceSec)*time.Second)
+	if err != nil {
+		if errors.Is(err, auth.ErrAPIKeyNotFound) {
+			http.Error(w, "api key not found", http.StatusNotFound)
+			return
+		}
+		c.logger.WithError(err).Error("Failed to rotate API key")
+		http.Error(w, "failed to rotate api key", http.StatusInternalServerError)
+		return
+	}
+	newID, _, _, _, err := c.store.GetAPIKeyByHash(sha256Hash(key))
+	if err != nil {
+		c.logger.WithError(err).Error("Failed to look up rotated API key")
+		http.Error(w, "failed to rotate api key", http.StatusInternalServerError)
+		return
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"old_key_id": id,
+		"new_key_id": newID,
+		"grace_sec":  graceSec,
+	}).Info("API key rotated")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":           newID,
+		"api_key":      key, // 仅返回一次
+		"rotated_from": id,
+		"grace_sec":    graceSec,
+	})
+}
+
 // generateAPIKey 生成 API Key 和哈希值
 func generateAPIKey() (string, string, error) {
 	bytes := make([]byte, 32)
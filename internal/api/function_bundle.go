@@ -0,0 +1,613 @@
+// Package api 提供了函数即服务(FaaS)平台的HTTP API处理程序。
+// 本文件实现函数导出包：将函数及其层内容、别名、各环境配置和版本导出为 JSON 或 zip，
+// 并在其他 Nimbus 实例中导入重建，导入时重新生成 ID 并按名称匹配层和环境。
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/oriys/nimbus/internal/compiler"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxFunctionBundleSize 是导入的导出包最大字节数，层内容包含在导出包中
+	maxFunctionBundleSize = 100 << 20
+	// functionBundleManifest 是 zip 格式导出包中保存导出包描述的文件名
+	functionBundleManifest = "bundle.json"
+	// maxImportRenameAttempts 是名称冲突时尝试生成新名称的最大次数
+	maxImportRenameAttempts = 100
+)
+
+// functionBundleStore 是导出和导入函数所需的存储接口，*storage.PostgresStore 实现了该接口。
+type functionBundleStore interface {
+	GetFunctionByName(name string) (*domain.Function, error)
+	CreateFunction(fn *domain.Function) error
+	GetFunctionLayers(functionID string) ([]domain.FunctionLayer, error)
+	SetFunctionLayers(functionID string, layers []domain.FunctionLayer) error
+	GetLayerByID(id string) (*domain.Layer, error)
+	GetLayerByName(name string) (*domain.Layer, error)
+	CreateLayer(l *domain.Layer) error
+	UpdateLayer(l *domain.Layer) error
+	ListLayerVersions(layerID string) ([]*domain.LayerVersion, error)
+	CreateLayerVersion(lv *domain.LayerVersion, content []byte) error
+	GetLayerVersionContent(layerID string, version int) ([]byte, error)
+	ListFunctionAliases(functionID string) ([]*domain.FunctionAlias, error)
+	CreateFunctionAlias(a *domain.FunctionAlias) error
+	ListFunctionVersions(functionID string) ([]*domain.FunctionVersion, error)
+	CreateFunctionVersion(v *domain.FunctionVersion) error
+	ListFunctionEnvConfigs(functionID string) ([]*domain.FunctionEnvConfig, error)
+	GetEnvironmentByName(name string) (*domain.Environment, error)
+	UpsertFunctionEnvConfig(cfg *domain.FunctionEnvConfig) error
+}
+
+// functionImportOptions 是导入函数导出包的选项。
+type functionImportOptions struct {
+	// Name 指定新函数的名称，为空时使用导出包中的名称
+	Name string
+	// Rename 为 true 时名称冲突自动改用 <name>-imported、<name>-imported-2 等名称，否则返回冲突错误
+	Rename bool
+	// TaskID 是新函数的创建任务 ID
+	TaskID string
+}
+
+// ExportFunction 导出函数及其依赖为可移植的导出包。
+// HTTP端点: GET /api/v1/functions/{id}/export
+//
+// 功能说明：
+//   - 导出函数定义、使用的层（含内容）、别名、各环境配置，以及最新版本和别名引用的版本
+//   - 不包含实例相关的 ID、状态和 Webhook 密钥；函数有源代码时不包含编译产物，导入后重新编译
+//
+// 查询参数：
+//   - format: json（默认）或 zip；zip 格式中层内容保存为单独的文件
+//
+// 返回值：
+//   - 200: 返回 domain.FunctionBundle（json）或 zip 文件
+//   - 404: 函数不存在
+func (h *Handler) ExportFunction(w http.ResponseWriter, r *http.Request) {
+	idOrName := chi.URLParam(r, "id")
+	if idOrName == "" {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "function id or name required")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "zip" {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid format, must be json or zip")
+		return
+	}
+
+	h.logInfo(r, "ExportFunction", "导出函数", logrus.Fields{"function": idOrName, "format": format})
+
+	fn, err := h.store.GetFunctionByID(idOrName)
+	if err == domain.ErrFunctionNotFound {
+		fn, err = h.store.GetFunctionByName(idOrName)
+	}
+	if err == domain.ErrFunctionNotFound {
+		writeErrorWithContext(w, r, http.StatusNotFound, "function not found: "+idOrName)
+		return
+	}
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get function: "+err.Error())
+		return
+	}
+
+	bundle, err := exportFunctionBundle(h.store, fn)
+	if err != nil {
+		h.logError(r, "ExportFunction", "导出函数失败", err, logrus.Fields{"function": fn.Name})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to export function: "+err.Error())
+		return
+	}
+
+	if format == "zip" {
+		data, err := encodeFunctionBundleZip(bundle)
+		if err != nil {
+			writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to build bundle: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.zip", fn.Name))
+		w.Header().Set("Content-Type", "application/zip")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", fn.Name))
+	writeJSON(w, http.StatusOK, bundle)
+}
+
+// ImportFunction 从导出包导入函数。
+// HTTP端点: POST /api/v1/functions/import
+//
+// 功能说明：
+//   - 请求体为 ExportFunction 导出的 JSON 或 zip，也接受只含函数字段的旧版导出格式
+//   - 重新生成函数、别名和版本的 ID；层按名称和内容哈希匹配，已有相同内容的版本时直接复用
+//   - 环境配置按环境名称关联，目标实例中不存在的环境跳过并在 warnings 中说明
+//
+// 查询参数：
+//   - name: 新函数名称（可选，默认使用导出包中的名称）
+//   - rename: 为 true 时名称冲突自动改名，否则返回 409
+//
+// 返回值：
+//   - 202: 返回 domain.FunctionImportResult，函数在后台完成创建
+//   - 400: 导出包无效
+//   - 409: 函数名称已存在
+func (h *Handler) ImportFunction(w http.ResponseWriter, r *http.Request) {
+	requestID := middleware.GetReqID(r.Context())
+	h.logInfo(r, "ImportFunction", "导入函数", logrus.Fields{"request_id": requestID})
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFunctionBundleSize))
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "failed to read bundle: "+err.Error())
+		return
+	}
+	bundle, err := decodeFunctionBundle(data)
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid bundle: "+err.Error())
+		return
+	}
+
+	rename, _ := strconv.ParseBool(r.URL.Query().Get("rename"))
+	opts := functionImportOptions{
+		Name:   r.URL.Query().Get("name"),
+		Rename: rename,
+		TaskID: fmt.Sprintf("task-%s", requestID),
+	}
+	result, err := importFunctionBundle(h.store, bundle, opts)
+	if errors.Is(err, domain.ErrFunctionExists) {
+		writeErrorWithContext(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, errInvalidFunctionBundle) {
+		writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.logError(r, "ImportFunction", "导入函数失败", err, nil)
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to import function: "+err.Error())
+		return
+	}
+	result.TaskID = opts.TaskID
+
+	// 创建任务记录
+	task := &domain.FunctionTask{
+		ID:         opts.TaskID,
+		FunctionID: result.Function.ID,
+		Type:       domain.FunctionTaskCreate,
+		Status:     domain.FunctionTaskPending,
+		CreatedAt:  time.Now(),
+	}
+	if err := h.store.CreateFunctionTask(task); err != nil {
+		h.logError(r, "ImportFunction", "创建任务记录失败", err, nil)
+	}
+
+	// 异步处理函数创建
+	go h.processCreateFunctionTask(result.Function.ID, opts.TaskID)
+
+	h.logInfo(r, "ImportFunction", "函数导入成功", logrus.Fields{
+		"function": result.Function.Name,
+		"id":       result.Function.ID,
+		"layers":   len(result.Layers),
+		"aliases":  result.Aliases,
+		"warnings": len(result.Warnings),
+	})
+	writeJSON(w, http.StatusAccepted, result)
+}
+
+// errInvalidFunctionBundle 表示导出包内容不完整或格式不受支持
+var errInvalidFunctionBundle = errors.New("invalid function bundle")
+
+// exportFunctionBundle 构建函数的导出包。
+// 导出的版本包括最新版本和别名权重中引用的版本，以保证导入后别名仍然有效。
+func exportFunctionBundle(store functionBundleStore, fn *domain.Function) (*domain.FunctionBundle, error) {
+	bundle := &domain.FunctionBundle{
+		FormatVersion: domain.FunctionBundleFormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Function:      portableFunction(fn),
+	}
+
+	layers, err := store.GetFunctionLayers(fn.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get function layers: %w", err)
+	}
+	for _, fl := range layers {
+		layer, err := store.GetLayerByID(fl.LayerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get layer %s: %w", fl.LayerName, err)
+		}
+		content, err := store.GetLayerVersionContent(fl.LayerID, fl.LayerVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content of layer %s version %d: %w", fl.LayerName, fl.LayerVersion, err)
+		}
+		bundle.Layers = append(bundle.Layers, domain.BundleLayer{
+			Name:               layer.Name,
+			Description:        layer.Description,
+			CompatibleRuntimes: layer.CompatibleRuntimes,
+			Version:            fl.LayerVersion,
+			Order:              fl.Order,
+			ContentHash:        bundleContentHash(content),
+			Content:            content,
+		})
+	}
+
+	aliases, err := store.ListFunctionAliases(fn.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list aliases: %w", err)
+	}
+	referenced := make(map[int]bool)
+	for _, a := range aliases {
+		alias := *a
+		alias.ID, alias.FunctionID = "", ""
+		bundle.Aliases = append(bundle.Aliases, &alias)
+		for _, w := range a.RoutingConfig.Weights {
+			referenced[w.Version] = true
+		}
+	}
+
+	versions, err := store.ListFunctionVersions(fn.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	latest := 0
+	for _, v := range versions {
+		if v.Version > latest {
+			latest = v.Version
+		}
+	}
+	for _, v := range versions {
+		if v.Version == latest || referenced[v.Version] {
+			version := *v
+			version.ID, version.FunctionID = "", ""
+			bundle.Versions = append(bundle.Versions, &version)
+		}
+	}
+	sort.Slice(bundle.Versions, func(i, j int) bool { return bundle.Versions[i].Version < bundle.Versions[j].Version })
+
+	envConfigs, err := store.ListFunctionEnvConfigs(fn.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environment configs: %w", err)
+	}
+	for _, c := range envConfigs {
+		cfg := *c
+		cfg.FunctionID, cfg.EnvironmentID = "", ""
+		bundle.EnvConfigs = append(bundle.EnvConfigs, &cfg)
+	}
+	return bundle, nil
+}
+
+// portableFunction 返回去掉实例相关字段的函数定义副本。
+// 有源代码时去掉编译产物，导入后重新编译；只有二进制的函数保留二进制。
+func portableFunction(fn *domain.Function) *domain.Function {
+	portable := *fn
+	portable.ID = ""
+	portable.Status = ""
+	portable.StatusMessage = ""
+	portable.TaskID = ""
+	portable.WebhookKey = ""
+	portable.LastDeployedAt = nil
+	portable.OwnerID = ""
+	portable.Pinned = false
+	portable.CreatedAt = time.Time{}
+	portable.UpdatedAt = time.Time{}
+	if compiler.IsSourceCode(string(fn.Runtime), fn.Code) {
+		portable.Binary = ""
+	}
+	return &portable
+}
+
+// importFunctionBundle 在 store 中重建导出包中的函数及其依赖。
+// 先导入层，再创建函数（状态为 creating）并关联层、版本、别名和环境配置；
+// 函数创建后的步骤失败时返回错误，已创建的记录保留。
+func importFunctionBundle(store functionBundleStore, bundle *domain.FunctionBundle, opts functionImportOptions) (*domain.FunctionImportResult, error) {
+	if err := validateFunctionBundle(bundle); err != nil {
+		return nil, err
+	}
+	spec := bundle.Function
+
+	name, renamed, err := resolveImportName(store, spec.Name, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := &domain.FunctionImportResult{Renamed: renamed}
+
+	for _, bl := range bundle.Layers {
+		fl, err := importBundleLayer(store, bl)
+		if err != nil {
+			return nil, err
+		}
+		result.Layers = append(result.Layers, fl)
+	}
+
+	fn := *spec
+	fn.ID = uuid.New().String()
+	fn.Name = name
+	fn.Status = domain.FunctionStatusCreating
+	fn.StatusMessage = "函数正在创建中（导入）"
+	fn.TaskID = opts.TaskID
+	fn.WebhookKey = ""
+	if fn.WebhookEnabled {
+		fn.WebhookKey = generateWebhookKey()
+	}
+	if fn.MemoryMB == 0 {
+		fn.MemoryMB = 256
+	}
+	if fn.TimeoutSec == 0 {
+		fn.TimeoutSec = 30
+	}
+	if fn.Version == 0 {
+		fn.Version = 1
+	}
+	if err := store.CreateFunction(&fn); err != nil {
+		return nil, fmt.Errorf("failed to create function: %w", err)
+	}
+	result.Function = &fn
+
+	if len(result.Layers) > 0 {
+		if err := store.SetFunctionLayers(fn.ID, result.Layers); err != nil {
+			return result, fmt.Errorf("failed to attach layers: %w", err)
+		}
+	}
+
+	for _, v := range bundle.Versions {
+		version := *v
+		version.ID = ""
+		version.FunctionID = fn.ID
+		if err := store.CreateFunctionVersion(&version); err != nil {
+			return result, fmt.Errorf("failed to create version %d: %w", v.Version, err)
+		}
+		result.Versions++
+	}
+
+	for _, a := range bundle.Aliases {
+		alias := *a
+		alias.ID = ""
+		alias.FunctionID = fn.ID
+		if err := store.CreateFunctionAlias(&alias); err != nil {
+			return result, fmt.Errorf("failed to create alias %s: %w", a.Name, err)
+		}
+		result.Aliases++
+	}
+
+	for _, c := range bundle.EnvConfigs {
+		env, err := store.GetEnvironmentByName(c.EnvironmentName)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("environment %q not found, config skipped", c.EnvironmentName))
+			continue
+		}
+		cfg := *c
+		cfg.FunctionID = fn.ID
+		cfg.EnvironmentID = env.ID
+		if err := store.UpsertFunctionEnvConfig(&cfg); err != nil {
+			return result, fmt.Errorf("failed to create config for environment %s: %w", c.EnvironmentName, err)
+		}
+		result.EnvConfigs++
+	}
+	return result, nil
+}
+
+// validateFunctionBundle 检查导出包的格式版本和函数定义的必填字段
+func validateFunctionBundle(bundle *domain.FunctionBundle) error {
+	if bundle.FormatVersion > domain.FunctionBundleFormatVersion {
+		return fmt.Errorf("%w: unsupported format version %d", errInvalidFunctionBundle, bundle.FormatVersion)
+	}
+	fn := bundle.Function
+	switch {
+	case fn == nil:
+		return fmt.Errorf("%w: function is required", errInvalidFunctionBundle)
+	case fn.Name == "":
+		return fmt.Errorf("%w: name is required", errInvalidFunctionBundle)
+	case !fn.Runtime.IsValid():
+		return fmt.Errorf("%w: invalid runtime: %s", errInvalidFunctionBundle, fn.Runtime)
+	case fn.Handler == "":
+		return fmt.Errorf("%w: handler is required", errInvalidFunctionBundle)
+	case fn.Code == "" && fn.Binary == "":
+		return fmt.Errorf("%w: code is required", errInvalidFunctionBundle)
+	}
+	for _, bl := range bundle.Layers {
+		if bl.Name == "" {
+			return fmt.Errorf("%w: layer name is required", errInvalidFunctionBundle)
+		}
+		if bl.ContentHash != "" && bundleContentHash(bl.Content) != bl.ContentHash {
+			return fmt.Errorf("%w: content hash mismatch for layer %s", errInvalidFunctionBundle, bl.Name)
+		}
+	}
+	return nil
+}
+
+// resolveImportName 返回导入后使用的函数名称以及是否因冲突改名
+func resolveImportName(store functionBundleStore, bundleName string, opts functionImportOptions) (string, bool, error) {
+	name := bundleName
+	if opts.Name != "" {
+		name = opts.Name
+	}
+	if _, err := store.GetFunctionByName(name); err == domain.ErrFunctionNotFound {
+		return name, false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("failed to check function name: %w", err)
+	}
+	if !opts.Rename {
+		return "", false, fmt.Errorf("%w: %s", domain.ErrFunctionExists, name)
+	}
+
+	for i := 1; i <= maxImportRenameAttempts; i++ {
+		candidate := name + "-imported"
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-imported-%d", name, i)
+		}
+		if _, err := store.GetFunctionByName(candidate); err == domain.ErrFunctionNotFound {
+			return candidate, true, nil
+		} else if err != nil {
+			return "", false, fmt.Errorf("failed to check function name: %w", err)
+		}
+	}
+	return "", false, fmt.Errorf("%w: no free name for %s after %d attempts", domain.ErrFunctionExists, name, maxImportRenameAttempts)
+}
+
+// importBundleLayer 在目标实例中找到或创建导出包中的层版本。
+// 同名层已有相同内容的版本时直接复用，否则创建新版本（层不存在时先创建层）。
+func importBundleLayer(store functionBundleStore, bl domain.BundleLayer) (domain.FunctionLayer, error) {
+	hash := bundleContentHash(bl.Content)
+	layer, err := store.GetLayerByName(bl.Name)
+	if err != nil {
+		layer = &domain.Layer{
+			Name:               bl.Name,
+			Description:        bl.Description,
+			CompatibleRuntimes: bl.CompatibleRuntimes,
+		}
+		if err := store.CreateLayer(layer); err != nil {
+			return domain.FunctionLayer{}, fmt.Errorf("failed to create layer %s: %w", bl.Name, err)
+		}
+	} else {
+		versions, err := store.ListLayerVersions(layer.ID)
+		if err != nil {
+			return domain.FunctionLayer{}, fmt.Errorf("failed to list versions of layer %s: %w", bl.Name, err)
+		}
+		for _, lv := range versions {
+			if lv.ContentHash == hash {
+				return domain.FunctionLayer{LayerID: layer.ID, LayerName: layer.Name, LayerVersion: lv.Version, Order: bl.Order, ContentHash: hash}, nil
+			}
+		}
+	}
+
+	lv := &domain.LayerVersion{
+		LayerID:     layer.ID,
+		Version:     layer.LatestVersion + 1,
+		ContentHash: hash,
+		SizeBytes:   int64(len(bl.Content)),
+	}
+	if err := store.CreateLayerVersion(lv, bl.Content); err != nil {
+		return domain.FunctionLayer{}, fmt.Errorf("failed to create version of layer %s: %w", bl.Name, err)
+	}
+	layer.LatestVersion = lv.Version
+	if err := store.UpdateLayer(layer); err != nil {
+		return domain.FunctionLayer{}, fmt.Errorf("failed to update layer %s: %w", bl.Name, err)
+	}
+	return domain.FunctionLayer{LayerID: layer.ID, LayerName: layer.Name, LayerVersion: lv.Version, Order: bl.Order, ContentHash: hash}, nil
+}
+
+// bundleContentHash 计算层内容的 SHA-256 哈希（十六进制）
+func bundleContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeFunctionBundleZip 将导出包编码为 zip：bundle.json 保存导出包描述，层内容保存在 layers/ 下的单独文件中
+func encodeFunctionBundleZip(bundle *domain.FunctionBundle) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	manifest := *bundle
+	manifest.Layers = make([]domain.BundleLayer, len(bundle.Layers))
+	for i, bl := range bundle.Layers {
+		bl.ContentFile = fmt.Sprintf("layers/%d-%s-v%d.zip", i, path.Base(bl.Name), bl.Version)
+		f, err := zw.Create(bl.ContentFile)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(bl.Content); err != nil {
+			return nil, err
+		}
+		bl.Content = nil
+		manifest.Layers[i] = bl
+	}
+
+	f, err := zw.Create(functionBundleManifest)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeFunctionBundle 解析 JSON 或 zip 格式的导出包。
+// 不含 function 字段的 JSON 按旧版导出格式处理，整个对象即函数定义。
+func decodeFunctionBundle(data []byte) (*domain.FunctionBundle, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return decodeFunctionBundleZip(data)
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	if _, ok := probe["function"]; !ok {
+		var fn domain.Function
+		if err := json.Unmarshal(data, &fn); err != nil {
+			return nil, err
+		}
+		return &domain.FunctionBundle{Function: &fn}, nil
+	}
+
+	var bundle domain.FunctionBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+// decodeFunctionBundleZip 解析 zip 格式的导出包，从 content_file 指向的文件读取层内容
+func decodeFunctionBundleZip(data []byte) (*domain.FunctionBundle, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	readFile := func(name string) ([]byte, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%s not found in bundle", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxFunctionBundleSize))
+	}
+
+	manifest, err := readFile(functionBundleManifest)
+	if err != nil {
+		return nil, err
+	}
+	var bundle domain.FunctionBundle
+	if err := json.Unmarshal(manifest, &bundle); err != nil {
+		return nil, err
+	}
+	for i := range bundle.Layers {
+		bl := &bundle.Layers[i]
+		if bl.ContentFile == "" {
+			continue
+		}
+		if bl.Content, err = readFile(bl.ContentFile); err != nil {
+			return nil, err
+		}
+		bl.ContentFile = ""
+	}
+	return &bundle, nil
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// fakeBundleStore 是实现 functionBundleStore 的内存存储，层和环境是全局的，
+// 删除函数时与数据库一样级联删除其别名、版本、环境配置和层关联。
+type fakeBundleStore struct {
+	functions     map[string]*domain.Function
+	layers        map[string]*domain.Layer
+	layerVersions map[string][]*domain.LayerVersion
+	layerContent  map[string][]byte // layerID:version -> content
+	functionLayer map[string][]domain.FunctionLayer
+	aliases       map[string][]*domain.FunctionAlias
+	versions      map[string][]*domain.FunctionVersion
+	environments  map[string]*domain.Environment
+	envConfigs    map[string][]*domain.FunctionEnvConfig
+}
+
+func newFakeBundleStore(envNames ...string) *fakeBundleStore {
+	s := &fakeBundleStore{
+		functions:     map[string]*domain.Function{},
+		layers:        map[string]*domain.Layer{},
+		layerVersions: map[string][]*domain.LayerVersion{},
+		layerContent:  map[string][]byte{},
+		functionLayer: map[string][]domain.FunctionLayer{},
+		aliases:       map[string][]*domain.FunctionAlias{},
+		versions:      map[string][]*domain.FunctionVersion{},
+		environments:  map[string]*domain.Environment{},
+		envConfigs:    map[string][]*domain.FunctionEnvConfig{},
+	}
+	for _, name := range envNames {
+		s.environments[name] = &domain.Environment{ID: uuid.New().String(), Name: name}
+	}
+	return s
+}
+
+func (s *fakeBundleStore) GetFunctionByName(name string) (*domain.Function, error) {
+	for _, fn := range s.functions {
+		if fn.Name == name {
+			return fn, nil
+		}
+	}
+	return nil, domain.ErrFunctionNotFound
+}
+
+func (s *fakeBundleStore) CreateFunction(fn *domain.Function) error {
+	copied := *fn
+	s.functions[fn.ID] = &copied
+	return nil
+}
+
+func (s *fakeBundleStore) deleteFunction(id string) {
+	delete(s.functions, id)
+	delete(s.functionLayer, id)
+	delete(s.aliases, id)
+	delete(s.versions, id)
+	delete(s.envConfigs, id)
+}
+
+func (s *fakeBundleStore) GetFunctionLayers(functionID string) ([]domain.FunctionLayer, error) {
+	return s.functionLayer[functionID], nil
+}
+
+func (s *fakeBundleStore) SetFunctionLayers(functionID string, layers []domain.FunctionLayer) error {
+	s.functionLayer[functionID] = layers
+	return nil
+}
+
+func (s *fakeBundleStore) GetLayerByID(id string) (*domain.Layer, error) {
+	if l, ok := s.layers[id]; ok {
+		return l, nil
+	}
+	return nil, errors.New("layer not found")
+}
+
+func (s *fakeBundleStore) GetLayerByName(name string) (*domain.Layer, error) {
+	for _, l := range s.layers {
+		if l.Name == name {
+			return l, nil
+		}
+	}
+	return nil, errors.New("layer not found")
+}
+
+func (s *fakeBundleStore) CreateLayer(l *domain.Layer) error {
+	l.ID = uuid.New().String()
+	s.layers[l.ID] = l
+	return nil
+}
+
+func (s *fakeBundleStore) UpdateLayer(l *domain.Layer) error {
+	s.layers[l.ID] = l
+	return nil
+}
+
+func (s *fakeBundleStore) ListLayerVersions(layerID string) ([]*domain.LayerVersion, error) {
+	return s.layerVersions[layerID], nil
+}
+
+func (s *fakeBundleStore) CreateLayerVersion(lv *domain.LayerVersion, content []byte) error {
+	s.layerVersions[lv.LayerID] = append(s.layerVersions[lv.LayerID], lv)
+	s.layerContent[fmt.Sprintf("%s:%d", lv.LayerID, lv.Version)] = content
+	return nil
+}
+
+func (s *fakeBundleStore) GetLayerVersionContent(layerID string, version int) ([]byte, error) {
+	if c, ok := s.layerContent[fmt.Sprintf("%s:%d", layerID, version)]; ok {
+		return c, nil
+	}
+	return nil, errors.New("layer version not found")
+}
+
+func (s *fakeBundleStore) ListFunctionAliases(functionID string) ([]*domain.FunctionAlias, error) {
+	return s.aliases[functionID], nil
+}
+
+func (s *fakeBundleStore) CreateFunctionAlias(a *domain.FunctionAlias) error {
+	a.ID = uuid.New().String()
+	s.aliases[a.FunctionID] = append(s.aliases[a.FunctionID], a)
+	return nil
+}
+
+func (s *fakeBundleStore) ListFunctionVersions(functionID string) ([]*domain.FunctionVersion, error) {
+	return s.versions[functionID], nil
+}
+
+func (s *fakeBundleStore) CreateFunctionVersion(v *domain.FunctionVersion) error {
+	v.ID = uuid.New().String()
+	s.versions[v.FunctionID] = append(s.versions[v.FunctionID], v)
+	return nil
+}
+
+func (s *fakeBundleStore) ListFunctionEnvConfigs(functionID string) ([]*domain.FunctionEnvConfig, error) {
+	return s.envConfigs[functionID], nil
+}
+
+func (s *fakeBundleStore) GetEnvironmentByName(name string) (*domain.Environment, error) {
+	if e, ok := s.environments[name]; ok {
+		return e, nil
+	}
+	return nil, errors.New("environment not found")
+}
+
+func (s *fakeBundleStore) UpsertFunctionEnvConfig(cfg *domain.FunctionEnvConfig) error {
+	cfg.EnvironmentName = ""
+	for _, e := range s.environments {
+		if e.ID == cfg.EnvironmentID {
+			cfg.EnvironmentName = e.Name
+		}
+	}
+	s.envConfigs[cfg.FunctionID] = append(s.envConfigs[cfg.FunctionID], cfg)
+	return nil
+}
+
+// seedBundleFunction 创建一个带层、别名、多个版本和环境配置的函数
+func seedBundleFunction(t *testing.T, s *fakeBundleStore) *domain.Function {
+	t.Helper()
+	fn := &domain.Function{
+		ID: uuid.New().String(), Name: "orders", Runtime: domain.RuntimePython311, Handler: "handler.main",
+		Code: "def main(event):\n    return event", MemoryMB: 512, TimeoutSec: 10, Version: 3,
+		EnvVars: map[string]string{"LOG_LEVEL": "debug"}, Tags: []string{"billing"},
+		Status: domain.FunctionStatusActive, WebhookEnabled: true, WebhookKey: "secret-key",
+	}
+	s.CreateFunction(fn)
+
+	layer := &domain.Layer{Name: "requests", CompatibleRuntimes: []string{"python3.11"}}
+	s.CreateLayer(layer)
+	for v, content := range []string{"requests-v1", "requests-v2"} {
+		lv := &domain.LayerVersion{LayerID: layer.ID, Version: v + 1, ContentHash: bundleContentHash([]byte(content))}
+		s.CreateLayerVersion(lv, []byte(content))
+	}
+	layer.LatestVersion = 2
+	s.SetFunctionLayers(fn.ID, []domain.FunctionLayer{{LayerID: layer.ID, LayerName: layer.Name, LayerVersion: 1, Order: 0}})
+
+	for v := 1; v <= 3; v++ {
+		s.CreateFunctionVersion(&domain.FunctionVersion{FunctionID: fn.ID, Version: v, Handler: fn.Handler, Code: fn.Code, Description: "release"})
+	}
+	s.CreateFunctionAlias(&domain.FunctionAlias{FunctionID: fn.ID, Name: "prod", RoutingConfig: domain.RoutingConfig{
+		Weights: []domain.VersionWeight{{Version: 2, Weight: 90}, {Version: 3, Weight: 10}},
+	}})
+	memory := 1024
+	s.UpsertFunctionEnvConfig(&domain.FunctionEnvConfig{
+		FunctionID: fn.ID, EnvironmentID: s.environments["prod"].ID, EnvVars: map[string]string{"DB": "prod-db"}, MemoryMB: &memory, ActiveAlias: "prod",
+	})
+	return fn
+}
+
+// functionSnapshot 返回与实例无关的函数及其依赖，用于比较导入前后是否等价
+func functionSnapshot(t *testing.T, s *fakeBundleStore, fn *domain.Function) *domain.FunctionBundle {
+	t.Helper()
+	bundle, err := exportFunctionBundle(s, fn)
+	if err != nil {
+		t.Fatalf("exportFunctionBundle() error = %v", err)
+	}
+	bundle.ExportedAt = time.Time{}
+	return bundle
+}
+
+func TestFunctionBundleRoundTrip(t *testing.T) {
+	for _, format := range []string{"json", "zip"} {
+		t.Run(format, func(t *testing.T) {
+			store := newFakeBundleStore("prod")
+			fn := seedBundleFunction(t, store)
+			before := functionSnapshot(t, store, fn)
+
+			// 导出只包含最新版本和别名引用的版本
+			if got := len(before.Versions); got != 2 {
+				t.Errorf("exported %d versions, want 2 (latest and alias targets)", got)
+			}
+			if before.Function.ID != "" || before.Function.WebhookKey != "" || before.Function.Status != "" {
+				t.Errorf("exported function keeps instance fields: %+v", before.Function)
+			}
+
+			var data []byte
+			var err error
+			if format == "zip" {
+				data, err = encodeFunctionBundleZip(before)
+			} else {
+				data, err = json.Marshal(before)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.deleteFunction(fn.ID)
+
+			bundle, err := decodeFunctionBundle(data)
+			if err != nil {
+				t.Fatalf("decodeFunctionBundle() error = %v", err)
+			}
+			result, err := importFunctionBundle(store, bundle, functionImportOptions{TaskID: "task-1"})
+			if err != nil {
+				t.Fatalf("importFunctionBundle() error = %v", err)
+			}
+			imported := result.Function
+			if imported.ID == fn.ID || imported.ID == "" {
+				t.Errorf("imported function ID = %q, want a new ID", imported.ID)
+			}
+			if imported.Status != domain.FunctionStatusCreating || imported.TaskID != "task-1" {
+				t.Errorf("imported function status = %s task = %s", imported.Status, imported.TaskID)
+			}
+			if imported.WebhookKey == "" || imported.WebhookKey == "secret-key" {
+				t.Errorf("imported webhook key = %q, want a newly generated key", imported.WebhookKey)
+			}
+			// 层内容相同，复用已有的层版本而不是创建新版本
+			if n := len(store.layerVersions[result.Layers[0].LayerID]); n != 2 || result.Layers[0].LayerVersion != 1 {
+				t.Errorf("layer versions = %d, imported layer version = %d, want reuse of version 1", n, result.Layers[0].LayerVersion)
+			}
+
+			after := functionSnapshot(t, store, imported)
+			if !reflect.DeepEqual(before, after) {
+				t.Errorf("round trip mismatch:\nbefore: %s\nafter:  %s", mustJSON(before), mustJSON(after))
+			}
+		})
+	}
+}
+
+func TestImportFunctionBundleIntoNewInstance(t *testing.T) {
+	source := newFakeBundleStore("prod")
+	fn := seedBundleFunction(t, source)
+	bundle, err := exportFunctionBundle(source, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 目标实例已有同名层（内容不同）和同名函数，没有 prod 环境
+	target := newFakeBundleStore("staging")
+	existing := &domain.Layer{Name: "requests"}
+	target.CreateLayer(existing)
+	target.CreateLayerVersion(&domain.LayerVersion{LayerID: existing.ID, Version: 1, ContentHash: bundleContentHash([]byte("other"))}, []byte("other"))
+	existing.LatestVersion = 1
+	target.CreateFunction(&domain.Function{ID: uuid.New().String(), Name: "orders"})
+
+	if _, err := importFunctionBundle(target, bundle, functionImportOptions{}); !errors.Is(err, domain.ErrFunctionExists) {
+		t.Fatalf("import with name conflict error = %v, want ErrFunctionExists", err)
+	}
+
+	result, err := importFunctionBundle(target, bundle, functionImportOptions{Rename: true})
+	if err != nil {
+		t.Fatalf("import with rename error = %v", err)
+	}
+	if result.Function.Name != "orders-imported" || !result.Renamed {
+		t.Errorf("imported name = %s renamed = %v, want orders-imported", result.Function.Name, result.Renamed)
+	}
+	if fl := result.Layers[0]; fl.LayerID != existing.ID || fl.LayerVersion != 2 {
+		t.Errorf("imported layer = %+v, want new version 2 of the existing layer", fl)
+	}
+	if content, _ := target.GetLayerVersionContent(existing.ID, 2); string(content) != "requests-v1" {
+		t.Errorf("imported layer content = %q", content)
+	}
+	if result.Aliases != 1 || result.Versions != 2 || result.EnvConfigs != 0 || len(result.Warnings) != 1 {
+		t.Errorf("result = %+v, want 1 alias, 2 versions and a warning for the missing environment", result)
+	}
+
+	if result, err := importFunctionBundle(target, bundle, functionImportOptions{Name: "orders-copy"}); err != nil || result.Function.Name != "orders-copy" {
+		t.Errorf("import with explicit name = %v, %v", result, err)
+	}
+}
+
+func TestDecodeFunctionBundleLegacyFormat(t *testing.T) {
+	bundle, err := decodeFunctionBundle([]byte(`{"name":"hello","runtime":"python3.11","handler":"main.handler","code":"x","memory_mb":128}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Function == nil || bundle.Function.Name != "hello" || bundle.Function.MemoryMB != 128 {
+		t.Errorf("legacy bundle function = %+v", bundle.Function)
+	}
+
+	tampered := &domain.FunctionBundle{
+		Function: &domain.Function{Name: "hello", Runtime: domain.RuntimePython311, Handler: "h", Code: "x"},
+		Layers:   []domain.BundleLayer{{Name: "l", Content: []byte("a"), ContentHash: bundleContentHash([]byte("b"))}},
+	}
+	if _, err := importFunctionBundle(newFakeBundleStore(), tampered, functionImportOptions{}); !errors.Is(err, errInvalidFunctionBundle) {
+		t.Errorf("import with mismatched layer hash error = %v, want errInvalidFunctionBundle", err)
+	}
+}
+
+func mustJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
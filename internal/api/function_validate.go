@@ -0,0 +1,201 @@
+// Package api 提供了函数即服务(FaaS)平台的HTTP API处理程序。
+// 本文件实现函数定义预检（dry-run）：按创建/更新的规则校验函数定义并检查配额，不写入任何数据。
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// ValidateFunction 预检函数定义，返回将要写入的函数定义、校验错误和警告，不保存任何数据。
+// HTTP端点: POST /api/v1/functions/validate
+//
+// 功能说明：
+//   - 按创建函数的规则校验请求体，补全内存、超时等默认值
+//   - 查询参数 function 指定已有函数时按更新预检：请求体中为零值的字段沿用原函数，
+//     名称和运行时必须与该函数一致，配额按替换该函数计算
+//   - 超出配额只作为警告返回，不影响 valid
+//
+// 请求体：domain.CreateFunctionRequest，按更新预检时只需提供要修改的字段
+//
+// 查询参数：
+//   - function: 可选，被更新函数的 ID 或名称
+//
+// 返回值：
+//   - 200: 返回 domain.FunctionValidationResult，校验失败时 valid 为 false 且 errors 非空
+//   - 400: 请求体无法解析
+//   - 404: function 指定的函数不存在
+func (h *Handler) ValidateFunction(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreateFunctionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var existing *domain.Function
+	if ref := r.URL.Query().Get("function"); ref != "" {
+		fn, err := h.store.GetFunctionByID(ref)
+		if err == domain.ErrFunctionNotFound {
+			fn, err = h.store.GetFunctionByName(ref)
+		}
+		if err == domain.ErrFunctionNotFound {
+			writeErrorWithContext(w, r, http.StatusNotFound, "function not found: "+ref)
+			return
+		}
+		if err != nil {
+			h.logError(r, "ValidateFunction", "查询函数失败", err, logrus.Fields{"function": ref})
+			writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get function: "+err.Error())
+			return
+		}
+		existing = fn
+		fillUpdateFromFunction(&req, existing)
+	}
+
+	writeJSON(w, http.StatusOK, h.validateFunctionDefinition(&req, existing))
+}
+
+// validateFunctionDefinition 校验函数定义并检查配额，existing 不为 nil 时按更新该函数校验
+func (h *Handler) validateFunctionDefinition(req *domain.CreateFunctionRequest, existing *domain.Function) *domain.FunctionValidationResult {
+	result := &domain.FunctionValidationResult{}
+	if err := req.Validate(); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	if err := h.validateNodeSelector(req.NodeSelector); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	hash := sha256.Sum256([]byte(req.Code))
+	fn := newFunctionFromRequest(req, hex.EncodeToString(hash[:]))
+
+	// 配额变化量：新建时增加一个函数，更新时只计算与原函数的差值
+	addFunctions, addMemoryMB, addCodeSizeKB := 1, fn.MemoryMB, int64(len(fn.Code)/1024)
+	if existing == nil {
+		if other, _ := h.store.GetFunctionByName(req.Name); other != nil {
+			result.Errors = append(result.Errors, "function with this name already exists")
+		}
+		fn.Status = domain.FunctionStatusCreating
+		fn.Version = 1
+	} else {
+		if req.Name != existing.Name {
+			result.Errors = append(result.Errors, "function name cannot be changed")
+		}
+		if req.Runtime != existing.Runtime {
+			result.Errors = append(result.Errors, "function runtime cannot be changed")
+		}
+		fn.ID = existing.ID
+		fn.Status = existing.Status
+		fn.Version = existing.Version + 1
+		fn.Pinned = existing.Pinned
+		fn.OwnerID = existing.OwnerID
+		fn.WebhookEnabled = existing.WebhookEnabled
+		fn.StateConfig = existing.StateConfig
+		fn.CreatedAt = existing.CreatedAt
+		addFunctions = 0
+		addMemoryMB -= existing.MemoryMB
+		addCodeSizeKB -= int64(len(existing.Code) / 1024)
+	}
+	if err := h.store.CheckQuota(addFunctions, addMemoryMB, addCodeSizeKB); err != nil {
+		result.Warnings = append(result.Warnings, err.Error())
+	}
+
+	result.Valid = len(result.Errors) == 0
+	result.Function = fn
+	return result
+}
+
+// fillUpdateFromFunction 将更新预检请求中为零值的字段补全为已有函数的当前值
+func fillUpdateFromFunction(req *domain.CreateFunctionRequest, fn *domain.Function) {
+	if req.Name == "" {
+		req.Name = fn.Name
+	}
+	if req.Runtime == "" {
+		req.Runtime = fn.Runtime
+	}
+	if req.Description == "" {
+		req.Description = fn.Description
+	}
+	if req.Tags == nil {
+		req.Tags = fn.Tags
+	}
+	if req.Handler == "" {
+		req.Handler = fn.Handler
+	}
+	if req.Code == "" {
+		req.Code = fn.Code
+		if req.Binary == "" {
+			req.Binary = fn.Binary
+		}
+	}
+	if req.MemoryMB == 0 {
+		req.MemoryMB = fn.MemoryMB
+	}
+	if req.TimeoutSec == 0 {
+		req.TimeoutSec = fn.TimeoutSec
+	}
+	if req.MaxConcurrency == 0 {
+		req.MaxConcurrency = fn.MaxConcurrency
+	}
+	if req.EnvVars == nil {
+		req.EnvVars = fn.EnvVars
+	}
+	if req.CronExpression == "" {
+		req.CronExpression = fn.CronExpression
+	}
+	if req.CronTimezone == "" {
+		req.CronTimezone = fn.CronTimezone
+	}
+	if req.HTTPPath == "" {
+		req.HTTPPath = fn.HTTPPath
+	}
+	if req.HTTPMethods == nil {
+		req.HTTPMethods = fn.HTTPMethods
+	}
+	if req.NodeSelector == nil {
+		req.NodeSelector = fn.NodeSelector
+	}
+	if req.WarmupStrategy == "" {
+		req.WarmupStrategy = fn.WarmupStrategy
+		req.WarmupSchedule = fn.WarmupSchedule
+	}
+	if req.MaxInvocationRecords == 0 {
+		req.MaxInvocationRecords = fn.MaxInvocationRecords
+	}
+	if req.SnapshotTTLHours == 0 {
+		req.SnapshotTTLHours = fn.SnapshotTTLHours
+	}
+	if req.CacheTTLSec == 0 {
+		req.CacheTTLSec = fn.CacheTTLSec
+	}
+	if req.WarmupHandler == "" {
+		req.WarmupHandler = fn.WarmupHandler
+	}
+	if req.WarmupTimeoutSec == 0 {
+		req.WarmupTimeoutSec = fn.WarmupTimeoutSec
+	}
+	if !req.InstallDeps {
+		req.InstallDeps = fn.InstallDeps
+	}
+	if req.InstallDepsTimeoutSec == 0 {
+		req.InstallDepsTimeoutSec = fn.InstallDepsTimeoutSec
+	}
+	if req.IOLimits == nil {
+		req.IOLimits = fn.IOLimits
+	}
+	if req.InputSchema == nil {
+		req.InputSchema = fn.InputSchema
+	}
+	if req.RateLimit == nil {
+		req.RateLimit = fn.RateLimit
+	}
+	if !req.PropagateIdentity {
+		req.PropagateIdentity = fn.PropagateIdentity
+	}
+	if !req.Cacheable {
+		req.Cacheable = fn.Cacheable
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
+	"github.com/oriys/nimbus/internal/auth"
 	"github.com/oriys/nimbus/internal/compiler"
 	"github.com/oriys/nimbus/internal/domain"
 	"github.com/oriys/nimbus/internal/scheduler"
@@ -40,6 +41,7 @@ import (
 //   - scheduler: 函数调度器接口，负责函数的实际执行调度
 //   - compiler: 代码编译器，用于编译Go/Rust源代码
 //   - cronManager: 定时任务管理器，负责管理函数的定时触发
+//   - retention: 保留策略管理器，负责执行数据清理
 //   - logger: 日志记录器，用于记录调试和错误信息
 type Handler struct {
 	store       *storage.PostgresStore
@@ -47,7 +49,11 @@ type Handler struct {
 	scheduler   Scheduler
 	compiler    *compiler.Compiler
 	cronManager *scheduler.CronManager
+	retention   *scheduler.RetentionManager
 	logger      *logrus.Logger
+
+	inputSchemas inputSchemaCache // 函数输入 schema 的编译缓存
+	isLeader     func() bool      // 当前节点是否为主节点，未设置时健康检查不返回 leader 字段
 }
 
 // Scheduler 定义了函数调度器的接口。
@@ -68,6 +74,20 @@ type Scheduler interface {
 	InvokeAsync(req *domain.InvokeRequest) (string, error)
 }
 
+// ActiveInvocationLister 是调度器的可选接口，用于列出正在执行的调用。
+// Firecracker 调度器和 Docker 调度器均实现了该接口。
+type ActiveInvocationLister interface {
+	// ListActiveInvocations 返回当前正在执行的调用列表
+	ListActiveInvocations() ([]domain.ActiveInvocation, error)
+}
+
+// InvocationCanceller 是调度器的可选接口，用于取消正在执行的调用。
+// Firecracker 调度器和 Docker 调度器均实现了该接口。
+type InvocationCanceller interface {
+	// CancelInvocation 取消正在本节点执行的调用，调用不存在时返回 false
+	CancelInvocation(invocationID string) bool
+}
+
 // NewHandler 创建并返回一个新的Handler实例。
 //
 // 参数：
@@ -75,17 +95,19 @@ type Scheduler interface {
 //   - redis: Redis存储实例，用于缓存操作
 //   - scheduler: 函数调度器实例，用于执行函数调用
 //   - cronManager: 定时任务管理器实例
+//   - retention: 保留策略管理器实例
 //   - logger: 日志记录器实例，用于记录调试信息
 //
 // 返回值：
 //   - *Handler: 初始化完成的处理器实例
-func NewHandler(store *storage.PostgresStore, redis *storage.RedisStore, scheduler Scheduler, cronManager *scheduler.CronManager, logger *logrus.Logger) *Handler {
+func NewHandler(store *storage.PostgresStore, redis *storage.RedisStore, scheduler Scheduler, cronManager *scheduler.CronManager, retention *scheduler.RetentionManager, logger *logrus.Logger) *Handler {
 	return &Handler{
 		store:       store,
 		redis:       redis,
 		scheduler:   scheduler,
 		compiler:    compiler.NewCompiler(),
 		cronManager: cronManager,
+		retention:   retention,
 		logger:      logger,
 	}
 }
@@ -152,6 +174,7 @@ func (h *Handler) RecoverPendingCompileTasks() {
 //
 // 功能说明：
 //   - 解析并验证请求体中的函数配置
+//   - 携带 Idempotency-Key 的重复请求返回首次创建的函数
 //   - 检查函数名称是否已存在（防止重复）
 //   - 计算代码的SHA256哈希值用于版本控制
 //   - 异步创建函数，立即返回带有任务ID的响应
@@ -183,6 +206,19 @@ func (h *Handler) CreateFunction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 校验节点选择器至少能匹配一个已注册节点
+	if err := h.validateNodeSelector(req.NodeSelector); err != nil {
+		h.logWarn(r, "CreateFunction", "节点选择器无效", logrus.Fields{"name": req.Name, "node_selector": req.NodeSelector})
+		writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 携带幂等键的重复请求（如客户端重试）返回首次创建的函数，不再报名称冲突
+	idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+	if h.writeIdempotentCreate(w, r, h.store, idempotencyKey, req.Name) {
+		return
+	}
+
 	// 检查是否存在同名函数，防止重复创建
 	existing, _ := h.store.GetFunctionByName(req.Name)
 	if existing != nil {
@@ -199,26 +235,15 @@ func (h *Handler) CreateFunction(w http.ResponseWriter, r *http.Request) {
 	taskID := uuid.New().String()
 
 	// 构建函数对象，初始状态为 creating
-	fn := &domain.Function{
-		Name:           req.Name,
-		Description:    req.Description,
-		Tags:           req.Tags,
-		Runtime:        req.Runtime,
-		Handler:        req.Handler,
-		Code:           req.Code,
-		Binary:         req.Binary,
-		CodeHash:       codeHash,
-		MemoryMB:       req.MemoryMB,
-		TimeoutSec:     req.TimeoutSec,
-		MaxConcurrency: req.MaxConcurrency,
-		EnvVars:        req.EnvVars,
-		CronExpression: req.CronExpression,
-		HTTPPath:       req.HTTPPath,
-		HTTPMethods:    req.HTTPMethods,
-		Status:         domain.FunctionStatusCreating,
-		StatusMessage:  "函数正在创建中",
-		TaskID:         taskID,
-		Version:        1,
+	fn := newFunctionFromRequest(&req, codeHash)
+	fn.Status = domain.FunctionStatusCreating
+	fn.StatusMessage = "函数正在创建中"
+	fn.TaskID = taskID
+	fn.Version = 1
+	fn.IdempotencyKey = idempotencyKey
+	// 认证用户创建的函数归属于该用户
+	if user := auth.GetUser(r.Context()); user != nil {
+		fn.OwnerID = user.UserID
 	}
 
 	// 保存函数到数据库（状态为 creating）
@@ -254,6 +279,93 @@ func (h *Handler) CreateFunction(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, fn)
 }
 
+// IdempotencyKeyHeader 是创建函数时携带幂等键的请求头，客户端重试同一请求时保持不变
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength 是幂等键的最大长度，与 functions.idempotency_key 列一致
+const maxIdempotencyKeyLength = 128
+
+// idempotentFunctionGetter 是按幂等键查找已创建函数所需的存储方法，*storage.PostgresStore 实现了该接口
+type idempotentFunctionGetter interface {
+	GetFunctionByIdempotencyKey(key string) (*domain.Function, error)
+}
+
+// writeIdempotentCreate 处理携带幂等键的创建请求，store 单独传入以便测试。
+// 幂等键已创建过同名函数时返回该函数；已用于其他名称的函数时返回 422。
+// 返回 true 表示已写入响应，调用方不应再创建函数。
+func (h *Handler) writeIdempotentCreate(w http.ResponseWriter, r *http.Request, store idempotentFunctionGetter, key, name string) bool {
+	if key == "" {
+		return false
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		writeErrorWithContext(w, r, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength))
+		return true
+	}
+
+	fn, err := store.GetFunctionByIdempotencyKey(key)
+	if err == domain.ErrFunctionNotFound {
+		return false
+	}
+	if err != nil {
+		h.logError(r, "CreateFunction", "查询幂等键失败", err, logrus.Fields{"name": name})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to check idempotency key")
+		return true
+	}
+	if fn.Name != name {
+		writeErrorWithContext(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was already used to create a different function")
+		return true
+	}
+
+	h.logInfo(r, "CreateFunction", "重复的创建请求，返回已创建的函数", logrus.Fields{"name": fn.Name, "id": fn.ID})
+	writeJSON(w, http.StatusOK, fn)
+	return true
+}
+
+// newFunctionFromRequest 根据创建请求构建函数对象，不设置状态、任务和版本号
+func newFunctionFromRequest(req *domain.CreateFunctionRequest, codeHash string) *domain.Function {
+	return &domain.Function{
+		Name:                  req.Name,
+		Description:           req.Description,
+		Tags:                  req.Tags,
+		Runtime:               req.Runtime,
+		Handler:               req.Handler,
+		Code:                  req.Code,
+		Binary:                req.Binary,
+		CodeHash:              codeHash,
+		MemoryMB:              req.MemoryMB,
+		TimeoutSec:            req.TimeoutSec,
+		MaxConcurrency:        req.MaxConcurrency,
+		EnvVars:               req.EnvVars,
+		CronExpression:        req.CronExpression,
+		CronTimezone:          req.CronTimezone,
+		HTTPPath:              req.HTTPPath,
+		HTTPMethods:           req.HTTPMethods,
+		NodeSelector:          req.NodeSelector,
+		WarmupStrategy:        req.WarmupStrategy,
+		WarmupSchedule:        req.WarmupSchedule,
+		MaxInvocationRecords:  req.MaxInvocationRecords,
+		PropagateIdentity:     req.PropagateIdentity,
+		SnapshotTTLHours:      req.SnapshotTTLHours,
+		IOLimits:              req.IOLimits,
+		InputSchema:           normalizeInputSchema(req.InputSchema),
+		RateLimit:             req.RateLimit,
+		Cacheable:             req.Cacheable,
+		CacheTTLSec:           req.CacheTTLSec,
+		WarmupHandler:         req.WarmupHandler,
+		WarmupTimeoutSec:      req.WarmupTimeoutSec,
+		InstallDeps:           req.InstallDeps,
+		InstallDepsTimeoutSec: req.InstallDepsTimeoutSec,
+	}
+}
+
+// normalizeInputSchema 将未设置的输入 schema（空值或 null）统一为 nil
+func normalizeInputSchema(schema json.RawMessage) json.RawMessage {
+	if !domain.HasInputSchema(schema) {
+		return nil
+	}
+	return schema
+}
+
 // processCreateFunctionTask 异步处理函数创建任务
 // 流程：源代码已在 CreateFunction 中保存 → 编译 → 更新二进制和状态
 func (h *Handler) processCreateFunctionTask(functionID, taskID string) {
@@ -318,12 +430,12 @@ func (h *Handler) processCreateFunctionTask(functionID, taskID string) {
 		return
 	}
 
-	// 同步定时任务
-	if h.cronManager != nil && fn.CronExpression != "" {
+	// 同步定时任务，并按 on_deploy 策略预热
+	if h.cronManager != nil {
 		// 重新获取最新的函数信息
 		latestFn, _ := h.store.GetFunctionByID(functionID)
 		if latestFn != nil {
-			h.cronManager.AddOrUpdateFunction(latestFn)
+			h.cronManager.OnFunctionDeployed(latestFn)
 		}
 	}
 
@@ -405,34 +517,47 @@ func (h *Handler) GetFunction(w http.ResponseWriter, r *http.Request) {
 
 	// 构建响应，包含代码大小信息
 	response := map[string]interface{}{
-		"id":              fn.ID,
-		"name":            fn.Name,
-		"description":     fn.Description,
-		"tags":            fn.Tags,
-		"pinned":          fn.Pinned,
-		"runtime":         fn.Runtime,
-		"handler":         fn.Handler,
-		"code":            fn.Code,
-		"binary":          fn.Binary,
-		"code_hash":       fn.CodeHash,
-		"memory_mb":       fn.MemoryMB,
-		"timeout_sec":     fn.TimeoutSec,
-		"max_concurrency": fn.MaxConcurrency,
-		"env_vars":        fn.EnvVars,
-		"status":          fn.Status,
-		"status_message":  fn.StatusMessage,
-		"task_id":         fn.TaskID,
-		"version":         fn.Version,
-		"cron_expression": fn.CronExpression,
-		"http_path":       fn.HTTPPath,
-		"http_methods":    fn.HTTPMethods,
-		"webhook_enabled": fn.WebhookEnabled,
-		"webhook_key":     fn.WebhookKey,
-		"last_deployed_at": fn.LastDeployedAt,
-		"created_at":      fn.CreatedAt,
-		"updated_at":      fn.UpdatedAt,
-		"code_size":       len(fn.Code),
-		"code_size_limit": domain.MaxCodeSize,
+		"id":                       fn.ID,
+		"name":                     fn.Name,
+		"description":              fn.Description,
+		"tags":                     fn.Tags,
+		"pinned":                   fn.Pinned,
+		"runtime":                  fn.Runtime,
+		"handler":                  fn.Handler,
+		"code":                     fn.Code,
+		"binary":                   fn.Binary,
+		"code_hash":                fn.CodeHash,
+		"memory_mb":                fn.MemoryMB,
+		"timeout_sec":              fn.TimeoutSec,
+		"max_concurrency":          fn.MaxConcurrency,
+		"env_vars":                 fn.EnvVars,
+		"status":                   fn.Status,
+		"status_message":           fn.StatusMessage,
+		"task_id":                  fn.TaskID,
+		"version":                  fn.Version,
+		"cron_expression":          fn.CronExpression,
+		"cron_timezone":            fn.CronTimezone,
+		"http_path":                fn.HTTPPath,
+		"http_methods":             fn.HTTPMethods,
+		"webhook_enabled":          fn.WebhookEnabled,
+		"webhook_key":              fn.WebhookKey,
+		"last_deployed_at":         fn.LastDeployedAt,
+		"max_invocation_records":   fn.MaxInvocationRecords,
+		"propagate_identity":       fn.PropagateIdentity,
+		"snapshot_ttl_hours":       fn.SnapshotTTLHours,
+		"io_limits":                fn.IOLimits,
+		"input_schema":             fn.InputSchema,
+		"rate_limit":               fn.RateLimit,
+		"cacheable":                fn.Cacheable,
+		"cache_ttl_sec":            fn.CacheTTLSec,
+		"warmup_handler":           fn.WarmupHandler,
+		"warmup_timeout_sec":       fn.WarmupTimeoutSec,
+		"install_deps":             fn.InstallDeps,
+		"install_deps_timeout_sec": fn.InstallDepsTimeoutSec,
+		"created_at":               fn.CreatedAt,
+		"updated_at":               fn.UpdatedAt,
+		"code_size":                len(fn.Code),
+		"code_size_limit":          domain.MaxCodeSize,
 	}
 	writeJSON(w, http.StatusOK, response)
 }
@@ -447,6 +572,7 @@ func (h *Handler) GetFunction(w http.ResponseWriter, r *http.Request) {
 // 查询参数：
 //   - offset: 偏移量，跳过前N条记录（默认0）
 //   - limit: 每页数量，范围1-100（默认20）
+//   - owner: 所有者用户ID，传 me 表示当前认证用户（可选，指定后忽略其他筛选条件）
 //
 // 返回值：
 //   - functions: 函数列表
@@ -488,7 +614,16 @@ func (h *Handler) ListFunctions(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	// 根据是否有筛选条件选择不同的查询方法
-	if hasFilter {
+	// owner=me 表示当前认证用户，用于"我的函数"视图
+	owner := r.URL.Query().Get("owner")
+	if owner == "me" {
+		if user := auth.GetUser(r.Context()); user != nil {
+			owner = user.UserID
+		}
+	}
+	if owner != "" {
+		functions, total, err = h.store.ListFunctionsByOwner(owner, offset, limit)
+	} else if hasFilter {
 		functions, total, err = h.store.ListFunctionsWithFilter(filter, offset, limit)
 	} else {
 		functions, total, err = h.store.ListFunctions(offset, limit)
@@ -655,12 +790,127 @@ func (h *Handler) UpdateFunction(w http.ResponseWriter, r *http.Request) {
 		}
 		fn.CronExpression = *req.CronExpression
 	}
+	if req.CronTimezone != nil {
+		fn.CronTimezone = *req.CronTimezone
+	}
+	if req.CronExpression != nil || req.CronTimezone != nil {
+		if err := domain.ValidateCronTimezone(fn.CronExpression, fn.CronTimezone); err != nil {
+			writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 	if req.HTTPPath != nil {
 		fn.HTTPPath = *req.HTTPPath
 	}
 	if req.HTTPMethods != nil {
 		fn.HTTPMethods = *req.HTTPMethods
 	}
+	if req.NodeSelector != nil {
+		if err := h.validateNodeSelector(*req.NodeSelector); err != nil {
+			h.logWarn(r, "UpdateFunction", "节点选择器无效", logrus.Fields{"function": fn.Name, "node_selector": *req.NodeSelector})
+			writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		fn.NodeSelector = *req.NodeSelector
+	}
+	if req.WarmupStrategy != nil || req.WarmupSchedule != nil {
+		strategy, schedule := fn.WarmupStrategy, fn.WarmupSchedule
+		if req.WarmupStrategy != nil {
+			strategy = *req.WarmupStrategy
+		}
+		if req.WarmupSchedule != nil {
+			schedule = *req.WarmupSchedule
+		}
+		if err := domain.ValidateWarmup(strategy, schedule); err != nil {
+			writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		fn.WarmupStrategy, fn.WarmupSchedule = strategy, schedule
+	}
+	if req.MaxInvocationRecords != nil {
+		if *req.MaxInvocationRecords < 0 {
+			writeErrorWithContext(w, r, http.StatusBadRequest, domain.ErrInvalidMaxInvocationRecords.Error())
+			return
+		}
+		fn.MaxInvocationRecords = *req.MaxInvocationRecords
+	}
+	if req.PropagateIdentity != nil {
+		fn.PropagateIdentity = *req.PropagateIdentity
+	}
+	if req.SnapshotTTLHours != nil {
+		if *req.SnapshotTTLHours < 0 {
+			writeErrorWithContext(w, r, http.StatusBadRequest, domain.ErrInvalidSnapshotTTL.Error())
+			return
+		}
+		fn.SnapshotTTLHours = *req.SnapshotTTLHours
+	}
+	if req.Cacheable != nil {
+		fn.Cacheable = *req.Cacheable
+	}
+	if req.CacheTTLSec != nil {
+		if *req.CacheTTLSec < 0 {
+			writeErrorWithContext(w, r, http.StatusBadRequest, domain.ErrInvalidCacheTTL.Error())
+			return
+		}
+		fn.CacheTTLSec = *req.CacheTTLSec
+	}
+	if req.WarmupHandler != nil || req.WarmupTimeoutSec != nil {
+		warmupHandler, warmupTimeoutSec := fn.WarmupHandler, fn.WarmupTimeoutSec
+		if req.WarmupHandler != nil {
+			warmupHandler = *req.WarmupHandler
+		}
+		if req.WarmupTimeoutSec != nil {
+			warmupTimeoutSec = *req.WarmupTimeoutSec
+		}
+		if err := domain.ValidateWarmupHandler(fn.Runtime, warmupHandler, warmupTimeoutSec); err != nil {
+			writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		fn.WarmupHandler, fn.WarmupTimeoutSec = warmupHandler, warmupTimeoutSec
+	}
+	if req.InstallDeps != nil || req.InstallDepsTimeoutSec != nil {
+		installDeps, installDepsTimeoutSec := fn.InstallDeps, fn.InstallDepsTimeoutSec
+		if req.InstallDeps != nil {
+			installDeps = *req.InstallDeps
+		}
+		if req.InstallDepsTimeoutSec != nil {
+			installDepsTimeoutSec = *req.InstallDepsTimeoutSec
+		}
+		if err := domain.ValidateInstallDeps(fn.Runtime, installDeps, installDepsTimeoutSec); err != nil {
+			writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		fn.InstallDeps, fn.InstallDepsTimeoutSec = installDeps, installDepsTimeoutSec
+	}
+	if req.IOLimits != nil {
+		if err := req.IOLimits.Validate(); err != nil {
+			writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.IOLimits.IsZero() {
+			fn.IOLimits = nil
+		} else {
+			fn.IOLimits = req.IOLimits
+		}
+	}
+	if req.InputSchema != nil {
+		if err := domain.ValidateInputSchema(req.InputSchema); err != nil {
+			writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		fn.InputSchema = normalizeInputSchema(req.InputSchema)
+	}
+	if req.RateLimit != nil {
+		if err := req.RateLimit.Validate(); err != nil {
+			writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.RateLimit.IsZero() {
+			fn.RateLimit = nil
+		} else {
+			fn.RateLimit = req.RateLimit
+		}
+	}
 
 	// 如果代码更新且是需要编译的运行时，异步处理
 	if needRecompile && compiler.IsSourceCode(string(fn.Runtime), fn.Code) {
@@ -742,6 +992,24 @@ func (h *Handler) UpdateFunction(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, fn)
 }
 
+// validateNodeSelector 校验节点选择器至少能匹配一个已注册节点的标签。
+// 选择器为空表示任意节点，无需校验。
+func (h *Handler) validateNodeSelector(selector map[string]string) error {
+	if len(selector) == 0 {
+		return nil
+	}
+	nodes, err := h.store.ListNodes()
+	if err != nil {
+		return fmt.Errorf("failed to validate node selector: %w", err)
+	}
+	for _, node := range nodes {
+		if domain.MatchesNodeSelector(selector, node.Labels) {
+			return nil
+		}
+	}
+	return fmt.Errorf("node selector %v does not match any known node", selector)
+}
+
 // processUpdateFunctionTask 异步处理函数更新任务
 // 流程：源代码已在 UpdateFunction 中保存 → 编译 → 更新二进制和状态
 func (h *Handler) processUpdateFunctionTask(functionID, taskID string) {
@@ -815,12 +1083,12 @@ func (h *Handler) processUpdateFunctionTask(functionID, taskID string) {
 		return
 	}
 
-	// 同步定时任务
+	// 同步定时任务，并按 on_deploy 策略预热
 	if h.cronManager != nil {
 		// 重新获取最新的函数信息
 		latestFn, _ := h.store.GetFunctionByID(functionID)
 		if latestFn != nil {
-			h.cronManager.AddOrUpdateFunction(latestFn)
+			h.cronManager.OnFunctionDeployed(latestFn)
 		}
 	}
 
@@ -1081,6 +1349,125 @@ func (h *Handler) BulkUpdateFunctions(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, result)
 }
 
+// BulkUpdateFunctionStatus 使用一条语句批量更新函数状态。
+// HTTP端点: POST /api/v1/functions/bulk-status
+//
+// 功能说明：
+//   - 供发布编排任务将一批函数切换到 updating，完成后再切回 active
+//   - 不逐个校验状态流转，只返回实际更新的数量
+//   - 整个批次只记录一条汇总审计日志
+func (h *Handler) BulkUpdateFunctionStatus(w http.ResponseWriter, r *http.Request) {
+	var req domain.BulkStatusUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logError(r, "BulkUpdateFunctionStatus", "解析请求体失败", err, nil)
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "ids is required and cannot be empty")
+		return
+	}
+	if !req.Status.IsValid() {
+		writeErrorWithContext(w, r, http.StatusBadRequest, fmt.Sprintf("invalid status: %s", req.Status))
+		return
+	}
+
+	updated, err := h.store.BulkUpdateFunctionStatus(req.IDs, req.Status, req.Message)
+	if err != nil {
+		h.logError(r, "BulkUpdateFunctionStatus", "批量更新函数状态失败", err, logrus.Fields{"count": len(req.IDs)})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.auditLog(r, "function.bulk_status_update", "function", "", "", map[string]interface{}{
+		"ids":       req.IDs,
+		"status":    req.Status,
+		"message":   req.Message,
+		"requested": len(req.IDs),
+		"updated":   updated,
+	})
+	h.logInfo(r, "BulkUpdateFunctionStatus", "批量更新函数状态完成", logrus.Fields{
+		"status":    req.Status,
+		"requested": len(req.IDs),
+		"updated":   updated,
+	})
+	writeJSON(w, http.StatusOK, domain.BulkStatusUpdateResult{
+		Requested: len(req.IDs),
+		Updated:   updated,
+	})
+}
+
+// TransferFunctionOwnership 将函数转移给新的所有者。
+// HTTP端点: POST /api/v1/functions/{id}/transfer
+//
+// 功能说明：
+//   - 只修改所有者，不影响函数配置和版本号
+//   - 审计日志同时记录原所有者和新所有者
+//
+// 路径参数：
+//   - id: 函数ID或名称
+//
+// 请求体：domain.TransferOwnershipRequest (JSON)
+func (h *Handler) TransferFunctionOwnership(w http.ResponseWriter, r *http.Request) {
+	idOrName := chi.URLParam(r, "id")
+	if idOrName == "" {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "function id or name required")
+		return
+	}
+
+	var req domain.TransferOwnershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logError(r, "TransferFunctionOwnership", "解析请求体失败", err, nil)
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	req.OwnerID = strings.TrimSpace(req.OwnerID)
+	if req.OwnerID == "" {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "owner_id is required")
+		return
+	}
+
+	fn, err := h.store.GetFunctionByID(idOrName)
+	if err == domain.ErrFunctionNotFound {
+		fn, err = h.store.GetFunctionByName(idOrName)
+	}
+	if err == domain.ErrFunctionNotFound {
+		writeErrorWithContext(w, r, http.StatusNotFound, "function not found: "+idOrName)
+		return
+	}
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get function: "+err.Error())
+		return
+	}
+
+	oldOwnerID, err := h.store.TransferFunctionOwnership(fn.ID, req.OwnerID)
+	if err == domain.ErrFunctionNotFound {
+		writeErrorWithContext(w, r, http.StatusNotFound, "function not found: "+idOrName)
+		return
+	}
+	if err != nil {
+		h.logError(r, "TransferFunctionOwnership", "转移函数所有权失败", err, logrus.Fields{"function": fn.Name})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.auditLog(r, "function.transfer_ownership", "function", fn.ID, fn.Name, map[string]interface{}{
+		"old_owner_id": oldOwnerID,
+		"new_owner_id": req.OwnerID,
+	})
+	h.logInfo(r, "TransferFunctionOwnership", "函数所有权转移成功", logrus.Fields{
+		"function":     fn.Name,
+		"old_owner_id": oldOwnerID,
+		"new_owner_id": req.OwnerID,
+	})
+	writeJSON(w, http.StatusOK, domain.OwnershipTransferResult{
+		FunctionID: fn.ID,
+		OldOwnerID: oldOwnerID,
+		NewOwnerID: req.OwnerID,
+	})
+}
+
 // CloneFunction 处理克隆函数的请求。
 // HTTP端点: POST /api/v1/functions/{id}/clone
 //
@@ -1186,24 +1573,40 @@ func (h *Handler) CloneFunction(w http.ResponseWriter, r *http.Request) {
 
 	// 构建新函数对象
 	newFn := &domain.Function{
-		Name:           req.Name,
-		Description:    description,
-		Tags:           tags,
-		Runtime:        sourceFn.Runtime,
-		Handler:        sourceFn.Handler,
-		Code:           sourceFn.Code,
-		Binary:         sourceFn.Binary,
-		CodeHash:       codeHash,
-		MemoryMB:       sourceFn.MemoryMB,
-		TimeoutSec:     sourceFn.TimeoutSec,
-		EnvVars:        envVars,
-		CronExpression: sourceFn.CronExpression,
-		HTTPPath:       "", // HTTP路径需要用户重新配置，避免冲突
-		HTTPMethods:    httpMethods,
-		Status:         domain.FunctionStatusCreating,
-		StatusMessage:  "函数正在创建中（克隆自 " + sourceFn.Name + "）",
-		TaskID:         taskID,
-		Version:        1,
+		Name:                  req.Name,
+		Description:           description,
+		Tags:                  tags,
+		Runtime:               sourceFn.Runtime,
+		Handler:               sourceFn.Handler,
+		Code:                  sourceFn.Code,
+		Binary:                sourceFn.Binary,
+		CodeHash:              codeHash,
+		MemoryMB:              sourceFn.MemoryMB,
+		TimeoutSec:            sourceFn.TimeoutSec,
+		EnvVars:               envVars,
+		CronExpression:        sourceFn.CronExpression,
+		CronTimezone:          sourceFn.CronTimezone,
+		HTTPPath:              "", // HTTP路径需要用户重新配置，避免冲突
+		HTTPMethods:           httpMethods,
+		NodeSelector:          sourceFn.NodeSelector,
+		WarmupStrategy:        sourceFn.WarmupStrategy,
+		WarmupSchedule:        sourceFn.WarmupSchedule,
+		MaxInvocationRecords:  sourceFn.MaxInvocationRecords,
+		PropagateIdentity:     sourceFn.PropagateIdentity,
+		SnapshotTTLHours:      sourceFn.SnapshotTTLHours,
+		IOLimits:              sourceFn.IOLimits,
+		InputSchema:           sourceFn.InputSchema,
+		RateLimit:             sourceFn.RateLimit,
+		Cacheable:             sourceFn.Cacheable,
+		CacheTTLSec:           sourceFn.CacheTTLSec,
+		WarmupHandler:         sourceFn.WarmupHandler,
+		WarmupTimeoutSec:      sourceFn.WarmupTimeoutSec,
+		InstallDeps:           sourceFn.InstallDeps,
+		InstallDepsTimeoutSec: sourceFn.InstallDepsTimeoutSec,
+		Status:                domain.FunctionStatusCreating,
+		StatusMessage:         "函数正在创建中（克隆自 " + sourceFn.Name + "）",
+		TaskID:                taskID,
+		Version:               1,
 	}
 
 	// 保存函数到数据库
@@ -1226,6 +1629,7 @@ func (h *Handler) CloneFunction(w http.ResponseWriter, r *http.Request) {
 		TimeoutSec:     sourceFn.TimeoutSec,
 		EnvVars:        envVars,
 		CronExpression: sourceFn.CronExpression,
+		CronTimezone:   sourceFn.CronTimezone,
 		HTTPMethods:    httpMethods,
 	}
 	taskInput, _ := json.Marshal(createReq)
@@ -1263,6 +1667,21 @@ func (h *Handler) CloneFunction(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// requestIdentity 从认证中间件写入的上下文中提取调用方身份。
+// 请求未经认证（API Key 或已验证的 JWT）时返回 nil。
+func requestIdentity(r *http.Request) *domain.Identity {
+	user := auth.GetUser(r.Context())
+	if user == nil || user.UserID == "" {
+		return nil
+	}
+	return &domain.Identity{UserID: user.UserID, Role: user.Role, Method: user.Method}
+}
+
+// requestCallChain 读取函数调用其他函数时携带的调用链请求头，不是函数间调用时返回 nil
+func requestCallChain(r *http.Request) []string {
+	return domain.ParseCallChain(r.Header.Get(domain.HeaderCallChain))
+}
+
 // InvokeFunction 处理同步调用函数的请求。
 // HTTP端点: POST /api/v1/functions/{id}/invoke
 //
@@ -1325,6 +1744,10 @@ func (h *Handler) InvokeFunction(w http.ResponseWriter, r *http.Request) {
 	if payload == nil {
 		payload = json.RawMessage("{}")
 	}
+	// 按函数的 input_schema 校验输入，不合法时不进入调度
+	if !h.checkInvokeInput(w, r, "InvokeFunction", fn, payload) {
+		return
+	}
 
 	// 生成请求ID
 	requestID := generateRequestID()
@@ -1349,10 +1772,14 @@ func (h *Handler) InvokeFunction(w http.ResponseWriter, r *http.Request) {
 
 	// 构建调用请求
 	req := &domain.InvokeRequest{
-		FunctionID: fn.ID,
-		Payload:    payload,
-		Async:      false,
-		SessionKey: r.URL.Query().Get("session_key"), // 支持有状态函数的会话标识
+		FunctionID:  fn.ID,
+		Payload:     payload,
+		Async:       false,
+		SessionKey:  r.URL.Query().Get("session_key"), // 支持有状态函数的会话标识
+		Environment: r.URL.Query().Get("environment"), // 调用所在环境，决定生效的环境变量
+		Identity:    requestIdentity(r),               // 认证调用方身份，函数启用 propagate_identity 时传递
+		CallChain:   requestCallChain(r),              // 函数间调用的调用链，用于拒绝循环调用
+		Context:     r.Context(),                      // 请求上下文，携带调用链路的追踪信息
 	}
 
 	// 记录开始时间
@@ -1384,7 +1811,7 @@ func (h *Handler) InvokeFunction(w http.ResponseWriter, r *http.Request) {
 			DurationMs:   durationMs,
 		})
 		// 返回带堆栈的错误响应
-		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+		writeJSON(w, invokeErrorStatus(err), map[string]interface{}{
 			"error":       err.Error(),
 			"stack":       getStackTrace(0),
 			"request_id":  requestID,
@@ -1464,19 +1891,27 @@ func (h *Handler) InvokeFunctionAsync(w http.ResponseWriter, r *http.Request) {
 	if payload == nil {
 		payload = json.RawMessage("{}")
 	}
+	// 按函数的 input_schema 校验输入，不合法时不进入调度
+	if !h.checkInvokeInput(w, r, "InvokeFunctionAsync", fn, payload) {
+		return
+	}
 
 	// 构建异步调用请求
 	req := &domain.InvokeRequest{
-		FunctionID: fn.ID,
-		Payload:    payload,
-		Async:      true,
-		SessionKey: r.URL.Query().Get("session_key"), // 支持有状态函数的会话标识
+		FunctionID:  fn.ID,
+		Payload:     payload,
+		Async:       true,
+		SessionKey:  r.URL.Query().Get("session_key"), // 支持有状态函数的会话标识
+		Environment: r.URL.Query().Get("environment"), // 调用所在环境，决定生效的环境变量
+		Identity:    requestIdentity(r),               // 认证调用方身份，函数启用 propagate_identity 时传递
+		CallChain:   requestCallChain(r),              // 函数间调用的调用链，用于拒绝循环调用
+		Context:     r.Context(),                      // 请求上下文，携带调用链路的追踪信息
 	}
 
 	// 通过调度器提交异步执行请求
 	requestID, err := h.scheduler.InvokeAsync(req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, invokeErrorStatus(err), err.Error())
 		return
 	}
 
@@ -1522,20 +1957,59 @@ func (h *Handler) GetInvocation(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, inv)
 }
 
+// GetInvocationExplain 处理获取调用执行路径说明的请求。
+// HTTP端点: GET /api/v1/invocations/{id}/explain
+//
+// 功能说明：
+//   - 返回调用路径上记录的决策（版本解析、节点选择、环境变量合并、虚拟机选择）
+//   - 用于排查两次调用的路由或延迟为何不同
+func (h *Handler) GetInvocationExplain(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invocation id required")
+		return
+	}
+
+	explain, err := h.store.GetInvocationExplain(id)
+	if err == domain.ErrInvocationNotFound {
+		writeErrorWithContext(w, r, http.StatusNotFound, "invocation not found")
+		return
+	}
+	if err != nil {
+		h.logError(r, "GetInvocationExplain", "查询调用决策记录失败", err, logrus.Fields{"invocation_id": id})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get invocation explain: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, explain)
+}
+
+// invocationReplayStore 是重放调用读取原始调用和函数所需的存储方法，*storage.PostgresStore 实现了该接口
+type invocationReplayStore interface {
+	GetInvocationByID(id string) (*domain.Invocation, error)
+	GetFunctionByID(id string) (*domain.Function, error)
+}
+
 // ReplayInvocation 处理重放调用记录的请求。
 // HTTP端点: POST /api/v1/invocations/{id}/replay
 //
 // 功能说明：
-//   - 使用历史调用记录的输入参数重新执行函数
+//   - 使用历史调用记录的原始输入重新执行函数的当前版本
+//   - 新的调用记录通过 replay_of 指向原始调用
 //   - 适用于调试和问题重现
 //
 // 路径参数：
 //   - id: 调用记录的唯一标识符
 //
 // 返回值：
-//   - 200: 成功，返回新的调用结果
+//   - 200: 成功，返回新的调用结果、新调用 ID（invocation_id）和原始调用 ID（replay_of）
 //   - 404: 调用记录不存在或函数已删除
 func (h *Handler) ReplayInvocation(w http.ResponseWriter, r *http.Request) {
+	h.replayInvocation(w, r, h.store)
+}
+
+// replayInvocation 实现 ReplayInvocation，store 单独传入以便测试
+func (h *Handler) replayInvocation(w http.ResponseWriter, r *http.Request, store invocationReplayStore) {
 	requestID := middleware.GetReqID(r.Context())
 
 	// 从URL路径中提取调用记录ID
@@ -1548,7 +2022,7 @@ func (h *Handler) ReplayInvocation(w http.ResponseWriter, r *http.Request) {
 	h.logInfo(r, "ReplayInvocation", "开始重放调用", logrus.Fields{"invocation_id": id, "request_id": requestID})
 
 	// 查询原始调用记录
-	inv, err := h.store.GetInvocationByID(id)
+	inv, err := store.GetInvocationByID(id)
 	if err == domain.ErrInvocationNotFound {
 		h.logWarn(r, "ReplayInvocation", "调用记录不存在", logrus.Fields{"invocation_id": id})
 		writeError(w, http.StatusNotFound, "invocation not found")
@@ -1561,7 +2035,7 @@ func (h *Handler) ReplayInvocation(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 查询函数信息
-	fn, err := h.store.GetFunctionByID(inv.FunctionID)
+	fn, err := store.GetFunctionByID(inv.FunctionID)
 	if err == domain.ErrFunctionNotFound {
 		h.logWarn(r, "ReplayInvocation", "函数已删除", logrus.Fields{"function_id": inv.FunctionID})
 		writeError(w, http.StatusNotFound, "function not found (may have been deleted)")
@@ -1595,6 +2069,9 @@ func (h *Handler) ReplayInvocation(w http.ResponseWriter, r *http.Request) {
 	req := &domain.InvokeRequest{
 		FunctionID: fn.ID,
 		Payload:    inv.Input,
+		Identity:   requestIdentity(r),
+		Context:    r.Context(),
+		ReplayOf:   id,
 	}
 
 	// 执行函数调用
@@ -1607,7 +2084,7 @@ func (h *Handler) ReplayInvocation(w http.ResponseWriter, r *http.Request) {
 			"original_invocation": id,
 			"duration_ms":         durationMs,
 		})
-		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+		writeJSON(w, invokeErrorStatus(err), map[string]interface{}{
 			"error":                err.Error(),
 			"request_id":           requestID,
 			"original_invocation":  id,
@@ -1623,9 +2100,11 @@ func (h *Handler) ReplayInvocation(w http.ResponseWriter, r *http.Request) {
 		"status_code":         resp.StatusCode,
 	})
 
-	// 返回结果（包含原始调用ID以便追踪）
+	// 返回结果（包含新调用ID和原始调用ID以便追踪）
 	writeJSON(w, resp.StatusCode, map[string]interface{}{
 		"request_id":          requestID,
+		"invocation_id":       resp.RequestID,
+		"replay_of":           id,
 		"original_invocation": id,
 		"status_code":         resp.StatusCode,
 		"body":                resp.Body,
@@ -1640,20 +2119,25 @@ func (h *Handler) ReplayInvocation(w http.ResponseWriter, r *http.Request) {
 //
 // 功能说明：
 //   - 查询指定函数的所有调用记录
-//   - 支持分页查询
+//   - 使用游标分页，翻页深度不影响查询性能
 //
 // 路径参数：
 //   - id: 函数的唯一标识符或名称
 //
 // 查询参数：
-//   - offset: 偏移量（默认0）
+//   - before: 游标，上一页返回的 next_cursor，为空时从最新的记录开始
 //   - limit: 每页数量，范围1-100（默认20）
 //
 // 返回值：
 //   - invocations: 调用记录列表
-//   - total: 总数量
-//   - offset/limit: 分页信息
+//   - next_cursor: 下一页游标，没有更多记录时为空
+//   - limit: 分页信息
 func (h *Handler) ListInvocations(w http.ResponseWriter, r *http.Request) {
+	listInvocations(w, r, h.store, h.store)
+}
+
+// listInvocations 实现 ListInvocations，只依赖函数和调用记录的仓库接口，测试中可使用内存实现
+func listInvocations(w http.ResponseWriter, r *http.Request, functions domain.FunctionRepository, invocations domain.InvocationRepository) {
 	// 从URL路径中提取函数ID或名称
 	idOrName := chi.URLParam(r, "id")
 	if idOrName == "" {
@@ -1662,9 +2146,9 @@ func (h *Handler) ListInvocations(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 解析函数标识符，如果提供的是名称则转换为ID
-	fn, err := h.store.GetFunctionByID(idOrName)
+	fn, err := functions.GetFunctionByID(idOrName)
 	if err == domain.ErrFunctionNotFound {
-		fn, err = h.store.GetFunctionByName(idOrName)
+		fn, err = functions.GetFunctionByName(idOrName)
 	}
 	if err == domain.ErrFunctionNotFound {
 		writeError(w, http.StatusNotFound, "function not found")
@@ -1676,7 +2160,11 @@ func (h *Handler) ListInvocations(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 解析分页参数
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	before, err := domain.ParseInvocationCursor(r.URL.Query().Get("before"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid 'before' cursor")
+		return
+	}
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 
 	// 设置limit的默认值和最大值限制
@@ -1688,17 +2176,19 @@ func (h *Handler) ListInvocations(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 查询该函数的调用记录
-	invocations, total, err := h.store.ListInvocationsByFunction(fn.ID, offset, limit)
+	page, next, err := invocations.ListInvocationsByFunctionCursor(fn.ID, before, limit)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list invocations")
 		return
 	}
+	if page == nil {
+		page = []*domain.Invocation{}
+	}
 
 	// 返回分页结果
 	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"invocations": invocations,
-		"total":       total,
-		"offset":      offset,
+		"invocations": page,
+		"next_cursor": next.String(),
 		"limit":       limit,
 	})
 }
@@ -1708,45 +2198,166 @@ func (h *Handler) ListInvocations(w http.ResponseWriter, r *http.Request) {
 //
 // 功能说明：
 //   - 查询所有函数的调用记录
-//   - 支持按状态过滤和分页查询
+//   - 支持按状态、触发类型和创建时间范围过滤，以及分页查询
 //
 // 查询参数：
 //   - status: 状态过滤（可选）
+//   - trigger_type: 触发类型过滤（可选）
+//   - after: 只返回创建时间不早于该时间的记录，RFC3339 格式（可选）
+//   - before: 只返回创建时间早于该时间的记录，RFC3339 格式（可选）
 //   - offset: 偏移量（默认0）
 //   - limit: 每页数量，范围1-100（默认20）
 //
-// 返回值：
-//   - invocations: 调用记录列表
-//   - total: 总数量
-//   - offset/limit: 分页信息
-func (h *Handler) ListAllInvocations(w http.ResponseWriter, r *http.Request) {
-	// 解析参数
-	status := r.URL.Query().Get("status")
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+// 返回值：
+//   - invocations: 调用记录列表
+//   - total: 总数量
+//   - offset/limit: 分页信息
+func (h *Handler) ListAllInvocations(w http.ResponseWriter, r *http.Request) {
+	// 解析参数
+	filter := &domain.InvocationFilter{
+		Status:      domain.InvocationStatus(r.URL.Query().Get("status")),
+		TriggerType: domain.TriggerType(r.URL.Query().Get("trigger_type")),
+	}
+	for name, target := range map[string]**time.Time{"after": &filter.After, "before": &filter.Before} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid '%s' timestamp, expected RFC3339", name))
+			return
+		}
+		*target = &ts
+	}
+	if filter.After != nil && filter.Before != nil && !filter.After.Before(*filter.Before) {
+		writeError(w, http.StatusBadRequest, "'after' must be earlier than 'before'")
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	// 设置limit的默认值和最大值限制
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	// 查询所有调用记录
+	invocations, total, err := h.store.ListAllInvocationsWithFilter(filter, offset, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list invocations")
+		return
+	}
+
+	// 返回分页结果
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"invocations": invocations,
+		"total":       total,
+		"offset":      offset,
+		"limit":       limit,
+	})
+}
+
+// ListActiveInvocations 处理获取正在执行的调用列表的请求。
+// HTTP端点: GET /api/v1/invocations/active
+//
+// 功能说明：
+//   - 返回调度器当前正在执行的调用（ID、函数、开始时间、虚拟机ID）
+//   - 包含每个调用已运行的时长，便于发现挂起的调用
+//   - 标记运行时长接近超时时间的调用
+//
+// 返回值：
+//   - invocations: 正在执行的调用列表
+//   - total: 总数量
+//   - near_timeout: 接近超时的调用数量
+func (h *Handler) ListActiveInvocations(w http.ResponseWriter, r *http.Request) {
+	lister, ok := h.scheduler.(ActiveInvocationLister)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "scheduler does not support listing active invocations")
+		return
+	}
+
+	active, err := lister.ListActiveInvocations()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list active invocations")
+		return
+	}
+
+	nearTimeout := 0
+	for _, inv := range active {
+		if inv.NearTimeout {
+			nearTimeout++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"invocations":  active,
+		"total":        len(active),
+		"near_timeout": nearTimeout,
+	})
+}
+
+// CancelInvocation 处理取消正在执行的调用的请求。
+// HTTP端点: POST /api/v1/invocations/{id}/cancel
+//
+// 功能说明：
+//   - 中止本节点上正在执行的调用，调用记录的状态变为 cancelled
+//   - 取消是异步生效的，返回 202 时调用可能仍在收尾
+//   - 调用已结束时返回 409，调用不存在时返回 404
+func (h *Handler) CancelInvocation(w http.ResponseWriter, r *http.Request) {
+	h.cancelInvocation(w, r, h.store)
+}
 
-	// 设置limit的默认值和最大值限制
-	if limit <= 0 {
-		limit = 20
+// invocationGetter 是取消调用时读取调用记录所需的存储方法，*storage.PostgresStore 实现了该接口
+type invocationGetter interface {
+	GetInvocationByID(id string) (*domain.Invocation, error)
+}
+
+// cancelInvocation 实现 CancelInvocation，store 单独传入以便测试
+func (h *Handler) cancelInvocation(w http.ResponseWriter, r *http.Request, store invocationGetter) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "invocation id required")
+		return
 	}
-	if limit > 100 {
-		limit = 100
+
+	canceller, ok := h.scheduler.(InvocationCanceller)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "scheduler does not support cancelling invocations")
+		return
 	}
 
-	// 查询所有调用记录
-	invocations, total, err := h.store.ListAllInvocations(status, offset, limit)
+	if canceller.CancelInvocation(id) {
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"id":     id,
+			"status": "cancelling",
+		})
+		return
+	}
+
+	// 调用未在本节点执行，根据调用记录区分不存在、已结束和仍在排队或在其他节点执行
+	inv, err := store.GetInvocationByID(id)
+	if err == domain.ErrInvocationNotFound {
+		writeError(w, http.StatusNotFound, "invocation not found")
+		return
+	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to list invocations")
+		writeError(w, http.StatusInternalServerError, "failed to get invocation")
+		return
+	}
+	if inv.Status != domain.InvocationStatusPending && inv.Status != domain.InvocationStatusRunning {
+		writeError(w, http.StatusConflict, "invocation already finished with status "+string(inv.Status))
 		return
 	}
+	writeError(w, http.StatusConflict, "invocation is not executing on this node")
+}
 
-	// 返回分页结果
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"invocations": invocations,
-		"total":       total,
-		"offset":      offset,
-		"limit":       limit,
-	})
+// SetLeaderStatus 设置主节点状态来源，设置后健康检查返回当前节点是否为主节点
+func (h *Handler) SetLeaderStatus(isLeader func() bool) {
+	h.isLeader = isLeader
 }
 
 // Health 处理基本健康检查请求。
@@ -1755,10 +2366,15 @@ func (h *Handler) ListAllInvocations(w http.ResponseWriter, r *http.Request) {
 // 功能说明：
 //   - 返回服务的基本运行状态
 //   - 用于负载均衡器的健康检查
+//   - 启用主节点选举时返回 leader 字段，表示当前节点是否在运行单例后台任务
 //
-// 返回值：{"status": "healthy"}
+// 返回值：{"status": "healthy", "leader": true}
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+	resp := map[string]interface{}{"status": "healthy"}
+	if h.isLeader != nil {
+		resp["leader"] = h.isLeader()
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // Ready 处理Kubernetes就绪探针请求。
@@ -2002,9 +2618,14 @@ func (h *Handler) HandleCustomRoute(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	method := r.Method
 
-	// 查找匹配该路径的函数
-	fn, err := h.store.GetFunctionByPath(path)
+	// 查找匹配该路径和方法的函数
+	fn, err := h.store.GetFunctionByPathAndMethod(path, method)
 	if err == domain.ErrFunctionNotFound {
+		// 区分路径不存在（404）和方法不被允许（405）
+		if _, err := h.store.GetFunctionByPath(path); err == nil {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed for this route")
+			return
+		}
 		http.NotFound(w, r)
 		return
 	}
@@ -2019,21 +2640,6 @@ func (h *Handler) HandleCustomRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 检查方法是否允许 (如果设置了方法限制)
-	if len(fn.HTTPMethods) > 0 {
-		allowed := false
-		for _, m := range fn.HTTPMethods {
-			if strings.EqualFold(m, method) {
-				allowed = true
-				break
-			}
-		}
-		if !allowed {
-			writeError(w, http.StatusMethodNotAllowed, "method not allowed for this route")
-			return
-		}
-	}
-
 	// 读取请求体作为函数输入
 	var payload json.RawMessage
 	if r.Body != nil {
@@ -2051,26 +2657,18 @@ func (h *Handler) HandleCustomRoute(w http.ResponseWriter, r *http.Request) {
 		FunctionID: fn.ID,
 		Payload:    payload,
 		Async:      false,
+		Identity:   requestIdentity(r),
+		Context:    r.Context(),
 	}
 
 	resp, err := h.scheduler.Invoke(req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, invokeErrorStatus(err), err.Error())
 		return
 	}
 
-	// 尝试解析 Lambda 样式的响应格式 (含 statusCode 和 body)
-	var lambdaResp struct {
-		StatusCode int               `json:"statusCode"`
-		Headers    map[string]string `json:"headers"`
-		Body       json.RawMessage   `json:"body"`
-	}
-
-	if err := json.Unmarshal(resp.Body, &lambdaResp); err == nil && lambdaResp.StatusCode != 0 {
-		for k, v := range lambdaResp.Headers {
-			w.Header().Set(k, v)
-		}
-		writeJSON(w, lambdaResp.StatusCode, lambdaResp.Body)
+	// 函数返回代理响应信封（含 statusCode、headers 和 body）时按信封约定输出，见 proxy_response.go
+	if writeProxyResponse(w, resp.Body) {
 		return
 	}
 
@@ -2078,6 +2676,18 @@ func (h *Handler) HandleCustomRoute(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, resp.StatusCode, resp.Body)
 }
 
+// invokeErrorStatus 返回调度器提交调用失败时的 HTTP 状态码：
+// 函数达到 max_concurrency 时为 429，函数间调用形成循环或调用链过长时为 508，其他错误为 500
+func invokeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrConcurrencyLimitExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, domain.ErrDependencyCycle), errors.Is(err, domain.ErrCallDepthExceeded):
+		return http.StatusLoopDetected
+	}
+	return http.StatusInternalServerError
+}
+
 // ========== 日志辅助方法 ==========
 
 // logInfo 记录信息级别日志
@@ -2373,23 +2983,13 @@ func (h *Handler) RollbackFunction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	v, err := h.store.GetFunctionVersion(fn.ID, version)
+	// 恢复目标版本的代码，递增函数版本号并记录一条回滚版本
+	fn, err = h.store.RollbackFunction(fn.ID, version)
 	if err == domain.ErrFunctionNotFound {
 		writeErrorWithContext(w, r, http.StatusNotFound, "version not found")
 		return
 	}
 	if err != nil {
-		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get version: "+err.Error())
-		return
-	}
-
-	// 更新函数到目标版本
-	fn.Handler = v.Handler
-	fn.Code = v.Code
-	fn.Binary = v.Binary
-	fn.CodeHash = v.CodeHash
-
-	if err := h.store.UpdateFunction(fn); err != nil {
 		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to rollback function: "+err.Error())
 		return
 	}
@@ -2855,9 +3455,10 @@ func (h *Handler) CreateEnvironment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	env := &domain.Environment{
-		Name:        req.Name,
-		Description: req.Description,
-		IsDefault:   req.IsDefault,
+		Name:           req.Name,
+		Description:    req.Description,
+		IsDefault:      req.IsDefault,
+		DefaultEnvVars: req.DefaultEnvVars,
 	}
 
 	if err := h.store.CreateEnvironment(env); err != nil {
@@ -2869,6 +3470,38 @@ func (h *Handler) CreateEnvironment(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, env)
 }
 
+// UpdateEnvironmentEnvVars 更新环境的默认环境变量。
+// 环境默认变量优先级最低，会被函数级变量和函数环境覆盖变量覆盖。
+// HTTP端点: PUT /api/v1/environments/{id}/env-vars
+func (h *Handler) UpdateEnvironmentEnvVars(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req domain.UpdateEnvironmentEnvVarsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	if err := h.store.UpdateEnvironmentDefaultEnvVars(id, req.DefaultEnvVars); err != nil {
+		if err.Error() == "environment not found" {
+			writeErrorWithContext(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		h.logError(r, "UpdateEnvironmentEnvVars", "更新环境默认变量失败", err, logrus.Fields{"environment_id": id})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to update environment env vars: "+err.Error())
+		return
+	}
+
+	env, err := h.store.GetEnvironmentByID(id)
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get environment: "+err.Error())
+		return
+	}
+
+	h.logInfo(r, "UpdateEnvironmentEnvVars", "环境默认变量更新成功", logrus.Fields{"environment_id": id})
+	writeJSON(w, http.StatusOK, env)
+}
+
 // DeleteEnvironment 删除环境。
 // HTTP端点: DELETE /api/v1/environments/{id}
 func (h *Handler) DeleteEnvironment(w http.ResponseWriter, r *http.Request) {
@@ -2974,6 +3607,55 @@ func (h *Handler) UpdateFunctionEnvConfig(w http.ResponseWriter, r *http.Request
 	writeJSON(w, http.StatusOK, cfg)
 }
 
+// PromoteFunction 将函数在源环境下的别名和资源配置晋升到目标环境。
+// 复制 active_alias、memory_mb 和 timeout_sec，目标环境的环境变量保持不变；
+// 配置复制和审计日志在同一事务中写入。
+// HTTP端点: POST /api/v1/functions/{id}/promote
+//
+// 请求体：
+//   - from: 源环境名称，如 dev
+//   - to: 目标环境名称，如 staging
+func (h *Handler) PromoteFunction(w http.ResponseWriter, r *http.Request) {
+	idOrName := chi.URLParam(r, "id")
+
+	fn, err := h.store.GetFunctionByID(idOrName)
+	if err == domain.ErrFunctionNotFound {
+		fn, err = h.store.GetFunctionByName(idOrName)
+	}
+	if err == domain.ErrFunctionNotFound {
+		writeErrorWithContext(w, r, http.StatusNotFound, "function not found")
+		return
+	}
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get function: "+err.Error())
+		return
+	}
+
+	var req domain.PromoteFunctionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	audit := newAuditLog(r, "function.promote", "function", fn.ID, fn.Name, nil)
+	cfg, err := h.store.PromoteFunction(fn.ID, req.From, req.To, audit)
+	switch {
+	case errors.Is(err, domain.ErrInvalidPromotion):
+		writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, domain.ErrEnvironmentNotFound), errors.Is(err, domain.ErrEnvConfigNotFound):
+		writeErrorWithContext(w, r, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		h.logError(r, "PromoteFunction", "函数环境晋升失败", err, logrus.Fields{"function": fn.Name, "from": req.From, "to": req.To})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to promote function: "+err.Error())
+		return
+	}
+
+	h.logInfo(r, "PromoteFunction", "函数环境晋升成功", logrus.Fields{"function": fn.Name, "from": req.From, "to": req.To})
+	writeJSON(w, http.StatusOK, cfg)
+}
+
 // ==================== 函数状态管理处理器 ====================
 
 // GetFunctionTask 获取函数任务状态。
@@ -3047,8 +3729,8 @@ func (h *Handler) OfflineFunction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 移除定时任务
-	if h.cronManager != nil && fn.CronExpression != "" {
+	// 移除定时任务和预热计划
+	if h.cronManager != nil && (fn.CronExpression != "" || fn.WarmupStrategy == domain.WarmupScheduled) {
 		h.cronManager.RemoveFunction(fn.ID)
 		h.logDebug(r, "OfflineFunction", "移除定时任务", logrus.Fields{"function": fn.Name})
 	}
@@ -3106,8 +3788,8 @@ func (h *Handler) OnlineFunction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 恢复定时任务
-	if h.cronManager != nil && fn.CronExpression != "" {
+	// 恢复定时任务和预热计划
+	if h.cronManager != nil && (fn.CronExpression != "" || fn.WarmupStrategy == domain.WarmupScheduled) {
 		fn.Status = domain.FunctionStatusActive // 临时设置状态以便 cronManager 使用
 		h.cronManager.AddOrUpdateFunction(fn)
 		h.logDebug(r, "OnlineFunction", "恢复定时任务", logrus.Fields{"function": fn.Name, "cron": fn.CronExpression})
@@ -3254,178 +3936,6 @@ func (h *Handler) PinFunction(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, fn)
 }
 
-// ExportFunction 导出函数配置为JSON格式。
-// HTTP端点: GET /api/v1/functions/{id}/export
-//
-// 功能说明：
-//   - 导出函数的完整配置（不包括二进制）
-//   - 可用于备份或迁移
-func (h *Handler) ExportFunction(w http.ResponseWriter, r *http.Request) {
-	idOrName := chi.URLParam(r, "id")
-	if idOrName == "" {
-		writeErrorWithContext(w, r, http.StatusBadRequest, "function id or name required")
-		return
-	}
-
-	h.logInfo(r, "ExportFunction", "导出函数配置", logrus.Fields{"function": idOrName})
-
-	// 查找函数
-	fn, err := h.store.GetFunctionByID(idOrName)
-	if err == domain.ErrFunctionNotFound {
-		fn, err = h.store.GetFunctionByName(idOrName)
-	}
-	if err == domain.ErrFunctionNotFound {
-		writeErrorWithContext(w, r, http.StatusNotFound, "function not found: "+idOrName)
-		return
-	}
-	if err != nil {
-		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get function: "+err.Error())
-		return
-	}
-
-	// 创建导出数据结构
-	export := map[string]interface{}{
-		"name":            fn.Name,
-		"description":     fn.Description,
-		"tags":            fn.Tags,
-		"runtime":         fn.Runtime,
-		"handler":         fn.Handler,
-		"code":            fn.Code,
-		"memory_mb":       fn.MemoryMB,
-		"timeout_sec":     fn.TimeoutSec,
-		"env_vars":        fn.EnvVars,
-		"cron_expression": fn.CronExpression,
-		"http_path":       fn.HTTPPath,
-		"http_methods":    fn.HTTPMethods,
-		"exported_at":     time.Now().Format(time.RFC3339),
-		"version":         fn.Version,
-	}
-
-	// 设置下载头
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", fn.Name))
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, http.StatusOK, export)
-}
-
-// ImportFunction 从JSON配置导入函数。
-// HTTP端点: POST /api/v1/functions/import
-//
-// 功能说明：
-//   - 从JSON配置创建新函数
-//   - 如果名称已存在，会提示错误
-func (h *Handler) ImportFunction(w http.ResponseWriter, r *http.Request) {
-	requestID := middleware.GetReqID(r.Context())
-	h.logInfo(r, "ImportFunction", "导入函数配置", logrus.Fields{"request_id": requestID})
-
-	var req struct {
-		Name           string            `json:"name"`
-		Description    string            `json:"description"`
-		Tags           []string          `json:"tags"`
-		Runtime        domain.Runtime    `json:"runtime"`
-		Handler        string            `json:"handler"`
-		Code           string            `json:"code"`
-		MemoryMB       int               `json:"memory_mb"`
-		TimeoutSec     int               `json:"timeout_sec"`
-		MaxConcurrency int               `json:"max_concurrency"`
-		EnvVars        map[string]string `json:"env_vars"`
-		CronExpression string            `json:"cron_expression"`
-		HTTPPath       string            `json:"http_path"`
-		HTTPMethods    []string          `json:"http_methods"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid JSON: "+err.Error())
-		return
-	}
-
-	// 验证必填字段
-	if req.Name == "" {
-		writeErrorWithContext(w, r, http.StatusBadRequest, "name is required")
-		return
-	}
-	if !req.Runtime.IsValid() {
-		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid runtime: "+string(req.Runtime))
-		return
-	}
-	if req.Handler == "" {
-		writeErrorWithContext(w, r, http.StatusBadRequest, "handler is required")
-		return
-	}
-	if req.Code == "" {
-		writeErrorWithContext(w, r, http.StatusBadRequest, "code is required")
-		return
-	}
-
-	// 检查名称是否已存在
-	if _, err := h.store.GetFunctionByName(req.Name); err == nil {
-		writeErrorWithContext(w, r, http.StatusConflict, "function with this name already exists: "+req.Name)
-		return
-	}
-
-	// 设置默认值
-	if req.MemoryMB == 0 {
-		req.MemoryMB = 256
-	}
-	if req.TimeoutSec == 0 {
-		req.TimeoutSec = 30
-	}
-
-	// 创建函数
-	now := time.Now()
-	taskID := fmt.Sprintf("task-%s", requestID)
-	fn := &domain.Function{
-		ID:             uuid.New().String(),
-		Name:           req.Name,
-		Description:    req.Description,
-		Tags:           req.Tags,
-		Runtime:        req.Runtime,
-		Handler:        req.Handler,
-		Code:           req.Code,
-		MemoryMB:       req.MemoryMB,
-		TimeoutSec:     req.TimeoutSec,
-		MaxConcurrency: req.MaxConcurrency,
-		EnvVars:        req.EnvVars,
-		CronExpression: req.CronExpression,
-		HTTPPath:       req.HTTPPath,
-		HTTPMethods:    req.HTTPMethods,
-		Status:         domain.FunctionStatusCreating,
-		StatusMessage:  "函数正在创建中（导入）",
-		TaskID:         taskID,
-		Version:        1,
-		CreatedAt:      now,
-		UpdatedAt:      now,
-	}
-
-	// 保存函数
-	if err := h.store.CreateFunction(fn); err != nil {
-		h.logError(r, "ImportFunction", "创建函数失败", err, logrus.Fields{"name": req.Name})
-		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to create function: "+err.Error())
-		return
-	}
-
-	// 创建任务记录
-	task := &domain.FunctionTask{
-		ID:         taskID,
-		FunctionID: fn.ID,
-		Type:       domain.FunctionTaskCreate,
-		Status:     domain.FunctionTaskPending,
-		CreatedAt:  now,
-	}
-	if err := h.store.CreateFunctionTask(task); err != nil {
-		h.logError(r, "ImportFunction", "创建任务记录失败", err, nil)
-	}
-
-	// 异步处理函数创建
-	go h.processCreateFunctionTask(fn.ID, taskID)
-
-	h.logInfo(r, "ImportFunction", "函数导入成功", logrus.Fields{"function": fn.Name, "id": fn.ID})
-	writeJSON(w, http.StatusAccepted, map[string]interface{}{
-		"function": fn,
-		"task_id":  taskID,
-		"message":  "函数导入任务已开始",
-	})
-}
-
 // ==================== 死信队列 (DLQ) 处理器 ====================
 
 // ListDLQMessages 获取死信消息列表。
@@ -3539,6 +4049,7 @@ func (h *Handler) RetryDLQMessage(w http.ResponseWriter, r *http.Request) {
 	req := &domain.InvokeRequest{
 		FunctionID: fn.ID,
 		Payload:    msg.Payload,
+		Context:    r.Context(),
 	}
 
 	resp, err := h.scheduler.Invoke(req)
@@ -3743,63 +4254,38 @@ func (h *Handler) GetRetentionStats(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, stats)
 }
 
-// RunRetentionCleanup 执行保留策略清理。
+// RunRetentionCleanup 立即执行一次保留策略清理。
 // HTTP端点: POST /api/v1/retention/cleanup
+//
+// 功能说明：
+//   - 按系统设置中的保留天数清理调用记录、死信消息、任务记录和审计日志，并按函数上限裁剪调用记录
+//   - 与后台的自动清理互斥，自动清理正在执行时等待其完成
+//
+// 返回值：
+//   - 200: 返回 scheduler.RetentionResult，部分清理项失败时也返回 200，错误信息在 errors 中
 func (h *Handler) RunRetentionCleanup(w http.ResponseWriter, r *http.Request) {
 	h.logInfo(r, "RunRetentionCleanup", "开始执行保留策略清理", nil)
 
-	// 获取保留天数设置
-	logRetentionDays := 30 // 默认值
-	dlqRetentionDays := 90 // 默认值
-
-	if setting, err := h.store.GetSystemSetting("log_retention_days"); err == nil {
-		if days, err := strconv.Atoi(setting.Value); err == nil && days > 0 {
-			logRetentionDays = days
-		}
-	}
-	if setting, err := h.store.GetSystemSetting("dlq_retention_days"); err == nil {
-		if days, err := strconv.Atoi(setting.Value); err == nil && days > 0 {
-			dlqRetentionDays = days
-		}
-	}
-
-	// 清理调用记录
-	invocationsDeleted, err := h.store.CleanupOldInvocations(logRetentionDays)
-	if err != nil {
-		h.logError(r, "RunRetentionCleanup", "清理调用记录失败", err, nil)
-	}
-
-	// 清理死信队列
-	dlqDeleted, err := h.store.CleanupOldDLQMessages(dlqRetentionDays)
-	if err != nil {
-		h.logError(r, "RunRetentionCleanup", "清理死信队列失败", err, nil)
-	}
-
-	// 清理任务记录
-	tasksDeleted, err := h.store.CleanupOldTasks(logRetentionDays)
+	result, err := h.retention.RunNow(r.Context())
 	if err != nil {
-		h.logError(r, "RunRetentionCleanup", "清理任务记录失败", err, nil)
+		h.logError(r, "RunRetentionCleanup", "保留策略清理部分失败", err, nil)
 	}
 
-	h.logInfo(r, "RunRetentionCleanup", "保留策略清理完成", logrus.Fields{
-		"invocations_deleted": invocationsDeleted,
-		"dlq_deleted":         dlqDeleted,
-		"tasks_deleted":       tasksDeleted,
-	})
-
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"invocations_deleted": invocationsDeleted,
-		"dlq_deleted":         dlqDeleted,
-		"tasks_deleted":       tasksDeleted,
-		"log_retention_days":  logRetentionDays,
-		"dlq_retention_days":  dlqRetentionDays,
-	})
+	writeJSON(w, http.StatusOK, result)
 }
 
 // ==================== 审计日志处理器 ====================
 
 // auditLog 记录审计日志的辅助方法
 func (h *Handler) auditLog(r *http.Request, action, resourceType, resourceID, resourceName string, details map[string]interface{}) {
+	log := newAuditLog(r, action, resourceType, resourceID, resourceName, details)
+	if err := h.store.CreateAuditLog(log); err != nil {
+		h.logger.WithError(err).Warn("审计日志记录失败")
+	}
+}
+
+// newAuditLog 根据请求构造审计日志，填写操作者和来源 IP
+func newAuditLog(r *http.Request, action, resourceType, resourceID, resourceName string, details map[string]interface{}) *storage.AuditLog {
 	log := &storage.AuditLog{
 		Action:       action,
 		ResourceType: resourceType,
@@ -3815,10 +4301,7 @@ func (h *Handler) auditLog(r *http.Request, action, resourceType, resourceID, re
 	} else {
 		log.Actor = "anonymous"
 	}
-
-	if err := h.store.CreateAuditLog(log); err != nil {
-		h.logger.WithError(err).Warn("审计日志记录失败")
-	}
+	return log
 }
 
 // ListAuditLogs 获取审计日志列表。
@@ -4088,12 +4571,13 @@ func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		FunctionID: fn.ID,
 		Payload:    payloadBytes,
 		Async:      false,
+		Context:    r.Context(),
 	}
 
 	// 通过调度器同步执行函数
 	resp, err := h.scheduler.Invoke(req)
 	if err != nil {
-		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to invoke function: "+err.Error())
+		writeErrorWithContext(w, r, invokeErrorStatus(err), "failed to invoke function: "+err.Error())
 		return
 	}
 
@@ -299,12 +299,14 @@ func (h *Handler) GetWarmingStatus(w http.ResponseWriter, r *http.Request) {
 	policy, _ := h.store.GetWarmingPolicy(functionID)
 
 	status := &domain.WarmingStatus{
-		FunctionID:    functionID,
-		FunctionName:  fn.Name,
-		WarmInstances: 0,
-		BusyInstances: 0,
-		ColdStartRate: 0,
-		Policy:        policy,
+		FunctionID:     functionID,
+		FunctionName:   fn.Name,
+		WarmInstances:  0,
+		BusyInstances:  0,
+		ColdStartRate:  0,
+		Policy:         policy,
+		WarmupStrategy: fn.WarmupStrategy,
+		WarmupSchedule: fn.WarmupSchedule,
 	}
 
 	writeJSON(w, http.StatusOK, status)
@@ -396,24 +398,24 @@ func (h *Handler) GetFunctionDependencies(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// 获取该函数调用的其他函数
-	callsTo, err := h.store.GetFunctionCallsTo(functionID)
+	calledBy, callsTo, err := h.store.GetFunctionDependencies(functionID)
 	if err != nil {
-		callsTo = []domain.FunctionDependency{}
-	}
-
-	// 获取调用该函数的其他函数
-	calledBy, err := h.store.GetFunctionCalledBy(functionID)
-	if err != nil {
-		calledBy = []domain.FunctionDependency{}
+		h.logError(r, "GetFunctionDependencies", "获取函数依赖失败", err, nil)
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get function dependencies")
+		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
+	resp := map[string]interface{}{
 		"function_id":   functionID,
 		"function_name": fn.Name,
 		"calls_to":      callsTo,
 		"called_by":     calledBy,
-	})
+	}
+	// 已记录的依赖中存在经过该函数的调用环时一并返回，便于排查被拒绝的函数间调用
+	if cycle, err := h.store.DetectCycle(functionID); err == nil && cycle != nil {
+		resp["cycle"] = cycle
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // GetDependencyGraph 获取依赖关系图
@@ -481,7 +483,7 @@ func (h *Handler) AddDependency(w http.ResponseWriter, r *http.Request) {
 		depType = domain.DependencyType(req.Type)
 	}
 
-	if err := h.store.AddFunctionDependency(req.SourceID, req.TargetID, depType); err != nil {
+	if err := h.store.RecordDependency(req.SourceID, req.TargetID, depType); err != nil {
 		h.logError(r, "AddDependency", "添加依赖失败", err, nil)
 		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to add dependency")
 		return
@@ -502,7 +504,7 @@ func (h *Handler) GetImpactAnalysis(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取直接依赖此函数的函数
-	directDeps, _ := h.store.GetFunctionCalledBy(functionID)
+	directDeps, _, _ := h.store.GetFunctionDependencies(functionID)
 	directNodes := make([]domain.DependencyNode, 0, len(directDeps))
 	for _, dep := range directDeps {
 		directNodes = append(directNodes, domain.DependencyNode{
@@ -532,3 +534,81 @@ func (h *Handler) GetImpactAnalysis(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, analysis)
 }
+
+// ==================== 调用结果目标 ====================
+
+// GetFunctionDestinations 获取函数的结果投递配置
+// GET /api/v1/functions/{id}/destinations
+func (h *Handler) GetFunctionDestinations(w http.ResponseWriter, r *http.Request) {
+	functionID := chi.URLParam(r, "id")
+
+	if _, err := h.store.GetFunctionByID(functionID); err != nil {
+		writeErrorWithContext(w, r, http.StatusNotFound, "function not found")
+		return
+	}
+
+	cfg, err := h.store.GetFunctionDestinations(functionID)
+	if err != nil {
+		h.logError(r, "GetFunctionDestinations", "获取结果投递配置失败", err, nil)
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get function destinations")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// UpdateFunctionDestinations 更新函数的结果投递配置
+// PUT /api/v1/functions/{id}/destinations
+func (h *Handler) UpdateFunctionDestinations(w http.ResponseWriter, r *http.Request) {
+	functionID := chi.URLParam(r, "id")
+
+	fn, err := h.store.GetFunctionByID(functionID)
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusNotFound, "function not found")
+		return
+	}
+
+	var req struct {
+		OnSuccess *domain.Destination `json:"on_success"`
+		OnFailure *domain.Destination `json:"on_failure"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	for _, dest := range []*domain.Destination{req.OnSuccess, req.OnFailure} {
+		if dest == nil {
+			continue
+		}
+		if err := dest.Validate(); err != nil {
+			writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		// 目标函数必须存在，且不能是函数自身
+		if dest.Type == domain.DestinationFunction {
+			if dest.Target == fn.Name {
+				writeErrorWithContext(w, r, http.StatusBadRequest, "destination function cannot be the function itself")
+				return
+			}
+			if _, err := h.store.GetFunctionByName(dest.Target); err != nil {
+				writeErrorWithContext(w, r, http.StatusBadRequest, "destination function not found: "+dest.Target)
+				return
+			}
+		}
+	}
+
+	cfg := &domain.DestinationConfig{
+		FunctionID: functionID,
+		OnSuccess:  req.OnSuccess,
+		OnFailure:  req.OnFailure,
+	}
+	if err := h.store.SetFunctionDestinations(cfg); err != nil {
+		h.logError(r, "UpdateFunctionDestinations", "保存结果投递配置失败", err, nil)
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to save function destinations")
+		return
+	}
+
+	h.auditLog(r, "destinations.update", "function", functionID, fn.Name, nil)
+	writeJSON(w, http.StatusOK, cfg)
+}
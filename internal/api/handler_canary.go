@@ -0,0 +1,247 @@
+// Package api 提供了函数即服务(FaaS)平台的HTTP API处理程序。
+// 本文件实现金丝雀发布和版本指标对比的 API，自动放量和回滚由 scheduler.CanaryController 执行。
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// ==================== 金丝雀发布 ====================
+
+// StartCanaryDeployment 在别名上启动金丝雀发布，立即按初始权重分流。
+// HTTP端点: POST /api/v1/functions/{id}/canaries
+func (h *Handler) StartCanaryDeployment(w http.ResponseWriter, r *http.Request) {
+	fn, ok := h.lookupFunction(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.CreateCanaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 两个版本和别名都必须存在
+	for _, version := range []int{req.StableVersion, req.CanaryVersion} {
+		if _, err := h.store.GetFunctionVersion(fn.ID, version); err != nil {
+			writeErrorWithContext(w, r, http.StatusBadRequest, "version "+strconv.Itoa(version)+" not found")
+			return
+		}
+	}
+	if _, err := h.store.GetFunctionAlias(fn.ID, req.AliasName); err != nil {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "alias "+req.AliasName+" not found")
+		return
+	}
+	if _, err := h.store.GetRunningCanaryDeployment(fn.ID, req.AliasName); err == nil {
+		writeErrorWithContext(w, r, http.StatusConflict, domain.ErrCanaryExists.Error())
+		return
+	}
+
+	now := time.Now()
+	canary := &domain.CanaryDeployment{
+		FunctionID:         fn.ID,
+		AliasName:          req.AliasName,
+		StableVersion:      req.StableVersion,
+		CanaryVersion:      req.CanaryVersion,
+		Weight:             req.InitialWeight,
+		StepWeight:         req.StepWeight,
+		HealthyDurationSec: req.HealthyDurationSec,
+		MinInvocations:     req.MinInvocations,
+		MaxErrorRateDelta:  req.MaxErrorRateDelta,
+		MaxLatencyRatio:    req.MaxLatencyRatio,
+		Status:             domain.CanaryStatusRunning,
+		StepStartedAt:      now,
+	}
+	if err := h.store.CreateCanaryDeployment(canary); err != nil {
+		h.logError(r, "StartCanaryDeployment", "创建金丝雀发布失败", err, logrus.Fields{"function": fn.Name, "alias": req.AliasName})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to create canary deployment: "+err.Error())
+		return
+	}
+
+	// 别名更新失败时终止发布，避免记录与实际路由不一致
+	if err := h.store.ApplyCanaryDeployment(canary); err != nil {
+		h.logError(r, "StartCanaryDeployment", "更新别名权重失败", err, logrus.Fields{"function": fn.Name, "alias": req.AliasName})
+		canary.Status = domain.CanaryStatusAborted
+		h.store.UpdateCanaryDeployment(canary)
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to apply canary weight: "+err.Error())
+		return
+	}
+
+	h.logInfo(r, "StartCanaryDeployment", "金丝雀发布已启动", logrus.Fields{
+		"function":       fn.Name,
+		"alias":          canary.AliasName,
+		"stable_version": canary.StableVersion,
+		"canary_version": canary.CanaryVersion,
+		"weight":         canary.Weight,
+	})
+	h.auditLog(r, "function.canary_start", "function", fn.ID, fn.Name, map[string]interface{}{
+		"canary_id":      canary.ID,
+		"alias":          canary.AliasName,
+		"stable_version": canary.StableVersion,
+		"canary_version": canary.CanaryVersion,
+		"weight":         canary.Weight,
+	})
+	writeJSON(w, http.StatusCreated, canary)
+}
+
+// ListCanaryDeployments 获取函数的金丝雀发布列表。
+// HTTP端点: GET /api/v1/functions/{id}/canaries
+//
+// 查询参数：
+//   - status: 按状态筛选（running、promoted、rolled_back、aborted），可选
+func (h *Handler) ListCanaryDeployments(w http.ResponseWriter, r *http.Request) {
+	fn, ok := h.lookupFunction(w, r)
+	if !ok {
+		return
+	}
+
+	status := domain.CanaryStatus(r.URL.Query().Get("status"))
+	deployments, err := h.store.ListCanaryDeployments(fn.ID, status)
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to list canary deployments: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"canaries": deployments,
+		"total":    len(deployments),
+	})
+}
+
+// GetCanaryDeployment 获取金丝雀发布详情，包括最近的决策记录。
+// HTTP端点: GET /api/v1/functions/{id}/canaries/{canaryId}
+func (h *Handler) GetCanaryDeployment(w http.ResponseWriter, r *http.Request) {
+	canary, ok := h.lookupCanary(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, canary)
+}
+
+// AbortCanaryDeployment 手动终止金丝雀发布，流量全部切回稳定版本。
+// HTTP端点: POST /api/v1/functions/{id}/canaries/{canaryId}/abort
+func (h *Handler) AbortCanaryDeployment(w http.ResponseWriter, r *http.Request) {
+	canary, ok := h.lookupCanary(w, r)
+	if !ok {
+		return
+	}
+	if canary.IsTerminal() {
+		writeErrorWithContext(w, r, http.StatusConflict, "canary deployment is already "+string(canary.Status))
+		return
+	}
+
+	canary.Weight = 0
+	canary.Status = domain.CanaryStatusAborted
+	canary.HealthySince = nil
+	canary.Decisions = append(canary.Decisions, domain.CanaryDecision{
+		Action:    domain.CanaryActionRollback,
+		Weight:    0,
+		Reason:    "aborted manually",
+		DecidedAt: time.Now(),
+	})
+	// 控制器可能在读取后结束了该发布，此时不再改动别名
+	if err := h.store.ApplyCanaryDeployment(canary); err != nil {
+		if errors.Is(err, domain.ErrCanaryNotRunning) {
+			writeErrorWithContext(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		h.logError(r, "AbortCanaryDeployment", "更新别名权重失败", err, logrus.Fields{"canary_id": canary.ID})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to apply canary weight: "+err.Error())
+		return
+	}
+
+	h.logInfo(r, "AbortCanaryDeployment", "金丝雀发布已终止", logrus.Fields{"canary_id": canary.ID, "alias": canary.AliasName})
+	h.auditLog(r, "function.canary_abort", "function", canary.FunctionID, "", map[string]interface{}{
+		"canary_id":      canary.ID,
+		"alias":          canary.AliasName,
+		"canary_version": canary.CanaryVersion,
+	})
+	writeJSON(w, http.StatusOK, canary)
+}
+
+// CompareFunctionVersions 对比函数两个版本的错误率和耗时。
+// HTTP端点: GET /api/v1/functions/{id}/versions/compare
+//
+// 查询参数：
+//   - baseline: 基线版本号，必填
+//   - candidate: 候选版本号，必填
+//   - window: 统计窗口，如 1h、30m（默认 1h）
+func (h *Handler) CompareFunctionVersions(w http.ResponseWriter, r *http.Request) {
+	fn, ok := h.lookupFunction(w, r)
+	if !ok {
+		return
+	}
+
+	baseline, err1 := strconv.Atoi(r.URL.Query().Get("baseline"))
+	candidate, err2 := strconv.Atoi(r.URL.Query().Get("candidate"))
+	if err1 != nil || err2 != nil || baseline <= 0 || candidate <= 0 {
+		writeErrorWithContext(w, r, http.StatusBadRequest, "baseline and candidate must be positive version numbers")
+		return
+	}
+	window := time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeErrorWithContext(w, r, http.StatusBadRequest, "invalid window: "+v)
+			return
+		}
+		window = d
+	}
+
+	cmp, err := h.store.CompareVersionStats(fn.ID, baseline, candidate, time.Now().Add(-window))
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to compare versions: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, cmp)
+}
+
+// lookupFunction 按 URL 中的 ID 或名称查找函数，失败时写入错误响应
+func (h *Handler) lookupFunction(w http.ResponseWriter, r *http.Request) (*domain.Function, bool) {
+	idOrName := chi.URLParam(r, "id")
+
+	fn, err := h.store.GetFunctionByID(idOrName)
+	if err == domain.ErrFunctionNotFound {
+		fn, err = h.store.GetFunctionByName(idOrName)
+	}
+	if err == domain.ErrFunctionNotFound {
+		writeErrorWithContext(w, r, http.StatusNotFound, "function not found")
+		return nil, false
+	}
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get function: "+err.Error())
+		return nil, false
+	}
+	return fn, true
+}
+
+// lookupCanary 查找 URL 指定的金丝雀发布，并校验其属于 URL 中的函数
+func (h *Handler) lookupCanary(w http.ResponseWriter, r *http.Request) (*domain.CanaryDeployment, bool) {
+	fn, ok := h.lookupFunction(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	canary, err := h.store.GetCanaryDeployment(chi.URLParam(r, "canaryId"))
+	if err == domain.ErrCanaryNotFound || (err == nil && canary.FunctionID != fn.ID) {
+		writeErrorWithContext(w, r, http.StatusNotFound, domain.ErrCanaryNotFound.Error())
+		return nil, false
+	}
+	if err != nil {
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get canary deployment: "+err.Error())
+		return nil, false
+	}
+	return canary, true
+}
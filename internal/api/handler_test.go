@@ -256,6 +256,20 @@ func TestHealth(t *testing.T) {
 	}
 }
 
+// TestHealthReportsLeader 测试设置主节点状态来源后健康检查返回 leader 字段。
+func TestHealthReportsLeader(t *testing.T) {
+	h := &Handler{}
+	h.SetLeaderStatus(func() bool { return true })
+	w := httptest.NewRecorder()
+	h.Health(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["status"] != "healthy" || resp["leader"] != true {
+		t.Errorf("Health() = %v, want healthy with leader=true", resp)
+	}
+}
+
 // TestLive 测试存活探针端点。
 //
 // 测试内容：
@@ -282,3 +296,58 @@ func TestLive(t *testing.T) {
 		t.Errorf("Live() status = %s, want alive", resp["status"])
 	}
 }
+
+// TestGetVMMetrics 测试虚拟机运行指标端点。
+//
+// 测试内容：
+//   - 未设置指标来源（Docker 模式）时返回503
+//   - 返回各虚拟机指标及汇总值
+func TestGetVMMetrics(t *testing.T) {
+	c := &ConsoleHandler{}
+	w := httptest.NewRecorder()
+	c.GetVMMetrics(w, httptest.NewRequest(http.MethodGet, "/api/console/vms/metrics", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GetVMMetrics() without source status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	c.SetVMMetricsSource(func() []VMMetrics {
+		return []VMMetrics{
+			{VMID: "vm-1", CPUTimeMs: 100, BlockReadBytes: 4096, NetRxBytes: 10, ThrottledEvents: 1},
+			{VMID: "vm-2", CPUTimeMs: 50, BlockReadBytes: 1024, NetRxBytes: 5},
+		}
+	})
+	w = httptest.NewRecorder()
+	c.GetVMMetrics(w, httptest.NewRequest(http.MethodGet, "/api/console/vms/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetVMMetrics() status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var resp VMMetricsResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.VMCount != 2 || len(resp.VMs) != 2 {
+		t.Errorf("GetVMMetrics() vm_count = %d, vms = %d, want 2", resp.VMCount, len(resp.VMs))
+	}
+	if resp.Total.CPUTimeMs != 150 || resp.Total.BlockReadBytes != 5120 || resp.Total.NetRxBytes != 15 || resp.Total.ThrottledEvents != 1 {
+		t.Errorf("GetVMMetrics() total = %+v", resp.Total)
+	}
+}
+
+func TestInvokeCallChainHeaderAndStatus(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/functions/fn-b/invoke", nil)
+	if chain := requestCallChain(req); chain != nil {
+		t.Errorf("call chain without header = %v, want nil", chain)
+	}
+	req.Header.Set(domain.HeaderCallChain, "fn-a,fn-b")
+	chain := requestCallChain(req)
+	if len(chain) != 2 || chain[0] != "fn-a" || chain[1] != "fn-b" {
+		t.Errorf("call chain = %v, want [fn-a fn-b]", chain)
+	}
+
+	// 循环调用和调用链过长返回 508
+	if got := invokeErrorStatus(domain.CheckCallChain(chain, "fn-a", 0)); got != http.StatusLoopDetected {
+		t.Errorf("status for cycle = %d, want 508", got)
+	}
+	if got := invokeErrorStatus(domain.CheckCallChain(chain, "fn-c", 1)); got != http.StatusLoopDetected {
+		t.Errorf("status for depth exceeded = %d, want 508", got)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// idempotencyStore 按幂等键返回预设的函数
+type idempotencyStore struct {
+	functions map[string]*domain.Function
+	err       error
+}
+
+func (s *idempotencyStore) GetFunctionByIdempotencyKey(key string) (*domain.Function, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if fn, ok := s.functions[key]; ok {
+		return fn, nil
+	}
+	return nil, domain.ErrFunctionNotFound
+}
+
+func TestWriteIdempotentCreate(t *testing.T) {
+	store := &idempotencyStore{functions: map[string]*domain.Function{"key-1": {ID: "fn-1", Name: "hello"}}}
+	h := &Handler{}
+
+	tests := []struct {
+		name, key, fnName string
+		handled           bool
+		status            int
+	}{
+		{"no key", "", "hello", false, 0},
+		{"unknown key", "key-2", "hello", false, 0},
+		{"retry returns created function", "key-1", "hello", true, http.StatusOK},
+		{"key reused for another function", "key-1", "other", true, http.StatusUnprocessableEntity},
+		{"key too long", strings.Repeat("k", maxIdempotencyKeyLength+1), "hello", true, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/functions", nil)
+		handled := h.writeIdempotentCreate(rec, req, store, tt.key, tt.fnName)
+		if handled != tt.handled || (handled && rec.Code != tt.status) {
+			t.Errorf("%s: handled = %v status = %d, want %v %d", tt.name, handled, rec.Code, tt.handled, tt.status)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.writeIdempotentCreate(rec, httptest.NewRequest(http.MethodPost, "/api/v1/functions", nil), store, "key-1", "hello")
+	var fn domain.Function
+	if err := json.NewDecoder(rec.Body).Decode(&fn); err != nil || fn.ID != "fn-1" {
+		t.Errorf("response = %+v (%v), want function fn-1", fn, err)
+	}
+
+	// 查询失败时不继续创建
+	rec = httptest.NewRecorder()
+	failing := &idempotencyStore{err: errors.New("connection refused")}
+	if !h.writeIdempotentCreate(rec, httptest.NewRequest(http.MethodPost, "/api/v1/functions", nil), failing, "key-1", "hello") || rec.Code != http.StatusInternalServerError {
+		t.Errorf("lookup failure status = %d, want 500", rec.Code)
+	}
+}
@@ -0,0 +1,126 @@
+// Package api 提供了函数即服务(FaaS)平台的HTTP API处理程序。
+// 本文件实现调用输入校验：按函数的 input_schema 在调度前拒绝不合法的输入，避免为错误请求启动虚拟机。
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// InputValidationErrorResponse 是调用输入未通过 input_schema 校验时的响应体（HTTP 422）
+type InputValidationErrorResponse struct {
+	Error            string   `json:"error"`                // 错误消息
+	ValidationErrors []string `json:"validation_errors"`    // 逐条的校验错误，格式为 "<JSON Pointer>: <原因>"
+	Function         string   `json:"function"`             // 函数名称
+	RequestID        string   `json:"request_id,omitempty"` // 请求ID，用于关联日志
+}
+
+// inputSchemaCache 按函数 ID 缓存编译后的输入 schema，schema 内容变化时重新编译
+type inputSchemaCache struct {
+	mu      sync.Mutex
+	entries map[string]inputSchemaEntry
+}
+
+// inputSchemaEntry 是一个函数的 schema 原文及其编译结果
+type inputSchemaEntry struct {
+	raw    string
+	schema *jsonschema.Schema
+}
+
+// get 返回函数 input_schema 的编译结果
+func (c *inputSchemaCache) get(fn *domain.Function) (*jsonschema.Schema, error) {
+	raw := string(fn.InputSchema)
+	c.mu.Lock()
+	entry, ok := c.entries[fn.ID]
+	c.mu.Unlock()
+	if ok && entry.raw == raw {
+		return entry.schema, nil
+	}
+
+	schema, err := domain.CompileInputSchema(fn.InputSchema)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]inputSchemaEntry)
+	}
+	c.entries[fn.ID] = inputSchemaEntry{raw: raw, schema: schema}
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// inputValidationErrors 按函数的 input_schema 校验一组调用输入，未设置 schema 时不校验。
+// batch 为 true 时每条错误以 "inputs[i]: " 开头，标明批量调用中的输入位置。
+//
+// 返回值：
+//   - []string: 校验错误，全部通过时为空
+//   - error: schema 无法编译时返回错误
+func (h *Handler) inputValidationErrors(fn *domain.Function, inputs []json.RawMessage, batch bool) ([]string, error) {
+	if !domain.HasInputSchema(fn.InputSchema) {
+		return nil, nil
+	}
+	schema, err := h.inputSchemas.get(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	for i, input := range inputs {
+		var ve *domain.InputValidationError
+		if !errors.As(domain.ValidateInput(schema, input), &ve) {
+			continue
+		}
+		for _, msg := range ve.Errors {
+			if batch {
+				msg = fmt.Sprintf("inputs[%d]: %s", i, msg)
+			}
+			errs = append(errs, msg)
+		}
+	}
+	return errs, nil
+}
+
+// checkInvokeInput 校验单次调用的输入，未通过时写入错误响应并返回 false
+func (h *Handler) checkInvokeInput(w http.ResponseWriter, r *http.Request, action string, fn *domain.Function, payload json.RawMessage) bool {
+	errs, err := h.inputValidationErrors(fn, []json.RawMessage{payload}, false)
+	return h.writeInputValidationResult(w, r, action, fn, errs, err)
+}
+
+// checkBatchInvokeInput 校验批量调用的全部输入，任一输入不合法时写入错误响应并返回 false
+func (h *Handler) checkBatchInvokeInput(w http.ResponseWriter, r *http.Request, fn *domain.Function, inputs []json.RawMessage) bool {
+	errs, err := h.inputValidationErrors(fn, inputs, true)
+	return h.writeInputValidationResult(w, r, "InvokeFunctionBatch", fn, errs, err)
+}
+
+// writeInputValidationResult 在校验未通过时写入 422（schema 无法编译时写入 500）并返回 false，通过时返回 true
+func (h *Handler) writeInputValidationResult(w http.ResponseWriter, r *http.Request, action string, fn *domain.Function, errs []string, err error) bool {
+	if err != nil {
+		h.logError(r, action, "编译输入 schema 失败", err, logrus.Fields{"function": fn.Name})
+		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to compile input_schema: "+err.Error())
+		return false
+	}
+	if len(errs) == 0 {
+		return true
+	}
+
+	h.logWarn(r, action, "调用输入未通过 schema 校验", logrus.Fields{
+		"function": fn.Name,
+		"errors":   errs,
+	})
+	writeJSON(w, http.StatusUnprocessableEntity, InputValidationErrorResponse{
+		Error:            "input does not match input_schema",
+		ValidationErrors: errs,
+		Function:         fn.Name,
+		RequestID:        middleware.GetReqID(r.Context()),
+	})
+	return false
+}
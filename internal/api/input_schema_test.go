@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+func TestCheckInvokeInput(t *testing.T) {
+	h := &Handler{}
+	fn := &domain.Function{
+		ID:          "fn-1",
+		Name:        "greet",
+		InputSchema: json.RawMessage(`{"type":"object","required":["name"],"properties":{"name":{"type":"string"}}}`),
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/functions/greet/invoke", nil)
+	if !h.checkInvokeInput(rec, req, "InvokeFunction", fn, json.RawMessage(`{"name":"ada"}`)) {
+		t.Fatalf("valid input rejected: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	if h.checkInvokeInput(rec, req, "InvokeFunction", fn, json.RawMessage(`{"name":1}`)) {
+		t.Fatal("invalid input accepted")
+	}
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	var resp InputValidationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Function != "greet" || !reflect.DeepEqual(resp.ValidationErrors, []string{"/name: expected string, but got number"}) {
+		t.Errorf("response = %+v", resp)
+	}
+
+	// 未设置 schema 的函数不校验输入
+	rec = httptest.NewRecorder()
+	if !h.checkInvokeInput(rec, req, "InvokeFunction", &domain.Function{ID: "fn-2", Name: "raw"}, json.RawMessage(`not json`)) {
+		t.Errorf("function without input_schema rejected input: %s", rec.Body.String())
+	}
+
+	// 更新后的 schema 不使用缓存中的旧编译结果
+	updated := *fn
+	updated.InputSchema = json.RawMessage(`{"type":"object","required":["id"]}`)
+	rec = httptest.NewRecorder()
+	if h.checkInvokeInput(rec, req, "InvokeFunction", &updated, json.RawMessage(`{"name":"ada"}`)) {
+		t.Error("input accepted against stale schema")
+	}
+}
+
+func TestCheckBatchInvokeInput(t *testing.T) {
+	h := &Handler{}
+	fn := &domain.Function{ID: "fn-1", Name: "double", InputSchema: json.RawMessage(`{"type":"object","required":["n"]}`)}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/functions/double/invoke-batch", nil)
+
+	rec := httptest.NewRecorder()
+	inputs := []json.RawMessage{json.RawMessage(`{"n":1}`), json.RawMessage(`{}`), json.RawMessage(`{"n":3}`)}
+	if h.checkBatchInvokeInput(rec, req, fn, inputs) {
+		t.Fatal("batch with invalid input accepted")
+	}
+	var resp InputValidationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !reflect.DeepEqual(resp.ValidationErrors, []string{"inputs[1]: /: missing properties: 'n'"}) {
+		t.Errorf("validation_errors = %q", resp.ValidationErrors)
+	}
+
+	// schema 无法编译时返回 500
+	broken := &domain.Function{ID: "fn-3", Name: "broken", InputSchema: json.RawMessage(`{"type":12}`)}
+	rec = httptest.NewRecorder()
+	if h.checkBatchInvokeInput(rec, req, broken, inputs[:1]) || rec.Code != http.StatusInternalServerError {
+		t.Errorf("broken schema: status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage/storagetest"
+)
+
+// newListInvocationsRouter 返回挂在 /functions/{id}/invocations 上、以内存仓库为存储的调用记录列表路由
+func newListInvocationsRouter(store *storagetest.MemoryStore) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/functions/{id}/invocations", func(w http.ResponseWriter, r *http.Request) {
+		listInvocations(w, r, store, store)
+	})
+	return r
+}
+
+func TestListInvocationsPagesByCursor(t *testing.T) {
+	store := storagetest.NewMemoryStore()
+	fn := &domain.Function{Name: "hello"}
+	if err := store.CreateFunction(fn); err != nil {
+		t.Fatalf("CreateFunction: %v", err)
+	}
+	other := &domain.Function{Name: "other"}
+	store.CreateFunction(other)
+	store.CreateInvocation(domain.NewInvocation(other.ID, other.Name, domain.TriggerHTTP, nil))
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		inv := domain.NewInvocation(fn.ID, fn.Name, domain.TriggerHTTP, nil)
+		inv.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		store.CreateInvocation(inv)
+	}
+
+	router := newListInvocationsRouter(store)
+	var seen []string
+	cursor := ""
+	for page := 0; page < 3; page++ {
+		// 按名称查找函数，翻页时带上一页返回的游标
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/functions/hello/invocations?limit=2&before="+url.QueryEscape(cursor), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("page %d status = %d: %s", page, rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Invocations []*domain.Invocation `json:"invocations"`
+			NextCursor  string               `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode page %d: %v", page, err)
+		}
+		for _, inv := range resp.Invocations {
+			if inv.FunctionID != fn.ID {
+				t.Errorf("invocation %s belongs to %s, want %s", inv.ID, inv.FunctionID, fn.ID)
+			}
+			seen = append(seen, inv.ID)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+	if len(seen) != 3 {
+		t.Errorf("listed %d invocations, want 3: %v", len(seen), seen)
+	}
+
+	for path, want := range map[string]int{
+		"/functions/missing/invocations":               http.StatusNotFound,
+		"/functions/hello/invocations?before=bogus%21": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s status = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
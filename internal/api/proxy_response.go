@@ -0,0 +1,105 @@
+// Package api 提供了函数即服务(FaaS)平台的HTTP API处理程序。
+// 本文件实现 HTTP 触发函数的代理响应信封解析，使函数可以返回重定向、201、204 等自定义响应。
+//
+// 信封约定（函数返回值为如下 JSON 对象时视为代理响应）：
+//
+//	{
+//	  "statusCode": 301,                              // 必填，100-599，缺省或为 0 时不视为信封
+//	  "headers": {"Location": "https://example.com"}, // 可选，重定向通过 Location 头指定目标
+//	  "body": {...}                                   // 可选，按 JSON 编码写入响应体
+//	}
+//
+// 网关的处理规则：
+//   - statusCode 超出 100-599 时返回 502，不透传函数响应
+//   - 头名称必须是合法的 HTTP token，头名称和值中不允许出现 CR、LF 或 NUL，否则返回 502（防止响应头注入）
+//   - 1xx、204 和 304 响应以及未提供 body 的响应不写入响应体
+//   - 其余响应的 Content-Type 为 application/json，body 按 JSON 编码写入
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// proxyResponse 是函数返回的 HTTP 代理响应信封
+type proxyResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers"`
+	Body       json.RawMessage   `json:"body"`
+}
+
+// parseProxyResponse 尝试将函数输出解析为代理响应信封。
+// 输出不是 JSON 对象或未设置 statusCode 时返回 false，调用方按普通输出处理。
+func parseProxyResponse(output []byte) (*proxyResponse, bool) {
+	var pr proxyResponse
+	if err := json.Unmarshal(output, &pr); err != nil || pr.StatusCode == 0 {
+		return nil, false
+	}
+	return &pr, true
+}
+
+// validate 校验状态码和响应头，防止非法状态码和响应头注入
+func (pr *proxyResponse) validate() error {
+	if pr.StatusCode < 100 || pr.StatusCode > 599 {
+		return fmt.Errorf("invalid statusCode %d: must be between 100 and 599", pr.StatusCode)
+	}
+	for name, value := range pr.Headers {
+		if !isValidHeaderName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("invalid value for header %q: must not contain CR, LF or NUL", name)
+		}
+	}
+	return nil
+}
+
+// hasBody 判断响应是否需要写入响应体
+func (pr *proxyResponse) hasBody() bool {
+	if pr.StatusCode < 200 || pr.StatusCode == http.StatusNoContent || pr.StatusCode == http.StatusNotModified {
+		return false
+	}
+	return len(pr.Body) > 0 && string(pr.Body) != "null"
+}
+
+// writeProxyResponse 按信封约定写入函数的 HTTP 响应。
+// 函数输出不是信封时返回 false，不写入任何内容；信封无效时返回 502。
+func writeProxyResponse(w http.ResponseWriter, output []byte) bool {
+	pr, ok := parseProxyResponse(output)
+	if !ok {
+		return false
+	}
+	if err := pr.validate(); err != nil {
+		writeError(w, http.StatusBadGateway, "invalid function response: "+err.Error())
+		return true
+	}
+
+	for name, value := range pr.Headers {
+		w.Header().Set(name, value)
+	}
+	if !pr.hasBody() {
+		w.WriteHeader(pr.StatusCode)
+		return true
+	}
+	writeJSON(w, pr.StatusCode, pr.Body)
+	return true
+}
+
+// isValidHeaderName 判断头名称是否为 RFC 7230 定义的 token
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestWriteProxyResponseRedirect 测试函数返回 301 重定向。
+func TestWriteProxyResponseRedirect(t *testing.T) {
+	w := httptest.NewRecorder()
+	output := []byte(`{"statusCode":301,"headers":{"Location":"https://example.com/new"}}`)
+
+	if !writeProxyResponse(w, output) {
+		t.Fatal("writeProxyResponse() = false, want envelope handled")
+	}
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusMovedPermanently)
+	}
+	if got := w.Header().Get("Location"); got != "https://example.com/new" {
+		t.Errorf("Location = %q, want https://example.com/new", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want empty", w.Body.String())
+	}
+}
+
+// TestWriteProxyResponseNoContent 测试 204 响应不写入响应体。
+func TestWriteProxyResponseNoContent(t *testing.T) {
+	w := httptest.NewRecorder()
+	output := []byte(`{"statusCode":204,"body":{"ignored":true}}`)
+
+	if !writeProxyResponse(w, output) {
+		t.Fatal("writeProxyResponse() = false, want envelope handled")
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want empty", w.Body.String())
+	}
+}
+
+// TestWriteProxyResponseCreated 测试 201 响应写入 JSON 响应体和自定义头。
+func TestWriteProxyResponseCreated(t *testing.T) {
+	w := httptest.NewRecorder()
+	output := []byte(`{"statusCode":201,"headers":{"X-Resource-Id":"42"},"body":{"id":42}}`)
+
+	if !writeProxyResponse(w, output) {
+		t.Fatal("writeProxyResponse() = false, want envelope handled")
+	}
+	if w.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	if got := w.Header().Get("X-Resource-Id"); got != "42" {
+		t.Errorf("X-Resource-Id = %q, want 42", got)
+	}
+	if got := w.Body.String(); got != "{\"id\":42}\n" {
+		t.Errorf("body = %q, want {\"id\":42}", got)
+	}
+}
+
+// TestWriteProxyResponseRejectsInvalid 测试非法状态码和响应头注入返回 502。
+func TestWriteProxyResponseRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{"status too low", `{"statusCode":99}`},
+		{"status too high", `{"statusCode":600}`},
+		{"CRLF in header value", `{"statusCode":302,"headers":{"Location":"https://example.com\r\nSet-Cookie: a=b"}}`},
+		{"LF in header value", `{"statusCode":200,"headers":{"X-Test":"a\nb"}}`},
+		{"invalid header name", `{"statusCode":200,"headers":{"Bad Header":"x"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if !writeProxyResponse(w, []byte(tt.output)) {
+				t.Fatal("writeProxyResponse() = false, want envelope handled")
+			}
+			if w.Code != http.StatusBadGateway {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadGateway)
+			}
+			if w.Header().Get("Set-Cookie") != "" || w.Header().Get("Location") != "" {
+				t.Errorf("function headers must not be written: %v", w.Header())
+			}
+		})
+	}
+}
+
+// TestWriteProxyResponseNotEnvelope 测试非信封输出交由调用方处理。
+func TestWriteProxyResponseNotEnvelope(t *testing.T) {
+	for _, output := range []string{`{"message":"hello"}`, `[1,2,3]`, `"text"`, ``} {
+		w := httptest.NewRecorder()
+		if writeProxyResponse(w, []byte(output)) {
+			t.Errorf("writeProxyResponse(%q) = true, want false", output)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("writeProxyResponse(%q) wrote body %q", output, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package api 提供了函数即服务(FaaS)平台的HTTP API处理程序。
+// 本文件实现函数调用限流中间件：每个调用方（API Key、用户或客户端 IP）对每个函数一个令牌桶，
+// 令牌桶保存在 Redis 中，多个网关实例共享。
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oriys/nimbus/internal/auth"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/metrics"
+	"github.com/oriys/nimbus/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// 限流相关的系统设置
+const (
+	// settingRateLimitRPS 每个调用方对每个函数的每秒调用数上限，0 表示不限流
+	settingRateLimitRPS = "rate_limit_rps"
+	// settingRateLimitBurst 令牌桶容量，0 表示取 rate_limit_rps 向上取整
+	settingRateLimitBurst = "rate_limit_burst"
+	// rateLimitSettingsTTL 系统设置的缓存时间，修改设置后最多经过该时间生效
+	rateLimitSettingsTTL = 10 * time.Second
+)
+
+// TokenBucket 令牌桶存储，*storage.RedisStore 实现了该接口
+type TokenBucket interface {
+	// TakeTokens 从 key 对应的令牌桶取出 n 个令牌，返回是否取到以及距离令牌补足的时间
+	TakeTokens(ctx context.Context, key string, n int, rps float64, burst int, now time.Time) (bool, time.Duration, error)
+}
+
+// RateLimitStore 限流中间件读取系统设置和函数配置所需的存储方法，*storage.PostgresStore 实现了该接口
+type RateLimitStore interface {
+	GetSystemSetting(key string) (*storage.SystemSetting, error)
+	GetFunctionByID(id string) (*domain.Function, error)
+	GetFunctionByName(name string) (*domain.Function, error)
+}
+
+// RateLimiter 函数调用限流中间件。
+// 限流参数取函数的 rate_limit，未设置时取系统设置 rate_limit_rps/rate_limit_burst。
+// 令牌桶存储出错时放行请求，避免 Redis 故障导致所有调用失败。
+type RateLimiter struct {
+	buckets TokenBucket
+	store   RateLimitStore
+	metrics *metrics.Metrics
+	logger  *logrus.Logger
+	now     func() time.Time
+
+	mu         sync.Mutex
+	defaults   domain.InvokeRateLimit // 缓存的系统默认限流参数
+	defaultsAt time.Time              // defaults 的读取时间，零值表示尚未读取
+}
+
+// NewRateLimiter 创建调用限流中间件。
+//
+// 参数：
+//   - buckets: 令牌桶存储（通常为 Redis）
+//   - store: 读取系统设置和函数配置的存储
+//   - m: 指标收集器（可选），用于记录被限流的请求数
+//   - logger: 日志记录器
+//
+// 返回值：
+//   - *RateLimiter: 限流中间件实例
+func NewRateLimiter(buckets TokenBucket, store RateLimitStore, m *metrics.Metrics, logger *logrus.Logger) *RateLimiter {
+	return &RateLimiter{
+		buckets: buckets,
+		store:   store,
+		metrics: m,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// Limit 返回对函数调用路由限流的中间件，路由中需包含 {id} 参数（函数 ID 或名称）。
+// 每个请求取一个令牌；超过限制时返回 429 和 Retry-After 头（秒）；函数不存在时交给后续处理器返回 404。
+func (l *RateLimiter) Limit(next http.Handler) http.Handler {
+	return l.limit(next, func(*http.Request) int { return 1 })
+}
+
+// LimitBatch 返回对批量调用路由限流的中间件，每个请求按请求体中 inputs 的个数取令牌，
+// 批量调用与逐个调用消耗相同的配额。输入数超过桶容量的批次永远无法放行，直接返回 429。
+// 请求体无法解析时只取一个令牌，由后续处理器返回 400。
+func (l *RateLimiter) LimitBatch(next http.Handler) http.Handler {
+	return l.limit(next, batchInputCount)
+}
+
+// batchInputCount 读取批量调用请求体中 inputs 的个数（至少为 1），并恢复请求体供后续处理器读取
+func batchInputCount(r *http.Request) int {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 1
+	}
+	var req struct {
+		Inputs []json.RawMessage `json:"inputs"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || len(req.Inputs) == 0 {
+		return 1
+	}
+	return len(req.Inputs)
+}
+
+// limit 按 cost 计算的令牌数对请求限流
+func (l *RateLimiter) limit(next http.Handler, cost func(*http.Request) int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idOrName := chi.URLParam(r, "id")
+		fn, err := l.store.GetFunctionByID(idOrName)
+		if err == domain.ErrFunctionNotFound {
+			fn, err = l.store.GetFunctionByName(idOrName)
+		}
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rps, burst := effectiveRateLimit(l.defaultLimit(), fn.RateLimit)
+		if rps <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		n := cost(r)
+		if n > burst {
+			if l.metrics != nil {
+				l.metrics.RecordThrottled(fn.ID)
+			}
+			writeErrorWithContext(w, r, http.StatusTooManyRequests,
+				fmt.Sprintf("batch of %d inputs exceeds the rate limit burst of %d", n, burst))
+			return
+		}
+
+		allowed, retryAfter, err := l.buckets.TakeTokens(r.Context(), fn.ID+":"+rateLimitCaller(r), n, rps, burst, l.now())
+		if err != nil {
+			l.logger.WithError(err).WithField("function_id", fn.ID).Warn("Rate limit check failed, allowing request")
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !allowed {
+			if l.metrics != nil {
+				l.metrics.RecordThrottled(fn.ID)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeErrorWithContext(w, r, http.StatusTooManyRequests, "rate limit exceeded, retry after "+retryAfter.String())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// defaultLimit 返回系统设置中的默认限流参数，读取结果缓存 rateLimitSettingsTTL。
+// 设置不存在或无法解析时视为 0（不限流）。
+func (l *RateLimiter) defaultLimit() domain.InvokeRateLimit {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.defaultsAt.IsZero() && now.Sub(l.defaultsAt) < rateLimitSettingsTTL {
+		return l.defaults
+	}
+
+	var limit domain.InvokeRateLimit
+	if setting, err := l.store.GetSystemSetting(settingRateLimitRPS); err == nil {
+		if v, err := strconv.ParseFloat(setting.Value, 64); err == nil && v > 0 {
+			limit.RPS = v
+		}
+	}
+	if setting, err := l.store.GetSystemSetting(settingRateLimitBurst); err == nil {
+		if v, err := strconv.Atoi(setting.Value); err == nil && v > 0 {
+			limit.Burst = v
+		}
+	}
+	l.defaults, l.defaultsAt = limit, now
+	return limit
+}
+
+// effectiveRateLimit 合并系统默认值和函数的限流配置。
+// 函数设置了 rps 时整体覆盖默认值；只设置了 burst 时沿用默认 rps。
+// burst 未设置时取 rps 向上取整（至少为 1）。
+func effectiveRateLimit(defaults domain.InvokeRateLimit, override *domain.InvokeRateLimit) (float64, int) {
+	limit := defaults
+	if override != nil {
+		if override.RPS > 0 {
+			limit = *override
+		} else if override.Burst > 0 {
+			limit.Burst = override.Burst
+		}
+	}
+	if limit.RPS <= 0 {
+		return 0, 0
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Max(1, math.Ceil(limit.RPS)))
+	}
+	return limit.RPS, limit.Burst
+}
+
+// rateLimitCaller 返回请求的调用方标识：优先使用 API Key（只取哈希），
+// 其次为已认证的用户，否则为客户端 IP。
+func rateLimitCaller(r *http.Request) string {
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		return "key:" + auth.HashAPIKey(apiKey)
+	}
+	if user := auth.GetUser(r.Context()); user != nil && user.UserID != "" {
+		return "user:" + user.UserID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
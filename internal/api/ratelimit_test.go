@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/metrics"
+	"github.com/oriys/nimbus/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeRateLimitStore 在 MockStore 的函数存储之上提供系统设置
+type fakeRateLimitStore struct {
+	*MockStore
+	settings map[string]string
+}
+
+func (s *fakeRateLimitStore) GetSystemSetting(key string) (*storage.SystemSetting, error) {
+	if v, ok := s.settings[key]; ok {
+		return &storage.SystemSetting{Key: key, Value: v}, nil
+	}
+	return nil, errors.New("setting not found")
+}
+
+// memoryTokenBucket 是内存中的令牌桶，时间完全由调用方传入
+type memoryTokenBucket struct {
+	buckets map[string]*memoryBucketState
+	err     error
+	keys    []string
+}
+
+type memoryBucketState struct {
+	tokens float64
+	ts     time.Time
+}
+
+func (b *memoryTokenBucket) TakeTokens(ctx context.Context, key string, n int, rps float64, burst int, now time.Time) (bool, time.Duration, error) {
+	b.keys = append(b.keys, key)
+	if b.err != nil {
+		return false, 0, b.err
+	}
+	state, ok := b.buckets[key]
+	if !ok {
+		state = &memoryBucketState{tokens: float64(burst), ts: now}
+		b.buckets[key] = state
+	}
+	state.tokens = math.Min(float64(burst), state.tokens+now.Sub(state.ts).Seconds()*rps)
+	state.ts = now
+	if state.tokens >= float64(n) {
+		state.tokens -= float64(n)
+		return true, 0, nil
+	}
+	return false, time.Duration((float64(n) - state.tokens) / rps * float64(time.Second)), nil
+}
+
+// newTestRateLimiter 返回挂在 /functions/{id}/invoke 上的限流路由、令牌桶、存储、指标和假时钟
+func newTestRateLimiter(t *testing.T, settings map[string]string) (http.Handler, *memoryTokenBucket, *fakeRateLimitStore, *metrics.Metrics, *time.Time) {
+	t.Helper()
+	store := &fakeRateLimitStore{MockStore: NewMockStore(), settings: settings}
+	bucket := &memoryTokenBucket{buckets: make(map[string]*memoryBucketState)}
+	m := metrics.NewMetricsWithRegistry("nimbus", prometheus.NewRegistry())
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(bucket, store, m, logger)
+	limiter.now = func() time.Time { return now }
+
+	r := chi.NewRouter()
+	r.With(limiter.Limit).Post("/functions/{id}/invoke", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	r.With(limiter.LimitBatch).Post("/functions/{id}/invoke-batch", func(w http.ResponseWriter, r *http.Request) {
+		// 请求体已恢复，处理器仍能读到完整的批次
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+	return r, bucket, store, m, &now
+}
+
+// invokeAs 以 apiKey 调用函数并返回响应
+func invokeAs(router http.Handler, fn, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/functions/"+fn+"/invoke", nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiterExhaustsAndRefills(t *testing.T) {
+	router, _, store, m, now := newTestRateLimiter(t, map[string]string{
+		settingRateLimitRPS:   "0.5",
+		settingRateLimitBurst: "2",
+	})
+	store.CreateFunction(&domain.Function{ID: "fn-1", Name: "hello"})
+
+	// 按名称调用同样计入函数的令牌桶
+	for i, fn := range []string{"fn-1", "hello"} {
+		if rec := invokeAs(router, fn, "key-a"); rec.Code != http.StatusOK {
+			t.Fatalf("call %d: status = %d, want 200", i+1, rec.Code)
+		}
+	}
+	rec := invokeAs(router, "fn-1", "key-a")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Fatalf("exhausted bucket: status = %d, Retry-After = %q; want 429 with 2", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if got := testutil.ToFloat64(m.ThrottledInvocations.WithLabelValues("fn-1")); got != 1 {
+		t.Errorf("throttled counter = %v, want 1", got)
+	}
+
+	// 其他 API Key 有独立的令牌桶
+	if rec := invokeAs(router, "fn-1", "key-b"); rec.Code != http.StatusOK {
+		t.Errorf("other key: status = %d, want 200", rec.Code)
+	}
+
+	// 每 2 秒补充一个令牌
+	*now = now.Add(1500 * time.Millisecond)
+	if rec := invokeAs(router, "fn-1", "key-a"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("after 1.5s: status = %d, Retry-After = %q; want 429 with 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	*now = now.Add(500 * time.Millisecond)
+	if rec := invokeAs(router, "fn-1", "key-a"); rec.Code != http.StatusOK {
+		t.Errorf("after refill: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimiterFunctionOverride(t *testing.T) {
+	router, bucket, store, _, _ := newTestRateLimiter(t, map[string]string{settingRateLimitRPS: "100"})
+	store.CreateFunction(&domain.Function{ID: "fn-1", Name: "strict", RateLimit: &domain.InvokeRateLimit{RPS: 1}})
+
+	if rec := invokeAs(router, "fn-1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("first call: status = %d, want 200", rec.Code)
+	}
+	if rec := invokeAs(router, "fn-1", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second call: status = %d, want 429 from the function's 1 rps limit", rec.Code)
+	}
+	// 没有 API Key 时按客户端 IP 计数
+	if len(bucket.keys) == 0 || bucket.keys[0] != "fn-1:ip:192.0.2.1" {
+		t.Errorf("bucket keys = %v, want fn-1:ip:192.0.2.1", bucket.keys)
+	}
+}
+
+func TestRateLimiterChargesBatchPerInput(t *testing.T) {
+	router, _, store, _, _ := newTestRateLimiter(t, map[string]string{settingRateLimitRPS: "1", settingRateLimitBurst: "5"})
+	store.CreateFunction(&domain.Function{ID: "fn-1", Name: "hello"})
+
+	batch := func(n int) *httptest.ResponseRecorder {
+		body := `{"inputs":[` + strings.TrimSuffix(strings.Repeat(`{},`, n), ",") + `]}`
+		req := httptest.NewRequest(http.MethodPost, "/functions/fn-1/invoke-batch", strings.NewReader(body))
+		req.Header.Set("X-API-Key", "key-a")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code == http.StatusOK && rec.Body.String() != body {
+			t.Fatalf("handler read body %q, want the original batch", rec.Body.String())
+		}
+		return rec
+	}
+
+	// 4 个输入取 4 个令牌，剩余 1 个令牌不足以放行下一批 2 个输入
+	if rec := batch(4); rec.Code != http.StatusOK {
+		t.Fatalf("batch of 4: status = %d, want 200", rec.Code)
+	}
+	if rec := batch(2); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("batch of 2 with 1 token left: status = %d, Retry-After = %q; want 429 with 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := invokeAs(router, "fn-1", "key-a"); rec.Code != http.StatusOK {
+		t.Errorf("single call with 1 token left: status = %d, want 200", rec.Code)
+	}
+	// 超过桶容量的批次永远无法放行
+	if rec := batch(6); rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "burst of 5") {
+		t.Errorf("batch larger than burst: status = %d body = %s, want 429 naming the burst", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRateLimiterDisabledAndFailOpen(t *testing.T) {
+	router, bucket, store, _, now := newTestRateLimiter(t, map[string]string{settingRateLimitRPS: "0"})
+	store.CreateFunction(&domain.Function{ID: "fn-1", Name: "hello"})
+
+	for i := 0; i < 5; i++ {
+		if rec := invokeAs(router, "fn-1", "key-a"); rec.Code != http.StatusOK {
+			t.Fatalf("unlimited call %d: status = %d", i+1, rec.Code)
+		}
+	}
+	if len(bucket.keys) != 0 {
+		t.Errorf("bucket used while rate limiting is disabled: %v", bucket.keys)
+	}
+	// 不存在的函数交给处理器处理
+	if rec := invokeAs(router, "missing", "key-a"); rec.Code != http.StatusOK {
+		t.Errorf("unknown function: status = %d, want passthrough", rec.Code)
+	}
+
+	// 设置修改后在缓存过期时生效；令牌桶出错时放行
+	store.settings[settingRateLimitRPS] = "1"
+	bucket.err = errors.New("redis unavailable")
+	*now = now.Add(rateLimitSettingsTTL)
+	if rec := invokeAs(router, "fn-1", "key-a"); rec.Code != http.StatusOK {
+		t.Errorf("bucket error: status = %d, want request allowed", rec.Code)
+	}
+	if len(bucket.keys) != 1 {
+		t.Errorf("bucket calls after settings change = %v, want 1", bucket.keys)
+	}
+}
+
+func TestEffectiveRateLimit(t *testing.T) {
+	defaults := domain.InvokeRateLimit{RPS: 10, Burst: 50}
+	tests := []struct {
+		name      string
+		defaults  domain.InvokeRateLimit
+		override  *domain.InvokeRateLimit
+		wantRPS   float64
+		wantBurst int
+	}{
+		{"defaults", defaults, nil, 10, 50},
+		{"override rps", defaults, &domain.InvokeRateLimit{RPS: 2.5}, 2.5, 3},
+		{"override burst only", defaults, &domain.InvokeRateLimit{Burst: 5}, 10, 5},
+		{"burst from rps", domain.InvokeRateLimit{RPS: 0.2}, nil, 0.2, 1},
+		{"disabled", domain.InvokeRateLimit{}, &domain.InvokeRateLimit{Burst: 5}, 0, 0},
+	}
+	for _, tt := range tests {
+		rps, burst := effectiveRateLimit(tt.defaults, tt.override)
+		if rps != tt.wantRPS || burst != tt.wantBurst {
+			t.Errorf("%s: got (%v, %d), want (%v, %d)", tt.name, rps, burst, tt.wantRPS, tt.wantBurst)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// recordingScheduler 记录收到的同步调用请求，并以固定 ID 作为新调用的 ID
+type recordingScheduler struct {
+	MockScheduler
+	requests []*domain.InvokeRequest
+}
+
+func (s *recordingScheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeResponse, error) {
+	s.requests = append(s.requests, req)
+	return &domain.InvokeResponse{RequestID: "inv-replay", StatusCode: http.StatusOK, Body: json.RawMessage(`{"ok":true}`)}, nil
+}
+
+// newReplayRouter 返回挂在 /invocations/{id}/replay 上的重放路由
+func newReplayRouter(store *MockStore, sched *recordingScheduler) http.Handler {
+	h := &Handler{scheduler: sched}
+	r := chi.NewRouter()
+	r.Post("/invocations/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		h.replayInvocation(w, r, store)
+	})
+	return r
+}
+
+func TestReplayInvocationCompleted(t *testing.T) {
+	store := NewMockStore()
+	store.CreateFunction(&domain.Function{ID: "fn-1", Name: "hello", Status: domain.FunctionStatusActive, Version: 3})
+	store.invocations["inv-1"] = &domain.Invocation{
+		ID:         "inv-1",
+		FunctionID: "fn-1",
+		Status:     domain.InvocationStatusSuccess,
+		Input:      json.RawMessage(`{"name":"nimbus"}`),
+		Version:    1,
+	}
+	sched := &recordingScheduler{}
+
+	rec := httptest.NewRecorder()
+	newReplayRouter(store, sched).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/invocations/inv-1/replay", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	// 用原始输入调用函数当前版本，并关联原始调用
+	if len(sched.requests) != 1 {
+		t.Fatalf("scheduler got %d requests, want 1", len(sched.requests))
+	}
+	req := sched.requests[0]
+	if req.FunctionID != "fn-1" || string(req.Payload) != `{"name":"nimbus"}` || req.ReplayOf != "inv-1" || req.Version != 0 {
+		t.Errorf("invoke request = %+v, want original input for the current version with replay_of inv-1", req)
+	}
+
+	var resp map[string]interface{}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp["invocation_id"] != "inv-replay" || resp["replay_of"] != "inv-1" {
+		t.Errorf("response ids = %v / %v, want inv-replay / inv-1", resp["invocation_id"], resp["replay_of"])
+	}
+}
+
+func TestReplayInvocationUnknownID(t *testing.T) {
+	sched := &recordingScheduler{}
+	rec := httptest.NewRecorder()
+	newReplayRouter(NewMockStore(), sched).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/invocations/missing/replay", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+	if len(sched.requests) != 0 {
+		t.Errorf("scheduler invoked for an unknown invocation")
+	}
+}
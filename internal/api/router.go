@@ -24,10 +24,16 @@ type RouterConfig struct {
 	SnapshotHandler *SnapshotHandler
 	// StateHandler 状态处理器（可选）
 	StateHandler *StateHandler
+	// PoolStats 虚拟机池统计来源（可选），用于控制台系统状态
+	PoolStats func() []PoolStats
+	// VMMetrics 虚拟机运行指标来源（可选），仅 Firecracker 模式下存在
+	VMMetrics func() []VMMetrics
 	// Logger 日志记录器
 	Logger *logrus.Logger
 	// WebFS 前端静态文件系统（可选，用于嵌入前端资源）
 	WebFS fs.FS
+	// RateLimiter 函数调用限流中间件（可选），为空时不限流
+	RateLimiter *RateLimiter
 }
 
 // NewRouter 创建并配置HTTP路由器。
@@ -57,6 +63,13 @@ type RouterConfig struct {
 //	/*                   - 前端静态文件（如果配置了WebFS）
 func NewRouter(cfg *RouterConfig) *chi.Mux {
 	h := cfg.Handler
+	// 函数调用路由的限流中间件，未配置时直接放行
+	limitInvoke := func(next http.Handler) http.Handler { return next }
+	limitBatchInvoke := limitInvoke
+	if cfg.RateLimiter != nil {
+		limitInvoke = cfg.RateLimiter.Limit
+		limitBatchInvoke = cfg.RateLimiter.LimitBatch
+	}
 	// 创建新的chi路由器
 	r := chi.NewRouter()
 
@@ -107,14 +120,18 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 			r.Post("/", h.CreateFunction)
 			// GET /api/v1/functions - 获取函数列表
 			r.Get("/", h.ListFunctions)
-			// POST /api/v1/functions/import - 导入函数
+			// POST /api/v1/functions/import - 从导出包导入函数
 			r.Post("/import", h.ImportFunction)
 			// POST /api/v1/functions/bulk-delete - 批量删除函数
 			r.Post("/bulk-delete", h.BulkDeleteFunctions)
 			// POST /api/v1/functions/bulk-update - 批量更新函数
 			r.Post("/bulk-update", h.BulkUpdateFunctions)
+			// POST /api/v1/functions/bulk-status - 批量更新函数状态（单条语句）
+			r.Post("/bulk-status", h.BulkUpdateFunctionStatus)
 			// POST /api/v1/functions/from-template - 从模板创建函数
 			r.Post("/from-template", h.CreateFunctionFromTemplate)
+			// POST /api/v1/functions/validate - 预检函数定义（不保存）
+			r.Post("/validate", h.ValidateFunction)
 
 			// 单个函数的操作路由组
 			r.Route("/{id}", func(r chi.Router) {
@@ -127,9 +144,11 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 				// POST /api/v1/functions/{id}/clone - 克隆函数
 				r.Post("/clone", h.CloneFunction)
 				// POST /api/v1/functions/{id}/invoke - 同步调用函数
-				r.Post("/invoke", h.InvokeFunction)
+				r.With(limitInvoke).Post("/invoke", h.InvokeFunction)
+				// POST /api/v1/functions/{id}/invoke-batch - 批量同步调用函数
+				r.With(limitBatchInvoke).Post("/invoke-batch", h.InvokeFunctionBatch)
 				// POST /api/v1/functions/{id}/async - 异步调用函数
-				r.Post("/async", h.InvokeFunctionAsync)
+				r.With(limitInvoke).Post("/async", h.InvokeFunctionAsync)
 				// GET /api/v1/functions/{id}/invocations - 获取函数的调用记录
 				r.Get("/invocations", h.ListInvocations)
 
@@ -142,8 +161,10 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 				r.Post("/recompile", h.RecompileFunction)
 				// POST /api/v1/functions/{id}/pin - 置顶/取消置顶函数
 				r.Post("/pin", h.PinFunction)
-				// GET /api/v1/functions/{id}/export - 导出函数配置
+				// GET /api/v1/functions/{id}/export - 导出函数及其依赖
 				r.Get("/export", h.ExportFunction)
+				// POST /api/v1/functions/{id}/transfer - 转移函数所有权
+				r.Post("/transfer", h.TransferFunctionOwnership)
 
 				// Webhook 管理路由组
 				r.Route("/webhook", func(r chi.Router) {
@@ -163,6 +184,8 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 					r.Get("/", h.ListFunctionVersions)
 					// GET /api/v1/functions/{id}/versions/{version} - 获取指定版本
 					r.Get("/{version}", h.GetFunctionVersion)
+					// GET /api/v1/functions/{id}/versions/compare - 对比两个版本的指标
+					r.Get("/compare", h.CompareFunctionVersions)
 					// POST /api/v1/functions/{id}/versions/{version}/rollback - 回滚到指定版本
 					r.Post("/{version}/rollback", h.RollbackFunction)
 				})
@@ -179,6 +202,18 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 					r.Delete("/{name}", h.DeleteFunctionAlias)
 				})
 
+				// 金丝雀发布路由组
+				r.Route("/canaries", func(r chi.Router) {
+					// GET /api/v1/functions/{id}/canaries - 获取金丝雀发布列表
+					r.Get("/", h.ListCanaryDeployments)
+					// POST /api/v1/functions/{id}/canaries - 启动金丝雀发布
+					r.Post("/", h.StartCanaryDeployment)
+					// GET /api/v1/functions/{id}/canaries/{canaryId} - 获取金丝雀发布详情
+					r.Get("/{canaryId}", h.GetCanaryDeployment)
+					// POST /api/v1/functions/{id}/canaries/{canaryId}/abort - 终止金丝雀发布
+					r.Post("/{canaryId}/abort", h.AbortCanaryDeployment)
+				})
+
 				// 层管理路由组（函数级别）
 				r.Route("/layers", func(r chi.Router) {
 					// GET /api/v1/functions/{id}/layers - 获取函数的层
@@ -194,6 +229,8 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 					// PUT /api/v1/functions/{id}/environments/{env} - 更新函数环境配置
 					r.Put("/{env}", h.UpdateFunctionEnvConfig)
 				})
+				// POST /api/v1/functions/{id}/promote - 将函数配置从一个环境晋升到另一个环境
+				r.Post("/promote", h.PromoteFunction)
 
 				// 预热管理路由组
 				r.Route("/warming", func(r chi.Router) {
@@ -205,6 +242,14 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 				// POST /api/v1/functions/{id}/warm - 触发预热
 				r.Post("/warm", h.TriggerWarming)
 
+				// 结果投递目标路由组
+				r.Route("/destinations", func(r chi.Router) {
+					// GET /api/v1/functions/{id}/destinations - 获取结果投递配置
+					r.Get("/", h.GetFunctionDestinations)
+					// PUT /api/v1/functions/{id}/destinations - 更新结果投递配置
+					r.Put("/", h.UpdateFunctionDestinations)
+				})
+
 				// GET /api/v1/functions/{id}/dependencies - 获取函数依赖关系
 				r.Get("/dependencies", h.GetFunctionDependencies)
 				// GET /api/v1/functions/{id}/impact - 获取影响分析
@@ -216,10 +261,16 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 		r.Route("/invocations", func(r chi.Router) {
 			// GET /api/v1/invocations - 获取所有调用记录列表
 			r.Get("/", h.ListAllInvocations)
+			// GET /api/v1/invocations/active - 获取正在执行的调用列表
+			r.Get("/active", h.ListActiveInvocations)
 			// GET /api/v1/invocations/{id} - 获取调用记录详情
 			r.Get("/{id}", h.GetInvocation)
+			// GET /api/v1/invocations/{id}/explain - 获取调用的执行路径决策记录
+			r.Get("/{id}/explain", h.GetInvocationExplain)
 			// POST /api/v1/invocations/{id}/replay - 重放调用
 			r.Post("/{id}/replay", h.ReplayInvocation)
+			// POST /api/v1/invocations/{id}/cancel - 取消正在执行的调用
+			r.Post("/{id}/cancel", h.CancelInvocation)
 		})
 
 		// GET /api/v1/stats - 获取系统统计信息
@@ -256,6 +307,8 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 			r.Post("/", h.CreateEnvironment)
 			// DELETE /api/v1/environments/{id} - 删除环境
 			r.Delete("/{id}", h.DeleteEnvironment)
+			// PUT /api/v1/environments/{id}/env-vars - 更新环境默认环境变量
+			r.Put("/{id}/env-vars", h.UpdateEnvironmentEnvVars)
 		})
 
 		// 死信队列 (DLQ) 管理路由组
@@ -407,6 +460,8 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 					r.Post("/stop", wh.StopExecution)
 					// GET /api/v1/executions/{id}/history - 获取执行历史
 					r.Get("/history", wh.GetExecutionHistory)
+					// GET /api/v1/executions/{id}/latency - 获取执行延迟分解
+					r.Get("/latency", wh.GetExecutionLatency)
 					// POST /api/v1/executions/{id}/resume - 恢复暂停的执行
 					r.Post("/resume", wh.ResumeExecution)
 
@@ -428,6 +483,18 @@ func NewRouter(cfg *RouterConfig) *chi.Mux {
 	// 提供仪表板、函数测试、实时日志等功能的API
 	if cfg.Logger != nil {
 		consoleHandler := NewConsoleHandler(h, h.store, cfg.Logger)
+		if cfg.StateHandler != nil && cfg.StateHandler.stateHandler != nil {
+			consoleHandler.SetStateHandler(cfg.StateHandler.stateHandler)
+		}
+		if cfg.SnapshotHandler != nil {
+			consoleHandler.SetSnapshotManager(cfg.SnapshotHandler.snapshotMgr)
+		}
+		if cfg.PoolStats != nil {
+			consoleHandler.SetPoolStatsSource(cfg.PoolStats)
+		}
+		if cfg.VMMetrics != nil {
+			consoleHandler.SetVMMetricsSource(cfg.VMMetrics)
+		}
 		debugHandler := NewDebugHandler(h.store, cfg.Logger)
 		r.Route("/api", func(r chi.Router) {
 			consoleHandler.RegisterRoutes(r)
@@ -3,6 +3,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -27,13 +28,16 @@ func NewStateHandler(h *Handler, stateHandler *state.Handler, sessionRouter *sch
 }
 
 // RegisterRoutes 注册状态相关路由
+// 会话管理路由依赖会话路由器，未配置（如 Docker 模式）时不注册
 func (sh *StateHandler) RegisterRoutes(r chi.Router) {
 	// 会话管理路由
-	r.Route("/functions/{id}/sessions", func(r chi.Router) {
-		r.Get("/", sh.ListSessions)
-		r.Get("/{sessionKey}", sh.GetSession)
-		r.Delete("/{sessionKey}", sh.DeleteSession)
-	})
+	if sh.sessionRouter != nil {
+		r.Route("/functions/{id}/sessions", func(r chi.Router) {
+			r.Get("/", sh.ListSessions)
+			r.Get("/{sessionKey}", sh.GetSession)
+			r.Delete("/{sessionKey}", sh.DeleteSession)
+		})
+	}
 
 	// 状态管理路由
 	r.Route("/functions/{id}/state", func(r chi.Router) {
@@ -148,7 +152,7 @@ func (sh *StateHandler) GetSessionState(w http.ResponseWriter, r *http.Request)
 
 	keyInfos, totalSize, err := sh.stateHandler.GetSessionState(r.Context(), functionID, sessionKey)
 	if err != nil {
-		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to get session state: "+err.Error())
+		writeErrorWithContext(w, r, stateErrorStatus(err), "failed to get session state: "+err.Error())
 		return
 	}
 
@@ -176,7 +180,7 @@ func (sh *StateHandler) DeleteSessionState(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := sh.stateHandler.DeleteSessionState(r.Context(), functionID, sessionKey); err != nil {
-		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to delete session state: "+err.Error())
+		writeErrorWithContext(w, r, stateErrorStatus(err), "failed to delete session state: "+err.Error())
 		return
 	}
 
@@ -201,13 +205,22 @@ func (sh *StateHandler) DeleteStateKey(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := sh.stateHandler.DeleteStateKey(r.Context(), functionID, sessionKey, key); err != nil {
-		writeErrorWithContext(w, r, http.StatusInternalServerError, "failed to delete state key: "+err.Error())
+		writeErrorWithContext(w, r, stateErrorStatus(err), "failed to delete state key: "+err.Error())
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// stateErrorStatus 根据状态操作错误选择 HTTP 状态码：
+// 状态后端（Redis）不可用时返回 503，其他错误返回 500
+func stateErrorStatus(err error) int {
+	if errors.Is(err, state.ErrBackendUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
 // parseInt 解析整数，失败时返回默认值
 func parseInt(s string, defaultVal int) int {
 	var val int
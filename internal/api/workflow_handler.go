@@ -184,10 +184,15 @@ func (h *WorkflowHandler) DeleteWorkflow(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// StartExecution 启动工作流执行
+// StartExecution 启动工作流执行，{id} 可以是工作流 ID 或名称
 // POST /api/v1/workflows/{id}/executions
 func (h *WorkflowHandler) StartExecution(w http.ResponseWriter, r *http.Request) {
 	workflowID := chi.URLParam(r, "id")
+	if _, err := h.store.GetWorkflowByID(workflowID); err == domain.ErrWorkflowNotFound {
+		if wf, err := h.store.GetWorkflowByName(workflowID); err == nil {
+			workflowID = wf.ID
+		}
+	}
 
 	// 解析请求
 	var req domain.StartExecutionRequest
@@ -326,6 +331,24 @@ func (h *WorkflowHandler) GetExecutionHistory(w http.ResponseWriter, r *http.Req
 	})
 }
 
+// GetExecutionLatency 获取执行的延迟分解
+// GET /api/v1/executions/{id}/latency
+func (h *WorkflowHandler) GetExecutionLatency(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	breakdown, err := h.store.GetWorkflowLatencyBreakdown(id)
+	if err != nil {
+		if err == domain.ErrExecutionNotFound {
+			h.writeError(w, http.StatusNotFound, "execution not found", err)
+		} else {
+			h.writeError(w, http.StatusInternalServerError, "failed to get execution latency", err)
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, breakdown)
+}
+
 // getPagination 获取分页参数
 func (h *WorkflowHandler) getPagination(r *http.Request) (offset, limit int) {
 	offset = 0
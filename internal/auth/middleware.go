@@ -28,6 +28,8 @@ type UserContext struct {
 	Role string
 	// Method 认证方式，可能的值为 "jwt" 或 "apikey"
 	Method string
+	// Scopes API Key 的权限范围，为空表示不受限制（JWT 认证的用户总是为空）
+	Scopes []string
 }
 
 // APIKeyValidator 定义了 API Key 验证器的接口。
@@ -76,6 +78,7 @@ func NewMiddleware(jwt *JWTManager, apiKeyHeader string, keyValidator APIKeyVali
 // Authenticate 是一个 HTTP 中间件函数，用于验证请求的身份。
 // 它首先尝试 API Key 认证，如果失败则尝试 JWT Bearer Token 认证。
 // 认证成功后，用户信息会被存储在请求的 context 中。
+// 设置了权限范围的 API Key 访问其权限范围之外的路由时返回 403。
 // 参数:
 //   - next: 下一个要执行的 HTTP 处理器
 //
@@ -96,6 +99,11 @@ func (m *Middleware) Authenticate(next http.Handler) http.Handler {
 			if m.keyValidator != nil {
 				// 验证 API Key 的有效性
 				if user, err := m.keyValidator.ValidateAPIKey(apiKey); err == nil {
+					// 检查密钥的权限范围是否覆盖本次请求
+					if !user.HasScope(RequiredScope(r)) {
+						http.Error(w, `{"error":"insufficient scope"}`, http.StatusForbidden)
+						return
+					}
 					// API Key 验证成功，将用户信息存入 context
 					ctx := context.WithValue(r.Context(), UserContextKey, user)
 					// 使用新的 context 继续处理请求
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// staticKeyValidator 按密钥原文返回预置的用户上下文
+type staticKeyValidator map[string]*UserContext
+
+func (v staticKeyValidator) ValidateAPIKey(key string) (*UserContext, error) {
+	if user, ok := v[key]; ok {
+		return user, nil
+	}
+	return nil, errors.New("invalid api key")
+}
+
+func TestAuthenticateEnforcesScopes(t *testing.T) {
+	validator := staticKeyValidator{
+		"read-only": {UserID: "u1", Role: "user", Method: "apikey", Scopes: []string{ScopeFunctionsRead}},
+		"invoker":   {UserID: "u1", Role: "user", Method: "apikey", Scopes: []string{ScopeFunctionsInvoke}},
+		"legacy":    {UserID: "u1", Role: "user", Method: "apikey"},
+	}
+	m := NewMiddleware(NewJWTManager("secret", 0), "X-API-Key", validator, true)
+	handler := m.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		key, method, path string
+		want              int
+	}{
+		{"read-only", http.MethodGet, "/api/v1/functions", http.StatusOK},
+		{"read-only", http.MethodGet, "/api/v1/invocations/inv-1", http.StatusOK},
+		{"read-only", http.MethodPost, "/api/v1/functions", http.StatusForbidden},
+		{"read-only", http.MethodPut, "/api/v1/functions/fn-1", http.StatusForbidden},
+		{"read-only", http.MethodPost, "/api/v1/functions/fn-1/invoke", http.StatusForbidden},
+		{"read-only", http.MethodGet, "/api/v1/workflows", http.StatusForbidden},
+		{"read-only", http.MethodGet, "/api/v1/stats", http.StatusForbidden},
+		{"invoker", http.MethodPost, "/api/v1/functions/fn-1/invoke", http.StatusOK},
+		{"invoker", http.MethodPost, "/api/v1/functions/fn-1/async", http.StatusOK},
+		{"invoker", http.MethodDelete, "/api/v1/functions/fn-1", http.StatusForbidden},
+		{"legacy", http.MethodDelete, "/api/v1/functions/fn-1", http.StatusOK},
+		{"legacy", http.MethodGet, "/api/v1/stats", http.StatusOK},
+		{"unknown", http.MethodGet, "/api/v1/functions", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-API-Key", tt.key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s with %s key: status = %d, want %d", tt.method, tt.path, tt.key, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestNarrowScopes(t *testing.T) {
+	parent := []string{ScopeFunctionsRead, ScopeFunctionsInvoke}
+	if got, err := NarrowScopes(parent, nil); err != nil || len(got) != 2 {
+		t.Errorf("NarrowScopes(parent, nil) = %v, %v; want parent scopes", got, err)
+	}
+	if got, err := NarrowScopes(parent, []string{ScopeFunctionsRead}); err != nil || len(got) != 1 {
+		t.Errorf("NarrowScopes(parent, [read]) = %v, %v", got, err)
+	}
+	if _, err := NarrowScopes(parent, []string{ScopeFunctionsWrite}); err == nil {
+		t.Error("NarrowScopes allowed a scope beyond the parent's")
+	}
+	if got, err := NarrowScopes(nil, nil); err != nil || len(got) != 0 {
+		t.Errorf("NarrowScopes(nil, nil) = %v, %v; want unrestricted", got, err)
+	}
+}
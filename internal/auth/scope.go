@@ -0,0 +1,133 @@
+// Package auth 提供身份认证和授权相关的功能。
+// 本文件实现 API Key 的权限范围（scope）：在 user/admin 角色之外，把密钥限制为只读或特定操作。
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// API Key 权限范围
+const (
+	// ScopeFunctionsRead 查看函数、版本、调用记录等
+	ScopeFunctionsRead = "functions:read"
+	// ScopeFunctionsWrite 创建、更新、删除函数及其配置
+	ScopeFunctionsWrite = "functions:write"
+	// ScopeFunctionsInvoke 调用函数（同步、批量、异步）和重放调用
+	ScopeFunctionsInvoke = "functions:invoke"
+	// ScopeWorkflowsRead 查看工作流和执行
+	ScopeWorkflowsRead = "workflows:read"
+	// ScopeWorkflowsWrite 创建、更新、删除工作流和断点
+	ScopeWorkflowsWrite = "workflows:write"
+	// ScopeWorkflowsInvoke 启动、停止、恢复工作流执行
+	ScopeWorkflowsInvoke = "workflows:invoke"
+)
+
+// knownScopes 是所有合法的权限范围
+var knownScopes = map[string]bool{
+	ScopeFunctionsRead:   true,
+	ScopeFunctionsWrite:  true,
+	ScopeFunctionsInvoke: true,
+	ScopeWorkflowsRead:   true,
+	ScopeWorkflowsWrite:  true,
+	ScopeWorkflowsInvoke: true,
+}
+
+// ValidateScopes 检查权限范围列表中的每一项都是已知的权限范围
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// NarrowScopes 返回由持有 parent 权限范围的调用方创建的新密钥的权限范围，新密钥的权限不能超过调用方。
+// 调用方不受限制时原样返回 requested；调用方受限而 requested 为空时继承调用方的权限范围。
+func NarrowScopes(parent, requested []string) ([]string, error) {
+	if len(parent) == 0 {
+		return requested, nil
+	}
+	if len(requested) == 0 {
+		return parent, nil
+	}
+	for _, scope := range requested {
+		if !HasScope(parent, scope) {
+			return nil, fmt.Errorf("scope %q exceeds the caller's scopes", scope)
+		}
+	}
+	return requested, nil
+}
+
+// HasScope 判断权限范围列表是否允许 scope。
+// 空列表表示密钥不受权限范围限制（只按角色控制），兼容未设置权限范围的旧密钥；
+// 非空列表只允许其中列出的权限范围，scope 为空（请求不属于任何权限范围）时总是拒绝。
+func HasScope(scopes []string, scope string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope && scope != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope 判断已认证用户是否拥有 scope，见 HasScope
+func (u *UserContext) HasScope(scope string) bool {
+	return u != nil && HasScope(u.Scopes, scope)
+}
+
+// functionInvokeActions 是 /api/v1/functions/{id}/ 下属于调用的操作
+var functionInvokeActions = map[string]bool{
+	"invoke":       true,
+	"invoke-batch": true,
+	"async":        true,
+}
+
+// RequiredScope 返回请求需要的权限范围：
+//   - /api/v1/functions 和 /api/v1/invocations：GET 为 functions:read，调用和重放为 functions:invoke，其余为 functions:write
+//   - /api/v1/workflows 和 /api/v1/executions：GET 为 workflows:read，启动、停止、恢复执行为 workflows:invoke，其余为 workflows:write
+//
+// 其他路由不属于任何权限范围，返回空字符串，设置了权限范围的密钥不能访问。
+func RequiredScope(r *http.Request) string {
+	path, ok := strings.CutPrefix(r.URL.Path, "/api/v1/")
+	if !ok {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	// action 是资源 ID 之后的第一段，如 /functions/{id}/invoke 中的 invoke
+	action := ""
+	if len(segments) >= 3 {
+		action = segments[2]
+	}
+
+	switch segments[0] {
+	case "functions", "invocations":
+		switch {
+		case read:
+			return ScopeFunctionsRead
+		case segments[0] == "functions" && functionInvokeActions[action],
+			segments[0] == "invocations" && action == "replay":
+			return ScopeFunctionsInvoke
+		default:
+			return ScopeFunctionsWrite
+		}
+	case "workflows", "executions":
+		switch {
+		case read:
+			return ScopeWorkflowsRead
+		case segments[0] == "workflows" && action == "executions",
+			segments[0] == "executions" && (action == "stop" || action == "resume"):
+			return ScopeWorkflowsInvoke
+		default:
+			return ScopeWorkflowsWrite
+		}
+	}
+	return ""
+}
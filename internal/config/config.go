@@ -32,7 +32,7 @@ type Config struct {
 	Scheduler SchedulerConfig `yaml:"scheduler"`
 	// Storage 存储配置，包括 PostgreSQL 和 Redis 连接信息
 	Storage StorageConfig `yaml:"storage"`
-	// Events 事件配置，包括 NATS 消息队列连接信息
+	// Events 事件配置，包括 NATS 消息队列连接信息和 Kafka 事件触发器
 	Events EventsConfig `yaml:"events"`
 	// Logging 日志配置，包括日志级别和格式
 	Logging LoggingConfig `yaml:"logging"`
@@ -149,6 +149,54 @@ type FirecrackerConfig struct {
 	// BootTimeout 虚拟机启动超时时间
 	// 默认值：10 秒
 	BootTimeout time.Duration `yaml:"boot_timeout"`
+	// RootfsStrategy 为每个虚拟机准备可写根文件系统的方式：
+	// reflink（写时复制克隆，不支持时回退到复制）、overlay（device-mapper snapshot）或 copy（完整复制）
+	// 默认值：reflink
+	RootfsStrategy string `yaml:"rootfs_strategy"`
+	// OverlaySizeMB overlay 策略下每个虚拟机可写层的大小（MB），COW 文件为稀疏文件，只占用实际写入的空间
+	// 默认值：1024
+	OverlaySizeMB int `yaml:"overlay_size_mb"`
+
+	// BalloonEnabled 是否为虚拟机挂载 balloon 设备，空闲的预热虚拟机通过充气把内存归还主机
+	// 需要 guest 内核启用 virtio-balloon 驱动
+	BalloonEnabled bool `yaml:"balloon_enabled"`
+	// BalloonIdleTargetMB 虚拟机回到预热池时 balloon 充气的目标大小（MB），
+	// 实际值不超过虚拟机内存减去 guest 保留的最小内存
+	// 默认值：128
+	BalloonIdleTargetMB int `yaml:"balloon_idle_target_mb"`
+	// BalloonStatsIntervalSec balloon 统计信息的轮询间隔（秒），0 表示不采集统计
+	BalloonStatsIntervalSec int `yaml:"balloon_stats_interval_s"`
+
+	// IdleTimeout 虚拟机空闲超过该时长后被停止，每个运行时仍保留 min_warm 个最近使用的空闲虚拟机
+	// 0 表示不按空闲时间回收
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// IdleReapInterval 空闲虚拟机回收的检查间隔
+	// 默认值：30 秒
+	IdleReapInterval time.Duration `yaml:"idle_reap_interval"`
+	// MaxVMReuses 虚拟机被复用的最大次数，达到后回收以限制 guest 内存碎片，0 表示不限制
+	MaxVMReuses int `yaml:"max_vm_reuses"`
+
+	// UseJailer 是否通过 jailer 启动 Firecracker（chroot、cgroup、降权运行和 seccomp）
+	// 开启后 API socket、vsock、内核和根文件系统都放在每个虚拟机独立的 chroot 目录内
+	UseJailer bool `yaml:"use_jailer"`
+	// JailerBinary jailer 可执行文件路径
+	// 默认值：jailer
+	JailerBinary string `yaml:"jailer_binary"`
+	// ChrootBaseDir jailer 创建 chroot 目录的根路径，实际目录为 <chroot_base_dir>/<firecracker 文件名>/<vm_id>/root
+	// 默认值：/srv/jailer
+	ChrootBaseDir string `yaml:"chroot_base_dir"`
+	// JailerUID Firecracker 在 chroot 内降权运行使用的用户 ID
+	// 默认值：123
+	JailerUID int `yaml:"jailer_uid"`
+	// JailerGID Firecracker 在 chroot 内降权运行使用的组 ID
+	// 默认值：123
+	JailerGID int `yaml:"jailer_gid"`
+	// JailerCgroupVersion jailer 使用的 cgroup 版本（1 或 2），为空时使用 jailer 默认值
+	JailerCgroupVersion string `yaml:"jailer_cgroup_version"`
+	// JailerCgroups 额外写入虚拟机 cgroup 的配置，格式为 "文件=值"，如 "cpu.max=100000 100000"
+	JailerCgroups []string `yaml:"jailer_cgroups"`
+	// JailerSeccompFilter 自定义 seccomp 过滤器（编译后的 BPF 文件）路径，为空时使用 Firecracker 内置的默认过滤器
+	JailerSeccompFilter string `yaml:"jailer_seccomp_filter"`
 }
 
 // NetworkConfig 网络配置结构体。
@@ -168,6 +216,14 @@ type NetworkConfig struct {
 	UseNAT bool `yaml:"use_nat"`
 	// ExternalInterface 外部网络接口名称，用于 NAT 出口
 	ExternalInterface string `yaml:"external_interface"`
+	// EnableIPv6 是否为虚拟机启用 IPv6（双栈），关闭时仅分配 IPv4 地址
+	EnableIPv6 bool `yaml:"enable_ipv6"`
+	// IPv6SubnetCIDR 虚拟机 IPv6 前缀所在的父前缀，如 "fd00:172:20::/48"；
+	// 第一个子前缀保留给网桥，其余按虚拟机逐个分配
+	IPv6SubnetCIDR string `yaml:"ipv6_subnet_cidr"`
+	// IPv6VMPrefixLen 每个虚拟机分配的 IPv6 前缀长度
+	// 默认值：64
+	IPv6VMPrefixLen int `yaml:"ipv6_vm_prefix_len"`
 }
 
 // PoolConfig 虚拟机/容器池配置结构体。
@@ -208,6 +264,14 @@ type RuntimeConfig struct {
 	MemoryMB int `yaml:"memory_mb"`
 	// VCPUs 虚拟 CPU 数量
 	VCPUs int `yaml:"vcpus"`
+	// DiskMBps 根文件系统读写带宽上限（MB/s），0 表示不限速，函数可通过 io_limits 覆盖
+	DiskMBps float64 `yaml:"disk_mbps"`
+	// DiskIOPS 根文件系统每秒读写操作数上限，0 表示不限速
+	DiskIOPS int `yaml:"disk_iops"`
+	// NetMBps 网卡收、发方向各自的带宽上限（MB/s），0 表示不限速
+	NetMBps float64 `yaml:"net_mbps"`
+	// NetPPS 网卡收、发方向各自的每秒包数上限，0 表示不限速
+	NetPPS int `yaml:"net_pps"`
 }
 
 // SchedulerConfig 调度器配置结构体。
@@ -225,6 +289,44 @@ type SchedulerConfig struct {
 	// MaxRetries 失败重试最大次数
 	// 默认值：3
 	MaxRetries int `yaml:"max_retries"`
+	// NodeName 当前节点名称，用于节点注册
+	// 默认值：主机名
+	NodeName string `yaml:"node_name"`
+	// NodeLabels 当前节点的标签（如 gpu: "true"、memory: high），
+	// 配置了 node_selector 的函数只会在标签匹配的节点上运行
+	NodeLabels map[string]string `yaml:"node_labels"`
+	// CanaryInterval 金丝雀发布控制器的评估间隔
+	// 默认值：30 秒
+	CanaryInterval time.Duration `yaml:"canary_interval"`
+	// StaleInvocationThreshold 调用停留在 pending/running 超过该时长即视为停滞并标记为失败，
+	// 应大于最长函数超时和异步队列的最长排队时间
+	// 默认值：1 小时
+	StaleInvocationThreshold time.Duration `yaml:"stale_invocation_threshold"`
+	// StaleInvocationInterval 停滞调用对账任务的执行间隔
+	// 默认值：5 分钟
+	StaleInvocationInterval time.Duration `yaml:"stale_invocation_interval"`
+	// DLQRetryInterval 死信自动重试任务的扫描间隔，退避时间和最大次数在系统设置中配置
+	// 默认值：30 秒
+	DLQRetryInterval time.Duration `yaml:"dlq_retry_interval"`
+	// RetentionCheckInterval 保留策略管理器检查是否到达清理时间的间隔，
+	// 清理间隔和保留天数在系统设置中配置
+	// 默认值：1 分钟
+	RetentionCheckInterval time.Duration `yaml:"retention_check_interval"`
+	// LeaderElectionInterval 主节点选举获取和续约咨询锁的间隔，
+	// 多节点部署时定时触发、死信重试和保留策略清理只在主节点运行
+	// 默认值：10 秒
+	LeaderElectionInterval time.Duration `yaml:"leader_election_interval"`
+	// MaxCallDepth 函数间调用链（X-Nimbus-Call-Chain 请求头或结果投递）的最大长度，形成循环的调用总是被拒绝，
+	// 负数表示不限制长度
+	// 默认值：10
+	MaxCallDepth int `yaml:"max_call_depth"`
+	// ConcurrencyLimitMode 函数执行数达到 max_concurrency 时的处理方式：
+	// reject 立即拒绝（HTTP 429），wait 等待其他调用结束，超过 ConcurrencyWaitTimeout 后拒绝
+	// 默认值：reject
+	ConcurrencyLimitMode string `yaml:"concurrency_limit_mode"`
+	// ConcurrencyWaitTimeout wait 模式下等待执行名额的最长时间
+	// 默认值：与 DefaultTimeout 相同
+	ConcurrencyWaitTimeout time.Duration `yaml:"concurrency_wait_timeout"`
 }
 
 // StorageConfig 存储配置结构体。
@@ -234,6 +336,50 @@ type StorageConfig struct {
 	Postgres PostgresConfig `yaml:"postgres"`
 	// Redis Redis 缓存配置
 	Redis RedisConfig `yaml:"redis"`
+	// LayerBlobs 层内容存储配置
+	LayerBlobs LayerBlobConfig `yaml:"layer_blobs"`
+	// InputRefs 大输入按引用传递配置
+	InputRefs InputRefConfig `yaml:"input_refs"`
+}
+
+// InputRefConfig 大输入按引用传递配置结构体。
+// 超过阈值的函数输入上传到 S3 兼容对象存储，执行请求只携带预签名下载 URL，由 Agent 自行下载，
+// 避免大体积输入经过 vsock 消息。
+type InputRefConfig struct {
+	// ThresholdBytes 输入超过该字节数时按引用传递，0 表示禁用（始终内联传递）
+	ThresholdBytes int `yaml:"threshold_bytes"`
+	// URLExpiry 预签名下载 URL 的有效期，默认 15 分钟
+	URLExpiry time.Duration `yaml:"url_expiry"`
+	// S3 对象存储配置，未设置 bucket 时使用 layer_blobs.s3
+	S3 S3Config `yaml:"s3"`
+}
+
+// LayerBlobConfig 层内容存储配置结构体。
+// 层内容按 SHA-256 哈希寻址保存，数据库中只记录哈希和大小。
+type LayerBlobConfig struct {
+	// Backend 存储后端：postgres（默认，保存在 layer_blobs 表）或 s3（S3 兼容对象存储）
+	Backend string `yaml:"backend"`
+	// S3 对象存储配置，仅在 Backend 为 s3 时使用
+	S3 S3Config `yaml:"s3"`
+}
+
+// S3Config S3 兼容对象存储配置结构体。
+type S3Config struct {
+	// Endpoint 服务地址（不含协议），如 "s3.amazonaws.com" 或 "minio:9000"
+	Endpoint string `yaml:"endpoint"`
+	// Region 区域，为空时由服务端决定
+	Region string `yaml:"region"`
+	// Bucket 存储桶名称，需预先创建
+	Bucket string `yaml:"bucket"`
+	// Prefix 对象键前缀，如 "layers/"
+	Prefix string `yaml:"prefix"`
+	// AccessKeyID 访问密钥 ID
+	AccessKeyID string `yaml:"access_key_id"`
+	// SecretAccessKey 访问密钥，可通过环境变量 NIMBUS_S3_SECRET_ACCESS_KEY 或
+	// NIMBUS_S3_SECRET_ACCESS_KEY_FILE（文件路径）覆盖
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// UseSSL 是否使用 HTTPS 访问
+	UseSSL bool `yaml:"use_ssl"`
 }
 
 // PostgresConfig PostgreSQL 数据库配置结构体。
@@ -252,6 +398,8 @@ type PostgresConfig struct {
 	Password string `yaml:"password"`
 	// MaxConnections 最大连接数
 	MaxConnections int `yaml:"max_connections"`
+	// SchemaCheck 启动时的表结构漂移检查方式：off（不检查）、warn（记录警告，默认）或 fail（发现差异时拒绝启动）
+	SchemaCheck string `yaml:"schema_check"`
 }
 
 // RedisConfig Redis 缓存配置结构体。
@@ -271,6 +419,29 @@ type RedisConfig struct {
 type EventsConfig struct {
 	// NatsURL NATS 消息服务器 URL，如 "nats://localhost:4222"
 	NatsURL string `yaml:"nats_url"`
+	// Kafka Kafka 事件触发器配置，消费指定主题的消息并调用对应函数
+	Kafka KafkaConfig `yaml:"kafka"`
+}
+
+// KafkaConfig Kafka 事件触发器配置结构体。
+// 触发器以消费组方式订阅 Topics 中的主题，每条消息调用主题映射的函数。
+type KafkaConfig struct {
+	// Enabled 是否启用 Kafka 事件触发器
+	Enabled bool `yaml:"enabled"`
+	// Brokers Kafka 集群地址列表，如 ["localhost:9092"]
+	Brokers []string `yaml:"brokers"`
+	// GroupID 消费组 ID，多个网关实例使用相同的消费组分摊分区，默认为 "nimbus-triggers"
+	GroupID string `yaml:"group_id"`
+	// Topics 主题到函数的映射
+	Topics []KafkaTopicConfig `yaml:"topics"`
+}
+
+// KafkaTopicConfig 定义一个主题由哪个函数处理。
+type KafkaTopicConfig struct {
+	// Topic 订阅的主题名称
+	Topic string `yaml:"topic"`
+	// FunctionID 处理该主题消息的函数 ID
+	FunctionID string `yaml:"function_id"`
 }
 
 // LoggingConfig 日志配置结构体。
@@ -296,8 +467,8 @@ type MetricsConfig struct {
 type TelemetryConfig struct {
 	// Enabled 是否启用遥测
 	Enabled bool `yaml:"enabled"`
-	// Endpoint OTLP 端点地址（如 "tempo:4317"）
-	// 默认值：tempo:4317
+	// Endpoint OTLP gRPC 端点地址（如 "tempo:4317"）
+	// 为空时不导出追踪数据，追踪器为空操作实现
 	Endpoint string `yaml:"endpoint"`
 	// ServiceName 服务名称，用于追踪标识
 	// 默认值：nimbus-gateway
@@ -352,6 +523,10 @@ type SnapshotConfig struct {
 	CleanupInterval time.Duration `yaml:"cleanup_interval"`
 	// MaxSnapshotsPerFunction 单个函数最大快照数
 	MaxSnapshotsPerFunction int `yaml:"max_snapshots_per_function"`
+	// StatsFlushInterval 恢复统计批量写入数据库的间隔
+	StatsFlushInterval time.Duration `yaml:"stats_flush_interval"`
+	// MaxTotalSizeBytes 就绪快照的总大小上限（字节），超出时按最近最少使用淘汰，0 表示不限制
+	MaxTotalSizeBytes int64 `yaml:"max_total_size_bytes"`
 }
 
 // StateConfig 有状态函数配置结构体。
@@ -373,6 +548,12 @@ type StateConfig struct {
 	SessionTimeout int `yaml:"session_timeout"`
 	// CacheTTL 本地缓存 TTL（秒）
 	CacheTTL int `yaml:"cache_ttl"`
+	// DegradationMode Redis 不可用时写操作的处理方式：reject（拒绝，默认）或 buffer（本地缓冲后重放）
+	DegradationMode string `yaml:"degradation_mode"`
+	// WriteBufferSize buffer 模式下本地写缓冲的最大条数
+	WriteBufferSize int `yaml:"write_buffer_size"`
+	// HealthCheckInterval Redis 健康检查间隔
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
 }
 
 // Load 从指定路径加载配置文件。
@@ -426,6 +607,13 @@ func (c *Config) applyEnvOverrides() {
 	); v != "" {
 		c.Auth.JWTSecret = v
 	}
+	if v := readEnvOrFileAny(
+		[]string{"NIMBUS_S3_SECRET_ACCESS_KEY"},
+		[]string{"NIMBUS_S3_SECRET_ACCESS_KEY_FILE"},
+	); v != "" {
+		c.Storage.LayerBlobs.S3.SecretAccessKey = v
+		c.Storage.InputRefs.S3.SecretAccessKey = v
+	}
 }
 
 // readEnvOrFileAny 从环境变量或文件读取配置值。
@@ -515,6 +703,35 @@ func (c *Config) applyDefaults() {
 	if c.Firecracker.BootTimeout == 0 {
 		c.Firecracker.BootTimeout = 10 * time.Second
 	}
+	// 根文件系统默认使用 reflink 克隆
+	if c.Firecracker.RootfsStrategy == "" {
+		c.Firecracker.RootfsStrategy = "reflink"
+	}
+	if c.Firecracker.OverlaySizeMB == 0 {
+		c.Firecracker.OverlaySizeMB = 1024
+	}
+	if c.Firecracker.BalloonIdleTargetMB == 0 {
+		c.Firecracker.BalloonIdleTargetMB = 128
+	}
+	if c.Firecracker.IdleReapInterval == 0 {
+		c.Firecracker.IdleReapInterval = 30 * time.Second
+	}
+	if c.Firecracker.JailerBinary == "" {
+		c.Firecracker.JailerBinary = "jailer"
+	}
+	if c.Firecracker.ChrootBaseDir == "" {
+		c.Firecracker.ChrootBaseDir = "/srv/jailer"
+	}
+	if c.Firecracker.JailerUID == 0 {
+		c.Firecracker.JailerUID = 123
+	}
+	if c.Firecracker.JailerGID == 0 {
+		c.Firecracker.JailerGID = 123
+	}
+	// 虚拟机 IPv6 前缀默认为 /64
+	if c.Network.IPv6VMPrefixLen == 0 {
+		c.Network.IPv6VMPrefixLen = 64
+	}
 	// 调度器工作线程数默认为 10
 	if c.Scheduler.Workers == 0 {
 		c.Scheduler.Workers = 10
@@ -531,6 +748,50 @@ func (c *Config) applyDefaults() {
 	if c.Scheduler.MaxRetries == 0 {
 		c.Scheduler.MaxRetries = 3
 	}
+	// 金丝雀发布评估间隔默认为 30 秒
+	if c.Scheduler.CanaryInterval == 0 {
+		c.Scheduler.CanaryInterval = 30 * time.Second
+	}
+	// 停滞调用对账默认每 5 分钟执行一次，阈值 1 小时
+	if c.Scheduler.StaleInvocationThreshold == 0 {
+		c.Scheduler.StaleInvocationThreshold = time.Hour
+	}
+	if c.Scheduler.StaleInvocationInterval == 0 {
+		c.Scheduler.StaleInvocationInterval = 5 * time.Minute
+	}
+	// 死信自动重试默认每 30 秒扫描一次
+	if c.Scheduler.DLQRetryInterval == 0 {
+		c.Scheduler.DLQRetryInterval = 30 * time.Second
+	}
+	// 保留策略默认每分钟检查一次是否到达清理时间
+	if c.Scheduler.RetentionCheckInterval == 0 {
+		c.Scheduler.RetentionCheckInterval = time.Minute
+	}
+	// 主节点选举默认每 10 秒续约一次
+	if c.Scheduler.LeaderElectionInterval == 0 {
+		c.Scheduler.LeaderElectionInterval = 10 * time.Second
+	}
+	// 函数间调用链默认最多 10 层
+	if c.Scheduler.MaxCallDepth == 0 {
+		c.Scheduler.MaxCallDepth = 10
+	}
+	// 并发达到上限时默认立即拒绝，等待模式的最长等待时间与函数默认超时相同
+	if c.Scheduler.ConcurrencyLimitMode == "" {
+		c.Scheduler.ConcurrencyLimitMode = "reject"
+	}
+	if c.Scheduler.ConcurrencyWaitTimeout == 0 {
+		c.Scheduler.ConcurrencyWaitTimeout = c.Scheduler.DefaultTimeout
+	}
+	// 表结构漂移检查默认只记录警告
+	if c.Storage.Postgres.SchemaCheck == "" {
+		c.Storage.Postgres.SchemaCheck = "warn"
+	}
+	// 节点名称默认为主机名
+	if c.Scheduler.NodeName == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			c.Scheduler.NodeName = hostname
+		}
+	}
 	// JWT 过期时间默认为 24 小时
 	if c.Auth.JWTExpiration == 0 {
 		c.Auth.JWTExpiration = 24 * time.Hour
@@ -543,10 +804,6 @@ func (c *Config) applyDefaults() {
 	if c.Telemetry.ServiceName == "" {
 		c.Telemetry.ServiceName = "nimbus-gateway"
 	}
-	// OTLP 端点默认为 tempo:4317
-	if c.Telemetry.Endpoint == "" {
-		c.Telemetry.Endpoint = "tempo:4317"
-	}
 	// 采样率默认为 10%
 	if c.Telemetry.SampleRate == 0 {
 		c.Telemetry.SampleRate = 0.1
@@ -592,4 +849,22 @@ func (c *Config) applyDefaults() {
 	if c.Snapshot.MaxSnapshotsPerFunction == 0 {
 		c.Snapshot.MaxSnapshotsPerFunction = 3
 	}
+	if c.Snapshot.StatsFlushInterval == 0 {
+		c.Snapshot.StatsFlushInterval = 10 * time.Second
+	}
+	// 层内容默认保存在 PostgreSQL
+	if c.Storage.LayerBlobs.Backend == "" {
+		c.Storage.LayerBlobs.Backend = "postgres"
+	}
+	// 大输入引用默认与层内容使用同一个对象存储
+	if c.Storage.InputRefs.URLExpiry == 0 {
+		c.Storage.InputRefs.URLExpiry = 15 * time.Minute
+	}
+	if c.Storage.InputRefs.S3.Bucket == "" {
+		c.Storage.InputRefs.S3 = c.Storage.LayerBlobs.S3
+	}
+	// Kafka 消费组默认为 nimbus-triggers
+	if c.Events.Kafka.GroupID == "" {
+		c.Events.Kafka.GroupID = "nimbus-triggers"
+	}
 }
@@ -103,6 +103,8 @@ func NewManager(cfg config.DockerConfig, m *metrics.Metrics, logger *logrus.Logg
 	images := map[string]string{
 		"python3.11": "function-runtime-python:latest",
 		"nodejs20":   "function-runtime-nodejs:latest",
+		"ruby3.2":    "function-runtime-ruby:latest",
+		"java17":     "function-runtime-java:latest",
 		"go1.24":     "function-runtime-go:latest",
 		"rust1.75":   "function-runtime-go:latest",
 		"wasm":       "function-runtime-wasm:latest",
@@ -121,6 +123,8 @@ func NewManager(cfg config.DockerConfig, m *metrics.Metrics, logger *logrus.Logg
 		execCmd: map[string][]string{
 			"python3.11": {"python3", "/app/runtime.py"},
 			"nodejs20":   {"node", "/app/runtime.js"},
+			"ruby3.2":    {"ruby", "/app/runtime.rb"},
+			"java17":     {"/app/runtime"},
 			"go1.24":     {"/app/runtime"},
 			"rust1.75":   {"/app/runtime"},
 			"wasm":       {"/app/runtime"},
@@ -768,6 +772,72 @@ func (m *Manager) releaseContainer(ctx context.Context, pc *pooledContainer, hea
 	}
 }
 
+// Prewarm 为函数所在的容器池（运行时 + 内存）创建预热容器，供函数级预热策略提前消除冷启动。
+// 未启用池化模式时不做任何操作；创建数量受 MaxTotal 限制。
+// 返回：
+//   - int: 实际创建成功的容器数量
+//   - error: 运行时不受支持或创建失败时返回错误
+func (m *Manager) Prewarm(ctx context.Context, fn *domain.Function, count int) (int, error) {
+	if !m.poolCfg.Enabled {
+		return 0, nil
+	}
+	image, ok := m.images[string(fn.Runtime)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported runtime: %s", fn.Runtime)
+	}
+
+	pool := m.getPool(string(fn.Runtime), fn.MemoryMB)
+	created := 0
+	for i := 0; i < count; i++ {
+		pool.mu.Lock()
+		canCreate := len(pool.all)+pool.creating < m.poolCfg.MaxTotal
+		if canCreate {
+			pool.creating++
+		}
+		pool.mu.Unlock()
+		if !canCreate {
+			break
+		}
+
+		pc, err := m.createContainer(ctx, string(fn.Runtime), fn.MemoryMB, image)
+		pool.mu.Lock()
+		pool.creating--
+		if err == nil {
+			pool.all[pc.ID] = pc
+		}
+		pool.mu.Unlock()
+		if err != nil {
+			return created, err
+		}
+
+		select {
+		case pool.warm <- pc:
+			created++
+		default:
+			// 预热队列已满：销毁多余容器
+			pool.mu.Lock()
+			delete(pool.all, pc.ID)
+			pool.mu.Unlock()
+			_ = exec.CommandContext(context.Background(), "docker", "rm", "-f", pc.ID).Run()
+		}
+	}
+	m.updatePoolMetrics(string(fn.Runtime))
+	return created, nil
+}
+
+// WarmContainers 返回各运行时当前的预热容器数量，同一运行时不同内存规格的池合并统计。
+// 用于 Prometheus 采集时实时读取 warm_vms 指标。
+func (m *Manager) WarmContainers() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, pool := range m.pools {
+		counts[pool.runtime] += len(pool.warm)
+	}
+	return counts
+}
+
 // updatePoolMetrics 更新容器池的 Prometheus 指标。
 // 统计指定运行时的预热、忙碌和总容器数。
 func (m *Manager) updatePoolMetrics(runtime string) {
@@ -1,6 +1,26 @@
 package docker
 
-import "testing"
+import (
+	"io"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewManagerCoversRuntimes(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	m := NewManager(config.DockerConfig{}, nil, logger)
+	for _, rt := range []domain.Runtime{
+		domain.RuntimePython311, domain.RuntimeNodeJS20, domain.RuntimeRuby32, domain.RuntimeJava17, domain.RuntimeGo124, domain.RuntimeWasm,
+	} {
+		if m.images[string(rt)] == "" || len(m.execCmd[string(rt)]) == 0 {
+			t.Errorf("runtime %s has image %q and exec command %v, want both", rt, m.images[string(rt)], m.execCmd[string(rt)])
+		}
+	}
+}
 
 func TestExtractJSONFromStdout(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
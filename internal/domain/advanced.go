@@ -113,6 +113,8 @@ type WarmingStatus struct {
 	ColdStartRate  float64   `json:"cold_start_rate"` // 冷启动率
 	LastWarmedAt   *time.Time `json:"last_warmed_at,omitempty"`
 	Policy         *WarmingPolicy `json:"policy,omitempty"`
+	WarmupStrategy WarmupStrategy `json:"warmup_strategy"`           // 函数的冷启动预热策略
+	WarmupSchedule string         `json:"warmup_schedule,omitempty"` // scheduled 策略的预热计划
 }
 
 // ==================== 依赖分析类型 ====================
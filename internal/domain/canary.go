@@ -0,0 +1,271 @@
+// Package domain 定义了函数计算平台的核心领域模型。
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ==================== 版本统计定义 ====================
+
+// VersionStats 表示函数某个版本在统计窗口内的调用指标
+type VersionStats struct {
+	// Version 版本号
+	Version int `json:"version"`
+	// Invocations 已完成的调用次数
+	Invocations int64 `json:"invocations"`
+	// Errors 失败和超时的调用次数
+	Errors int64 `json:"errors"`
+	// ErrorRate 错误率（0-1）
+	ErrorRate float64 `json:"error_rate"`
+	// AvgDurationMs 平均执行耗时（毫秒）
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+	// P95DurationMs P95 执行耗时（毫秒）
+	P95DurationMs float64 `json:"p95_duration_ms"`
+}
+
+// VersionComparison 表示候选版本相对基线版本的指标对比
+type VersionComparison struct {
+	// Baseline 基线（稳定）版本的指标
+	Baseline VersionStats `json:"baseline"`
+	// Candidate 候选（金丝雀）版本的指标
+	Candidate VersionStats `json:"candidate"`
+	// ErrorRateDelta 候选版本错误率减去基线版本错误率
+	ErrorRateDelta float64 `json:"error_rate_delta"`
+	// LatencyRatio 候选版本 P95 耗时与基线版本 P95 耗时之比，基线无数据时为 0
+	LatencyRatio float64 `json:"latency_ratio"`
+	// Since 统计窗口起始时间
+	Since time.Time `json:"since"`
+}
+
+// NewVersionComparison 根据两个版本的指标计算对比结果
+func NewVersionComparison(baseline, candidate VersionStats, since time.Time) *VersionComparison {
+	c := &VersionComparison{
+		Baseline:       baseline,
+		Candidate:      candidate,
+		ErrorRateDelta: candidate.ErrorRate - baseline.ErrorRate,
+		Since:          since,
+	}
+	if baseline.P95DurationMs > 0 {
+		c.LatencyRatio = candidate.P95DurationMs / baseline.P95DurationMs
+	}
+	return c
+}
+
+// ==================== 金丝雀发布定义 ====================
+
+// CanaryStatus 金丝雀发布状态
+type CanaryStatus string
+
+const (
+	// CanaryStatusRunning 正在逐步放量
+	CanaryStatusRunning CanaryStatus = "running"
+	// CanaryStatusPromoted 金丝雀版本已承接全部流量
+	CanaryStatusPromoted CanaryStatus = "promoted"
+	// CanaryStatusRolledBack 指标劣化，已自动回滚到稳定版本
+	CanaryStatusRolledBack CanaryStatus = "rolled_back"
+	// CanaryStatusAborted 被手动终止，流量已切回稳定版本
+	CanaryStatusAborted CanaryStatus = "aborted"
+)
+
+// CanaryAction 金丝雀控制器的单次决策动作
+type CanaryAction string
+
+const (
+	// CanaryActionHold 保持当前权重，继续观察
+	CanaryActionHold CanaryAction = "hold"
+	// CanaryActionPromote 提升金丝雀版本的流量权重
+	CanaryActionPromote CanaryAction = "promote"
+	// CanaryActionRollback 将金丝雀版本权重置为 0
+	CanaryActionRollback CanaryAction = "rollback"
+)
+
+// 金丝雀发布参数默认值
+const (
+	DefaultCanaryInitialWeight     = 10
+	DefaultCanaryStepWeight        = 10
+	DefaultCanaryHealthyDuration   = 300 // 秒
+	DefaultCanaryMinInvocations    = 20
+	DefaultCanaryMaxErrorRateDelta = 0.05
+	DefaultCanaryMaxLatencyRatio   = 1.5
+	// maxCanaryDecisions 每个金丝雀发布保留的决策记录条数
+	maxCanaryDecisions = 50
+)
+
+// CanaryDecision 表示控制器的一次决策记录
+type CanaryDecision struct {
+	// Action 决策动作
+	Action CanaryAction `json:"action"`
+	// Weight 决策后金丝雀版本的流量权重
+	Weight int `json:"weight"`
+	// Reason 决策原因
+	Reason string `json:"reason"`
+	// Comparison 决策依据的版本指标对比
+	Comparison *VersionComparison `json:"comparison,omitempty"`
+	// DecidedAt 决策时间
+	DecidedAt time.Time `json:"decided_at"`
+}
+
+// CanaryDeployment 表示一次基于别名权重的金丝雀发布。
+// 控制器周期性对比金丝雀版本与稳定版本的指标：持续健康达到 HealthyDurationSec 后
+// 按 StepWeight 提升权重，指标超出阈值时将权重置为 0 并告警。
+type CanaryDeployment struct {
+	// ID 金丝雀发布唯一标识符
+	ID string `json:"id"`
+	// FunctionID 关联的函数 ID
+	FunctionID string `json:"function_id"`
+	// AliasName 用于分流的别名
+	AliasName string `json:"alias_name"`
+	// StableVersion 稳定版本号
+	StableVersion int `json:"stable_version"`
+	// CanaryVersion 金丝雀版本号
+	CanaryVersion int `json:"canary_version"`
+	// Weight 金丝雀版本当前的流量权重（0-100）
+	Weight int `json:"weight"`
+	// StepWeight 每次提升的权重
+	StepWeight int `json:"step_weight"`
+	// HealthyDurationSec 提升权重前需要持续健康的时长（秒）
+	HealthyDurationSec int `json:"healthy_duration_sec"`
+	// MinInvocations 做出判断所需的金丝雀版本最少调用次数
+	MinInvocations int `json:"min_invocations"`
+	// MaxErrorRateDelta 允许的错误率增量上限，超出即回滚
+	MaxErrorRateDelta float64 `json:"max_error_rate_delta"`
+	// MaxLatencyRatio 允许的 P95 耗时倍数上限，超出即回滚
+	MaxLatencyRatio float64 `json:"max_latency_ratio"`
+	// Status 发布状态
+	Status CanaryStatus `json:"status"`
+	// StepStartedAt 当前权重的生效时间，指标只统计此后的调用
+	StepStartedAt time.Time `json:"step_started_at"`
+	// HealthySince 在当前权重下连续健康的起始时间
+	HealthySince *time.Time `json:"healthy_since,omitempty"`
+	// Decisions 最近的决策记录，按时间顺序排列
+	Decisions []CanaryDecision `json:"decisions,omitempty"`
+	// CreatedAt 创建时间
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt 最后更新时间
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsTerminal 判断金丝雀发布是否已结束
+func (c *CanaryDeployment) IsTerminal() bool {
+	return c.Status != CanaryStatusRunning
+}
+
+// RoutingConfig 返回按当前权重在稳定版本和金丝雀版本间分流的路由配置
+func (c *CanaryDeployment) RoutingConfig() RoutingConfig {
+	switch {
+	case c.Weight <= 0:
+		return RoutingConfig{Weights: []VersionWeight{{Version: c.StableVersion, Weight: 100}}}
+	case c.Weight >= 100:
+		return RoutingConfig{Weights: []VersionWeight{{Version: c.CanaryVersion, Weight: 100}}}
+	default:
+		return RoutingConfig{Weights: []VersionWeight{
+			{Version: c.StableVersion, Weight: 100 - c.Weight},
+			{Version: c.CanaryVersion, Weight: c.Weight},
+		}}
+	}
+}
+
+// Evaluate 根据版本指标对比做出决策，并更新权重、状态和健康计时。
+// 返回的决策同时追加到 Decisions 中，只保留最近的记录。
+func (c *CanaryDeployment) Evaluate(cmp *VersionComparison, now time.Time) CanaryDecision {
+	decision := CanaryDecision{Action: CanaryActionHold, Comparison: cmp, DecidedAt: now}
+
+	switch {
+	case cmp.Candidate.Invocations < int64(c.MinInvocations):
+		decision.Reason = fmt.Sprintf("waiting for traffic: %d/%d canary invocations", cmp.Candidate.Invocations, c.MinInvocations)
+	case cmp.ErrorRateDelta > c.MaxErrorRateDelta:
+		decision.Action = CanaryActionRollback
+		decision.Reason = fmt.Sprintf("error rate %.2f%% exceeds stable %.2f%% by more than %.2f%%",
+			cmp.Candidate.ErrorRate*100, cmp.Baseline.ErrorRate*100, c.MaxErrorRateDelta*100)
+	case cmp.LatencyRatio > c.MaxLatencyRatio:
+		decision.Action = CanaryActionRollback
+		decision.Reason = fmt.Sprintf("p95 latency %.0fms is %.2fx stable, limit %.2fx",
+			cmp.Candidate.P95DurationMs, cmp.LatencyRatio, c.MaxLatencyRatio)
+	case c.HealthySince == nil:
+		c.HealthySince = &now
+		decision.Reason = "canary healthy, observation started"
+	case now.Sub(*c.HealthySince) < time.Duration(c.HealthyDurationSec)*time.Second:
+		decision.Reason = fmt.Sprintf("canary healthy for %s", now.Sub(*c.HealthySince).Round(time.Second))
+	default:
+		decision.Action = CanaryActionPromote
+		decision.Reason = fmt.Sprintf("canary healthy for %ds", c.HealthyDurationSec)
+	}
+
+	switch decision.Action {
+	case CanaryActionPromote:
+		c.Weight += c.StepWeight
+		if c.Weight >= 100 {
+			c.Weight = 100
+			c.Status = CanaryStatusPromoted
+		}
+		c.StepStartedAt = now
+		c.HealthySince = nil
+	case CanaryActionRollback:
+		c.Weight = 0
+		c.Status = CanaryStatusRolledBack
+		c.HealthySince = nil
+	}
+	decision.Weight = c.Weight
+
+	c.Decisions = append(c.Decisions, decision)
+	if len(c.Decisions) > maxCanaryDecisions {
+		c.Decisions = c.Decisions[len(c.Decisions)-maxCanaryDecisions:]
+	}
+	return decision
+}
+
+// CreateCanaryRequest 表示启动金丝雀发布的请求
+type CreateCanaryRequest struct {
+	// AliasName 用于分流的别名，默认 latest
+	AliasName string `json:"alias_name,omitempty"`
+	// StableVersion 稳定版本号，必填
+	StableVersion int `json:"stable_version"`
+	// CanaryVersion 金丝雀版本号，必填
+	CanaryVersion int `json:"canary_version"`
+	// InitialWeight 金丝雀版本的初始权重，默认 10
+	InitialWeight int `json:"initial_weight,omitempty"`
+	// StepWeight 每次提升的权重，默认 10
+	StepWeight int `json:"step_weight,omitempty"`
+	// HealthyDurationSec 提升权重前需要持续健康的时长（秒），默认 300
+	HealthyDurationSec int `json:"healthy_duration_sec,omitempty"`
+	// MinInvocations 做出判断所需的最少调用次数，默认 20
+	MinInvocations int `json:"min_invocations,omitempty"`
+	// MaxErrorRateDelta 允许的错误率增量上限，默认 0.05
+	MaxErrorRateDelta float64 `json:"max_error_rate_delta,omitempty"`
+	// MaxLatencyRatio 允许的 P95 耗时倍数上限，默认 1.5
+	MaxLatencyRatio float64 `json:"max_latency_ratio,omitempty"`
+}
+
+// Validate 验证请求参数并为可选参数设置默认值
+func (r *CreateCanaryRequest) Validate() error {
+	if r.StableVersion <= 0 || r.CanaryVersion <= 0 || r.StableVersion == r.CanaryVersion {
+		return ErrInvalidCanary
+	}
+	if r.AliasName == "" {
+		r.AliasName = "latest"
+	}
+	if r.InitialWeight == 0 {
+		r.InitialWeight = DefaultCanaryInitialWeight
+	}
+	if r.StepWeight == 0 {
+		r.StepWeight = DefaultCanaryStepWeight
+	}
+	if r.HealthyDurationSec == 0 {
+		r.HealthyDurationSec = DefaultCanaryHealthyDuration
+	}
+	if r.MinInvocations == 0 {
+		r.MinInvocations = DefaultCanaryMinInvocations
+	}
+	if r.MaxErrorRateDelta == 0 {
+		r.MaxErrorRateDelta = DefaultCanaryMaxErrorRateDelta
+	}
+	if r.MaxLatencyRatio == 0 {
+		r.MaxLatencyRatio = DefaultCanaryMaxLatencyRatio
+	}
+	if r.InitialWeight < 1 || r.InitialWeight > 99 || r.StepWeight < 1 || r.StepWeight > 100 ||
+		r.HealthyDurationSec < 0 || r.MinInvocations < 0 || r.MaxErrorRateDelta < 0 || r.MaxLatencyRatio < 1 {
+		return ErrInvalidCanary
+	}
+	return nil
+}
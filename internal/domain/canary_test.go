@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestCanary 返回一个使用默认阈值、当前权重为 weight 的金丝雀发布
+func newTestCanary(weight int) *CanaryDeployment {
+	return &CanaryDeployment{
+		StableVersion:      1,
+		CanaryVersion:      2,
+		Weight:             weight,
+		StepWeight:         DefaultCanaryStepWeight,
+		HealthyDurationSec: 60,
+		MinInvocations:     10,
+		MaxErrorRateDelta:  DefaultCanaryMaxErrorRateDelta,
+		MaxLatencyRatio:    DefaultCanaryMaxLatencyRatio,
+		Status:             CanaryStatusRunning,
+	}
+}
+
+// comparison 构造稳定版本和金丝雀版本的指标对比
+func comparison(canaryInvocations int64, canaryErrorRate, canaryP95 float64) *VersionComparison {
+	baseline := VersionStats{Version: 1, Invocations: 100, ErrorRate: 0.01, P95DurationMs: 100}
+	candidate := VersionStats{Version: 2, Invocations: canaryInvocations, ErrorRate: canaryErrorRate, P95DurationMs: canaryP95}
+	return NewVersionComparison(baseline, candidate, time.Time{})
+}
+
+func TestCanaryEvaluatePromotesAfterHealthyDuration(t *testing.T) {
+	c := newTestCanary(10)
+	start := time.Now()
+
+	if d := c.Evaluate(comparison(20, 0.01, 110), start); d.Action != CanaryActionHold || c.HealthySince == nil {
+		t.Fatalf("first healthy evaluation = %s, want hold with observation started", d.Action)
+	}
+	if d := c.Evaluate(comparison(40, 0.01, 110), start.Add(30*time.Second)); d.Action != CanaryActionHold {
+		t.Fatalf("evaluation before healthy duration = %s, want hold", d.Action)
+	}
+
+	promotedAt := start.Add(time.Minute)
+	d := c.Evaluate(comparison(60, 0.01, 110), promotedAt)
+	if d.Action != CanaryActionPromote || c.Weight != 20 {
+		t.Fatalf("evaluation after healthy duration = %s (weight %d), want promote to 20", d.Action, c.Weight)
+	}
+	if c.HealthySince != nil || !c.StepStartedAt.Equal(promotedAt) {
+		t.Errorf("promotion must restart the observation window")
+	}
+	if len(c.Decisions) != 3 {
+		t.Errorf("recorded %d decisions, want 3", len(c.Decisions))
+	}
+}
+
+func TestCanaryEvaluateCompletesAtFullWeight(t *testing.T) {
+	c := newTestCanary(95)
+	since := time.Now().Add(-2 * time.Minute)
+	c.HealthySince = &since
+
+	c.Evaluate(comparison(20, 0, 100), time.Now())
+	if c.Weight != 100 || c.Status != CanaryStatusPromoted {
+		t.Errorf("weight = %d, status = %s, want 100 and promoted", c.Weight, c.Status)
+	}
+	if w := c.RoutingConfig().Weights; len(w) != 1 || w[0].Version != 2 || w[0].Weight != 100 {
+		t.Errorf("RoutingConfig() = %+v, want all traffic on canary version", w)
+	}
+}
+
+func TestCanaryEvaluateRollsBack(t *testing.T) {
+	tests := []struct {
+		name string
+		cmp  *VersionComparison
+	}{
+		{"error rate regression", comparison(20, 0.2, 100)},
+		{"latency regression", comparison(20, 0.01, 200)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCanary(30)
+			d := c.Evaluate(tt.cmp, time.Now())
+			if d.Action != CanaryActionRollback || c.Weight != 0 || c.Status != CanaryStatusRolledBack {
+				t.Fatalf("Evaluate() = %s (weight %d, status %s), want rollback to 0", d.Action, c.Weight, c.Status)
+			}
+			if w := c.RoutingConfig().Weights; len(w) != 1 || w[0].Version != 1 {
+				t.Errorf("RoutingConfig() = %+v, want all traffic on stable version", w)
+			}
+		})
+	}
+}
+
+func TestCanaryEvaluateWaitsForTraffic(t *testing.T) {
+	c := newTestCanary(10)
+	// 调用次数不足时即使错误率很高也不回滚
+	if d := c.Evaluate(comparison(5, 1, 1000), time.Now()); d.Action != CanaryActionHold || c.Weight != 10 {
+		t.Errorf("Evaluate() = %s (weight %d), want hold at 10", d.Action, c.Weight)
+	}
+	if c.HealthySince != nil {
+		t.Errorf("insufficient traffic must not start the healthy window")
+	}
+}
+
+func TestCreateCanaryRequestValidate(t *testing.T) {
+	req := CreateCanaryRequest{StableVersion: 1, CanaryVersion: 2}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if req.AliasName != "latest" || req.InitialWeight != DefaultCanaryInitialWeight || req.MaxLatencyRatio != DefaultCanaryMaxLatencyRatio {
+		t.Errorf("defaults not applied: %+v", req)
+	}
+
+	invalid := []CreateCanaryRequest{
+		{StableVersion: 1, CanaryVersion: 1},
+		{StableVersion: 0, CanaryVersion: 2},
+		{StableVersion: 1, CanaryVersion: 2, InitialWeight: 100},
+		{StableVersion: 1, CanaryVersion: 2, MaxLatencyRatio: 0.5},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err != ErrInvalidCanary {
+			t.Errorf("Validate(%+v) = %v, want ErrInvalidCanary", r, err)
+		}
+	}
+}
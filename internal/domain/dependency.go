@@ -0,0 +1,117 @@
+// Package domain 定义了函数计算平台的核心领域模型。
+// 本文件实现函数依赖图上的环检测，以及随调用传递的调用链检查，用于拒绝函数间的循环调用和过长的调用链。
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dependencyGraph 是依赖边构成的有向图，邻接表按边的出现顺序保存，同一对函数的多种依赖类型只保留一条边
+type dependencyGraph struct {
+	downstream map[string][]string // 函数 ID -> 它调用的函数
+}
+
+func newDependencyGraph(edges []DependencyEdge) *dependencyGraph {
+	g := &dependencyGraph{downstream: make(map[string][]string)}
+	seen := make(map[[2]string]bool, len(edges))
+	for _, e := range edges {
+		key := [2]string{e.Source, e.Target}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		g.downstream[e.Source] = append(g.downstream[e.Source], e.Target)
+	}
+	return g
+}
+
+// path 沿调用方向查找从 from 到 to 的路径，返回路径上的函数 ID（含首尾），不可达时返回 nil
+func (g *dependencyGraph) path(from, to string) []string {
+	visited := make(map[string]bool)
+	var walk func(id string) []string
+	walk = func(id string) []string {
+		if id == to {
+			return []string{id}
+		}
+		if visited[id] {
+			return nil
+		}
+		visited[id] = true
+		for _, next := range g.downstream[id] {
+			if rest := walk(next); rest != nil {
+				return append([]string{id}, rest...)
+			}
+		}
+		return nil
+	}
+	return walk(from)
+}
+
+// FindDependencyCycle 在依赖图中查找从 startID 出发沿调用方向回到 startID 的环。
+//
+// 参数:
+//   - edges: 依赖图的所有边
+//   - startID: 起点函数 ID
+//
+// 返回值:
+//   - []string: 环上的函数 ID，首尾均为 startID（如 A、B、A）；不存在环时返回 nil
+func FindDependencyCycle(edges []DependencyEdge, startID string) []string {
+	g := newDependencyGraph(edges)
+	for _, next := range g.downstream[startID] {
+		if p := g.path(next, startID); p != nil {
+			return append([]string{startID}, p...)
+		}
+	}
+	return nil
+}
+
+// 函数间调用链的传递方式：网关在函数环境变量 EnvCallChain 中给出本次调用的调用链（含函数自身），
+// 函数调用其他函数时把该值原样放入 HeaderCallChain 请求头，结果投递等平台发起的调用自动携带
+const (
+	// HeaderCallChain 是函数间调用携带调用链的 HTTP 请求头，值为逗号分隔的函数 ID，从最上游函数到直接调用方
+	HeaderCallChain = "X-Nimbus-Call-Chain"
+	// EnvCallChain 是传给函数的调用链环境变量，格式与 HeaderCallChain 相同，最后一项为函数自身
+	EnvCallChain = "NIMBUS_CALL_CHAIN"
+)
+
+// ParseCallChain 解析 HeaderCallChain 的值，忽略空项，值为空时返回 nil
+func ParseCallChain(value string) []string {
+	var chain []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			chain = append(chain, id)
+		}
+	}
+	return chain
+}
+
+// FormatCallChain 返回调用链在 HeaderCallChain 和 EnvCallChain 中的表示
+func FormatCallChain(chain []string) string {
+	return strings.Join(chain, ",")
+}
+
+// CheckCallChain 检查沿调用链 chain 调用 targetID 是否形成循环调用，
+// 或使调用链长度（从最上游函数到 targetID 的调用次数，即 len(chain)）超过 maxDepth。
+// 调用链随每次调用传递，只反映本次调用实际经过的函数，与历史记录的依赖无关。
+//
+// 参数:
+//   - chain: 发起调用的函数 ID，从最上游函数到直接调用方，不是函数间调用时为空
+//   - targetID: 被调用函数 ID
+//   - maxDepth: 调用链的最大长度，不大于 0 时不限制
+//
+// 返回值:
+//   - error: targetID 已在调用链中时返回包装 ErrDependencyCycle 的错误（包含环路径），
+//     超过最大长度时返回包装 ErrCallDepthExceeded 的错误
+func CheckCallChain(chain []string, targetID string, maxDepth int) error {
+	for i, id := range chain {
+		if id == targetID {
+			cycle := append(append([]string{}, chain[i:]...), targetID)
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cycle, " -> "))
+		}
+	}
+	if depth := len(chain); maxDepth > 0 && depth > maxDepth {
+		return fmt.Errorf("%w: call chain to %s would be %d calls deep (max %d)", ErrCallDepthExceeded, targetID, depth, maxDepth)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// chain 根据函数 ID 序列构造依赖边，如 chain("A", "B", "C") 得到 A->B、B->C
+func chain(ids ...string) []DependencyEdge {
+	var edges []DependencyEdge
+	for i := 1; i < len(ids); i++ {
+		edges = append(edges, DependencyEdge{Source: ids[i-1], Target: ids[i], Type: DependencyTypeDirectCall})
+	}
+	return edges
+}
+
+func TestFindDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges []DependencyEdge
+		start string
+		want  []string
+	}{
+		{"two functions", chain("A", "B", "A"), "A", []string{"A", "B", "A"}},
+		{"three functions", chain("A", "B", "C", "A"), "B", []string{"B", "C", "A", "B"}},
+		{"diamond without cycle", append(chain("A", "B", "D"), chain("A", "C", "D")...), "A", nil},
+		{"cycle not through start", append(chain("A", "B"), chain("B", "C", "B")...), "A", nil},
+	}
+	for _, tt := range tests {
+		if got := FindDependencyCycle(tt.edges, tt.start); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FindDependencyCycle() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckCallChain(t *testing.T) {
+	// B 调用 A 时调用链中已有 A，形成环
+	err := CheckCallChain([]string{"A", "B"}, "A", 0)
+	if !errors.Is(err, ErrDependencyCycle) || err.Error() != ErrDependencyCycle.Error()+": A -> B -> A" {
+		t.Errorf("A->B->A: error = %v, want cycle A -> B -> A", err)
+	}
+	err = CheckCallChain([]string{"X", "A", "B", "C"}, "A", 0)
+	if !errors.Is(err, ErrDependencyCycle) || err.Error() != ErrDependencyCycle.Error()+": A -> B -> C -> A" {
+		t.Errorf("X->A->B->C->A: error = %v, want cycle A -> B -> C -> A", err)
+	}
+	if err := CheckCallChain([]string{"A"}, "A", 0); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("self call: error = %v, want ErrDependencyCycle", err)
+	}
+
+	// 调用链长度只由本次调用经过的函数决定
+	if err := CheckCallChain(nil, "A", 1); err != nil {
+		t.Errorf("top-level call: error = %v", err)
+	}
+	if err := CheckCallChain([]string{"A", "B", "C"}, "D", 3); err != nil {
+		t.Errorf("depth 3 with max 3: error = %v", err)
+	}
+	if err := CheckCallChain([]string{"A", "B", "C"}, "D", 2); !errors.Is(err, ErrCallDepthExceeded) {
+		t.Errorf("depth 3 with max 2: error = %v, want ErrCallDepthExceeded", err)
+	}
+	if err := CheckCallChain([]string{"A", "B", "C"}, "D", 0); err != nil {
+		t.Errorf("depth 3 without limit: error = %v", err)
+	}
+}
+
+func TestParseCallChain(t *testing.T) {
+	if got := ParseCallChain(""); got != nil {
+		t.Errorf("ParseCallChain(\"\") = %v, want nil", got)
+	}
+	got := ParseCallChain(" fn-a, ,fn-b ")
+	if !reflect.DeepEqual(got, []string{"fn-a", "fn-b"}) {
+		t.Errorf("ParseCallChain() = %v, want [fn-a fn-b]", got)
+	}
+	if FormatCallChain(got) != "fn-a,fn-b" {
+		t.Errorf("FormatCallChain() = %q, want fn-a,fn-b", FormatCallChain(got))
+	}
+}
@@ -31,6 +31,26 @@ var (
 	ErrInvalidTimeout = errors.New("invalid timeout: must be between 1 and 300 seconds")
 	// ErrInvalidCronExpression 表示定时任务表达式无效
 	ErrInvalidCronExpression = errors.New("invalid cron expression")
+	// ErrInvalidCronTimezone 表示定时任务时区不是有效的 IANA 时区名，或与表达式中的 CRON_TZ 前缀冲突
+	ErrInvalidCronTimezone = errors.New("invalid cron_timezone: must be an IANA time zone name such as Asia/Shanghai and cannot be combined with a CRON_TZ= prefix")
+	// ErrInvalidWarmupStrategy 表示预热策略无效或 scheduled 策略缺少有效的预热计划
+	ErrInvalidWarmupStrategy = errors.New("invalid warmup strategy: must be none, on_deploy, predictive or scheduled (scheduled requires a valid warmup_schedule)")
+	// ErrInvalidMaxInvocationRecords 表示调用记录保留条数上限为负数
+	ErrInvalidMaxInvocationRecords = errors.New("invalid max_invocation_records: must be 0 (unlimited) or positive")
+	// ErrInvalidSnapshotTTL 表示快照保留时长为负数
+	ErrInvalidSnapshotTTL = errors.New("invalid snapshot_ttl_hours: must be 0 (default) or positive")
+	// ErrInvalidCacheTTL 表示结果缓存有效期为负数
+	ErrInvalidCacheTTL = errors.New("invalid cache_ttl_sec: must be 0 (default) or positive")
+	// ErrInvalidInstallDeps 表示要求安装依赖的运行时不支持安装，或安装超时为负数
+	ErrInvalidInstallDeps = errors.New("invalid install_deps: only supported by python3.11 (requirements.txt) and nodejs20 (package.json), and install_deps_timeout_sec must be 0 (default) or positive")
+	// ErrInvalidWarmupHandler 表示声明了预热函数的运行时不支持预热，或预热超时为负数
+	ErrInvalidWarmupHandler = errors.New("invalid warmup_handler: only supported by python3.11 and nodejs20, and warmup_timeout_sec must be 0 (default) or positive")
+	// ErrInvalidIOLimits 表示磁盘或网络限速参数为负数
+	ErrInvalidIOLimits = errors.New("invalid io_limits: values must be 0 (default) or positive")
+	// ErrInvalidRateLimit 表示调用限流参数为负数
+	ErrInvalidRateLimit = errors.New("invalid rate_limit: values must be 0 (default) or positive")
+	// ErrInvalidInputSchema 表示函数的输入 JSON Schema 无法解析或编译
+	ErrInvalidInputSchema = errors.New("invalid input_schema")
 
 	// ========== 调用相关错误 ==========
 
@@ -42,6 +62,12 @@ var (
 	ErrInvocationFailed = errors.New("invocation failed")
 	// ErrInvocationCancelled 表示函数调用被取消
 	ErrInvocationCancelled = errors.New("invocation cancelled")
+	// ErrConcurrencyLimitExceeded 表示函数正在执行的调用数已达到 max_concurrency
+	ErrConcurrencyLimitExceeded = errors.New("function concurrency limit exceeded")
+	// ErrDependencyCycle 表示函数间调用会形成循环调用
+	ErrDependencyCycle = errors.New("function call would form a dependency cycle")
+	// ErrCallDepthExceeded 表示函数间调用链超过了最大长度
+	ErrCallDepthExceeded = errors.New("function call depth exceeded")
 
 	// ========== 虚拟机相关错误 ==========
 
@@ -57,6 +83,8 @@ var (
 	ErrNoAvailableVM = errors.New("no available vm")
 	// ErrVMPoolExhausted 表示虚拟机池资源耗尽
 	ErrVMPoolExhausted = errors.New("vm pool exhausted")
+	// ErrNodeSelectorMismatch 表示当前节点的标签不满足函数的节点选择器
+	ErrNodeSelectorMismatch = errors.New("node selector does not match this node")
 	// ErrSnapshotNotFound 表示请求的快照不存在
 	ErrSnapshotNotFound = errors.New("snapshot not found")
 	// ErrSnapshotFailed 表示快照创建失败
@@ -76,6 +104,15 @@ var (
 	// ErrStorageQuery 表示存储查询错误（如 SQL 查询失败）
 	ErrStorageQuery = errors.New("storage query error")
 
+	// ========== 环境相关错误 ==========
+
+	// ErrEnvironmentNotFound 表示请求的环境不存在
+	ErrEnvironmentNotFound = errors.New("environment not found")
+	// ErrEnvConfigNotFound 表示函数在该环境下没有配置
+	ErrEnvConfigNotFound = errors.New("function has no config in this environment")
+	// ErrInvalidPromotion 表示晋升的源环境和目标环境相同或未指定
+	ErrInvalidPromotion = errors.New("invalid promotion: from and to must be different environments")
+
 	// ========== 工作流相关错误 ==========
 
 	// ErrWorkflowNotFound 表示请求的工作流不存在
@@ -142,4 +179,15 @@ var (
 	ErrInvalidWeights = errors.New("weights must sum to 100")
 	// ErrCannotDeleteLatest 表示无法删除 latest 别名
 	ErrCannotDeleteLatest = errors.New("cannot delete 'latest' alias")
+
+	// ========== 金丝雀发布相关错误 ==========
+
+	// ErrCanaryNotFound 表示请求的金丝雀发布不存在
+	ErrCanaryNotFound = errors.New("canary deployment not found")
+	// ErrCanaryExists 表示别名上已有进行中的金丝雀发布
+	ErrCanaryExists = errors.New("a canary deployment is already running for this alias")
+	// ErrCanaryNotRunning 表示金丝雀发布已结束（已提升、回滚或终止），不能再修改
+	ErrCanaryNotRunning = errors.New("canary deployment is no longer running")
+	// ErrInvalidCanary 表示金丝雀发布参数无效
+	ErrInvalidCanary = errors.New("invalid canary: stable_version and canary_version must be different positive versions, initial_weight 1-99, step_weight 1-100, max_latency_ratio >= 1")
 )
@@ -4,7 +4,12 @@
 package domain
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -20,8 +25,12 @@ const (
 	RuntimePython311 Runtime = "python3.11"
 	// RuntimeNodeJS20 表示 Node.js 20 运行时环境
 	RuntimeNodeJS20 Runtime = "nodejs20"
+	// RuntimeRuby32 表示 Ruby 3.2 运行时环境
+	RuntimeRuby32 Runtime = "ruby3.2"
 	// RuntimeGo124 表示 Go 1.24 运行时环境
 	RuntimeGo124 Runtime = "go1.24"
+	// RuntimeJava17 表示 Java 17 运行时环境
+	RuntimeJava17 Runtime = "java17"
 	// RuntimeWasm 表示 WebAssembly 运行时环境
 	RuntimeWasm Runtime = "wasm"
 )
@@ -34,6 +43,53 @@ const (
 	MaxBinarySize = 50 * 1024 * 1024
 )
 
+// DefaultCacheTTLSec 是函数未设置 cache_ttl_sec 时结果缓存的默认有效期（秒）
+const DefaultCacheTTLSec = 300
+
+// ResultCacheTTL 返回函数结果缓存的有效期，未设置时使用 DefaultCacheTTLSec
+func (f *Function) ResultCacheTTL() time.Duration {
+	if f.CacheTTLSec > 0 {
+		return time.Duration(f.CacheTTLSec) * time.Second
+	}
+	return DefaultCacheTTLSec * time.Second
+}
+
+// DefaultWarmupTimeoutSec 是函数未设置 warmup_timeout_sec 时预热函数的超时时间（秒），与 Agent 的默认值一致
+const DefaultWarmupTimeoutSec = 60
+
+// WarmupTimeout 返回预热函数的超时时间，未声明预热函数时为 0
+func (f *Function) WarmupTimeout() time.Duration {
+	if f.WarmupHandler == "" {
+		return 0
+	}
+	if f.WarmupTimeoutSec > 0 {
+		return time.Duration(f.WarmupTimeoutSec) * time.Second
+	}
+	return DefaultWarmupTimeoutSec * time.Second
+}
+
+// ValidateInstallDeps 验证依赖安装配置：只有 Python（requirements.txt）和 Node.js（package.json）运行时支持安装，超时不能为负数
+func ValidateInstallDeps(runtime Runtime, installDeps bool, timeoutSec int) error {
+	if timeoutSec < 0 {
+		return ErrInvalidInstallDeps
+	}
+	if installDeps && runtime != RuntimePython311 && runtime != RuntimeNodeJS20 {
+		return ErrInvalidInstallDeps
+	}
+	return nil
+}
+
+// ValidateWarmupHandler 验证预热函数配置：只有 Python 和 Node.js 运行时支持预热，超时不能为负数
+func ValidateWarmupHandler(runtime Runtime, handler string, timeoutSec int) error {
+	if timeoutSec < 0 {
+		return ErrInvalidWarmupHandler
+	}
+	if handler != "" && runtime != RuntimePython311 && runtime != RuntimeNodeJS20 {
+		return ErrInvalidWarmupHandler
+	}
+	return nil
+}
+
 // ValidateCodeSize 验证代码大小是否在限制范围内
 // 返回 nil 表示验证通过，否则返回 ErrCodeSizeExceeded
 func ValidateCodeSize(code string) error {
@@ -68,6 +124,22 @@ func ValidateCronExpression(expr string) error {
 	return nil
 }
 
+// ValidateCronTimezone 验证定时任务时区是否为有效的 IANA 时区名（如 "Asia/Shanghai"），空字符串表示网关所在时区。
+// 表达式已通过 CRON_TZ= 或 TZ= 前缀指定时区时不能再设置时区。
+// 返回 nil 表示验证通过，否则返回 ErrInvalidCronTimezone
+func ValidateCronTimezone(expr, timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		return ErrInvalidCronTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return ErrInvalidCronTimezone
+	}
+	return nil
+}
+
 // GetCodeSizeInfo 返回代码大小信息
 func GetCodeSizeInfo(code string) (size int, limit int, percentage float64) {
 	size = len(code)
@@ -80,7 +152,7 @@ func GetCodeSizeInfo(code string) (size int, limit int, percentage float64) {
 // 返回 true 表示该运行时是受支持的，返回 false 表示不受支持。
 func (r Runtime) IsValid() bool {
 	switch r {
-	case RuntimePython311, RuntimeNodeJS20, RuntimeGo124, RuntimeWasm:
+	case RuntimePython311, RuntimeNodeJS20, RuntimeRuby32, RuntimeGo124, RuntimeJava17, RuntimeWasm:
 		return true
 	default:
 		return false
@@ -109,6 +181,17 @@ const (
 	FunctionStatusFailed FunctionStatus = "failed"
 )
 
+// IsValid 检查函数状态是否为已定义的状态
+func (s FunctionStatus) IsValid() bool {
+	switch s {
+	case FunctionStatusCreating, FunctionStatusActive, FunctionStatusUpdating, FunctionStatusOffline,
+		FunctionStatusInactive, FunctionStatusBuilding, FunctionStatusFailed:
+		return true
+	default:
+		return false
+	}
+}
+
 // CanInvoke 检查当前状态是否可以调用函数
 func (s FunctionStatus) CanInvoke() bool {
 	return s == FunctionStatusActive
@@ -129,6 +212,43 @@ func (s FunctionStatus) CanOnline() bool {
 	return s == FunctionStatusOffline
 }
 
+// WarmupStrategy 表示函数的冷启动预热策略。
+type WarmupStrategy string
+
+// 预热策略常量定义
+const (
+	// WarmupNone 不主动预热，由预热池按全局配置维护
+	WarmupNone WarmupStrategy = "none"
+	// WarmupOnDeploy 函数部署完成后立即预热
+	WarmupOnDeploy WarmupStrategy = "on_deploy"
+	// WarmupPredictive 根据近期调用规律预热（暂未实现，按 none 处理）
+	WarmupPredictive WarmupStrategy = "predictive"
+	// WarmupScheduled 按 cron 计划预热，如工作时间开始前
+	WarmupScheduled WarmupStrategy = "scheduled"
+)
+
+// IsValid 检查预热策略是否为已定义的策略，空值视为 none
+func (s WarmupStrategy) IsValid() bool {
+	switch s {
+	case "", WarmupNone, WarmupOnDeploy, WarmupPredictive, WarmupScheduled:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidateWarmup 验证预热策略及其预热计划。
+// scheduled 策略必须提供有效的 cron 表达式，其他策略不使用预热计划。
+func ValidateWarmup(strategy WarmupStrategy, schedule string) error {
+	if !strategy.IsValid() {
+		return ErrInvalidWarmupStrategy
+	}
+	if strategy == WarmupScheduled && (schedule == "" || ValidateCronExpression(schedule) != nil) {
+		return ErrInvalidWarmupStrategy
+	}
+	return nil
+}
+
 // Function 表示一个无服务器函数实体。
 // 这是函数计算平台的核心领域对象，包含了函数的所有配置和元数据。
 type Function struct {
@@ -170,6 +290,8 @@ type Function struct {
 	Version int `json:"version"`
 	// CronExpression 是定时任务表达式（可选），如 "*/5 * * * *"
 	CronExpression string `json:"cron_expression,omitempty"`
+	// CronTimezone 是定时任务的 IANA 时区名（可选），如 "Asia/Shanghai"，为空表示网关所在时区
+	CronTimezone string `json:"cron_timezone,omitempty"`
 	// HTTPPath 是自定义 HTTP 路由路径（可选），如 "/api/hello"
 	HTTPPath string `json:"http_path,omitempty"`
 	// HTTPMethods 是自定义 HTTP 路由方法（可选），如 ["GET", "POST"]
@@ -182,6 +304,42 @@ type Function struct {
 	LastDeployedAt *time.Time `json:"last_deployed_at,omitempty"`
 	// StateConfig 是状态配置（可选），用于启用有状态函数功能
 	StateConfig *StateConfig `json:"state_config,omitempty"`
+	// NodeSelector 是节点选择器（可选），函数只在标签全部匹配的节点上运行，为空表示任意节点
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	// WarmupStrategy 是冷启动预热策略，默认 none
+	WarmupStrategy WarmupStrategy `json:"warmup_strategy"`
+	// WarmupSchedule 是 scheduled 策略的预热 cron 表达式
+	WarmupSchedule string `json:"warmup_schedule,omitempty"`
+	// OwnerID 是函数所有者的用户 ID（可选），只能通过所有权转移修改
+	OwnerID string `json:"owner_id,omitempty"`
+	// MaxInvocationRecords 是调用记录保留条数上限，0 表示不限制，超出部分由保留策略清理
+	MaxInvocationRecords int `json:"max_invocation_records"`
+	// PropagateIdentity 表示是否将调用方身份注入函数输入（_nimbus.identity）和环境变量
+	PropagateIdentity bool `json:"propagate_identity"`
+	// SnapshotTTLHours 是函数快照的保留时长（小时），0 表示使用全局默认值
+	SnapshotTTLHours int `json:"snapshot_ttl_hours"`
+	// IOLimits 是虚拟机磁盘和网络限速（可选），未设置的项使用运行时默认值
+	IOLimits *IOLimits `json:"io_limits,omitempty"`
+	// InputSchema 是调用输入的 JSON Schema（可选），设置后网关在调度前校验输入，为空表示不校验
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+	// RateLimit 是函数的调用限流（可选），为空时使用系统设置 rate_limit_rps/rate_limit_burst
+	RateLimit *InvokeRateLimit `json:"rate_limit,omitempty"`
+	// Cacheable 表示函数是确定性的，相同版本和输入的成功结果可以缓存并直接返回
+	Cacheable bool `json:"cacheable"`
+	// CacheTTLSec 是结果缓存的有效期（秒），0 表示使用默认值 DefaultCacheTTLSec
+	CacheTTLSec int `json:"cache_ttl_sec"`
+	// WarmupHandler 是虚拟机初始化函数后调用一次的预热函数（如 handler.warmup），为空表示不预热；
+	// 预热完成后虚拟机才被视为就绪，适合加载模型等耗时的准备工作
+	WarmupHandler string `json:"warmup_handler,omitempty"`
+	// WarmupTimeoutSec 是预热函数的超时时间（秒），0 表示使用默认值 DefaultWarmupTimeoutSec
+	WarmupTimeoutSec int `json:"warmup_timeout_sec"`
+	// InstallDeps 表示虚拟机初始化函数时安装代码包中 requirements.txt / package.json 声明的依赖，
+	// 依赖清单未变化时 Agent 复用已安装的依赖
+	InstallDeps bool `json:"install_deps"`
+	// InstallDepsTimeoutSec 是安装依赖的超时时间（秒），0 表示使用 Agent 的默认值
+	InstallDepsTimeoutSec int `json:"install_deps_timeout_sec"`
+	// IdempotencyKey 是创建请求携带的幂等键，只在创建时写入，查询函数时不读回
+	IdempotencyKey string `json:"-"`
 	// CreatedAt 是函数的创建时间
 	CreatedAt time.Time `json:"created_at"`
 	// UpdatedAt 是函数的最后更新时间
@@ -264,10 +422,42 @@ type CreateFunctionRequest struct {
 	EnvVars map[string]string `json:"env_vars,omitempty"`
 	// CronExpression 是定时任务表达式（可选）
 	CronExpression string `json:"cron_expression,omitempty"`
+	// CronTimezone 是定时任务的 IANA 时区名（可选）
+	CronTimezone string `json:"cron_timezone,omitempty"`
 	// HTTPPath 是自定义 HTTP 路由路径（可选）
 	HTTPPath string `json:"http_path,omitempty"`
 	// HTTPMethods 是自定义 HTTP 路由方法（可选）
 	HTTPMethods []string `json:"http_methods,omitempty"`
+	// NodeSelector 是节点选择器（可选），为空表示任意节点
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	// WarmupStrategy 是冷启动预热策略（可选），默认 none
+	WarmupStrategy WarmupStrategy `json:"warmup_strategy,omitempty"`
+	// WarmupSchedule 是 scheduled 策略的预热 cron 表达式
+	WarmupSchedule string `json:"warmup_schedule,omitempty"`
+	// MaxInvocationRecords 是调用记录保留条数上限（可选），0 表示不限制
+	MaxInvocationRecords int `json:"max_invocation_records,omitempty"`
+	// PropagateIdentity 表示是否向函数传递调用方身份（可选），默认关闭
+	PropagateIdentity bool `json:"propagate_identity,omitempty"`
+	// SnapshotTTLHours 是快照保留时长（小时，可选），0 表示使用全局默认值
+	SnapshotTTLHours int `json:"snapshot_ttl_hours,omitempty"`
+	// IOLimits 是虚拟机磁盘和网络限速（可选）
+	IOLimits *IOLimits `json:"io_limits,omitempty"`
+	// InputSchema 是调用输入的 JSON Schema（可选）
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+	// RateLimit 是函数的调用限流（可选）
+	RateLimit *InvokeRateLimit `json:"rate_limit,omitempty"`
+	// Cacheable 表示是否缓存函数的成功结果（可选），默认关闭，仅适用于确定性函数
+	Cacheable bool `json:"cacheable,omitempty"`
+	// CacheTTLSec 是结果缓存的有效期（秒，可选），0 表示使用默认值
+	CacheTTLSec int `json:"cache_ttl_sec,omitempty"`
+	// WarmupHandler 是虚拟机初始化后调用一次的预热函数（可选），仅 Python 和 Node.js 运行时支持
+	WarmupHandler string `json:"warmup_handler,omitempty"`
+	// WarmupTimeoutSec 是预热函数的超时时间（秒，可选），0 表示使用默认值
+	WarmupTimeoutSec int `json:"warmup_timeout_sec,omitempty"`
+	// InstallDeps 表示初始化时安装代码包声明的依赖（可选），仅 Python 和 Node.js 运行时支持
+	InstallDeps bool `json:"install_deps,omitempty"`
+	// InstallDepsTimeoutSec 是安装依赖的超时时间（秒，可选），0 表示使用默认值
+	InstallDepsTimeoutSec int `json:"install_deps_timeout_sec,omitempty"`
 }
 
 // Validate 验证创建函数请求的参数是否有效。
@@ -300,6 +490,40 @@ func (r *CreateFunctionRequest) Validate() error {
 	if err := ValidateCronExpression(r.CronExpression); err != nil {
 		return err
 	}
+	if err := ValidateCronTimezone(r.CronExpression, r.CronTimezone); err != nil {
+		return err
+	}
+	// 验证预热策略，未指定时默认 none
+	if err := ValidateWarmup(r.WarmupStrategy, r.WarmupSchedule); err != nil {
+		return err
+	}
+	if r.WarmupStrategy == "" {
+		r.WarmupStrategy = WarmupNone
+	}
+	if r.MaxInvocationRecords < 0 {
+		return ErrInvalidMaxInvocationRecords
+	}
+	if r.SnapshotTTLHours < 0 {
+		return ErrInvalidSnapshotTTL
+	}
+	if r.CacheTTLSec < 0 {
+		return ErrInvalidCacheTTL
+	}
+	if err := ValidateWarmupHandler(r.Runtime, r.WarmupHandler, r.WarmupTimeoutSec); err != nil {
+		return err
+	}
+	if err := ValidateInstallDeps(r.Runtime, r.InstallDeps, r.InstallDepsTimeoutSec); err != nil {
+		return err
+	}
+	if err := r.IOLimits.Validate(); err != nil {
+		return err
+	}
+	if err := ValidateInputSchema(r.InputSchema); err != nil {
+		return err
+	}
+	if err := r.RateLimit.Validate(); err != nil {
+		return err
+	}
 	// 如果未指定内存，设置默认值为 256MB
 	if r.MemoryMB == 0 {
 		r.MemoryMB = 256
@@ -340,27 +564,63 @@ type UpdateFunctionRequest struct {
 	EnvVars *map[string]string `json:"env_vars,omitempty"`
 	// CronExpression 是更新后的定时任务表达式
 	CronExpression *string `json:"cron_expression,omitempty"`
+	// CronTimezone 是更新后的定时任务时区，传空字符串表示网关所在时区
+	CronTimezone *string `json:"cron_timezone,omitempty"`
 	// HTTPPath 是更新后的自定义 HTTP 路由路径
 	HTTPPath *string `json:"http_path,omitempty"`
 	// HTTPMethods 是更新后的自定义 HTTP 路由方法
 	HTTPMethods *[]string `json:"http_methods,omitempty"`
+	// NodeSelector 是更新后的节点选择器，传空对象表示取消限制
+	NodeSelector *map[string]string `json:"node_selector,omitempty"`
+	// WarmupStrategy 是更新后的预热策略
+	WarmupStrategy *WarmupStrategy `json:"warmup_strategy,omitempty"`
+	// WarmupSchedule 是更新后的预热 cron 表达式
+	WarmupSchedule *string `json:"warmup_schedule,omitempty"`
+	// MaxInvocationRecords 是更新后的调用记录保留条数上限，0 表示不限制
+	MaxInvocationRecords *int `json:"max_invocation_records,omitempty"`
+	// PropagateIdentity 是更新后的身份传递开关
+	PropagateIdentity *bool `json:"propagate_identity,omitempty"`
+	// SnapshotTTLHours 是更新后的快照保留时长（小时），0 表示使用全局默认值
+	SnapshotTTLHours *int `json:"snapshot_ttl_hours,omitempty"`
+	// IOLimits 是更新后的磁盘和网络限速，传空对象表示全部使用运行时默认值
+	IOLimits *IOLimits `json:"io_limits,omitempty"`
+	// InputSchema 是更新后的输入 JSON Schema，传 null 表示取消输入校验
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+	// RateLimit 是更新后的调用限流，传空对象表示使用系统默认值
+	RateLimit *InvokeRateLimit `json:"rate_limit,omitempty"`
+	// Cacheable 是更新后的结果缓存开关
+	Cacheable *bool `json:"cacheable,omitempty"`
+	// CacheTTLSec 是更新后的结果缓存有效期（秒），0 表示使用默认值
+	CacheTTLSec *int `json:"cache_ttl_sec,omitempty"`
+	// WarmupHandler 是更新后的预热函数，传空字符串表示取消预热
+	WarmupHandler *string `json:"warmup_handler,omitempty"`
+	// WarmupTimeoutSec 是更新后的预热超时时间（秒），0 表示使用默认值
+	WarmupTimeoutSec *int `json:"warmup_timeout_sec,omitempty"`
+	// InstallDeps 是更新后的依赖安装开关
+	InstallDeps *bool `json:"install_deps,omitempty"`
+	// InstallDepsTimeoutSec 是更新后的依赖安装超时时间（秒），0 表示使用默认值
+	InstallDepsTimeoutSec *int `json:"install_deps_timeout_sec,omitempty"`
 }
 
 // FunctionRepository 定义了函数存储的接口。
-// 该接口抽象了函数的持久化操作，允许不同的存储实现（如数据库、内存等）。
+// 该接口抽象了函数的持久化操作，由 storage.PostgresStore 和测试用的 storagetest.MemoryStore 实现。
 type FunctionRepository interface {
-	// Create 创建一个新的函数记录
-	Create(fn *Function) error
-	// GetByID 根据 ID 获取函数
-	GetByID(id string) (*Function, error)
-	// GetByName 根据名称获取函数
-	GetByName(name string) (*Function, error)
-	// List 分页获取函数列表，返回函数列表、总数和可能的错误
-	List(offset, limit int) ([]*Function, int, error)
-	// Update 更新函数信息
-	Update(fn *Function) error
-	// Delete 根据 ID 删除函数
-	Delete(id string) error
+	// CreateFunction 创建函数，未提供 ID 时自动生成
+	CreateFunction(fn *Function) error
+	// GetFunctionByID 根据 ID 获取函数，不存在时返回 ErrFunctionNotFound
+	GetFunctionByID(id string) (*Function, error)
+	// GetFunctionByName 根据名称获取函数，不存在时返回 ErrFunctionNotFound
+	GetFunctionByName(name string) (*Function, error)
+	// ListFunctions 分页获取函数列表（置顶优先，按创建时间倒序），返回函数列表和总数
+	ListFunctions(offset, limit int) ([]*Function, int, error)
+	// GetFunctionsByStatuses 获取处于指定状态的函数
+	GetFunctionsByStatuses(statuses []string) ([]*Function, error)
+	// UpdateFunction 更新函数并递增版本号，不存在时返回 ErrFunctionNotFound
+	UpdateFunction(fn *Function) error
+	// UpsertFunction 按名称原子地创建或更新函数，返回是否为新建
+	UpsertFunction(fn *Function) (bool, error)
+	// DeleteFunction 删除函数，不存在时返回 ErrFunctionNotFound
+	DeleteFunction(id string) error
 }
 
 // FunctionFilter 用于函数列表的筛选条件
@@ -393,6 +653,42 @@ type BulkUpdateRequest struct {
 	Tags []string `json:"tags,omitempty"`
 }
 
+// BulkStatusUpdateRequest 表示批量更新函数状态的请求。
+// 用于发布编排：将一批函数切换到 updating，完成后再切回 active。
+// 与 BulkUpdateRequest 不同，不逐个校验状态流转，由一条语句完成更新。
+type BulkStatusUpdateRequest struct {
+	// IDs 要更新的函数 ID 列表
+	IDs []string `json:"ids" validate:"required,min=1"`
+	// Status 目标状态
+	Status FunctionStatus `json:"status" validate:"required"`
+	// Message 状态消息（可选），如 "base image upgrade"
+	Message string `json:"message,omitempty"`
+}
+
+// BulkStatusUpdateResult 表示批量更新函数状态的结果
+type BulkStatusUpdateResult struct {
+	// Requested 请求更新的函数数量
+	Requested int `json:"requested"`
+	// Updated 实际更新的函数数量（不存在的 ID 不计入）
+	Updated int64 `json:"updated"`
+}
+
+// TransferOwnershipRequest 表示转移函数所有权的请求
+type TransferOwnershipRequest struct {
+	// OwnerID 新所有者的用户 ID
+	OwnerID string `json:"owner_id"`
+}
+
+// OwnershipTransferResult 表示函数所有权转移的结果
+type OwnershipTransferResult struct {
+	// FunctionID 函数 ID
+	FunctionID string `json:"function_id"`
+	// OldOwnerID 转移前的所有者，为空表示此前没有所有者
+	OldOwnerID string `json:"old_owner_id"`
+	// NewOwnerID 转移后的所有者
+	NewOwnerID string `json:"new_owner_id"`
+}
+
 // BulkOperationResult 表示批量操作的结果
 type BulkOperationResult struct {
 	// Success 成功处理的函数 ID 列表
@@ -401,6 +697,18 @@ type BulkOperationResult struct {
 	Failed []BulkOperationFailure `json:"failed"`
 }
 
+// FunctionValidationResult 表示函数定义预检（dry-run）的结果，预检不会保存任何数据
+type FunctionValidationResult struct {
+	// Valid 函数定义是否通过校验，配额警告不影响该值
+	Valid bool `json:"valid"`
+	// Function 补全默认值后将要写入的函数定义（未保存）
+	Function *Function `json:"function"`
+	// Errors 校验错误，Valid 为 false 时非空
+	Errors []string `json:"errors,omitempty"`
+	// Warnings 不阻止写入的问题，如超出配额
+	Warnings []string `json:"warnings,omitempty"`
+}
+
 // BulkOperationFailure 表示单个失败的操作
 type BulkOperationFailure struct {
 	// ID 失败的函数 ID
@@ -426,6 +734,28 @@ type InvokeRequest struct {
 	Version int `json:"version,omitempty"`
 	// SessionKey 会话标识，用于有状态函数的状态隔离和会话亲和性路由
 	SessionKey string `json:"session_key,omitempty"`
+	// Environment 指定调用所在的环境名称，为空则使用默认环境，决定生效的环境变量
+	Environment string `json:"environment,omitempty"`
+	// Identity 是网关认证得到的调用方身份（不参与 JSON 序列化，调用方无法自行指定）
+	Identity *Identity `json:"-"`
+	// Context 是发起调用的请求上下文（不参与 JSON 序列化），调度器从中读取追踪信息，
+	// 把函数执行的 span 挂到上游请求的链路下；为空时执行链路作为新的 trace 开始
+	Context context.Context `json:"-"`
+	// Trigger 是触发本次调用的方式（不参与 JSON 序列化），记录到调用的 trigger_type，为空时视为 HTTP 调用
+	Trigger TriggerType `json:"-"`
+	// ReplayOf 是被重放的原始调用 ID（不参与 JSON 序列化），记录到新调用的 replay_of
+	ReplayOf string `json:"-"`
+	// CallChain 是发起本次调用的函数 ID（不参与 JSON 序列化），从最上游函数到直接调用方，
+	// 来自 HeaderCallChain 请求头或平台发起的函数间调用，不是函数间调用时为空
+	CallChain []string `json:"-"`
+}
+
+// TriggerType 返回本次调用的触发方式，未指定时为 TriggerHTTP
+func (r *InvokeRequest) TriggerType() TriggerType {
+	if r.Trigger == "" {
+		return TriggerHTTP
+	}
+	return r.Trigger
 }
 
 // InvokeResponse 表示函数调用响应结构体。
@@ -445,6 +775,8 @@ type InvokeResponse struct {
 	ColdStart bool `json:"cold_start"`
 	// BilledTimeMs 是计费时长（单位：毫秒），按最小计费单位向上取整
 	BilledTimeMs int64 `json:"billed_time_ms"`
+	// CacheHit 表示结果来自结果缓存，函数没有实际执行
+	CacheHit bool `json:"cache_hit,omitempty"`
 	// Version 是实际执行的函数版本号
 	Version int `json:"version,omitempty"`
 	// AliasUsed 是调用时使用的别名（如果有）
@@ -453,6 +785,44 @@ type InvokeResponse struct {
 	SessionKey string `json:"session_key,omitempty"`
 }
 
+// BatchInvokeRequest 表示批量调用请求：对每个输入各同步调用一次函数。
+type BatchInvokeRequest struct {
+	// Inputs 是函数输入列表，每个元素作为一次调用的载荷
+	Inputs []json.RawMessage `json:"inputs"`
+	// Parallelism 是同时执行的最大调用数，可选，不超过服务端上限和函数的 max_concurrency
+	Parallelism int `json:"parallelism,omitempty"`
+}
+
+// BatchInvokeItem 表示批量调用中单个输入的调用结果。
+type BatchInvokeItem struct {
+	// Index 是输入在请求中的下标
+	Index int `json:"index"`
+	// RequestID 是本次调用的请求标识，调用未能提交时为空
+	RequestID string `json:"request_id,omitempty"`
+	// StatusCode 是调用的状态码，调用未能提交时为对应的 HTTP 错误码（如 429）
+	StatusCode int `json:"status_code"`
+	// Body 是函数的返回结果
+	Body json.RawMessage `json:"body,omitempty"`
+	// Error 是调用失败时的错误信息
+	Error string `json:"error,omitempty"`
+	// DurationMs 是函数执行耗时（单位：毫秒）
+	DurationMs int64 `json:"duration_ms"`
+	// ColdStart 表示本次调用是否为冷启动
+	ColdStart bool `json:"cold_start"`
+}
+
+// BatchInvokeResponse 表示批量调用的结果，Results 与请求的输入一一对应且顺序一致。
+type BatchInvokeResponse struct {
+	// FunctionID 是被调用的函数 ID
+	FunctionID string `json:"function_id"`
+	// Results 是每个输入的调用结果
+	Results []BatchInvokeItem `json:"results"`
+	// Succeeded 是状态码小于 400 的调用数
+	Succeeded int `json:"succeeded"`
+	// Failed 是失败的调用数
+	Failed int `json:"failed"`
+}
+
 // ==================== 版本管理相关类型 ====================
 
 // FunctionVersion 表示函数的一个不可变版本快照。
@@ -505,6 +875,34 @@ type RoutingConfig struct {
 	Weights []VersionWeight `json:"weights"`
 }
 
+// UnmarshalJSON 解析路由配置。
+// 除 {"weights": [{"version": 3, "weight": 90}]} 外，也接受以版本号为键的简写形式
+// {"v3": 90, "v4": 10}（前缀 v 可省略），简写形式按版本号升序排列。
+func (c *RoutingConfig) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Weights = nil
+	if weights, ok := raw["weights"]; ok {
+		return json.Unmarshal(weights, &c.Weights)
+	}
+
+	for key, value := range raw {
+		version, err := strconv.Atoi(strings.TrimPrefix(key, "v"))
+		if err != nil {
+			return fmt.Errorf("invalid routing config version %q", key)
+		}
+		var weight int
+		if err := json.Unmarshal(value, &weight); err != nil {
+			return fmt.Errorf("invalid routing config weight for %q: %w", key, err)
+		}
+		c.Weights = append(c.Weights, VersionWeight{Version: version, Weight: weight})
+	}
+	sort.Slice(c.Weights, func(i, j int) bool { return c.Weights[i].Version < c.Weights[j].Version })
+	return nil
+}
+
 // VersionWeight 定义单个版本的流量权重。
 type VersionWeight struct {
 	// Version 是目标版本号
@@ -578,6 +976,8 @@ type FunctionLayer struct {
 	LayerVersion int `json:"layer_version"`
 	// Order 是层的加载顺序
 	Order int `json:"order"`
+	// ContentHash 是所用层版本内容的 SHA-256 哈希（十六进制）
+	ContentHash string `json:"content_hash,omitempty"`
 }
 
 // RuntimeLayerInfo 表示运行时加载层所需的信息。
@@ -616,6 +1016,8 @@ type Environment struct {
 	Description string `json:"description,omitempty"`
 	// IsDefault 表示是否为默认环境
 	IsDefault bool `json:"is_default"`
+	// DefaultEnvVars 是环境级别的默认环境变量，对该环境下的所有函数生效
+	DefaultEnvVars map[string]string `json:"default_env_vars,omitempty"`
 	// CreatedAt 是环境创建时间
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -650,6 +1052,32 @@ type CreateEnvironmentRequest struct {
 	Description string `json:"description,omitempty"`
 	// IsDefault 是否设为默认环境
 	IsDefault bool `json:"is_default,omitempty"`
+	// DefaultEnvVars 是环境级别的默认环境变量，可选
+	DefaultEnvVars map[string]string `json:"default_env_vars,omitempty"`
+}
+
+// UpdateEnvironmentEnvVarsRequest 表示更新环境默认环境变量的请求。
+type UpdateEnvironmentEnvVarsRequest struct {
+	// DefaultEnvVars 是新的环境默认环境变量，整体替换原有值
+	DefaultEnvVars map[string]string `json:"default_env_vars"`
+}
+
+// MergeEnvVars 按优先级合并函数调用时生效的环境变量。
+// 优先级从低到高依次为：
+//  1. 环境默认变量（environments.default_env_vars）
+//  2. 函数级变量（functions.env_vars）
+//  3. 函数在该环境下的覆盖变量（function_environment_configs.env_vars）
+//
+// 同名变量由优先级高的一方覆盖，任意参数为 nil 时跳过。
+// 返回值始终是新分配的映射，不会修改入参。
+func MergeEnvVars(envDefaults, functionVars, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(envDefaults)+len(functionVars)+len(overrides))
+	for _, vars := range []map[string]string{envDefaults, functionVars, overrides} {
+		for k, v := range vars {
+			merged[k] = v
+		}
+	}
+	return merged
 }
 
 // UpdateFunctionEnvConfigRequest 表示更新函数环境配置的请求。
@@ -664,6 +1092,103 @@ type UpdateFunctionEnvConfigRequest struct {
 	ActiveAlias *string `json:"active_alias,omitempty"`
 }
 
+// PromoteFunctionRequest 表示将函数配置从一个环境晋升到另一个环境的请求。
+type PromoteFunctionRequest struct {
+	// From 是源环境名称
+	From string `json:"from"`
+	// To 是目标环境名称
+	To string `json:"to"`
+}
+
+// ==================== 函数导出包相关类型 ====================
+
+// FunctionBundleFormatVersion 是当前函数导出包的格式版本
+const FunctionBundleFormatVersion = 1
+
+// FunctionBundle 表示可在 Nimbus 实例间迁移的函数导出包。
+// 包含函数定义及其依赖的层内容、别名、各环境配置和别名引用的版本，
+// 不包含实例相关的 ID、状态、编译产物和 Webhook 密钥，导入时重新生成。
+type FunctionBundle struct {
+	// FormatVersion 是导出包的格式版本
+	FormatVersion int `json:"format_version"`
+	// ExportedAt 是导出时间
+	ExportedAt time.Time `json:"exported_at"`
+	// Function 是函数定义
+	Function *Function `json:"function"`
+	// Layers 是函数使用的层及其内容，按加载顺序排列
+	Layers []BundleLayer `json:"layers,omitempty"`
+	// Aliases 是函数的别名
+	Aliases []*FunctionAlias `json:"aliases,omitempty"`
+	// EnvConfigs 是函数在各环境下的配置，按环境名称关联
+	EnvConfigs []*FunctionEnvConfig `json:"env_configs,omitempty"`
+	// Versions 是最新版本和别名引用的版本，按版本号升序排列
+	Versions []*FunctionVersion `json:"versions,omitempty"`
+}
+
+// BundleLayer 表示导出包中函数使用的一个层版本。
+type BundleLayer struct {
+	// Name 是层名称，导入时按名称匹配目标实例中的层
+	Name string `json:"name"`
+	// Description 是层描述
+	Description string `json:"description,omitempty"`
+	// CompatibleRuntimes 是兼容的运行时列表
+	CompatibleRuntimes []string `json:"compatible_runtimes,omitempty"`
+	// Version 是导出时使用的层版本号，导入后可能映射为其他版本号
+	Version int `json:"version"`
+	// Order 是层的加载顺序
+	Order int `json:"order"`
+	// ContentHash 是层内容的 SHA-256 哈希（十六进制）
+	ContentHash string `json:"content_hash"`
+	// Content 是层内容（ZIP 压缩包），zip 格式的导出包中为空，内容保存在 ContentFile 中
+	Content []byte `json:"content,omitempty"`
+	// ContentFile 是 zip 格式导出包中保存层内容的文件路径
+	ContentFile string `json:"content_file,omitempty"`
+}
+
+// FunctionImportResult 表示导入函数导出包的结果。
+type FunctionImportResult struct {
+	// Function 是新创建的函数
+	Function *Function `json:"function"`
+	// TaskID 是函数创建任务 ID
+	TaskID string `json:"task_id,omitempty"`
+	// Renamed 表示因名称冲突使用了新名称
+	Renamed bool `json:"renamed,omitempty"`
+	// Layers 是函数关联的层，版本号为目标实例中的版本
+	Layers []FunctionLayer `json:"layers,omitempty"`
+	// Aliases 是创建的别名数
+	Aliases int `json:"aliases"`
+	// Versions 是创建的版本数
+	Versions int `json:"versions"`
+	// EnvConfigs 是创建的环境配置数
+	EnvConfigs int `json:"env_configs"`
+	// Warnings 是未能导入的内容，如目标实例中不存在的环境
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ==================== 节点选择相关类型 ====================
+
+// Node 表示一个运行调度器的计算节点。
+// 调度器启动时注册节点及其标签，用于校验函数的节点选择器。
+type Node struct {
+	// Name 是节点名称
+	Name string `json:"name"`
+	// Labels 是节点标签（如 gpu: "true"）
+	Labels map[string]string `json:"labels,omitempty"`
+	// LastSeenAt 是节点最近一次注册的时间
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// MatchesNodeSelector 判断节点标签是否满足节点选择器。
+// 选择器中的每个键值对都必须在节点标签中存在且相等；选择器为空时匹配任意节点。
+func MatchesNodeSelector(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
 // ==================== 死信队列 (DLQ) 相关类型 ====================
 
 // DeadLetterMessage 表示死信队列中的一条消息。
@@ -705,6 +1230,85 @@ const (
 	DLQStatusDiscarded = "discarded"
 )
 
+// ==================== 调用结果目标 (Destinations) 相关类型 ====================
+
+// DestinationType 表示调用结果投递目标的类型。
+type DestinationType string
+
+// 结果投递目标类型常量
+const (
+	// DestinationFunction 将结果作为输入异步调用另一个函数
+	DestinationFunction DestinationType = "function"
+	// DestinationWebhook 将结果 POST 到指定的 Webhook URL
+	DestinationWebhook DestinationType = "webhook"
+	// DestinationDLQ 将结果写入死信队列
+	DestinationDLQ DestinationType = "dlq"
+)
+
+// IsValid 检查目标类型是否有效。
+func (t DestinationType) IsValid() bool {
+	switch t {
+	case DestinationFunction, DestinationWebhook, DestinationDLQ:
+		return true
+	}
+	return false
+}
+
+// Destination 表示一个调用结果投递目标。
+type Destination struct {
+	// Type 是目标类型（function/webhook/dlq）
+	Type DestinationType `json:"type"`
+	// Target 是目标地址：函数名称或 Webhook URL，dlq 类型无需填写
+	Target string `json:"target,omitempty"`
+}
+
+// Validate 校验目标配置。
+func (d *Destination) Validate() error {
+	if !d.Type.IsValid() {
+		return fmt.Errorf("invalid destination type: %s", d.Type)
+	}
+	if d.Type != DestinationDLQ && d.Target == "" {
+		return fmt.Errorf("destination target is required for type %s", d.Type)
+	}
+	return nil
+}
+
+// DestinationConfig 表示函数的调用结果投递配置。
+// 异步调用完成后，成功结果投递到 OnSuccess，失败结果投递到 OnFailure；
+// 未配置 OnFailure 时，失败结果默认写入死信队列。
+type DestinationConfig struct {
+	// FunctionID 是关联的函数 ID
+	FunctionID string `json:"function_id"`
+	// OnSuccess 是成功结果的投递目标（可选）
+	OnSuccess *Destination `json:"on_success,omitempty"`
+	// OnFailure 是失败结果的投递目标（可选，默认 DLQ）
+	OnFailure *Destination `json:"on_failure,omitempty"`
+	// UpdatedAt 是最后更新时间
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DestinationRecord 是投递到目标的调用结果记录。
+type DestinationRecord struct {
+	// InvocationID 是源调用的 ID
+	InvocationID string `json:"invocation_id"`
+	// FunctionID 是源函数 ID
+	FunctionID string `json:"function_id"`
+	// FunctionName 是源函数名称
+	FunctionName string `json:"function_name"`
+	// Status 是调用的最终状态
+	Status InvocationStatus `json:"status"`
+	// Input 是源调用的输入
+	Input json.RawMessage `json:"input,omitempty"`
+	// Output 是源调用的输出（成功时）
+	Output json.RawMessage `json:"output,omitempty"`
+	// Error 是错误信息（失败时）
+	Error string `json:"error,omitempty"`
+	// DurationMs 是执行时长（毫秒）
+	DurationMs int64 `json:"duration_ms"`
+	// Timestamp 是调用完成时间
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // ==================== 有状态函数相关类型 ====================
 
 // StateConfig 状态配置，用于启用和配置函数的状态管理功能。
@@ -725,6 +1329,59 @@ type StateConfig struct {
 	SessionTimeout int `json:"session_timeout,omitempty"`
 }
 
+// IOLimits 虚拟机磁盘和网络限速，用于防止单个函数占满宿主机的磁盘或网络带宽。
+// 各项为 0 表示该项使用运行时默认值（运行时也未配置时不限速）。
+type IOLimits struct {
+	// DiskMBps 根文件系统读写带宽上限（MB/s）
+	DiskMBps float64 `json:"disk_mbps,omitempty"`
+	// DiskIOPS 根文件系统每秒读写操作数上限
+	DiskIOPS int `json:"disk_iops,omitempty"`
+	// NetMBps 网卡收、发方向各自的带宽上限（MB/s）
+	NetMBps float64 `json:"net_mbps,omitempty"`
+	// NetPPS 网卡收、发方向各自的每秒包数上限
+	NetPPS int `json:"net_pps,omitempty"`
+}
+
+// Validate 校验限速参数均不为负数，nil 表示未设置
+func (l *IOLimits) Validate() error {
+	if l == nil {
+		return nil
+	}
+	if l.DiskMBps < 0 || l.DiskIOPS < 0 || l.NetMBps < 0 || l.NetPPS < 0 {
+		return ErrInvalidIOLimits
+	}
+	return nil
+}
+
+// IsZero 判断是否未设置任何限速
+func (l *IOLimits) IsZero() bool {
+	return l == nil || *l == IOLimits{}
+}
+
+// InvokeRateLimit 函数调用限流（令牌桶），每个调用方（API Key、用户或客户端 IP）对每个函数单独计数。
+type InvokeRateLimit struct {
+	// RPS 每秒补充的令牌数，即稳定状态下允许的每秒调用数，0 表示使用系统默认值
+	RPS float64 `json:"rps,omitempty"`
+	// Burst 令牌桶容量，即允许的突发调用数，0 表示取 RPS 向上取整（至少为 1）
+	Burst int `json:"burst,omitempty"`
+}
+
+// Validate 校验限流参数均不为负数，nil 表示未设置
+func (l *InvokeRateLimit) Validate() error {
+	if l == nil {
+		return nil
+	}
+	if l.RPS < 0 || l.Burst < 0 {
+		return ErrInvalidRateLimit
+	}
+	return nil
+}
+
+// IsZero 判断是否未设置限流
+func (l *InvokeRateLimit) IsZero() bool {
+	return l == nil || *l == InvokeRateLimit{}
+}
+
 // DefaultStateConfig 返回默认的状态配置
 func DefaultStateConfig() *StateConfig {
 	return &StateConfig{
@@ -2,6 +2,8 @@
 package domain
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -150,6 +152,30 @@ func TestCreateFunctionRequest_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			// 测试用例：有效的 IO 限速
+			name: "valid io limits",
+			req: CreateFunctionRequest{
+				Name:     "test-function",
+				Runtime:  "python3.11",
+				Handler:  "handler.main",
+				Code:     "def main(event): return {}",
+				IOLimits: &IOLimits{DiskMBps: 20, NetPPS: 1000},
+			},
+			wantErr: false,
+		},
+		{
+			// 测试用例：IO 限速为负数
+			name: "negative io limits",
+			req: CreateFunctionRequest{
+				Name:     "test-function",
+				Runtime:  "python3.11",
+				Handler:  "handler.main",
+				Code:     "def main(event): return {}",
+				IOLimits: &IOLimits{NetMBps: -1},
+			},
+			wantErr: true,
+		},
 	}
 
 	// 遍历所有测试用例
@@ -174,9 +200,10 @@ func TestRuntime_IsValid(t *testing.T) {
 		{RuntimePython311, true},  // Python 3.11 应该是有效的
 		{RuntimeNodeJS20, true},   // Node.js 20 应该是有效的
 		{RuntimeGo124, true},      // Go 1.24 应该是有效的
+		{RuntimeJava17, true},     // Java 17 应该是有效的
 		{Runtime("python3.10"), false}, // Python 3.10 不受支持
 		{Runtime("nodejs18"), false},   // Node.js 18 不受支持
-		{Runtime("java"), false},       // Java 不受支持
+		{Runtime("java"), false},       // 未带版本号的 java 不受支持
 		{Runtime(""), false},           // 空字符串无效
 	}
 
@@ -447,3 +474,189 @@ func TestCreateFunctionRequest_Validate_CronExpression(t *testing.T) {
 		})
 	}
 }
+
+// TestMergeEnvVars 测试环境变量的合并优先级：
+// 环境默认变量 < 函数级变量 < 函数在该环境下的覆盖变量
+func TestMergeEnvVars(t *testing.T) {
+	tests := []struct {
+		name         string
+		envDefaults  map[string]string
+		functionVars map[string]string
+		overrides    map[string]string
+		want         map[string]string
+	}{
+		{
+			name: "all empty",
+			want: map[string]string{},
+		},
+		{
+			name:         "function overrides environment defaults",
+			envDefaults:  map[string]string{"LOG_LEVEL": "info", "REGION": "cn"},
+			functionVars: map[string]string{"LOG_LEVEL": "debug"},
+			want:         map[string]string{"LOG_LEVEL": "debug", "REGION": "cn"},
+		},
+		{
+			name:         "environment override wins over function and defaults",
+			envDefaults:  map[string]string{"DB_HOST": "db.default", "REGION": "cn"},
+			functionVars: map[string]string{"DB_HOST": "db.fn", "API_KEY": "k1"},
+			overrides:    map[string]string{"DB_HOST": "db.prod"},
+			want:         map[string]string{"DB_HOST": "db.prod", "REGION": "cn", "API_KEY": "k1"},
+		},
+		{
+			name:        "override applies without function vars",
+			envDefaults: map[string]string{"A": "1"},
+			overrides:   map[string]string{"A": "2", "B": "3"},
+			want:        map[string]string{"A": "2", "B": "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeEnvVars(tt.envDefaults, tt.functionVars, tt.overrides)
+			if len(got) != len(tt.want) {
+				t.Fatalf("MergeEnvVars() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("MergeEnvVars()[%s] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+
+	// 合并结果是新映射，修改不影响入参
+	fnVars := map[string]string{"A": "1"}
+	MergeEnvVars(nil, fnVars, nil)["A"] = "changed"
+	if fnVars["A"] != "1" {
+		t.Errorf("MergeEnvVars() mutated input map")
+	}
+}
+
+// TestMatchesNodeSelector 测试节点选择器与节点标签的匹配规则
+func TestMatchesNodeSelector(t *testing.T) {
+	labels := map[string]string{"gpu": "true", "memory": "high"}
+	tests := []struct {
+		name     string
+		selector map[string]string
+		want     bool
+	}{
+		{"empty selector matches any node", nil, true},
+		{"subset matches", map[string]string{"gpu": "true"}, true},
+		{"all labels match", map[string]string{"gpu": "true", "memory": "high"}, true},
+		{"value mismatch", map[string]string{"gpu": "false"}, false},
+		{"missing label", map[string]string{"zone": "a"}, false},
+		{"empty value requires label present", map[string]string{"zone": ""}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchesNodeSelector(tt.selector, labels); got != tt.want {
+				t.Errorf("MatchesNodeSelector(%v) = %v, want %v", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWarmup(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy WarmupStrategy
+		schedule string
+		wantErr  bool
+	}{
+		{"empty strategy defaults to none", "", "", false},
+		{"none", WarmupNone, "", false},
+		{"on_deploy", WarmupOnDeploy, "", false},
+		{"predictive", WarmupPredictive, "", false},
+		{"scheduled with schedule", WarmupScheduled, "0 30 8 * * 1-5", false},
+		{"scheduled without schedule", WarmupScheduled, "", true},
+		{"scheduled with invalid schedule", WarmupScheduled, "30 8 * * 1-5", true},
+		{"unknown strategy", WarmupStrategy("always"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWarmup(tt.strategy, tt.schedule)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateWarmup(%q, %q) error = %v, wantErr %v", tt.strategy, tt.schedule, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateWarmupHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		runtime    Runtime
+		handler    string
+		timeoutSec int
+		wantErr    bool
+	}{
+		{"no warmup", RuntimeGo124, "", 0, false},
+		{"python", RuntimePython311, "warmup", 120, false},
+		{"nodejs", RuntimeNodeJS20, "model.load", 0, false},
+		{"unsupported runtime", RuntimeGo124, "warmup", 0, true},
+		{"negative timeout", RuntimePython311, "warmup", -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWarmupHandler(tt.runtime, tt.handler, tt.timeoutSec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateWarmupHandler(%q, %q, %d) error = %v, wantErr %v", tt.runtime, tt.handler, tt.timeoutSec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateInstallDeps(t *testing.T) {
+	tests := []struct {
+		name        string
+		runtime     Runtime
+		installDeps bool
+		timeoutSec  int
+		wantErr     bool
+	}{
+		{"disabled", RuntimeGo124, false, 0, false},
+		{"python", RuntimePython311, true, 600, false},
+		{"nodejs", RuntimeNodeJS20, true, 0, false},
+		{"unsupported runtime", RuntimeJava17, true, 0, true},
+		{"negative timeout", RuntimePython311, true, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateInstallDeps(tt.runtime, tt.installDeps, tt.timeoutSec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateInstallDeps(%q, %v, %d) error = %v, wantErr %v", tt.runtime, tt.installDeps, tt.timeoutSec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutingConfigUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []VersionWeight
+		wantErr bool
+	}{
+		{"weights list", `{"weights":[{"version":3,"weight":90},{"version":4,"weight":10}]}`, []VersionWeight{{3, 90}, {4, 10}}, false},
+		{"version map", `{"v4":10,"v3":90}`, []VersionWeight{{3, 90}, {4, 10}}, false},
+		{"version map without prefix", `{"12":100}`, []VersionWeight{{12, 100}}, false},
+		{"empty", `{}`, nil, false},
+		{"invalid version key", `{"prod":100}`, nil, true},
+		{"invalid weight", `{"v3":"ninety"}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c RoutingConfig
+			err := json.Unmarshal([]byte(tt.input), &c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(c.Weights, tt.want) {
+				t.Errorf("Weights = %v, want %v", c.Weights, tt.want)
+			}
+		})
+	}
+}
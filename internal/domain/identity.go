@@ -0,0 +1,51 @@
+// Package domain 定义了函数计算平台的核心领域模型。
+package domain
+
+import (
+	"encoding/json"
+)
+
+// 身份传递相关常量
+const (
+	// ReservedInputField 是函数输入中由平台保留的字段，启用身份传递时调用方传入的同名字段会被移除
+	ReservedInputField = "_nimbus"
+	// EnvCallerID 是传递调用方用户 ID 的环境变量
+	EnvCallerID = "NIMBUS_CALLER_ID"
+	// EnvCallerRole 是传递调用方角色的环境变量
+	EnvCallerRole = "NIMBUS_CALLER_ROLE"
+)
+
+// Identity 表示已通过网关认证的调用方身份。
+// 只包含身份引用（用户 ID 和角色），不包含令牌或 API Key 本身。
+type Identity struct {
+	// UserID 调用方用户 ID
+	UserID string `json:"user_id"`
+	// Role 调用方角色
+	Role string `json:"role,omitempty"`
+	// Method 认证方式：apikey 或 jwt
+	Method string `json:"method,omitempty"`
+}
+
+// InjectIdentity 将调用方身份写入函数输入的 _nimbus.identity 字段。
+// 调用方自带的 _nimbus 字段总会被移除，防止伪造身份；identity 为 nil 时只做移除。
+// 输入不是 JSON 对象时原样返回，ok 为 false。
+func InjectIdentity(input json.RawMessage, identity *Identity) (json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if len(input) == 0 {
+		fields = make(map[string]json.RawMessage)
+	} else if err := json.Unmarshal(input, &fields); err != nil || fields == nil {
+		return input, false
+	}
+
+	delete(fields, ReservedInputField)
+	if identity != nil {
+		reserved, _ := json.Marshal(map[string]*Identity{"identity": identity})
+		fields[ReservedInputField] = reserved
+	}
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return input, false
+	}
+	return out, true
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInjectIdentity(t *testing.T) {
+	identity := &Identity{UserID: "user-1", Role: "developer", Method: "apikey"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"object input", `{"name":"nimbus"}`, `{"_nimbus":{"identity":{"user_id":"user-1","role":"developer","method":"apikey"}},"name":"nimbus"}`},
+		{"empty input", ``, `{"_nimbus":{"identity":{"user_id":"user-1","role":"developer","method":"apikey"}}}`},
+		{"spoofed identity is replaced", `{"_nimbus":{"identity":{"user_id":"admin"}}}`, `{"_nimbus":{"identity":{"user_id":"user-1","role":"developer","method":"apikey"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := InjectIdentity(json.RawMessage(tt.input), identity)
+			if !ok || string(got) != tt.want {
+				t.Errorf("InjectIdentity() = %s, %v; want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestInjectIdentityAnonymousStripsReservedField(t *testing.T) {
+	got, ok := InjectIdentity(json.RawMessage(`{"_nimbus":{"identity":{"user_id":"admin"}},"a":1}`), nil)
+	if !ok || string(got) != `{"a":1}` {
+		t.Errorf("InjectIdentity() = %s, %v; want reserved field removed", got, ok)
+	}
+}
+
+func TestInjectIdentityNonObjectInput(t *testing.T) {
+	input := json.RawMessage(`[1,2,3]`)
+	got, ok := InjectIdentity(input, &Identity{UserID: "user-1"})
+	if ok || string(got) != string(input) {
+		t.Errorf("InjectIdentity() = %s, %v; want input unchanged", got, ok)
+	}
+}
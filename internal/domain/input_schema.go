@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// inputSchemaURL 编译输入 schema 时使用的资源地址，只用于错误信息中的定位
+const inputSchemaURL = "input_schema.json"
+
+// CompileInputSchema 编译函数的输入 JSON Schema（未声明 $schema 时按 draft 2020-12）。
+// 不允许通过 $ref 引用外部文件或 URL，schema 必须自包含。
+func CompileInputSchema(schema json.RawMessage) (*jsonschema.Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("external reference %q is not allowed", url)
+	}
+	if err := c.AddResource(inputSchemaURL, bytes.NewReader(schema)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInputSchema, err)
+	}
+	compiled, err := c.Compile(inputSchemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInputSchema, err)
+	}
+	return compiled, nil
+}
+
+// ValidateInputSchema 校验输入 schema 能否编译，空值和 null 表示不校验输入
+func ValidateInputSchema(schema json.RawMessage) error {
+	if !HasInputSchema(schema) {
+		return nil
+	}
+	_, err := CompileInputSchema(schema)
+	return err
+}
+
+// HasInputSchema 判断是否设置了输入 schema
+func HasInputSchema(schema json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(schema)
+	return len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null"))
+}
+
+// InputValidationError 表示调用输入不符合函数的 input_schema
+type InputValidationError struct {
+	// Errors 是逐条的校验错误，格式为 "<输入中的 JSON Pointer>: <原因>"
+	Errors []string
+}
+
+// Error 实现 error 接口
+func (e *InputValidationError) Error() string {
+	return "input does not match input_schema: " + strings.Join(e.Errors, "; ")
+}
+
+// ValidateInput 按已编译的 schema 校验调用输入。
+// 输入不是合法 JSON 或不符合 schema 时返回 *InputValidationError。
+func ValidateInput(schema *jsonschema.Schema, input json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber() // 保留数字精度，避免大整数按 float64 比较
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return &InputValidationError{Errors: []string{"invalid JSON: " + err.Error()}}
+	}
+
+	err := schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return &InputValidationError{Errors: []string{err.Error()}}
+	}
+	result := &InputValidationError{}
+	collectValidationErrors(ve, &result.Errors)
+	return result
+}
+
+// collectValidationErrors 展开嵌套的校验错误，只保留最底层的具体原因
+func collectValidationErrors(ve *jsonschema.ValidationError, out *[]string) {
+	if len(ve.Causes) == 0 {
+		location := ve.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		*out = append(*out, location+": "+ve.Message)
+		return
+	}
+	for _, cause := range ve.Causes {
+		collectValidationErrors(cause, out)
+	}
+}
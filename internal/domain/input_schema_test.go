@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testInputSchema = `{
+	"type": "object",
+	"required": ["name", "count"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"count": {"type": "integer", "maximum": 9007199254740993}
+	}
+}`
+
+func TestValidateInput(t *testing.T) {
+	schema, err := CompileInputSchema(json.RawMessage(testInputSchema))
+	if err != nil {
+		t.Fatalf("CompileInputSchema() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "valid", input: `{"name":"a","count":3}`},
+		{name: "large integer keeps precision", input: `{"name":"a","count":9007199254740993}`},
+		{name: "missing field", input: `{"name":"a"}`, want: []string{"/: missing properties: 'count'"}},
+		{name: "wrong types", input: `{"name":"","count":"3"}`, want: []string{
+			"/name: length must be >= 1, but got 0",
+			"/count: expected integer, but got string",
+		}},
+		{name: "invalid json", input: `{"name":`, want: []string{"invalid JSON: unexpected EOF"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateInput(schema, json.RawMessage(tt.input))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ValidateInput() error = %v, want nil", err)
+				}
+				return
+			}
+			var ve *InputValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("ValidateInput() error = %v, want *InputValidationError", err)
+			}
+			got := map[string]bool{}
+			for _, e := range ve.Errors {
+				got[e] = true
+			}
+			want := map[string]bool{}
+			for _, e := range tt.want {
+				want[e] = true
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ValidateInput() errors = %q, want %q", ve.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateInputSchema(t *testing.T) {
+	for _, schema := range []string{"", "null", " null ", testInputSchema} {
+		if err := ValidateInputSchema(json.RawMessage(schema)); err != nil {
+			t.Errorf("ValidateInputSchema(%q) error = %v", schema, err)
+		}
+	}
+
+	for _, schema := range []string{
+		`{"type": 12}`,
+		`{"type": "object"`,
+		`{"$ref": "https://example.com/schema.json"}`,
+		`{"$ref": "file:///etc/passwd"}`,
+	} {
+		if err := ValidateInputSchema(json.RawMessage(schema)); !errors.Is(err, ErrInvalidInputSchema) {
+			t.Errorf("ValidateInputSchema(%q) error = %v, want ErrInvalidInputSchema", schema, err)
+		}
+	}
+}
@@ -2,7 +2,10 @@
 package domain
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"strings"
 	"time"
 )
 
@@ -60,6 +63,8 @@ type Invocation struct {
 	Error string `json:"error,omitempty"`
 	// ColdStart 表示本次调用是否为冷启动（需要启动新的虚拟机）
 	ColdStart bool `json:"cold_start"`
+	// CacheHit 表示本次调用直接返回了结果缓存中的输出，没有实际执行函数
+	CacheHit bool `json:"cache_hit"`
 	// VMID 是执行本次调用的虚拟机 ID
 	VMID string `json:"vm_id,omitempty"`
 	// Version 是实际执行的函数版本号
@@ -68,6 +73,14 @@ type Invocation struct {
 	AliasUsed string `json:"alias_used,omitempty"`
 	// SessionKey 是会话标识（用于有状态函数）
 	SessionKey string `json:"session_key,omitempty"`
+	// CallerID 是发起调用的已认证用户 ID（如果有）
+	CallerID string `json:"caller_id,omitempty"`
+	// CallerRole 是发起调用的已认证用户角色（如果有）
+	CallerRole string `json:"caller_role,omitempty"`
+	// ReplayOf 是被重放的原始调用 ID，仅重放产生的调用记录有值
+	ReplayOf string `json:"replay_of,omitempty"`
+	// CallChain 是发起本次调用的函数 ID，从最上游函数到直接调用方，仅函数间调用有值
+	CallChain []string `json:"call_chain,omitempty"`
 	// StartedAt 是调用开始执行的时间
 	StartedAt *time.Time `json:"started_at,omitempty"`
 	// CompletedAt 是调用执行完成的时间
@@ -80,6 +93,87 @@ type Invocation struct {
 	MemoryUsedMB int `json:"memory_used_mb"`
 	// RetryCount 是调用的重试次数
 	RetryCount int `json:"retry_count"`
+	// DecisionTrace 是调用路径上的决策记录（版本解析、节点、环境变量、虚拟机选择等）
+	DecisionTrace []DecisionStep `json:"decision_trace,omitempty"`
+	// CreatedAt 是调用记录的创建时间
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InvocationFilter 用于调用记录列表的筛选条件，零值字段不参与筛选
+type InvocationFilter struct {
+	// Status 调用状态（精确匹配）
+	Status InvocationStatus `json:"status,omitempty"`
+	// TriggerType 触发类型（精确匹配）
+	TriggerType TriggerType `json:"trigger_type,omitempty"`
+	// After 只返回创建时间不早于该时间的记录
+	After *time.Time `json:"after,omitempty"`
+	// Before 只返回创建时间早于该时间的记录
+	Before *time.Time `json:"before,omitempty"`
+}
+
+// Matches 判断调用记录是否满足筛选条件
+func (f *InvocationFilter) Matches(inv *Invocation) bool {
+	if f.Status != "" && inv.Status != f.Status {
+		return false
+	}
+	if f.TriggerType != "" && inv.TriggerType != f.TriggerType {
+		return false
+	}
+	if f.After != nil && inv.CreatedAt.Before(*f.After) {
+		return false
+	}
+	if f.Before != nil && !inv.CreatedAt.Before(*f.Before) {
+		return false
+	}
+	return true
+}
+
+// 决策记录的阶段
+const (
+	// DecisionStageVersion 版本解析：显式版本、别名（含灰度权重路由）或函数当前版本
+	DecisionStageVersion = "version"
+	// DecisionStageNode 节点选择：节点选择器匹配情况
+	DecisionStageNode = "node"
+	// DecisionStageEnvironment 环境变量合并：所在环境及合并结果
+	DecisionStageEnvironment = "environment"
+	// DecisionStageVM 运行环境选择：复用预热虚拟机或冷启动
+	DecisionStageVM = "vm"
+	// DecisionStageIdentity 调用方身份传递：注入、未认证或输入不支持注入
+	DecisionStageIdentity = "identity"
+	// DecisionStageCache 结果缓存：命中时直接返回缓存的输出
+	DecisionStageCache = "cache"
+)
+
+// DecisionStep 表示调用路径上的一次决策。
+type DecisionStep struct {
+	// Stage 是决策所属阶段，如 version、node、environment、vm、identity
+	Stage string `json:"stage"`
+	// Decision 是决策结果，如 alias、warm_vm、cold_boot
+	Decision string `json:"decision"`
+	// Details 是决策的补充信息，如解析得到的版本号、虚拟机 ID
+	Details map[string]interface{} `json:"details,omitempty"`
+	// At 是做出决策的时间
+	At time.Time `json:"at"`
+}
+
+// InvocationExplain 表示一次调用的执行路径说明，用于排查路由和延迟差异。
+type InvocationExplain struct {
+	// InvocationID 是调用记录 ID
+	InvocationID string `json:"invocation_id"`
+	// FunctionID 是被调用函数的 ID
+	FunctionID string `json:"function_id"`
+	// FunctionName 是被调用函数的名称
+	FunctionName string `json:"function_name"`
+	// Status 是调用状态
+	Status InvocationStatus `json:"status"`
+	// ColdStart 表示是否为冷启动
+	ColdStart bool `json:"cold_start"`
+	// VMID 是执行本次调用的虚拟机 ID
+	VMID string `json:"vm_id,omitempty"`
+	// DurationMs 是执行时长（单位：毫秒）
+	DurationMs int64 `json:"duration_ms"`
+	// DecisionTrace 是按时间顺序排列的决策记录
+	DecisionTrace []DecisionStep `json:"decision_trace"`
 	// CreatedAt 是调用记录的创建时间
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -108,6 +202,15 @@ func NewInvocation(functionID, functionName string, triggerType TriggerType, inp
 	}
 }
 
+// SetCaller 记录发起调用的已认证身份，identity 为 nil 时不做任何修改
+func (i *Invocation) SetCaller(identity *Identity) {
+	if identity == nil {
+		return
+	}
+	i.CallerID = identity.UserID
+	i.CallerRole = identity.Role
+}
+
 // Start 标记调用开始执行。
 // 将状态更新为 running，并记录执行的虚拟机信息和冷启动状态。
 //
@@ -122,6 +225,21 @@ func (i *Invocation) Start(vmID string, coldStart bool) {
 	i.StartedAt = &now
 }
 
+// RecordDecision 追加一条调用路径上的决策记录。
+//
+// 参数:
+//   - stage: 决策阶段，见 DecisionStage* 常量
+//   - decision: 决策结果
+//   - details: 补充信息，可为 nil
+func (i *Invocation) RecordDecision(stage, decision string, details map[string]interface{}) {
+	i.DecisionTrace = append(i.DecisionTrace, DecisionStep{
+		Stage:    stage,
+		Decision: decision,
+		Details:  details,
+		At:       time.Now(),
+	})
+}
+
 // Complete 标记调用执行成功完成。
 // 将状态更新为 success，记录输出结果和内存使用情况，并计算执行时长和计费时长。
 //
@@ -140,6 +258,23 @@ func (i *Invocation) Complete(output json.RawMessage, memoryUsedMB int) {
 	i.calculateBilledTime()
 }
 
+// CompleteFromCache 标记调用由结果缓存直接完成。
+// 函数没有实际执行，因此不是冷启动，执行时长和计费时长均为 0。
+//
+// 参数:
+//   - output: 缓存的输出结果
+func (i *Invocation) CompleteFromCache(output json.RawMessage) {
+	now := time.Now()
+	i.Status = InvocationStatusSuccess
+	i.Output = output
+	i.CacheHit = true
+	i.ColdStart = false
+	i.StartedAt = &now
+	i.CompletedAt = &now
+	i.DurationMs = 0
+	i.BilledTimeMs = 0
+}
+
 // Fail 标记调用执行失败。
 // 将状态更新为 failed，记录错误信息，并计算执行时长和计费时长。
 //
@@ -169,6 +304,19 @@ func (i *Invocation) Timeout() {
 	i.calculateBilledTime()
 }
 
+// Cancel 标记调用被取消。
+// 将状态更新为 cancelled，记录取消信息，并计算执行时长和计费时长。
+func (i *Invocation) Cancel() {
+	now := time.Now()
+	i.Status = InvocationStatusCancelled
+	i.Error = "invocation cancelled"
+	i.CompletedAt = &now
+	if i.StartedAt != nil {
+		i.DurationMs = now.Sub(*i.StartedAt).Milliseconds()
+	}
+	i.calculateBilledTime()
+}
+
 // calculateBilledTime 计算计费时长。
 //
 // 计费规则说明：
@@ -194,17 +342,95 @@ func (i *Invocation) calculateBilledTime() {
 	i.BilledTimeMs = billedMs
 }
 
+// ActiveInvocation 表示一次正在执行中的函数调用。
+// 由调度器的内存调用跟踪器维护，用于展示"当前正在运行什么"以及发现挂起的调用。
+type ActiveInvocation struct {
+	// ID 是调用记录的唯一标识符
+	ID string `json:"id"`
+	// FunctionID 是被调用函数的 ID
+	FunctionID string `json:"function_id"`
+	// FunctionName 是被调用函数的名称
+	FunctionName string `json:"function_name"`
+	// VMID 是执行本次调用的虚拟机（或容器）ID
+	VMID string `json:"vm_id,omitempty"`
+	// StartedAt 是调用开始执行的时间
+	StartedAt time.Time `json:"started_at"`
+	// RunningMs 是调用已运行的时长（单位：毫秒）
+	RunningMs int64 `json:"running_ms"`
+	// TimeoutSec 是函数配置的超时时间（单位：秒）
+	TimeoutSec int `json:"timeout_sec"`
+	// NearTimeout 表示调用已运行超过超时时间的 80%，可能即将超时或已挂起
+	NearTimeout bool `json:"near_timeout"`
+}
+
+// InvocationCursor 是调用记录键集分页的游标，由上一页最后一条记录的创建时间和 ID 组成。
+// 调用记录按 (created_at, id) 倒序排列，ID 保证创建时间相同的记录跨页时不会被跳过或重复。
+type InvocationCursor struct {
+	// CreatedAt 是上一页最后一条记录的创建时间
+	CreatedAt time.Time
+	// ID 是上一页最后一条记录的 ID
+	ID string
+}
+
+// IsZero 判断游标是否为空，空游标表示从最新的记录开始
+func (c InvocationCursor) IsZero() bool {
+	return c.CreatedAt.IsZero() && c.ID == ""
+}
+
+// String 将游标编码为不透明的字符串，空游标返回空字符串
+func (c InvocationCursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + c.ID))
+}
+
+// ParseInvocationCursor 解析 String 编码的游标，空字符串返回空游标
+func ParseInvocationCursor(s string) (InvocationCursor, error) {
+	if s == "" {
+		return InvocationCursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return InvocationCursor{}, errors.New("invalid invocation cursor")
+	}
+	ts, id, ok := strings.Cut(string(raw), ",")
+	if !ok || id == "" {
+		return InvocationCursor{}, errors.New("invalid invocation cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return InvocationCursor{}, errors.New("invalid invocation cursor")
+	}
+	return InvocationCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// NextInvocationCursor 返回下一页的游标：本页满页时为最后一条记录的 (created_at, id)，否则为空游标
+func NextInvocationCursor(invocations []*Invocation, limit int) InvocationCursor {
+	if len(invocations) == 0 || len(invocations) < limit {
+		return InvocationCursor{}
+	}
+	last := invocations[len(invocations)-1]
+	return InvocationCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+}
+
 // InvocationRepository 定义了调用记录存储的接口。
-// 该接口抽象了调用记录的持久化操作。
+// 该接口抽象了调用记录的持久化操作，由 storage.PostgresStore 和测试用的 storagetest.MemoryStore 实现。
 type InvocationRepository interface {
-	// Create 创建一条新的调用记录
-	Create(inv *Invocation) error
-	// GetByID 根据 ID 获取调用记录
-	GetByID(id string) (*Invocation, error)
-	// ListByFunction 根据函数 ID 分页获取调用记录列表，返回调用列表、总数和可能的错误
-	ListByFunction(functionID string, offset, limit int) ([]*Invocation, int, error)
-	// Update 更新调用记录
-	Update(inv *Invocation) error
+	// CreateInvocation 创建调用记录，未提供 ID 时自动生成
+	CreateInvocation(inv *Invocation) error
+	// GetInvocationByID 根据 ID 获取调用记录，不存在时返回 ErrInvocationNotFound
+	GetInvocationByID(id string) (*Invocation, error)
+	// ListInvocationsByFunction 分页获取函数的调用记录（按创建时间倒序），返回记录列表和总数
+	ListInvocationsByFunction(functionID string, offset, limit int) ([]*Invocation, int, error)
+	// ListInvocationsByFunctionCursor 按游标获取函数的调用记录（按创建时间和 ID 倒序），返回记录列表和下一页游标
+	ListInvocationsByFunctionCursor(functionID string, before InvocationCursor, limit int) ([]*Invocation, InvocationCursor, error)
+	// ListAllInvocations 分页获取所有调用记录，status 为空时不过滤
+	ListAllInvocations(status string, offset, limit int) ([]*Invocation, int, error)
+	// ListAllInvocationsWithFilter 按状态、触发类型和创建时间范围分页获取所有调用记录，filter 为 nil 时不过滤
+	ListAllInvocationsWithFilter(filter *InvocationFilter, offset, limit int) ([]*Invocation, int, error)
+	// UpdateInvocation 更新调用记录，不存在时返回 ErrInvocationNotFound
+	UpdateInvocation(inv *Invocation) error
 }
 
 // InvocationStats 表示函数调用的统计信息。
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvocationCursorRoundTrip(t *testing.T) {
+	cursor := InvocationCursor{CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.UTC), ID: "inv-1"}
+	parsed, err := ParseInvocationCursor(cursor.String())
+	if err != nil {
+		t.Fatalf("ParseInvocationCursor: %v", err)
+	}
+	if parsed.ID != cursor.ID || !parsed.CreatedAt.Equal(cursor.CreatedAt) {
+		t.Errorf("parsed = %+v, want %+v", parsed, cursor)
+	}
+
+	// 空字符串是空游标
+	if parsed, err := ParseInvocationCursor(""); err != nil || !parsed.IsZero() || (InvocationCursor{}).String() != "" {
+		t.Errorf("empty cursor = %+v, %v", parsed, err)
+	}
+	// 旧版本的时间戳游标和缺少 ID 的游标无效
+	for _, bad := range []string{"2026-01-02T03:04:05Z", "!!", "MjAyNi0wMS0wMlQwMzowNDowNVo"} {
+		if _, err := ParseInvocationCursor(bad); err == nil {
+			t.Errorf("ParseInvocationCursor(%q) accepted", bad)
+		}
+	}
+}
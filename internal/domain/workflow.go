@@ -34,6 +34,8 @@ const (
 	ExecutionStatusTimeout ExecutionStatus = "timeout"
 	// ExecutionStatusPaused 执行已暂停（断点）
 	ExecutionStatusPaused ExecutionStatus = "paused"
+	// ExecutionStatusWaiting 执行在 Wait 状态等待定时唤醒
+	ExecutionStatusWaiting ExecutionStatus = "waiting"
 	// ExecutionStatusCancelled 执行被取消
 	ExecutionStatusCancelled ExecutionStatus = "cancelled"
 )
@@ -68,6 +70,8 @@ const (
 	StateTypeWait StateType = "Wait"
 	// StateTypeParallel 并行状态，并行执行多个分支
 	StateTypeParallel StateType = "Parallel"
+	// StateTypeMap 映射状态，对数组中的每个元素执行同一个迭代分支
+	StateTypeMap StateType = "Map"
 	// StateTypePass 透传状态，透传输入到输出
 	StateTypePass StateType = "Pass"
 	// StateTypeFail 失败状态，以失败终止执行
@@ -146,6 +150,18 @@ type State struct {
 	// Branches 并行分支列表
 	Branches []Branch `json:"branches,omitempty"`
 
+	// ===== Map 状态字段 =====
+	// ItemsPath 要遍历的数组在输入中的路径（JSONPath），为空时输入本身必须是数组
+	ItemsPath string `json:"items_path,omitempty"`
+	// Iterator 对每个元素执行的迭代分支，元素作为分支输入
+	Iterator *Branch `json:"iterator,omitempty"`
+	// MaxConcurrency 同时执行的迭代数上限，0 表示使用默认值 10
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// ToleratedFailureCount 允许失败的迭代数，失败数不超过该值时 Map 状态仍然成功
+	ToleratedFailureCount int `json:"tolerated_failure_count,omitempty"`
+	// ToleratedFailurePercentage 允许失败的迭代百分比（0-100），与 ToleratedFailureCount 满足其一即可
+	ToleratedFailurePercentage float64 `json:"tolerated_failure_percentage,omitempty"`
+
 	// ===== Pass/Fail 状态字段 =====
 	// Result 传递的结果值
 	Result json.RawMessage `json:"result,omitempty"`
@@ -48,6 +48,10 @@ type WorkflowExecution struct {
 	PausedInput json.RawMessage `json:"paused_input,omitempty"`
 	// PausedAt 暂停时间
 	PausedAt *time.Time `json:"paused_at,omitempty"`
+	// WaitUntil Wait 状态的唤醒时间，执行处于 waiting 状态时有效
+	WaitUntil *time.Time `json:"wait_until,omitempty"`
+	// WaitInput 进入 Wait 状态时的输入数据，唤醒后作为该状态的输入
+	WaitInput json.RawMessage `json:"wait_input,omitempty"`
 }
 
 // IsTerminal 检查执行是否已终止
@@ -102,6 +106,51 @@ func (s *StateExecution) IsTerminal() bool {
 	}
 }
 
+// ==================== 延迟分解定义 ====================
+
+// StateLatency 单个状态的延迟分解。
+// 状态墙钟耗时 = 函数执行耗时 + 编排开销（排队、冷启动、状态转换等）。
+type StateLatency struct {
+	// StateName 状态名称
+	StateName string `json:"state_name"`
+	// StateType 状态类型
+	StateType StateType `json:"state_type"`
+	// Status 状态执行状态
+	Status StateExecutionStatus `json:"status"`
+	// InvocationID 关联的函数调用 ID，非 Task 状态为空
+	InvocationID string `json:"invocation_id,omitempty"`
+	// WallTimeMs 状态从开始到完成的墙钟耗时（毫秒），未完成时为 0
+	WallTimeMs int64 `json:"wall_time_ms"`
+	// FunctionMs 函数执行耗时（毫秒），取自调用记录的 duration_ms
+	FunctionMs int64 `json:"function_ms"`
+	// OverheadMs 编排开销（毫秒），即墙钟耗时减去函数执行耗时
+	OverheadMs int64 `json:"overhead_ms"`
+	// QueueMs 调用从创建到开始执行的排队耗时（毫秒）
+	QueueMs int64 `json:"queue_ms"`
+	// ColdStart 函数调用是否冷启动
+	ColdStart bool `json:"cold_start"`
+}
+
+// WorkflowLatencyBreakdown 工作流执行的端到端延迟分解，
+// 用于判断工作流变慢是函数本身还是编排层导致的。
+type WorkflowLatencyBreakdown struct {
+	// ExecutionID 执行 ID
+	ExecutionID string `json:"execution_id"`
+	// TotalMs 执行从开始到完成的墙钟耗时（毫秒），未完成时为 0
+	TotalMs int64 `json:"total_ms"`
+	// FunctionMs 所有状态的函数执行耗时之和（毫秒）
+	FunctionMs int64 `json:"function_ms"`
+	// OverheadMs 所有状态的编排开销之和（毫秒）
+	OverheadMs int64 `json:"overhead_ms"`
+	// TransitionMs 状态之外的耗时（毫秒），即执行墙钟耗时减去各状态墙钟耗时之和；
+	// 存在并行状态时各状态耗时会重叠，此值按 0 计
+	TransitionMs int64 `json:"transition_ms"`
+	// ColdStarts 冷启动的函数调用次数
+	ColdStarts int `json:"cold_starts"`
+	// States 按执行顺序排列的各状态延迟分解
+	States []StateLatency `json:"states"`
+}
+
 // ==================== 执行结果定义 ====================
 
 // StateResult 状态执行结果
@@ -209,6 +258,8 @@ const (
 	ErrorTypeParameterPathFailure = "States.ParameterPathFailure"
 	// ErrorTypeBranchFailed 分支失败
 	ErrorTypeBranchFailed = "States.BranchFailed"
+	// ErrorTypeExceedToleratedFailureThreshold Map 状态失败的迭代超过了容忍阈值
+	ErrorTypeExceedToleratedFailureThreshold = "States.ExceedToleratedFailureThreshold"
 	// ErrorTypeNoChoiceMatched 没有匹配的 Choice 条件
 	ErrorTypeNoChoiceMatched = "States.NoChoiceMatched"
 	// ErrorTypeIntrinsicFailure 内置函数失败
@@ -0,0 +1,300 @@
+// Package events 提供平台事件总线与触发器管理。
+// 本文件实现 Kafka 事件触发器：以消费组方式订阅配置的主题，每条消息同步调用主题映射的函数。
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Kafka 触发器的重试参数
+const (
+	// kafkaRetryBaseDelay 是拉取消息或写入死信失败后的默认初始等待时间
+	kafkaRetryBaseDelay = time.Second
+	// kafkaRetryMaxDelay 是失败重试的最大等待时间
+	kafkaRetryMaxDelay = 30 * time.Second
+)
+
+// KafkaConsumer 定义触发器使用的 Kafka 消费能力，由 *kafka.Reader 实现。
+// FetchMessage 不会自动提交偏移量，消息处理完成后由触发器调用 CommitMessages 提交。
+type KafkaConsumer interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaInvokeFunc 同步调用函数，签名与调度器的 Invoke 方法一致
+type KafkaInvokeFunc func(req *domain.InvokeRequest) (*domain.InvokeResponse, error)
+
+// DLQWriter 定义写入死信消息的存储能力，由 PostgresStore 实现
+type DLQWriter interface {
+	CreateDLQMessage(msg *domain.DeadLetterMessage) error
+}
+
+// KafkaTrigger 消费 Kafka 主题并触发函数执行。
+// 提供至少一次（at-least-once）语义：消息只有在函数调用成功、或调用失败后已写入死信队列时才提交偏移量；
+// 死信写入失败时按退避时间重试同一条消息，不会跳过。网关崩溃时未提交的消息会被重新消费，
+// 函数需要能容忍重复消息。
+type KafkaTrigger struct {
+	consumer KafkaConsumer
+	routes   map[string]string // topic -> 函数 ID
+	invoke   KafkaInvokeFunc
+	dlq      DLQWriter
+	logger   *logrus.Logger
+
+	retryDelay time.Duration // 失败重试的初始等待时间
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// kafkaEvent 是传给函数的事件载荷
+type kafkaEvent struct {
+	Source    string            `json:"source"`
+	Topic     string            `json:"topic"`
+	Partition int               `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Key       string            `json:"key,omitempty"`
+	Value     json.RawMessage   `json:"value"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// NewKafkaTrigger 根据配置创建 Kafka 事件触发器。
+//
+// 参数:
+//   - cfg: Kafka 配置，需包含 Brokers 和至少一个主题映射
+//   - invoke: 同步调用函数的方法，通常为调度器的 Invoke
+//   - dlq: 死信存储
+//   - logger: 日志记录器
+func NewKafkaTrigger(cfg config.KafkaConfig, invoke KafkaInvokeFunc, dlq DLQWriter, logger *logrus.Logger) (*KafkaTrigger, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("kafka brokers not configured")
+	}
+	routes, err := kafkaRoutes(cfg.Topics)
+	if err != nil {
+		return nil, err
+	}
+	topics := make([]string, 0, len(routes))
+	for topic := range routes {
+		topics = append(topics, topic)
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     cfg.Brokers,
+		GroupID:     cfg.GroupID,
+		GroupTopics: topics,
+		// 新消费组从最早的消息开始消费，避免启用触发器前写入的消息被跳过
+		StartOffset: kafka.FirstOffset,
+		// CommitInterval 为 0 时 CommitMessages 同步提交，提交返回即表示偏移量已持久化
+		CommitInterval: 0,
+	})
+	return newKafkaTrigger(reader, routes, invoke, dlq, logger), nil
+}
+
+// newKafkaTrigger 使用指定的消费者创建触发器
+func newKafkaTrigger(consumer KafkaConsumer, routes map[string]string, invoke KafkaInvokeFunc, dlq DLQWriter, logger *logrus.Logger) *KafkaTrigger {
+	return &KafkaTrigger{
+		consumer: consumer,
+		routes:   routes,
+		invoke:   invoke,
+		dlq:      dlq,
+		logger:   logger,
+
+		retryDelay: kafkaRetryBaseDelay,
+	}
+}
+
+// kafkaRoutes 校验主题映射并转换为 topic -> 函数 ID 的索引
+func kafkaRoutes(topics []config.KafkaTopicConfig) (map[string]string, error) {
+	if len(topics) == 0 {
+		return nil, errors.New("no kafka topics configured")
+	}
+	routes := make(map[string]string, len(topics))
+	for _, t := range topics {
+		if t.Topic == "" || t.FunctionID == "" {
+			return nil, fmt.Errorf("kafka topic mapping requires topic and function_id: %+v", t)
+		}
+		if existing, ok := routes[t.Topic]; ok && existing != t.FunctionID {
+			return nil, fmt.Errorf("kafka topic %q mapped to multiple functions", t.Topic)
+		}
+		routes[t.Topic] = t.FunctionID
+	}
+	return routes, nil
+}
+
+// Start 启动消费循环
+func (t *KafkaTrigger) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.run(ctx)
+	}()
+	t.logger.WithField("topics", len(t.routes)).Info("Kafka trigger started")
+}
+
+// Stop 停止消费循环，等待正在处理的消息完成后关闭消费者
+func (t *KafkaTrigger) Stop() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.wg.Wait()
+	if err := t.consumer.Close(); err != nil {
+		t.logger.WithError(err).Warn("Failed to close Kafka consumer")
+	}
+}
+
+// run 逐条拉取、处理并提交消息，直到 ctx 被取消
+func (t *KafkaTrigger) run(ctx context.Context) {
+	delay := t.retryDelay
+	for {
+		msg, err := t.consumer.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			t.logger.WithError(err).Warn("Failed to fetch Kafka message")
+			if !sleepContext(ctx, delay) {
+				return
+			}
+			delay = nextKafkaRetryDelay(delay)
+			continue
+		}
+		delay = t.retryDelay
+
+		if !t.process(ctx, msg) {
+			return
+		}
+		if err := t.consumer.CommitMessages(ctx, msg); err != nil {
+			// 提交失败时消息会在重新平衡或重启后再次投递，符合至少一次语义
+			t.logger.WithError(err).WithFields(kafkaMessageFields(msg)).Warn("Failed to commit Kafka offset")
+		}
+	}
+}
+
+// process 处理一条消息直到可以提交偏移量，ctx 被取消时返回 false（不提交）
+func (t *KafkaTrigger) process(ctx context.Context, msg kafka.Message) bool {
+	delay := t.retryDelay
+	for {
+		err := t.handle(ctx, msg)
+		if err == nil {
+			return true
+		}
+		t.logger.WithError(err).WithFields(kafkaMessageFields(msg)).Error("Failed to handle Kafka message, retrying")
+		if !sleepContext(ctx, delay) {
+			return false
+		}
+		delay = nextKafkaRetryDelay(delay)
+	}
+}
+
+// handle 调用主题映射的函数，调用失败时写入死信队列。
+// 返回 nil 表示消息已处理完毕（成功或已进入死信队列），可以提交偏移量。
+func (t *KafkaTrigger) handle(ctx context.Context, msg kafka.Message) error {
+	functionID, ok := t.routes[msg.Topic]
+	if !ok {
+		// 消费组只订阅已配置的主题，正常不会发生；提交以免阻塞分区
+		t.logger.WithFields(kafkaMessageFields(msg)).Warn("No function mapped for Kafka topic, skipping message")
+		return nil
+	}
+
+	payload, err := json.Marshal(newKafkaEvent(msg))
+	if err != nil {
+		return fmt.Errorf("failed to encode kafka event: %w", err)
+	}
+
+	resp, err := t.invoke(&domain.InvokeRequest{
+		FunctionID: functionID,
+		Payload:    payload,
+		Context:    ctx,
+		Trigger:    domain.TriggerEvent,
+	})
+	var invokeErr string
+	switch {
+	case err != nil:
+		invokeErr = err.Error()
+	case resp.Error != "":
+		invokeErr = resp.Error
+	default:
+		return nil
+	}
+
+	dlqMsg := &domain.DeadLetterMessage{
+		FunctionID: functionID,
+		Payload:    payload,
+		Error:      invokeErr,
+		Status:     domain.DLQStatusPending,
+	}
+	if resp != nil {
+		dlqMsg.OriginalRequestID = resp.RequestID
+	}
+	if err := t.dlq.CreateDLQMessage(dlqMsg); err != nil {
+		return fmt.Errorf("failed to create DLQ message: %w", err)
+	}
+	t.logger.WithFields(kafkaMessageFields(msg)).WithField("error", invokeErr).Warn("Kafka-triggered invocation failed, message moved to DLQ")
+	return nil
+}
+
+// newKafkaEvent 构造传给函数的事件载荷，消息值是合法 JSON 时原样嵌入，否则作为字符串
+func newKafkaEvent(msg kafka.Message) *kafkaEvent {
+	value := json.RawMessage(msg.Value)
+	if len(msg.Value) == 0 || !json.Valid(msg.Value) {
+		value, _ = json.Marshal(string(msg.Value))
+	}
+	event := &kafkaEvent{
+		Source:    "kafka",
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       string(msg.Key),
+		Value:     value,
+		Timestamp: msg.Time,
+	}
+	if len(msg.Headers) > 0 {
+		event.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			event.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return event
+}
+
+// kafkaMessageFields 返回用于日志的消息定位字段
+func kafkaMessageFields(msg kafka.Message) logrus.Fields {
+	return logrus.Fields{
+		"topic":     msg.Topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+	}
+}
+
+// nextKafkaRetryDelay 将等待时间翻倍，不超过 kafkaRetryMaxDelay
+func nextKafkaRetryDelay(d time.Duration) time.Duration {
+	d *= 2
+	if d > kafkaRetryMaxDelay {
+		return kafkaRetryMaxDelay
+	}
+	return d
+}
+
+// sleepContext 等待 d 或 ctx 被取消，被取消时返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,226 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeConsumer 按顺序返回预置消息，消息取完后阻塞直到 ctx 取消
+type fakeConsumer struct {
+	mu        sync.Mutex
+	messages  []kafka.Message
+	committed []int64
+	drained   chan struct{}
+	closed    bool
+}
+
+func newFakeConsumer(msgs ...kafka.Message) *fakeConsumer {
+	return &fakeConsumer{messages: msgs, drained: make(chan struct{})}
+}
+
+func (c *fakeConsumer) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	c.mu.Lock()
+	if len(c.messages) > 0 {
+		msg := c.messages[0]
+		c.messages = c.messages[1:]
+		c.mu.Unlock()
+		return msg, nil
+	}
+	c.mu.Unlock()
+	select {
+	case <-c.drained:
+	default:
+		close(c.drained)
+	}
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (c *fakeConsumer) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range msgs {
+		c.committed = append(c.committed, m.Offset)
+	}
+	return nil
+}
+
+func (c *fakeConsumer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// fakeDLQ 记录写入的死信消息，failures 次之前的写入返回错误
+type fakeDLQ struct {
+	mu       sync.Mutex
+	failures int
+	messages []*domain.DeadLetterMessage
+}
+
+func (d *fakeDLQ) CreateDLQMessage(msg *domain.DeadLetterMessage) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("database unavailable")
+	}
+	d.messages = append(d.messages, msg)
+	return nil
+}
+
+func quietLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// runTrigger 启动触发器，等待消费者取完所有消息后停止
+func runTrigger(t *testing.T, trigger *KafkaTrigger, consumer *fakeConsumer) {
+	t.Helper()
+	trigger.retryDelay = time.Millisecond
+	trigger.Start()
+	select {
+	case <-consumer.drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("trigger did not consume all messages")
+	}
+	trigger.Stop()
+}
+
+func TestKafkaTriggerInvokesMappedFunction(t *testing.T) {
+	consumer := newFakeConsumer(
+		kafka.Message{Topic: "orders", Partition: 0, Offset: 7, Key: []byte("k1"), Value: []byte(`{"id":1}`)},
+		kafka.Message{Topic: "clicks", Partition: 1, Offset: 3, Value: []byte("plain text")},
+	)
+	var mu sync.Mutex
+	var reqs []*domain.InvokeRequest
+	invoke := func(req *domain.InvokeRequest) (*domain.InvokeResponse, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reqs = append(reqs, req)
+		return &domain.InvokeResponse{RequestID: "req", StatusCode: 200}, nil
+	}
+	dlq := &fakeDLQ{}
+	trigger := newKafkaTrigger(consumer, map[string]string{"orders": "fn-orders", "clicks": "fn-clicks"}, invoke, dlq, quietLogger())
+	runTrigger(t, trigger, consumer)
+
+	if len(reqs) != 2 {
+		t.Fatalf("invocations = %d, want 2", len(reqs))
+	}
+	if reqs[0].FunctionID != "fn-orders" || reqs[1].FunctionID != "fn-clicks" {
+		t.Errorf("function IDs = %q, %q", reqs[0].FunctionID, reqs[1].FunctionID)
+	}
+	for _, req := range reqs {
+		if req.TriggerType() != domain.TriggerEvent {
+			t.Errorf("trigger type = %q, want %q", req.TriggerType(), domain.TriggerEvent)
+		}
+	}
+
+	var event kafkaEvent
+	if err := json.Unmarshal(reqs[0].Payload, &event); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if event.Source != "kafka" || event.Topic != "orders" || event.Offset != 7 || event.Key != "k1" || string(event.Value) != `{"id":1}` {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if err := json.Unmarshal(reqs[1].Payload, &event); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if string(event.Value) != `"plain text"` {
+		t.Errorf("non-JSON value = %s, want quoted string", event.Value)
+	}
+
+	if len(consumer.committed) != 2 || consumer.committed[0] != 7 || consumer.committed[1] != 3 {
+		t.Errorf("committed offsets = %v, want [7 3]", consumer.committed)
+	}
+	if len(dlq.messages) != 0 {
+		t.Errorf("DLQ messages = %d, want 0", len(dlq.messages))
+	}
+	if !consumer.closed {
+		t.Error("consumer not closed on Stop")
+	}
+}
+
+func TestKafkaTriggerRoutesFailuresToDLQ(t *testing.T) {
+	consumer := newFakeConsumer(
+		kafka.Message{Topic: "orders", Offset: 1, Value: []byte(`{"id":1}`)},
+		kafka.Message{Topic: "orders", Offset: 2, Value: []byte(`{"id":2}`)},
+	)
+	invoke := func(req *domain.InvokeRequest) (*domain.InvokeResponse, error) {
+		var event kafkaEvent
+		json.Unmarshal(req.Payload, &event)
+		if event.Offset == 1 {
+			return nil, errors.New("queue full")
+		}
+		return &domain.InvokeResponse{RequestID: "req-2", StatusCode: 500, Error: "function crashed"}, nil
+	}
+	// 第一次写入死信失败，消息必须重试而不能被提交
+	dlq := &fakeDLQ{failures: 1}
+	trigger := newKafkaTrigger(consumer, map[string]string{"orders": "fn-orders"}, invoke, dlq, quietLogger())
+	runTrigger(t, trigger, consumer)
+
+	if len(dlq.messages) != 2 {
+		t.Fatalf("DLQ messages = %d, want 2", len(dlq.messages))
+	}
+	first, second := dlq.messages[0], dlq.messages[1]
+	if first.FunctionID != "fn-orders" || first.Error != "queue full" || first.Status != domain.DLQStatusPending {
+		t.Errorf("unexpected first DLQ message: %+v", first)
+	}
+	if second.Error != "function crashed" || second.OriginalRequestID != "req-2" {
+		t.Errorf("unexpected second DLQ message: %+v", second)
+	}
+	if len(consumer.committed) != 2 {
+		t.Errorf("committed offsets = %v, want both messages committed after DLQ", consumer.committed)
+	}
+}
+
+func TestKafkaTriggerStopWithoutCommitWhileRetrying(t *testing.T) {
+	consumer := newFakeConsumer(kafka.Message{Topic: "orders", Offset: 5, Value: []byte(`{}`)})
+	invoked := make(chan struct{}, 1)
+	invoke := func(req *domain.InvokeRequest) (*domain.InvokeResponse, error) {
+		select {
+		case invoked <- struct{}{}:
+		default:
+		}
+		return nil, errors.New("function not found")
+	}
+	// 死信存储一直不可用时，停止触发器不能提交该消息
+	dlq := &fakeDLQ{failures: 1 << 30}
+	trigger := newKafkaTrigger(consumer, map[string]string{"orders": "fn-orders"}, invoke, dlq, quietLogger())
+	trigger.retryDelay = time.Millisecond
+	trigger.Start()
+	<-invoked
+	trigger.Stop()
+
+	if len(consumer.committed) != 0 {
+		t.Errorf("committed offsets = %v, want none", consumer.committed)
+	}
+}
+
+func TestKafkaRoutesValidation(t *testing.T) {
+	if _, err := kafkaRoutes(nil); err == nil {
+		t.Error("expected error for empty topics")
+	}
+	if _, err := kafkaRoutes([]config.KafkaTopicConfig{{Topic: "orders"}}); err == nil {
+		t.Error("expected error for missing function_id")
+	}
+	if _, err := kafkaRoutes([]config.KafkaTopicConfig{{Topic: "a", FunctionID: "f1"}, {Topic: "a", FunctionID: "f2"}}); err == nil {
+		t.Error("expected error for conflicting mapping")
+	}
+	routes, err := kafkaRoutes([]config.KafkaTopicConfig{{Topic: "a", FunctionID: "f1"}, {Topic: "b", FunctionID: "f1"}})
+	if err != nil || routes["a"] != "f1" || routes["b"] != "f1" {
+		t.Errorf("routes = %v, err = %v", routes, err)
+	}
+}
@@ -0,0 +1,127 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	"github.com/sirupsen/logrus"
+)
+
+// balloonMinGuestMB 充气后 guest 至少保留的内存（MB），避免 agent 和运行时因内存不足被 OOM
+const balloonMinGuestMB = 64
+
+// ErrBalloonDisabled 表示未启用 balloon 设备
+var ErrBalloonDisabled = errors.New("balloon device is not enabled")
+
+// BalloonEnabled 返回虚拟机是否挂载了 balloon 设备
+func (m *MachineManager) BalloonEnabled() bool {
+	return m.cfg.BalloonEnabled
+}
+
+// IdleBalloonTargetMB 返回虚拟机空闲时 balloon 的目标大小（MB），未启用 balloon 时为 0
+func (m *MachineManager) IdleBalloonTargetMB() int64 {
+	if !m.cfg.BalloonEnabled {
+		return 0
+	}
+	return int64(m.cfg.BalloonIdleTargetMB)
+}
+
+// buildBalloon 构建启动时挂载的 balloon 设备配置。
+// 启动时 balloon 不充气，虚拟机拥有全部内存；开启 deflate_on_oom，
+// guest 内存紧张时自动放气，避免充气导致函数执行 OOM。
+//
+// 返回：
+//   - models.Balloon: balloon 设备配置
+//   - bool: 是否启用 balloon
+func (m *MachineManager) buildBalloon() (models.Balloon, bool) {
+	if !m.cfg.BalloonEnabled {
+		return models.Balloon{}, false
+	}
+	interval := int64(m.cfg.BalloonStatsIntervalSec)
+	if interval < 0 {
+		interval = 0
+	}
+	return models.Balloon{
+		AmountMib:             firecracker.Int64(0),
+		DeflateOnOom:          firecracker.Bool(true),
+		StatsPollingIntervals: interval,
+	}, true
+}
+
+// attachBalloon 在虚拟机启动前挂载 balloon 设备。
+// SDK 的 Config 不包含 balloon，需要在 vsock 设备之后追加创建 balloon 的初始化步骤。
+func (m *MachineManager) attachBalloon(machine *firecracker.Machine) {
+	balloon, ok := m.buildBalloon()
+	if !ok {
+		return
+	}
+	machine.Handlers.FcInit = machine.Handlers.FcInit.AppendAfter(
+		firecracker.AddVsocksHandlerName,
+		firecracker.NewCreateBalloonHandler(*balloon.AmountMib, *balloon.DeflateOnOom, balloon.StatsPollingIntervals),
+	)
+}
+
+// clampBalloonTarget 将 balloon 目标限制在 [0, memoryMB-balloonMinGuestMB] 范围内
+func clampBalloonTarget(targetMB, memoryMB int64) int64 {
+	maxTarget := memoryMB - balloonMinGuestMB
+	if maxTarget < 0 {
+		maxTarget = 0
+	}
+	if targetMB < 0 {
+		return 0
+	}
+	if targetMB > maxTarget {
+		return maxTarget
+	}
+	return targetMB
+}
+
+// SetBalloonTarget 调整虚拟机 balloon 的目标大小。
+// 充气（目标增大）时 guest 把对应内存归还主机，放气（目标减小）时 guest 重新获得内存。
+// 目标会被限制在虚拟机内存减去 guest 保留内存的范围内。
+// 参数：
+//   - ctx: 上下文
+//   - vmID: 虚拟机 ID
+//   - targetMB: balloon 目标大小（MB），0 表示完全放气
+//
+// 返回：
+//   - error: 未启用 balloon、虚拟机不存在或调用 Firecracker API 失败时返回错误
+func (m *MachineManager) SetBalloonTarget(ctx context.Context, vmID string, targetMB int64) error {
+	if !m.cfg.BalloonEnabled {
+		return ErrBalloonDisabled
+	}
+
+	m.mu.RLock()
+	vm, ok := m.vms[vmID]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("vm not found: %s", vmID)
+	}
+
+	target := clampBalloonTarget(targetMB, vm.MemoryMB)
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.machine == nil {
+		return fmt.Errorf("vm not running: %s", vmID)
+	}
+	if target == vm.BalloonMB {
+		return nil
+	}
+	if err := vm.machine.UpdateBalloon(ctx, target); err != nil {
+		return fmt.Errorf("failed to update balloon: %w", err)
+	}
+	vm.BalloonMB = target
+
+	m.logger.WithFields(logrus.Fields{
+		"vm_id":      vmID,
+		"balloon_mb": target,
+	}).Debug("Balloon target updated")
+	return nil
+}
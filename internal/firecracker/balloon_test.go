@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/config"
+)
+
+func TestBuildBalloon(t *testing.T) {
+	m := NewMachineManager(config.FirecrackerConfig{}, nil, testLogger())
+	if _, ok := m.buildBalloon(); ok {
+		t.Error("balloon should not be attached when disabled")
+	}
+
+	m = NewMachineManager(config.FirecrackerConfig{BalloonEnabled: true, BalloonStatsIntervalSec: 5}, nil, testLogger())
+	balloon, ok := m.buildBalloon()
+	if !ok {
+		t.Fatal("balloon should be attached when enabled")
+	}
+	// 启动时不充气，内存紧张时自动放气
+	if *balloon.AmountMib != 0 || !*balloon.DeflateOnOom || balloon.StatsPollingIntervals != 5 {
+		t.Errorf("balloon = amount %d deflate_on_oom %v stats %d", *balloon.AmountMib, *balloon.DeflateOnOom, balloon.StatsPollingIntervals)
+	}
+}
+
+func TestClampBalloonTarget(t *testing.T) {
+	tests := []struct {
+		target, memory, want int64
+	}{
+		{128, 512, 128},
+		{0, 512, 0},
+		{-10, 512, 0},
+		{512, 512, 512 - balloonMinGuestMB},
+		{128, 128, 128 - balloonMinGuestMB},
+		{32, 48, 0},
+	}
+	for _, tt := range tests {
+		if got := clampBalloonTarget(tt.target, tt.memory); got != tt.want {
+			t.Errorf("clampBalloonTarget(%d, %d) = %d, want %d", tt.target, tt.memory, got, tt.want)
+		}
+	}
+}
+
+func TestSetBalloonTargetErrors(t *testing.T) {
+	m := NewMachineManager(config.FirecrackerConfig{}, nil, testLogger())
+	if err := m.SetBalloonTarget(context.Background(), "vm-1", 64); !errors.Is(err, ErrBalloonDisabled) {
+		t.Errorf("disabled: err = %v, want ErrBalloonDisabled", err)
+	}
+
+	m = NewMachineManager(config.FirecrackerConfig{BalloonEnabled: true, BalloonIdleTargetMB: 128}, nil, testLogger())
+	if err := m.SetBalloonTarget(context.Background(), "missing", 64); err == nil {
+		t.Error("expected error for unknown VM")
+	}
+	if got := m.IdleBalloonTargetMB(); got != 128 {
+		t.Errorf("IdleBalloonTargetMB = %d, want 128", got)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// 消息压缩相关常量，与 Agent 保持一致
+const (
+	// EncodingGzip 是 gzip 压缩编码的名称，初始化时通过 InitPayload.AcceptEncoding 声明支持
+	EncodingGzip = "gzip"
+
+	// frameFlagGzip 是长度前缀的最高位，置位表示消息体经过 gzip 压缩
+	frameFlagGzip = uint32(1) << 31
+
+	// CompressionThreshold 是启用压缩后消息体需要达到的最小字节数
+	CompressionThreshold = 64 * 1024
+)
+
+// InitResult 是 Agent 初始化成功响应中 Output 的内容
+type InitResult struct {
+	Encoding string `json:"encoding,omitempty"`  // Agent 确认启用的消息压缩编码，为空表示不压缩
+	WarmupMs int64  `json:"warmup_ms,omitempty"` // Agent 调用预热函数的耗时（毫秒）
+}
+
+// gzipFrame 压缩消息体，压缩后没有变小时返回 ok=false
+// 先使用默认压缩级别；缺少重复片段的数据（如 JSON 中 base64 编码的 ZIP 层）在默认级别下几乎不会变小，
+// 此时改用只做 Huffman 编码的级别，仍能去掉 base64 编码约四分之一的冗余。
+func gzipFrame(data []byte) ([]byte, bool) {
+	var best []byte
+	for _, level := range []int{gzip.DefaultCompression, gzip.HuffmanOnly} {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, level)
+		if _, err := gz.Write(data); err != nil {
+			return nil, false
+		}
+		if err := gz.Close(); err != nil {
+			return nil, false
+		}
+		if best == nil || buf.Len() < len(best) {
+			best = buf.Bytes()
+		}
+		// 默认级别已节省 10% 以上时不再尝试
+		if len(best) <= len(data)*9/10 {
+			break
+		}
+	}
+	if len(best) >= len(data) {
+		return nil, false
+	}
+	return best, true
+}
+
+// gunzipFrame 解压消息体，解压后超过 MaxMessageBytes 时返回错误
+func gunzipFrame(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip frame: %w", err)
+	}
+	defer gz.Close()
+
+	out, err := io.ReadAll(io.LimitReader(gz, MaxMessageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip frame: %w", err)
+	}
+	if len(out) > MaxMessageBytes {
+		return nil, fmt.Errorf("message too large: decompressed frame exceeds %d", MaxMessageBytes)
+	}
+	return out, nil
+}
@@ -0,0 +1,72 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrDraining 管理器正在排空，不再创建或交付虚拟机
+var ErrDraining = errors.New("machine manager is draining")
+
+// drainPollInterval 排空时检查使用中虚拟机的间隔
+const drainPollInterval = 100 * time.Millisecond
+
+// DrainAndShutdown 优雅关闭虚拟机管理器。
+// 先停止接受新的创建和取用（CreateVM、RestoreVM 和 MarkVMInUse 返回 ErrDraining），
+// 等待使用中的虚拟机执行完调用（MarkVMIdle），再停止全部虚拟机并清理 socket、vsock 文件和网络资源。
+// ctx 到期时不再等待，仍在执行的虚拟机被强制停止。
+// 参数：
+//   - ctx: 上下文，其截止时间即排空的最长等待时间
+//
+// 返回：
+//   - error: 截止时间前仍有虚拟机未空闲时返回包含 ctx 错误的错误
+func (m *MachineManager) DrainAndShutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.draining = true
+	m.mu.Unlock()
+
+	busy := m.busyVMs()
+	if len(busy) > 0 {
+		m.logger.WithField("busy_vms", len(busy)).Info("Draining VMs, waiting for in-flight invocations")
+	}
+
+	var drainErr error
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for len(busy) > 0 && drainErr == nil {
+		select {
+		case <-ctx.Done():
+			m.logger.WithFields(logrus.Fields{
+				"busy_vms": busy,
+			}).Warn("Drain deadline reached, stopping busy VMs")
+			drainErr = fmt.Errorf("%d VMs still busy after drain: %w", len(busy), ctx.Err())
+		case <-ticker.C:
+			busy = m.busyVMs()
+		}
+	}
+
+	// ctx 已到期时 StopVM 跳过优雅关机直接停止 VMM
+	m.Shutdown(ctx)
+	return drainErr
+}
+
+// busyVMs 返回仍在执行调用的虚拟机 ID。
+func (m *MachineManager) busyVMs() []string {
+	var busy []string
+	for _, vm := range m.ListVMs() {
+		vm.mu.Lock()
+		inUse := vm.inUse
+		vm.mu.Unlock()
+		if inUse {
+			busy = append(busy, vm.ID)
+		}
+	}
+	return busy
+}
@@ -0,0 +1,96 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/config"
+)
+
+// newDrainTestManager 返回停止虚拟机时记录 ID 和时间的管理器
+func newDrainTestManager() (*MachineManager, func() map[string]time.Time) {
+	m := NewMachineManager(config.FirecrackerConfig{}, nil, testLogger())
+	var mu sync.Mutex
+	stopped := make(map[string]time.Time)
+	m.stopVM = func(ctx context.Context, vmID string) error {
+		m.mu.Lock()
+		delete(m.vms, vmID)
+		m.mu.Unlock()
+		mu.Lock()
+		stopped[vmID] = time.Now()
+		mu.Unlock()
+		return nil
+	}
+	return m, func() map[string]time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		out := make(map[string]time.Time, len(stopped))
+		for id, at := range stopped {
+			out[id] = at
+		}
+		return out
+	}
+}
+
+func TestDrainWaitsForBusyVM(t *testing.T) {
+	m, stopped := newDrainTestManager()
+	now := time.Now()
+	addTestVM(m, "busy", "python3.11", VMStateRunning, now, 1, true)
+	addTestVM(m, "idle", "python3.11", VMStateRunning, now, 1, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- m.DrainAndShutdown(ctx) }()
+
+	// 排空开始后拒绝取用和创建
+	time.Sleep(3 * drainPollInterval)
+	if err := m.MarkVMInUse("idle"); !errors.Is(err, ErrDraining) {
+		t.Errorf("MarkVMInUse during drain = %v, want ErrDraining", err)
+	}
+	if _, err := m.CreateVM(ctx, "python3.11", 128, 1, RateLimits{}); !errors.Is(err, ErrDraining) {
+		t.Errorf("CreateVM during drain = %v, want ErrDraining", err)
+	}
+	if got := stopped(); len(got) != 0 {
+		t.Fatalf("VMs stopped before busy VM was released: %v", got)
+	}
+
+	released := time.Now()
+	m.MarkVMIdle("busy")
+	if err := <-done; err != nil {
+		t.Fatalf("DrainAndShutdown: %v", err)
+	}
+	got := stopped()
+	if len(got) != 2 {
+		t.Fatalf("stopped = %v, want both VMs", got)
+	}
+	if got["busy"].Before(released) {
+		t.Errorf("busy VM stopped at %v, before release at %v", got["busy"], released)
+	}
+}
+
+func TestDrainStopsBusyVMAtDeadline(t *testing.T) {
+	m, stopped := newDrainTestManager()
+	addTestVM(m, "stuck", "python3.11", VMStateRunning, time.Now(), 1, true)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*drainPollInterval)
+	defer cancel()
+	err := m.DrainAndShutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DrainAndShutdown = %v, want deadline exceeded", err)
+	}
+	at, ok := stopped()["stuck"]
+	if !ok {
+		t.Fatal("busy VM was not stopped at the deadline")
+	}
+	if at.Sub(start) < 3*drainPollInterval {
+		t.Errorf("busy VM stopped after %v, before the deadline", at.Sub(start))
+	}
+}
@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mdlayher/vsock"
+	"github.com/sirupsen/logrus"
+)
+
+// vmPingTimeout 单次健康探测的超时时间
+const vmPingTimeout = 2 * time.Second
+
+// dialAgent 通过 vsock 连接虚拟机内的 agent，不重试。
+// agent 为每个连接启动独立的处理协程，探测连接不影响池中已建立的执行连接。
+func dialAgent(ctx context.Context, cid uint32) (net.Conn, error) {
+	return vsock.Dial(cid, VsockPort, nil)
+}
+
+// PingVM 探测虚拟机内的 agent 是否仍有响应。
+// 新建一条 vsock 连接完成一次 Ping/Pong 往返，超时时间不超过 vmPingTimeout。
+// 探测失败时虚拟机被标记为失败，空闲回收会清理它。
+// 参数：
+//   - ctx: 上下文
+//   - vmID: 虚拟机 ID
+//
+// 返回：
+//   - error: 虚拟机不存在或探测失败时返回错误
+func (m *MachineManager) PingVM(ctx context.Context, vmID string) error {
+	vm, ok := m.GetVM(vmID)
+	if !ok {
+		return fmt.Errorf("vm not found: %s", vmID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vmPingTimeout)
+	defer cancel()
+	if err := m.pingAgent(ctx, vm.VsockCID); err != nil {
+		vm.mu.Lock()
+		vm.State = VMStateFailed
+		vm.mu.Unlock()
+		m.logger.WithFields(logrus.Fields{
+			"vm_id": vmID,
+			"cid":   vm.VsockCID,
+		}).WithError(err).Warn("VM agent health probe failed")
+		return fmt.Errorf("agent of vm %s is unresponsive: %w", vmID, err)
+	}
+	return nil
+}
+
+// pingAgent 建立探测连接并完成一次 Ping/Pong 往返，结束后关闭连接。
+func (m *MachineManager) pingAgent(ctx context.Context, cid uint32) error {
+	conn, err := m.dialVsock(ctx, cid)
+	if err != nil {
+		return fmt.Errorf("failed to connect vsock: %w", err)
+	}
+	client := &VsockClient{cid: cid, conn: conn, logger: m.logger}
+	defer client.Close()
+	return client.Ping(ctx)
+}
@@ -0,0 +1,112 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/config"
+)
+
+// newPingTestManager 返回注册了一个运行中虚拟机的管理器，vsock 连接由 serve 在 net.Pipe 上模拟
+func newPingTestManager(t *testing.T, serve func(guest net.Conn)) *MachineManager {
+	m := NewMachineManager(config.FirecrackerConfig{}, nil, testLogger())
+	m.dialVsock = func(ctx context.Context, cid uint32) (net.Conn, error) {
+		if cid != 100 {
+			t.Errorf("dial cid = %d, want 100", cid)
+		}
+		hostConn, guestConn := net.Pipe()
+		t.Cleanup(func() { guestConn.Close() })
+		go serve(guestConn)
+		return hostConn, nil
+	}
+	m.vms["vm-1"] = &VM{ID: "vm-1", Runtime: "python3.11", State: VMStateRunning, VsockCID: 100}
+	return m
+}
+
+func vmState(m *MachineManager, vmID string) VMState {
+	vm, _ := m.GetVM(vmID)
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.State
+}
+
+func TestPingVMResponsiveAgent(t *testing.T) {
+	m := newPingTestManager(t, func(guest net.Conn) {
+		servePipe(t, guest, MessageTypePong, nil)
+	})
+	if err := m.PingVM(context.Background(), "vm-1"); err != nil {
+		t.Fatalf("PingVM: %v", err)
+	}
+	if state := vmState(m, "vm-1"); state != VMStateRunning {
+		t.Errorf("state = %s, want running", state)
+	}
+}
+
+func TestPingVMUnresponsiveAgent(t *testing.T) {
+	tests := []struct {
+		name  string
+		serve func(guest net.Conn)
+	}{
+		{
+			// 读取请求后不回复
+			name: "timeout",
+			serve: func(guest net.Conn) {
+				agent := &VsockClient{conn: guest, logger: testLogger()}
+				agent.readMessage(context.Background())
+			},
+		},
+		{
+			// 回复一个长度前缀正确但内容不是 JSON 的帧
+			name: "garbage",
+			serve: func(guest net.Conn) {
+				agent := &VsockClient{conn: guest, logger: testLogger()}
+				agent.readMessage(context.Background())
+				body := []byte("not a message")
+				header := make([]byte, 4)
+				binary.BigEndian.PutUint32(header, uint32(len(body)))
+				guest.Write(append(header, body...))
+			},
+		},
+		{
+			name: "wrong type",
+			serve: func(guest net.Conn) {
+				servePipe(t, guest, MessageTypeResp, nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newPingTestManager(t, tt.serve)
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			if err := m.PingVM(ctx, "vm-1"); err == nil {
+				t.Fatal("PingVM succeeded, want error")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("PingVM took %v, want it bounded by the context deadline", elapsed)
+			}
+			if state := vmState(m, "vm-1"); state != VMStateFailed {
+				t.Errorf("state = %s, want failed", state)
+			}
+			// 失败的虚拟机由空闲回收清理
+			if reaped := m.selectVMsToReap(time.Now(), nil); reaped["vm-1"] != reapReasonStopped {
+				t.Errorf("reaped = %v, want vm-1 selected", reaped)
+			}
+		})
+	}
+}
+
+func TestPingVMUnknown(t *testing.T) {
+	m := NewMachineManager(config.FirecrackerConfig{}, nil, testLogger())
+	if err := m.PingVM(context.Background(), "missing"); err == nil {
+		t.Error("PingVM for unknown VM succeeded, want error")
+	}
+}
@@ -0,0 +1,180 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/oriys/nimbus/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// chroot 内的固定路径。
+// Firecracker 经 jailer 启动后工作在 chroot 根目录下，API 请求中的路径都按 chroot 解析，
+// 主机侧访问同一文件时使用 jail.hostPath 转换。
+const (
+	jailSocketPath        = "/run/firecracker.socket" // API socket
+	jailVsockPath         = "/vsock.sock"             // vsock 设备的 Unix socket
+	jailKernelPath        = "/vmlinux"                // 内核镜像
+	jailRootfsPath        = "/rootfs.ext4"            // 根文件系统（镜像文件或块设备节点）
+	jailSeccompPath       = "/seccomp.bpf"            // 自定义 seccomp 过滤器
+	jailSnapshotMemPath   = "/snapshot.mem"           // 快照内存文件
+	jailSnapshotStatePath = "/snapshot.state"         // 快照状态文件
+)
+
+// jail 描述一个虚拟机的 jailer chroot 目录。
+// 目录布局与 jailer 约定一致：<chroot_base_dir>/<firecracker 文件名>/<vm_id>/root。
+type jail struct {
+	dir  string // 虚拟机的 jail 目录（root 的上一级），StopVM 时整体删除
+	root string // chroot 根目录
+	uid  int    // Firecracker 降权后的用户 ID
+	gid  int    // Firecracker 降权后的组 ID
+}
+
+// newJail 按配置计算虚拟机的 jail 目录，不创建任何文件
+func newJail(cfg config.FirecrackerConfig, vmID string) *jail {
+	dir := filepath.Join(cfg.ChrootBaseDir, filepath.Base(cfg.Binary), vmID)
+	return &jail{
+		dir:  dir,
+		root: filepath.Join(dir, "root"),
+		uid:  cfg.JailerUID,
+		gid:  cfg.JailerGID,
+	}
+}
+
+// hostPath 将 chroot 内的路径转换为主机上的路径
+func (j *jail) hostPath(jailPath string) string {
+	return filepath.Join(j.root, jailPath)
+}
+
+// prepare 创建 chroot 目录结构，并交给降权后的用户，
+// 使 Firecracker 能在其中创建 API socket 和 vsock socket。
+func (j *jail) prepare() error {
+	runDir := j.hostPath(filepath.Dir(jailSocketPath))
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return fmt.Errorf("create jail dir: %w", err)
+	}
+	for _, dir := range []string{j.root, runDir} {
+		if err := os.Chown(dir, j.uid, j.gid); err != nil {
+			return fmt.Errorf("chown jail dir: %w", err)
+		}
+	}
+	return nil
+}
+
+// link 将主机文件放入 chroot 的指定路径。
+// 普通文件优先硬链接，跨文件系统时回退为写时复制克隆或完整复制；
+// 块设备（如 overlay 策略的 device-mapper 设备）在 chroot 内创建相同设备号的节点。
+func (j *jail) link(src, jailPath string) error {
+	dst := j.hostPath(jailPath)
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeDevice != 0 {
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("stat device %s: unsupported platform", src)
+		}
+		if err := unix.Mknod(dst, unix.S_IFBLK|0600, int(st.Rdev)); err != nil {
+			return fmt.Errorf("mknod %s: %w", dst, err)
+		}
+	} else if err := os.Link(src, dst); err != nil {
+		if err := cloneOrCopyFile(src, dst, true); err != nil {
+			return fmt.Errorf("link %s into jail: %w", src, err)
+		}
+	}
+
+	// 只有根文件系统需要 Firecracker 写入；内核、快照等只读文件保持原属主，
+	// 避免通过硬链接改动主机上共享文件的属主
+	if jailPath != jailRootfsPath {
+		return nil
+	}
+	return os.Chown(dst, j.uid, j.gid)
+}
+
+// moveOut 将 Firecracker 在 chroot 内生成的文件移动到主机上的目标路径，
+// 跨文件系统时回退为复制后删除。
+func (j *jail) moveOut(jailPath, dst string) error {
+	src := j.hostPath(jailPath)
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := cloneOrCopyFile(src, dst, true); err != nil {
+		return fmt.Errorf("move %s out of jail: %w", jailPath, err)
+	}
+	return os.Remove(src)
+}
+
+// remove 删除整个 jail 目录（含 chroot 内的链接、socket 和 jailer 复制的可执行文件）
+func (j *jail) remove() error {
+	return os.RemoveAll(j.dir)
+}
+
+// jailerArgs 构建 jailer 的命令行参数。
+// jailer 负责创建 chroot、写入 cgroup、切换到非特权用户后 exec Firecracker，
+// "--" 之后的参数原样传给 Firecracker，其中的路径均为 chroot 内路径。
+func jailerArgs(cfg config.FirecrackerConfig, vmID string) []string {
+	args := []string{
+		"--id", vmID,
+		"--exec-file", cfg.Binary,
+		"--uid", strconv.Itoa(cfg.JailerUID),
+		"--gid", strconv.Itoa(cfg.JailerGID),
+		"--chroot-base-dir", cfg.ChrootBaseDir,
+	}
+	if cfg.JailerCgroupVersion != "" {
+		args = append(args, "--cgroup-version", cfg.JailerCgroupVersion)
+	}
+	for _, cgroup := range cfg.JailerCgroups {
+		args = append(args, "--cgroup", cgroup)
+	}
+
+	args = append(args, "--", "--api-sock", jailSocketPath)
+	if cfg.JailerSeccompFilter != "" {
+		args = append(args, "--seccomp-filter", jailSeccompPath)
+	}
+	return args
+}
+
+// jailerCommand 构建通过 jailer 启动 Firecracker 的命令
+func (m *MachineManager) jailerCommand(ctx context.Context, vmID string, logFile *os.File) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, m.cfg.JailerBinary, jailerArgs(m.cfg, vmID)...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	return cmd
+}
+
+// setupJail 为虚拟机创建 chroot 并放入启动所需的文件。
+// 参数：
+//   - vmID: 虚拟机 ID
+//   - files: 主机路径 -> chroot 内路径
+//
+// 返回：
+//   - *jail: 已准备好的 jail，失败时目录已被清理
+//   - error: 创建目录或放入文件失败时的错误
+func (m *MachineManager) setupJail(vmID string, files map[string]string) (*jail, error) {
+	j := newJail(m.cfg, vmID)
+	if err := j.prepare(); err != nil {
+		_ = j.remove()
+		return nil, err
+	}
+
+	if m.cfg.JailerSeccompFilter != "" {
+		files[m.cfg.JailerSeccompFilter] = jailSeccompPath
+	}
+	for src, jailPath := range files {
+		if err := j.link(src, jailPath); err != nil {
+			_ = j.remove()
+			return nil, err
+		}
+	}
+	return j, nil
+}
@@ -0,0 +1,114 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/config"
+)
+
+func TestJailerArgs(t *testing.T) {
+	cfg := config.FirecrackerConfig{
+		Binary:              "/usr/local/bin/firecracker",
+		ChrootBaseDir:       "/srv/jailer",
+		JailerUID:           123,
+		JailerGID:           456,
+		JailerCgroupVersion: "2",
+		JailerCgroups:       []string{"cpu.max=100000 100000"},
+		JailerSeccompFilter: "/etc/nimbus/seccomp.bpf",
+	}
+
+	got := jailerArgs(cfg, "vm-1")
+	want := []string{
+		"--id", "vm-1",
+		"--exec-file", "/usr/local/bin/firecracker",
+		"--uid", "123",
+		"--gid", "456",
+		"--chroot-base-dir", "/srv/jailer",
+		"--cgroup-version", "2",
+		"--cgroup", "cpu.max=100000 100000",
+		"--", "--api-sock", "/run/firecracker.socket",
+		"--seccomp-filter", "/seccomp.bpf",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("jailerArgs =\n  %q\nwant\n  %q", got, want)
+	}
+}
+
+func TestJailPathRewriting(t *testing.T) {
+	cfg := config.FirecrackerConfig{Binary: "/usr/local/bin/firecracker", ChrootBaseDir: "/srv/jailer"}
+	j := newJail(cfg, "vm-1")
+
+	if j.dir != "/srv/jailer/firecracker/vm-1" {
+		t.Errorf("jail dir = %s", j.dir)
+	}
+	// 主机侧的 API socket 路径必须与 jailer 实际创建的 chroot 一致
+	if got := j.hostPath(jailSocketPath); got != "/srv/jailer/firecracker/vm-1/root/run/firecracker.socket" {
+		t.Errorf("host socket path = %s", got)
+	}
+
+	m := NewMachineManager(config.FirecrackerConfig{Kernel: "/opt/vmlinux", VsockDir: "/tmp/vsock"}, nil, testLogger())
+	vm := &VM{ID: "vm-1", SocketPath: j.hostPath(jailSocketPath), VCPUs: 1, MemoryMB: 128, jail: j}
+	fcCfg := m.buildFirecrackerConfig(vm, "/var/lib/nimbus/vm-1.ext4", &NetworkConfig{})
+
+	if fcCfg.KernelImagePath != jailKernelPath {
+		t.Errorf("kernel path = %s, want %s", fcCfg.KernelImagePath, jailKernelPath)
+	}
+	if got := *fcCfg.Drives[0].PathOnHost; got != jailRootfsPath {
+		t.Errorf("rootfs path = %s, want %s", got, jailRootfsPath)
+	}
+	if got := fcCfg.VsockDevices[0].Path; got != jailVsockPath {
+		t.Errorf("vsock path = %s, want %s", got, jailVsockPath)
+	}
+	if !fcCfg.DisableValidation {
+		t.Error("validation should be disabled for jailed paths")
+	}
+}
+
+func TestSetupJailLinksFilesAndRemove(t *testing.T) {
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "vmlinux")
+	rootfs := filepath.Join(dir, "vm-1.ext4")
+	for path, content := range map[string]string{kernel: "kernel", rootfs: "rootfs"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.FirecrackerConfig{
+		Binary:        "/usr/local/bin/firecracker",
+		ChrootBaseDir: filepath.Join(dir, "jailer"),
+		JailerUID:     os.Getuid(),
+		JailerGID:     os.Getgid(),
+	}
+	m := NewMachineManager(cfg, nil, testLogger())
+
+	j, err := m.setupJail("vm-1", map[string]string{kernel: jailKernelPath, rootfs: jailRootfsPath})
+	if err != nil {
+		t.Fatalf("setupJail: %v", err)
+	}
+	for jailPath, want := range map[string]string{jailKernelPath: "kernel", jailRootfsPath: "rootfs"} {
+		if data, _ := os.ReadFile(j.hostPath(jailPath)); string(data) != want {
+			t.Errorf("%s content = %q, want %q", jailPath, data, want)
+		}
+	}
+	if info, err := os.Stat(j.hostPath("/run")); err != nil || !info.IsDir() {
+		t.Errorf("socket dir not created: %v", err)
+	}
+
+	if err := j.remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(j.dir); !os.IsNotExist(err) {
+		t.Error("jail directory still exists after remove")
+	}
+	// 删除 jail 不影响主机上的源文件
+	if _, err := os.Stat(rootfs); err != nil {
+		t.Errorf("source rootfs removed: %v", err)
+	}
+}
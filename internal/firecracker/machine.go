@@ -6,9 +6,11 @@ package firecracker
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -18,8 +20,10 @@ import (
 	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
 	"github.com/google/uuid"
 	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/telemetry"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/sys/unix"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // VMState 表示虚拟机的状态。
@@ -50,9 +54,20 @@ type VM struct {
 	LastUsed   time.Time // 最后使用时间
 	UseCount   int       // 使用次数
 
-	machine *firecracker.Machine // Firecracker 机器实例
-	cancel  context.CancelFunc   // 用于取消虚拟机上下文
-	mu      sync.Mutex           // 保护虚拟机操作的互斥锁
+	RestoreDuration time.Duration // 从快照恢复的耗时，正常启动的虚拟机为 0
+	BalloonMB       int64         // balloon 当前目标大小（MB），即已归还主机的内存
+	Limits          RateLimits    // 当前生效的磁盘和网络限速，从快照恢复的虚拟机为零值
+
+	inUse    bool   // 是否正在执行调用，由 MarkVMInUse/MarkVMIdle 维护，使用中的虚拟机不会被回收
+	reaping  bool   // 已被空闲回收选中并确认空闲，正在停止，MarkVMInUse 不再交付
+	function string // 虚拟机内 Agent 当前初始化的函数 ID，由 BindFunction 维护，状态服务据此校验请求
+
+	machine       *firecracker.Machine // Firecracker 机器实例
+	cancel        context.CancelFunc   // 用于取消虚拟机上下文
+	rootfsCleanup func()               // 释放根文件系统（删除副本或拆除 overlay），从没有磁盘来源的快照恢复的虚拟机为 nil
+	jail          *jail                // jailer chroot 目录，未启用 jailer 时为 nil
+	metrics       *metricsCollector    // metrics FIFO 采集器，创建失败时为 nil
+	mu            sync.Mutex           // 保护虚拟机操作的互斥锁
 }
 
 // MachineManager 管理 Firecracker 虚拟机的生命周期。
@@ -62,9 +77,20 @@ type MachineManager struct {
 	networkMgr *NetworkManager          // 网络管理器
 	logger     *logrus.Logger           // 日志记录器
 
+	rootfsStrategy RootfsStrategy // 实际使用的根文件系统准备策略
+
 	mu      sync.RWMutex   // 保护 vms 映射的读写锁
 	vms     map[string]*VM // vmID -> VM 的映射
 	nextCID uint32         // 下一个可分配的 CID
+
+	now          func() time.Time                             // 返回当前时间，测试中可替换
+	stopVM       func(ctx context.Context, vmID string) error // 回收虚拟机时调用，默认为 StopVM，测试中可替换
+	reaperCancel context.CancelFunc                           // 停止空闲回收循环，未启动时为 nil
+	reaperDone   chan struct{}                                // 空闲回收循环退出后关闭
+	onReaped     func(vmID, runtime string)                   // 虚拟机被回收后调用，通知虚拟机池移除，受 mu 保护
+
+	dialVsock func(ctx context.Context, cid uint32) (net.Conn, error) // 建立到虚拟机 agent 的 vsock 连接，测试中可替换
+	draining  bool                                                    // 正在排空，拒绝创建和取用虚拟机，受 mu 保护
 }
 
 // NewMachineManager 创建新的虚拟机管理器。
@@ -73,11 +99,12 @@ type MachineManager struct {
 //   - networkMgr: 网络管理器
 //   - logger: 日志记录器
 func NewMachineManager(cfg config.FirecrackerConfig, networkMgr *NetworkManager, logger *logrus.Logger) *MachineManager {
-	return &MachineManager{
-		cfg:        cfg,
-		networkMgr: networkMgr,
-		logger:     logger,
-		vms:        make(map[string]*VM),
+	m := &MachineManager{
+		cfg:            cfg,
+		networkMgr:     networkMgr,
+		logger:         logger,
+		rootfsStrategy: selectRootfsStrategy(cfg.RootfsStrategy, overlaySupported, logger),
+		vms:            make(map[string]*VM),
 		// CID (Context ID) 是 vsock 协议中用于标识虚拟机的唯一地址。
 		// vsock CID 保留值说明：
 		//   - 0: 表示 hypervisor（预留）
@@ -86,7 +113,11 @@ func NewMachineManager(cfg config.FirecrackerConfig, networkMgr *NetworkManager,
 		//   - 3-99: 通常被系统或其他服务使用
 		// 因此从 100 开始分配，确保不会与系统保留值或其他服务冲突。
 		nextCID: 100,
+		now:     time.Now,
 	}
+	m.stopVM = m.StopVM
+	m.dialVsock = dialAgent
+	return m
 }
 
 // CreateVM 创建并启动一个新的 Firecracker 虚拟机。
@@ -95,15 +126,20 @@ func NewMachineManager(cfg config.FirecrackerConfig, networkMgr *NetworkManager,
 //   - runtime: 运行时类型
 //   - memoryMB: 内存大小（MB）
 //   - vcpus: 虚拟 CPU 数量
+//   - limits: 磁盘和网络限速，零值表示不限速
 //
 // 返回：
 //   - *VM: 创建的虚拟机实例
 //   - error: 创建过程中的错误
-func (m *MachineManager) CreateVM(ctx context.Context, runtime string, memoryMB, vcpus int64) (*VM, error) {
+func (m *MachineManager) CreateVM(ctx context.Context, runtime string, memoryMB, vcpus int64, limits RateLimits) (*VM, error) {
 	vmID := uuid.New().String()
 
-	// 分配唯一的 CID
+	// 分配唯一的 CID，排空期间不再创建虚拟机
 	m.mu.Lock()
+	if m.draining {
+		m.mu.Unlock()
+		return nil, ErrDraining
+	}
 	cid := m.nextCID
 	m.nextCID++
 	m.mu.Unlock()
@@ -124,16 +160,38 @@ func (m *MachineManager) CreateVM(ctx context.Context, runtime string, memoryMB,
 		return nil, fmt.Errorf("rootfs not found for runtime %s: %s", runtime, baseRootfsPath)
 	}
 
-	// 克隆根文件系统（每个虚拟机使用独立副本）
-	rootfsPath, err := m.cloneRootfs(runtime, vmID, baseRootfsPath)
+	// 准备根文件系统（每个虚拟机使用独立的可写根文件系统）
+	rootfsPath, cleanupRootfs, err := m.prepareRootfs(runtime, vmID, baseRootfsPath)
 	if err != nil {
 		return nil, err
 	}
 
+	// 启用 jailer 时把内核和根文件系统放入 chroot，API socket 也位于 chroot 内
+	var vmJail *jail
+	if m.cfg.UseJailer {
+		vmJail, err = m.setupJail(vmID, map[string]string{
+			m.cfg.Kernel: jailKernelPath,
+			rootfsPath:   jailRootfsPath,
+		})
+		if err != nil {
+			cleanupRootfs()
+			return nil, fmt.Errorf("failed to setup jail: %w", err)
+		}
+		socketPath = vmJail.hostPath(jailSocketPath)
+	}
+
+	// 创建失败时释放 jail 和根文件系统
+	cleanupFiles := func() {
+		if vmJail != nil {
+			_ = vmJail.remove()
+		}
+		cleanupRootfs()
+	}
+
 	// 配置网络
 	netConfig, err := m.networkMgr.SetupNetwork(vmID)
 	if err != nil {
-		_ = os.Remove(rootfsPath)
+		cleanupFiles()
 		return nil, fmt.Errorf("failed to setup network: %w", err)
 	}
 
@@ -145,12 +203,17 @@ func (m *MachineManager) CreateVM(ctx context.Context, runtime string, memoryMB,
 		VsockCID:   cid,
 		MemoryMB:   memoryMB,
 		VCPUs:      vcpus,
+		Limits:     limits,
 		SocketPath: socketPath,
 		RootfsPath: rootfsPath,
 		LogPath:    logPath,
 		IP:         netConfig.GuestIP,
 		CreatedAt:  time.Now(),
+
+		rootfsCleanup: cleanupRootfs,
+		jail:          vmJail,
 	}
+	m.startMetrics(vm)
 
 	// 构建 Firecracker 配置
 	fcConfig := m.buildFirecrackerConfig(vm, vm.RootfsPath, netConfig)
@@ -159,7 +222,8 @@ func (m *MachineManager) CreateVM(ctx context.Context, runtime string, memoryMB,
 	logFile, err := os.Create(logPath)
 	if err != nil {
 		m.networkMgr.CleanupNetwork(vmID)
-		_ = os.Remove(rootfsPath)
+		m.stopMetrics(vm)
+		cleanupFiles()
 		return nil, fmt.Errorf("failed to create log file: %w", err)
 	}
 
@@ -168,12 +232,7 @@ func (m *MachineManager) CreateVM(ctx context.Context, runtime string, memoryMB,
 	vm.cancel = cancel
 
 	// 构建 Firecracker 命令
-	cmd := firecracker.VMCommandBuilder{}.
-		WithBin(m.cfg.Binary).
-		WithSocketPath(socketPath).
-		WithStderr(logFile).
-		WithStdout(logFile).
-		Build(machineCtx)
+	cmd := m.vmmCommand(machineCtx, vm, logFile)
 
 	// 创建 Firecracker 机器实例
 	machine, err := firecracker.NewMachine(machineCtx, fcConfig, firecracker.WithProcessRunner(cmd))
@@ -181,22 +240,27 @@ func (m *MachineManager) CreateVM(ctx context.Context, runtime string, memoryMB,
 		cancel()
 		m.networkMgr.CleanupNetwork(vmID)
 		logFile.Close()
-		_ = os.Remove(rootfsPath)
+		m.stopMetrics(vm)
+		cleanupFiles()
 		return nil, fmt.Errorf("failed to create machine: %w", err)
 	}
 
 	vm.machine = machine
+	m.attachBalloon(machine)
+	useOwnMetricsFifo(machine)
 
 	// 启动虚拟机
 	if err := machine.Start(machineCtx); err != nil {
 		cancel()
 		m.networkMgr.CleanupNetwork(vmID)
 		logFile.Close()
-		_ = os.Remove(rootfsPath)
+		m.stopMetrics(vm)
+		cleanupFiles()
 		return nil, fmt.Errorf("failed to start machine: %w", err)
 	}
 
 	vm.State = VMStateRunning
+	vm.LastUsed = m.now()
 
 	// 注册虚拟机
 	m.mu.Lock()
@@ -215,21 +279,52 @@ func (m *MachineManager) CreateVM(ctx context.Context, runtime string, memoryMB,
 	return vm, nil
 }
 
+// vmmCommand 构建启动 VMM 的命令，启用 jailer 时经由 jailer 启动 Firecracker
+func (m *MachineManager) vmmCommand(ctx context.Context, vm *VM, logFile *os.File) *exec.Cmd {
+	if vm.jail != nil {
+		return m.jailerCommand(ctx, vm.ID, logFile)
+	}
+	return firecracker.VMCommandBuilder{}.
+		WithBin(m.cfg.Binary).
+		WithSocketPath(vm.SocketPath).
+		WithStderr(logFile).
+		WithStdout(logFile).
+		Build(ctx)
+}
+
+// vsockPath 返回交给 Firecracker 的 vsock socket 路径，启用 jailer 时为 chroot 内路径
+func (m *MachineManager) vsockPath(vm *VM) string {
+	if vm.jail != nil {
+		return jailVsockPath
+	}
+	return filepath.Join(m.cfg.VsockDir, vm.ID+".vsock")
+}
+
 // buildFirecrackerConfig 构建 Firecracker 虚拟机配置。
-// 包含内核、磁盘、网络和 vsock 配置。
+// 包含内核、磁盘、网络和 vsock 配置，磁盘和网卡按 vm.Limits 挂载限速器。
+// 启用 jailer 时内核和磁盘使用 chroot 内路径，SDK 无法在主机上校验这些路径，因此关闭校验。
 func (m *MachineManager) buildFirecrackerConfig(vm *VM, rootfsPath string, netConfig *NetworkConfig) firecracker.Config {
+	kernelPath := m.cfg.Kernel
+	if vm.jail != nil {
+		kernelPath = jailKernelPath
+		rootfsPath = jailRootfsPath
+	}
 	return firecracker.Config{
-		SocketPath:      vm.SocketPath,
-		KernelImagePath: m.cfg.Kernel,
+		SocketPath:        vm.SocketPath,
+		KernelImagePath:   kernelPath,
+		DisableValidation: vm.jail != nil,
+		// metrics 输出到 FIFO，由 metricsCollector 读取累加
+		MetricsPath: m.metricsPath(vm),
 		// 内核启动参数：控制台输出、panic 时重启、禁用 PCI、指定 init 进程
 		KernelArgs: m.buildKernelArgs(netConfig),
 		// 磁盘配置
 		Drives: []models.Drive{
 			{
-				DriveID:      firecracker.String("rootfs"),
+				DriveID:      firecracker.String(rootfsDriveID),
 				PathOnHost:   firecracker.String(rootfsPath),
 				IsRootDevice: firecracker.Bool(true),
 				IsReadOnly:   firecracker.Bool(false),
+				RateLimiter:  vm.Limits.diskLimiter(),
 			},
 		},
 		// 网络接口配置
@@ -239,6 +334,8 @@ func (m *MachineManager) buildFirecrackerConfig(vm *VM, rootfsPath string, netCo
 					MacAddress:  netConfig.MacAddress,
 					HostDevName: netConfig.TapDevice,
 				},
+				InRateLimiter:  vm.Limits.netLimiter(),
+				OutRateLimiter: vm.Limits.netLimiter(),
 			},
 		},
 		// 机器配置（CPU 和内存）
@@ -249,7 +346,7 @@ func (m *MachineManager) buildFirecrackerConfig(vm *VM, rootfsPath string, netCo
 		// vsock 设备配置，用于主机与虚拟机通信
 		VsockDevices: []firecracker.VsockDevice{
 			{
-				Path: filepath.Join(m.cfg.VsockDir, vm.ID+".vsock"),
+				Path: m.vsockPath(vm),
 				CID:  vm.VsockCID,
 			},
 		},
@@ -268,6 +365,13 @@ func (m *MachineManager) buildKernelArgs(netConfig *NetworkConfig) string {
 	if netConfig != nil && netConfig.GuestIP != "" && netConfig.GatewayIP != "" && netConfig.SubnetMask != "" {
 		args = append(args, fmt.Sprintf("ip=%s::%s:%s::eth0:off", netConfig.GuestIP, netConfig.GatewayIP, netConfig.SubnetMask))
 	}
+	// 内核 ip= 参数只支持 IPv4，IPv6 地址和网关由 guest 内的 agent 读取 /proc/cmdline 后配置
+	if netConfig != nil && netConfig.GuestIPv6 != "" && netConfig.GatewayIPv6 != "" {
+		args = append(args,
+			fmt.Sprintf("nimbus.ipv6=%s/%d", netConfig.GuestIPv6, netConfig.IPv6PrefixLen),
+			fmt.Sprintf("nimbus.gw6=%s", netConfig.GatewayIPv6),
+		)
+	}
 	return strings.Join(args, " ")
 }
 
@@ -312,11 +416,21 @@ func (m *MachineManager) StopVM(ctx context.Context, vmID string) error {
 	// 清理网络资源
 	m.networkMgr.CleanupNetwork(vmID)
 
+	// 停止 metrics 采集
+	m.stopMetrics(vm)
+
 	// 清理临时文件
 	os.Remove(vm.SocketPath)
 	os.Remove(filepath.Join(m.cfg.VsockDir, vm.ID+".vsock"))
-	if vm.RootfsPath != "" {
-		_ = os.Remove(vm.RootfsPath)
+	// 删除 jail 目录（chroot 内的内核、磁盘链接、socket 和 jailer 复制的可执行文件）
+	if vm.jail != nil {
+		if err := vm.jail.remove(); err != nil {
+			m.logger.WithError(err).WithField("vm_id", vmID).Warn("Failed to remove jail directory")
+		}
+	}
+	// 清理根文件系统（副本文件或 overlay 设备）
+	if vm.rootfsCleanup != nil {
+		vm.rootfsCleanup()
 	}
 
 	vm.State = VMStateStopped
@@ -325,60 +439,6 @@ func (m *MachineManager) StopVM(ctx context.Context, vmID string) error {
 	return nil
 }
 
-// cloneRootfs 克隆根文件系统镜像。
-// 每个虚拟机使用独立的根文件系统副本，避免相互影响。
-// 优先使用 FICLONE（reflink）进行写时复制克隆，失败时回退到普通复制。
-func (m *MachineManager) cloneRootfs(runtime, vmID, baseRootfsPath string) (string, error) {
-	destDir := filepath.Join(m.cfg.SnapshotDir, "vmrootfs", runtime)
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return "", fmt.Errorf("create vm rootfs dir: %w", err)
-	}
-	destPath := filepath.Join(destDir, vmID+".ext4")
-	if err := cloneOrCopyFile(baseRootfsPath, destPath); err != nil {
-		return "", fmt.Errorf("clone rootfs: %w", err)
-	}
-	return destPath, nil
-}
-
-// cloneOrCopyFile 克隆或复制文件。
-// 优先尝试使用 FICLONE ioctl（reflink）进行高效的写时复制克隆，
-// 如果文件系统不支持则回退到传统的数据复制。
-func cloneOrCopyFile(src, dst string) (err error) {
-	// 打开源文件
-	in, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer in.Close()
-
-	// 创建目标文件
-	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		closeErr := out.Close()
-		if err == nil {
-			err = closeErr
-		}
-		// 如果出错，清理目标文件
-		if err != nil {
-			_ = os.Remove(dst)
-		}
-	}()
-
-	// 尝试使用 FICLONE 进行写时复制克隆（仅在支持的文件系统上有效，如 Btrfs、XFS）
-	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err == nil {
-		return out.Sync()
-	}
-
-	// 回退到传统复制
-	if _, err := io.Copy(out, in); err != nil {
-		return err
-	}
-	return out.Sync()
-}
-
 // ListVMs 返回所有虚拟机的列表。
 func (m *MachineManager) ListVMs() []*VM {
 	m.mu.RLock()
@@ -418,7 +478,7 @@ func (m *MachineManager) CreateSnapshot(ctx context.Context, vmID, snapshotID st
 	}
 
 	// 创建快照
-	if err := vm.machine.CreateSnapshot(ctx, memFilePath, snapshotPath); err != nil {
+	if err := m.createSnapshotFiles(ctx, vm, memFilePath, snapshotPath); err != nil {
 		vm.machine.ResumeVM(ctx) // 恢复虚拟机
 		return fmt.Errorf("failed to create snapshot: %w", err)
 	}
@@ -452,7 +512,7 @@ func (m *MachineManager) CreateSnapshotWithPath(ctx context.Context, vmID, memFi
 	}
 
 	// 创建快照
-	if err := vm.machine.CreateSnapshot(ctx, memFilePath, snapshotFilePath); err != nil {
+	if err := m.createSnapshotFiles(ctx, vm, memFilePath, snapshotFilePath); err != nil {
 		vm.machine.ResumeVM(ctx) // 恢复虚拟机
 		return fmt.Errorf("failed to create snapshot: %w", err)
 	}
@@ -466,6 +526,38 @@ func (m *MachineManager) CreateSnapshotWithPath(ctx context.Context, vmID, memFi
 	return nil
 }
 
+// createSnapshotFiles 调用 Firecracker 生成快照文件，并把虚拟机的磁盘保存到状态文件所在目录。
+// 快照状态中记录了磁盘路径：未启用 jailer 时先把驱动器切换到保存的磁盘再生成快照，
+// 使快照引用的路径在源虚拟机销毁后仍然有效，生成后切回虚拟机自己的磁盘；
+// 启用 jailer 时 Firecracker 只能写入 chroot 内，快照先生成在 chroot 中再移动到目标路径，
+// 记录的是 chroot 内的固定路径，恢复时在同一位置放入磁盘副本。
+func (m *MachineManager) createSnapshotFiles(ctx context.Context, vm *VM, memFilePath, snapshotFilePath string) error {
+	savedRootfs, err := m.saveSnapshotRootfs(vm, filepath.Dir(snapshotFilePath))
+	if err != nil {
+		return err
+	}
+
+	if vm.jail == nil {
+		if err := vm.machine.UpdateGuestDrive(ctx, rootfsDriveID, savedRootfs); err != nil {
+			return fmt.Errorf("switch drive to snapshot rootfs: %w", err)
+		}
+		defer func() {
+			if err := vm.machine.UpdateGuestDrive(ctx, rootfsDriveID, vm.RootfsPath); err != nil {
+				m.logger.WithError(err).WithField("vm_id", vm.ID).Warn("Failed to switch drive back after snapshot")
+			}
+		}()
+		return vm.machine.CreateSnapshot(ctx, memFilePath, snapshotFilePath)
+	}
+
+	if err := vm.machine.CreateSnapshot(ctx, jailSnapshotMemPath, jailSnapshotStatePath); err != nil {
+		return err
+	}
+	if err := vm.jail.moveOut(jailSnapshotMemPath, memFilePath); err != nil {
+		return err
+	}
+	return vm.jail.moveOut(jailSnapshotStatePath, snapshotFilePath)
+}
+
 // RestoreFromSnapshot 从快照恢复创建新的虚拟机。
 // 比从头创建虚拟机更快，适用于需要快速启动的场景。
 // 参数：
@@ -473,49 +565,169 @@ func (m *MachineManager) CreateSnapshotWithPath(ctx context.Context, vmID, memFi
 //   - snapshotID: 快照 ID
 //   - runtime: 运行时类型
 func (m *MachineManager) RestoreFromSnapshot(ctx context.Context, snapshotID, runtime string) (*VM, error) {
+	vm, err := m.RestoreVM(ctx, filepath.Join(m.cfg.SnapshotDir, snapshotID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if runtime != "" {
+		vm.Runtime = runtime
+	}
+	return vm, nil
+}
+
+// snapshotVMInfo 是快照目录中 metadata.json 的部分字段，用于填充恢复出的虚拟机信息。
+// 字段与快照管理器写入的元数据一致，文件不存在时对应字段为空。
+type snapshotVMInfo struct {
+	Runtime  string `json:"runtime"`
+	MemoryMB int64  `json:"memory_mb"`
+	VCPUs    int64  `json:"vcpus"`
+}
+
+// readSnapshotVMInfo 读取快照目录中的元数据，读取失败时返回空信息
+func readSnapshotVMInfo(snapshotPath string) snapshotVMInfo {
+	var info snapshotVMInfo
+	data, err := os.ReadFile(filepath.Join(snapshotPath, "metadata.json"))
+	if err != nil {
+		return info
+	}
+	_ = json.Unmarshal(data, &info)
+	return info
+}
+
+// RestoreVM 从快照目录恢复一个虚拟机。
+// 快照目录包含 mem（内存快照）、snapshot（CPU/设备状态）和 rootfs.ext4（磁盘）三个文件，
+// 与 CreateSnapshot 和函数快照构建的产物布局一致。
+// 恢复流程为加载快照（覆盖网卡和 vsock 设备）后显式 Resume，新实例使用独立的磁盘副本、
+// 新的 vsock socket 和网络配置，
+// 从启动 VMM 到恢复运行的耗时记录在 VM.RestoreDuration 中，供调用方更新快照统计。
+// 整个恢复过程记录为 snapshot_restore span。
+// 参数：
+//   - ctx: 上下文
+//   - snapshotPath: 快照目录路径
+//   - netConfig: 新实例的网络配置，为 nil 时分配新的 TAP 设备和 IP，并在 StopVM 时清理
+//
+// 返回：
+//   - *VM: 已注册到管理器的虚拟机实例
+//   - error: 快照不存在或恢复失败时返回错误
+func (m *MachineManager) RestoreVM(ctx context.Context, snapshotPath string, netConfig *NetworkConfig) (*VM, error) {
+	ctx, span := telemetry.GetTracer(tracerName).Start(ctx, "snapshot_restore",
+		trace.WithAttributes(attribute.String("snapshot.path", snapshotPath)))
+	vm, err := m.restoreVM(ctx, snapshotPath, netConfig)
+	if err != nil {
+		telemetry.EndSpan(span, err)
+		return nil, err
+	}
+	span.SetAttributes(
+		attribute.String("vm.id", vm.ID),
+		attribute.Int64("snapshot.restore_ms", vm.RestoreDuration.Milliseconds()),
+	)
+	span.End()
+	return vm, nil
+}
+
+// restoreVM 执行 RestoreVM 的恢复流程
+func (m *MachineManager) restoreVM(ctx context.Context, snapshotPath string, netConfig *NetworkConfig) (*VM, error) {
+	memFilePath := filepath.Join(snapshotPath, "mem")
+	stateFilePath := filepath.Join(snapshotPath, "snapshot")
+
+	// 检查快照文件是否存在
+	for _, path := range []string{memFilePath, stateFilePath} {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("snapshot not found: %s: %w", snapshotPath, err)
+		}
+	}
+
 	vmID := uuid.New().String()
 
-	// 分配 CID
+	// 分配 CID，排空期间不再恢复虚拟机
 	m.mu.Lock()
+	if m.draining {
+		m.mu.Unlock()
+		return nil, ErrDraining
+	}
 	cid := m.nextCID
 	m.nextCID++
 	m.mu.Unlock()
 
-	// 构建快照路径
-	snapshotDir := filepath.Join(m.cfg.SnapshotDir, snapshotID)
-	memFilePath := filepath.Join(snapshotDir, "mem")
-	snapshotPath := filepath.Join(snapshotDir, "snapshot")
-
-	// 检查快照是否存在
-	if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("snapshot not found: %s", snapshotID)
-	}
-
 	socketPath := filepath.Join(m.cfg.SocketDir, vmID+".sock")
 	logPath := filepath.Join(m.cfg.LogDir, vmID+".log")
+	os.MkdirAll(m.cfg.SocketDir, 0755)
+	os.MkdirAll(m.cfg.LogDir, 0755)
+	os.MkdirAll(m.cfg.VsockDir, 0755)
 
-	// 配置网络
-	netConfig, err := m.networkMgr.SetupNetwork(vmID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to setup network: %w", err)
+	// 配置网络：未指定时为新实例分配独立的 TAP 设备和 IP
+	ownNetwork := netConfig == nil
+	if ownNetwork {
+		var err error
+		netConfig, err = m.networkMgr.SetupNetwork(vmID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup network: %w", err)
+		}
 	}
 
+	info := readSnapshotVMInfo(snapshotPath)
+
 	// 初始化虚拟机结构
 	vm := &VM{
 		ID:         vmID,
-		Runtime:    runtime,
+		Runtime:    info.Runtime,
 		State:      VMStateCreating,
 		VsockCID:   cid,
+		MemoryMB:   info.MemoryMB,
+		VCPUs:      info.VCPUs,
 		SocketPath: socketPath,
 		LogPath:    logPath,
 		IP:         netConfig.GuestIP,
 		CreatedAt:  time.Now(),
 	}
 
+	// 释放 jail 和根文件系统
+	cleanupFiles := func() {
+		if vm.jail != nil {
+			_ = vm.jail.remove()
+		}
+		if vm.rootfsCleanup != nil {
+			vm.rootfsCleanup()
+		}
+	}
+
+	// 为新实例准备独立的可写磁盘，多个恢复实例不共享同一磁盘
+	if base := m.restoreRootfsBase(snapshotPath, vm.Runtime); base != "" {
+		rootfsPath, cleanupRootfs, err := m.prepareRootfs(vm.Runtime, vmID, base)
+		if err != nil {
+			if ownNetwork {
+				m.networkMgr.CleanupNetwork(vmID)
+			}
+			return nil, err
+		}
+		vm.RootfsPath = rootfsPath
+		vm.rootfsCleanup = cleanupRootfs
+	}
+
+	// 启用 jailer 时快照文件需放入 chroot，Firecracker 按 chroot 内路径加载
+	fcMemPath, fcStatePath := memFilePath, stateFilePath
+	if m.cfg.UseJailer {
+		if err := m.setupRestoreJail(vm, memFilePath, stateFilePath); err != nil {
+			if ownNetwork {
+				m.networkMgr.CleanupNetwork(vmID)
+			}
+			cleanupFiles()
+			return nil, fmt.Errorf("failed to setup jail: %w", err)
+		}
+		socketPath = vm.jail.hostPath(jailSocketPath)
+		vm.SocketPath = socketPath
+		fcMemPath, fcStatePath = jailSnapshotMemPath, jailSnapshotStatePath
+	}
+	m.startMetrics(vm)
+
 	// 创建日志文件
 	logFile, err := os.Create(logPath)
 	if err != nil {
-		m.networkMgr.CleanupNetwork(vmID)
+		if ownNetwork {
+			m.networkMgr.CleanupNetwork(vmID)
+		}
+		m.stopMetrics(vm)
+		cleanupFiles()
 		return nil, fmt.Errorf("failed to create log file: %w", err)
 	}
 
@@ -523,59 +735,73 @@ func (m *MachineManager) RestoreFromSnapshot(ctx context.Context, snapshotID, ru
 	machineCtx, cancel := context.WithCancel(ctx)
 	vm.cancel = cancel
 
+	// 恢复失败时释放已分配的资源
+	cleanup := func() {
+		cancel()
+		if ownNetwork {
+			m.networkMgr.CleanupNetwork(vmID)
+		}
+		logFile.Close()
+		os.Remove(socketPath)
+		os.Remove(filepath.Join(m.cfg.VsockDir, vmID+".vsock"))
+		m.stopMetrics(vm)
+		cleanupFiles()
+	}
+
 	// 构建 Firecracker 命令
-	cmd := firecracker.VMCommandBuilder{}.
-		WithBin(m.cfg.Binary).
-		WithSocketPath(socketPath).
-		WithStderr(logFile).
-		WithStdout(logFile).
-		Build(machineCtx)
+	cmd := m.vmmCommand(machineCtx, vm, logFile)
 
-	// 配置从快照恢复
+	// 配置从快照恢复，vsock 和网卡在加载时通过覆盖参数重新绑定到新实例的 socket 和 TAP 设备
 	cfg := firecracker.Config{
-		SocketPath: socketPath,
+		SocketPath:        socketPath,
+		DisableValidation: vm.jail != nil,
+		MetricsPath:       m.metricsPath(vm),
 		Snapshot: firecracker.SnapshotConfig{
-			MemFilePath:         memFilePath,
-			SnapshotPath:        snapshotPath,
+			MemFilePath:         fcMemPath,
+			SnapshotPath:        fcStatePath,
 			EnableDiffSnapshots: false,
-			ResumeVM:            true, // 恢复后自动运行
-		},
-		VsockDevices: []firecracker.VsockDevice{
-			{
-				Path: filepath.Join(m.cfg.VsockDir, vm.ID+".vsock"),
-				CID:  cid,
-			},
-		},
-		NetworkInterfaces: []firecracker.NetworkInterface{
-			{
-				StaticConfiguration: &firecracker.StaticNetworkConfiguration{
-					MacAddress:  netConfig.MacAddress,
-					HostDevName: netConfig.TapDevice,
-				},
-			},
+			ResumeVM:            false, // 加载后显式 Resume，便于计时和处理失败
 		},
 	}
 
 	// 从快照创建机器实例
-	machine, err := firecracker.NewMachine(machineCtx, cfg, firecracker.WithProcessRunner(cmd), firecracker.WithSnapshot(memFilePath, snapshotPath))
+	machine, err := firecracker.NewMachine(machineCtx, cfg, firecracker.WithProcessRunner(cmd), firecracker.WithSnapshot(fcMemPath, fcStatePath))
 	if err != nil {
-		cancel()
-		m.networkMgr.CleanupNetwork(vmID)
-		logFile.Close()
+		cleanup()
 		return nil, fmt.Errorf("failed to create machine from snapshot: %w", err)
 	}
 
 	vm.machine = machine
+	useOwnMetricsFifo(machine)
+	loadSnapshotWithOverrides(machine, newSnapshotLoadParams(fcMemPath, fcStatePath, netConfig.TapDevice, m.vsockPath(vm)))
+	restoreStart := time.Now()
 
-	// 启动虚拟机
+	// 启动 VMM 并加载快照
 	if err := machine.Start(machineCtx); err != nil {
-		cancel()
-		m.networkMgr.CleanupNetwork(vmID)
-		logFile.Close()
-		return nil, fmt.Errorf("failed to start machine from snapshot: %w", err)
+		cleanup()
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	// 未启用 jailer 时快照引用的是保存在快照目录中的共享磁盘，恢复运行前切换到本实例的副本；
+	// 启用 jailer 时副本已位于 chroot 内快照记录的路径
+	if vm.jail == nil && vm.RootfsPath != "" {
+		if err := machine.UpdateGuestDrive(machineCtx, rootfsDriveID, vm.RootfsPath); err != nil {
+			machine.StopVMM()
+			cleanup()
+			return nil, fmt.Errorf("failed to attach restored rootfs: %w", err)
+		}
 	}
 
+	// 恢复虚拟机运行
+	if err := machine.ResumeVM(machineCtx); err != nil {
+		machine.StopVMM()
+		cleanup()
+		return nil, fmt.Errorf("failed to resume VM from snapshot: %w", err)
+	}
+
+	vm.RestoreDuration = time.Since(restoreStart)
 	vm.State = VMStateRunning
+	vm.LastUsed = m.now()
 
 	// 注册虚拟机
 	m.mu.Lock()
@@ -583,17 +809,42 @@ func (m *MachineManager) RestoreFromSnapshot(ctx context.Context, snapshotID, ru
 	m.mu.Unlock()
 
 	m.logger.WithFields(logrus.Fields{
-		"vm_id":       vmID,
-		"snapshot_id": snapshotID,
-		"runtime":     runtime,
+		"vm_id":         vmID,
+		"snapshot_path": snapshotPath,
+		"runtime":       vm.Runtime,
+		"cid":           cid,
+		"ip":            vm.IP,
+		"restore_ms":    vm.RestoreDuration.Milliseconds(),
 	}).Info("VM restored from snapshot")
 
 	return vm, nil
 }
 
+// setupRestoreJail 为从快照恢复的虚拟机准备 chroot。
+// 快照中记录的磁盘路径是源虚拟机 chroot 内的路径，因此把新实例的磁盘副本（vm.RootfsPath）
+// 放到相同位置；快照须由同样启用 jailer 的虚拟机创建。
+func (m *MachineManager) setupRestoreJail(vm *VM, memFilePath, stateFilePath string) error {
+	files := map[string]string{
+		memFilePath:   jailSnapshotMemPath,
+		stateFilePath: jailSnapshotStatePath,
+	}
+	if vm.RootfsPath != "" {
+		files[vm.RootfsPath] = jailRootfsPath
+	}
+
+	vmJail, err := m.setupJail(vm.ID, files)
+	if err != nil {
+		return err
+	}
+	vm.jail = vmJail
+	return nil
+}
+
 // Shutdown 关闭虚拟机管理器并停止所有虚拟机。
 // 应在程序退出时调用以确保所有资源被正确释放。
 func (m *MachineManager) Shutdown(ctx context.Context) error {
+	m.StopReaper()
+
 	// 获取所有虚拟机列表
 	m.mu.Lock()
 	vms := make([]*VM, 0, len(m.vms))
@@ -604,7 +855,7 @@ func (m *MachineManager) Shutdown(ctx context.Context) error {
 
 	// 逐个停止虚拟机
 	for _, vm := range vms {
-		if err := m.StopVM(ctx, vm.ID); err != nil {
+		if err := m.stopVM(ctx, vm.ID); err != nil {
 			m.logger.WithError(err).WithField("vm_id", vm.ID).Error("Failed to stop VM during shutdown")
 		}
 	}
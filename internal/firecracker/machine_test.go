@@ -0,0 +1,93 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestRestoreVMMissingSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	m := NewMachineManager(config.FirecrackerConfig{SnapshotDir: dir}, nil, testLogger())
+
+	// 快照文件缺失时在分配网络等资源之前返回错误
+	if _, err := m.RestoreVM(context.Background(), dir, nil); err == nil || !strings.Contains(err.Error(), "snapshot not found") {
+		t.Fatalf("RestoreVM without snapshot files: err=%v, want snapshot not found", err)
+	}
+	if _, err := m.RestoreFromSnapshot(context.Background(), "missing", "python3.11"); err == nil {
+		t.Fatal("RestoreFromSnapshot with unknown snapshot should fail")
+	}
+	if vms := m.ListVMs(); len(vms) != 0 {
+		t.Errorf("expected no registered VMs, got %d", len(vms))
+	}
+}
+
+// TestRestoreVMIntegration 从真实快照恢复虚拟机。
+// 需要 root、/dev/kvm 和 firecracker，通过环境变量指定配置文件和快照目录：
+//
+//	NIMBUS_FC_TEST_CONFIG=/etc/nimbus/config.yaml NIMBUS_FC_TEST_SNAPSHOT=/var/nimbus/snapshots/content/<key> go test ./internal/firecracker/ -run Integration
+func TestRestoreVMIntegration(t *testing.T) {
+	configPath := os.Getenv("NIMBUS_FC_TEST_CONFIG")
+	snapshotPath := os.Getenv("NIMBUS_FC_TEST_SNAPSHOT")
+	if configPath == "" || snapshotPath == "" {
+		t.Skip("NIMBUS_FC_TEST_CONFIG and NIMBUS_FC_TEST_SNAPSHOT not set")
+	}
+	if _, err := os.Stat("/dev/kvm"); err != nil {
+		t.Skip("/dev/kvm not available")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("restoring a VM requires root")
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if _, err := os.Stat(cfg.Firecracker.Binary); err != nil {
+		t.Skipf("firecracker binary not available: %v", err)
+	}
+
+	logger := testLogger()
+	networkMgr, err := NewNetworkManager(cfg.Network, logger)
+	if err != nil {
+		t.Fatalf("network manager: %v", err)
+	}
+	defer networkMgr.Shutdown()
+
+	m := NewMachineManager(cfg.Firecracker, networkMgr, logger)
+	defer m.Shutdown(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	vm, err := m.RestoreVM(ctx, snapshotPath, nil)
+	if err != nil {
+		t.Fatalf("RestoreVM: %v", err)
+	}
+	if vm.State != VMStateRunning || vm.RestoreDuration <= 0 {
+		t.Errorf("restored vm state=%s restore=%v, want running with measured duration", vm.State, vm.RestoreDuration)
+	}
+	if _, ok := m.GetVM(vm.ID); !ok {
+		t.Error("restored VM is not registered in the manager")
+	}
+	t.Logf("restored %s in %v", vm.ID, vm.RestoreDuration)
+
+	if err := m.StopVM(context.Background(), vm.ID); err != nil {
+		t.Errorf("StopVM: %v", err)
+	}
+}
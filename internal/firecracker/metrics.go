@@ -0,0 +1,295 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// jailMetricsPath 启用 jailer 时 metrics FIFO 在 chroot 内的路径
+const jailMetricsPath = "/metrics.fifo"
+
+// metricsLineMaxBytes 单条 metrics 文档的最大长度，Firecracker 每次刷新输出一行 JSON
+const metricsLineMaxBytes = 1 << 20
+
+// clockTicksPerSecond /proc/<pid>/stat 中 CPU 时间的单位（USER_HZ），Linux 上固定为 100
+const clockTicksPerSecond = 100
+
+// VMMetrics 是单个虚拟机的累计运行指标。
+// 计数器来自 Firecracker metrics FIFO，Firecracker 每次刷新（默认 60 秒）输出自上次刷新以来的增量，
+// 这里累加为虚拟机启动以来的总量；CPU 时间读取自 VMM 进程的 /proc/<pid>/stat。
+type VMMetrics struct {
+	VMID    string `json:"vm_id"`
+	Runtime string `json:"runtime"`
+
+	CPUTimeMs int64 `json:"cpu_time_ms"` // VMM 进程（含 vCPU 线程）累计 CPU 时间（毫秒）
+	VCPUExits int64 `json:"vcpu_exits"`  // vCPU 因 IO/MMIO 退出到 VMM 的次数
+
+	BlockReadBytes  int64 `json:"block_read_bytes"`
+	BlockWriteBytes int64 `json:"block_write_bytes"`
+	BlockReadCount  int64 `json:"block_read_count"`
+	BlockWriteCount int64 `json:"block_write_count"`
+
+	NetRxBytes   int64 `json:"net_rx_bytes"`
+	NetTxBytes   int64 `json:"net_tx_bytes"`
+	NetRxPackets int64 `json:"net_rx_packets"`
+	NetTxPackets int64 `json:"net_tx_packets"`
+
+	ThrottledEvents int64 `json:"throttled_events"` // 磁盘和网卡限速器触发的节流次数
+
+	UpdatedAt time.Time `json:"updated_at"` // 最近一次收到 metrics 的时间，尚未收到时为零值
+}
+
+// add 将一次刷新的增量累加到总量中
+func (v *VMMetrics) add(delta VMMetrics) {
+	v.VCPUExits += delta.VCPUExits
+	v.BlockReadBytes += delta.BlockReadBytes
+	v.BlockWriteBytes += delta.BlockWriteBytes
+	v.BlockReadCount += delta.BlockReadCount
+	v.BlockWriteCount += delta.BlockWriteCount
+	v.NetRxBytes += delta.NetRxBytes
+	v.NetTxBytes += delta.NetTxBytes
+	v.NetRxPackets += delta.NetRxPackets
+	v.NetTxPackets += delta.NetTxPackets
+	v.ThrottledEvents += delta.ThrottledEvents
+}
+
+// firecrackerMetricsDocument 是 Firecracker metrics 文档中用到的部分字段。
+// block 和 net 是所有磁盘、网卡的汇总值，按设备区分的 block_<id>、net_<id> 不重复统计。
+type firecrackerMetricsDocument struct {
+	Block struct {
+		ReadBytes                  int64 `json:"read_bytes"`
+		WriteBytes                 int64 `json:"write_bytes"`
+		ReadCount                  int64 `json:"read_count"`
+		WriteCount                 int64 `json:"write_count"`
+		RateLimiterThrottledEvents int64 `json:"rate_limiter_throttled_events"`
+	} `json:"block"`
+	Net struct {
+		RxBytesCount           int64 `json:"rx_bytes_count"`
+		TxBytesCount           int64 `json:"tx_bytes_count"`
+		RxPacketsCount         int64 `json:"rx_packets_count"`
+		TxPacketsCount         int64 `json:"tx_packets_count"`
+		RxRateLimiterThrottled int64 `json:"rx_rate_limiter_throttled"`
+		TxRateLimiterThrottled int64 `json:"tx_rate_limiter_throttled"`
+	} `json:"net"`
+	Vcpu struct {
+		ExitIOIn      int64 `json:"exit_io_in"`
+		ExitIOOut     int64 `json:"exit_io_out"`
+		ExitMMIORead  int64 `json:"exit_mmio_read"`
+		ExitMMIOWrite int64 `json:"exit_mmio_write"`
+	} `json:"vcpu"`
+}
+
+// parseFirecrackerMetrics 解析一条 Firecracker metrics 文档，返回本次刷新的增量
+func parseFirecrackerMetrics(data []byte) (VMMetrics, error) {
+	var doc firecrackerMetricsDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return VMMetrics{}, fmt.Errorf("parse firecracker metrics: %w", err)
+	}
+	return VMMetrics{
+		VCPUExits:       doc.Vcpu.ExitIOIn + doc.Vcpu.ExitIOOut + doc.Vcpu.ExitMMIORead + doc.Vcpu.ExitMMIOWrite,
+		BlockReadBytes:  doc.Block.ReadBytes,
+		BlockWriteBytes: doc.Block.WriteBytes,
+		BlockReadCount:  doc.Block.ReadCount,
+		BlockWriteCount: doc.Block.WriteCount,
+		NetRxBytes:      doc.Net.RxBytesCount,
+		NetTxBytes:      doc.Net.TxBytesCount,
+		NetRxPackets:    doc.Net.RxPacketsCount,
+		NetTxPackets:    doc.Net.TxPacketsCount,
+		ThrottledEvents: doc.Block.RateLimiterThrottledEvents + doc.Net.RxRateLimiterThrottled + doc.Net.TxRateLimiterThrottled,
+	}, nil
+}
+
+// parseProcStatCPUTime 从 /proc/<pid>/stat 的内容中解析进程的用户态和内核态 CPU 时间之和
+func parseProcStatCPUTime(stat string) (time.Duration, error) {
+	// 进程名可能包含空格和括号，从最后一个 ')' 之后开始按字段解析，
+	// 第一个字段为 state（第 3 列），utime 和 stime 分别为第 14、15 列
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("malformed proc stat")
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("malformed proc stat: %d fields", len(fields))
+	}
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse utime: %w", err)
+	}
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse stime: %w", err)
+	}
+	return time.Duration(utime+stime) * time.Second / clockTicksPerSecond, nil
+}
+
+// metricsCollector 从 metrics FIFO 读取 Firecracker 输出的指标并累加。
+type metricsCollector struct {
+	path string   // FIFO 在主机上的路径
+	fifo *os.File // 以读写方式打开，Firecracker 重新打开 FIFO 时读端不会收到 EOF
+
+	mu     sync.Mutex
+	totals VMMetrics
+}
+
+// newMetricsCollector 创建 metrics FIFO 并开始读取。
+// 参数：
+//   - path: FIFO 在主机上的路径
+//   - uid/gid: FIFO 的属主，需要与写入方 Firecracker 进程一致（jailer 降权后的用户）
+//   - logger: 日志记录器
+func newMetricsCollector(path string, uid, gid int, logger *logrus.Logger) (*metricsCollector, error) {
+	_ = os.Remove(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := unix.Mkfifo(path, 0600); err != nil {
+		return nil, fmt.Errorf("create metrics fifo: %w", err)
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("chown metrics fifo: %w", err)
+	}
+	fifo, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("open metrics fifo: %w", err)
+	}
+
+	c := &metricsCollector{path: path, fifo: fifo}
+	go c.run(logger)
+	return c, nil
+}
+
+// run 逐行读取 metrics 文档直到 FIFO 被关闭
+func (c *metricsCollector) run(logger *logrus.Logger) {
+	scanner := bufio.NewScanner(c.fifo)
+	scanner.Buffer(make([]byte, 64*1024), metricsLineMaxBytes)
+	for scanner.Scan() {
+		delta, err := parseFirecrackerMetrics(scanner.Bytes())
+		if err != nil {
+			logger.WithError(err).WithField("path", c.path).Debug("Skipping malformed firecracker metrics")
+			continue
+		}
+		c.mu.Lock()
+		c.totals.add(delta)
+		c.totals.UpdatedAt = time.Now()
+		c.mu.Unlock()
+	}
+}
+
+// snapshot 返回当前累计值的副本
+func (c *metricsCollector) snapshot() VMMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.totals
+}
+
+// close 停止读取并删除 FIFO
+func (c *metricsCollector) close() {
+	_ = c.fifo.Close()
+	_ = os.Remove(c.path)
+}
+
+// startMetrics 为虚拟机创建 metrics FIFO 并开始采集。
+// 采集失败只影响可观测性，记录警告后虚拟机照常启动，不配置 metrics 输出。
+func (m *MachineManager) startMetrics(vm *VM) {
+	path := filepath.Join(m.cfg.LogDir, vm.ID+".metrics")
+	uid, gid := os.Getuid(), os.Getgid()
+	if vm.jail != nil {
+		path = vm.jail.hostPath(jailMetricsPath)
+		uid, gid = vm.jail.uid, vm.jail.gid
+	}
+
+	collector, err := newMetricsCollector(path, uid, gid, m.logger)
+	if err != nil {
+		m.logger.WithError(err).WithField("vm_id", vm.ID).Warn("Failed to set up VM metrics, continuing without metrics")
+		return
+	}
+	vm.metrics = collector
+}
+
+// stopMetrics 停止采集并删除 metrics FIFO
+func (m *MachineManager) stopMetrics(vm *VM) {
+	if vm.metrics != nil {
+		vm.metrics.close()
+	}
+}
+
+// metricsPath 返回交给 Firecracker 的 metrics 输出路径，未启用采集时为空
+func (m *MachineManager) metricsPath(vm *VM) string {
+	if vm.metrics == nil {
+		return ""
+	}
+	if vm.jail != nil {
+		return jailMetricsPath
+	}
+	return vm.metrics.path
+}
+
+// useOwnMetricsFifo 移除 SDK 按配置路径创建日志和 metrics 文件的初始化步骤。
+// metrics FIFO 已由 startMetrics 创建（启用 jailer 时位于 chroot 内，SDK 无法从主机路径创建）。
+func useOwnMetricsFifo(machine *firecracker.Machine) {
+	machine.Handlers.FcInit = machine.Handlers.FcInit.Remove(firecracker.CreateLogFilesHandlerName)
+}
+
+// GetVMMetrics 获取虚拟机启动以来的累计运行指标。
+// 参数：
+//   - vmID: 虚拟机 ID
+//
+// 返回：
+//   - *VMMetrics: 累计指标，Firecracker 首次刷新 metrics 前计数器为 0
+//   - error: 虚拟机不存在或未启用 metrics 采集时返回错误
+func (m *MachineManager) GetVMMetrics(vmID string) (*VMMetrics, error) {
+	m.mu.RLock()
+	vm, ok := m.vms[vmID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("vm not found: %s", vmID)
+	}
+	if vm.metrics == nil {
+		return nil, fmt.Errorf("metrics not available for vm: %s", vmID)
+	}
+
+	metrics := vm.metrics.snapshot()
+	metrics.VMID = vm.ID
+	metrics.Runtime = vm.Runtime
+
+	// CPU 时间直接读取 VMM 进程，不依赖 metrics 刷新周期
+	if vm.machine != nil {
+		if pid, err := vm.machine.PID(); err == nil {
+			if stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil {
+				if cpu, err := parseProcStatCPUTime(string(stat)); err == nil {
+					metrics.CPUTimeMs = cpu.Milliseconds()
+				}
+			}
+		}
+	}
+	return &metrics, nil
+}
+
+// ListVMMetrics 返回所有启用了 metrics 采集的虚拟机的累计指标
+func (m *MachineManager) ListVMMetrics() []VMMetrics {
+	vms := m.ListVMs()
+	result := make([]VMMetrics, 0, len(vms))
+	for _, vm := range vms {
+		metrics, err := m.GetVMMetrics(vm.ID)
+		if err != nil {
+			continue
+		}
+		result = append(result, *metrics)
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// sampleMetrics 是 Firecracker 刷新一次 metrics 输出的文档（节选常用分组，数值为一次刷新的增量）
+const sampleMetrics = `{"utc_timestamp_ms":1718000000123,"api_server":{"process_startup_time_us":12,"process_startup_time_cpu_us":10,"sync_response_fails":0,"sync_vmm_send_timeout_count":0},"balloon":{"activate_fails":0,"inflate_count":1,"stats_updates_count":0,"stats_update_fails":0,"deflate_count":0,"event_fails":0},"block":{"activate_fails":0,"cfg_fails":0,"no_avail_buffer":3,"event_fails":0,"execute_fails":0,"invalid_reqs_count":0,"flush_count":2,"queue_event_count":57,"rate_limiter_event_count":0,"update_count":0,"update_fails":0,"read_bytes":1048576,"write_bytes":262144,"read_count":40,"write_count":12,"rate_limiter_throttled_events":2},"block_rootfs":{"activate_fails":0,"cfg_fails":0,"no_avail_buffer":3,"event_fails":0,"execute_fails":0,"invalid_reqs_count":0,"flush_count":2,"queue_event_count":57,"rate_limiter_event_count":0,"update_count":0,"update_fails":0,"read_bytes":1048576,"write_bytes":262144,"read_count":40,"write_count":12,"rate_limiter_throttled_events":2},"i8042":{"error_count":0,"missed_read_count":0,"missed_write_count":0,"read_count":0,"reset_count":0,"write_count":0},"latencies_us":{"full_create_snapshot":0,"diff_create_snapshot":0,"load_snapshot":0,"pause_vm":0,"resume_vm":0,"vmm_full_create_snapshot":0,"vmm_diff_create_snapshot":0,"vmm_load_snapshot":0,"vmm_pause_vm":0,"vmm_resume_vm":0},"net":{"activate_fails":0,"cfg_fails":0,"mac_address_updates":0,"no_rx_avail_buffer":0,"no_tx_avail_buffer":0,"event_fails":0,"rx_queue_event_count":4,"rx_event_rate_limiter_count":0,"rx_partial_writes":0,"rx_rate_limiter_throttled":1,"rx_tap_event_count":6,"rx_bytes_count":4096,"rx_packets_count":8,"rx_fails":0,"rx_count":8,"tap_read_fails":0,"tap_write_fails":0,"tx_bytes_count":2048,"tx_malformed_frames":0,"tx_fails":0,"tx_count":5,"tx_packets_count":5,"tx_partial_reads":0,"tx_queue_event_count":5,"tx_rate_limiter_event_count":0,"tx_rate_limiter_throttled":0,"tx_spoofed_mac_count":0},"net_eth0":{"rx_bytes_count":4096,"tx_bytes_count":2048},"seccomp":{"num_faults":0},"vcpu":{"exit_io_in":120,"exit_io_out":340,"exit_mmio_read":15,"exit_mmio_write":25,"failures":0},"vmm":{"device_events":10,"panic_count":0},"uart":{"error_count":0,"flush_count":0,"missed_read_count":0,"missed_write_count":0,"read_count":0,"write_count":512},"signals":{"sigbus":0,"sigsegv":0},"vsock":{"activate_fails":0,"cfg_fails":0,"rx_queue_event_fails":0,"tx_queue_event_fails":0,"ev_queue_event_fails":0,"muxer_event_fails":0,"conn_event_fails":0,"rx_queue_event_count":9,"tx_queue_event_count":9,"rx_bytes_count":1500,"tx_bytes_count":900,"rx_packets_count":9,"tx_packets_count":9,"conns_added":1,"conns_killed":0,"conns_removed":0,"killq_resync":0,"tx_flush_fails":0,"tx_write_fails":0,"rx_read_fails":0}}`
+
+func TestParseFirecrackerMetrics(t *testing.T) {
+	got, err := parseFirecrackerMetrics([]byte(sampleMetrics))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := VMMetrics{
+		VCPUExits:       500,
+		BlockReadBytes:  1048576,
+		BlockWriteBytes: 262144,
+		BlockReadCount:  40,
+		BlockWriteCount: 12,
+		NetRxBytes:      4096,
+		NetTxBytes:      2048,
+		NetRxPackets:    8,
+		NetTxPackets:    5,
+		ThrottledEvents: 3,
+	}
+	if got != want {
+		t.Errorf("parsed metrics =\n  %+v\nwant\n  %+v", got, want)
+	}
+
+	if _, err := parseFirecrackerMetrics([]byte("not json")); err == nil {
+		t.Error("expected error for malformed document")
+	}
+}
+
+func TestParseProcStatCPUTime(t *testing.T) {
+	// 进程名中包含空格和括号，utime=250、stime=50 个时钟周期
+	stat := "4242 (fire cracker) S 1 4242 4242 0 -1 4194560 1200 0 0 0 250 50 0 0 20 0 3 0 100 0 0"
+	got, err := parseProcStatCPUTime(stat)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got != 3*time.Second {
+		t.Errorf("cpu time = %s, want 3s", got)
+	}
+}
+
+func TestMetricsCollectorAccumulatesFlushes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vm-1.metrics")
+	c, err := newMetricsCollector(path, os.Getuid(), os.Getgid(), testLogger())
+	if err != nil {
+		t.Fatalf("newMetricsCollector: %v", err)
+	}
+	defer c.close()
+
+	// 模拟 Firecracker 两次刷新 metrics
+	w, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := w.WriteString(sampleMetrics + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for c.snapshot().NetRxBytes != 2*4096 {
+		if time.Now().After(deadline) {
+			t.Fatalf("totals = %+v, want two flushes accumulated", c.snapshot())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := c.snapshot(); got.VCPUExits != 1000 || got.UpdatedAt.IsZero() {
+		t.Errorf("totals = %+v", got)
+	}
+}
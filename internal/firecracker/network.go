@@ -27,6 +27,10 @@ type NetworkConfig struct {
 	GuestIP    string // 虚拟机内部的 IP 地址
 	GatewayIP  string // 网关 IP 地址（通常是网桥地址）
 	SubnetMask string // 子网掩码
+
+	GuestIPv6     string // 虚拟机的 IPv6 地址，未启用 IPv6 时为空
+	GatewayIPv6   string // IPv6 网关地址（网桥的 IPv6 地址）
+	IPv6PrefixLen int    // 虚拟机 IPv6 前缀长度
 }
 
 // NetworkManager 管理 Firecracker 虚拟机的网络配置。
@@ -45,6 +49,8 @@ type NetworkManager struct {
 	subnetLast  uint32     // 最后一个可用主机地址（含）
 	nextIP      uint32     // 下一次尝试分配的 IP（uint32）
 	netmask     string     // 子网掩码（点分十进制）
+
+	ipv6 *ipv6Allocator // IPv6 前缀分配器，未启用 IPv6 时为 nil
 }
 
 // NewNetworkManager 创建新的网络管理器。
@@ -79,11 +85,22 @@ func NewNetworkManager(cfg config.NetworkConfig, logger *logrus.Logger) (*Networ
 		nextIP:      first, // 从子网第一个可用地址开始，分配时会跳过网关等保留地址
 		netmask:     netmask,
 	}
+	if cfg.EnableIPv6 {
+		nm.ipv6, err = newIPv6Allocator(cfg.IPv6SubnetCIDR, cfg.IPv6VMPrefixLen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ipv6_subnet_cidr %q: %w", cfg.IPv6SubnetCIDR, err)
+		}
+	}
 
 	// 初始化网桥（如果不存在）
 	if err := nm.setupBridge(); err != nil {
 		return nil, fmt.Errorf("failed to setup bridge: %w", err)
 	}
+	if nm.ipv6 != nil {
+		if err := nm.setupBridgeIPv6(); err != nil {
+			return nil, fmt.Errorf("failed to setup bridge: %w", err)
+		}
+	}
 
 	return nm, nil
 }
@@ -160,7 +177,7 @@ func (nm *NetworkManager) setupNAT() error {
 }
 
 // SetupNetwork 为指定的虚拟机配置网络。
-// 创建 TAP 设备并分配 IP 地址。
+// 创建 TAP 设备并分配 IP 地址，启用 IPv6 时同时分配 IPv6 前缀。
 // 参数：
 //   - vmID: 虚拟机的唯一标识符
 //
@@ -199,6 +216,16 @@ func (nm *NetworkManager) SetupNetwork(vmID string) (*NetworkConfig, error) {
 		return nil, err
 	}
 
+	// 启用 IPv6 时分配虚拟机独占的前缀
+	var lease *ipv6Lease
+	if nm.ipv6 != nil {
+		lease, err = nm.setupVMIPv6Locked(vmID)
+		if err != nil {
+			exec.Command("ip", "link", "del", tapName).Run()
+			return nil, err
+		}
+	}
+
 	// 生成随机 MAC 地址
 	mac := nm.generateMAC()
 
@@ -214,11 +241,17 @@ func (nm *NetworkManager) SetupNetwork(vmID string) (*NetworkConfig, error) {
 		GatewayIP:  nm.cfg.BridgeIP,
 		SubnetMask: nm.netmask,
 	}
+	if lease != nil {
+		config.GuestIPv6 = lease.Guest.String()
+		config.GatewayIPv6 = nm.ipv6.gateway().IP.String()
+		config.IPv6PrefixLen = nm.ipv6.prefixLen
+	}
 
 	nm.logger.WithFields(logrus.Fields{
 		"vm_id": vmID,
 		"tap":   tapName,
 		"ip":    guestIP,
+		"ipv6":  config.GuestIPv6,
 		"mac":   mac,
 	}).Debug("Network configured for VM")
 
@@ -226,7 +259,7 @@ func (nm *NetworkManager) SetupNetwork(vmID string) (*NetworkConfig, error) {
 }
 
 // CleanupNetwork 清理指定虚拟机的网络资源。
-// 删除 TAP 设备并释放 IP 地址。
+// 删除 TAP 设备并释放 IP 地址和 IPv6 前缀。
 func (nm *NetworkManager) CleanupNetwork(vmID string) error {
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
@@ -248,6 +281,7 @@ func (nm *NetworkManager) CleanupNetwork(vmID string) error {
 		delete(nm.usedIPs, ip)
 		delete(nm.ipByVMID, vmID)
 	}
+	nm.cleanupVMIPv6Locked(vmID)
 
 	nm.logger.WithFields(logrus.Fields{
 		"vm_id": vmID,
@@ -316,6 +350,7 @@ func (nm *NetworkManager) Shutdown() error {
 			delete(nm.usedIPs, ip)
 			delete(nm.ipByVMID, vmID)
 		}
+		nm.cleanupVMIPv6Locked(vmID)
 	}
 
 	return nil
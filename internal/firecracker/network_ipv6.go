@@ -0,0 +1,182 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"os/exec"
+)
+
+// ipv6Lease 表示分配给一个虚拟机的 IPv6 前缀
+type ipv6Lease struct {
+	Prefix *net.IPNet // 虚拟机独占的前缀，宿主机将其路由到网桥
+	Guest  net.IP     // 虚拟机使用的地址（前缀内第一个地址）
+}
+
+// ipv6Allocator 从父前缀中按虚拟机分配等长的子前缀。
+// 第 0 个子前缀保留给网桥（其第一个地址作为网关），其余子前缀逐个分配给虚拟机，
+// 虚拟机销毁后子前缀回收复用。分配状态只保存在内存中，由 NetworkManager 的锁保护。
+type ipv6Allocator struct {
+	parent    *net.IPNet        // 父前缀
+	prefixLen int               // 子前缀长度
+	count     uint64            // 子前缀总数（含保留给网桥的第 0 个）
+	next      uint64            // 下一次尝试分配的子前缀序号
+	used      map[uint64]bool   // 已分配的子前缀序号
+	byVMID    map[string]uint64 // vmID -> 子前缀序号
+}
+
+// newIPv6Allocator 解析父前缀并创建分配器。
+// 参数：
+//   - cidr: 父前缀，如 "fd00:172:20::/48"
+//   - prefixLen: 每个虚拟机的前缀长度，需比父前缀更长且不超过 /126
+//
+// 返回：
+//   - *ipv6Allocator: 分配器
+//   - error: 前缀无效或可分配的子前缀不足时返回错误
+func newIPv6Allocator(cidr string, prefixLen int) (*ipv6Allocator, error) {
+	ip, parent, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := parent.Mask.Size()
+	if ip.To4() != nil || bits != 128 {
+		return nil, fmt.Errorf("not an IPv6 prefix")
+	}
+	if prefixLen <= ones || prefixLen > 126 {
+		return nil, fmt.Errorf("vm prefix length /%d must be longer than /%d and at most /126", prefixLen, ones)
+	}
+	// 子前缀序号使用 uint64 记录，限制可分配数量避免溢出
+	if prefixLen-ones > 32 {
+		return nil, fmt.Errorf("too many vm prefixes: /%d in /%d", prefixLen, ones)
+	}
+	return &ipv6Allocator{
+		parent:    parent,
+		prefixLen: prefixLen,
+		count:     1 << uint(prefixLen-ones),
+		next:      1, // 第 0 个子前缀保留给网桥
+		used:      make(map[uint64]bool),
+		byVMID:    make(map[string]uint64),
+	}, nil
+}
+
+// prefixAt 返回第 index 个子前缀
+func (a *ipv6Allocator) prefixAt(index uint64) *net.IPNet {
+	base := new(big.Int).SetBytes(a.parent.IP.To16())
+	offset := new(big.Int).Lsh(new(big.Int).SetUint64(index), uint(128-a.prefixLen))
+	return &net.IPNet{
+		IP:   bigToIPv6(base.Add(base, offset)),
+		Mask: net.CIDRMask(a.prefixLen, 128),
+	}
+}
+
+// gateway 返回网桥的 IPv6 地址（保留子前缀内的第一个地址）
+func (a *ipv6Allocator) gateway() *net.IPNet {
+	prefix := a.prefixAt(0)
+	return &net.IPNet{IP: firstHostIPv6(prefix), Mask: prefix.Mask}
+}
+
+// allocate 为虚拟机分配一个未占用的子前缀，同一虚拟机重复分配时返回已有前缀
+func (a *ipv6Allocator) allocate(vmID string) (*ipv6Lease, error) {
+	if index, ok := a.byVMID[vmID]; ok {
+		return a.lease(index), nil
+	}
+
+	// 完整遍历一轮，找不到则认为耗尽
+	start := a.next
+	for {
+		candidate := a.next
+		a.next++
+		if a.next >= a.count {
+			a.next = 1
+		}
+		if !a.used[candidate] {
+			a.used[candidate] = true
+			a.byVMID[vmID] = candidate
+			return a.lease(candidate), nil
+		}
+		if a.next == start {
+			return nil, fmt.Errorf("no available IPv6 prefixes in %s", a.parent)
+		}
+	}
+}
+
+// release 回收虚拟机的子前缀，返回被回收的前缀，未分配时返回 nil
+func (a *ipv6Allocator) release(vmID string) *ipv6Lease {
+	index, ok := a.byVMID[vmID]
+	if !ok {
+		return nil
+	}
+	delete(a.byVMID, vmID)
+	delete(a.used, index)
+	return a.lease(index)
+}
+
+// lease 构建第 index 个子前缀对应的分配结果
+func (a *ipv6Allocator) lease(index uint64) *ipv6Lease {
+	prefix := a.prefixAt(index)
+	return &ipv6Lease{Prefix: prefix, Guest: firstHostIPv6(prefix)}
+}
+
+// firstHostIPv6 返回前缀内的第一个地址（前缀地址加 1）
+func firstHostIPv6(prefix *net.IPNet) net.IP {
+	n := new(big.Int).SetBytes(prefix.IP.To16())
+	return bigToIPv6(n.Add(n, big.NewInt(1)))
+}
+
+// bigToIPv6 将整数转换为 16 字节的 IPv6 地址
+func bigToIPv6(n *big.Int) net.IP {
+	ip := make(net.IP, net.IPv6len)
+	n.FillBytes(ip)
+	return ip
+}
+
+// setupBridgeIPv6 为网桥配置 IPv6 网关地址并开启 IPv6 转发。
+// 网桥已存在时也会执行，地址使用 replace 保证幂等。
+func (nm *NetworkManager) setupBridgeIPv6() error {
+	gw := nm.ipv6.gateway()
+	if out, err := exec.Command("ip", "-6", "addr", "replace", gw.String(), "dev", nm.cfg.BridgeName).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set bridge IPv6 address: %w: %s", err, out)
+	}
+	if err := exec.Command("sysctl", "-w", "net.ipv6.conf.all.forwarding=1").Run(); err != nil {
+		nm.logger.WithError(err).Warn("Failed to enable IPv6 forwarding")
+	}
+	if nm.cfg.UseNAT && nm.cfg.ExternalInterface != "" {
+		if err := exec.Command("ip6tables", "-t", "nat", "-A", "POSTROUTING",
+			"-s", nm.ipv6.parent.String(), "-o", nm.cfg.ExternalInterface, "-j", "MASQUERADE").Run(); err != nil {
+			nm.logger.WithError(err).Warn("Failed to setup IPv6 NAT")
+		}
+	}
+	return nil
+}
+
+// setupVMIPv6Locked 为虚拟机分配 IPv6 前缀，并把该前缀路由到网桥（TAP 设备所在的二层网络）。
+// 必须在 nm.mu 持有状态下调用。
+func (nm *NetworkManager) setupVMIPv6Locked(vmID string) (*ipv6Lease, error) {
+	lease, err := nm.ipv6.allocate(vmID)
+	if err != nil {
+		return nil, err
+	}
+	if out, err := exec.Command("ip", "-6", "route", "replace", lease.Prefix.String(), "dev", nm.cfg.BridgeName).CombinedOutput(); err != nil {
+		nm.ipv6.release(vmID)
+		return nil, fmt.Errorf("failed to route IPv6 prefix: %w: %s", err, out)
+	}
+	return lease, nil
+}
+
+// cleanupVMIPv6Locked 删除虚拟机 IPv6 前缀的路由并回收前缀。
+// 必须在 nm.mu 持有状态下调用。
+func (nm *NetworkManager) cleanupVMIPv6Locked(vmID string) {
+	if nm.ipv6 == nil {
+		return
+	}
+	lease := nm.ipv6.release(vmID)
+	if lease == nil {
+		return
+	}
+	if err := exec.Command("ip", "-6", "route", "del", lease.Prefix.String(), "dev", nm.cfg.BridgeName).Run(); err != nil {
+		nm.logger.WithError(err).WithField("prefix", lease.Prefix.String()).Warn("Failed to delete IPv6 route")
+	}
+}
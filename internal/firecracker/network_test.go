@@ -0,0 +1,106 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewIPv6AllocatorValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		cidr      string
+		prefixLen int
+		wantErr   bool
+	}{
+		{name: "valid", cidr: "fd00:172:20::/48", prefixLen: 64},
+		{name: "ipv4 prefix", cidr: "172.20.0.0/16", prefixLen: 24, wantErr: true},
+		{name: "vm prefix not longer", cidr: "fd00::/64", prefixLen: 64, wantErr: true},
+		{name: "vm prefix too long", cidr: "fd00::/120", prefixLen: 127, wantErr: true},
+		{name: "too many prefixes", cidr: "fd00::/16", prefixLen: 64, wantErr: true},
+		{name: "invalid cidr", cidr: "not-a-prefix", prefixLen: 64, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newIPv6Allocator(tt.cidr, tt.prefixLen)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newIPv6Allocator(%q, %d) error = %v, wantErr %v", tt.cidr, tt.prefixLen, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIPv6AllocatorAllocateAndRecycle(t *testing.T) {
+	a, err := newIPv6Allocator("fd00:172:20::/62", 64)
+	if err != nil {
+		t.Fatalf("newIPv6Allocator: %v", err)
+	}
+	if got := a.gateway().String(); got != "fd00:172:20::1/64" {
+		t.Errorf("gateway = %s, want fd00:172:20::1/64", got)
+	}
+
+	// /62 内共 4 个 /64，第 0 个保留给网桥，可分配 3 个
+	want := map[string]string{
+		"vm-a": "fd00:172:20:1::/64",
+		"vm-b": "fd00:172:20:2::/64",
+		"vm-c": "fd00:172:20:3::/64",
+	}
+	for _, vmID := range []string{"vm-a", "vm-b", "vm-c"} {
+		lease, err := a.allocate(vmID)
+		if err != nil {
+			t.Fatalf("allocate %s: %v", vmID, err)
+		}
+		if lease.Prefix.String() != want[vmID] {
+			t.Errorf("%s prefix = %s, want %s", vmID, lease.Prefix, want[vmID])
+		}
+		if !lease.Prefix.Contains(lease.Guest) || lease.Guest.Equal(lease.Prefix.IP) {
+			t.Errorf("%s guest address %s should be a host address in %s", vmID, lease.Guest, lease.Prefix)
+		}
+	}
+
+	// 重复分配返回已有前缀
+	if lease, err := a.allocate("vm-b"); err != nil || lease.Prefix.String() != want["vm-b"] {
+		t.Errorf("re-allocate vm-b = %v, %v", lease, err)
+	}
+	if _, err := a.allocate("vm-d"); err == nil {
+		t.Fatal("expected exhaustion error")
+	}
+
+	// 回收后前缀可以分配给新虚拟机
+	if lease := a.release("vm-b"); lease == nil || lease.Prefix.String() != want["vm-b"] {
+		t.Fatalf("release vm-b = %v", lease)
+	}
+	if lease := a.release("vm-b"); lease != nil {
+		t.Errorf("second release = %v, want nil", lease)
+	}
+	lease, err := a.allocate("vm-d")
+	if err != nil {
+		t.Fatalf("allocate after release: %v", err)
+	}
+	if lease.Prefix.String() != want["vm-b"] {
+		t.Errorf("vm-d prefix = %s, want recycled %s", lease.Prefix, want["vm-b"])
+	}
+	if len(a.used) != 3 || len(a.byVMID) != 3 {
+		t.Errorf("bookkeeping: used=%d byVMID=%d, want 3/3", len(a.used), len(a.byVMID))
+	}
+}
+
+func TestBuildKernelArgsIPv6(t *testing.T) {
+	m := &MachineManager{}
+	netConfig := &NetworkConfig{GuestIP: "172.20.0.2", GatewayIP: "172.20.0.1", SubnetMask: "255.255.0.0"}
+	if args := m.buildKernelArgs(netConfig); strings.Contains(args, "nimbus.ipv6") {
+		t.Errorf("unexpected IPv6 boot args without IPv6: %s", args)
+	}
+
+	netConfig.GuestIPv6 = "fd00:172:20:1::1"
+	netConfig.GatewayIPv6 = "fd00:172:20::1"
+	netConfig.IPv6PrefixLen = 64
+	args := m.buildKernelArgs(netConfig)
+	for _, want := range []string{"ip=172.20.0.2::172.20.0.1:255.255.0.0::eth0:off", "nimbus.ipv6=fd00:172:20:1::1/64", "nimbus.gw6=fd00:172:20::1"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("kernel args %q missing %q", args, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	ops "github.com/firecracker-microvm/firecracker-go-sdk/client/operations"
+	"github.com/sirupsen/logrus"
+)
+
+// rateLimiterRefillMs 令牌桶补满一次的时间（毫秒）。
+// 桶容量按每秒配额计算、每秒补满，既限制平均速率，也允许一秒内的突发。
+const rateLimiterRefillMs = 1000
+
+// rootfsDriveID 根文件系统磁盘的设备 ID，与 buildFirecrackerConfig 中的配置一致
+const rootfsDriveID = "rootfs"
+
+// netIfaceID 网卡的设备 ID，SDK 按网卡序号从 1 开始编号
+const netIfaceID = "1"
+
+// RateLimits 描述虚拟机的磁盘和网络限速，各项为 0 表示不限速。
+// 网络限速对收、发两个方向分别生效。
+type RateLimits struct {
+	DiskMBps float64 // 根文件系统读写带宽上限（MB/s）
+	DiskIOPS int     // 根文件系统每秒读写操作数上限
+	NetMBps  float64 // 网卡单方向带宽上限（MB/s）
+	NetPPS   int     // 网卡单方向每秒包数上限
+}
+
+// IsZero 判断是否未设置任何限速
+func (l RateLimits) IsZero() bool {
+	return l == RateLimits{}
+}
+
+// mbpsToBytes 将 MB/s 转换为每秒字节数，负数按 0 处理
+func mbpsToBytes(mbps float64) int64 {
+	if mbps <= 0 {
+		return 0
+	}
+	return int64(mbps * 1024 * 1024)
+}
+
+// tokenBucket 构建每秒补满的令牌桶，容量为每秒配额
+func tokenBucket(perSecond int64) *models.TokenBucket {
+	if perSecond < 0 {
+		perSecond = 0
+	}
+	return &models.TokenBucket{
+		Size:       firecracker.Int64(perSecond),
+		RefillTime: firecracker.Int64(rateLimiterRefillMs),
+	}
+}
+
+// buildRateLimiter 构建启动时挂载到设备上的限速器。
+// 只为设置了配额的维度创建令牌桶，两个维度都未设置时返回 nil，设备不限速。
+// 参数：
+//   - bytesPerSec: 每秒字节数上限，0 表示不限
+//   - opsPerSec: 每秒操作数（磁盘 IO 或网络包）上限，0 表示不限
+func buildRateLimiter(bytesPerSec, opsPerSec int64) *models.RateLimiter {
+	if bytesPerSec <= 0 && opsPerSec <= 0 {
+		return nil
+	}
+	limiter := &models.RateLimiter{}
+	if bytesPerSec > 0 {
+		limiter.Bandwidth = tokenBucket(bytesPerSec)
+	}
+	if opsPerSec > 0 {
+		limiter.Ops = tokenBucket(opsPerSec)
+	}
+	return limiter
+}
+
+// patchRateLimiter 构建运行时更新用的限速器。
+// 更新请求中省略的令牌桶会保持原值，因此两个维度都显式下发，容量为 0 的令牌桶表示取消该维度的限速。
+func patchRateLimiter(bytesPerSec, opsPerSec int64) *models.RateLimiter {
+	return &models.RateLimiter{
+		Bandwidth: tokenBucket(bytesPerSec),
+		Ops:       tokenBucket(opsPerSec),
+	}
+}
+
+// diskLimiter 返回根文件系统磁盘的启动限速器，未限速时为 nil
+func (l RateLimits) diskLimiter() *models.RateLimiter {
+	return buildRateLimiter(mbpsToBytes(l.DiskMBps), int64(l.DiskIOPS))
+}
+
+// netLimiter 返回网卡单方向的启动限速器，未限速时为 nil
+func (l RateLimits) netLimiter() *models.RateLimiter {
+	return buildRateLimiter(mbpsToBytes(l.NetMBps), int64(l.NetPPS))
+}
+
+// UpdateRateLimits 调整运行中虚拟机的磁盘和网络限速。
+// 预热虚拟机按运行时默认限速创建，被某个函数取用时按该函数的限速调整；
+// 与当前限速相同时不调用 Firecracker API。
+// 参数：
+//   - ctx: 上下文
+//   - vmID: 虚拟机 ID
+//   - limits: 新的限速，各项为 0 表示取消该项限速
+//
+// 返回：
+//   - error: 虚拟机不存在或调用 Firecracker API 失败时返回错误
+func (m *MachineManager) UpdateRateLimits(ctx context.Context, vmID string, limits RateLimits) error {
+	m.mu.RLock()
+	vm, ok := m.vms[vmID]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("vm not found: %s", vmID)
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.machine == nil {
+		return fmt.Errorf("vm not running: %s", vmID)
+	}
+	if vm.Limits == limits {
+		return nil
+	}
+
+	if limits.DiskMBps != vm.Limits.DiskMBps || limits.DiskIOPS != vm.Limits.DiskIOPS {
+		disk := patchRateLimiter(mbpsToBytes(limits.DiskMBps), int64(limits.DiskIOPS))
+		// 路径留空只更新限速器，不替换磁盘
+		if err := vm.machine.UpdateGuestDrive(ctx, rootfsDriveID, "", func(params *ops.PatchGuestDriveByIDParams) {
+			params.Body.RateLimiter = disk
+		}); err != nil {
+			return fmt.Errorf("failed to update drive rate limiter: %w", err)
+		}
+	}
+
+	if limits.NetMBps != vm.Limits.NetMBps || limits.NetPPS != vm.Limits.NetPPS {
+		net := patchRateLimiter(mbpsToBytes(limits.NetMBps), int64(limits.NetPPS))
+		if err := vm.machine.UpdateGuestNetworkInterfaceRateLimit(ctx, netIfaceID, firecracker.RateLimiterSet{
+			InRateLimiter:  net,
+			OutRateLimiter: net,
+		}); err != nil {
+			return fmt.Errorf("failed to update network rate limiter: %w", err)
+		}
+	}
+	vm.Limits = limits
+
+	m.logger.WithFields(logrus.Fields{
+		"vm_id":     vmID,
+		"disk_mbps": limits.DiskMBps,
+		"disk_iops": limits.DiskIOPS,
+		"net_mbps":  limits.NetMBps,
+		"net_pps":   limits.NetPPS,
+	}).Debug("VM rate limits updated")
+	return nil
+}
@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	"github.com/oriys/nimbus/internal/config"
+)
+
+// bucketValues 返回令牌桶的容量和补满时间，nil 时返回 -1
+func bucketValues(b *models.TokenBucket) (size, refill int64) {
+	if b == nil {
+		return -1, -1
+	}
+	return *b.Size, *b.RefillTime
+}
+
+func TestBuildRateLimiterConversion(t *testing.T) {
+	tests := []struct {
+		name      string
+		limits    RateLimits
+		wantBW    int64
+		wantOps   int64
+		wantNilRL bool
+		diskOrNet string
+	}{
+		{name: "unlimited", limits: RateLimits{}, wantNilRL: true, diskOrNet: "disk"},
+		{name: "disk bandwidth", limits: RateLimits{DiskMBps: 10}, wantBW: 10 * 1024 * 1024, wantOps: -1, diskOrNet: "disk"},
+		{name: "fractional MBps", limits: RateLimits{DiskMBps: 0.5}, wantBW: 512 * 1024, wantOps: -1, diskOrNet: "disk"},
+		{name: "disk iops", limits: RateLimits{DiskIOPS: 500}, wantBW: -1, wantOps: 500, diskOrNet: "disk"},
+		{name: "net both", limits: RateLimits{NetMBps: 2, NetPPS: 1000}, wantBW: 2 * 1024 * 1024, wantOps: 1000, diskOrNet: "net"},
+		{name: "negative ignored", limits: RateLimits{NetMBps: -1}, wantNilRL: true, diskOrNet: "net"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := tt.limits.diskLimiter()
+			if tt.diskOrNet == "net" {
+				rl = tt.limits.netLimiter()
+			}
+			if tt.wantNilRL {
+				if rl != nil {
+					t.Fatalf("limiter = %+v, want nil", rl)
+				}
+				return
+			}
+			if rl == nil {
+				t.Fatal("limiter is nil")
+			}
+			size, refill := bucketValues(rl.Bandwidth)
+			if size != tt.wantBW || (size >= 0 && refill != rateLimiterRefillMs) {
+				t.Errorf("bandwidth bucket = (%d, %d), want (%d, %d)", size, refill, tt.wantBW, rateLimiterRefillMs)
+			}
+			size, refill = bucketValues(rl.Ops)
+			if size != tt.wantOps || (size >= 0 && refill != rateLimiterRefillMs) {
+				t.Errorf("ops bucket = (%d, %d), want (%d, %d)", size, refill, tt.wantOps, rateLimiterRefillMs)
+			}
+		})
+	}
+}
+
+func TestPatchRateLimiterClearsUnsetBuckets(t *testing.T) {
+	// 运行时更新必须显式下发两个令牌桶，容量 0 表示取消限速
+	rl := patchRateLimiter(mbpsToBytes(1), 0)
+	if size, _ := bucketValues(rl.Bandwidth); size != 1024*1024 {
+		t.Errorf("bandwidth size = %d, want %d", size, 1024*1024)
+	}
+	if size, refill := bucketValues(rl.Ops); size != 0 || refill != rateLimiterRefillMs {
+		t.Errorf("ops bucket = (%d, %d), want (0, %d)", size, refill, rateLimiterRefillMs)
+	}
+}
+
+func TestBuildFirecrackerConfigAttachesRateLimiters(t *testing.T) {
+	m := NewMachineManager(config.FirecrackerConfig{}, nil, testLogger())
+	netConfig := &NetworkConfig{TapDevice: "tap0", MacAddress: "AA:FC:00:00:00:01", GuestIP: "172.16.0.2", GatewayIP: "172.16.0.1", SubnetMask: "255.255.255.0"}
+
+	vm := &VM{ID: "vm-1", MemoryMB: 128, VCPUs: 1}
+	cfg := m.buildFirecrackerConfig(vm, "/tmp/rootfs.ext4", netConfig)
+	if cfg.Drives[0].RateLimiter != nil || cfg.NetworkInterfaces[0].InRateLimiter != nil || cfg.NetworkInterfaces[0].OutRateLimiter != nil {
+		t.Error("no rate limiters expected without limits")
+	}
+
+	vm.Limits = RateLimits{DiskIOPS: 100, NetMBps: 4}
+	cfg = m.buildFirecrackerConfig(vm, "/tmp/rootfs.ext4", netConfig)
+	if size, _ := bucketValues(cfg.Drives[0].RateLimiter.Ops); size != 100 {
+		t.Errorf("drive ops size = %d, want 100", size)
+	}
+	iface := cfg.NetworkInterfaces[0]
+	for name, rl := range map[string]*models.RateLimiter{"in": iface.InRateLimiter, "out": iface.OutRateLimiter} {
+		if rl == nil {
+			t.Fatalf("%s rate limiter is nil", name)
+		}
+		if size, _ := bucketValues(rl.Bandwidth); size != 4*1024*1024 {
+			t.Errorf("%s bandwidth size = %d, want %d", name, size, 4*1024*1024)
+		}
+	}
+}
+
+func TestUpdateRateLimitsUnknownVM(t *testing.T) {
+	m := NewMachineManager(config.FirecrackerConfig{}, nil, testLogger())
+	if err := m.UpdateRateLimits(context.Background(), "missing", RateLimits{DiskMBps: 1}); err == nil {
+		t.Error("expected error for unknown VM")
+	}
+}
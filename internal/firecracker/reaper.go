@@ -0,0 +1,243 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 虚拟机被回收的原因
+const (
+	reapReasonStopped   = "stopped"    // 已停止或失败但仍在注册表中
+	reapReasonMaxReuses = "max_reuses" // 复用次数达到 MaxVMReuses
+	reapReasonIdle      = "idle"       // 空闲超过 IdleTimeout
+)
+
+// defaultIdleReapInterval 未配置 IdleReapInterval 时的回收检查间隔
+const defaultIdleReapInterval = 30 * time.Second
+
+// ErrVMReaped 虚拟机已被空闲回收，不能再交付
+var ErrVMReaped = errors.New("vm has been reaped")
+
+// errVMBusy 回收前复查时虚拟机已被取出执行调用
+var errVMBusy = errors.New("vm is in use")
+
+// MarkVMInUse 在虚拟机被取出执行调用时调用，记录使用时间和次数。
+// 使用中的虚拟机不会被空闲回收，排空时会等待它变为空闲。
+//
+// 返回：
+//   - error: 管理器正在排空时返回 ErrDraining，虚拟机正在被回收时返回 ErrVMReaped，
+//     两种情况下虚拟机都不会被标记为使用中
+func (m *MachineManager) MarkVMInUse(vmID string) error {
+	// 持有读锁直到标记完成，DrainAndShutdown 置位 draining 后看到的使用中虚拟机不会再增加
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.draining {
+		return ErrDraining
+	}
+	vm, ok := m.vms[vmID]
+	if !ok {
+		return nil
+	}
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.reaping {
+		return ErrVMReaped
+	}
+	vm.inUse = true
+	vm.UseCount++
+	vm.LastUsed = m.now()
+	return nil
+}
+
+// MarkVMIdle 在虚拟机执行完调用、回到预热池时调用，空闲时间从此刻开始计算。
+// 返回 true 表示虚拟机的复用次数已达到 MaxVMReuses，调用方应销毁它而不是放回预热池。
+func (m *MachineManager) MarkVMIdle(vmID string) bool {
+	vm, ok := m.GetVM(vmID)
+	if !ok {
+		return false
+	}
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.inUse = false
+	vm.LastUsed = m.now()
+	return m.cfg.MaxVMReuses > 0 && vm.UseCount >= m.cfg.MaxVMReuses
+}
+
+// OnVMReaped 设置虚拟机被空闲回收后的回调，虚拟机池通过它移除已停止的虚拟机
+func (m *MachineManager) OnVMReaped(fn func(vmID, runtime string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onReaped = fn
+}
+
+// StartReaper 启动后台空闲回收循环，每隔 IdleReapInterval 回收一次。
+// 参数：
+//   - minWarm: 每个运行时至少保留的空闲虚拟机数（通常为虚拟机池的 min_warm），避免与池的扩容互相抵消
+func (m *MachineManager) StartReaper(minWarm map[string]int) {
+	interval := m.cfg.IdleReapInterval
+	if interval <= 0 {
+		interval = defaultIdleReapInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.mu.Lock()
+	if m.reaperCancel != nil {
+		m.mu.Unlock()
+		cancel()
+		return
+	}
+	m.reaperCancel = cancel
+	m.reaperDone = done
+	m.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.reapIdleVMs(ctx, minWarm)
+			}
+		}
+	}()
+
+	m.logger.WithFields(logrus.Fields{
+		"interval":      interval,
+		"idle_timeout":  m.cfg.IdleTimeout,
+		"max_vm_reuses": m.cfg.MaxVMReuses,
+	}).Info("VM idle reaper started")
+}
+
+// StopReaper 停止空闲回收循环并等待正在进行的回收完成，未启动时不做任何事。
+func (m *MachineManager) StopReaper() {
+	m.mu.Lock()
+	cancel, done := m.reaperCancel, m.reaperDone
+	m.reaperCancel, m.reaperDone = nil, nil
+	m.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// reapIdleVMs 执行一轮回收，停止 selectVMsToReap 选出的虚拟机。
+//
+// 返回：
+//   - map[string]string: 被回收的虚拟机 ID 到回收原因的映射
+func (m *MachineManager) reapIdleVMs(ctx context.Context, minWarm map[string]int) map[string]string {
+	reaped := m.selectVMsToReap(m.now(), minWarm)
+	for vmID, reason := range reaped {
+		log := m.logger.WithFields(logrus.Fields{
+			"vm_id":  vmID,
+			"reason": reason,
+		})
+		if err := m.reapVM(ctx, vmID); err != nil {
+			if errors.Is(err, errVMBusy) {
+				log.Debug("VM taken for an invocation before reaping, skipped")
+			} else {
+				log.WithError(err).Warn("Failed to reap VM")
+			}
+			delete(reaped, vmID)
+			continue
+		}
+		log.Info("Reaped VM")
+	}
+	return reaped
+}
+
+// reapVM 停止一个被选中回收的虚拟机。
+// 选中和停止之间虚拟机可能已被取出执行调用，因此在持有锁时复查使用状态并标记为回收中：
+// 标记之后 MarkVMInUse 返回 ErrVMReaped，虚拟机不会再交付。停止成功后通知虚拟机池移除。
+func (m *MachineManager) reapVM(ctx context.Context, vmID string) error {
+	m.mu.Lock()
+	vm, ok := m.vms[vmID]
+	if !ok {
+		m.mu.Unlock()
+		return nil
+	}
+	vm.mu.Lock()
+	if vm.inUse {
+		vm.mu.Unlock()
+		m.mu.Unlock()
+		return errVMBusy
+	}
+	vm.reaping = true
+	vm.mu.Unlock()
+	onReaped := m.onReaped
+	m.mu.Unlock()
+
+	if err := m.stopVM(ctx, vmID); err != nil {
+		return err
+	}
+	if onReaped != nil {
+		onReaped(vmID, vm.Runtime)
+	}
+	return nil
+}
+
+// selectVMsToReap 选出需要回收的虚拟机：
+//   - 已停止或失败但仍在注册表中的虚拟机
+//   - 空闲且复用次数达到 MaxVMReuses 的虚拟机
+//   - 空闲超过 IdleTimeout 的虚拟机，每个运行时保留 minWarm 个最近使用的空闲虚拟机
+//
+// 使用中的虚拟机和创建中的虚拟机不会被选中。
+//
+// 返回：
+//   - map[string]string: 虚拟机 ID 到回收原因的映射
+func (m *MachineManager) selectVMsToReap(now time.Time, minWarm map[string]int) map[string]string {
+	type idleVM struct {
+		id       string
+		lastUsed time.Time
+	}
+
+	reaped := make(map[string]string)
+	idle := make(map[string][]idleVM)
+	for _, vm := range m.ListVMs() {
+		vm.mu.Lock()
+		state, inUse, useCount, lastUsed := vm.State, vm.inUse, vm.UseCount, vm.LastUsed
+		vm.mu.Unlock()
+		if lastUsed.IsZero() {
+			lastUsed = vm.CreatedAt
+		}
+
+		switch {
+		case state == VMStateStopped || state == VMStateFailed:
+			reaped[vm.ID] = reapReasonStopped
+		case state != VMStateRunning || inUse:
+		case m.cfg.MaxVMReuses > 0 && useCount >= m.cfg.MaxVMReuses:
+			reaped[vm.ID] = reapReasonMaxReuses
+		default:
+			idle[vm.Runtime] = append(idle[vm.Runtime], idleVM{id: vm.ID, lastUsed: lastUsed})
+		}
+	}
+
+	if m.cfg.IdleTimeout <= 0 {
+		return reaped
+	}
+	for runtime, vms := range idle {
+		// 最近使用的排在前面，优先保留
+		sort.Slice(vms, func(i, j int) bool { return vms[i].lastUsed.After(vms[j].lastUsed) })
+		for i, vm := range vms {
+			if i < minWarm[runtime] {
+				continue
+			}
+			if now.Sub(vm.lastUsed) > m.cfg.IdleTimeout {
+				reaped[vm.id] = reapReasonIdle
+			}
+		}
+	}
+	return reaped
+}
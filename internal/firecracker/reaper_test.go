@@ -0,0 +1,160 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/config"
+)
+
+// newReaperTestManager 返回使用假时钟的管理器，停止虚拟机只记录 ID 并从注册表移除
+func newReaperTestManager(cfg config.FirecrackerConfig, clock *time.Time) (*MachineManager, *[]string) {
+	m := NewMachineManager(cfg, nil, testLogger())
+	m.now = func() time.Time { return *clock }
+	var stopped []string
+	m.stopVM = func(ctx context.Context, vmID string) error {
+		m.mu.Lock()
+		delete(m.vms, vmID)
+		m.mu.Unlock()
+		stopped = append(stopped, vmID)
+		return nil
+	}
+	return m, &stopped
+}
+
+// addTestVM 直接向注册表加入一个不带 Firecracker 进程的虚拟机
+func addTestVM(m *MachineManager, id, runtime string, state VMState, lastUsed time.Time, useCount int, inUse bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.vms[id] = &VM{ID: id, Runtime: runtime, State: state, CreatedAt: lastUsed, LastUsed: lastUsed, UseCount: useCount, inUse: inUse}
+}
+
+func TestReapIdleVMs(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m, stopped := newReaperTestManager(config.FirecrackerConfig{IdleTimeout: time.Hour, MaxVMReuses: 3}, &now)
+
+	addTestVM(m, "py-oldest", "python3.11", VMStateRunning, now.Add(-2*time.Hour), 1, false)
+	addTestVM(m, "py-older", "python3.11", VMStateRunning, now.Add(-90*time.Minute), 1, false) // 保留为 min_warm
+	addTestVM(m, "py-recent", "python3.11", VMStateRunning, now.Add(-time.Minute), 1, false)
+	addTestVM(m, "py-busy", "python3.11", VMStateRunning, now.Add(-3*time.Hour), 1, true)
+	addTestVM(m, "py-worn", "python3.11", VMStateRunning, now.Add(-time.Minute), 3, false)
+	addTestVM(m, "node-idle", "nodejs20", VMStateRunning, now.Add(-2*time.Hour), 1, false)
+	addTestVM(m, "node-dead", "nodejs20", VMStateStopped, now, 0, false)
+	addTestVM(m, "node-booting", "nodejs20", VMStateCreating, now.Add(-2*time.Hour), 0, false)
+
+	reaped := m.reapIdleVMs(context.Background(), map[string]int{"python3.11": 2})
+	want := map[string]string{
+		"py-oldest": reapReasonIdle,
+		"py-worn":   reapReasonMaxReuses,
+		"node-idle": reapReasonIdle,
+		"node-dead": reapReasonStopped,
+	}
+	if len(reaped) != len(want) {
+		t.Fatalf("reaped = %v, want %v", reaped, want)
+	}
+	for id, reason := range want {
+		if reaped[id] != reason {
+			t.Errorf("reaped[%s] = %q, want %q", id, reaped[id], reason)
+		}
+	}
+	if len(*stopped) != 4 {
+		t.Errorf("stopped = %v", *stopped)
+	}
+
+	// 剩下的 python3.11 空闲虚拟机正好是 min_warm 个，不再回收
+	now = now.Add(time.Hour)
+	if reaped := m.reapIdleVMs(context.Background(), map[string]int{"python3.11": 2}); len(reaped) != 0 {
+		t.Errorf("second pass reaped %v, want nothing", reaped)
+	}
+}
+
+func TestReapIdleVMsDisabledTimeout(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m, _ := newReaperTestManager(config.FirecrackerConfig{}, &now)
+	addTestVM(m, "idle", "python3.11", VMStateRunning, now.Add(-24*time.Hour), 100, false)
+	addTestVM(m, "failed", "python3.11", VMStateFailed, now, 0, false)
+
+	// 未配置 idle_timeout 和 max_vm_reuses 时只清理已失败的虚拟机
+	reaped := m.selectVMsToReap(now, nil)
+	if len(reaped) != 1 || reaped["failed"] != reapReasonStopped {
+		t.Errorf("reaped = %v, want only the failed VM", reaped)
+	}
+}
+
+func TestMarkVMInUseAndIdle(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m, _ := newReaperTestManager(config.FirecrackerConfig{IdleTimeout: time.Minute, MaxVMReuses: 2}, &now)
+	addTestVM(m, "vm-1", "python3.11", VMStateRunning, now, 0, false)
+
+	m.MarkVMInUse("vm-1")
+	now = now.Add(10 * time.Minute)
+	if reaped := m.selectVMsToReap(now, nil); len(reaped) != 0 {
+		t.Fatalf("in-use VM selected for reaping: %v", reaped)
+	}
+	if m.MarkVMIdle("vm-1") {
+		t.Fatal("MarkVMIdle after first use reported reuse limit")
+	}
+	if reaped := m.selectVMsToReap(now.Add(30*time.Second), nil); len(reaped) != 0 {
+		t.Fatalf("idle time should count from release, got %v", reaped)
+	}
+
+	m.MarkVMInUse("vm-1")
+	if !m.MarkVMIdle("vm-1") {
+		t.Error("MarkVMIdle after reaching max_vm_reuses should report the limit")
+	}
+	vm, _ := m.GetVM("vm-1")
+	if vm.UseCount != 2 || !vm.LastUsed.Equal(now) {
+		t.Errorf("vm use_count=%d last_used=%v", vm.UseCount, vm.LastUsed)
+	}
+
+	// 未注册的虚拟机不报错
+	m.MarkVMInUse("missing")
+	if m.MarkVMIdle("missing") {
+		t.Error("MarkVMIdle for unknown VM reported reuse limit")
+	}
+}
+
+func TestReapVMRechecksInUse(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m, stopped := newReaperTestManager(config.FirecrackerConfig{IdleTimeout: time.Minute}, &now)
+	addTestVM(m, "vm-1", "python3.11", VMStateRunning, now.Add(-time.Hour), 1, false)
+	addTestVM(m, "vm-2", "python3.11", VMStateRunning, now.Add(-time.Hour), 1, false)
+	var removed []string
+	m.OnVMReaped(func(vmID, runtime string) { removed = append(removed, runtime+"/"+vmID) })
+
+	// 选中之后、停止之前 vm-1 被取出执行调用：复查发现使用中，不再停止
+	if reaped := m.selectVMsToReap(now, nil); len(reaped) != 2 {
+		t.Fatalf("selected = %v, want both idle VMs", reaped)
+	}
+	if err := m.MarkVMInUse("vm-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.reapVM(context.Background(), "vm-1"); err != errVMBusy {
+		t.Errorf("reapVM on VM taken after selection: err = %v, want errVMBusy", err)
+	}
+
+	// 停止期间虚拟机已标记为回收中，不能再被取用；停止后通知池移除
+	m.stopVM = func(ctx context.Context, vmID string) error {
+		if err := m.MarkVMInUse(vmID); err != ErrVMReaped {
+			t.Errorf("MarkVMInUse during reap: err = %v, want ErrVMReaped", err)
+		}
+		m.mu.Lock()
+		delete(m.vms, vmID)
+		m.mu.Unlock()
+		*stopped = append(*stopped, vmID)
+		return nil
+	}
+	if err := m.reapVM(context.Background(), "vm-2"); err != nil {
+		t.Fatalf("reapVM: %v", err)
+	}
+	if len(*stopped) != 1 || (*stopped)[0] != "vm-2" {
+		t.Errorf("stopped = %v, want only vm-2", *stopped)
+	}
+	if len(removed) != 1 || removed[0] != "python3.11/vm-2" {
+		t.Errorf("reap callback calls = %v, want python3.11/vm-2", removed)
+	}
+}
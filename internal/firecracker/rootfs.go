@@ -0,0 +1,211 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// RootfsStrategy 表示为虚拟机准备独立根文件系统的方式。
+type RootfsStrategy string
+
+// 根文件系统准备策略
+const (
+	// RootfsReflink 使用 FICLONE（reflink）写时复制克隆镜像，文件系统不支持时回退到完整复制
+	RootfsReflink RootfsStrategy = "reflink"
+	// RootfsOverlay 使用 device-mapper snapshot：只读基础镜像 + 稀疏的可写 COW 层，
+	// 在 ext4 等不支持 reflink 的文件系统上也无需复制整个镜像
+	RootfsOverlay RootfsStrategy = "overlay"
+	// RootfsCopy 始终完整复制镜像
+	RootfsCopy RootfsStrategy = "copy"
+)
+
+// defaultOverlaySizeMB 是 overlay 可写层的默认大小（MB）
+const defaultOverlaySizeMB = 1024
+
+// selectRootfsStrategy 根据配置选择根文件系统准备策略。
+// 未配置时使用 reflink；配置了 overlay 但主机不支持 device-mapper 时回退到 reflink；
+// 无法识别的策略同样回退到 reflink 并记录警告。
+func selectRootfsStrategy(configured string, overlayAvailable func() bool, logger *logrus.Logger) RootfsStrategy {
+	switch RootfsStrategy(configured) {
+	case "", RootfsReflink:
+		return RootfsReflink
+	case RootfsCopy:
+		return RootfsCopy
+	case RootfsOverlay:
+		if overlayAvailable() {
+			return RootfsOverlay
+		}
+		logger.Warn("Overlay rootfs requires dmsetup and losetup, falling back to reflink")
+		return RootfsReflink
+	default:
+		logger.WithField("rootfs_strategy", configured).Warn("Unknown rootfs strategy, falling back to reflink")
+		return RootfsReflink
+	}
+}
+
+// overlaySupported 检查主机是否具备创建 device-mapper snapshot 所需的工具
+func overlaySupported() bool {
+	for _, bin := range []string{"dmsetup", "losetup"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareRootfs 为虚拟机准备独立的可写根文件系统。
+//
+// 返回：
+//   - string: 交给 Firecracker 的根文件系统路径（镜像文件或块设备）
+//   - func(): 释放根文件系统的清理函数，StopVM 或创建失败时调用
+//   - error: 准备失败时的错误
+func (m *MachineManager) prepareRootfs(runtime, vmID, baseRootfsPath string) (string, func(), error) {
+	destDir := filepath.Join(m.cfg.SnapshotDir, "vmrootfs", runtime)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", nil, fmt.Errorf("create vm rootfs dir: %w", err)
+	}
+
+	if m.rootfsStrategy == RootfsOverlay {
+		sizeMB := m.cfg.OverlaySizeMB
+		if sizeMB <= 0 {
+			sizeMB = defaultOverlaySizeMB
+		}
+		cowPath := filepath.Join(destDir, vmID+".cow")
+		devPath, cleanup, err := createOverlayRootfs(baseRootfsPath, cowPath, "nimbus-"+vmID, int64(sizeMB)<<20)
+		if err != nil {
+			return "", nil, fmt.Errorf("create overlay rootfs: %w", err)
+		}
+		return devPath, cleanup, nil
+	}
+
+	destPath := filepath.Join(destDir, vmID+".ext4")
+	if err := cloneOrCopyFile(baseRootfsPath, destPath, m.rootfsStrategy == RootfsReflink); err != nil {
+		return "", nil, fmt.Errorf("clone rootfs: %w", err)
+	}
+	return destPath, func() { _ = os.Remove(destPath) }, nil
+}
+
+// dmSnapshotTable 生成 device-mapper snapshot 目标的映射表。
+// 格式：<起始扇区> <扇区数> snapshot <源设备> <COW 设备> <持久化 P/N> <块大小（扇区）>，
+// COW 层不需要在重启后保留，使用非持久化（N）模式和 8 扇区（4KB）的块大小。
+func dmSnapshotTable(sectors int64, originDev, cowDev string) string {
+	return fmt.Sprintf("0 %d snapshot %s %s N 8", sectors, originDev, cowDev)
+}
+
+// createOverlayRootfs 基于只读基础镜像创建 device-mapper snapshot 设备。
+// 基础镜像以只读方式挂到 loop 设备，写入落在稀疏的 COW 文件中，COW 文件只占用实际写入的空间。
+// 需要 root 权限、loop 设备和内核 dm-snapshot 模块（CONFIG_DM_SNAPSHOT）。
+func createOverlayRootfs(basePath, cowPath, dmName string, cowSizeBytes int64) (devPath string, cleanup func(), err error) {
+	info, err := os.Stat(basePath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 按创建顺序的逆序释放已创建的资源
+	var undo []func()
+	cleanup = func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	originDev, err := runCommand("losetup", "--find", "--show", "--read-only", basePath)
+	if err != nil {
+		return "", nil, err
+	}
+	undo = append(undo, func() { _, _ = runCommand("losetup", "--detach", originDev) })
+
+	// 创建稀疏 COW 文件
+	cow, err := os.Create(cowPath)
+	if err != nil {
+		return "", nil, err
+	}
+	undo = append(undo, func() { _ = os.Remove(cowPath) })
+	err = cow.Truncate(cowSizeBytes)
+	cow.Close()
+	if err != nil {
+		return "", nil, err
+	}
+
+	cowDev, err := runCommand("losetup", "--find", "--show", cowPath)
+	if err != nil {
+		return "", nil, err
+	}
+	undo = append(undo, func() { _, _ = runCommand("losetup", "--detach", cowDev) })
+
+	table := dmSnapshotTable(info.Size()/512, originDev, cowDev)
+	if _, err = runCommand("dmsetup", "create", dmName, "--table", table); err != nil {
+		return "", nil, err
+	}
+	undo = append(undo, func() { _, _ = runCommand("dmsetup", "remove", dmName) })
+
+	return filepath.Join("/dev/mapper", dmName), cleanup, nil
+}
+
+// runCommand 执行外部命令，返回去除首尾空白的标准输出
+func runCommand(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// cloneOrCopyFile 克隆或复制文件。
+// reflink 为 true 时优先尝试使用 FICLONE ioctl 进行高效的写时复制克隆，
+// 如果文件系统不支持则回退到传统的数据复制。
+func cloneOrCopyFile(src, dst string, reflink bool) (err error) {
+	// 打开源文件
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// 创建目标文件
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := out.Close()
+		if err == nil {
+			err = closeErr
+		}
+		// 如果出错，清理目标文件
+		if err != nil {
+			_ = os.Remove(dst)
+		}
+	}()
+
+	// 尝试使用 FICLONE 进行写时复制克隆（仅在支持的文件系统上有效，如 Btrfs、XFS）
+	if reflink {
+		if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err == nil {
+			return out.Sync()
+		}
+	}
+
+	// 回退到传统复制
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
@@ -0,0 +1,72 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/config"
+)
+
+func TestSelectRootfsStrategy(t *testing.T) {
+	available := func() bool { return true }
+	unavailable := func() bool { return false }
+
+	tests := []struct {
+		configured string
+		overlay    func() bool
+		want       RootfsStrategy
+	}{
+		{"", available, RootfsReflink},
+		{"reflink", available, RootfsReflink},
+		{"copy", available, RootfsCopy},
+		{"overlay", available, RootfsOverlay},
+		{"overlay", unavailable, RootfsReflink},
+		{"btrfs", available, RootfsReflink},
+	}
+	for _, tt := range tests {
+		if got := selectRootfsStrategy(tt.configured, tt.overlay, testLogger()); got != tt.want {
+			t.Errorf("selectRootfsStrategy(%q) = %s, want %s", tt.configured, got, tt.want)
+		}
+	}
+}
+
+func TestDMSnapshotTable(t *testing.T) {
+	got := dmSnapshotTable(2097152, "/dev/loop3", "/dev/loop4")
+	want := "0 2097152 snapshot /dev/loop3 /dev/loop4 N 8"
+	if got != want {
+		t.Errorf("dmSnapshotTable = %q, want %q", got, want)
+	}
+}
+
+func TestPrepareRootfsCopyStrategies(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "rootfs.ext4")
+	if err := os.WriteFile(base, []byte("base-image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, strategy := range []string{"reflink", "copy"} {
+		cfg := config.FirecrackerConfig{SnapshotDir: t.TempDir(), RootfsStrategy: strategy}
+		m := NewMachineManager(cfg, nil, testLogger())
+
+		path, cleanup, err := m.prepareRootfs("python3.11", "vm-1", base)
+		if err != nil {
+			t.Fatalf("%s: prepareRootfs: %v", strategy, err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "base-image" {
+			t.Errorf("%s: rootfs content = %q, want copy of base", strategy, data)
+		}
+
+		// StopVM 调用清理函数后副本被删除，基础镜像保留
+		cleanup()
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s: rootfs copy still exists after cleanup", strategy)
+		}
+		if _, err := os.Stat(base); err != nil {
+			t.Errorf("%s: base image removed: %v", strategy, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+)
+
+// snapshotRootfsFile 是快照目录中根文件系统副本的文件名。
+// 创建快照时保存源虚拟机的磁盘，恢复时每个实例基于它准备独立的可写副本。
+const snapshotRootfsFile = "rootfs.ext4"
+
+// snapshotIfaceID 是快照中网卡的 ID，SDK 按网卡顺序从 1 开始编号，虚拟机只有一块网卡
+const snapshotIfaceID = "1"
+
+// snapshotNetworkOverride 将快照中的网卡重新绑定到新实例的 TAP 设备
+type snapshotNetworkOverride struct {
+	IfaceID     string `json:"iface_id"`
+	HostDevName string `json:"host_dev_name"`
+}
+
+// snapshotVsockOverride 将快照中的 vsock 设备重新绑定到新实例的 Unix socket
+type snapshotVsockOverride struct {
+	UDSPath string `json:"uds_path"`
+}
+
+// snapshotLoadParams 是 PUT /snapshot/load 的请求体。
+// SDK 的 SnapshotLoadParams 不包含设备覆盖字段，因此直接构造请求：
+// 快照中记录的是源虚拟机的 TAP 设备和 vsock socket，不覆盖时多个恢复实例会争用同一设备。
+type snapshotLoadParams struct {
+	MemFilePath         string                    `json:"mem_file_path"`
+	SnapshotPath        string                    `json:"snapshot_path"`
+	EnableDiffSnapshots bool                      `json:"enable_diff_snapshots"`
+	ResumeVM            bool                      `json:"resume_vm"`
+	NetworkOverrides    []snapshotNetworkOverride `json:"network_overrides,omitempty"`
+	VsockOverride       *snapshotVsockOverride    `json:"vsock_override,omitempty"`
+}
+
+// newSnapshotLoadParams 构建加载快照的参数，加载后不自动恢复运行。
+// tapDevice 和 vsockPath 为空时不覆盖对应设备。
+func newSnapshotLoadParams(memFilePath, snapshotPath, tapDevice, vsockPath string) *snapshotLoadParams {
+	params := &snapshotLoadParams{
+		MemFilePath:  memFilePath,
+		SnapshotPath: snapshotPath,
+	}
+	if tapDevice != "" {
+		params.NetworkOverrides = []snapshotNetworkOverride{{IfaceID: snapshotIfaceID, HostDevName: tapDevice}}
+	}
+	if vsockPath != "" {
+		params.VsockOverride = &snapshotVsockOverride{UDSPath: vsockPath}
+	}
+	return params
+}
+
+// loadSnapshotWithOverrides 替换 SDK 默认的快照加载流程：
+// 用带设备覆盖的请求代替 LoadSnapshot，并去掉加载后重新添加 vsock 的步骤（快照恢复后无法再添加设备）。
+func loadSnapshotWithOverrides(machine *firecracker.Machine, params *snapshotLoadParams) {
+	machine.Handlers.FcInit = machine.Handlers.FcInit.
+		Remove(firecracker.AddVsocksHandlerName).
+		Swap(firecracker.Handler{
+			Name: firecracker.LoadSnapshotHandlerName,
+			Fn: func(ctx context.Context, m *firecracker.Machine) error {
+				return putSnapshotLoad(ctx, m.Cfg.SocketPath, params)
+			},
+		})
+}
+
+// putSnapshotLoad 通过 API socket 发送 PUT /snapshot/load 请求
+func putSnapshotLoad(ctx context.Context, socketPath string, params *snapshotLoadParams) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://localhost/snapshot/load", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("load snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("load snapshot: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// saveSnapshotRootfs 将虚拟机当前的磁盘保存到快照目录，返回副本路径。
+// 调用时虚拟机须已暂停，保证磁盘内容与内存快照一致。
+func (m *MachineManager) saveSnapshotRootfs(vm *VM, snapshotDir string) (string, error) {
+	dst := filepath.Join(snapshotDir, snapshotRootfsFile)
+	if err := os.MkdirAll(snapshotDir, 0755); err != nil {
+		return "", err
+	}
+	if err := cloneOrCopyFile(vm.RootfsPath, dst, m.rootfsStrategy == RootfsReflink); err != nil {
+		return "", fmt.Errorf("save snapshot rootfs: %w", err)
+	}
+	return dst, nil
+}
+
+// restoreRootfsBase 返回恢复时用作磁盘来源的镜像：优先使用快照保存的磁盘，
+// 没有时（旧版本创建的快照）回退到运行时的基础镜像，两者都没有时返回空字符串。
+func (m *MachineManager) restoreRootfsBase(snapshotPath, runtime string) string {
+	saved := filepath.Join(snapshotPath, snapshotRootfsFile)
+	if _, err := os.Stat(saved); err == nil {
+		return saved
+	}
+	if runtime == "" {
+		return ""
+	}
+	return filepath.Join(m.cfg.RootfsDir, runtime, "rootfs.ext4")
+}
@@ -0,0 +1,129 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/config"
+)
+
+func TestNewSnapshotLoadParamsOverridesDevices(t *testing.T) {
+	params := newSnapshotLoadParams("/snap/mem", "/snap/snapshot", "nimbus-tap7", "/run/vsock/vm-2.vsock")
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"mem_file_path":"/snap/mem","snapshot_path":"/snap/snapshot","enable_diff_snapshots":false,"resume_vm":false,` +
+		`"network_overrides":[{"iface_id":"1","host_dev_name":"nimbus-tap7"}],"vsock_override":{"uds_path":"/run/vsock/vm-2.vsock"}}`
+	if string(data) != want {
+		t.Errorf("load params = %s, want %s", data, want)
+	}
+
+	// 没有新设备时不发送覆盖字段
+	data, _ = json.Marshal(newSnapshotLoadParams("/snap/mem", "/snap/snapshot", "", ""))
+	if strings.Contains(string(data), "override") {
+		t.Errorf("load params without devices = %s, want no overrides", data)
+	}
+}
+
+func TestPutSnapshotLoad(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "fc.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	var gotMethod, gotPath string
+	var gotBody snapshotLoadParams
+	status := http.StatusNoContent
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &gotBody)
+		if status != http.StatusNoContent {
+			http.Error(w, `{"fault_message":"bad snapshot"}`, status)
+			return
+		}
+		w.WriteHeader(status)
+	})}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	params := newSnapshotLoadParams("/snap/mem", "/snap/snapshot", "tap1", "/run/vm.vsock")
+	if err := putSnapshotLoad(context.Background(), socketPath, params); err != nil {
+		t.Fatalf("putSnapshotLoad: %v", err)
+	}
+	if gotMethod != http.MethodPut || gotPath != "/snapshot/load" {
+		t.Errorf("request = %s %s, want PUT /snapshot/load", gotMethod, gotPath)
+	}
+	if gotBody.VsockOverride == nil || gotBody.VsockOverride.UDSPath != "/run/vm.vsock" || len(gotBody.NetworkOverrides) != 1 {
+		t.Errorf("request body = %+v, want device overrides", gotBody)
+	}
+
+	status = http.StatusBadRequest
+	if err := putSnapshotLoad(context.Background(), socketPath, params); err == nil || !strings.Contains(err.Error(), "bad snapshot") {
+		t.Errorf("putSnapshotLoad on rejected load: err = %v, want Firecracker's fault message", err)
+	}
+}
+
+func TestSnapshotRootfsSaveAndRestoreBase(t *testing.T) {
+	rootfsDir := t.TempDir()
+	runtimeBase := filepath.Join(rootfsDir, "python3.11", "rootfs.ext4")
+	if err := os.MkdirAll(filepath.Dir(runtimeBase), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(runtimeBase, []byte("base-image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMachineManager(config.FirecrackerConfig{SnapshotDir: t.TempDir(), RootfsDir: rootfsDir, RootfsStrategy: "copy"}, nil, testLogger())
+	snapshotDir := filepath.Join(t.TempDir(), "content", "key")
+
+	// 没有保存磁盘的旧快照回退到运行时基础镜像
+	if got := m.restoreRootfsBase(snapshotDir, "python3.11"); got != runtimeBase {
+		t.Errorf("restore base without saved rootfs = %q, want runtime base %q", got, runtimeBase)
+	}
+	if got := m.restoreRootfsBase(snapshotDir, ""); got != "" {
+		t.Errorf("restore base without saved rootfs or runtime = %q, want empty", got)
+	}
+
+	// 快照保存源虚拟机当前的磁盘，源虚拟机的副本删除后仍可恢复
+	vmDisk := filepath.Join(t.TempDir(), "vm-1.ext4")
+	if err := os.WriteFile(vmDisk, []byte("installed-deps"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := m.saveSnapshotRootfs(&VM{ID: "vm-1", RootfsPath: vmDisk}, snapshotDir)
+	if err != nil {
+		t.Fatalf("saveSnapshotRootfs: %v", err)
+	}
+	os.Remove(vmDisk)
+	if got := m.restoreRootfsBase(snapshotDir, "python3.11"); got != saved {
+		t.Fatalf("restore base = %q, want saved rootfs %q", got, saved)
+	}
+
+	// 每个恢复实例得到独立的可写副本
+	a, cleanupA, err := m.prepareRootfs("python3.11", "vm-a", saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupA()
+	b, cleanupB, err := m.prepareRootfs("python3.11", "vm-b", saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupB()
+	if a == b || a == saved {
+		t.Errorf("restored rootfs paths a=%q b=%q saved=%q, want distinct copies", a, b, saved)
+	}
+	if data, _ := os.ReadFile(b); string(data) != "installed-deps" {
+		t.Errorf("restored rootfs content = %q, want the saved disk", data)
+	}
+}
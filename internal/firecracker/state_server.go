@@ -0,0 +1,205 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/vsock"
+	"github.com/oriys/nimbus/internal/state"
+	"github.com/sirupsen/logrus"
+)
+
+// stateRequestTimeout 单个状态操作在宿主机上的最长处理时间，与 Agent 侧的往返超时一致
+const stateRequestTimeout = 5 * time.Second
+
+// FunctionResolver 根据 vsock CID 查找虚拟机当前初始化的函数，由 MachineManager 实现
+type FunctionResolver interface {
+	FunctionForCID(cid uint32) (string, bool)
+}
+
+// BindFunction 记录虚拟机内 Agent 当前初始化的函数，初始化前传入空字符串解除绑定。
+// 状态服务只允许虚拟机读写所绑定函数的状态。
+func (vm *VM) BindFunction(functionID string) {
+	vm.mu.Lock()
+	vm.function = functionID
+	vm.mu.Unlock()
+}
+
+// FunctionForCID 返回 CID 对应的虚拟机当前绑定的函数 ID，虚拟机不存在或尚未绑定函数时返回 false
+func (m *MachineManager) FunctionForCID(cid uint32) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, vm := range m.vms {
+		if vm.VsockCID != cid {
+			continue
+		}
+		vm.mu.Lock()
+		defer vm.mu.Unlock()
+		return vm.function, vm.function != ""
+	}
+	return "", false
+}
+
+// vsockPeerCID 返回 vsock 连接对端虚拟机的 CID
+func vsockPeerCID(conn net.Conn) (uint32, bool) {
+	addr, ok := conn.RemoteAddr().(*vsock.Addr)
+	if !ok {
+		return 0, false
+	}
+	return addr.ContextID, true
+}
+
+// StateServer 是宿主机侧的状态服务。
+// 虚拟机内的 Agent 将函数发起的状态操作封装为 MessageTypeState 消息，
+// 主动连接宿主机的 StateVsockPort 发送，StateServer 交给 state.Handler 处理后在同一连接上返回结果。
+type StateServer struct {
+	handler   *state.Handler   // 执行状态操作
+	functions FunctionResolver // 根据连接对端的 CID 查找虚拟机所属函数
+	logger    *logrus.Logger   // 日志记录器
+
+	peerCID func(net.Conn) (uint32, bool) // 返回连接对端的 CID，默认为 vsockPeerCID，测试中可替换
+
+	mu       sync.Mutex
+	listener net.Listener          // 当前监听器，未启动时为 nil
+	conns    map[net.Conn]struct{} // 正在处理的 Agent 连接，关闭时一并断开
+	wg       sync.WaitGroup        // 等待连接处理协程退出
+}
+
+// NewStateServer 创建状态服务。
+// 参数：
+//   - handler: 状态处理器
+//   - functions: 根据 CID 查找虚拟机所属函数，请求只能访问该函数的状态
+//   - logger: 日志记录器
+func NewStateServer(handler *state.Handler, functions FunctionResolver, logger *logrus.Logger) *StateServer {
+	return &StateServer{
+		handler:   handler,
+		functions: functions,
+		logger:    logger,
+		peerCID:   vsockPeerCID,
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// Start 在宿主机的 StateVsockPort 上监听 Agent 的状态连接。
+func (s *StateServer) Start() error {
+	l, err := vsock.Listen(StateVsockPort, nil)
+	if err != nil {
+		return err
+	}
+	go s.Serve(l)
+	s.logger.WithField("port", StateVsockPort).Info("State service listening on vsock")
+	return nil
+}
+
+// Serve 在 l 上接受连接并处理状态请求，直到 l 被关闭。
+// 每个 Agent 连接由独立协程处理，连接上的请求逐个往返。
+func (s *StateServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close 停止监听并断开所有 Agent 连接，等待处理中的请求返回。
+func (s *StateServer) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// serveConn 处理一个 Agent 连接上的状态请求。
+// 连接按对端 CID 绑定到虚拟机，无法识别对端的连接直接断开。
+// 状态连接不协商压缩，消息按未压缩的长度前缀帧读写。
+func (s *StateServer) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+
+	cid, ok := s.peerCID(conn)
+	if !ok {
+		s.logger.WithField("remote", conn.RemoteAddr().String()).Warn("Rejected state connection from unknown peer")
+		return
+	}
+
+	c := &VsockClient{conn: conn, logger: s.logger}
+	ctx := context.Background()
+	for {
+		msg, err := c.readMessage(ctx)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.WithError(err).Debug("State connection closed")
+			}
+			return
+		}
+		resp := &VsockMessage{
+			Type:      MessageTypeResp,
+			RequestID: msg.RequestID,
+			Payload:   s.handle(ctx, cid, msg),
+		}
+		if err := c.writeMessage(ctx, resp); err != nil {
+			s.logger.WithError(err).Warn("Failed to write state response")
+			return
+		}
+	}
+}
+
+// handle 执行一条状态请求并返回序列化后的 state.StateResult。
+// 请求的函数以 CID 对应虚拟机当前绑定的函数为准：载荷未填写时使用该函数，填写了其他函数时拒绝。
+// 虚拟机在调用之间可能重新初始化为其他函数，因此每条请求都重新查找。
+func (s *StateServer) handle(ctx context.Context, cid uint32, msg *VsockMessage) json.RawMessage {
+	var result *state.StateResult
+	var req state.StateRequest
+	owner, bound := s.functions.FunctionForCID(cid)
+	switch {
+	case msg.Type != MessageTypeState:
+		result = &state.StateResult{Error: "unexpected message type on state connection", ErrorCode: state.ErrCodeOperation}
+	case json.Unmarshal(msg.Payload, &req) != nil:
+		result = &state.StateResult{Error: "invalid state payload", ErrorCode: state.ErrCodeOperation}
+	case !bound:
+		result = &state.StateResult{Error: "vm is not bound to a function", ErrorCode: state.ErrCodeOperation}
+	case req.FunctionID != "" && req.FunctionID != owner:
+		result = &state.StateResult{Error: fmt.Sprintf("function_id %s does not match the calling vm", req.FunctionID), ErrorCode: state.ErrCodeOperation}
+	default:
+		req.FunctionID = owner
+		reqCtx, cancel := context.WithTimeout(ctx, stateRequestTimeout)
+		result = s.handler.Handle(reqCtx, &req)
+		cancel()
+	}
+	data, _ := json.Marshal(result)
+	return data
+}
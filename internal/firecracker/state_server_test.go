@@ -0,0 +1,160 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/oriys/nimbus/internal/state"
+	"github.com/redis/go-redis/v9"
+)
+
+// testStateCID 是测试中状态连接对端虚拟机的 CID
+const testStateCID = 100
+
+// startTestStateServer 在 Unix socket 上启动连接 miniredis 的状态服务，返回模拟 Agent 的客户端。
+// 连接的对端固定为 CID 为 testStateCID 的虚拟机，该虚拟机已绑定函数 fn-1。
+func startTestStateServer(t *testing.T) (*VsockClient, *miniredis.Miniredis, *VM) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	vm := &VM{ID: "vm-1", VsockCID: testStateCID}
+	vm.BindFunction("fn-1")
+	machines := &MachineManager{vms: map[string]*VM{vm.ID: vm}}
+
+	srv := NewStateServer(state.NewHandler(client, nil, testLogger()), machines, testLogger())
+	srv.peerCID = func(net.Conn) (uint32, bool) { return testStateCID, true }
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "state.sock"))
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &VsockClient{conn: conn, logger: testLogger()}, mr, vm
+}
+
+// callState 以 Agent 的方式发送一条状态请求并解析结果
+func callState(t *testing.T, agent *VsockClient, msgType uint8, req map[string]interface{}) *state.StateResult {
+	t.Helper()
+	payload, _ := json.Marshal(req)
+	resp, err := agent.sendAndReceive(context.Background(), &VsockMessage{Type: msgType, RequestID: "state-1", Payload: payload})
+	if err != nil {
+		t.Fatalf("state round trip: %v", err)
+	}
+	if resp.Type != MessageTypeResp || resp.RequestID != "state-1" {
+		t.Fatalf("response = type %d id %q, want resp for state-1", resp.Type, resp.RequestID)
+	}
+	var result state.StateResult
+	if err := json.Unmarshal(resp.Payload, &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	return &result
+}
+
+func TestStateServerRoutesAgentRequestsToHandler(t *testing.T) {
+	agent, mr, _ := startTestStateServer(t)
+
+	r := callState(t, agent, MessageTypeState, map[string]interface{}{
+		"function_id": "fn-1", "session_key": "sess-1", "operation": "set", "scope": "session", "key": "user", "value": json.RawMessage(`"alice"`),
+	})
+	if !r.Success {
+		t.Fatalf("set = %+v, want success", r)
+	}
+	if got, _ := mr.Get("state:fn-1:sess-1:user"); got != `"alice"` {
+		t.Errorf("redis value = %q, want the value written through the state service", got)
+	}
+
+	r = callState(t, agent, MessageTypeState, map[string]interface{}{
+		"function_id": "fn-1", "session_key": "sess-1", "operation": "get", "scope": "session", "key": "user",
+	})
+	if !r.Success || string(r.Value) != `"alice"` {
+		t.Fatalf("get = %+v, want stored value", r)
+	}
+
+	// 同一连接上的后续请求逐个往返
+	for want := 1; want <= 2; want++ {
+		r = callState(t, agent, MessageTypeState, map[string]interface{}{
+			"function_id": "fn-1", "operation": "incr", "scope": "function", "key": "count", "delta": 1,
+		})
+		var n int
+		if err := json.Unmarshal(r.Value, &n); !r.Success || err != nil || n != want {
+			t.Fatalf("incr = %+v, want %d", r, want)
+		}
+	}
+
+	r = callState(t, agent, MessageTypeState, map[string]interface{}{
+		"function_id": "fn-1", "operation": "keys", "scope": "function", "key": "*",
+	})
+	if !r.Success || string(r.Value) != `["count"]` {
+		t.Errorf("keys = %+v, want [count]", r)
+	}
+}
+
+func TestStateServerRejectsInvalidRequests(t *testing.T) {
+	agent, _, _ := startTestStateServer(t)
+
+	r := callState(t, agent, MessageTypeExec, map[string]interface{}{"function_id": "fn-1", "operation": "get"})
+	if r.Success || r.ErrorCode != state.ErrCodeOperation {
+		t.Errorf("non-state message = %+v, want operation_error", r)
+	}
+
+	// 出错后连接仍可继续使用
+	r = callState(t, agent, MessageTypeState, map[string]interface{}{"function_id": "fn-1", "operation": "exists", "scope": "function", "key": "k"})
+	if !r.Success || string(r.Value) != "false" {
+		t.Errorf("exists after errors = %+v, want false", r)
+	}
+}
+
+func TestStateServerBindsRequestsToCallingVM(t *testing.T) {
+	agent, mr, vm := startTestStateServer(t)
+	mr.Set("state:fn-2:_global:secret", `"other"`)
+
+	// 未填写 function_id 时使用虚拟机绑定的函数
+	r := callState(t, agent, MessageTypeState, map[string]interface{}{
+		"operation": "set", "scope": "function", "key": "k", "value": json.RawMessage(`1`),
+	})
+	if !r.Success {
+		t.Fatalf("set without function id = %+v, want success", r)
+	}
+	if got, _ := mr.Get("state:fn-1:_global:k"); got != "1" {
+		t.Errorf("redis value = %q, want the value written under fn-1", got)
+	}
+
+	// 不能读写其他函数的状态
+	r = callState(t, agent, MessageTypeState, map[string]interface{}{
+		"function_id": "fn-2", "operation": "get", "scope": "function", "key": "secret",
+	})
+	if r.Success || r.ErrorCode != state.ErrCodeOperation || r.Value != nil {
+		t.Errorf("cross-function get = %+v, want operation_error without value", r)
+	}
+
+	// 虚拟机重新初始化为其他函数后按新的绑定校验
+	vm.BindFunction("fn-2")
+	r = callState(t, agent, MessageTypeState, map[string]interface{}{
+		"function_id": "fn-2", "operation": "get", "scope": "function", "key": "secret",
+	})
+	if !r.Success || string(r.Value) != `"other"` {
+		t.Errorf("get after rebinding = %+v, want fn-2 value", r)
+	}
+
+	// 解除绑定（初始化中或初始化失败）后拒绝所有请求
+	vm.BindFunction("")
+	r = callState(t, agent, MessageTypeState, map[string]interface{}{"operation": "exists", "scope": "function", "key": "k"})
+	if r.Success || r.ErrorCode != state.ErrCodeOperation {
+		t.Errorf("request from unbound vm = %+v, want operation_error", r)
+	}
+}
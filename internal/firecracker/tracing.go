@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+
+	"github.com/oriys/nimbus/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName 虚拟机管理使用的追踪器名称
+const tracerName = "firecracker"
+
+// startChildSpan 在调用链路内启动子 span。
+// 上下文中没有 span 时（如健康检查、预热）返回空操作 span，避免后台任务产生大量独立的 trace。
+func startChildSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) trace.Span {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return trace.SpanFromContext(ctx)
+	}
+	_, span := telemetry.GetTracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+	return span
+}
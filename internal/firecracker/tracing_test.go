@@ -0,0 +1,127 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useSpanRecorder 将全局追踪提供者替换为内存记录器，测试结束后恢复
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+// spanAttr 返回 span 上指定属性的值
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// servePipe 模拟 guest agent：读取一条消息并回复 respType 类型的响应
+func servePipe(t *testing.T, conn net.Conn, respType uint8, payload json.RawMessage) {
+	agent := &VsockClient{conn: conn, logger: testLogger()}
+	go func() {
+		req, err := agent.readMessage(context.Background())
+		if err != nil {
+			t.Errorf("agent read: %v", err)
+			return
+		}
+		if err := agent.writeMessage(context.Background(), &VsockMessage{Type: respType, RequestID: req.RequestID, Payload: payload}); err != nil {
+			t.Errorf("agent write: %v", err)
+		}
+	}()
+}
+
+func TestVsockSpansCarryRequestID(t *testing.T) {
+	sr := useSpanRecorder(t)
+	hostConn, guestConn := net.Pipe()
+	defer hostConn.Close()
+	defer guestConn.Close()
+	servePipe(t, guestConn, MessageTypeResp, json.RawMessage(`{"success":true}`))
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "function.invoke")
+	client := &VsockClient{conn: hostConn, logger: testLogger()}
+	if _, err := client.Execute(ctx, "req-1", json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	parent.End()
+
+	found := map[string]bool{}
+	for _, span := range sr.Ended() {
+		if span.Name() != "vsock.write" && span.Name() != "vsock.read" {
+			continue
+		}
+		found[span.Name()] = true
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s parent = %s, want %s", span.Name(), span.Parent().SpanID(), parent.SpanContext().SpanID())
+		}
+		if v, ok := spanAttr(span, "request_id"); !ok || v.AsString() != "req-1" {
+			t.Errorf("%s request_id = %q, want req-1", span.Name(), v.AsString())
+		}
+	}
+	if !found["vsock.write"] || !found["vsock.read"] {
+		t.Errorf("recorded vsock spans = %v, want write and read", found)
+	}
+}
+
+func TestVsockSkipsSpansOutsideTrace(t *testing.T) {
+	sr := useSpanRecorder(t)
+	hostConn, guestConn := net.Pipe()
+	defer hostConn.Close()
+	defer guestConn.Close()
+	servePipe(t, guestConn, MessageTypePong, nil)
+
+	// 健康检查不在调用链路内，不应产生独立的 trace
+	client := &VsockClient{conn: hostConn, logger: testLogger()}
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if spans := sr.Ended(); len(spans) != 0 {
+		t.Errorf("recorded %d spans, want none", len(spans))
+	}
+}
+
+func TestRestoreVMRecordsSpan(t *testing.T) {
+	sr := useSpanRecorder(t)
+	m := NewMachineManager(config.FirecrackerConfig{}, nil, testLogger())
+	dir := filepath.Join(t.TempDir(), "missing")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.RestoreVM(context.Background(), dir, nil); err == nil {
+		t.Fatal("expected error for missing snapshot files")
+	}
+	spans := sr.Ended()
+	if len(spans) != 1 || spans[0].Name() != "snapshot_restore" {
+		t.Fatalf("recorded spans = %v, want one snapshot_restore span", spans)
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("status = %v, want error", spans[0].Status().Code)
+	}
+	if v, _ := spanAttr(spans[0], "snapshot.path"); v.AsString() != dir {
+		t.Errorf("snapshot.path = %q, want %q", v.AsString(), dir)
+	}
+}
@@ -6,7 +6,9 @@ package firecracker
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,18 +17,32 @@ import (
 	"time"
 
 	"github.com/mdlayher/vsock"
+	"github.com/oriys/nimbus/internal/telemetry"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // vsock 消息类型常量。
 // vsock 是一种用于主机和虚拟机之间通信的高效套接字协议。
 const (
 	VsockPort       = 9999      // vsock 通信使用的端口号
+	StateVsockPort  = 9997      // 宿主机状态服务监听的 vsock 端口，Agent 经此转发状态操作
 	MessageTypeInit = 1         // 初始化消息类型，用于设置函数环境
 	MessageTypeExec = 2         // 执行消息类型，用于触发函数执行
 	MessageTypeResp = 3         // 响应消息类型，用于返回执行结果
 	MessageTypePing = 4         // 心跳检测请求消息
 	MessageTypePong = 5         // 心跳检测响应消息
+
+	// MessageTypeState 状态操作消息，由 Agent 主动连接宿主机 StateVsockPort 发送
+	MessageTypeState = 7
+
+	// MessageTypeRespChunk 流式输出分片消息，流式执行以一条 MessageTypeResp 结束
+	MessageTypeRespChunk = 8
+	// MessageTypeLog 函数日志消息，请求转发日志时 Agent 在最终响应之前推送，每条对应一行输出
+	MessageTypeLog = 9
+
+	// MaxMessageBytes 单条消息体的最大字节数，与 Agent 的默认上限一致
+	MaxMessageBytes = 32 * 1024 * 1024
 )
 
 // VsockMessage 表示通过 vsock 传输的消息结构。
@@ -48,6 +64,22 @@ type InitPayload struct {
 	MemoryLimitMB int               `json:"memory_limit_mb"`    // 内存限制（MB）
 	TimeoutSec    int               `json:"timeout_sec"`        // 执行超时时间（秒）
 	Layers        []LayerInfo       `json:"layers,omitempty"`   // 函数层列表（可选）
+
+	// CodeHash 是代码的 SHA-256 哈希，Agent 据此判断同一函数的重复初始化能否复用现有运行时
+	CodeHash string `json:"code_hash,omitempty"`
+
+	// AcceptEncoding 声明主机支持的消息压缩编码，由 InitFunction 填充
+	AcceptEncoding []string `json:"accept_encoding,omitempty"`
+
+	// InstallDeps 要求 Agent 在初始化时安装代码包中 requirements.txt / package.json 声明的依赖
+	InstallDeps bool `json:"install_deps,omitempty"`
+	// InstallDepsTimeoutSec 是安装依赖的超时时间（秒），为 0 时使用 Agent 的默认值
+	InstallDepsTimeoutSec int `json:"install_deps_timeout_sec,omitempty"`
+
+	// WarmupHandler 是 Agent 初始化后调用的预热函数，预热返回后 InitFunction 才成功返回
+	WarmupHandler string `json:"warmup_handler,omitempty"`
+	// WarmupTimeoutSec 是预热的超时时间（秒），为 0 时使用 Agent 的默认值
+	WarmupTimeoutSec int `json:"warmup_timeout_sec,omitempty"`
 }
 
 // LayerInfo 表示函数层的信息。
@@ -55,14 +87,57 @@ type InitPayload struct {
 type LayerInfo struct {
 	LayerID string `json:"layer_id"` // 层唯一标识符
 	Version int    `json:"version"`  // 层版本号
-	Content []byte `json:"content"`  // 层内容（ZIP 压缩包）
+	Content []byte `json:"content"`  // 层内容（ZIP 或 tar.gz 压缩包）
 	Order   int    `json:"order"`    // 加载顺序（小的先加载）
+
+	// ContentHash 层内容的 SHA-256 哈希（十六进制），Agent 解压前校验
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// Fingerprint 返回初始化载荷的指纹，指纹相同的载荷在 Agent 中建立相同的函数环境。
+// 调度器记录虚拟机最近一次初始化的指纹，相同时跳过重复初始化，预热函数因此在每个虚拟机上只调用一次。
+// 带内容哈希的层只按 ID、版本和哈希计入，不重复哈希层内容；AcceptEncoding 不影响函数环境，不计入。
+func (p *InitPayload) Fingerprint() string {
+	c := *p
+	c.AcceptEncoding = nil
+	c.Layers = make([]LayerInfo, len(p.Layers))
+	for i, layer := range p.Layers {
+		if layer.ContentHash != "" {
+			layer.Content = nil
+		}
+		c.Layers[i] = layer
+	}
+	// encoding/json 按键排序序列化 map，相同的环境变量总是得到相同的指纹
+	data, _ := json.Marshal(&c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 // ExecPayload 表示函数执行请求的载荷。
 // 包含传递给函数的输入参数。
 type ExecPayload struct {
-	Input json.RawMessage `json:"input"` // 函数输入参数（JSON 格式）
+	Input  json.RawMessage `json:"input"`            // 函数输入参数（JSON 格式）
+	Stream bool            `json:"stream,omitempty"` // 是否以分片流式返回输出
+
+	// ForwardLogs 要求 Agent 在执行期间以 MessageTypeLog 消息转发函数的标准输出/标准错误
+	ForwardLogs bool `json:"forward_logs,omitempty"`
+
+	// InputRef 是按引用传递的输入 URI（如对象存储的预签名 URL），设置时 Agent 自行下载输入并忽略 Input
+	InputRef string `json:"input_ref,omitempty"`
+}
+
+// LogPayload 表示 Agent 转发的一行函数日志。
+type LogPayload struct {
+	Timestamp time.Time `json:"timestamp"` // Agent 读到该行的时间
+	Stream    string    `json:"stream"`    // 来源：stdout 或 stderr
+	Level     string    `json:"level"`     // 按行内容推断的日志级别：debug、info、warn、error
+	Message   string    `json:"message"`   // 日志内容（不含换行符）
+}
+
+// ChunkPayload 表示流式执行中的一个输出分片。
+type ChunkPayload struct {
+	Seq  int    `json:"seq"`  // 分片序号，从 0 开始递增
+	Data []byte `json:"data"` // 分片内容
 }
 
 // ResponsePayload 表示函数执行响应的载荷。
@@ -73,6 +148,9 @@ type ResponsePayload struct {
 	Error        string          `json:"error,omitempty"`       // 错误信息（失败时）
 	DurationMs   int64           `json:"duration_ms"`           // 执行耗时（毫秒）
 	MemoryUsedMB int             `json:"memory_used_mb"`        // 内存使用量（MB）
+	Chunks       int             `json:"chunks,omitempty"`      // 流式执行时发送的输出分片数
+	Stderr       string          `json:"stderr,omitempty"`      // 函数的标准错误输出（由 Agent 截断）
+	OutputPrefix string          `json:"output_prefix,omitempty"` // 输出超过 Agent 上限时输出开头的一段
 }
 
 // VsockClient 是 vsock 客户端，用于与虚拟机内的 agent 通信。
@@ -82,6 +160,9 @@ type VsockClient struct {
 	conn   net.Conn       // vsock 连接
 	logger *logrus.Logger // 日志记录器
 	mu     sync.Mutex     // 保护连接操作的互斥锁
+
+	// compress 表示 Agent 已在初始化时确认支持 gzip，大消息压缩发送
+	compress bool
 }
 
 // NewVsockClient 创建新的 vsock 客户端。
@@ -134,6 +215,7 @@ func (c *VsockClient) Close() error {
 	if c.conn != nil {
 		err := c.conn.Close()
 		c.conn = nil
+		c.compress = false
 		return err
 	}
 	return nil
@@ -141,7 +223,9 @@ func (c *VsockClient) Close() error {
 
 // InitFunction 初始化虚拟机中的函数环境。
 // 发送函数配置信息到 agent，准备执行环境。
+// 同时声明支持 gzip 压缩，Agent 确认后该连接上的大消息（如层内容、大输入输出）双向压缩。
 func (c *VsockClient) InitFunction(ctx context.Context, payload *InitPayload) error {
+	payload.AcceptEncoding = []string{EncodingGzip}
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -167,6 +251,21 @@ func (c *VsockClient) InitFunction(ctx context.Context, payload *InitPayload) er
 		return fmt.Errorf("init failed: %s", respPayload.Error)
 	}
 
+	// 旧版本 Agent 不返回 Output，保持不压缩
+	var result InitResult
+	if len(respPayload.Output) > 0 {
+		json.Unmarshal(respPayload.Output, &result)
+	}
+	c.mu.Lock()
+	c.compress = result.Encoding == EncodingGzip
+	c.mu.Unlock()
+	if result.WarmupMs > 0 {
+		c.logger.WithFields(logrus.Fields{
+			"function_id": payload.FunctionID,
+			"warmup_ms":   result.WarmupMs,
+		}).Info("Function warmup completed")
+	}
+
 	return nil
 }
 
@@ -202,6 +301,139 @@ func (c *VsockClient) Execute(ctx context.Context, requestID string, input json.
 	return &respPayload, nil
 }
 
+// ExecuteWithLogs 执行函数，并在执行期间接收 Agent 转发的函数日志。
+// vsock 连接是请求/响应式的，日志与流式输出分片一样复用执行请求所在的连接：
+// Agent 在最终响应之前推送任意条 MessageTypeLog 消息，宿主机读到最终响应为止。
+// 参数：
+//   - ctx: 上下文，用于超时控制
+//   - requestID: 请求唯一标识符
+//   - input: 函数输入参数（JSON 格式）
+//   - onLog: 日志回调，在读取连接的协程中按顺序调用；为 nil 时不请求 Agent 转发日志
+func (c *VsockClient) ExecuteWithLogs(ctx context.Context, requestID string, input json.RawMessage, onLog func(*LogPayload)) (*ResponsePayload, error) {
+	return c.executeWithLogs(ctx, requestID, &ExecPayload{Input: input, ForwardLogs: onLog != nil}, onLog)
+}
+
+// ExecuteRefWithLogs 与 ExecuteWithLogs 相同，但输入按引用传递：
+// 执行请求只携带输入的 URI，由 Agent 下载，大体积输入不经过 vsock 连接。
+// 参数：
+//   - ctx: 上下文，用于超时控制
+//   - requestID: 请求唯一标识符
+//   - inputRef: 输入的 URI（如对象存储的预签名 URL）
+//   - onLog: 日志回调，为 nil 时不请求 Agent 转发日志
+func (c *VsockClient) ExecuteRefWithLogs(ctx context.Context, requestID string, inputRef string, onLog func(*LogPayload)) (*ResponsePayload, error) {
+	return c.executeWithLogs(ctx, requestID, &ExecPayload{InputRef: inputRef, ForwardLogs: onLog != nil}, onLog)
+}
+
+// executeWithLogs 发送执行请求，读取 Agent 转发的日志直到最终响应
+func (c *VsockClient) executeWithLogs(ctx context.Context, requestID string, payload *ExecPayload, onLog func(*LogPayload)) (*ResponsePayload, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+		defer c.conn.SetDeadline(time.Time{})
+	}
+
+	if err := c.writeMessage(ctx, &VsockMessage{Type: MessageTypeExec, RequestID: requestID, Payload: data}); err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+
+	for {
+		msg, err := c.readMessage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive message: %w", err)
+		}
+		switch msg.Type {
+		case MessageTypeLog:
+			var entry LogPayload
+			if err := json.Unmarshal(msg.Payload, &entry); err != nil {
+				c.logger.WithError(err).WithField("request_id", requestID).Debug("Ignoring invalid log message")
+				continue
+			}
+			if onLog != nil {
+				onLog(&entry)
+			}
+		case MessageTypeResp:
+			var respPayload ResponsePayload
+			if err := json.Unmarshal(msg.Payload, &respPayload); err != nil {
+				return nil, err
+			}
+			return &respPayload, nil
+		default:
+			return nil, fmt.Errorf("unexpected message type during execution: %d", msg.Type)
+		}
+	}
+}
+
+// ExecuteStream 以流式方式执行函数。
+// 输出分片按顺序交给 onChunk，分片序号不连续时返回错误；
+// 返回的 ResponsePayload 不含 Output，只包含执行状态、耗时和分片数。
+// 参数：
+//   - ctx: 上下文，用于超时控制
+//   - requestID: 请求唯一标识符
+//   - input: 函数输入参数（JSON 格式）
+//   - onChunk: 分片回调，返回错误时停止接收（连接状态不再可用，调用方应关闭连接）
+func (c *VsockClient) ExecuteStream(ctx context.Context, requestID string, input json.RawMessage, onChunk func([]byte) error) (*ResponsePayload, error) {
+	data, err := json.Marshal(&ExecPayload{Input: input, Stream: true})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+		defer c.conn.SetDeadline(time.Time{})
+	}
+
+	if err := c.writeMessage(ctx, &VsockMessage{Type: MessageTypeExec, RequestID: requestID, Payload: data}); err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+
+	// 依次接收分片，直到收到最终响应
+	next := 0
+	for {
+		msg, err := c.readMessage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive message: %w", err)
+		}
+		switch msg.Type {
+		case MessageTypeRespChunk:
+			var chunk ChunkPayload
+			if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+				return nil, fmt.Errorf("invalid output chunk: %w", err)
+			}
+			if chunk.Seq != next {
+				return nil, fmt.Errorf("output chunk out of order: got %d, want %d", chunk.Seq, next)
+			}
+			next++
+			if err := onChunk(chunk.Data); err != nil {
+				return nil, err
+			}
+		case MessageTypeResp:
+			var respPayload ResponsePayload
+			if err := json.Unmarshal(msg.Payload, &respPayload); err != nil {
+				return nil, err
+			}
+			return &respPayload, nil
+		default:
+			return nil, fmt.Errorf("unexpected message type during stream: %d", msg.Type)
+		}
+	}
+}
+
 // Ping 发送心跳检测请求。
 // 用于检查虚拟机内的 agent 是否正常运行。
 func (c *VsockClient) Ping(ctx context.Context) error {
@@ -218,6 +450,9 @@ func (c *VsockClient) Ping(ctx context.Context) error {
 	if resp.Type != MessageTypePong {
 		return fmt.Errorf("unexpected response type: %d", resp.Type)
 	}
+	if resp.RequestID != msg.RequestID {
+		return fmt.Errorf("unexpected pong request id: %q", resp.RequestID)
+	}
 
 	return nil
 }
@@ -239,12 +474,12 @@ func (c *VsockClient) sendAndReceive(ctx context.Context, msg *VsockMessage) (*V
 	}
 
 	// 发送消息
-	if err := c.writeMessage(msg); err != nil {
+	if err := c.writeMessage(ctx, msg); err != nil {
 		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
 
 	// 接收响应
-	resp, err := c.readMessage()
+	resp, err := c.readMessage(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to receive message: %w", err)
 	}
@@ -254,15 +489,35 @@ func (c *VsockClient) sendAndReceive(ctx context.Context, msg *VsockMessage) (*V
 
 // writeMessage 将消息写入 vsock 连接。
 // 使用长度前缀协议：4 字节大端序长度 + 消息体。
-func (c *VsockClient) writeMessage(msg *VsockMessage) error {
+// 已协商压缩且消息体不小于 CompressionThreshold 时使用 gzip 压缩，并置位长度前缀最高位。
+// ctx 位于调用链路内时记录 vsock.write span。
+func (c *VsockClient) writeMessage(ctx context.Context, msg *VsockMessage) (err error) {
+	span := startChildSpan(ctx, "vsock.write",
+		attribute.String("request_id", msg.RequestID),
+		attribute.Int("vsock.message_type", int(msg.Type)),
+	)
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
+	if len(data) > MaxMessageBytes {
+		return fmt.Errorf("message too large: %d > %d", len(data), MaxMessageBytes)
+	}
+
+	header := uint32(len(data))
+	if c.compress && len(data) >= CompressionThreshold {
+		if gz, ok := gzipFrame(data); ok {
+			data = gz
+			header = uint32(len(data)) | frameFlagGzip
+		}
+	}
+	span.SetAttributes(attribute.Int("vsock.frame_bytes", len(data)))
 
 	// 写入 4 字节长度前缀（大端序）
 	lenBuf := make([]byte, 4)
-	binary.BigEndian.PutUint32(lenBuf, uint32(len(data)))
+	binary.BigEndian.PutUint32(lenBuf, header)
 	if _, err := c.conn.Write(lenBuf); err != nil {
 		return err
 	}
@@ -276,25 +531,45 @@ func (c *VsockClient) writeMessage(msg *VsockMessage) error {
 }
 
 // readMessage 从 vsock 连接读取消息。
-// 使用长度前缀协议解析消息。
-func (c *VsockClient) readMessage() (*VsockMessage, error) {
+// 使用长度前缀协议解析消息，长度前缀最高位置位时透明解压 gzip 消息体。
+// ctx 位于调用链路内时记录 vsock.read span。
+func (c *VsockClient) readMessage(ctx context.Context) (_ *VsockMessage, err error) {
+	span := startChildSpan(ctx, "vsock.read")
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// 读取 4 字节长度前缀
 	lenBuf := make([]byte, 4)
 	if _, err := io.ReadFull(c.conn, lenBuf); err != nil {
 		return nil, err
 	}
-	length := binary.BigEndian.Uint32(lenBuf)
+	header := binary.BigEndian.Uint32(lenBuf)
+	length := header &^ frameFlagGzip
+	if length > MaxMessageBytes {
+		return nil, fmt.Errorf("message too large: %d > %d", length, MaxMessageBytes)
+	}
 
 	// 读取消息体
 	data := make([]byte, length)
 	if _, err := io.ReadFull(c.conn, data); err != nil {
 		return nil, err
 	}
+	if header&frameFlagGzip != 0 {
+		var err error
+		if data, err = gunzipFrame(data); err != nil {
+			return nil, err
+		}
+	}
+
+	span.SetAttributes(attribute.Int("vsock.frame_bytes", int(length)))
 
 	var msg VsockMessage
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil, err
 	}
+	span.SetAttributes(
+		attribute.String("request_id", msg.RequestID),
+		attribute.Int("vsock.message_type", int(msg.Type)),
+	)
 
 	return &msg, nil
 }
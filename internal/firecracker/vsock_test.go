@@ -0,0 +1,123 @@
+//go:build linux
+// +build linux
+
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestExecuteWithLogsDeliversLogsBeforeResponse(t *testing.T) {
+	const n = 3
+	hostConn, guestConn := net.Pipe()
+	defer hostConn.Close()
+	defer guestConn.Close()
+
+	// 模拟 Agent：确认请求要求转发日志，推送 n 行日志和一条非法日志后返回响应
+	go func() {
+		agent := &VsockClient{conn: guestConn, logger: testLogger()}
+		ctx := context.Background()
+		req, err := agent.readMessage(ctx)
+		if err != nil {
+			t.Errorf("agent read: %v", err)
+			return
+		}
+		var exec ExecPayload
+		if err := json.Unmarshal(req.Payload, &exec); err != nil || !exec.ForwardLogs {
+			t.Errorf("exec payload = %s, want forward_logs", req.Payload)
+		}
+		for i := 0; i < n; i++ {
+			payload, _ := json.Marshal(&LogPayload{Stream: "stdout", Level: "info", Message: fmt.Sprintf("line %d", i)})
+			agent.writeMessage(ctx, &VsockMessage{Type: MessageTypeLog, RequestID: req.RequestID, Payload: payload})
+		}
+		agent.writeMessage(ctx, &VsockMessage{Type: MessageTypeLog, RequestID: req.RequestID, Payload: json.RawMessage(`"bad"`)})
+		agent.writeMessage(ctx, &VsockMessage{Type: MessageTypeResp, RequestID: req.RequestID, Payload: json.RawMessage(`{"success":true}`)})
+	}()
+
+	client := &VsockClient{conn: hostConn, logger: testLogger()}
+	var lines []string
+	resp, err := client.ExecuteWithLogs(context.Background(), "req-1", json.RawMessage(`{}`), func(entry *LogPayload) {
+		lines = append(lines, entry.Message)
+	})
+	if err != nil {
+		t.Fatalf("ExecuteWithLogs: %v", err)
+	}
+	if !resp.Success {
+		t.Errorf("response = %+v, want success", resp)
+	}
+	if len(lines) != n || lines[0] != "line 0" || lines[n-1] != fmt.Sprintf("line %d", n-1) {
+		t.Errorf("log lines = %q, want %d lines in order", lines, n)
+	}
+}
+
+func TestExecuteRefWithLogsSendsReference(t *testing.T) {
+	hostConn, guestConn := net.Pipe()
+	defer hostConn.Close()
+	defer guestConn.Close()
+
+	// 模拟 Agent：确认请求只携带输入引用
+	go func() {
+		agent := &VsockClient{conn: guestConn, logger: testLogger()}
+		ctx := context.Background()
+		req, err := agent.readMessage(ctx)
+		if err != nil {
+			t.Errorf("agent read: %v", err)
+			return
+		}
+		var exec ExecPayload
+		if err := json.Unmarshal(req.Payload, &exec); err != nil || exec.InputRef != "https://objects.example.com/inputs/1" || len(exec.Input) != 0 && string(exec.Input) != "null" {
+			t.Errorf("exec payload = %s, want input_ref without inline input", req.Payload)
+		}
+		agent.writeMessage(ctx, &VsockMessage{Type: MessageTypeResp, RequestID: req.RequestID, Payload: json.RawMessage(`{"success":true}`)})
+	}()
+
+	client := &VsockClient{conn: hostConn, logger: testLogger()}
+	resp, err := client.ExecuteRefWithLogs(context.Background(), "req-1", "https://objects.example.com/inputs/1", nil)
+	if err != nil || !resp.Success {
+		t.Fatalf("ExecuteRefWithLogs = %+v, %v, want success", resp, err)
+	}
+}
+
+func TestInitPayloadFingerprint(t *testing.T) {
+	base := func() *InitPayload {
+		return &InitPayload{
+			FunctionID:    "fn-1",
+			Handler:       "handler.main",
+			Code:          "def main(event): return event",
+			Runtime:       "python3.11",
+			EnvVars:       map[string]string{"A": "1", "B": "2"},
+			MemoryLimitMB: 256,
+			TimeoutSec:    30,
+			Layers:        []LayerInfo{{LayerID: "layer-1", Version: 1, Content: []byte("zip"), ContentHash: "abc"}},
+			WarmupHandler: "warmup",
+		}
+	}
+	want := base().Fingerprint()
+
+	same := base()
+	same.AcceptEncoding = []string{EncodingGzip}
+	same.Layers[0].Content = []byte("not hashed again")
+	if got := same.Fingerprint(); got != want {
+		t.Errorf("fingerprint changed with accept_encoding or hashed layer content")
+	}
+
+	changes := map[string]func(p *InitPayload){
+		"code":          func(p *InitPayload) { p.Code += "\n" },
+		"handler":       func(p *InitPayload) { p.Handler = "handler.other" },
+		"env":           func(p *InitPayload) { p.EnvVars["B"] = "3" },
+		"timeout":       func(p *InitPayload) { p.TimeoutSec = 60 },
+		"layer version": func(p *InitPayload) { p.Layers[0].Version = 2 },
+		"warmup":        func(p *InitPayload) { p.WarmupHandler = "" },
+	}
+	for name, change := range changes {
+		p := base()
+		change(p)
+		if p.Fingerprint() == want {
+			t.Errorf("fingerprint unchanged after %s changed", name)
+		}
+	}
+}
@@ -1,5 +1,5 @@
 // Package gatewayclient 提供访问 Function Gateway HTTP API 的 Go 客户端封装。
-// 该包将常用的函数管理接口（创建/查询/更新/删除）封装为结构化方法，便于在程序中复用。
+// 该包将常用的函数管理接口（创建/查询/更新/删除/调用/日志/回滚/层管理/工作流）封装为结构化方法，便于在程序中复用。
 package gatewayclient
 
 import (
@@ -19,6 +19,7 @@ import (
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	retry      RetryPolicy
 }
 
 // New 创建一个新的客户端。
@@ -32,6 +33,7 @@ func New(baseURL string) *Client {
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		retry: DefaultRetryPolicy,
 	}
 }
 
@@ -63,6 +65,9 @@ type CreateFunctionRequest struct {
 	MemoryMB    int               `json:"memory_mb,omitempty"`
 	TimeoutSec  int               `json:"timeout_sec,omitempty"`
 	EnvVars     map[string]string `json:"env_vars,omitempty"`
+
+	// IdempotencyKey 通过 Idempotency-Key 请求头发送，重试时保持不变；为空时自动生成
+	IdempotencyKey string `json:"-"`
 }
 
 // UpdateFunctionRequest 表示更新函数的请求体（使用指针字段表示“是否更新该字段”）。
@@ -75,6 +80,15 @@ type UpdateFunctionRequest struct {
 	EnvVars     *map[string]string `json:"env_vars,omitempty"`
 }
 
+// ValidationResult 表示函数定义预检（dry-run）的结果。
+// Valid 为 false 时 Errors 列出校验错误；Warnings（如超出配额）不影响 Valid。
+type ValidationResult struct {
+	Valid    bool      `json:"valid"`
+	Function *Function `json:"function"`
+	Errors   []string  `json:"errors,omitempty"`
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
 // ListFunctionsResponse 表示函数列表查询响应。
 type ListFunctionsResponse struct {
 	Functions []Function `json:"functions"`
@@ -83,6 +97,58 @@ type ListFunctionsResponse struct {
 	Limit     int        `json:"limit"`
 }
 
+// InvokeResponse 表示同步调用函数的响应。
+type InvokeResponse struct {
+	RequestID    string          `json:"request_id"`
+	StatusCode   int             `json:"status_code"`
+	Body         json.RawMessage `json:"body,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	DurationMs   int64           `json:"duration_ms"`
+	ColdStart    bool            `json:"cold_start"`
+	BilledTimeMs int64           `json:"billed_time_ms"`
+	Version      int             `json:"version,omitempty"`
+}
+
+// BatchInvokeItem 表示批量调用中单个输入的调用结果。
+type BatchInvokeItem struct {
+	Index      int             `json:"index"`
+	RequestID  string          `json:"request_id,omitempty"`
+	StatusCode int             `json:"status_code"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+	ColdStart  bool            `json:"cold_start"`
+}
+
+// BatchInvokeResponse 表示批量调用的响应，Results 与输入顺序一致。
+type BatchInvokeResponse struct {
+	FunctionID string            `json:"function_id"`
+	Results    []BatchInvokeItem `json:"results"`
+	Succeeded  int               `json:"succeeded"`
+	Failed     int               `json:"failed"`
+}
+
+// LogEntry 表示一条已落库的函数日志。
+type LogEntry struct {
+	Timestamp    time.Time       `json:"timestamp"`
+	Level        string          `json:"level"`
+	FunctionID   string          `json:"function_id"`
+	FunctionName string          `json:"function_name"`
+	Message      string          `json:"message"`
+	RequestID    string          `json:"request_id,omitempty"`
+	Input        json.RawMessage `json:"input,omitempty"`
+	Output       json.RawMessage `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	DurationMs   int64           `json:"duration_ms,omitempty"`
+}
+
+// LogQuery 表示函数日志的过滤条件（零值字段不过滤）。
+type LogQuery struct {
+	Level     string
+	RequestID string
+	Limit     int
+}
+
 // apiError 是网关返回的标准错误结构。
 type apiError struct {
 	Message string `json:"error"`
@@ -101,6 +167,33 @@ func (e *apiError) Error() string {
 // - 发起 HTTP 请求并解析 JSON 响应
 // - 将 4xx/5xx 转换为可读错误
 func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, result any) error {
+	status, respBody, err := c.send(ctx, method, path, query, body, nil)
+	if err != nil {
+		return err
+	}
+	if status >= 400 {
+		return responseError(status, respBody)
+	}
+	return decodeResult(respBody, result)
+}
+
+// decodeResult 将成功响应的 JSON 响应体解析到 result（result 为 nil 时忽略响应体）。
+func decodeResult(respBody []byte, result any) error {
+	if result == nil {
+		return nil
+	}
+	if len(respBody) == 0 {
+		return errors.New("empty response body")
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("parse response: %w", err)
+	}
+	return nil
+}
+
+// send 发起 HTTP 请求，返回状态码与原始响应体（不解释状态码）。
+// header 中的请求头会附加到请求上，可以为 nil。
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any, header http.Header) (int, []byte, error) {
 	u := c.baseURL + path
 	if len(query) > 0 {
 		u += "?" + query.Encode()
@@ -110,56 +203,41 @@ func (c *Client) do(ctx context.Context, method, path string, query url.Values,
 	if body != nil {
 		data, err := json.Marshal(body)
 		if err != nil {
-			return fmt.Errorf("marshal request: %w", err)
+			return 0, nil, fmt.Errorf("marshal request: %w", err)
 		}
 		reqBody = bytes.NewReader(data)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
 	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+		return 0, nil, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return 0, nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("read response: %w", err)
-	}
-
-	if resp.StatusCode >= 400 {
-		var apiErr apiError
-		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Message != "" {
-			return &apiErr
-		}
-		return fmt.Errorf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
-	}
-
-	if result == nil {
-		return nil
-	}
-	if len(respBody) == 0 {
-		return errors.New("empty response body")
-	}
-	if err := json.Unmarshal(respBody, result); err != nil {
-		return fmt.Errorf("parse response: %w", err)
+		return 0, nil, fmt.Errorf("read response: %w", err)
 	}
-	return nil
+	return resp.StatusCode, respBody, nil
 }
 
-// CreateFunction 创建函数。
-func (c *Client) CreateFunction(ctx context.Context, req *CreateFunctionRequest) (*Function, error) {
-	var fn Function
-	if err := c.do(ctx, http.MethodPost, "/api/v1/functions", nil, req, &fn); err != nil {
-		return nil, err
+// responseError 将 4xx/5xx 响应转换为可读错误。
+func responseError(status int, body []byte) error {
+	var apiErr apiError
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+		return &apiErr
 	}
-	return &fn, nil
+	return fmt.Errorf("http %d: %s", status, strings.TrimSpace(string(body)))
 }
 
 // ListFunctions 获取函数列表（支持 offset/limit 分页）。
@@ -196,7 +274,94 @@ func (c *Client) UpdateFunction(ctx context.Context, idOrName string, req *Updat
 	return &fn, nil
 }
 
+// ValidateFunction 预检函数定义而不保存：校验名称、运行时、处理器和资源限制并检查配额，
+// 返回将要写入的函数定义和警告。existing 为已有函数的 ID 或 name 时按更新该函数预检，
+// 此时 req 中的 Name 和 Runtime 可以为空。
+func (c *Client) ValidateFunction(ctx context.Context, req *CreateFunctionRequest, existing string) (*ValidationResult, error) {
+	var q url.Values
+	if existing != "" {
+		q = url.Values{"function": {existing}}
+	}
+	var result ValidationResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/functions/validate", q, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 // DeleteFunction 删除函数（按 ID 或 name）。
 func (c *Client) DeleteFunction(ctx context.Context, idOrName string) error {
 	return c.do(ctx, http.MethodDelete, "/api/v1/functions/"+url.PathEscape(idOrName), nil, nil, nil)
 }
+
+// RollbackFunction 将函数（按 ID 或 name）回滚到指定版本，返回回滚后的函数。
+// 网关将目标版本的 handler/code/binary 复制到当前函数并递增版本号；版本不存在时返回错误。
+func (c *Client) RollbackFunction(ctx context.Context, idOrName string, version int) (*Function, error) {
+	var fn Function
+	path := fmt.Sprintf("/api/v1/functions/%s/versions/%d/rollback", url.PathEscape(idOrName), version)
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, &fn); err != nil {
+		return nil, err
+	}
+	return &fn, nil
+}
+
+// InvokeFunction 同步调用函数（按 ID 或 name），input 为函数的 JSON 输入。
+// 函数自身执行失败时网关仍返回调用结果（StatusCode 非 2xx，Error 为错误信息），此时不返回 error；
+// 只有函数不存在、调度失败等网关错误才返回 error。
+func (c *Client) InvokeFunction(ctx context.Context, idOrName string, input json.RawMessage) (*InvokeResponse, error) {
+	status, respBody, err := c.send(ctx, http.MethodPost, "/api/v1/functions/"+url.PathEscape(idOrName)+"/invoke", nil, input, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp InvokeResponse
+	if json.Unmarshal(respBody, &resp) == nil && resp.StatusCode != 0 {
+		return &resp, nil
+	}
+	if status >= 400 {
+		return nil, responseError(status, respBody)
+	}
+	return nil, fmt.Errorf("parse response: unexpected invoke response %s", strings.TrimSpace(string(respBody)))
+}
+
+// InvokeBatch 对每个输入各同步调用一次函数（按 ID 或 name），返回与输入顺序一致的结果。
+// 单个调用失败不会使整个请求失败，失败信息在对应结果的 StatusCode/Error 中；
+// 网关最多同时执行 10 个调用且不超过函数的 max_concurrency，单次最多 100 个输入。
+func (c *Client) InvokeBatch(ctx context.Context, idOrName string, inputs []json.RawMessage) (*BatchInvokeResponse, error) {
+	body := map[string]any{"inputs": inputs}
+	var resp BatchInvokeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/functions/"+url.PathEscape(idOrName)+"/invoke-batch", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetFunctionLogs 查询函数（按 ID 或 name）的日志，按时间倒序返回（最新在前）。
+// 先解析函数 ID，再通过控制台日志接口按 function_id 过滤。
+func (c *Client) GetFunctionLogs(ctx context.Context, idOrName string, query *LogQuery) ([]LogEntry, error) {
+	fn, err := c.GetFunction(ctx, idOrName)
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+	q.Set("function_id", fn.ID)
+	if query != nil {
+		if query.Level != "" {
+			q.Set("level", query.Level)
+		}
+		if query.RequestID != "" {
+			q.Set("request_id", query.RequestID)
+		}
+		if query.Limit > 0 {
+			q.Set("limit", fmt.Sprintf("%d", query.Limit))
+		}
+	}
+	var resp struct {
+		Data []LogEntry `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/console/logs", q, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
@@ -0,0 +1,359 @@
+package gatewayclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInvokeFunction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/functions/hello/invoke":
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"request_id":"req-1","status_code":200,"body":` + string(body) + `,"duration_ms":12,"cold_start":true}`))
+		case "/api/v1/functions/broken/invoke":
+			// 函数执行失败：网关以函数状态码返回调用结果
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"request_id":"req-2","status_code":500,"error":"python error: boom","duration_ms":3}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"function not found: missing"}`))
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+
+	resp, err := c.InvokeFunction(context.Background(), "hello", json.RawMessage(`{"n":1}`))
+	if err != nil {
+		t.Fatalf("InvokeFunction() error = %v", err)
+	}
+	if resp.StatusCode != 200 || string(resp.Body) != `{"n":1}` || resp.DurationMs != 12 || !resp.ColdStart {
+		t.Errorf("InvokeFunction() = %+v", resp)
+	}
+
+	resp, err = c.InvokeFunction(context.Background(), "broken", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("InvokeFunction() on function error returned error %v, want result", err)
+	}
+	if resp.StatusCode != 500 || resp.Error != "python error: boom" {
+		t.Errorf("InvokeFunction() = %+v", resp)
+	}
+
+	if _, err := c.InvokeFunction(context.Background(), "missing", json.RawMessage(`{}`)); err == nil || err.Error() != "function not found: missing" {
+		t.Errorf("InvokeFunction() error = %v, want gateway error", err)
+	}
+}
+
+func TestInvokeBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/functions/hello/invoke-batch" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"function not found: missing"}`))
+			return
+		}
+		var req struct {
+			Inputs []json.RawMessage `json:"inputs"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Inputs) != 2 || string(req.Inputs[0]) != `{"n":1}` {
+			t.Errorf("inputs = %s", req.Inputs)
+		}
+		w.Write([]byte(`{"function_id":"fn-1","results":[
+			{"index":0,"request_id":"req-1","status_code":200,"body":{"n":1}},
+			{"index":1,"status_code":429,"error":"function concurrency limit exceeded"}
+		],"succeeded":1,"failed":1}`))
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+
+	resp, err := c.InvokeBatch(context.Background(), "hello", []json.RawMessage{json.RawMessage(`{"n":1}`), json.RawMessage(`{"n":2}`)})
+	if err != nil {
+		t.Fatalf("InvokeBatch() error = %v", err)
+	}
+	if resp.Succeeded != 1 || resp.Failed != 1 || len(resp.Results) != 2 {
+		t.Fatalf("InvokeBatch() = %+v", resp)
+	}
+	if r := resp.Results[1]; r.Index != 1 || r.StatusCode != 429 || r.Error == "" {
+		t.Errorf("failed item = %+v", r)
+	}
+
+	if _, err := c.InvokeBatch(context.Background(), "missing", nil); err == nil || err.Error() != "function not found: missing" {
+		t.Errorf("InvokeBatch() error = %v, want gateway error", err)
+	}
+}
+
+func TestGetFunctionLogs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/functions/hello":
+			w.Write([]byte(`{"id":"fn-1","name":"hello"}`))
+		case "/api/console/logs":
+			q := r.URL.Query()
+			if q.Get("function_id") != "fn-1" || q.Get("level") != "ERROR" || q.Get("request_id") != "req-1" || q.Get("limit") != "10" {
+				t.Errorf("unexpected log query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"data":[{"timestamp":"2026-01-02T03:04:05Z","level":"ERROR","function_id":"fn-1","function_name":"hello","message":"函数调用失败","request_id":"req-1","error":"boom","duration_ms":7}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	entries, err := New(srv.URL).GetFunctionLogs(context.Background(), "hello", &LogQuery{Level: "ERROR", RequestID: "req-1", Limit: 10})
+	if err != nil {
+		t.Fatalf("GetFunctionLogs() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Error != "boom" || entries[0].DurationMs != 7 || entries[0].Timestamp.IsZero() {
+		t.Errorf("GetFunctionLogs() = %+v", entries)
+	}
+}
+
+func TestRollbackFunction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v1/functions/hello/versions/2/rollback" {
+			w.Write([]byte(`{"id":"fn-1","name":"hello","handler":"handler.handler","version":5}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"version not found"}`))
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+
+	fn, err := c.RollbackFunction(context.Background(), "hello", 2)
+	if err != nil {
+		t.Fatalf("RollbackFunction() error = %v", err)
+	}
+	if fn.ID != "fn-1" || fn.Version != 5 {
+		t.Errorf("RollbackFunction() = %+v", fn)
+	}
+	if _, err := c.RollbackFunction(context.Background(), "hello", 9); err == nil || err.Error() != "version not found" {
+		t.Errorf("RollbackFunction() error = %v, want version not found", err)
+	}
+}
+
+func TestValidateFunction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/functions/validate" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req CreateFunctionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Query().Get("function") != "hello" || req.Name != "" || req.MemoryMB != 2048 {
+			t.Errorf("unexpected validate request %s %+v", r.URL.RawQuery, req)
+		}
+		w.Write([]byte(`{"valid":true,"function":{"id":"fn-1","name":"hello","memory_mb":2048,"version":3},"warnings":["quota exceeded: max total memory (4096/3072 MB)"]}`))
+	}))
+	defer srv.Close()
+
+	result, err := New(srv.URL).ValidateFunction(context.Background(), &CreateFunctionRequest{MemoryMB: 2048}, "hello")
+	if err != nil {
+		t.Fatalf("ValidateFunction() error = %v", err)
+	}
+	if !result.Valid || result.Function == nil || result.Function.MemoryMB != 2048 || len(result.Warnings) != 1 {
+		t.Errorf("ValidateFunction() = %+v", result)
+	}
+}
+
+// flakyCreateServer 模拟创建函数接口：前 failures 次请求返回 failStatus。
+// createdOnFailure 非空时，失败的请求会留下以该代码创建的同名函数，
+// 模拟网关已创建但响应丢失（代码相同）或其他调用方抢先创建（代码不同）。
+type flakyCreateServer struct {
+	failures         int
+	failStatus       int
+	createdOnFailure string
+	existingHash     string // 已存在的同名函数的代码哈希，为空表示不存在
+
+	mu       sync.Mutex
+	attempts int
+	keys     []string
+}
+
+func (s *flakyCreateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/functions":
+		s.attempts++
+		s.keys = append(s.keys, r.Header.Get(IdempotencyKeyHeader))
+		var req CreateFunctionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if s.existingHash != "" {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":"function with this name already exists"}`))
+			return
+		}
+		if s.attempts <= s.failures {
+			if s.createdOnFailure != "" {
+				s.existingHash = codeHash(s.createdOnFailure)
+			}
+			w.WriteHeader(s.failStatus)
+			w.Write([]byte(`{"error":"temporarily unavailable"}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"id":"fn-1","name":"` + req.Name + `","code_hash":"` + codeHash(req.Code) + `"}`))
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/functions/hello" && s.existingHash != "":
+		w.Write([]byte(`{"id":"fn-existing","name":"hello","code_hash":"` + s.existingHash + `"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"function not found"}`))
+	}
+}
+
+func newRetryingClient(url string) *Client {
+	c := New(url)
+	c.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	return c
+}
+
+func TestCreateFunctionRetriesTransientErrors(t *testing.T) {
+	srv := &flakyCreateServer{failures: 2, failStatus: http.StatusServiceUnavailable}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	fn, err := newRetryingClient(ts.URL).CreateFunction(context.Background(), &CreateFunctionRequest{Name: "hello", Code: "print(1)"})
+	if err != nil {
+		t.Fatalf("CreateFunction() error = %v", err)
+	}
+	if fn.ID != "fn-1" {
+		t.Errorf("CreateFunction() = %+v", fn)
+	}
+	if srv.attempts != 3 {
+		t.Errorf("attempts = %d, want 3", srv.attempts)
+	}
+	for _, key := range srv.keys {
+		if key == "" || key != srv.keys[0] {
+			t.Errorf("idempotency keys = %v, want one shared non-empty key", srv.keys)
+			break
+		}
+	}
+}
+
+func TestCreateFunctionGivesUpAfterMaxAttempts(t *testing.T) {
+	srv := &flakyCreateServer{failures: 10, failStatus: http.StatusBadGateway}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	_, err := newRetryingClient(ts.URL).CreateFunction(context.Background(), &CreateFunctionRequest{Name: "hello", Code: "print(1)", IdempotencyKey: "key-1"})
+	if err == nil || err.Error() != "temporarily unavailable" {
+		t.Fatalf("CreateFunction() error = %v, want last gateway error", err)
+	}
+	if srv.attempts != 3 || srv.keys[0] != "key-1" {
+		t.Errorf("attempts = %d, keys = %v", srv.attempts, srv.keys)
+	}
+}
+
+func TestCreateFunctionConflictAfterRetry(t *testing.T) {
+	tests := []struct {
+		name             string
+		createdOnFailure string
+		wantID           string
+		wantConflict     bool
+	}{
+		// 第一次请求已创建函数但返回 500，重试遇到同名冲突，代码哈希一致视为成功
+		{name: "same code", createdOnFailure: "print(1)", wantID: "fn-existing"},
+		// 其他调用方抢先创建了代码不同的同名函数
+		{name: "different code", createdOnFailure: "print(2)", wantConflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &flakyCreateServer{failures: 1, failStatus: http.StatusInternalServerError, createdOnFailure: tt.createdOnFailure}
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+
+			fn, err := newRetryingClient(ts.URL).CreateFunction(context.Background(), &CreateFunctionRequest{Name: "hello", Code: "print(1)"})
+			if srv.attempts != 2 {
+				t.Errorf("attempts = %d, want 2", srv.attempts)
+			}
+			if !tt.wantConflict {
+				if err != nil || fn.ID != tt.wantID {
+					t.Fatalf("CreateFunction() = %+v, %v", fn, err)
+				}
+				return
+			}
+			var conflict *ConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("CreateFunction() error = %v, want *ConflictError", err)
+			}
+			if conflict.ExistingCodeHash != codeHash("print(2)") {
+				t.Errorf("ExistingCodeHash = %q", conflict.ExistingCodeHash)
+			}
+		})
+	}
+}
+
+func TestCreateFunctionConflictOnFirstAttempt(t *testing.T) {
+	// 首次尝试即冲突：即使代码相同也不是本次创建的，不重试并返回冲突错误
+	srv := &flakyCreateServer{existingHash: codeHash("print(1)")}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	_, err := newRetryingClient(ts.URL).CreateFunction(context.Background(), &CreateFunctionRequest{Name: "hello", Code: "print(1)"})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Name != "hello" || conflict.Error() != "function with this name already exists" {
+		t.Fatalf("CreateFunction() error = %v, want *ConflictError", err)
+	}
+	if srv.attempts != 1 {
+		t.Errorf("attempts = %d, want 1", srv.attempts)
+	}
+}
+
+func TestCreateFunctionDoesNotRetryClientErrors(t *testing.T) {
+	srv := &flakyCreateServer{failures: 5, failStatus: http.StatusBadRequest}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	if _, err := newRetryingClient(ts.URL).CreateFunction(context.Background(), &CreateFunctionRequest{Name: "hello"}); err == nil {
+		t.Fatal("CreateFunction() error = nil, want bad request")
+	}
+	if srv.attempts != 1 {
+		t.Errorf("attempts = %d, want 1", srv.attempts)
+	}
+}
+
+func TestValidateWorkflowDefinition(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		wantErr    string
+	}{
+		{"single pass state", `{"start_at":"A","states":{"A":{"type":"Pass","end":true}}}`, ""},
+		{"choice and parallel", `{"start_at":"C","states":{
+			"C":{"type":"Choice","choices":[{"variable":"$.ok","boolean_equals":true,"next":"P"}],"default":"F"},
+			"P":{"type":"Parallel","branches":[{"start_at":"T","states":{"T":{"type":"Task","function_id":"fn-1","end":true}}}],"end":true},
+			"F":{"type":"Fail","error":"NotOK"}}}`, ""},
+		{"map state", `{"start_at":"M","states":{"M":{"type":"Map","items_path":"$.items","iterator":{"start_at":"T","states":{"T":{"type":"Task","function_id":"fn-1","end":true}}},"end":true}}}`, ""},
+		{"map without iterator", `{"start_at":"M","states":{"M":{"type":"Map","end":true}}}`, "Map state requires iterator"},
+		{"not an object", `[]`, "invalid workflow definition"},
+		{"missing start state", `{"start_at":"B","states":{"A":{"type":"Pass","end":true}}}`, `start_at "B" is not a defined state`},
+		{"unknown type", `{"start_at":"A","states":{"A":{"type":"Lambda","end":true}}}`, `unknown state type "Lambda"`},
+		{"dangling next", `{"start_at":"A","states":{"A":{"type":"Pass","next":"B"}}}`, `next state "B" is not defined`},
+		{"task without function", `{"start_at":"A","states":{"A":{"type":"Task","end":true}}}`, "Task state requires function_id"},
+		{"invalid branch", `{"start_at":"P","states":{"P":{"type":"Parallel","branches":[{"start_at":"X","states":{}}],"end":true}}}`, "states.P.branches[0].states must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWorkflowDefinition(json.RawMessage(tt.definition))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateWorkflowDefinition() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateWorkflowDefinition() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package gatewayclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader 是创建函数时携带幂等键的请求头。
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryPolicy 定义创建函数遇到网络错误或 5xx/429 响应时的重试策略。
+type RetryPolicy struct {
+	MaxAttempts int           // 最多尝试次数（含首次），不大于 1 表示不重试
+	BaseDelay   time.Duration // 第一次重试前的基础等待时间，之后每次翻倍
+	MaxDelay    time.Duration // 单次等待时间上限
+}
+
+// DefaultRetryPolicy 是客户端默认的重试策略。
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
+
+// SetRetryPolicy 设置创建函数的重试策略。
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c.retry = p
+}
+
+// backoff 返回第 attempt 次失败后的等待时间：指数退避并在后一半区间内随机抖动，避免多个客户端同时重试。
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(mathrand.Int63n(int64(d-half)+1))
+}
+
+// ConflictError 表示同名函数已存在且不是本次请求创建的。
+type ConflictError struct {
+	Name string
+	// ExistingCodeHash 是已存在函数的代码哈希，仅在重试后发生冲突时查询并填充
+	ExistingCodeHash string
+	// Message 是网关返回的错误信息
+	Message string
+}
+
+func (e *ConflictError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("function %s already exists", e.Name)
+}
+
+// retryableStatus 判断响应状态码是否为可重试的临时错误。
+func retryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests
+}
+
+// newIdempotencyKey 生成随机幂等键。
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// codeHash 按网关的算法计算代码哈希（SHA-256 十六进制）。
+func codeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateFunction 创建函数。
+// 遇到网络错误或 5xx/429 响应时按 RetryPolicy 退避重试，所有尝试携带相同的幂等键，
+// 网关据此对前一次已创建成功（只是响应丢失）的请求直接返回已创建的函数。
+// 幂等键未生效时（如旧版网关）重试会遇到同名冲突，此时查询已存在的函数：
+// 代码哈希与本次请求一致则视为创建成功并返回该函数，否则返回 *ConflictError。
+// 首次尝试即冲突时直接返回 *ConflictError。
+func (c *Client) CreateFunction(ctx context.Context, req *CreateFunctionRequest) (*Function, error) {
+	key := req.IdempotencyKey
+	if key == "" {
+		key = newIdempotencyKey()
+	}
+	header := http.Header{}
+	header.Set(IdempotencyKeyHeader, key)
+
+	for attempt := 1; ; attempt++ {
+		status, respBody, err := c.send(ctx, http.MethodPost, "/api/v1/functions", nil, req, header)
+		if err == nil {
+			switch {
+			case status < 400:
+				var fn Function
+				if err := decodeResult(respBody, &fn); err != nil {
+					return nil, err
+				}
+				return &fn, nil
+			case status == http.StatusConflict:
+				conflict := &ConflictError{Name: req.Name, Message: responseError(status, respBody).Error()}
+				if attempt == 1 {
+					return nil, conflict
+				}
+				return c.resolveCreateConflict(ctx, req, conflict)
+			case !retryableStatus(status):
+				return nil, responseError(status, respBody)
+			}
+			err = responseError(status, respBody)
+		}
+		if ctx.Err() != nil || attempt >= c.retry.MaxAttempts {
+			return nil, err
+		}
+
+		timer := time.NewTimer(c.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// resolveCreateConflict 处理重试后的同名冲突：已存在函数的代码哈希与请求一致时视为本次创建成功。
+func (c *Client) resolveCreateConflict(ctx context.Context, req *CreateFunctionRequest, conflict *ConflictError) (*Function, error) {
+	existing, err := c.GetFunction(ctx, req.Name)
+	if err != nil {
+		return nil, conflict
+	}
+	conflict.ExistingCodeHash = existing.CodeHash
+	if existing.CodeHash != codeHash(req.Code) {
+		return nil, conflict
+	}
+	return existing, nil
+}
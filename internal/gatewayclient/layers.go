@@ -0,0 +1,167 @@
+package gatewayclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Layer 表示共享依赖层（与网关 API 的 JSON 字段对应）。
+type Layer struct {
+	ID                 string    `json:"id"`
+	Name               string    `json:"name"`
+	Description        string    `json:"description,omitempty"`
+	CompatibleRuntimes []string  `json:"compatible_runtimes"`
+	LatestVersion      int       `json:"latest_version"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// LayerVersion 表示层的一个不可变版本。
+type LayerVersion struct {
+	ID          string    `json:"id"`
+	LayerID     string    `json:"layer_id"`
+	Version     int       `json:"version"`
+	ContentHash string    `json:"content_hash"`
+	SizeBytes   int64     `json:"size_bytes"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LayerDetail 表示层详情及其全部版本。
+type LayerDetail struct {
+	Layer    Layer          `json:"layer"`
+	Versions []LayerVersion `json:"versions"`
+}
+
+// FunctionLayer 表示函数使用的一个层版本，切片顺序即加载顺序。
+type FunctionLayer struct {
+	LayerID      string `json:"layer_id"`
+	LayerName    string `json:"layer_name,omitempty"`
+	LayerVersion int    `json:"layer_version"`
+	Order        int    `json:"order"`
+	ContentHash  string `json:"content_hash,omitempty"`
+}
+
+// CreateLayerRequest 表示创建层的请求体。
+type CreateLayerRequest struct {
+	Name               string   `json:"name"`
+	Description        string   `json:"description,omitempty"`
+	CompatibleRuntimes []string `json:"compatible_runtimes"`
+}
+
+// ListLayersResponse 表示层列表查询响应。
+type ListLayersResponse struct {
+	Layers []Layer `json:"layers"`
+	Total  int     `json:"total"`
+	Offset int     `json:"offset"`
+	Limit  int     `json:"limit"`
+}
+
+// ListLayers 获取层列表（支持 offset/limit 分页）。
+func (c *Client) ListLayers(ctx context.Context, offset, limit int) (*ListLayersResponse, error) {
+	q := url.Values{}
+	if offset > 0 {
+		q.Set("offset", fmt.Sprintf("%d", offset))
+	}
+	if limit > 0 {
+		q.Set("limit", fmt.Sprintf("%d", limit))
+	}
+	var resp ListLayersResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/layers", q, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetLayer 根据 ID 或 name 获取层详情及其版本列表。
+func (c *Client) GetLayer(ctx context.Context, idOrName string) (*LayerDetail, error) {
+	var detail LayerDetail
+	if err := c.do(ctx, http.MethodGet, "/api/v1/layers/"+url.PathEscape(idOrName), nil, nil, &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// CreateLayer 创建层（不含内容），之后通过 CreateLayerVersion 上传内容。同名层已存在时网关返回错误。
+func (c *Client) CreateLayer(ctx context.Context, req *CreateLayerRequest) (*Layer, error) {
+	var layer Layer
+	if err := c.do(ctx, http.MethodPost, "/api/v1/layers", nil, req, &layer); err != nil {
+		return nil, err
+	}
+	return &layer, nil
+}
+
+// CreateLayerVersion 为层（按 ID 或 name）上传 ZIP 内容，网关以最新版本号加一创建新版本。
+func (c *Client) CreateLayerVersion(ctx context.Context, idOrName string, content []byte) (*LayerVersion, error) {
+	// 层内容接口接收 multipart 表单（字段 content），不能走 JSON 请求
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("content", "layer.zip")
+	if err != nil {
+		return nil, fmt.Errorf("create form file: %w", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, fmt.Errorf("write form file: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("close multipart writer: %w", err)
+	}
+
+	u := c.baseURL + "/api/v1/layers/" + url.PathEscape(idOrName) + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, &body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, responseError(resp.StatusCode, respBody)
+	}
+
+	var lv LayerVersion
+	if err := decodeResult(respBody, &lv); err != nil {
+		return nil, err
+	}
+	return &lv, nil
+}
+
+// GetFunctionLayers 获取函数（按 ID 或 name）使用的层，按加载顺序排列。
+func (c *Client) GetFunctionLayers(ctx context.Context, idOrName string) ([]FunctionLayer, error) {
+	var resp struct {
+		Layers []FunctionLayer `json:"layers"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/functions/"+url.PathEscape(idOrName)+"/layers", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Layers, nil
+}
+
+// SetFunctionLayers 用 layers 整体替换函数（按 ID 或 name）使用的层，切片顺序即加载顺序，传空切片表示移除全部层。
+// 网关不校验层与函数运行时是否兼容，调用方需要自行检查。
+func (c *Client) SetFunctionLayers(ctx context.Context, idOrName string, layers []FunctionLayer) ([]FunctionLayer, error) {
+	if layers == nil {
+		layers = []FunctionLayer{}
+	}
+	var resp struct {
+		Layers []FunctionLayer `json:"layers"`
+	}
+	if err := c.do(ctx, http.MethodPut, "/api/v1/functions/"+url.PathEscape(idOrName)+"/layers", nil, layers, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Layers, nil
+}
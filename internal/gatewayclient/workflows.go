@@ -0,0 +1,212 @@
+package gatewayclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Workflow 表示工作流（与网关 API 的 JSON 字段对应），Definition 保持原始 JSON。
+type Workflow struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Version     int             `json:"version"`
+	Status      string          `json:"status"`
+	Definition  json.RawMessage `json:"definition"`
+	TimeoutSec  int             `json:"timeout_sec"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// CreateWorkflowRequest 表示创建工作流的请求体。
+type CreateWorkflowRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Definition  json.RawMessage `json:"definition"`
+	TimeoutSec  int             `json:"timeout_sec,omitempty"`
+}
+
+// WorkflowExecution 表示工作流的一次执行。
+type WorkflowExecution struct {
+	ID           string          `json:"id"`
+	WorkflowID   string          `json:"workflow_id"`
+	WorkflowName string          `json:"workflow_name"`
+	Status       string          `json:"status"`
+	Input        json.RawMessage `json:"input,omitempty"`
+	Output       json.RawMessage `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	ErrorCode    string          `json:"error_code,omitempty"`
+	CurrentState string          `json:"current_state,omitempty"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	CompletedAt  *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// IsTerminal 判断执行是否已结束（成功、失败、超时或取消）。
+func (e *WorkflowExecution) IsTerminal() bool {
+	switch e.Status {
+	case "succeeded", "failed", "timeout", "cancelled":
+		return true
+	default:
+		return false
+	}
+}
+
+// workflowState 是校验工作流定义时关心的状态字段。
+type workflowState struct {
+	Type       string `json:"type"`
+	Next       string `json:"next"`
+	End        bool   `json:"end"`
+	FunctionID string `json:"function_id"`
+	Choices    []struct {
+		Next string `json:"next"`
+	} `json:"choices"`
+	Default  string               `json:"default"`
+	Branches []workflowDefinition `json:"branches"`
+	Iterator *workflowDefinition  `json:"iterator"`
+	Catch    []struct {
+		Next string `json:"next"`
+	} `json:"catch"`
+}
+
+// workflowDefinition 是工作流定义（以及 Parallel 分支、Map 迭代分支）的结构。
+type workflowDefinition struct {
+	StartAt string                   `json:"start_at"`
+	States  map[string]workflowState `json:"states"`
+}
+
+// ValidateWorkflowDefinition 检查工作流定义的 JSON 结构：start_at 指向已定义的状态、状态类型合法、
+// 每个状态的跳转目标都存在、非终止状态有 next 或 end、Task 指定了 function_id，Parallel 分支和 Map 迭代分支递归检查。
+// 只检查结构，不检查函数是否存在。
+func ValidateWorkflowDefinition(definition json.RawMessage) error {
+	var def workflowDefinition
+	if err := json.Unmarshal(definition, &def); err != nil {
+		return fmt.Errorf("invalid workflow definition: %w", err)
+	}
+	return validateWorkflowDefinition(&def, "")
+}
+
+// validateWorkflowDefinition 校验一个定义或分支，path 为错误信息中的位置前缀。
+func validateWorkflowDefinition(def *workflowDefinition, path string) error {
+	if def.StartAt == "" {
+		return fmt.Errorf("invalid workflow definition: %sstart_at is required", path)
+	}
+	if len(def.States) == 0 {
+		return fmt.Errorf("invalid workflow definition: %sstates must not be empty", path)
+	}
+	if _, ok := def.States[def.StartAt]; !ok {
+		return fmt.Errorf("invalid workflow definition: %sstart_at %q is not a defined state", path, def.StartAt)
+	}
+
+	// 按名称排序，使同一定义总是报告同一个错误
+	names := make([]string, 0, len(def.States))
+	for name := range def.States {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		state := def.States[name]
+		where := fmt.Sprintf("%sstates.%s", path, name)
+		targets := []string{state.Next}
+		switch state.Type {
+		case "Task":
+			if state.FunctionID == "" {
+				return fmt.Errorf("invalid workflow definition: %s: Task state requires function_id", where)
+			}
+			for _, c := range state.Catch {
+				targets = append(targets, c.Next)
+			}
+		case "Choice":
+			if len(state.Choices) == 0 {
+				return fmt.Errorf("invalid workflow definition: %s: Choice state requires choices", where)
+			}
+			for _, c := range state.Choices {
+				if c.Next == "" {
+					return fmt.Errorf("invalid workflow definition: %s: every choice requires next", where)
+				}
+				targets = append(targets, c.Next)
+			}
+			targets = append(targets, state.Default)
+		case "Parallel":
+			if len(state.Branches) == 0 {
+				return fmt.Errorf("invalid workflow definition: %s: Parallel state requires branches", where)
+			}
+			for i := range state.Branches {
+				if err := validateWorkflowDefinition(&state.Branches[i], fmt.Sprintf("%s.branches[%d].", where, i)); err != nil {
+					return err
+				}
+			}
+			for _, c := range state.Catch {
+				targets = append(targets, c.Next)
+			}
+		case "Map":
+			if state.Iterator == nil {
+				return fmt.Errorf("invalid workflow definition: %s: Map state requires iterator", where)
+			}
+			if err := validateWorkflowDefinition(state.Iterator, where+".iterator."); err != nil {
+				return err
+			}
+			for _, c := range state.Catch {
+				targets = append(targets, c.Next)
+			}
+		case "Wait", "Pass", "Succeed", "Fail":
+		default:
+			return fmt.Errorf("invalid workflow definition: %s: unknown state type %q", where, state.Type)
+		}
+
+		for _, target := range targets {
+			if target == "" {
+				continue
+			}
+			if _, ok := def.States[target]; !ok {
+				return fmt.Errorf("invalid workflow definition: %s: next state %q is not defined", where, target)
+			}
+		}
+		// Choice/Succeed/Fail 自行决定去向，其余状态必须声明下一步或结束
+		if state.Type != "Choice" && state.Type != "Succeed" && state.Type != "Fail" && state.Next == "" && !state.End {
+			return fmt.Errorf("invalid workflow definition: %s: state requires next or end", where)
+		}
+	}
+	return nil
+}
+
+// CreateWorkflow 创建工作流。提交前先在本地校验定义结构，同名工作流已存在时网关返回错误。
+func (c *Client) CreateWorkflow(ctx context.Context, req *CreateWorkflowRequest) (*Workflow, error) {
+	if err := ValidateWorkflowDefinition(req.Definition); err != nil {
+		return nil, err
+	}
+	var wf Workflow
+	if err := c.do(ctx, http.MethodPost, "/api/v1/workflows", nil, req, &wf); err != nil {
+		return nil, err
+	}
+	return &wf, nil
+}
+
+// StartWorkflowExecution 以 input 为输入启动工作流（按 ID 或 name）的一次执行，返回刚创建的执行。
+// 执行在网关中异步推进，通过 GetWorkflowExecution 查询进度和结果。
+func (c *Client) StartWorkflowExecution(ctx context.Context, idOrName string, input json.RawMessage) (*WorkflowExecution, error) {
+	body := map[string]any{}
+	if len(input) > 0 {
+		body["input"] = input
+	}
+	var exec WorkflowExecution
+	if err := c.do(ctx, http.MethodPost, "/api/v1/workflows/"+url.PathEscape(idOrName)+"/executions", nil, body, &exec); err != nil {
+		return nil, err
+	}
+	return &exec, nil
+}
+
+// GetWorkflowExecution 根据执行 ID 获取执行详情（状态、当前状态、输出或错误）。
+func (c *Client) GetWorkflowExecution(ctx context.Context, executionID string) (*WorkflowExecution, error) {
+	var exec WorkflowExecution
+	if err := c.do(ctx, http.MethodGet, "/api/v1/executions/"+url.PathEscape(executionID), nil, nil, &exec); err != nil {
+		return nil, err
+	}
+	return &exec, nil
+}
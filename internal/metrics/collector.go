@@ -3,6 +3,8 @@
 package metrics
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -31,6 +33,14 @@ type Metrics struct {
 	// 标签: function_id, function_name, error_type
 	InvocationErrors *prometheus.CounterVec
 
+	// StaleInvocations 对账任务发现并标记为失败的停滞调用数
+	// 标签: status (pending/running)
+	StaleInvocations *prometheus.CounterVec
+
+	// ThrottledInvocations 被调用限流拒绝（返回 429）的请求数
+	// 标签: function_id
+	ThrottledInvocations *prometheus.CounterVec
+
 	// ========== 虚拟机池相关指标 ==========
 
 	// VMPoolSize 虚拟机池总容量
@@ -116,13 +126,29 @@ type Metrics struct {
 	// SnapshotSizeBytes 快照文件大小
 	// 标签: function_id
 	SnapshotSizeBytes *prometheus.GaugeVec
+
+	// warmVMs 预热虚拟机数量，采集时从虚拟机池实时读取
+	// 标签: runtime
+	warmVMs *warmVMsCollector
 }
 
 // NewMetrics 创建并注册一组 Prometheus 指标。
 // namespace 用于作为所有指标名前缀，便于在同一 Prometheus 中区分不同应用。
+// 指标注册到默认注册表，由 promhttp.Handler 暴露在 /metrics。
 func NewMetrics(namespace string) *Metrics {
+	return NewMetricsWithRegistry(namespace, prometheus.DefaultRegisterer)
+}
+
+// NewMetricsWithRegistry 创建一组 Prometheus 指标并注册到指定注册表。
+// 测试中使用独立的注册表，避免重复注册默认注册表导致 panic。
+func NewMetricsWithRegistry(namespace string, reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
+	warmVMs := newWarmVMsCollector(namespace)
+	reg.MustRegister(warmVMs)
+
 	return &Metrics{
-		InvocationsTotal: promauto.NewCounterVec(
+		warmVMs: warmVMs,
+		InvocationsTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "invocations_total",
@@ -130,7 +156,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"function_id", "function_name", "runtime", "status"},
 		),
-		InvocationDuration: promauto.NewHistogramVec(
+		InvocationDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Name:      "invocation_duration_ms",
@@ -139,7 +165,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"function_id", "function_name", "runtime", "cold_start"},
 		),
-		InvocationErrors: promauto.NewCounterVec(
+		InvocationErrors: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "invocation_errors_total",
@@ -147,7 +173,23 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"function_id", "function_name", "error_type"},
 		),
-		VMPoolSize: promauto.NewGaugeVec(
+		StaleInvocations: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "stale_invocations_total",
+				Help:      "Total number of stale invocations found by reconciliation and marked failed",
+			},
+			[]string{"status"},
+		),
+		ThrottledInvocations: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "throttled_invocations_total",
+				Help:      "Total number of invocation requests rejected by rate limiting",
+			},
+			[]string{"function_id"},
+		),
+		VMPoolSize: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "vm_pool_size",
@@ -155,7 +197,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"runtime"},
 		),
-		VMPoolWarm: promauto.NewGaugeVec(
+		VMPoolWarm: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "vm_pool_warm",
@@ -163,7 +205,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"runtime"},
 		),
-		VMPoolBusy: promauto.NewGaugeVec(
+		VMPoolBusy: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "vm_pool_busy",
@@ -171,7 +213,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"runtime"},
 		),
-		ColdStarts: promauto.NewCounterVec(
+		ColdStarts: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "cold_starts_total",
@@ -179,7 +221,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"function_id", "function_name", "runtime"},
 		),
-		WarmStarts: promauto.NewCounterVec(
+		WarmStarts: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "warm_starts_total",
@@ -187,7 +229,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"function_id", "function_name", "runtime"},
 		),
-		VMBootDuration: promauto.NewHistogramVec(
+		VMBootDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Name:      "vm_boot_duration_ms",
@@ -196,7 +238,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"runtime", "from_snapshot"},
 		),
-		VMRestoreDuration: promauto.NewHistogramVec(
+		VMRestoreDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Name:      "vm_restore_duration_ms",
@@ -205,28 +247,28 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"runtime"},
 		),
-		FunctionsTotal: promauto.NewGauge(
+		FunctionsTotal: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "functions_total",
 				Help:      "Total number of registered functions",
 			},
 		),
-		ActiveFunctions: promauto.NewGauge(
+		ActiveFunctions: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "active_functions",
 				Help:      "Number of active functions",
 			},
 		),
-		SchedulerQueueSize: promauto.NewGauge(
+		SchedulerQueueSize: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "scheduler_queue_size",
 				Help:      "Current scheduler queue size",
 			},
 		),
-		SchedulerWorkers: promauto.NewGauge(
+		SchedulerWorkers: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "scheduler_workers",
@@ -234,7 +276,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 		),
 		// 状态操作指标
-		StateOperationsTotal: promauto.NewCounterVec(
+		StateOperationsTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "state_operations_total",
@@ -242,7 +284,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"function_id", "operation", "scope", "success"},
 		),
-		StateOperationDuration: promauto.NewHistogramVec(
+		StateOperationDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Name:      "state_operation_duration_ms",
@@ -251,7 +293,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"function_id", "operation"},
 		),
-		SessionRouteTotal: promauto.NewCounterVec(
+		SessionRouteTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "session_route_total",
@@ -259,7 +301,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"function_id", "result"},
 		),
-		ActiveSessionsGauge: promauto.NewGaugeVec(
+		ActiveSessionsGauge: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "active_sessions",
@@ -267,7 +309,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"function_id"},
 		),
-		StateSizeBytes: promauto.NewGaugeVec(
+		StateSizeBytes: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "state_size_bytes",
@@ -276,7 +318,7 @@ func NewMetrics(namespace string) *Metrics {
 			[]string{"function_id", "scope"},
 		),
 		// 快照指标
-		SnapshotsTotal: promauto.NewGaugeVec(
+		SnapshotsTotal: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "snapshots_total",
@@ -284,7 +326,7 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"status"},
 		),
-		SnapshotBuildDuration: promauto.NewHistogramVec(
+		SnapshotBuildDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Name:      "snapshot_build_duration_ms",
@@ -293,16 +335,16 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"runtime", "success"},
 		),
-		SnapshotRestoreDuration: promauto.NewHistogramVec(
+		SnapshotRestoreDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
-				Name:      "snapshot_restore_duration_ms",
+				Name:      "snapshot_restore_ms",
 				Help:      "Snapshot restore duration in milliseconds",
 				Buckets:   []float64{5, 10, 25, 50, 100, 250, 500},
 			},
 			[]string{"runtime"},
 		),
-		SnapshotSizeBytes: promauto.NewGaugeVec(
+		SnapshotSizeBytes: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "snapshot_size_bytes",
@@ -334,6 +376,16 @@ func (m *Metrics) RecordError(functionID, functionName, errorType string) {
 	m.InvocationErrors.WithLabelValues(functionID, functionName, errorType).Inc()
 }
 
+// RecordStaleInvocations 记录对账任务标记为失败的停滞调用数。
+func (m *Metrics) RecordStaleInvocations(status string, count int) {
+	m.StaleInvocations.WithLabelValues(status).Add(float64(count))
+}
+
+// RecordThrottled 记录一次被调用限流拒绝的请求。
+func (m *Metrics) RecordThrottled(functionID string) {
+	m.ThrottledInvocations.WithLabelValues(functionID).Inc()
+}
+
 // UpdatePoolStats 更新虚拟机池统计指标。
 func (m *Metrics) UpdatePoolStats(runtime string, warm, busy, total int) {
 	m.VMPoolWarm.WithLabelValues(runtime).Set(float64(warm))
@@ -402,3 +454,54 @@ func (m *Metrics) RecordSnapshotRestore(runtime string, durationMs float64) {
 func (m *Metrics) UpdateSnapshotSize(functionID string, sizeBytes int64) {
 	m.SnapshotSizeBytes.WithLabelValues(functionID).Set(float64(sizeBytes))
 }
+
+// SetWarmVMsSource 设置预热虚拟机数量的来源，采集指标时调用。
+// source 返回运行时 -> 预热虚拟机（或容器）数量，未设置时不输出 warm_vms 指标。
+func (m *Metrics) SetWarmVMsSource(source func() map[string]int) {
+	m.warmVMs.setSource(source)
+}
+
+// warmVMsCollector 在每次采集时读取各运行时的预热虚拟机数量。
+// 与定期推送的 vm_pool_warm 不同，该指标始终反映采集时刻的池状态。
+type warmVMsCollector struct {
+	desc *prometheus.Desc
+
+	mu     sync.RWMutex
+	source func() map[string]int
+}
+
+// newWarmVMsCollector 创建预热虚拟机数量采集器
+func newWarmVMsCollector(namespace string) *warmVMsCollector {
+	return &warmVMsCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "warm_vms"),
+			"Warm VMs ready to serve invocations, read from the pool at scrape time",
+			[]string{"runtime"}, nil,
+		),
+	}
+}
+
+// setSource 设置数量来源
+func (c *warmVMsCollector) setSource(source func() map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.source = source
+}
+
+// Describe 实现 prometheus.Collector
+func (c *warmVMsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect 实现 prometheus.Collector
+func (c *warmVMsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	source := c.source
+	c.mu.RUnlock()
+	if source == nil {
+		return
+	}
+	for runtime, count := range source() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), runtime)
+	}
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// scrape 通过 HTTP 采集注册表中的指标并按文本格式解析
+func scrape(t *testing.T, reg *prometheus.Registry) map[string]*dto.MetricFamily {
+	t.Helper()
+	srv := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept", "text/plain")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("exposition does not parse: %v\n%s", err, body)
+	}
+	return families
+}
+
+func TestMetricsExpositionParses(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetricsWithRegistry("nimbus", reg)
+
+	m.RecordInvocation("fn-1", "hello", "python3.11", "success", 42, true)
+	m.RecordInvocation("fn-1", "hello", "python3.11", "failed", 7, false)
+	m.RecordSnapshotRestore("python3.11", 12.5)
+	m.SetWarmVMsSource(func() map[string]int {
+		return map[string]int{"python3.11": 3, "nodejs20": 1}
+	})
+
+	families := scrape(t, reg)
+
+	invocations := families["nimbus_invocations_total"]
+	if invocations == nil || invocations.GetType() != dto.MetricType_COUNTER || len(invocations.Metric) != 2 {
+		t.Fatalf("nimbus_invocations_total = %v, want 2 counter series", invocations)
+	}
+	if cold := families["nimbus_cold_starts_total"]; cold == nil || cold.Metric[0].GetCounter().GetValue() != 1 {
+		t.Errorf("nimbus_cold_starts_total = %v, want 1", cold)
+	}
+
+	duration := families["nimbus_invocation_duration_ms"]
+	if duration == nil || duration.GetType() != dto.MetricType_HISTOGRAM {
+		t.Fatalf("nimbus_invocation_duration_ms = %v, want histogram", duration)
+	}
+	var count uint64
+	for _, metric := range duration.Metric {
+		count += metric.GetHistogram().GetSampleCount()
+	}
+	if count != 2 {
+		t.Errorf("duration sample count = %d, want 2", count)
+	}
+
+	restore := families["nimbus_snapshot_restore_ms"]
+	if restore == nil || restore.Metric[0].GetHistogram().GetSampleSum() != 12.5 {
+		t.Errorf("nimbus_snapshot_restore_ms = %v, want one 12.5ms sample", restore)
+	}
+
+	warm := families["nimbus_warm_vms"]
+	if warm == nil || warm.GetType() != dto.MetricType_GAUGE {
+		t.Fatalf("nimbus_warm_vms = %v, want gauge", warm)
+	}
+	got := map[string]float64{}
+	for _, metric := range warm.Metric {
+		got[metric.Label[0].GetValue()] = metric.GetGauge().GetValue()
+	}
+	if got["python3.11"] != 3 || got["nodejs20"] != 1 {
+		t.Errorf("warm_vms = %v", got)
+	}
+}
+
+func TestWarmVMsWithoutSource(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	NewMetricsWithRegistry("nimbus", reg)
+
+	if _, ok := scrape(t, reg)["nimbus_warm_vms"]; ok {
+		t.Error("nimbus_warm_vms should be absent until a source is set")
+	}
+}
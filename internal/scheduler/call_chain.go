@@ -0,0 +1,41 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件负责把调用链传给函数，使函数调用其他函数时能够继续传递，见 domain.HeaderCallChain，
+// 并把函数间调用记录为函数依赖。
+package scheduler
+
+import (
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// dependencyRecorder 记录函数间的调用关系，由 *storage.PostgresStore 实现
+type dependencyRecorder interface {
+	RecordDependency(sourceID, targetID string, depType domain.DependencyType) error
+}
+
+// recordCallDependency 把函数间调用记录为直接调用方到被调用函数的依赖，不是函数间调用时不做任何事。
+// 记录失败只输出警告，不影响调用本身。
+func recordCallDependency(store dependencyRecorder, logger *logrus.Logger, fn *domain.Function, chain []string) {
+	if len(chain) == 0 {
+		return
+	}
+	caller := chain[len(chain)-1]
+	if err := store.RecordDependency(caller, fn.ID, domain.DependencyTypeDirectCall); err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"source_id": caller,
+			"target_id": fn.ID,
+		}).Warn("Failed to record function dependency")
+	}
+}
+
+// callChainEnvVars 添加调用链环境变量，值为本次调用的调用链加上函数自身。
+// 返回新的映射，不修改传入的 envVars（它可能是函数定义中的原始映射）。
+func callChainEnvVars(fn *domain.Function, inv *domain.Invocation, envVars map[string]string) map[string]string {
+	merged := make(map[string]string, len(envVars)+1)
+	for k, v := range envVars {
+		merged[k] = v
+	}
+	chain := append(append([]string{}, inv.CallChain...), fn.ID)
+	merged[domain.EnvCallChain] = domain.FormatCallChain(chain)
+	return merged
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeDependencyRecorder 记录写入的依赖，err 不为空时返回该错误
+type fakeDependencyRecorder struct {
+	recorded []string
+	err      error
+}
+
+func (r *fakeDependencyRecorder) RecordDependency(sourceID, targetID string, depType domain.DependencyType) error {
+	r.recorded = append(r.recorded, sourceID+"->"+targetID+":"+string(depType))
+	return r.err
+}
+
+func TestCallChainEnvVars(t *testing.T) {
+	fn := &domain.Function{ID: "fn-c"}
+	base := map[string]string{"LOG_LEVEL": "debug"}
+
+	// 直接调用时调用链只有函数自身
+	got := callChainEnvVars(fn, &domain.Invocation{}, base)
+	if got[domain.EnvCallChain] != "fn-c" || got["LOG_LEVEL"] != "debug" {
+		t.Errorf("env vars for top-level call = %v, want chain fn-c and the original variables", got)
+	}
+	if _, ok := base[domain.EnvCallChain]; ok {
+		t.Error("callChainEnvVars modified the function's env vars")
+	}
+
+	// 函数间调用时在上游调用链后追加函数自身
+	inv := &domain.Invocation{CallChain: []string{"fn-a", "fn-b"}}
+	got = callChainEnvVars(fn, inv, nil)
+	if got[domain.EnvCallChain] != "fn-a,fn-b,fn-c" {
+		t.Errorf("%s = %q, want fn-a,fn-b,fn-c", domain.EnvCallChain, got[domain.EnvCallChain])
+	}
+	if len(inv.CallChain) != 2 {
+		t.Errorf("invocation call chain = %v, want it unchanged", inv.CallChain)
+	}
+}
+
+func TestRecordCallDependency(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	fn := &domain.Function{ID: "fn-c"}
+
+	// 不是函数间调用时不记录
+	rec := &fakeDependencyRecorder{}
+	recordCallDependency(rec, logger, fn, nil)
+	if len(rec.recorded) != 0 {
+		t.Errorf("recorded = %v, want none for a top-level call", rec.recorded)
+	}
+
+	// 只记录直接调用方到被调用函数的依赖
+	recordCallDependency(rec, logger, fn, []string{"fn-a", "fn-b"})
+	want := "fn-b->fn-c:" + string(domain.DependencyTypeDirectCall)
+	if len(rec.recorded) != 1 || rec.recorded[0] != want {
+		t.Errorf("recorded = %v, want [%s]", rec.recorded, want)
+	}
+
+	// 记录失败不会中断调用
+	recordCallDependency(&fakeDependencyRecorder{err: errors.New("db down")}, logger, fn, []string{"fn-a"})
+}
@@ -0,0 +1,149 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现金丝雀发布控制器：周期性对比别名上金丝雀版本与稳定版本的指标，
+// 健康时逐步提升金丝雀权重，劣化时将权重置为 0 并告警。
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// CanaryStore 是金丝雀发布控制器所需的存储方法
+type CanaryStore interface {
+	ListCanaryDeployments(functionID string, status domain.CanaryStatus) ([]*domain.CanaryDeployment, error)
+	CompareVersionStats(functionID string, baselineVersion, candidateVersion int, since time.Time) (*domain.VersionComparison, error)
+	// UpdateCanaryDeployment 保存仍在进行中的发布，已结束时返回 domain.ErrCanaryNotRunning
+	UpdateCanaryDeployment(c *domain.CanaryDeployment) error
+	// ApplyCanaryDeployment 与 UpdateCanaryDeployment 相同，并在同一事务中按权重更新别名的路由配置
+	ApplyCanaryDeployment(c *domain.CanaryDeployment) error
+	GetFunctionByID(id string) (*domain.Function, error)
+	CreateAlert(alert *domain.Alert) error
+}
+
+// CanaryController 周期性评估所有进行中的金丝雀发布。
+// 权重变化通过更新别名的路由配置生效，流量路由器在缓存过期后读取新权重。
+type CanaryController struct {
+	store    CanaryStore
+	interval time.Duration
+	logger   *logrus.Logger
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewCanaryController 创建金丝雀发布控制器
+func NewCanaryController(store CanaryStore, interval time.Duration, logger *logrus.Logger) *CanaryController {
+	return &CanaryController{
+		store:    store,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start 启动评估循环，Stop 之后可再次启动（如重新成为主节点时）
+func (c *CanaryController) Start() {
+	c.stopCh = make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stopCh:
+				return
+			case <-ticker.C:
+				c.evaluateAll()
+			}
+		}
+	}()
+	c.logger.WithField("interval", c.interval).Info("Canary controller started")
+}
+
+// Stop 停止评估循环并等待进行中的评估完成
+func (c *CanaryController) Stop() {
+	close(c.stopCh)
+	c.wg.Wait()
+}
+
+// evaluateAll 评估所有进行中的金丝雀发布，单个发布失败不影响其他发布
+func (c *CanaryController) evaluateAll() {
+	deployments, err := c.store.ListCanaryDeployments("", domain.CanaryStatusRunning)
+	if err != nil {
+		c.logger.WithError(err).Warn("Failed to list running canary deployments")
+		return
+	}
+	for _, d := range deployments {
+		if err := c.evaluate(d); err != nil {
+			c.logger.WithError(err).WithField("canary_id", d.ID).Warn("Failed to evaluate canary deployment")
+		}
+	}
+}
+
+// evaluate 对比当前权重生效以来的版本指标并执行决策。
+// 权重变化与发布记录在同一事务中保存，评估期间发布被手动终止时放弃本次决策，不改动别名。
+func (c *CanaryController) evaluate(d *domain.CanaryDeployment) error {
+	cmp, err := c.store.CompareVersionStats(d.FunctionID, d.StableVersion, d.CanaryVersion, d.StepStartedAt)
+	if err != nil {
+		return err
+	}
+
+	decision := d.Evaluate(cmp, time.Now())
+	if decision.Action != domain.CanaryActionHold {
+		err = c.store.ApplyCanaryDeployment(d)
+	} else {
+		err = c.store.UpdateCanaryDeployment(d)
+	}
+	if errors.Is(err, domain.ErrCanaryNotRunning) {
+		c.logger.WithField("canary_id", d.ID).Debug("Canary deployment ended during evaluation, decision discarded")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logger := c.logger.WithFields(logrus.Fields{
+		"canary_id":      d.ID,
+		"function_id":    d.FunctionID,
+		"alias":          d.AliasName,
+		"canary_version": d.CanaryVersion,
+		"weight":         d.Weight,
+		"action":         decision.Action,
+	})
+	switch decision.Action {
+	case domain.CanaryActionPromote:
+		logger.Info("Canary promoted: " + decision.Reason)
+	case domain.CanaryActionRollback:
+		logger.Warn("Canary rolled back: " + decision.Reason)
+		c.alert(d, decision)
+	default:
+		logger.Debug("Canary held: " + decision.Reason)
+	}
+	return nil
+}
+
+// alert 为自动回滚记录一条严重告警
+func (c *CanaryController) alert(d *domain.CanaryDeployment, decision domain.CanaryDecision) {
+	functionName := d.FunctionID
+	if fn, err := c.store.GetFunctionByID(d.FunctionID); err == nil {
+		functionName = fn.Name
+	}
+	alert := &domain.Alert{
+		RuleName:     "canary_rollback",
+		FunctionID:   d.FunctionID,
+		FunctionName: functionName,
+		Severity:     domain.AlertSeverityCritical,
+		Status:       domain.AlertStatusActive,
+		Message: fmt.Sprintf("canary version %d of alias %s rolled back to version %d: %s",
+			d.CanaryVersion, d.AliasName, d.StableVersion, decision.Reason),
+		Value:     decision.Comparison.ErrorRateDelta,
+		Threshold: d.MaxErrorRateDelta,
+	}
+	if err := c.store.CreateAlert(alert); err != nil {
+		c.logger.WithError(err).WithField("canary_id", d.ID).Warn("Failed to record canary rollback alert")
+	}
+}
@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeCanaryStore 返回固定的版本指标，并记录保存的发布、应用的路由配置和告警
+type fakeCanaryStore struct {
+	cmp       *domain.VersionComparison
+	updateErr error // 模拟发布在评估期间被终止
+	updated   int
+	applied   []domain.RoutingConfig
+	alerts    []*domain.Alert
+}
+
+func (s *fakeCanaryStore) ListCanaryDeployments(functionID string, status domain.CanaryStatus) ([]*domain.CanaryDeployment, error) {
+	return nil, nil
+}
+
+func (s *fakeCanaryStore) CompareVersionStats(functionID string, baselineVersion, candidateVersion int, since time.Time) (*domain.VersionComparison, error) {
+	return s.cmp, nil
+}
+
+func (s *fakeCanaryStore) UpdateCanaryDeployment(c *domain.CanaryDeployment) error {
+	if s.updateErr != nil {
+		return s.updateErr
+	}
+	s.updated++
+	return nil
+}
+
+func (s *fakeCanaryStore) ApplyCanaryDeployment(c *domain.CanaryDeployment) error {
+	if s.updateErr != nil {
+		return s.updateErr
+	}
+	s.applied = append(s.applied, c.RoutingConfig())
+	return nil
+}
+
+func (s *fakeCanaryStore) GetFunctionByID(id string) (*domain.Function, error) {
+	return &domain.Function{ID: id, Name: "checkout"}, nil
+}
+
+func (s *fakeCanaryStore) CreateAlert(alert *domain.Alert) error {
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func newTestCanary() *domain.CanaryDeployment {
+	return &domain.CanaryDeployment{
+		ID:                 "canary-1",
+		FunctionID:         "fn-1",
+		AliasName:          "live",
+		StableVersion:      1,
+		CanaryVersion:      2,
+		Weight:             10,
+		StepWeight:         40,
+		HealthyDurationSec: 60,
+		MinInvocations:     10,
+		MaxErrorRateDelta:  0.05,
+		MaxLatencyRatio:    1.5,
+		Status:             domain.CanaryStatusRunning,
+	}
+}
+
+func newTestCanaryController(store *fakeCanaryStore) *CanaryController {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewCanaryController(store, time.Minute, logger)
+}
+
+func healthyComparison() *domain.VersionComparison {
+	return domain.NewVersionComparison(
+		domain.VersionStats{Version: 1, Invocations: 100, P95DurationMs: 100},
+		domain.VersionStats{Version: 2, Invocations: 20, P95DurationMs: 110},
+		time.Now(),
+	)
+}
+
+func TestCanaryControllerPromotesAfterHealthyDuration(t *testing.T) {
+	store := &fakeCanaryStore{cmp: healthyComparison()}
+	c := newTestCanaryController(store)
+	d := newTestCanary()
+
+	// 首次健康只开始计时，权重不变，只保存发布记录
+	if err := c.evaluate(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Weight != 10 || store.updated != 1 || len(store.applied) != 0 {
+		t.Fatalf("first healthy evaluation: weight=%d updated=%d applied=%d, want hold", d.Weight, store.updated, len(store.applied))
+	}
+
+	// 持续健康达到时长后提升权重，并同时更新别名路由
+	past := time.Now().Add(-2 * time.Minute)
+	d.HealthySince = &past
+	if err := c.evaluate(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Weight != 50 || len(store.applied) != 1 {
+		t.Fatalf("after healthy duration: weight=%d applied=%d, want 50 and one routing update", d.Weight, len(store.applied))
+	}
+	want := domain.RoutingConfig{Weights: []domain.VersionWeight{{Version: 1, Weight: 50}, {Version: 2, Weight: 50}}}
+	if got := store.applied[0]; len(got.Weights) != 2 || got.Weights[0] != want.Weights[0] || got.Weights[1] != want.Weights[1] {
+		t.Errorf("applied routing = %+v, want %+v", got, want)
+	}
+
+	// 权重达到 100 时发布完成，全部流量切到金丝雀版本
+	past = time.Now().Add(-2 * time.Minute)
+	d.HealthySince = &past
+	d.Weight = 80
+	if err := c.evaluate(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Status != domain.CanaryStatusPromoted {
+		t.Errorf("status = %s, want promoted", d.Status)
+	}
+	if got := store.applied[len(store.applied)-1]; len(got.Weights) != 1 || got.Weights[0].Version != 2 {
+		t.Errorf("final routing = %+v, want canary version only", got)
+	}
+	if len(store.alerts) != 0 {
+		t.Errorf("alerts = %d, want none for a promotion", len(store.alerts))
+	}
+}
+
+func TestCanaryControllerRollsBackAndAlerts(t *testing.T) {
+	cmp := domain.NewVersionComparison(
+		domain.VersionStats{Version: 1, Invocations: 100, ErrorRate: 0.01, P95DurationMs: 100},
+		domain.VersionStats{Version: 2, Invocations: 20, ErrorRate: 0.2, P95DurationMs: 100},
+		time.Now(),
+	)
+	store := &fakeCanaryStore{cmp: cmp}
+	c := newTestCanaryController(store)
+	d := newTestCanary()
+
+	if err := c.evaluate(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Weight != 0 || d.Status != domain.CanaryStatusRolledBack {
+		t.Fatalf("weight=%d status=%s, want 0 and rolled_back", d.Weight, d.Status)
+	}
+	if len(store.applied) != 1 || len(store.applied[0].Weights) != 1 || store.applied[0].Weights[0].Version != 1 {
+		t.Errorf("applied routing = %+v, want stable version only", store.applied)
+	}
+	if len(store.alerts) != 1 {
+		t.Fatalf("alerts = %d, want 1", len(store.alerts))
+	}
+	if a := store.alerts[0]; a.Severity != domain.AlertSeverityCritical || a.FunctionName != "checkout" {
+		t.Errorf("alert = %+v, want critical alert for checkout", a)
+	}
+}
+
+func TestCanaryControllerDiscardsDecisionWhenAborted(t *testing.T) {
+	cmp := domain.NewVersionComparison(
+		domain.VersionStats{Version: 1, Invocations: 100, ErrorRate: 0.01},
+		domain.VersionStats{Version: 2, Invocations: 20, ErrorRate: 0.5},
+		time.Now(),
+	)
+	// 评估期间发布被手动终止：保存时存储返回 ErrCanaryNotRunning
+	store := &fakeCanaryStore{cmp: cmp, updateErr: domain.ErrCanaryNotRunning}
+	c := newTestCanaryController(store)
+
+	if err := c.evaluate(newTestCanary()); err != nil {
+		t.Fatalf("evaluate aborted canary: %v, want nil", err)
+	}
+	if len(store.applied) != 0 || len(store.alerts) != 0 {
+		t.Errorf("applied=%d alerts=%d, want the rollback discarded", len(store.applied), len(store.alerts))
+	}
+}
@@ -0,0 +1,134 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现了按函数限制并发执行数的并发限制器，限制值来自函数的 max_concurrency。
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// 并发达到上限时的处理方式
+const (
+	// ConcurrencyModeReject 立即拒绝超出上限的调用（默认）
+	ConcurrencyModeReject = "reject"
+	// ConcurrencyModeWait 等待其他调用结束释放名额，超过等待时间后拒绝
+	ConcurrencyModeWait = "wait"
+)
+
+// concurrencySlots 记录一个函数正在执行的调用数。
+type concurrencySlots struct {
+	active  int
+	changed chan struct{} // 有名额释放时关闭并替换，用于唤醒等待者
+}
+
+// ConcurrencyLimiter 按函数 ID 限制同时执行的调用数量。
+// 限制值在获取名额时传入，因此函数的 max_concurrency 更新后立即对新调用生效，
+// 已在执行的调用不受影响。所有方法都是并发安全的。
+type ConcurrencyLimiter struct {
+	mode        string        // 达到上限时的处理方式：reject 或 wait
+	waitTimeout time.Duration // wait 模式下的最长等待时间
+	mu          sync.Mutex
+	functions   map[string]*concurrencySlots
+}
+
+// NewConcurrencyLimiter 创建并发限制器。
+//
+// 参数:
+//   - mode: 达到上限时的处理方式，ConcurrencyModeWait 表示等待，其他值均为立即拒绝
+//   - waitTimeout: wait 模式下的最长等待时间，不大于 0 时只受调用方上下文限制
+func NewConcurrencyLimiter(mode string, waitTimeout time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		mode:        mode,
+		waitTimeout: waitTimeout,
+		functions:   make(map[string]*concurrencySlots),
+	}
+}
+
+// Acquire 为函数获取一个执行名额。
+// 成功时返回释放函数，调用结束（包括失败、超时、panic）时必须调用且只生效一次；
+// limit 不大于 0 表示不限制，返回的释放函数为空操作。
+//
+// 参数:
+//   - ctx: 调用方上下文，wait 模式下取消时停止等待
+//   - functionID: 函数 ID
+//   - limit: 函数的最大并发数
+//
+// 返回值:
+//   - func(): 释放名额的函数
+//   - error: 达到上限时返回包装了 domain.ErrConcurrencyLimitExceeded 的错误
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, functionID string, limit int) (func(), error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	if l.mode == ConcurrencyModeWait && l.waitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.waitTimeout)
+		defer cancel()
+	}
+
+	for {
+		l.mu.Lock()
+		slots := l.functions[functionID]
+		if slots == nil {
+			slots = &concurrencySlots{changed: make(chan struct{})}
+			l.functions[functionID] = slots
+		}
+		if slots.active < limit {
+			slots.active++
+			l.mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(func() { l.release(functionID) }) }, nil
+		}
+		changed := slots.changed
+		l.mu.Unlock()
+
+		if l.mode != ConcurrencyModeWait {
+			return nil, fmt.Errorf("%w: function %s is at max_concurrency %d", domain.ErrConcurrencyLimitExceeded, functionID, limit)
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: function %s is at max_concurrency %d: %v", domain.ErrConcurrencyLimitExceeded, functionID, limit, ctx.Err())
+		}
+	}
+}
+
+// release 归还一个名额并唤醒等待者，函数没有执行中的调用时删除其记录。
+func (l *ConcurrencyLimiter) release(functionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots := l.functions[functionID]
+	if slots == nil {
+		return
+	}
+	slots.active--
+	close(slots.changed)
+	slots.changed = make(chan struct{})
+	if slots.active <= 0 {
+		delete(l.functions, functionID)
+	}
+}
+
+// Active 返回函数当前正在执行的调用数。
+func (l *ConcurrencyLimiter) Active(functionID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if slots := l.functions[functionID]; slots != nil {
+		return slots.active
+	}
+	return 0
+}
+
+// acquireInvocationSlot 在提交调用前获取函数的执行名额。
+// wait 模式下优先使用调用方请求的上下文，请求取消时停止等待；请求未携带上下文时使用调度器上下文。
+func acquireInvocationSlot(base context.Context, limiter *ConcurrencyLimiter, fn *domain.Function, req *domain.InvokeRequest) (func(), error) {
+	ctx := base
+	if req.Context != nil {
+		ctx = req.Context
+	}
+	return limiter.Acquire(ctx, fn.ID, fn.MaxConcurrency)
+}
@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+func TestConcurrencyLimiterWaitModeCapsActiveInvocations(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyModeWait, 5*time.Second)
+
+	const limit, invocations = 3, 20
+	var running, peak, completed int32
+	var wg sync.WaitGroup
+	for i := 0; i < invocations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := limiter.Acquire(context.Background(), "fn-1", limit)
+			if err != nil {
+				t.Errorf("Acquire() error = %v", err)
+				return
+			}
+			defer release()
+
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&completed, 1)
+		}()
+	}
+	wg.Wait()
+
+	if peak > limit {
+		t.Errorf("peak concurrency = %d, want <= %d", peak, limit)
+	}
+	if completed != invocations {
+		t.Errorf("completed = %d, want %d", completed, invocations)
+	}
+	if n := limiter.Active("fn-1"); n != 0 {
+		t.Errorf("Active() = %d after all invocations finished, want 0", n)
+	}
+}
+
+func TestConcurrencyLimiterRejectMode(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyModeReject, 0)
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := limiter.Acquire(context.Background(), "fn-1", 2)
+		if err != nil {
+			t.Fatalf("Acquire() #%d error = %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+	if _, err := limiter.Acquire(context.Background(), "fn-1", 2); !errors.Is(err, domain.ErrConcurrencyLimitExceeded) {
+		t.Fatalf("Acquire() over limit error = %v, want ErrConcurrencyLimitExceeded", err)
+	}
+	// 其他函数不受影响
+	if _, err := limiter.Acquire(context.Background(), "fn-2", 2); err != nil {
+		t.Errorf("Acquire() for another function error = %v", err)
+	}
+
+	// 重复释放只生效一次
+	releases[0]()
+	releases[0]()
+	if n := limiter.Active("fn-1"); n != 1 {
+		t.Errorf("Active() = %d after one release, want 1", n)
+	}
+	if _, err := limiter.Acquire(context.Background(), "fn-1", 2); err != nil {
+		t.Errorf("Acquire() after release error = %v", err)
+	}
+}
+
+func TestConcurrencyLimiterWaitTimeout(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyModeWait, 20*time.Millisecond)
+	release, err := limiter.Acquire(context.Background(), "fn-1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	start := time.Now()
+	if _, err := limiter.Acquire(context.Background(), "fn-1", 1); !errors.Is(err, domain.ErrConcurrencyLimitExceeded) {
+		t.Fatalf("Acquire() error = %v, want ErrConcurrencyLimitExceeded after waiting", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Acquire() returned after %v, want to wait for the timeout", elapsed)
+	}
+
+	// 调用方取消时停止等待
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.Acquire(ctx, "fn-1", 1); !errors.Is(err, domain.ErrConcurrencyLimitExceeded) {
+		t.Errorf("Acquire() with cancelled context error = %v", err)
+	}
+}
+
+func TestConcurrencyLimiterReleasesOnPanic(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyModeReject, 0)
+	func() {
+		defer func() { recover() }()
+		release, err := limiter.Acquire(context.Background(), "fn-1", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+		panic("function handler crashed")
+	}()
+	if n := limiter.Active("fn-1"); n != 0 {
+		t.Errorf("Active() = %d after panic, want 0", n)
+	}
+}
+
+func TestConcurrencyLimiterUnlimited(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyModeReject, 0)
+	for i := 0; i < 100; i++ {
+		if _, err := limiter.Acquire(context.Background(), "fn-1", 0); err != nil {
+			t.Fatalf("Acquire() with limit 0 error = %v", err)
+		}
+	}
+	if n := limiter.Active("fn-1"); n != 0 {
+		t.Errorf("Active() = %d for unlimited function, want 0", n)
+	}
+}
@@ -1,34 +1,55 @@
 package scheduler
 
 import (
-	"context"
 	"encoding/json"
 	"sync"
+	"time"
 
 	"github.com/oriys/nimbus/internal/domain"
-	"github.com/oriys/nimbus/internal/storage"
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
 )
 
-// CronManager 管理定时任务触发器
+// cronFireWindow 是推算本次触发计划时间时向前回看的范围，任务启动延迟超过该范围时以当前秒作为计划时间
+const cronFireWindow = time.Minute
+
+// CronStore 是 CronManager 依赖的存储接口，*storage.PostgresStore 实现了该接口。
+type CronStore interface {
+	ListFunctions(offset, limit int) ([]*domain.Function, int, error)
+	// ClaimCronFire 认领函数在计划时间的一次触发，同一计划时间只有一次认领成功
+	ClaimCronFire(functionID string, scheduledAt time.Time) (bool, error)
+	GetInvocationByID(id string) (*domain.Invocation, error)
+}
+
+// CronManager 管理定时任务触发器，以及函数的 scheduled/on_deploy 预热（见 warmup.go）。
+// 定时任务按函数的 cron_timezone 计算触发时间；每次触发先在数据库中认领计划时间，
+// 多个网关实例或重启后不会重复触发；上一次定时触发的调用仍在执行时跳过本次触发。
 type CronManager struct {
-	cron     *cron.Cron
-	store    *storage.PostgresStore
-	invoker  func(*domain.InvokeRequest) (string, error)
-	logger   *logrus.Logger
-	mu       sync.Mutex
-	entries  map[string]cron.EntryID // functionID -> cronEntryID
+	cron          *cron.Cron
+	store         CronStore
+	invoker       func(*domain.InvokeRequest) (string, error)
+	prewarmer     Prewarmer
+	logger        *logrus.Logger
+	now           func() time.Time
+	isLeader      func() bool // 为 nil 时每次都触发，见 SetLeaderCheck
+	mu            sync.Mutex
+	entries       map[string]cron.EntryID // functionID -> cronEntryID
+	warmupEntries map[string]cron.EntryID // functionID -> 预热任务 entryID
+	lastRuns      map[string]string       // functionID -> 最近一次定时触发的调用 ID
 }
 
 // NewCronManager 创建一个新的 CronManager
-func NewCronManager(store *storage.PostgresStore, invoker func(*domain.InvokeRequest) (string, error), logger *logrus.Logger) *CronManager {
+func NewCronManager(store CronStore, invoker func(*domain.InvokeRequest) (string, error), logger *logrus.Logger) *CronManager {
 	return &CronManager{
 		cron:    cron.New(cron.WithSeconds()), // 支持秒级
 		store:   store,
 		invoker: invoker,
 		logger:  logger,
+		now:     time.Now,
 		entries: make(map[string]cron.EntryID),
+
+		warmupEntries: make(map[string]cron.EntryID),
+		lastRuns:      make(map[string]string),
 	}
 }
 
@@ -51,6 +72,10 @@ func (cm *CronManager) ReloadAll() error {
 		cm.cron.Remove(entryID)
 	}
 	cm.entries = make(map[string]cron.EntryID)
+	for _, entryID := range cm.warmupEntries {
+		cm.cron.Remove(entryID)
+	}
+	cm.warmupEntries = make(map[string]cron.EntryID)
 
 	// 分页加载所有函数并筛选
 	offset := 0
@@ -65,6 +90,7 @@ func (cm *CronManager) ReloadAll() error {
 			if fn.CronExpression != "" && fn.Status == domain.FunctionStatusActive {
 				cm.addFunction(fn)
 			}
+			cm.syncWarmup(fn)
 		}
 
 		offset += len(fns)
@@ -92,6 +118,7 @@ func (cm *CronManager) AddOrUpdateFunction(fn *domain.Function) {
 	if fn.CronExpression != "" && fn.Status == domain.FunctionStatusActive {
 		cm.addFunction(fn)
 	}
+	cm.syncWarmup(fn)
 }
 
 // RemoveFunction 移除函数的定时任务
@@ -103,46 +130,125 @@ func (cm *CronManager) RemoveFunction(functionID string) {
 		cm.cron.Remove(entryID)
 		delete(cm.entries, functionID)
 	}
+	delete(cm.lastRuns, functionID)
+	cm.removeWarmup(functionID)
 }
 
 // addFunction 内部方法，将函数添加到 cron 调度器
 // 调用此方法前必须持有 cm.mu 锁
 func (cm *CronManager) addFunction(fn *domain.Function) {
-	entryID, err := cm.cron.AddFunc(fn.CronExpression, func() {
-		cm.logger.WithFields(logrus.Fields{
-			"function_id":   fn.ID,
-			"function_name": fn.Name,
-			"cron":          fn.CronExpression,
-		}).Info("Triggering cron function")
-
-		// 构造一个定时任务触发的载荷
-		payload := map[string]interface{}{
-			"trigger": "cron",
-			"cron":    fn.CronExpression,
-			"time":    context.Background().Value("timestamp"), // 占位
-		}
-		payloadBytes, _ := json.Marshal(payload)
-
-		req := &domain.InvokeRequest{
-			FunctionID: fn.ID,
-			Payload:    payloadBytes,
-			Async:      true,
-		}
-
-		if _, err := cm.invoker(req); err != nil {
-			cm.logger.WithError(err).WithField("function_id", fn.ID).Error("Failed to invoke cron function")
-		}
-	})
-
+	schedule, err := parseCronSchedule(fn.CronExpression, fn.CronTimezone)
 	if err != nil {
 		cm.logger.WithError(err).WithFields(logrus.Fields{
 			"function_id": fn.ID,
 			"cron":        fn.CronExpression,
+			"timezone":    fn.CronTimezone,
 		}).Error("Failed to add cron function")
 		return
 	}
 
-	cm.entries[fn.ID] = entryID
+	target := *fn
+	cm.entries[fn.ID] = cm.cron.Schedule(schedule, cron.FuncJob(func() {
+		cm.fire(&target, schedule)
+	}))
+}
+
+// SetLeaderCheck 设置主节点判断，设置后只有主节点触发定时调用。
+// 定时预热针对本节点的虚拟机池，不受影响，在所有节点上执行。
+func (cm *CronManager) SetLeaderCheck(isLeader func() bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.isLeader = isLeader
+}
+
+// fire 执行一次定时触发：跳过与上一次定时调用重叠的触发，认领计划时间后异步调用函数
+func (cm *CronManager) fire(fn *domain.Function, schedule cron.Schedule) {
+	cm.mu.Lock()
+	isLeader := cm.isLeader
+	cm.mu.Unlock()
+	if isLeader != nil && !isLeader() {
+		return
+	}
+
+	scheduledAt := lastFireTime(schedule, cm.now())
+	log := cm.logger.WithFields(logrus.Fields{
+		"function_id":   fn.ID,
+		"function_name": fn.Name,
+		"cron":          fn.CronExpression,
+		"scheduled_at":  scheduledAt,
+	})
+
+	if invocationID, running := cm.previousRunActive(fn.ID); running {
+		log.WithField("invocation_id", invocationID).Warn("Skipping cron trigger, previous run still in progress")
+		return
+	}
+
+	claimed, err := cm.store.ClaimCronFire(fn.ID, scheduledAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to claim cron trigger")
+		return
+	}
+	if !claimed {
+		log.Debug("Cron trigger already fired")
+		return
+	}
+
+	log.Info("Triggering cron function")
+
+	// 构造一个定时任务触发的载荷
+	payload := map[string]interface{}{
+		"trigger":        "cron",
+		"cron":           fn.CronExpression,
+		"scheduled_time": scheduledAt.Format(time.RFC3339),
+	}
+	if fn.CronTimezone != "" {
+		payload["timezone"] = fn.CronTimezone
+	}
+	payloadBytes, _ := json.Marshal(payload)
+
+	req := &domain.InvokeRequest{
+		FunctionID: fn.ID,
+		Payload:    payloadBytes,
+		Async:      true,
+		Trigger:    domain.TriggerCron,
+	}
+
+	invocationID, err := cm.invoker(req)
+	if err != nil {
+		log.WithError(err).Error("Failed to invoke cron function")
+		return
+	}
+	cm.mu.Lock()
+	cm.lastRuns[fn.ID] = invocationID
+	cm.mu.Unlock()
+}
+
+// previousRunActive 判断函数上一次定时触发的调用是否仍在等待或执行
+func (cm *CronManager) previousRunActive(functionID string) (string, bool) {
+	cm.mu.Lock()
+	invocationID := cm.lastRuns[functionID]
+	cm.mu.Unlock()
+	if invocationID == "" {
+		return "", false
+	}
+	inv, err := cm.store.GetInvocationByID(invocationID)
+	if err != nil {
+		return invocationID, false
+	}
+	return invocationID, inv.Status == domain.InvocationStatusPending || inv.Status == domain.InvocationStatusRunning
+}
+
+// lastFireTime 返回 now 时刻（含）之前最近的一次计划触发时间，作为本次触发的计划时间。
+// 在 cronFireWindow 内找不到时（任务启动严重延迟）使用当前秒。
+func lastFireTime(schedule cron.Schedule, now time.Time) time.Time {
+	last := time.Time{}
+	for t := schedule.Next(now.Add(-cronFireWindow)); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		last = t
+	}
+	if last.IsZero() {
+		return now.Truncate(time.Second)
+	}
+	return last
 }
 
 // Stop 停止 Cron 调度器
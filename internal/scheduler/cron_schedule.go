@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser 与 cron.New(cron.WithSeconds()) 使用的解析器一致：6 字段（含秒），支持 @every 等描述符
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// allHours 是小时字段 0-23 全部置位时的位掩码
+const allHours = 1<<24 - 1
+
+// parseCronSchedule 解析函数的定时任务表达式，timezone 为 IANA 时区名，为空时使用网关所在时区。
+//
+// 固定小时的任务（小时字段不是 *）在夏令时切换时：
+//   - 拨快时计划时间落在被跳过的时段内，在切换时刻触发，而不是跳过当天
+//   - 拨慢时重复出现的时段内只在第一次出现时触发
+//
+// 每小时或更频繁的任务以及 @every 任务按实际经过的时间触发，不做调整。
+func parseCronSchedule(expr, timezone string) (cron.Schedule, error) {
+	if timezone != "" {
+		if err := domain.ValidateCronTimezone(expr, timezone); err != nil {
+			return nil, err
+		}
+		expr = "CRON_TZ=" + timezone + " " + expr
+	}
+	schedule, err := cronParser.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := schedule.(*cron.SpecSchedule)
+	if !ok || spec.Hour&allHours == allHours {
+		return schedule, nil
+	}
+	return &dstSchedule{spec: spec}, nil
+}
+
+// dstSchedule 按 parseCronSchedule 描述的规则处理夏令时切换的固定小时调度
+type dstSchedule struct {
+	spec *cron.SpecSchedule
+}
+
+// Next 实现 cron.Schedule 接口，返回 t 之后的下一次触发时间
+func (s *dstSchedule) Next(t time.Time) time.Time {
+	for {
+		next := s.spec.Next(t)
+		if next.IsZero() {
+			return next
+		}
+		if skipped, ok := s.skippedByGap(t, next); ok {
+			return skipped
+		}
+		if !repeatedWallClock(next.In(s.spec.Location)) {
+			return next
+		}
+		t = next
+	}
+}
+
+// skippedByGap 检查 t 与 next 之间是否有拨快的时区切换跳过了计划时间，有则返回切换时刻
+func (s *dstSchedule) skippedByGap(t, next time.Time) (time.Time, bool) {
+	_, transition := t.In(s.spec.Location).ZoneBounds()
+	for !transition.IsZero() && !transition.After(next) {
+		_, before := transition.Add(-time.Nanosecond).Zone()
+		_, after := transition.Zone()
+		if after > before && transition.After(t) {
+			// 被跳过的本地时间为 [切换前的本地时间, 切换前的本地时间 + 时差)
+			wall := transition.In(time.FixedZone("", before))
+			gapStart := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, time.UTC)
+			wallSpec := *s.spec
+			wallSpec.Location = time.UTC
+			if wallSpec.Next(gapStart.Add(-time.Second)).Before(gapStart.Add(time.Duration(after-before) * time.Second)) {
+				return transition, true
+			}
+		}
+		_, transition = transition.ZoneBounds()
+	}
+	return time.Time{}, false
+}
+
+// repeatedWallClock 判断 t 是否位于拨慢后重复出现的时段中（即该本地时间的第二次出现）
+func repeatedWallClock(t time.Time) bool {
+	start, _ := t.ZoneBounds()
+	if start.IsZero() {
+		return false
+	}
+	_, before := start.Add(-time.Nanosecond).Zone()
+	_, after := t.Zone()
+	return before > after && t.Sub(start) < time.Duration(before-after)*time.Second
+}
@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeCronStore 在内存中记录已认领的计划时间和调用状态
+type fakeCronStore struct {
+	lastFired   map[string]time.Time
+	invocations map[string]*domain.Invocation
+}
+
+func (s *fakeCronStore) ListFunctions(offset, limit int) ([]*domain.Function, int, error) {
+	return nil, 0, nil
+}
+
+func (s *fakeCronStore) ClaimCronFire(functionID string, scheduledAt time.Time) (bool, error) {
+	if last, ok := s.lastFired[functionID]; ok && !last.Before(scheduledAt) {
+		return false, nil
+	}
+	s.lastFired[functionID] = scheduledAt
+	return true, nil
+}
+
+func (s *fakeCronStore) GetInvocationByID(id string) (*domain.Invocation, error) {
+	if inv, ok := s.invocations[id]; ok {
+		return inv, nil
+	}
+	return nil, errors.New("invocation not found")
+}
+
+func TestCronScheduleDSTTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		from  string
+		wants []string
+	}{
+		// 2026-03-08 02:00 EST 拨快到 03:00 EDT，02:30 不存在，在切换时刻触发
+		{"spring forward", "0 30 2 * * *", "2026-03-07T12:00:00Z", []string{"2026-03-08T07:00:00Z", "2026-03-09T06:30:00Z"}},
+		// 2026-11-01 02:00 EDT 拨慢到 01:00 EST，01:30 出现两次，只触发第一次
+		{"fall back", "0 30 1 * * *", "2026-10-31T12:00:00Z", []string{"2026-11-01T05:30:00Z", "2026-11-02T06:30:00Z"}},
+		// 不在切换时段的任务不受影响
+		{"unaffected hour", "0 0 9 * * *", "2026-10-31T14:00:00Z", []string{"2026-11-01T14:00:00Z", "2026-11-02T14:00:00Z"}},
+		// 每小时任务按实际经过的时间触发，重复的一小时照常触发
+		{"hourly", "0 0 * * * *", "2026-11-01T04:30:00Z", []string{"2026-11-01T05:00:00Z", "2026-11-01T06:00:00Z", "2026-11-01T07:00:00Z"}},
+	}
+	for _, tt := range tests {
+		schedule, err := parseCronSchedule(tt.expr, "America/New_York")
+		if err != nil {
+			t.Fatalf("%s: parseCronSchedule() error = %v", tt.name, err)
+		}
+		next, _ := time.Parse(time.RFC3339, tt.from)
+		for i, want := range tt.wants {
+			next = schedule.Next(next)
+			if got := next.UTC().Format(time.RFC3339); got != want {
+				t.Errorf("%s: fire %d = %s, want %s", tt.name, i+1, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseCronScheduleTimezone(t *testing.T) {
+	schedule, err := parseCronSchedule("0 0 8 * * *", "Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("parseCronSchedule() error = %v", err)
+	}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got, want := schedule.Next(from), time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+
+	if _, err := parseCronSchedule("0 0 8 * * *", "Mars/Olympus"); !errors.Is(err, domain.ErrInvalidCronTimezone) {
+		t.Errorf("unknown timezone: error = %v, want ErrInvalidCronTimezone", err)
+	}
+	if _, err := parseCronSchedule("CRON_TZ=UTC 0 0 8 * * *", "Asia/Shanghai"); !errors.Is(err, domain.ErrInvalidCronTimezone) {
+		t.Errorf("CRON_TZ prefix with timezone: error = %v, want ErrInvalidCronTimezone", err)
+	}
+}
+
+func TestCronManagerFireSuppressesDuplicatesAndOverlaps(t *testing.T) {
+	store := &fakeCronStore{lastFired: make(map[string]time.Time), invocations: make(map[string]*domain.Invocation)}
+	var requests []*domain.InvokeRequest
+	invoker := func(req *domain.InvokeRequest) (string, error) {
+		requests = append(requests, req)
+		id := fmt.Sprintf("inv-%d", len(requests))
+		store.invocations[id] = &domain.Invocation{ID: id, Status: domain.InvocationStatusRunning}
+		return id, nil
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cm := NewCronManager(store, invoker, logger)
+
+	fn := &domain.Function{ID: "fn-1", Name: "report", CronExpression: "0 * * * * *", CronTimezone: "Asia/Shanghai"}
+	schedule, err := parseCronSchedule(fn.CronExpression, fn.CronTimezone)
+	if err != nil {
+		t.Fatalf("parseCronSchedule() error = %v", err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 300*int(time.Millisecond), time.UTC)
+	cm.now = func() time.Time { return now }
+
+	// 非主节点不触发
+	leader := false
+	cm.SetLeaderCheck(func() bool { return leader })
+	cm.fire(fn, schedule)
+	if len(requests) != 0 {
+		t.Fatalf("follower fired %d invocations", len(requests))
+	}
+
+	leader = true
+	cm.fire(fn, schedule)
+	if len(requests) != 1 {
+		t.Fatalf("first fire: %d invocations, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.Trigger != domain.TriggerCron || !req.Async {
+		t.Errorf("request trigger = %q async = %v, want async cron", req.Trigger, req.Async)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload["scheduled_time"] != "2026-01-01T12:00:00Z" || payload["timezone"] != "Asia/Shanghai" {
+		t.Errorf("payload = %v", payload)
+	}
+
+	// 上一次调用仍在执行，下一分钟的触发被跳过且不占用计划时间
+	now = now.Add(time.Minute)
+	cm.fire(fn, schedule)
+	if len(requests) != 1 {
+		t.Fatalf("overlapping fire: %d invocations, want 1", len(requests))
+	}
+	if !store.lastFired["fn-1"].Equal(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("overlapping fire claimed %v", store.lastFired["fn-1"])
+	}
+
+	// 调用完成后恢复触发；同一计划时间（如另一个网关实例）只触发一次
+	store.invocations["inv-1"].Status = domain.InvocationStatusSuccess
+	cm.fire(fn, schedule)
+	store.invocations["inv-2"].Status = domain.InvocationStatusSuccess
+	cm.fire(fn, schedule)
+	if len(requests) != 2 {
+		t.Fatalf("after completion: %d invocations, want 2", len(requests))
+	}
+}
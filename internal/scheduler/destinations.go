@@ -0,0 +1,241 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现了异步调用结果的投递（Destinations），
+// 将调用结果按函数配置路由到另一个函数、Webhook 或死信队列。
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// destinationMaxAttempts 是单次投递的最大尝试次数
+	destinationMaxAttempts = 3
+	// destinationBaseBackoff 是投递重试的初始退避时间，每次重试翻倍
+	destinationBaseBackoff = 500 * time.Millisecond
+	// destinationWebhookTimeout 是 Webhook 投递的请求超时时间
+	destinationWebhookTimeout = 10 * time.Second
+)
+
+// AsyncInvoker 是异步调用函数的方法签名，用于将结果投递到另一个函数。
+type AsyncInvoker func(req *domain.InvokeRequest) (string, error)
+
+// DestinationStore 是结果投递依赖的存储接口，由 *storage.PostgresStore 实现
+type DestinationStore interface {
+	// GetFunctionDestinations 读取函数的投递配置，配置无法解析时返回错误
+	GetFunctionDestinations(functionID string) (*domain.DestinationConfig, error)
+	GetFunctionByName(name string) (*domain.Function, error)
+	CreateDLQMessage(msg *domain.DeadLetterMessage) error
+}
+
+// DestinationDispatcher 负责将异步调用的结果投递到函数配置的目标。
+// 成功结果投递到 on_success，失败结果投递到 on_failure（默认死信队列）。
+// 每次投递失败会以指数退避重试，失败结果的投递最终失败时兜底写入死信队列。
+type DestinationDispatcher struct {
+	store   DestinationStore // 存储，用于读取投递配置和写入死信队列
+	invoke  AsyncInvoker     // 异步调用方法，用于 function 类型目标
+	client  *http.Client     // HTTP 客户端，用于 webhook 类型目标
+	backoff time.Duration    // 投递重试的初始退避时间，每次重试翻倍
+	logger  *logrus.Logger   // 日志记录器
+}
+
+// NewDestinationDispatcher 创建一个新的结果投递器。
+//
+// 参数:
+//   - store: 存储实例，通常为 PostgreSQL 存储
+//   - invoke: 异步调用方法，通常为调度器的 InvokeAsync
+//   - logger: 日志记录器实例
+//
+// 返回值:
+//   - *DestinationDispatcher: 结果投递器实例
+func NewDestinationDispatcher(store DestinationStore, invoke AsyncInvoker, logger *logrus.Logger) *DestinationDispatcher {
+	return &DestinationDispatcher{
+		store:   store,
+		invoke:  invoke,
+		client:  &http.Client{Timeout: destinationWebhookTimeout},
+		backoff: destinationBaseBackoff,
+		logger:  logger,
+	}
+}
+
+// Dispatch 在后台投递一次已结束调用的结果，不阻塞调用方。
+//
+// 参数:
+//   - fn: 源函数
+//   - inv: 已结束的调用记录
+func (d *DestinationDispatcher) Dispatch(fn *domain.Function, inv *domain.Invocation) {
+	go d.dispatch(fn, inv)
+}
+
+// dispatch 根据调用结果选择目标并执行投递。
+func (d *DestinationDispatcher) dispatch(fn *domain.Function, inv *domain.Invocation) {
+	logger := d.logger.WithFields(logrus.Fields{
+		"invocation_id": inv.ID,
+		"function_id":   fn.ID,
+		"function_name": fn.Name,
+	})
+
+	cfg, err := d.store.GetFunctionDestinations(fn.ID)
+	if err != nil {
+		// 配置读取失败或无法解析时按未配置处理：成功结果不投递，失败结果写入死信队列
+		logger.WithError(err).Warn("Failed to load function destinations")
+		cfg = &domain.DestinationConfig{FunctionID: fn.ID}
+	}
+
+	success := inv.Status == domain.InvocationStatusSuccess
+	dest := cfg.OnSuccess
+	if !success {
+		dest = cfg.OnFailure
+		if dest == nil {
+			// 未配置失败目标时，默认写入死信队列
+			dest = &domain.Destination{Type: domain.DestinationDLQ}
+		}
+	}
+	if dest == nil {
+		return
+	}
+
+	record := &domain.DestinationRecord{
+		InvocationID: inv.ID,
+		FunctionID:   fn.ID,
+		FunctionName: fn.Name,
+		Status:       inv.Status,
+		Input:        inv.Input,
+		Output:       inv.Output,
+		Error:        inv.Error,
+		DurationMs:   inv.DurationMs,
+		Timestamp:    time.Now(),
+	}
+	if inv.CompletedAt != nil {
+		record.Timestamp = *inv.CompletedAt
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"destination_type":   dest.Type,
+		"destination_target": dest.Target,
+	})
+
+	if err := d.deliverWithRetry(fn, inv, dest, record); err != nil {
+		logger.WithError(err).Error("Failed to deliver invocation result")
+		// 失败结果不能丢失，兜底写入死信队列
+		if !success && dest.Type != domain.DestinationDLQ {
+			if err := d.deliverToDLQ(fn, inv); err != nil {
+				logger.WithError(err).Error("Failed to write invocation result to DLQ")
+			}
+		}
+		return
+	}
+	logger.Debug("Invocation result delivered")
+}
+
+// deliverWithRetry 以指数退避重试投递，直到成功或达到最大尝试次数。
+func (d *DestinationDispatcher) deliverWithRetry(fn *domain.Function, inv *domain.Invocation, dest *domain.Destination, record *domain.DestinationRecord) error {
+	var lastErr error
+	backoff := d.backoff
+	for attempt := 1; attempt <= destinationMaxAttempts; attempt++ {
+		lastErr = d.deliver(fn, inv, dest, record)
+		if lastErr == nil {
+			return nil
+		}
+		// 循环调用和调用链过长不会因重试而改变
+		if errors.Is(lastErr, domain.ErrDependencyCycle) || errors.Is(lastErr, domain.ErrCallDepthExceeded) {
+			return lastErr
+		}
+		if attempt < destinationMaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return fmt.Errorf("delivery failed after %d attempts: %w", destinationMaxAttempts, lastErr)
+}
+
+// deliver 执行一次投递。
+func (d *DestinationDispatcher) deliver(fn *domain.Function, inv *domain.Invocation, dest *domain.Destination, record *domain.DestinationRecord) error {
+	switch dest.Type {
+	case domain.DestinationFunction:
+		return d.deliverToFunction(fn, inv, dest.Target, record)
+	case domain.DestinationWebhook:
+		return d.deliverToWebhook(dest.Target, record)
+	case domain.DestinationDLQ:
+		return d.deliverToDLQ(fn, inv)
+	default:
+		return fmt.Errorf("unsupported destination type: %s", dest.Type)
+	}
+}
+
+// deliverToFunction 以调用结果作为输入，异步调用目标函数。
+// 目标调用沿用源调用的调用链并追加源函数，调度器据此拒绝循环调用（如 A -> B -> A）和过长的调用链，
+// 并记录源函数到目标函数的依赖。
+func (d *DestinationDispatcher) deliverToFunction(fn *domain.Function, inv *domain.Invocation, targetName string, record *domain.DestinationRecord) error {
+	target, err := d.store.GetFunctionByName(targetName)
+	if err != nil {
+		return fmt.Errorf("failed to get destination function %s: %w", targetName, err)
+	}
+	// 目标已在调用链中（包括投递给自身）时不再调用，调用链长度由调度器检查
+	chain := append(append([]string{}, inv.CallChain...), fn.ID)
+	if err := domain.CheckCallChain(chain, target.ID, 0); err != nil {
+		return fmt.Errorf("destination function %s rejected: %w", targetName, err)
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal destination record: %w", err)
+	}
+	if _, err := d.invoke(&domain.InvokeRequest{FunctionID: target.ID, Payload: payload, CallChain: chain}); err != nil {
+		return fmt.Errorf("failed to invoke destination function: %w", err)
+	}
+	return nil
+}
+
+// deliverToWebhook 将调用结果 POST 到目标 URL，非 2xx 响应视为失败。
+func (d *DestinationDispatcher) deliverToWebhook(url string, record *domain.DestinationRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal destination record: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), destinationWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Nimbus-Invocation-Id", record.InvocationID)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deliverToDLQ 将调用写入死信队列。
+func (d *DestinationDispatcher) deliverToDLQ(fn *domain.Function, inv *domain.Invocation) error {
+	msg := &domain.DeadLetterMessage{
+		FunctionID:        fn.ID,
+		FunctionName:      fn.Name,
+		OriginalRequestID: inv.ID,
+		Payload:           inv.Input,
+		Error:             inv.Error,
+		RetryCount:        inv.RetryCount,
+		Status:            domain.DLQStatusPending,
+	}
+	if err := d.store.CreateDLQMessage(msg); err != nil {
+		return fmt.Errorf("failed to create DLQ message: %w", err)
+	}
+	return nil
+}
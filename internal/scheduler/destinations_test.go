@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeDestinationStore 返回预设的投递配置和函数，记录写入的死信消息
+type fakeDestinationStore struct {
+	cfg       *domain.DestinationConfig
+	cfgErr    error
+	functions map[string]*domain.Function
+	dlq       []*domain.DeadLetterMessage
+}
+
+func (s *fakeDestinationStore) GetFunctionDestinations(functionID string) (*domain.DestinationConfig, error) {
+	if s.cfgErr != nil {
+		return nil, s.cfgErr
+	}
+	if s.cfg == nil {
+		return &domain.DestinationConfig{FunctionID: functionID}, nil
+	}
+	return s.cfg, nil
+}
+
+func (s *fakeDestinationStore) GetFunctionByName(name string) (*domain.Function, error) {
+	if fn, ok := s.functions[name]; ok {
+		return fn, nil
+	}
+	return nil, domain.ErrFunctionNotFound
+}
+
+func (s *fakeDestinationStore) CreateDLQMessage(msg *domain.DeadLetterMessage) error {
+	s.dlq = append(s.dlq, msg)
+	return nil
+}
+
+func newTestDispatcher(store DestinationStore, invoke AsyncInvoker) *DestinationDispatcher {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	d := NewDestinationDispatcher(store, invoke, logger)
+	d.backoff = 0
+	return d
+}
+
+func TestDispatchSuccessToFunctionExtendsCallChain(t *testing.T) {
+	store := &fakeDestinationStore{
+		cfg: &domain.DestinationConfig{
+			OnSuccess: &domain.Destination{Type: domain.DestinationFunction, Target: "audit"},
+		},
+		functions: map[string]*domain.Function{"audit": {ID: "fn-audit", Name: "audit"}},
+	}
+	var got []*domain.InvokeRequest
+	d := newTestDispatcher(store, func(req *domain.InvokeRequest) (string, error) {
+		got = append(got, req)
+		return "inv-2", nil
+	})
+
+	fn := &domain.Function{ID: "fn-orders", Name: "orders"}
+	inv := &domain.Invocation{ID: "inv-1", Status: domain.InvocationStatusSuccess, CallChain: []string{"fn-api"}}
+	d.dispatch(fn, inv)
+
+	if len(got) != 1 {
+		t.Fatalf("invocations = %d, want 1", len(got))
+	}
+	if got[0].FunctionID != "fn-audit" || strings.Join(got[0].CallChain, ",") != "fn-api,fn-orders" {
+		t.Errorf("request = %s chain %v, want fn-audit chain [fn-api fn-orders]", got[0].FunctionID, got[0].CallChain)
+	}
+	var record domain.DestinationRecord
+	if err := json.Unmarshal(got[0].Payload, &record); err != nil || record.InvocationID != "inv-1" {
+		t.Errorf("payload = %s (%v), want record of inv-1", got[0].Payload, err)
+	}
+	// 源调用的调用链不能被修改
+	if len(inv.CallChain) != 1 {
+		t.Errorf("source chain = %v, want unchanged", inv.CallChain)
+	}
+	if len(store.dlq) != 0 {
+		t.Errorf("dlq messages = %d, want none", len(store.dlq))
+	}
+}
+
+func TestDispatchFailureDefaultsToDLQ(t *testing.T) {
+	store := &fakeDestinationStore{}
+	d := newTestDispatcher(store, nil)
+
+	fn := &domain.Function{ID: "fn-orders", Name: "orders"}
+	d.dispatch(fn, &domain.Invocation{ID: "inv-1", Status: domain.InvocationStatusFailed, Error: "boom"})
+	if len(store.dlq) != 1 || store.dlq[0].OriginalRequestID != "inv-1" || store.dlq[0].Error != "boom" {
+		t.Fatalf("dlq = %+v, want one message for inv-1", store.dlq)
+	}
+
+	// 未配置成功目标时成功结果不投递
+	d.dispatch(fn, &domain.Invocation{ID: "inv-2", Status: domain.InvocationStatusSuccess})
+	if len(store.dlq) != 1 {
+		t.Errorf("dlq messages = %d, want 1", len(store.dlq))
+	}
+}
+
+func TestDispatchConfigErrorStillSendsFailuresToDLQ(t *testing.T) {
+	// 配置无法解析时失败结果仍写入死信队列，成功结果不投递
+	store := &fakeDestinationStore{cfgErr: errors.New("invalid on_failure destination")}
+	called := false
+	d := newTestDispatcher(store, func(req *domain.InvokeRequest) (string, error) {
+		called = true
+		return "", nil
+	})
+
+	fn := &domain.Function{ID: "fn-orders", Name: "orders"}
+	d.dispatch(fn, &domain.Invocation{ID: "inv-1", Status: domain.InvocationStatusSuccess})
+	d.dispatch(fn, &domain.Invocation{ID: "inv-2", Status: domain.InvocationStatusFailed})
+	if called {
+		t.Error("destination function invoked without a valid config")
+	}
+	if len(store.dlq) != 1 || store.dlq[0].OriginalRequestID != "inv-2" {
+		t.Errorf("dlq = %+v, want one message for inv-2", store.dlq)
+	}
+}
+
+func TestDispatchWebhookRetriesThenFallsBackToDLQ(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.Header.Get("X-Nimbus-Invocation-Id") != "inv-1" {
+			t.Errorf("invocation header = %q, want inv-1", r.Header.Get("X-Nimbus-Invocation-Id"))
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	store := &fakeDestinationStore{cfg: &domain.DestinationConfig{
+		OnFailure: &domain.Destination{Type: domain.DestinationWebhook, Target: srv.URL},
+	}}
+	d := newTestDispatcher(store, nil)
+	d.dispatch(&domain.Function{ID: "fn-orders", Name: "orders"}, &domain.Invocation{ID: "inv-1", Status: domain.InvocationStatusFailed})
+
+	if attempts != destinationMaxAttempts {
+		t.Errorf("webhook attempts = %d, want %d", attempts, destinationMaxAttempts)
+	}
+	if len(store.dlq) != 1 {
+		t.Errorf("dlq messages = %d, want 1 after webhook delivery failed", len(store.dlq))
+	}
+}
+
+func TestDispatchRejectsCyclesWithoutRetry(t *testing.T) {
+	// 目标已在调用链中：A 的结果投递给 B，B 的结果再投递回 A
+	store := &fakeDestinationStore{
+		cfg: &domain.DestinationConfig{
+			OnFailure: &domain.Destination{Type: domain.DestinationFunction, Target: "a"},
+		},
+		functions: map[string]*domain.Function{"a": {ID: "fn-a", Name: "a"}},
+	}
+	calls := 0
+	d := newTestDispatcher(store, func(req *domain.InvokeRequest) (string, error) {
+		calls++
+		return "", nil
+	})
+	fnB := &domain.Function{ID: "fn-b", Name: "b"}
+	d.dispatch(fnB, &domain.Invocation{ID: "inv-1", Status: domain.InvocationStatusFailed, CallChain: []string{"fn-a"}})
+
+	if calls != 0 {
+		t.Errorf("invocations = %d, want none for a cycle", calls)
+	}
+	if len(store.dlq) != 1 {
+		t.Errorf("dlq messages = %d, want 1", len(store.dlq))
+	}
+
+	// 投递给自身同样被拒绝
+	store.functions["b"] = fnB
+	err := d.deliverWithRetry(fnB, &domain.Invocation{ID: "inv-2"}, &domain.Destination{Type: domain.DestinationFunction, Target: "b"}, &domain.DestinationRecord{})
+	if !errors.Is(err, domain.ErrDependencyCycle) || calls != 0 {
+		t.Errorf("self delivery err = %v calls = %d, want ErrDependencyCycle and no invoke", err, calls)
+	}
+}
@@ -0,0 +1,231 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现死信自动重试：周期性地取出已到达退避时间的待处理死信消息，
+// 用原始载荷重新调用函数，成功后标记为已解决，超过最大次数后丢弃。
+package scheduler
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// 死信自动重试的默认参数，可通过系统设置覆盖
+const (
+	// defaultDLQRetryBaseDelay 是第一次重试前的基础退避时间
+	defaultDLQRetryBaseDelay = time.Minute
+	// defaultDLQRetryMaxAttempts 是最大重试次数
+	defaultDLQRetryMaxAttempts = 5
+	// dlqRetryMaxExponent 是退避指数上限，避免重试次数很大时退避时间溢出
+	dlqRetryMaxExponent = 16
+	// dlqRetryBatchSize 是每轮最多重试的消息数
+	dlqRetryBatchSize = 20
+	// dlqRetryClaimTimeout 是认领后仍未写回结果的超时时间，超时的消息放回待处理状态，
+	// 需大于函数最长执行时间（300 秒）加上冷启动耗时
+	dlqRetryClaimTimeout = 15 * time.Minute
+)
+
+// InvokeFunc 同步调用函数，签名与调度器的 Invoke 方法一致
+type InvokeFunc func(req *domain.InvokeRequest) (*domain.InvokeResponse, error)
+
+// RetryWorker 周期性地按指数退避自动重试死信队列中的待处理消息。
+type RetryWorker struct {
+	store    *storage.PostgresStore
+	invoke   InvokeFunc
+	interval time.Duration
+	logger   *logrus.Logger
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewRetryWorker 创建死信自动重试任务。
+//
+// 参数:
+//   - store: PostgreSQL 存储
+//   - invoke: 同步调用函数的方法，通常为调度器的 Invoke
+//   - interval: 扫描间隔
+//   - logger: 日志记录器
+func NewRetryWorker(store *storage.PostgresStore, invoke InvokeFunc, interval time.Duration, logger *logrus.Logger) *RetryWorker {
+	return &RetryWorker{
+		store:    store,
+		invoke:   invoke,
+		interval: interval,
+		logger:   logger,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start 启动重试循环，Stop 之后可再次启动（如重新成为主节点时）
+func (w *RetryWorker) Start() {
+	w.stopCh = make(chan struct{})
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stopCh:
+				return
+			case <-ticker.C:
+				w.retryDue()
+			}
+		}
+	}()
+	w.logger.WithField("interval", w.interval).Info("DLQ retry worker started")
+}
+
+// Stop 停止重试循环并等待进行中的重试完成
+func (w *RetryWorker) Stop() {
+	close(w.stopCh)
+	w.wg.Wait()
+}
+
+// dlqRetryDelay 计算第 retryCount 次重试前的退避时间：base * 2^retryCount，指数不超过 dlqRetryMaxExponent
+func dlqRetryDelay(base time.Duration, retryCount int) time.Duration {
+	if retryCount < 0 {
+		retryCount = 0
+	}
+	if retryCount > dlqRetryMaxExponent {
+		retryCount = dlqRetryMaxExponent
+	}
+	return base << uint(retryCount)
+}
+
+// dlqNextRetryAt 返回消息下一次可以重试的时间，从未重试过的消息从入队时间开始计算
+func dlqNextRetryAt(msg *domain.DeadLetterMessage, base time.Duration) time.Time {
+	from := msg.CreatedAt
+	if msg.LastRetryAt != nil {
+		from = *msg.LastRetryAt
+	}
+	return from.Add(dlqRetryDelay(base, msg.RetryCount))
+}
+
+// settings 读取系统设置中的基础退避时间和最大重试次数，未设置或无效时使用默认值
+func (w *RetryWorker) settings() (time.Duration, int) {
+	base := defaultDLQRetryBaseDelay
+	maxAttempts := defaultDLQRetryMaxAttempts
+	if setting, err := w.store.GetSystemSetting("dlq_retry_base_delay_seconds"); err == nil {
+		if seconds, err := strconv.Atoi(setting.Value); err == nil && seconds > 0 {
+			base = time.Duration(seconds) * time.Second
+		}
+	}
+	if setting, err := w.store.GetSystemSetting("dlq_retry_max_attempts"); err == nil {
+		if attempts, err := strconv.Atoi(setting.Value); err == nil && attempts >= 0 {
+			maxAttempts = attempts
+		}
+	}
+	return base, maxAttempts
+}
+
+// retryDue 执行一轮重试：先回收认领超时的消息，再取出已到期的待处理消息并逐条重试
+func (w *RetryWorker) retryDue() {
+	// 回收崩溃或写回失败留下的认领，即使自动重试已关闭也要执行，避免消息卡在 retrying
+	if released, err := w.store.ReleaseStaleDLQClaims(dlqRetryClaimTimeout); err != nil {
+		w.logger.WithError(err).Warn("Failed to release stale DLQ claims")
+	} else if released > 0 {
+		w.logger.WithField("count", released).Warn("Released DLQ messages stuck in retrying")
+	}
+
+	base, maxAttempts := w.settings()
+	if maxAttempts == 0 {
+		return
+	}
+
+	messages, err := w.store.ListRetryableDLQMessages(base, dlqRetryMaxExponent, dlqRetryBatchSize)
+	if err != nil {
+		w.logger.WithError(err).Warn("Failed to list retryable DLQ messages")
+		return
+	}
+	for _, msg := range messages {
+		select {
+		case <-w.stopCh:
+			return
+		default:
+		}
+		w.retry(msg, base, maxAttempts)
+	}
+}
+
+// retry 重试一条死信消息并根据结果更新其状态
+func (w *RetryWorker) retry(msg *domain.DeadLetterMessage, base time.Duration, maxAttempts int) {
+	logger := w.logger.WithFields(logrus.Fields{
+		"message_id":  msg.ID,
+		"function_id": msg.FunctionID,
+	})
+
+	// 最大次数调小后，已达到上限的消息直接丢弃
+	if msg.RetryCount >= maxAttempts {
+		msg.Status = domain.DLQStatusDiscarded
+		if err := w.store.UpdateDLQMessage(msg); err != nil {
+			logger.WithError(err).Warn("Failed to discard DLQ message")
+		}
+		return
+	}
+
+	claimed, err := w.store.ClaimDLQMessage(msg)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to claim DLQ message")
+		return
+	}
+	if !claimed {
+		return // 已被其他实例或手动操作处理
+	}
+
+	retryErr := w.invokeMessage(msg)
+	if retryErr == "" {
+		msg.Status = domain.DLQStatusResolved
+		resolvedAt := time.Now()
+		msg.ResolvedAt = &resolvedAt
+		if err := w.store.UpdateDLQMessage(msg); err != nil {
+			logger.WithError(err).Warn("Failed to mark DLQ message resolved")
+			return
+		}
+		logger.WithField("retry_count", msg.RetryCount).Info("DLQ message resolved by automatic retry")
+		return
+	}
+
+	msg.Error = retryErr
+	if msg.RetryCount >= maxAttempts {
+		msg.Status = domain.DLQStatusDiscarded
+	} else {
+		msg.Status = domain.DLQStatusPending
+	}
+	if err := w.store.UpdateDLQMessage(msg); err != nil {
+		logger.WithError(err).Warn("Failed to update DLQ message after retry")
+		return
+	}
+	fields := logrus.Fields{"retry_count": msg.RetryCount, "error": retryErr}
+	if msg.Status == domain.DLQStatusDiscarded {
+		logger.WithFields(fields).Warn("DLQ message discarded after reaching max retry attempts")
+	} else {
+		fields["next_retry_at"] = dlqNextRetryAt(msg, base)
+		logger.WithFields(fields).Info("DLQ message retry failed")
+	}
+}
+
+// invokeMessage 用死信消息的原始载荷调用函数，成功时返回空字符串，失败时返回错误信息
+func (w *RetryWorker) invokeMessage(msg *domain.DeadLetterMessage) string {
+	fn, err := w.store.GetFunctionByID(msg.FunctionID)
+	if err != nil {
+		return "function not found: " + err.Error()
+	}
+	if !fn.Status.CanInvoke() {
+		return "function is not active: " + string(fn.Status)
+	}
+
+	resp, err := w.invoke(&domain.InvokeRequest{
+		FunctionID: fn.ID,
+		Payload:    msg.Payload,
+	})
+	if err != nil {
+		return err.Error()
+	}
+	if resp.Error != "" {
+		return resp.Error
+	}
+	return ""
+}
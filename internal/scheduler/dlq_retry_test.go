@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+func TestDLQRetryDelay(t *testing.T) {
+	tests := []struct {
+		retryCount int
+		want       time.Duration
+	}{
+		{-1, time.Minute},
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 4 * time.Minute},
+		{5, 32 * time.Minute},
+		{dlqRetryMaxExponent, time.Minute << dlqRetryMaxExponent},
+		{100, time.Minute << dlqRetryMaxExponent}, // 指数封顶，不会溢出
+	}
+	for _, tt := range tests {
+		if got := dlqRetryDelay(time.Minute, tt.retryCount); got != tt.want {
+			t.Errorf("dlqRetryDelay(1m, %d) = %v, want %v", tt.retryCount, got, tt.want)
+		}
+	}
+}
+
+func TestDLQNextRetryAt(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	// 从未重试：从入队时间开始退避
+	msg := &domain.DeadLetterMessage{CreatedAt: created}
+	if got, want := dlqNextRetryAt(msg, 30*time.Second), created.Add(30*time.Second); !got.Equal(want) {
+		t.Errorf("dlqNextRetryAt(never retried) = %v, want %v", got, want)
+	}
+
+	// 已重试 3 次：从上次重试时间开始退避 base * 2^3
+	lastRetry := created.Add(time.Hour)
+	msg = &domain.DeadLetterMessage{CreatedAt: created, LastRetryAt: &lastRetry, RetryCount: 3}
+	if got, want := dlqNextRetryAt(msg, 30*time.Second), lastRetry.Add(4*time.Minute); !got.Equal(want) {
+		t.Errorf("dlqNextRetryAt(3 retries) = %v, want %v", got, want)
+	}
+}
@@ -57,6 +57,11 @@ type DockerScheduler struct {
 	store    *storage.PostgresStore   // PostgreSQL 存储，用于持久化函数和调用记录
 	redis    *storage.RedisStore      // Redis 存储，用于异步调用的队列溢出处理
 	executor Executor                 // 函数执行器，负责在 Docker 容器中运行函数
+	router   *TrafficRouter           // 流量路由器，按别名的路由配置选择执行的版本
+	tracker  *InvocationTracker       // 调用跟踪器，记录正在执行的调用
+	limiter  *ConcurrencyLimiter      // 并发限制器，按函数的 max_concurrency 限制同时执行的调用数
+	destinations *DestinationDispatcher // 结果投递器，将异步调用结果投递到配置的目标
+	cache    ResultCache              // 结果缓存，缓存 cacheable 函数的成功结果
 	metrics  *metrics.Metrics         // 指标收集器，用于记录调度器性能指标
 	logger   *logrus.Logger           // 日志记录器
 
@@ -73,6 +78,9 @@ type dockerWorkItem struct {
 	invocation *domain.Invocation              // 调用记录，包含调用ID、输入参数等
 	function   *domain.Function                // 函数定义，包含运行时、处理器、超时配置等
 	resultCh   chan *domain.InvokeResponse     // 结果通道，用于同步调用时返回执行结果；异步调用时为 nil
+	envID      string                          // 调用所在的环境 ID，为空表示默认环境
+	spanCtx    trace.SpanContext               // 调用方请求的追踪上下文，无效时函数执行作为新的 trace 开始
+	release    func()                          // 释放函数的并发名额，工作项处理结束时调用
 }
 
 // NewDockerScheduler 创建一个新的基于 Docker 的函数调度器实例。
@@ -98,17 +106,26 @@ func NewDockerScheduler(
 	// 创建可取消的上下文，用于控制调度器的生命周期
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &DockerScheduler{
+	s := &DockerScheduler{
 		cfg:       cfg,
 		store:     store,
 		redis:     redis,
 		executor:  executor,
+		router:    NewTrafficRouter(store, logger),
+		tracker:   NewInvocationTracker(),
+		limiter:   NewConcurrencyLimiter(cfg.ConcurrencyLimitMode, cfg.ConcurrencyWaitTimeout),
 		metrics:   m,
 		logger:    logger,
 		workQueue: make(chan *dockerWorkItem, cfg.QueueSize), // 创建带缓冲的工作队列
 		ctx:       ctx,
 		cancel:    cancel,
 	}
+	s.destinations = NewDestinationDispatcher(store, s.InvokeAsync, logger)
+	if redis != nil {
+		s.cache = redis
+	}
+
+	return s
 }
 
 // Start 启动 Docker 调度器，开始处理函数调用请求。
@@ -117,6 +134,9 @@ func NewDockerScheduler(
 // 返回值:
 //   - error: 启动过程中的错误，当前实现始终返回 nil
 func (s *DockerScheduler) Start() error {
+	// 注册当前节点，供函数节点选择器校验
+	registerNode(s.store, s.cfg, s.logger)
+
 	// 启动指定数量的工作协程
 	for i := 0; i < s.cfg.Workers; i++ {
 		s.wg.Add(1)
@@ -193,12 +213,57 @@ func (s *DockerScheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeRespo
 		return nil, err
 	}
 
+	// 拒绝在不满足节点选择器的节点上运行
+	if err := checkNodeSelector(fn, s.cfg); err != nil {
+		return nil, err
+	}
+
+	// 拒绝沿调用链形成循环或超过最大长度的函数间调用
+	if err := domain.CheckCallChain(req.CallChain, fn.ID, s.cfg.MaxCallDepth); err != nil {
+		return nil, err
+	}
+
+	// 解析版本：显式版本或别名的路由配置决定执行的代码
+	version, aliasUsed, versionData, err := resolveInvokeVersion(s.store, s.router, s.logger, fn, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve version: %w", err)
+	}
+
+	// 解析调用所在的环境，决定生效的环境变量
+	envID, err := resolveEnvironmentID(s.store, req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取函数的并发名额，达到 max_concurrency 时拒绝或等待
+	release, err := acquireInvocationSlot(s.ctx, s.limiter, fn, req)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建调用记录，用于追踪调用状态和持久化
-	inv := domain.NewInvocation(fn.ID, fn.Name, domain.TriggerHTTP, req.Payload)
+	inv := domain.NewInvocation(fn.ID, fn.Name, req.TriggerType(), req.Payload)
 	inv.ID = uuid.New().String()
+	inv.ReplayOf = req.ReplayOf
+	inv.CallChain = req.CallChain
+	inv.Version = version
+	inv.AliasUsed = aliasUsed
+	recordVersionDecision(inv, req, version, aliasUsed)
+	recordNodeDecision(inv, fn, s.cfg)
+	applyIdentity(fn, inv, req.Identity)
+
+	// 记录函数间调用的依赖，命中结果缓存的调用同样计入
+	recordCallDependency(s.store, s.logger, fn, req.CallChain)
+
+	// 命中结果缓存时直接返回缓存的输出，不再执行函数
+	if resp, err := serveCachedResult(s.ctx, s.cache, s.store, fn, inv, s.logger); resp != nil || err != nil {
+		release()
+		return resp, err
+	}
 
 	// 持久化调用记录
 	if err := s.store.CreateInvocation(inv); err != nil {
+		release()
 		return nil, fmt.Errorf("failed to create invocation: %w", err)
 	}
 
@@ -206,8 +271,11 @@ func (s *DockerScheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeRespo
 	resultCh := make(chan *domain.InvokeResponse, 1)
 	item := &dockerWorkItem{
 		invocation: inv,
-		function:   fn,
+		function:   functionAtVersion(fn, versionData),
 		resultCh:   resultCh,
+		envID:      envID,
+		spanCtx:    requestSpanContext(req),
+		release:    release,
 	}
 
 	// 非阻塞方式提交工作项到队列
@@ -216,6 +284,7 @@ func (s *DockerScheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeRespo
 		// 成功提交到队列
 	default:
 		// 队列已满，返回错误
+		release()
 		return nil, fmt.Errorf("work queue is full")
 	}
 
@@ -261,20 +330,60 @@ func (s *DockerScheduler) InvokeAsync(req *domain.InvokeRequest) (string, error)
 		return "", err
 	}
 
+	// 拒绝在不满足节点选择器的节点上运行
+	if err := checkNodeSelector(fn, s.cfg); err != nil {
+		return "", err
+	}
+
+	// 拒绝沿调用链形成循环或超过最大长度的函数间调用
+	if err := domain.CheckCallChain(req.CallChain, fn.ID, s.cfg.MaxCallDepth); err != nil {
+		return "", err
+	}
+
+	// 解析版本：显式版本或别名的路由配置决定执行的代码
+	version, aliasUsed, versionData, err := resolveInvokeVersion(s.store, s.router, s.logger, fn, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve version: %w", err)
+	}
+
+	// 解析调用所在的环境，决定生效的环境变量
+	envID, err := resolveEnvironmentID(s.store, req.Environment)
+	if err != nil {
+		return "", err
+	}
+
+	// 获取函数的并发名额，达到 max_concurrency 时拒绝或等待
+	release, err := acquireInvocationSlot(s.ctx, s.limiter, fn, req)
+	if err != nil {
+		return "", err
+	}
+
 	// 创建调用记录
-	inv := domain.NewInvocation(fn.ID, fn.Name, domain.TriggerHTTP, req.Payload)
+	inv := domain.NewInvocation(fn.ID, fn.Name, req.TriggerType(), req.Payload)
 	inv.ID = uuid.New().String()
+	inv.ReplayOf = req.ReplayOf
+	inv.CallChain = req.CallChain
+	inv.Version = version
+	inv.AliasUsed = aliasUsed
+	recordVersionDecision(inv, req, version, aliasUsed)
+	recordNodeDecision(inv, fn, s.cfg)
+	applyIdentity(fn, inv, req.Identity)
 
 	// 持久化调用记录
 	if err := s.store.CreateInvocation(inv); err != nil {
+		release()
 		return "", fmt.Errorf("failed to create invocation: %w", err)
 	}
+	recordCallDependency(s.store, s.logger, fn, req.CallChain)
 
 	// 创建工作项，异步调用不需要结果通道
 	item := &dockerWorkItem{
 		invocation: inv,
-		function:   fn,
+		function:   functionAtVersion(fn, versionData),
 		resultCh:   nil, // 异步调用不需要等待结果
+		envID:      envID,
+		spanCtx:    requestSpanContext(req),
+		release:    release,
 	}
 
 	// 尝试提交到工作队列
@@ -284,7 +393,8 @@ func (s *DockerScheduler) InvokeAsync(req *domain.InvokeRequest) (string, error)
 		return inv.ID, nil
 	default:
 		// 队列已满，将调用ID推送到Redis作为备用队列
-		// 后续可由其他工作进程从Redis拉取并处理
+		// 后续可由其他工作进程从Redis拉取并处理，不在本调度器执行，归还名额
+		release()
 		if err := s.redis.PushInvocation(context.Background(), inv.ID); err != nil {
 			return "", fmt.Errorf("queue full and redis push failed: %w", err)
 		}
@@ -316,6 +426,21 @@ func (s *DockerScheduler) worker(id int) {
 	}
 }
 
+// functionAtVersion 返回使用指定版本代码和入口点的函数副本，供 Docker 执行器直接执行。
+// versionData 为 nil（未指定版本或别名未解析到版本）时返回函数本身。
+func functionAtVersion(fn *domain.Function, versionData *domain.FunctionVersion) *domain.Function {
+	if versionData == nil {
+		return fn
+	}
+	versioned := *fn
+	versioned.Version = versionData.Version
+	versioned.Handler = versionData.Handler
+	versioned.Code = versionData.Code
+	versioned.Binary = versionData.Binary
+	versioned.CodeHash = versionData.CodeHash
+	return &versioned
+}
+
 // processItem 处理单个工作项，执行函数调用的完整流程。
 // 该方法负责：
 //  1. 通过 Docker 执行器运行函数
@@ -329,11 +454,16 @@ func (s *DockerScheduler) worker(id int) {
 func (s *DockerScheduler) processItem(workerID int, item *dockerWorkItem) {
 	inv := item.invocation
 	fn := item.function
+	// 无论成功、失败、超时还是 panic，处理结束时都归还并发名额
+	if item.release != nil {
+		defer item.release()
+	}
 
-	// 启动分布式追踪 span，用于监控函数调用链路
-	tracer := telemetry.GetTracer("function-scheduler")
-	ctx, span := tracer.Start(s.ctx, "function.invoke",
+	// 启动分布式追踪 span，用于监控函数调用链路，调用方请求带有追踪上下文时作为其子 span
+	tracer := telemetry.GetTracer(tracerName)
+	ctx, span := tracer.Start(invokeSpanParent(s.ctx, item.spanCtx), "function.invoke",
 		trace.WithAttributes(
+			attribute.String("request_id", inv.ID),
 			attribute.String("function.id", fn.ID),
 			attribute.String("function.name", fn.Name),
 			attribute.String("function.runtime", string(fn.Runtime)),
@@ -354,10 +484,14 @@ func (s *DockerScheduler) processItem(workerID int, item *dockerWorkItem) {
 
 	// 标记调用状态为运行中
 	// 注意：Docker 模式下默认为冷启动，实际值在执行后更新
+	inv.RecordDecision(domain.DecisionStageVM, "docker_container", nil)
 	inv.Start("docker", true)
 	s.store.UpdateInvocation(inv)
 	span.AddEvent("invocation.started")
 
+	// 合并环境默认变量、函数变量和环境覆盖变量，并追加调用方身份，执行器从 fn.EnvVars 读取
+	fn.EnvVars = callChainEnvVars(fn, inv, identityEnvVars(fn, inv, effectiveEnvVars(s.store, fn, inv, item.envID, logger)))
+
 	// 获取函数关联的层
 	functionLayers, err := s.store.GetFunctionLayers(fn.ID)
 	if err != nil {
@@ -389,25 +523,29 @@ func (s *DockerScheduler) processItem(workerID int, item *dockerWorkItem) {
 		}).Debug("Layer content loaded")
 	}
 
-	// 创建带函数超时的执行上下文
+	// 创建带函数超时的执行上下文，并登记到调用跟踪器以支持中途取消
 	execCtx, cancel := context.WithTimeout(ctx, time.Duration(fn.TimeoutSec)*time.Second)
 	defer cancel()
+	s.tracker.Track(inv, fn, cancel)
+	defer s.tracker.Untrack(inv.ID)
 
 	// 通过 Docker 执行器执行函数
 	span.AddEvent("execution.start")
 
 	var resp *domain.InvokeResponse
+	runtimeCtx, runtimeSpan := startRuntimeSpan(execCtx, tracer, inv.ID)
 	// 如果有层且执行器支持层，使用 ExecuteWithLayers
 	if len(layerInfos) > 0 {
 		if layerExec, ok := s.executor.(LayerExecutor); ok {
-			resp, err = layerExec.ExecuteWithLayers(execCtx, fn, inv.Input, layerInfos)
+			resp, err = layerExec.ExecuteWithLayers(runtimeCtx, fn, inv.Input, layerInfos)
 		} else {
 			logger.Warn("Executor does not support layers, executing without layers")
-			resp, err = s.executor.Execute(execCtx, fn, inv.Input)
+			resp, err = s.executor.Execute(runtimeCtx, fn, inv.Input)
 		}
 	} else {
-		resp, err = s.executor.Execute(execCtx, fn, inv.Input)
+		resp, err = s.executor.Execute(runtimeCtx, fn, inv.Input)
 	}
+	telemetry.EndSpan(runtimeSpan, err)
 
 	if err != nil {
 		// 执行失败，处理错误类型
@@ -457,6 +595,12 @@ func (s *DockerScheduler) processItem(workerID int, item *dockerWorkItem) {
 	inv.DurationMs = resp.DurationMs
 	inv.BilledTimeMs = resp.BilledTimeMs
 	s.store.UpdateInvocation(inv)
+	storeCachedResult(s.ctx, s.cache, fn, inv, s.logger)
+
+	// 异步调用的结果投递到配置的目标
+	if item.resultCh == nil {
+		s.destinations.Dispatch(fn, inv)
+	}
 
 	// 记录调用指标
 	if s.metrics != nil {
@@ -480,6 +624,32 @@ func (s *DockerScheduler) processItem(workerID int, item *dockerWorkItem) {
 	}).Info("Invocation completed")
 }
 
+// ListActiveInvocations 返回当前正在执行的调用列表。
+// 数据来自内存中的调用跟踪器，仅包含本调度器实例上的调用。
+//
+// 返回值:
+//   - []domain.ActiveInvocation: 正在执行的调用，按开始时间升序排列
+//   - error: 当前实现始终返回 nil
+func (s *DockerScheduler) ListActiveInvocations() ([]domain.ActiveInvocation, error) {
+	return s.tracker.List(), nil
+}
+
+// CancelInvocation 取消一个正在执行的调用。
+// 返回 false 表示调用不在本调度器上执行。
+func (s *DockerScheduler) CancelInvocation(invocationID string) bool {
+	return s.tracker.Cancel(invocationID)
+}
+
+// Prewarm 为函数创建预热容器，执行器不支持预热时不做任何操作。
+// 实现 Prewarmer 接口，供 CronManager 执行函数级预热策略。
+func (s *DockerScheduler) Prewarm(fn *domain.Function, count int) (int, error) {
+	pe, ok := s.executor.(prewarmExecutor)
+	if !ok {
+		return 0, nil
+	}
+	return pe.Prewarm(s.ctx, fn, count)
+}
+
 // fail 处理工作项执行失败的情况。
 // 该方法负责更新调用状态、记录指标，并在同步调用时返回错误响应。
 //
@@ -489,14 +659,24 @@ func (s *DockerScheduler) processItem(workerID int, item *dockerWorkItem) {
 //   - statusCode: HTTP状态码（500=内部错误，504=超时）
 //   - errorType: 错误类型，用于指标分类
 func (s *DockerScheduler) fail(item *dockerWorkItem, errMsg string, statusCode int, errorType string) {
-	// 根据状态码更新调用状态
-	if statusCode == 504 {
+	// 根据状态码更新调用状态，通过取消接口中止的调用不论处于哪个阶段都标记为已取消
+	cancelled := s.tracker.Cancelled(item.invocation.ID)
+	switch {
+	case cancelled:
+		item.invocation.Cancel()
+		errMsg, statusCode, errorType = item.invocation.Error, cancelledStatusCode, "cancelled"
+	case statusCode == 504:
 		item.invocation.Timeout() // 超时
-	} else {
+	default:
 		item.invocation.Fail(errMsg) // 其他错误
 	}
 	s.store.UpdateInvocation(item.invocation)
 
+	// 异步调用的失败结果投递到 on_failure 目标（默认死信队列），主动取消的调用不投递
+	if item.resultCh == nil && !cancelled {
+		s.destinations.Dispatch(item.function, item.invocation)
+	}
+
 	// 记录错误指标
 	if s.metrics != nil {
 		s.metrics.RecordInvocation(
@@ -0,0 +1,49 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件负责解析调用所在的环境，并计算调用时实际下发给运行环境的环境变量。
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// resolveEnvironmentID 将调用请求中的环境名称解析为环境 ID。
+// 名称为空时返回空字符串，表示使用默认环境。
+func resolveEnvironmentID(store *storage.PostgresStore, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	env, err := store.GetEnvironmentByName(name)
+	if err != nil {
+		return "", fmt.Errorf("environment %s not found: %w", name, err)
+	}
+	return env.ID, nil
+}
+
+// effectiveEnvVars 返回函数在指定环境下调用时生效的环境变量，并记录到调用的决策记录中。
+// 查询失败时退回函数级环境变量，保证调用不因环境配置读取失败而中断。
+func effectiveEnvVars(store *storage.PostgresStore, fn *domain.Function, inv *domain.Invocation, environmentID string, logger *logrus.Entry) map[string]string {
+	environment := environmentID
+	if environment == "" {
+		environment = "default"
+	}
+
+	envVars, err := store.GetEffectiveEnvVars(fn.ID, environmentID)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get effective env vars, falling back to function env vars")
+		inv.RecordDecision(domain.DecisionStageEnvironment, "function_env_fallback", map[string]interface{}{
+			"environment": environment,
+			"error":       err.Error(),
+			"var_count":   len(fn.EnvVars),
+		})
+		return fn.EnvVars
+	}
+	inv.RecordDecision(domain.DecisionStageEnvironment, "merged", map[string]interface{}{
+		"environment": environment,
+		"var_count":   len(envVars),
+	})
+	return envVars
+}
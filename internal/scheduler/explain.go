@@ -0,0 +1,52 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件负责在调用路径上记录决策（版本解析、节点选择等），
+// 供 explain 接口说明一次调用为何以某种方式运行。
+package scheduler
+
+import (
+	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// recordVersionDecision 记录版本解析结果。
+//
+// 参数:
+//   - inv: 调用记录
+//   - req: 调用请求，用于区分显式版本和别名
+//   - version: 解析得到的版本号
+//   - aliasUsed: 实际生效的别名，为空表示别名未生效
+func recordVersionDecision(inv *domain.Invocation, req *domain.InvokeRequest, version int, aliasUsed string) {
+	switch {
+	case req.Version > 0:
+		inv.RecordDecision(domain.DecisionStageVersion, "explicit_version", map[string]interface{}{
+			"version": version,
+		})
+	case aliasUsed != "":
+		inv.RecordDecision(domain.DecisionStageVersion, "alias", map[string]interface{}{
+			"alias":   aliasUsed,
+			"version": version,
+		})
+	default:
+		// 别名不存在或未指定，使用函数当前代码
+		details := map[string]interface{}{"version": version}
+		if req.Alias != "" {
+			details["requested_alias"] = req.Alias
+		}
+		inv.RecordDecision(domain.DecisionStageVersion, "current_version", details)
+	}
+}
+
+// recordNodeDecision 记录节点选择器的匹配结果。
+// 调用到达此处时节点选择器已通过校验。
+func recordNodeDecision(inv *domain.Invocation, fn *domain.Function, cfg config.SchedulerConfig) {
+	if len(fn.NodeSelector) == 0 {
+		inv.RecordDecision(domain.DecisionStageNode, "any_node", map[string]interface{}{
+			"node": cfg.NodeName,
+		})
+		return
+	}
+	inv.RecordDecision(domain.DecisionStageNode, "selector_matched", map[string]interface{}{
+		"node":          cfg.NodeName,
+		"node_selector": fn.NodeSelector,
+	})
+}
@@ -0,0 +1,58 @@
+//go:build linux
+// +build linux
+
+// Package scheduler 提供函数调度器的实现。
+// 本文件负责持久化 Agent 在函数执行期间转发的日志。
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	fc "github.com/oriys/nimbus/internal/firecracker"
+	"github.com/sirupsen/logrus"
+)
+
+// functionLogWriteTimeout 写入单条函数日志的超时时间
+const functionLogWriteTimeout = 2 * time.Second
+
+// logEntryCreator 是写入日志记录的存储接口，由 PostgresStore 实现
+type logEntryCreator interface {
+	CreateLogEntry(ctx context.Context, entry *domain.LogEntry) error
+}
+
+// functionLogSink 返回把 Agent 转发的函数日志写入 logs 表的回调。
+// 每行日志以调用 ID 作为 request_id，便于按调用检索；写入失败只记录一次警告，不影响函数执行。
+//
+// 参数:
+//   - store: 日志存储
+//   - fn: 被调用的函数
+//   - inv: 调用记录
+//   - logger: 带调用上下文的日志记录器
+//
+// 返回值:
+//   - func(*fc.LogPayload): 传给 VsockClient.ExecuteWithLogs 的日志回调
+func functionLogSink(store logEntryCreator, fn *domain.Function, inv *domain.Invocation, logger *logrus.Entry) func(*fc.LogPayload) {
+	warned := false
+	return func(entry *fc.LogPayload) {
+		ts := entry.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), functionLogWriteTimeout)
+		defer cancel()
+		err := store.CreateLogEntry(ctx, &domain.LogEntry{
+			Timestamp:    ts,
+			Level:        entry.Level,
+			FunctionID:   fn.ID,
+			FunctionName: fn.Name,
+			Message:      entry.Message,
+			RequestID:    inv.ID,
+		})
+		if err != nil && !warned {
+			warned = true
+			logger.WithError(err).Warn("Failed to persist function log")
+		}
+	}
+}
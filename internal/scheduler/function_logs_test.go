@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/domain"
+	fc "github.com/oriys/nimbus/internal/firecracker"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeLogStore 在内存中记录写入的日志，err 不为空时写入失败
+type fakeLogStore struct {
+	entries []*domain.LogEntry
+	err     error
+}
+
+func (s *fakeLogStore) CreateLogEntry(ctx context.Context, entry *domain.LogEntry) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestFunctionLogSinkPersistsEachLine(t *testing.T) {
+	const n = 4
+	store := &fakeLogStore{}
+	fn := &domain.Function{ID: "fn-1", Name: "hello"}
+	inv := &domain.Invocation{ID: "inv-1"}
+	sink := functionLogSink(store, fn, inv, logrus.NewEntry(logrus.New()))
+
+	for i := 0; i < n; i++ {
+		sink(&fc.LogPayload{Stream: "stdout", Level: "info", Message: fmt.Sprintf("line %d", i)})
+	}
+
+	if len(store.entries) != n {
+		t.Fatalf("persisted %d log rows, want %d", len(store.entries), n)
+	}
+	for i, entry := range store.entries {
+		if entry.RequestID != inv.ID || entry.FunctionID != fn.ID || entry.FunctionName != fn.Name {
+			t.Errorf("row %d = %+v, want request_id %s for function %s", i, entry, inv.ID, fn.ID)
+		}
+		if entry.Message != fmt.Sprintf("line %d", i) || entry.Level != "info" || entry.Timestamp.IsZero() {
+			t.Errorf("row %d = %+v, want info line %d with timestamp", i, entry, i)
+		}
+	}
+}
+
+func TestFunctionLogSinkWarnsOnce(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	warnings := 0
+	logger.AddHook(&countingHook{count: &warnings})
+
+	sink := functionLogSink(&fakeLogStore{err: errors.New("db down")}, &domain.Function{}, &domain.Invocation{}, logrus.NewEntry(logger))
+	for i := 0; i < 3; i++ {
+		sink(&fc.LogPayload{Message: "x"})
+	}
+	if warnings != 1 {
+		t.Errorf("logged %d warnings, want 1", warnings)
+	}
+}
+
+// countingHook 统计 warn 级别的日志条数
+type countingHook struct{ count *int }
+
+func (h *countingHook) Levels() []logrus.Level { return []logrus.Level{logrus.WarnLevel} }
+
+func (h *countingHook) Fire(*logrus.Entry) error {
+	*h.count++
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件负责将网关认证得到的调用方身份传递给启用了 propagate_identity 的函数。
+package scheduler
+
+import (
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// applyIdentity 在调用记录上记录调用方身份引用，并在函数启用身份传递时将身份注入调用输入。
+// 必须在持久化调用记录之前调用，使重试和 Redis 溢出队列重新加载的调用也带有身份。
+func applyIdentity(fn *domain.Function, inv *domain.Invocation, identity *domain.Identity) {
+	inv.SetCaller(identity)
+	if !fn.PropagateIdentity {
+		return
+	}
+
+	input, ok := domain.InjectIdentity(inv.Input, identity)
+	switch {
+	case !ok:
+		inv.RecordDecision(domain.DecisionStageIdentity, "unsupported_input", map[string]interface{}{
+			"reason": "input is not a JSON object",
+		})
+		return
+	case identity == nil:
+		inv.RecordDecision(domain.DecisionStageIdentity, "anonymous", nil)
+	default:
+		inv.RecordDecision(domain.DecisionStageIdentity, "injected", map[string]interface{}{
+			"user_id": identity.UserID,
+			"method":  identity.Method,
+		})
+	}
+	inv.Input = input
+}
+
+// identityEnvVars 为启用身份传递的函数添加调用方身份环境变量。
+// 返回新的映射，不修改传入的 envVars（它可能是函数定义中的原始映射）。
+func identityEnvVars(fn *domain.Function, inv *domain.Invocation, envVars map[string]string) map[string]string {
+	if !fn.PropagateIdentity || inv.CallerID == "" {
+		return envVars
+	}
+	merged := make(map[string]string, len(envVars)+2)
+	for k, v := range envVars {
+		merged[k] = v
+	}
+	merged[domain.EnvCallerID] = inv.CallerID
+	merged[domain.EnvCallerRole] = inv.CallerRole
+	return merged
+}
@@ -0,0 +1,41 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现大输入的引用传递：超过阈值的输入上传到对象存储，执行请求只携带下载 URI，
+// 由 Agent 自行下载，避免大体积输入经过 vsock 消息。
+package scheduler
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// InputStore 保存函数输入并返回 Agent 可以直接下载的 URI，由 *storage.S3InputStore 实现
+type InputStore interface {
+	PutInput(ctx context.Context, input []byte) (string, error)
+}
+
+// offloadInput 决定输入的传递方式：输入超过阈值时上传并返回引用 URI；
+// 未配置输入存储、未超过阈值或上传失败时返回空字符串，输入内联传递。
+//
+// 参数:
+//   - ctx: 上下文
+//   - store: 输入存储，为 nil 时始终内联
+//   - thresholdBytes: 按引用传递的输入大小阈值（字节），不大于 0 时始终内联
+//   - input: 函数输入
+//   - logger: 日志记录器
+//
+// 返回值:
+//   - string: 输入的引用 URI，内联传递时为空
+func offloadInput(ctx context.Context, store InputStore, thresholdBytes int, input []byte, logger *logrus.Entry) string {
+	if store == nil || thresholdBytes <= 0 || len(input) <= thresholdBytes {
+		return ""
+	}
+	ref, err := store.PutInput(ctx, input)
+	if err != nil {
+		// 对象存储不可用时仍可内联传递，只要输入没有超过消息大小上限
+		logger.WithError(err).WithField("input_bytes", len(input)).Warn("Failed to upload input, sending it inline")
+		return ""
+	}
+	logger.WithField("input_bytes", len(input)).Debug("Passing input by reference")
+	return ref
+}
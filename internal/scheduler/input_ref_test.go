@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// memoryInputStore 记录上传的输入，err 不为 nil 时上传失败
+type memoryInputStore struct {
+	uploads [][]byte
+	err     error
+}
+
+func (s *memoryInputStore) PutInput(_ context.Context, input []byte) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	s.uploads = append(s.uploads, input)
+	return "https://objects.example.com/inputs/1", nil
+}
+
+func TestOffloadInput(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	entry := logrus.NewEntry(logger)
+	ctx := context.Background()
+	large := make([]byte, 2048)
+
+	store := &memoryInputStore{}
+	if ref := offloadInput(ctx, store, 1024, []byte(`{"small":true}`), entry); ref != "" || len(store.uploads) != 0 {
+		t.Errorf("small input: ref=%q uploads=%d, want inline", ref, len(store.uploads))
+	}
+	if ref := offloadInput(ctx, store, 1024, large, entry); ref != "https://objects.example.com/inputs/1" || len(store.uploads) != 1 {
+		t.Errorf("large input: ref=%q uploads=%d, want uploaded by reference", ref, len(store.uploads))
+	}
+
+	// 上传失败、未配置存储或阈值时回退到内联传递
+	if ref := offloadInput(ctx, &memoryInputStore{err: errors.New("s3 down")}, 1024, large, entry); ref != "" {
+		t.Errorf("upload failure: ref=%q, want inline fallback", ref)
+	}
+	if ref := offloadInput(ctx, nil, 1024, large, entry); ref != "" {
+		t.Errorf("no store: ref=%q, want inline", ref)
+	}
+	if ref := offloadInput(ctx, store, 0, large, entry); ref != "" {
+		t.Errorf("zero threshold: ref=%q, want inline", ref)
+	}
+}
@@ -0,0 +1,186 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现基于 PostgreSQL 会话级咨询锁的主节点选举：多节点部署时只有持有锁的节点
+// 运行定时触发、保留策略清理和死信重试等单例后台任务，主节点失联后由其他节点接管。
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LeaderLockName 是单例后台任务使用的咨询锁名称
+const LeaderLockName = "nimbus-leader"
+
+// errLeaderLockReleased 表示会话仍然存活但已不再持有咨询锁
+var errLeaderLockReleased = errors.New("advisory lock is no longer held")
+
+// LeaderElector 通过 pg_try_advisory_lock 选举主节点。
+// 锁属于一个专用的数据库会话，每个周期检查会话是否仍持有锁（续约）；
+// 未持有锁时每个周期尝试获取。会话断开时锁由数据库自动释放，其他节点在下一个周期接管。
+type LeaderElector struct {
+	db       *sql.DB
+	lockID   int64
+	interval time.Duration
+	logger   *logrus.Logger
+	onStart  func()
+	onStop   func()
+
+	mu     sync.Mutex // 串行化 tick 和 Stop
+	conn   *sql.Conn  // 持有锁的会话，未持有锁时为 nil
+	leader atomic.Bool
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewLeaderElector 创建主节点选举器。
+//
+// 参数:
+//   - db: PostgreSQL 连接池，持有锁期间占用其中一个连接
+//   - name: 锁名称，同名的选举器互斥
+//   - interval: 获取锁和续约的间隔，也是失去主节点后其他节点接管的最长延迟
+//   - logger: 日志记录器
+func NewLeaderElector(db *sql.DB, name string, interval time.Duration, logger *logrus.Logger) *LeaderElector {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return &LeaderElector{
+		db:       db,
+		lockID:   int64(h.Sum64() >> 1), // 保持非负，便于与 pg_locks 中的 classid/objid 比较
+		interval: interval,
+		logger:   logger,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// OnLeadership 设置成为主节点和失去主节点时的回调，需在 Start 之前调用。
+// stop 返回后才会释放锁，保证同一时间只有一个节点运行单例任务。
+func (e *LeaderElector) OnLeadership(start, stop func()) {
+	e.onStart = start
+	e.onStop = stop
+}
+
+// Start 启动选举循环，启动时立即尝试获取一次锁
+func (e *LeaderElector) Start() {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.tick()
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stopCh:
+				return
+			case <-ticker.C:
+				e.tick()
+			}
+		}
+	}()
+	e.logger.WithField("interval", e.interval).Info("Leader elector started")
+}
+
+// Stop 停止选举循环；当前为主节点时停止单例任务并释放锁，使其他节点尽快接管
+func (e *LeaderElector) Stop() {
+	close(e.stopCh)
+	e.wg.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn != nil {
+		e.resign()
+	}
+}
+
+// IsLeader 返回当前节点是否为主节点
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// tick 执行一次选举：持有锁时续约，否则尝试获取锁
+func (e *LeaderElector) tick() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+	defer cancel()
+
+	if e.conn != nil {
+		if err := e.renew(ctx); err != nil {
+			e.logger.WithError(err).Warn("Lost leadership")
+			e.resign()
+		}
+		return
+	}
+
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		e.logger.WithError(err).Warn("Leader election failed to get a database connection")
+		return
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", e.lockID).Scan(&acquired); err != nil {
+		e.logger.WithError(err).Warn("Leader election failed to try the advisory lock")
+		discardConn(conn)
+		return
+	}
+	if !acquired {
+		conn.Close()
+		return
+	}
+
+	e.conn = conn
+	e.leader.Store(true)
+	e.logger.Info("Acquired leadership, starting singleton workers")
+	if e.onStart != nil {
+		e.onStart()
+	}
+}
+
+// renew 检查持有锁的会话仍然存活且锁未被释放
+func (e *LeaderElector) renew(ctx context.Context) error {
+	var held bool
+	err := e.conn.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory' AND granted AND pid = pg_backend_pid()
+			  AND objsubid = 1 AND (classid::bigint << 32 | objid::bigint) = $1
+		)`, e.lockID).Scan(&held)
+	if err != nil {
+		return err
+	}
+	if !held {
+		return errLeaderLockReleased
+	}
+	return nil
+}
+
+// resign 停止单例任务后释放锁，调用方需持有 e.mu
+func (e *LeaderElector) resign() {
+	e.leader.Store(false)
+	if e.onStop != nil {
+		e.onStop()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+	defer cancel()
+	if _, err := e.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", e.lockID); err != nil {
+		e.logger.WithError(err).Debug("Failed to release advisory lock, closing the session instead")
+	}
+	discardConn(e.conn)
+	e.conn = nil
+	e.logger.Info("Released leadership")
+}
+
+// discardConn 关闭连接而不放回连接池，会话级咨询锁随会话结束释放，
+// 避免仍持有锁的连接被其他查询复用
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+	conn.Close()
+}
@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+	"github.com/sirupsen/logrus"
+)
+
+// testElector 是带有任务启停计数的选举器
+type testElector struct {
+	*LeaderElector
+	started, stopped int
+}
+
+func newTestElector(db *sql.DB) *testElector {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	e := &testElector{LeaderElector: NewLeaderElector(db, LeaderLockName, time.Second, logger)}
+	e.OnLeadership(func() { e.started++ }, func() { e.stopped++ })
+	return e
+}
+
+// lockResult 返回只有一个布尔值的单行查询结果
+func lockResult(v bool) sqltest.Result {
+	return sqltest.Rows([]string{"result"}, []driver.Value{v})
+}
+
+func TestLeaderElectorSingleLeader(t *testing.T) {
+	// 咨询锁由数据库按会话互斥，这里按两个实例的调用顺序预设获取锁和续约检查的结果
+	db := sqltest.New().
+		On("pg_try_advisory_lock",
+			lockResult(true),  // a 获取锁
+			lockResult(false), // b 等待
+			lockResult(false), // b 等待
+			lockResult(true),  // a 失去会话后 b 接管
+			lockResult(false), // a 等待
+			lockResult(true),  // b 停止后 a 重新获取
+		).
+		On("FROM pg_locks",
+			lockResult(true),                       // a 续约
+			sqltest.Result{Err: driver.ErrBadConn}, // a 的会话被终止
+		)
+	pool := db.Open(t)
+	a, b := newTestElector(pool), newTestElector(pool)
+
+	a.tick()
+	b.tick()
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("after first election: a=%v b=%v, want only a leading", a.IsLeader(), b.IsLeader())
+	}
+
+	// 续约不会重复启动任务，另一个实例持续等待
+	a.tick()
+	b.tick()
+	if !a.IsLeader() || b.IsLeader() || a.started != 1 || b.started != 0 {
+		t.Fatalf("after renewal: a=%v (started %d) b=%v (started %d)", a.IsLeader(), a.started, b.IsLeader(), b.started)
+	}
+	renew := db.Find("FROM pg_locks")[0].Query
+	for _, want := range []string{"locktype = 'advisory' AND granted AND pid = pg_backend_pid()", "(classid::bigint << 32 | objid::bigint) = $1"} {
+		if !strings.Contains(renew, want) {
+			t.Errorf("renewal query missing %q:\n%s", want, renew)
+		}
+	}
+
+	// 主节点会话被终止后停止任务，另一个实例接管
+	a.tick()
+	if a.IsLeader() || a.stopped != 1 {
+		t.Fatalf("after session loss: a leader=%v stopped=%d, want resigned", a.IsLeader(), a.stopped)
+	}
+	b.tick()
+	a.tick()
+	if !b.IsLeader() || a.IsLeader() || b.started != 1 {
+		t.Fatalf("after failover: a=%v b=%v (started %d), want only b leading", a.IsLeader(), b.IsLeader(), b.started)
+	}
+
+	// 主节点停止时释放锁
+	b.Stop()
+	if b.IsLeader() || b.stopped != 1 {
+		t.Fatalf("after Stop: b leader=%v stopped=%d", b.IsLeader(), b.stopped)
+	}
+	a.tick()
+	if !a.IsLeader() || a.started != 2 {
+		t.Errorf("after b stopped: a leader=%v started=%d, want a leading again", a.IsLeader(), a.started)
+	}
+	a.Stop()
+	if unlocks := db.Find("pg_advisory_unlock"); len(unlocks) != 2 {
+		t.Errorf("unlocks = %d, want one for each leader that stopped", len(unlocks))
+	}
+
+	// 所有语句都使用同一个锁 ID
+	for _, call := range db.Calls() {
+		if len(call.Args) != 1 || call.Args[0] != a.lockID {
+			t.Errorf("%s args = %v, want [%d]", call.Query, call.Args, a.lockID)
+		}
+	}
+}
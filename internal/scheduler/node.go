@@ -0,0 +1,40 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现了节点注册和节点选择器校验：
+// 调度器启动时注册当前节点及其标签，调用时拒绝在标签不匹配的节点上运行函数。
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// registerNode 将当前节点及其标签注册到存储，供保存函数时校验节点选择器。
+// 注册失败只记录警告，不影响调度器启动。
+func registerNode(store *storage.PostgresStore, cfg config.SchedulerConfig, logger *logrus.Logger) {
+	if cfg.NodeName == "" {
+		return
+	}
+	node := &domain.Node{Name: cfg.NodeName, Labels: cfg.NodeLabels}
+	if err := store.RegisterNode(node); err != nil {
+		logger.WithError(err).WithField("node", cfg.NodeName).Warn("Failed to register node")
+		return
+	}
+	logger.WithFields(logrus.Fields{
+		"node":   cfg.NodeName,
+		"labels": cfg.NodeLabels,
+	}).Info("Node registered")
+}
+
+// checkNodeSelector 检查当前节点是否满足函数的节点选择器。
+// 不满足时返回包装了 domain.ErrNodeSelectorMismatch 的错误。
+func checkNodeSelector(fn *domain.Function, cfg config.SchedulerConfig) error {
+	if domain.MatchesNodeSelector(fn.NodeSelector, cfg.NodeLabels) {
+		return nil
+	}
+	return fmt.Errorf("%w: function %s requires %v, node %s has %v",
+		domain.ErrNodeSelectorMismatch, fn.Name, fn.NodeSelector, cfg.NodeName, cfg.NodeLabels)
+}
@@ -0,0 +1,130 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现停滞调用对账：宿主在执行中途崩溃时调用记录会永远停留在 pending/running，
+// 对账任务周期性地将这些记录标记为失败，使调用统计和计费与实际执行一致。
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// staleInvocationError 是停滞调用被标记为失败时写入的错误信息
+const staleInvocationError = "invocation abandoned: no result reported before reconciliation threshold (host may have crashed)"
+
+// staleInvocationBatchSize 是每批标记的停滞调用数上限，避免长时间锁住大量调用记录
+const staleInvocationBatchSize = 500
+
+// StaleInvocationStore 是对账任务依赖的存储接口，由 *storage.PostgresStore 实现
+type StaleInvocationStore interface {
+	// MarkStaleInvocationsFailed 将一批停滞调用标记为失败，返回实际标记的调用及其标记前的状态
+	MarkStaleInvocationsFailed(olderThan time.Duration, errorMsg string, limit int) ([]*domain.Invocation, error)
+}
+
+// InvocationReconciler 周期性查找并关闭停滞的调用记录。
+type InvocationReconciler struct {
+	store     StaleInvocationStore
+	threshold time.Duration
+	interval  time.Duration
+	metrics   *metrics.Metrics
+	logger    *logrus.Logger
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewInvocationReconciler 创建停滞调用对账任务。
+//
+// 参数:
+//   - store: 调用记录存储
+//   - threshold: 调用停留在非终态超过该时长即视为停滞
+//   - interval: 对账执行间隔
+//   - m: 指标收集器，为 nil 时不上报指标
+//   - logger: 日志记录器
+func NewInvocationReconciler(store StaleInvocationStore, threshold, interval time.Duration, m *metrics.Metrics, logger *logrus.Logger) *InvocationReconciler {
+	return &InvocationReconciler{
+		store:     store,
+		threshold: threshold,
+		interval:  interval,
+		metrics:   m,
+		logger:    logger,
+	}
+}
+
+// Start 启动对账循环，启动时立即执行一次以关闭上次崩溃遗留的调用；Stop 之后可再次启动（如重新成为主节点时）
+func (r *InvocationReconciler) Start() {
+	r.stopCh = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.reconcile()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			case <-ticker.C:
+				r.reconcile()
+			}
+		}
+	}()
+	r.logger.WithFields(logrus.Fields{
+		"threshold": r.threshold,
+		"interval":  r.interval,
+	}).Info("Invocation reconciler started")
+}
+
+// Stop 停止对账循环并等待进行中的对账完成
+func (r *InvocationReconciler) Stop() {
+	close(r.stopCh)
+	r.wg.Wait()
+}
+
+// reconcile 执行一次对账：分批将停滞调用标记为失败，按实际标记的记录上报指标，
+// 直到某一批不满或收到停止信号
+func (r *InvocationReconciler) reconcile() {
+	byStatus := make(map[domain.InvocationStatus]int)
+	byFunction := make(map[string]int)
+	marked := 0
+	for {
+		batch, err := r.store.MarkStaleInvocationsFailed(r.threshold, staleInvocationError, staleInvocationBatchSize)
+		if err != nil {
+			r.logger.WithError(err).WithField("marked", marked).Warn("Failed to mark stale invocations failed")
+			break
+		}
+		for _, inv := range batch {
+			byStatus[inv.Status]++
+			byFunction[inv.FunctionName]++
+		}
+		marked += len(batch)
+		if len(batch) < staleInvocationBatchSize || r.stopping() {
+			break
+		}
+	}
+	if marked == 0 {
+		return
+	}
+
+	if r.metrics != nil {
+		for status, count := range byStatus {
+			r.metrics.RecordStaleInvocations(string(status), count)
+		}
+	}
+	r.logger.WithFields(logrus.Fields{
+		"marked":    marked,
+		"functions": byFunction,
+	}).Warn("Reconciled stale invocations")
+}
+
+// stopping 返回是否已调用 Stop
+func (r *InvocationReconciler) stopping() bool {
+	select {
+	case <-r.stopCh:
+		return true
+	default:
+		return false
+	}
+}
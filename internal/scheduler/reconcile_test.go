@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeStaleInvocationStore 按顺序返回预设的批次，记录每次调用的 limit
+type fakeStaleInvocationStore struct {
+	batches [][]*domain.Invocation
+	err     error // 预设批次用完后返回的错误
+	limits  []int
+}
+
+func (s *fakeStaleInvocationStore) MarkStaleInvocationsFailed(olderThan time.Duration, errorMsg string, limit int) ([]*domain.Invocation, error) {
+	s.limits = append(s.limits, limit)
+	if len(s.batches) == 0 {
+		return nil, s.err
+	}
+	batch := s.batches[0]
+	s.batches = s.batches[1:]
+	return batch, nil
+}
+
+func staleBatch(n int, status domain.InvocationStatus) []*domain.Invocation {
+	batch := make([]*domain.Invocation, n)
+	for i := range batch {
+		batch[i] = &domain.Invocation{ID: "inv", FunctionName: "orders", Status: status}
+	}
+	return batch
+}
+
+func newTestReconciler(store StaleInvocationStore) (*InvocationReconciler, *metrics.Metrics) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	m := metrics.NewMetricsWithRegistry("nimbus", prometheus.NewRegistry())
+	return NewInvocationReconciler(store, time.Hour, time.Minute, m, logger), m
+}
+
+func TestReconcileMarksInBatchesAndCountsMarkedRows(t *testing.T) {
+	// 满批继续处理下一批，不满的批次结束本轮
+	store := &fakeStaleInvocationStore{batches: [][]*domain.Invocation{
+		staleBatch(staleInvocationBatchSize, domain.InvocationStatusRunning),
+		staleBatch(3, domain.InvocationStatusPending),
+	}}
+	r, m := newTestReconciler(store)
+	r.reconcile()
+
+	if len(store.limits) != 2 || store.limits[0] != staleInvocationBatchSize {
+		t.Fatalf("batch calls = %v, want two calls limited to %d", store.limits, staleInvocationBatchSize)
+	}
+	if got := testutil.ToFloat64(m.StaleInvocations.WithLabelValues("running")); got != staleInvocationBatchSize {
+		t.Errorf("running stale metric = %v, want %d", got, staleInvocationBatchSize)
+	}
+	if got := testutil.ToFloat64(m.StaleInvocations.WithLabelValues("pending")); got != 3 {
+		t.Errorf("pending stale metric = %v, want 3", got)
+	}
+}
+
+func TestReconcileReportsMarkedRowsBeforeFailure(t *testing.T) {
+	// 后续批次失败时，已标记的记录仍计入指标
+	store := &fakeStaleInvocationStore{
+		batches: [][]*domain.Invocation{staleBatch(staleInvocationBatchSize, domain.InvocationStatusRunning)},
+		err:     errors.New("connection reset"),
+	}
+	r, m := newTestReconciler(store)
+	r.reconcile()
+
+	if len(store.limits) != 2 {
+		t.Fatalf("batch calls = %d, want 2", len(store.limits))
+	}
+	if got := testutil.ToFloat64(m.StaleInvocations.WithLabelValues("running")); got != staleInvocationBatchSize {
+		t.Errorf("running stale metric = %v, want %d", got, staleInvocationBatchSize)
+	}
+
+	// 没有停滞调用时不上报
+	r, m = newTestReconciler(&fakeStaleInvocationStore{})
+	r.reconcile()
+	if got := testutil.CollectAndCount(m.StaleInvocations); got != 0 {
+		t.Errorf("stale metric series = %d, want none", got)
+	}
+}
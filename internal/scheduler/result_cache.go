@@ -0,0 +1,98 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现确定性函数的结果缓存：启用 cacheable 的函数按函数 ID、版本和输入缓存成功结果，
+// 相同版本和输入的同步调用直接返回缓存的输出，不再分配运行环境。
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// ResultCache 是函数结果缓存的存储，由 *storage.RedisStore 实现
+type ResultCache interface {
+	// GetCachedResult 返回缓存的输出，未命中时返回 nil
+	GetCachedResult(ctx context.Context, key string) ([]byte, error)
+	// SetCachedResult 缓存输出，ttl 后过期
+	SetCachedResult(ctx context.Context, key string, output []byte, ttl time.Duration) error
+}
+
+// invocationWriter 是结果缓存命中时持久化调用记录所需的存储方法
+type invocationWriter interface {
+	CreateInvocation(inv *domain.Invocation) error
+	UpdateInvocation(inv *domain.Invocation) error
+}
+
+// resultCacheKey 生成结果缓存键：函数 ID、版本号和输入的 SHA-256。
+// 版本号参与缓存键，函数更新后版本号递增，旧版本的缓存不会再被命中，随 TTL 自然过期。
+func resultCacheKey(functionID string, version int, input []byte) string {
+	return fmt.Sprintf("%s:%d:%x", functionID, version, sha256.Sum256(input))
+}
+
+// usesResultCache 判断调用是否读写结果缓存：函数需启用 cacheable，有状态函数的会话调用不缓存
+func usesResultCache(cache ResultCache, fn *domain.Function, inv *domain.Invocation) bool {
+	return cache != nil && fn.Cacheable && inv.SessionKey == ""
+}
+
+// serveCachedResult 查找调用的结果缓存，命中时把调用记录为由缓存完成并持久化，返回缓存的响应。
+// 必须在注入调用方身份之后调用，使注入身份的函数按调用方分别缓存。
+//
+// 参数:
+//   - ctx: 上下文
+//   - cache: 结果缓存，为 nil 时不使用缓存
+//   - store: 调用记录存储
+//   - fn: 被调用的函数
+//   - inv: 尚未持久化的调用记录
+//   - logger: 日志记录器
+//
+// 返回值:
+//   - *domain.InvokeResponse: 命中时返回缓存的响应，未命中或不使用缓存时返回 nil
+//   - error: 持久化调用记录失败时返回错误
+func serveCachedResult(ctx context.Context, cache ResultCache, store invocationWriter, fn *domain.Function, inv *domain.Invocation, logger *logrus.Logger) (*domain.InvokeResponse, error) {
+	if !usesResultCache(cache, fn, inv) {
+		return nil, nil
+	}
+	output, err := cache.GetCachedResult(ctx, resultCacheKey(fn.ID, inv.Version, inv.Input))
+	if err != nil {
+		// 缓存不可用时按未命中处理，调用照常执行
+		logger.WithError(err).WithField("function_id", fn.ID).Warn("Failed to read result cache")
+		return nil, nil
+	}
+	if output == nil {
+		inv.RecordDecision(domain.DecisionStageCache, "miss", nil)
+		return nil, nil
+	}
+
+	inv.CompleteFromCache(output)
+	inv.RecordDecision(domain.DecisionStageCache, "hit", map[string]interface{}{"version": inv.Version})
+	if err := store.CreateInvocation(inv); err != nil {
+		return nil, fmt.Errorf("failed to create invocation: %w", err)
+	}
+	if err := store.UpdateInvocation(inv); err != nil {
+		logger.WithError(err).WithField("invocation_id", inv.ID).Warn("Failed to record cached invocation result")
+	}
+	return &domain.InvokeResponse{
+		RequestID:  inv.ID,
+		StatusCode: 200,
+		Body:       output,
+		CacheHit:   true,
+		Version:    inv.Version,
+		AliasUsed:  inv.AliasUsed,
+		SessionKey: inv.SessionKey,
+	}, nil
+}
+
+// storeCachedResult 在调用成功后缓存输出，没有输出的调用不缓存，写入失败只记录警告
+func storeCachedResult(ctx context.Context, cache ResultCache, fn *domain.Function, inv *domain.Invocation, logger *logrus.Logger) {
+	if !usesResultCache(cache, fn, inv) || inv.Status != domain.InvocationStatusSuccess || len(inv.Output) == 0 {
+		return
+	}
+	key := resultCacheKey(fn.ID, inv.Version, inv.Input)
+	if err := cache.SetCachedResult(ctx, key, inv.Output, fn.ResultCacheTTL()); err != nil {
+		logger.WithError(err).WithField("function_id", fn.ID).Warn("Failed to write result cache")
+	}
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// memoryResultCache 是记录写入 TTL 的内存结果缓存
+type memoryResultCache struct {
+	entries map[string][]byte
+	ttls    map[string]time.Duration
+}
+
+func newMemoryResultCache() *memoryResultCache {
+	return &memoryResultCache{entries: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (c *memoryResultCache) GetCachedResult(_ context.Context, key string) ([]byte, error) {
+	return c.entries[key], nil
+}
+
+func (c *memoryResultCache) SetCachedResult(_ context.Context, key string, output []byte, ttl time.Duration) error {
+	c.entries[key] = output
+	c.ttls[key] = ttl
+	return nil
+}
+
+// memoryInvocationWriter 按 ID 保存持久化的调用记录
+type memoryInvocationWriter map[string]domain.Invocation
+
+func (w memoryInvocationWriter) CreateInvocation(inv *domain.Invocation) error {
+	w[inv.ID] = *inv
+	return nil
+}
+
+func (w memoryInvocationWriter) UpdateInvocation(inv *domain.Invocation) error {
+	w[inv.ID] = *inv
+	return nil
+}
+
+// invokeWithCache 模拟调度器的同步调用：先查结果缓存，未命中时“执行”函数并写入缓存
+func invokeWithCache(t *testing.T, cache ResultCache, store memoryInvocationWriter, fn *domain.Function, input, output string) (*domain.InvokeResponse, *domain.Invocation) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	inv := domain.NewInvocation(fn.ID, fn.Name, domain.TriggerHTTP, json.RawMessage(input))
+	inv.ID = uuid.New().String()
+	inv.Version = fn.Version
+	resp, err := serveCachedResult(context.Background(), cache, store, fn, inv, logger)
+	if err != nil {
+		t.Fatalf("serveCachedResult() error = %v", err)
+	}
+	if resp != nil {
+		return resp, inv
+	}
+	inv.Start("vm-1", true)
+	inv.Complete(json.RawMessage(output), 64)
+	storeCachedResult(context.Background(), cache, fn, inv, logger)
+	return &domain.InvokeResponse{RequestID: inv.ID, StatusCode: 200, Body: inv.Output, ColdStart: inv.ColdStart}, inv
+}
+
+func TestResultCacheHitAndMiss(t *testing.T) {
+	cache, store := newMemoryResultCache(), memoryInvocationWriter{}
+	fn := &domain.Function{ID: "fn-1", Name: "sum", Version: 3, Cacheable: true, CacheTTLSec: 60}
+
+	resp, inv := invokeWithCache(t, cache, store, fn, `{"a":1,"b":2}`, `{"sum":3}`)
+	if resp.CacheHit || !inv.ColdStart || len(cache.entries) != 1 {
+		t.Fatalf("first call: cache_hit=%v cold_start=%v entries=%d, want executed and cached", resp.CacheHit, inv.ColdStart, len(cache.entries))
+	}
+	if ttl := cache.ttls[resultCacheKey("fn-1", 3, []byte(`{"a":1,"b":2}`))]; ttl != time.Minute {
+		t.Errorf("cache ttl = %v, want 1m", ttl)
+	}
+
+	// 相同版本和输入命中缓存，调用记录标记为非冷启动的缓存命中
+	resp, inv = invokeWithCache(t, cache, store, fn, `{"a":1,"b":2}`, `{"sum":"executed"}`)
+	if !resp.CacheHit || string(resp.Body) != `{"sum":3}` || resp.ColdStart {
+		t.Fatalf("second call: response = %+v, want cached {\"sum\":3}", resp)
+	}
+	saved := store[inv.ID]
+	if !saved.CacheHit || saved.ColdStart || saved.Status != domain.InvocationStatusSuccess || saved.BilledTimeMs != 0 {
+		t.Errorf("cached invocation = %+v, want success with cache_hit and no cold start", saved)
+	}
+
+	// 不同输入未命中
+	if resp, _ := invokeWithCache(t, cache, store, fn, `{"a":2,"b":2}`, `{"sum":4}`); resp.CacheHit {
+		t.Errorf("different input hit the cache")
+	}
+
+	// 未启用 cacheable 的函数不读写缓存
+	plain := &domain.Function{ID: "fn-2", Name: "now", Version: 1}
+	invokeWithCache(t, cache, store, plain, `{}`, `{"t":1}`)
+	if resp, _ := invokeWithCache(t, cache, store, plain, `{}`, `{"t":2}`); resp.CacheHit || string(resp.Body) != `{"t":2}` {
+		t.Errorf("non-cacheable function served from cache: %+v", resp)
+	}
+}
+
+func TestResultCacheInvalidatedByVersionChange(t *testing.T) {
+	cache, store := newMemoryResultCache(), memoryInvocationWriter{}
+	fn := &domain.Function{ID: "fn-1", Name: "sum", Version: 1, Cacheable: true}
+
+	invokeWithCache(t, cache, store, fn, `{"a":1}`, `"v1"`)
+	if resp, _ := invokeWithCache(t, cache, store, fn, `{"a":1}`, `"v1 again"`); !resp.CacheHit {
+		t.Fatalf("same version did not hit the cache")
+	}
+	if ttl := cache.ttls[resultCacheKey("fn-1", 1, []byte(`{"a":1}`))]; ttl != domain.DefaultCacheTTLSec*time.Second {
+		t.Errorf("default cache ttl = %v, want %ds", ttl, domain.DefaultCacheTTLSec)
+	}
+
+	// 更新函数后版本号递增，旧结果不再命中
+	fn.Version = 2
+	resp, _ := invokeWithCache(t, cache, store, fn, `{"a":1}`, `"v2"`)
+	if resp.CacheHit || string(resp.Body) != `"v2"` {
+		t.Fatalf("after update: response = %+v, want fresh v2 result", resp)
+	}
+	if resp, _ := invokeWithCache(t, cache, store, fn, `{"a":1}`, `"v2 again"`); !resp.CacheHit || string(resp.Body) != `"v2"` {
+		t.Errorf("after update: second call = %+v, want cached v2 result", resp)
+	}
+}
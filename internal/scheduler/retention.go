@@ -0,0 +1,257 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现数据保留策略管理：按系统设置中的保留天数周期性地清理调用记录、死信消息、
+// 任务记录和审计日志，并支持通过管理接口立即执行一次清理。
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oriys/nimbus/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// 保留策略的默认参数，可通过系统设置覆盖
+const (
+	// defaultLogRetentionDays 是调用记录和任务记录的默认保留天数
+	defaultLogRetentionDays = 30
+	// defaultDLQRetentionDays 是已处理死信消息的默认保留天数
+	defaultDLQRetentionDays = 90
+	// defaultAuditRetentionDays 是审计日志的默认保留天数
+	defaultAuditRetentionDays = 180
+	// defaultRetentionCleanupInterval 是自动清理的默认执行间隔
+	defaultRetentionCleanupInterval = 24 * time.Hour
+)
+
+// RetentionStore 是保留策略管理器依赖的存储接口，*storage.PostgresStore 实现了该接口。
+type RetentionStore interface {
+	GetSystemSetting(key string) (*storage.SystemSetting, error)
+	CleanupOldInvocations(retentionDays int) (int64, error)
+	ListInvocationRecordCaps() (map[string]int, error)
+	TrimFunctionInvocations(functionID string, keepLatest int) (int64, error)
+	TrimFunctionInvocationsPreferFailed(functionID string, keepLatest int) (int64, error)
+	CleanupOldDLQMessages(retentionDays int) (int64, error)
+	CleanupOldTasks(retentionDays int) (int64, error)
+	CleanupOldAuditLogs(retentionDays int) (int64, error)
+}
+
+// RetentionResult 是一次保留策略清理的结果。
+// 某一项清理失败时其计数为 0，错误信息记录在 Errors 中，不影响其他清理项。
+type RetentionResult struct {
+	InvocationsDeleted int64     `json:"invocations_deleted"`
+	InvocationsTrimmed int64     `json:"invocations_trimmed"`
+	DLQDeleted         int64     `json:"dlq_deleted"`
+	TasksDeleted       int64     `json:"tasks_deleted"`
+	AuditLogsDeleted   int64     `json:"audit_logs_deleted"`
+	LogRetentionDays   int       `json:"log_retention_days"`
+	DLQRetentionDays   int       `json:"dlq_retention_days"`
+	AuditRetentionDays int       `json:"audit_retention_days"`
+	StartedAt          time.Time `json:"started_at"`
+	DurationMs         int64     `json:"duration_ms"`
+	Errors             []string  `json:"errors,omitempty"`
+}
+
+// RetentionManager 按系统设置周期性地执行保留策略清理。
+// 自动清理的间隔由系统设置 retention_cleanup_interval_hours 决定，每次检查时重新读取，
+// 修改后无需重启即可生效；设置为 0 时关闭自动清理，仍可通过 RunNow 手动执行。
+type RetentionManager struct {
+	store         RetentionStore
+	checkInterval time.Duration
+	logger        *logrus.Logger
+	runMu         sync.Mutex // 保证同一时间只有一次清理在执行
+	mu            sync.Mutex
+	lastRun       time.Time
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewRetentionManager 创建保留策略管理器。
+//
+// 参数:
+//   - store: 存储，通常为 *storage.PostgresStore
+//   - checkInterval: 检查是否到达清理时间的间隔
+//   - logger: 日志记录器
+func NewRetentionManager(store RetentionStore, checkInterval time.Duration, logger *logrus.Logger) *RetentionManager {
+	return &RetentionManager{
+		store:         store,
+		checkInterval: checkInterval,
+		logger:        logger,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// Start 启动清理循环，第一次检查时即执行一次清理；Stop 之后可再次启动（如重新成为主节点时）
+func (m *RetentionManager) Start() {
+	m.stopCh = make(chan struct{})
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			case now := <-ticker.C:
+				m.tick(now)
+			}
+		}
+	}()
+	m.logger.WithField("check_interval", m.checkInterval).Info("Retention manager started")
+}
+
+// Stop 停止清理循环并等待进行中的清理完成
+func (m *RetentionManager) Stop() {
+	close(m.stopCh)
+	m.wg.Wait()
+}
+
+// Interval 返回系统设置中的自动清理间隔，未设置或无效时使用默认值，返回 0 表示关闭自动清理
+func (m *RetentionManager) Interval() time.Duration {
+	setting, err := m.store.GetSystemSetting("retention_cleanup_interval_hours")
+	if err != nil {
+		return defaultRetentionCleanupInterval
+	}
+	hours, err := strconv.Atoi(setting.Value)
+	if err != nil || hours < 0 {
+		return defaultRetentionCleanupInterval
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// due 判断在 now 时刻是否应执行自动清理
+func (m *RetentionManager) due(now time.Time) bool {
+	interval := m.Interval()
+	if interval == 0 {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastRun.IsZero() || now.Sub(m.lastRun) >= interval
+}
+
+// tick 到达清理时间时执行一次自动清理
+func (m *RetentionManager) tick(now time.Time) {
+	if !m.due(now) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-m.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if _, err := m.RunNow(ctx); err != nil {
+		m.logger.WithError(err).Warn("Scheduled retention cleanup finished with errors")
+	}
+}
+
+// retentionDays 读取以天为单位的保留设置，未设置或无效时使用默认值
+func (m *RetentionManager) retentionDays(key string, fallback int) int {
+	if setting, err := m.store.GetSystemSetting(key); err == nil {
+		if days, err := strconv.Atoi(setting.Value); err == nil && days > 0 {
+			return days
+		}
+	}
+	return fallback
+}
+
+// RunNow 立即执行一次保留策略清理并返回各项清理的删除数。
+// 与自动清理互斥，已有清理在执行时等待其完成后再执行。
+// 单项清理失败不影响其他清理项，所有失败合并为返回的错误；ctx 取消时跳过剩余的清理项。
+//
+// 参数:
+//   - ctx: 上下文，取消时停止执行剩余的清理项
+//
+// 返回值:
+//   - *RetentionResult: 清理结果，出错时也返回已完成部分的计数
+//   - error: 各清理项的错误合并后的结果
+func (m *RetentionManager) RunNow(ctx context.Context) (*RetentionResult, error) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	result := &RetentionResult{
+		LogRetentionDays:   m.retentionDays("log_retention_days", defaultLogRetentionDays),
+		DLQRetentionDays:   m.retentionDays("dlq_retention_days", defaultDLQRetentionDays),
+		AuditRetentionDays: m.retentionDays("audit_retention_days", defaultAuditRetentionDays),
+		StartedAt:          time.Now(),
+	}
+
+	var errs []error
+	steps := []struct {
+		name  string
+		count *int64
+		run   func() (int64, error)
+	}{
+		{"invocations", &result.InvocationsDeleted, func() (int64, error) { return m.store.CleanupOldInvocations(result.LogRetentionDays) }},
+		{"invocation caps", &result.InvocationsTrimmed, m.trimInvocationsByCap},
+		{"dlq", &result.DLQDeleted, func() (int64, error) { return m.store.CleanupOldDLQMessages(result.DLQRetentionDays) }},
+		{"tasks", &result.TasksDeleted, func() (int64, error) { return m.store.CleanupOldTasks(result.LogRetentionDays) }},
+		{"audit logs", &result.AuditLogsDeleted, func() (int64, error) { return m.store.CleanupOldAuditLogs(result.AuditRetentionDays) }},
+	}
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("retention cleanup interrupted before %s: %w", step.name, err))
+			break
+		}
+		n, err := step.run()
+		*step.count = n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cleanup %s: %w", step.name, err))
+		}
+	}
+	for _, err := range errs {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+
+	m.mu.Lock()
+	m.lastRun = result.StartedAt
+	m.mu.Unlock()
+
+	m.logger.WithFields(logrus.Fields{
+		"invocations_deleted": result.InvocationsDeleted,
+		"invocations_trimmed": result.InvocationsTrimmed,
+		"dlq_deleted":         result.DLQDeleted,
+		"tasks_deleted":       result.TasksDeleted,
+		"audit_logs_deleted":  result.AuditLogsDeleted,
+		"duration_ms":         result.DurationMs,
+	}).Info("Retention cleanup completed")
+	return result, errors.Join(errs...)
+}
+
+// trimInvocationsByCap 对设置了 max_invocation_records 的函数裁剪超出上限的调用记录。
+// 系统设置 invocation_trim_preserve_failed 为 true 时优先保留失败记录。
+// 单个函数裁剪失败不影响其他函数，返回已裁剪的总数和合并后的错误。
+func (m *RetentionManager) trimInvocationsByCap() (int64, error) {
+	caps, err := m.store.ListInvocationRecordCaps()
+	if err != nil {
+		return 0, err
+	}
+
+	trim := m.store.TrimFunctionInvocations
+	if setting, err := m.store.GetSystemSetting("invocation_trim_preserve_failed"); err == nil {
+		if preserveFailed, _ := strconv.ParseBool(setting.Value); preserveFailed {
+			trim = m.store.TrimFunctionInvocationsPreferFailed
+		}
+	}
+
+	var total int64
+	var errs []error
+	for functionID, limit := range caps {
+		deleted, err := trim(functionID, limit)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("function %s: %w", functionID, err))
+			continue
+		}
+		total += deleted
+	}
+	return total, errors.Join(errs...)
+}
@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeRetentionStore 记录清理调用使用的保留天数并返回预设的删除数
+type fakeRetentionStore struct {
+	settings map[string]string
+	days     map[string]int
+	failDLQ  bool
+}
+
+func (s *fakeRetentionStore) GetSystemSetting(key string) (*storage.SystemSetting, error) {
+	v, ok := s.settings[key]
+	if !ok {
+		return nil, errors.New("setting not found")
+	}
+	return &storage.SystemSetting{Key: key, Value: v}, nil
+}
+
+func (s *fakeRetentionStore) CleanupOldInvocations(days int) (int64, error) {
+	s.days["invocations"] = days
+	return 10, nil
+}
+
+func (s *fakeRetentionStore) ListInvocationRecordCaps() (map[string]int, error) {
+	return map[string]int{"fn-1": 100, "fn-2": 50}, nil
+}
+
+func (s *fakeRetentionStore) TrimFunctionInvocations(functionID string, keepLatest int) (int64, error) {
+	return 2, nil
+}
+
+func (s *fakeRetentionStore) TrimFunctionInvocationsPreferFailed(functionID string, keepLatest int) (int64, error) {
+	return 3, nil
+}
+
+func (s *fakeRetentionStore) CleanupOldDLQMessages(days int) (int64, error) {
+	s.days["dlq"] = days
+	if s.failDLQ {
+		return 0, errors.New("connection reset")
+	}
+	return 4, nil
+}
+
+func (s *fakeRetentionStore) CleanupOldTasks(days int) (int64, error) {
+	s.days["tasks"] = days
+	return 5, nil
+}
+
+func (s *fakeRetentionStore) CleanupOldAuditLogs(days int) (int64, error) {
+	s.days["audit"] = days
+	return 6, nil
+}
+
+func newTestRetentionManager(settings map[string]string) (*RetentionManager, *fakeRetentionStore) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store := &fakeRetentionStore{settings: settings, days: make(map[string]int)}
+	return NewRetentionManager(store, time.Minute, logger), store
+}
+
+func TestRetentionManagerIntervalFromSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     time.Duration
+	}{
+		{"unset", nil, defaultRetentionCleanupInterval},
+		{"configured", map[string]string{"retention_cleanup_interval_hours": "6"}, 6 * time.Hour},
+		{"disabled", map[string]string{"retention_cleanup_interval_hours": "0"}, 0},
+		{"invalid", map[string]string{"retention_cleanup_interval_hours": "soon"}, defaultRetentionCleanupInterval},
+		{"negative", map[string]string{"retention_cleanup_interval_hours": "-1"}, defaultRetentionCleanupInterval},
+	}
+	for _, tt := range tests {
+		m, _ := newTestRetentionManager(tt.settings)
+		if got := m.Interval(); got != tt.want {
+			t.Errorf("%s: Interval() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetentionManagerSchedulesBySettingsInterval(t *testing.T) {
+	settings := map[string]string{"retention_cleanup_interval_hours": "6"}
+	m, _ := newTestRetentionManager(settings)
+
+	start := time.Now()
+	if !m.due(start) {
+		t.Fatal("due() = false before the first run")
+	}
+	m.tick(start)
+	if m.due(time.Now().Add(5 * time.Hour)) {
+		t.Error("due() = true 5h after a run with a 6h interval")
+	}
+	if !m.due(time.Now().Add(6 * time.Hour)) {
+		t.Error("due() = false 6h after a run with a 6h interval")
+	}
+
+	// 修改设置后下一次检查立即生效
+	settings["retention_cleanup_interval_hours"] = "2"
+	if !m.due(time.Now().Add(3 * time.Hour)) {
+		t.Error("due() = false 3h after a run once the interval is lowered to 2h")
+	}
+	settings["retention_cleanup_interval_hours"] = "0"
+	if m.due(time.Now().Add(1000 * time.Hour)) {
+		t.Error("due() = true with automatic cleanup disabled")
+	}
+}
+
+func TestRetentionManagerRunNowAggregatesResults(t *testing.T) {
+	m, store := newTestRetentionManager(map[string]string{
+		"log_retention_days":              "7",
+		"dlq_retention_days":              "14",
+		"audit_retention_days":            "30",
+		"invocation_trim_preserve_failed": "true",
+	})
+
+	result, err := m.RunNow(context.Background())
+	if err != nil {
+		t.Fatalf("RunNow() error = %v", err)
+	}
+	want := RetentionResult{
+		// 两个函数按优先保留失败记录的方式各裁剪 3 条
+		InvocationsDeleted: 10, InvocationsTrimmed: 6, DLQDeleted: 4, TasksDeleted: 5, AuditLogsDeleted: 6,
+		LogRetentionDays: 7, DLQRetentionDays: 14, AuditRetentionDays: 30,
+	}
+	result.StartedAt, result.DurationMs = time.Time{}, 0
+	if !reflect.DeepEqual(*result, want) {
+		t.Errorf("RunNow() = %+v, want %+v", *result, want)
+	}
+	for step, days := range map[string]int{"invocations": 7, "tasks": 7, "dlq": 14, "audit": 30} {
+		if store.days[step] != days {
+			t.Errorf("%s cleanup used %d retention days, want %d", step, store.days[step], days)
+		}
+	}
+	if m.due(time.Now()) {
+		t.Error("due() = true right after a manual run")
+	}
+}
+
+func TestRetentionManagerRunNowContinuesAfterFailure(t *testing.T) {
+	m, store := newTestRetentionManager(nil)
+	store.failDLQ = true
+
+	result, err := m.RunNow(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("RunNow() error = %v, want the DLQ cleanup error", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Errorf("result.Errors = %v, want one error", result.Errors)
+	}
+	if result.TasksDeleted != 5 || result.AuditLogsDeleted != 6 {
+		t.Errorf("cleanups after the failure did not run: %+v", *result)
+	}
+	if result.LogRetentionDays != defaultLogRetentionDays || result.DLQRetentionDays != defaultDLQRetentionDays {
+		t.Errorf("retention days = %d/%d, want defaults", result.LogRetentionDays, result.DLQRetentionDays)
+	}
+
+	// 上下文已取消时不执行任何清理
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store.days = make(map[string]int)
+	if _, err := m.RunNow(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("RunNow() with cancelled context error = %v, want context.Canceled", err)
+	}
+	if len(store.days) != 0 {
+		t.Errorf("cleanups ran with a cancelled context: %v", store.days)
+	}
+}
@@ -1,11 +1,9 @@
-//go:build linux
-// +build linux
-
 // Package scheduler 提供函数调度器的实现。
 package scheduler
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
 	"sync"
 	"time"
@@ -22,7 +20,7 @@ type TrafficRouter struct {
 	cache    map[string]*cachedAlias // functionID:aliasName -> alias
 	cacheMu  sync.RWMutex
 	cacheTTL time.Duration
-	rng      *rand.Rand
+	intn     func(n int) int // 返回 [0, n) 的随机数，测试时可替换为确定的实现，需并发安全
 	logger   *logrus.Logger
 }
 
@@ -32,26 +30,55 @@ type cachedAlias struct {
 	expiresAt time.Time
 }
 
-// NewTrafficRouter 创建新的流量路由器
+// NewTrafficRouter 创建新的流量路由器。
+// 本进程修改别名或金丝雀发布调整权重后缓存立即失效，其他实例的修改在缓存过期后生效。
 func NewTrafficRouter(store *storage.PostgresStore, logger *logrus.Logger) *TrafficRouter {
-	return &TrafficRouter{
+	r := &TrafficRouter{
 		store:    store,
 		cache:    make(map[string]*cachedAlias),
 		cacheTTL: 30 * time.Second, // 别名配置缓存 30 秒
-		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		intn:     rand.Intn,        // 全局随机源是并发安全的，调用在多个协程中并发解析别名
 		logger:   logger,
 	}
+	if store != nil {
+		store.OnAliasChanged(r.invalidateAlias)
+	}
+	return r
 }
 
-// SelectVersion 根据别名选择要执行的版本号
-// 返回选中的版本号
-func (r *TrafficRouter) SelectVersion(ctx context.Context, functionID, aliasName string) (int, error) {
-	alias, err := r.getAlias(ctx, functionID, aliasName)
+// ResolveAlias 将别名解析为本次调用要执行的版本号。
+// 路由配置合法时按权重随机选择版本；配置不合法（权重为负、总和不为 100 或版本号无效）时
+// 回退到主版本，即权重最大的有效版本（权重相同时取靠前的）。
+//
+// 参数:
+//   - functionID: 函数 ID
+//   - aliasName: 别名名称
+//
+// 返回值:
+//   - int: 选中的版本号
+//   - error: 别名不存在，或路由配置中没有任何有效版本时返回错误
+func (r *TrafficRouter) ResolveAlias(functionID, aliasName string) (int, error) {
+	alias, err := r.getAlias(context.Background(), functionID, aliasName)
 	if err != nil {
 		return 0, err
 	}
 
-	return r.weightedSelect(alias.RoutingConfig.Weights), nil
+	weights := alias.RoutingConfig.Weights
+	if err := ValidateRoutingConfig(alias.RoutingConfig); err == nil {
+		return r.weightedSelect(weights), nil
+	}
+
+	version := primaryVersion(weights)
+	if version == 0 {
+		return 0, fmt.Errorf("alias %s has no valid version in routing config: %w", aliasName, domain.ErrInvalidVersion)
+	}
+	r.logger.WithFields(logrus.Fields{
+		"function_id": functionID,
+		"alias":       aliasName,
+		"weights":     weights,
+		"version":     version,
+	}).Warn("Malformed alias routing config, falling back to primary version")
+	return version, nil
 }
 
 // getAlias 获取别名（带缓存）
@@ -93,7 +120,7 @@ func (r *TrafficRouter) weightedSelect(weights []domain.VersionWeight) int {
 	}
 
 	// 生成 0-99 的随机数
-	random := r.rng.Intn(100)
+	random := r.intn(100)
 
 	// 累计权重选择
 	cumulative := 0
@@ -108,6 +135,23 @@ func (r *TrafficRouter) weightedSelect(weights []domain.VersionWeight) int {
 	return weights[0].Version
 }
 
+// primaryVersion 返回权重最大的有效版本（权重相同时取靠前的），没有有效版本时返回 0
+func primaryVersion(weights []domain.VersionWeight) int {
+	best := -1
+	for i, w := range weights {
+		if w.Version <= 0 {
+			continue
+		}
+		if best < 0 || w.Weight > weights[best].Weight {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+	return weights[best].Version
+}
+
 // InvalidateCache 使指定别名的缓存失效
 func (r *TrafficRouter) InvalidateCache(functionID, aliasName string) {
 	cacheKey := functionID + ":" + aliasName
@@ -121,6 +165,15 @@ func (r *TrafficRouter) InvalidateCache(functionID, aliasName string) {
 	}).Debug("Alias cache invalidated")
 }
 
+// invalidateAlias 处理存储的别名变更通知，aliasName 为空时使函数的所有别名缓存失效
+func (r *TrafficRouter) invalidateAlias(functionID, aliasName string) {
+	if aliasName == "" {
+		r.InvalidateFunctionCache(functionID)
+		return
+	}
+	r.InvalidateCache(functionID, aliasName)
+}
+
 // InvalidateFunctionCache 使某个函数所有别名的缓存失效
 func (r *TrafficRouter) InvalidateFunctionCache(functionID string) {
 	r.cacheMu.Lock()
@@ -168,3 +221,45 @@ func ValidateRoutingConfig(config domain.RoutingConfig) error {
 
 	return nil
 }
+
+// resolveInvokeVersion 解析调用要执行的版本，Firecracker 和 Docker 调度器共用。
+// 优先级：显式指定版本 > 别名 > 默认 latest；别名按路由配置的权重选择版本，
+// 别名不存在或没有有效版本时回退到函数当前代码（versionData 为 nil）。
+func resolveInvokeVersion(store *storage.PostgresStore, router *TrafficRouter, logger *logrus.Logger, fn *domain.Function, req *domain.InvokeRequest) (version int, alias string, versionData *domain.FunctionVersion, err error) {
+	// 优先使用显式指定的版本号
+	if req.Version > 0 {
+		versionData, err = store.GetFunctionVersion(fn.ID, req.Version)
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("version %d not found: %w", req.Version, err)
+		}
+		return req.Version, "", versionData, nil
+	}
+
+	// 使用别名解析版本
+	aliasName := req.Alias
+	if aliasName == "" {
+		aliasName = "latest" // 默认使用 latest 别名
+	}
+
+	// 按别名的路由配置加权选择版本
+	version, err = router.ResolveAlias(fn.ID, aliasName)
+	if err != nil {
+		// 如果别名不存在或没有有效版本，回退到函数当前版本
+		logger.WithFields(logrus.Fields{
+			"function_id": fn.ID,
+			"alias":       aliasName,
+			"error":       err.Error(),
+		}).Debug("Alias not resolved, falling back to current function version")
+
+		// 使用函数当前的代码（不从版本表加载）
+		return fn.Version, "", nil, nil
+	}
+
+	// 加载版本数据
+	versionData, err = store.GetFunctionVersion(fn.ID, version)
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("failed to load version %d: %w", version, err)
+	}
+
+	return version, aliasName, versionData, nil
+}
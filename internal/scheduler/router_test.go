@@ -0,0 +1,124 @@
+//go:build linux
+// +build linux
+
+package scheduler
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestRouter 返回别名已在缓存中的流量路由器，intn 为确定的随机数实现
+func newTestRouter(intn func(n int) int, aliases ...*domain.FunctionAlias) *TrafficRouter {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	r := NewTrafficRouter(nil, logger)
+	r.intn = intn
+	for _, a := range aliases {
+		r.cache[a.FunctionID+":"+a.Name] = &cachedAlias{alias: a, expiresAt: time.Now().Add(time.Hour)}
+	}
+	return r
+}
+
+func alias(name string, weights ...domain.VersionWeight) *domain.FunctionAlias {
+	return &domain.FunctionAlias{FunctionID: "fn-1", Name: name, RoutingConfig: domain.RoutingConfig{Weights: weights}}
+}
+
+func TestResolveAliasWeightedBoundaries(t *testing.T) {
+	prod := alias("prod", domain.VersionWeight{Version: 3, Weight: 90}, domain.VersionWeight{Version: 4, Weight: 10})
+	tests := []struct {
+		random int
+		want   int
+	}{
+		{0, 3},
+		{89, 3},
+		{90, 4},
+		{99, 4},
+	}
+	for _, tt := range tests {
+		r := newTestRouter(func(n int) int { return tt.random }, prod)
+		got, err := r.ResolveAlias("fn-1", "prod")
+		if err != nil {
+			t.Fatalf("ResolveAlias() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("ResolveAlias() with random %d = %d, want %d", tt.random, got, tt.want)
+		}
+	}
+}
+
+func TestResolveAliasDistribution(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	r := newTestRouter(rng.Intn, alias("prod", domain.VersionWeight{Version: 3, Weight: 90}, domain.VersionWeight{Version: 4, Weight: 10}))
+
+	counts := map[int]int{}
+	for i := 0; i < 10000; i++ {
+		v, err := r.ResolveAlias("fn-1", "prod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[v]++
+	}
+	if counts[4] < 800 || counts[4] > 1200 {
+		t.Errorf("version 4 selected %d/10000 times, want about 10%%", counts[4])
+	}
+	if counts[3]+counts[4] != 10000 {
+		t.Errorf("unexpected versions selected: %v", counts)
+	}
+}
+
+func TestResolveAliasMalformedFallsBackToPrimary(t *testing.T) {
+	never := func(n int) int {
+		t.Fatal("random source used for malformed config")
+		return 0
+	}
+	r := newTestRouter(never,
+		// 权重总和不为 100
+		alias("sum", domain.VersionWeight{Version: 3, Weight: 30}, domain.VersionWeight{Version: 4, Weight: 50}),
+		// 负权重和无效版本号
+		alias("invalid", domain.VersionWeight{Version: 0, Weight: 100}, domain.VersionWeight{Version: 5, Weight: -10}, domain.VersionWeight{Version: 6, Weight: 10}),
+		alias("empty"),
+		alias("none", domain.VersionWeight{Version: -1, Weight: 100}),
+	)
+
+	for name, want := range map[string]int{"sum": 4, "invalid": 6} {
+		got, err := r.ResolveAlias("fn-1", name)
+		if err != nil || got != want {
+			t.Errorf("ResolveAlias(%s) = %d, %v, want primary version %d", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"empty", "none"} {
+		if _, err := r.ResolveAlias("fn-1", name); !errors.Is(err, domain.ErrInvalidVersion) {
+			t.Errorf("ResolveAlias(%s) error = %v, want ErrInvalidVersion", name, err)
+		}
+	}
+}
+
+func TestInvalidateAliasOnStoreChange(t *testing.T) {
+	other := &domain.FunctionAlias{FunctionID: "fn-2", Name: "prod"}
+	r := newTestRouter(rand.Intn, alias("prod"), alias("staging"), other)
+
+	// 单个别名变更只清除该别名
+	r.invalidateAlias("fn-1", "prod")
+	if _, ok := r.cache["fn-1:prod"]; ok {
+		t.Error("fn-1:prod still cached after alias change")
+	}
+	if _, ok := r.cache["fn-1:staging"]; !ok {
+		t.Error("fn-1:staging evicted by an unrelated alias change")
+	}
+
+	// 函数删除时清除其所有别名，不影响其他函数
+	r.invalidateAlias("fn-1", "")
+	if _, ok := r.cache["fn-1:staging"]; ok {
+		t.Error("fn-1:staging still cached after function delete")
+	}
+	if _, ok := r.cache["fn-2:prod"]; !ok {
+		t.Error("fn-2:prod evicted by another function's delete")
+	}
+}
@@ -45,6 +45,13 @@ type Scheduler struct {
 	pool      *vmpool.Pool             // 虚拟机池，管理 Firecracker 虚拟机资源
 	router    *TrafficRouter           // 流量路由器，用于版本选择和流量分配
 	snapshotMgr *snapshot.Manager      // 快照管理器，用于函数级快照
+	snapshotPool *vmpool.SnapshotPool  // 基于快照管理器的虚拟机池，冷启动时从函数快照恢复，未设置快照管理器时为 nil
+	tracker   *InvocationTracker       // 调用跟踪器，记录正在执行的调用
+	limiter   *ConcurrencyLimiter      // 并发限制器，按函数的 max_concurrency 限制同时执行的调用数
+	destinations *DestinationDispatcher // 结果投递器，将异步调用结果投递到配置的目标
+	cache     ResultCache              // 结果缓存，缓存 cacheable 函数的成功结果
+	inputs    InputStore               // 输入存储，超过阈值的输入按引用传递给 Agent，为 nil 时始终内联
+	inputRefThreshold int              // 按引用传递的输入大小阈值（字节）
 	metrics   *metrics.Metrics         // 指标收集器，用于记录调度器性能指标
 	logger    *logrus.Logger           // 日志记录器
 
@@ -63,6 +70,9 @@ type workItem struct {
 	function   *domain.Function                // 函数定义，包含运行时、处理器、超时配置等
 	version    *domain.FunctionVersion         // 要执行的版本（如果指定了版本/别名）
 	resultCh   chan *domain.InvokeResponse     // 结果通道，用于同步调用时返回执行结果；异步调用时为 nil
+	envID      string                          // 调用所在的环境 ID，为空表示默认环境
+	spanCtx    trace.SpanContext               // 调用方请求的追踪上下文，无效时函数执行作为新的 trace 开始
+	release    func()                          // 释放函数的并发名额，工作项处理结束时调用
 }
 
 // worker 表示一个工作协程。
@@ -102,12 +112,18 @@ func NewScheduler(
 		redis:     redis,
 		pool:      pool,
 		router:    NewTrafficRouter(store, logger),
+		tracker:   NewInvocationTracker(),
+		limiter:   NewConcurrencyLimiter(cfg.ConcurrencyLimitMode, cfg.ConcurrencyWaitTimeout),
 		metrics:   m,
 		logger:    logger,
 		workQueue: make(chan *workItem, cfg.QueueSize), // 创建带缓冲的工作队列
 		ctx:       ctx,
 		cancel:    cancel,
 	}
+	s.destinations = NewDestinationDispatcher(store, s.InvokeAsync, logger)
+	if redis != nil {
+		s.cache = redis
+	}
 
 	return s
 }
@@ -118,6 +134,9 @@ func NewScheduler(
 // 返回值:
 //   - error: 启动过程中的错误，当前实现始终返回 nil
 func (s *Scheduler) Start() error {
+	// 注册当前节点，供函数节点选择器校验
+	registerNode(s.store, s.cfg, s.logger)
+
 	// 启动工作协程池
 	s.workers = make([]*worker, s.cfg.Workers)
 	for i := 0; i < s.cfg.Workers; i++ {
@@ -199,21 +218,58 @@ func (s *Scheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeResponse, e
 		return nil, err
 	}
 
+	// 拒绝在不满足节点选择器的节点上运行
+	if err := checkNodeSelector(fn, s.cfg); err != nil {
+		return nil, err
+	}
+
+	// 拒绝沿调用链形成循环或超过最大长度的函数间调用
+	if err := domain.CheckCallChain(req.CallChain, fn.ID, s.cfg.MaxCallDepth); err != nil {
+		return nil, err
+	}
+
 	// 解析版本
 	version, aliasUsed, versionData, err := s.resolveVersion(fn, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve version: %w", err)
 	}
 
+	// 解析调用所在的环境，决定生效的环境变量
+	envID, err := resolveEnvironmentID(s.store, req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取函数的并发名额，达到 max_concurrency 时拒绝或等待
+	release, err := acquireInvocationSlot(s.ctx, s.limiter, fn, req)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建调用记录，用于追踪调用状态和持久化
-	inv := domain.NewInvocation(fn.ID, fn.Name, domain.TriggerHTTP, req.Payload)
+	inv := domain.NewInvocation(fn.ID, fn.Name, req.TriggerType(), req.Payload)
 	inv.ID = uuid.New().String()
+	inv.ReplayOf = req.ReplayOf
+	inv.CallChain = req.CallChain
 	inv.Version = version
 	inv.AliasUsed = aliasUsed
 	inv.SessionKey = req.SessionKey // 设置会话标识（有状态函数）
+	recordVersionDecision(inv, req, version, aliasUsed)
+	recordNodeDecision(inv, fn, s.cfg)
+	applyIdentity(fn, inv, req.Identity)
+
+	// 记录函数间调用的依赖，命中结果缓存的调用同样计入
+	recordCallDependency(s.store, s.logger, fn, req.CallChain)
+
+	// 命中结果缓存时直接返回缓存的输出，不再执行函数
+	if resp, err := serveCachedResult(s.ctx, s.cache, s.store, fn, inv, s.logger); resp != nil || err != nil {
+		release()
+		return resp, err
+	}
 
 	// 持久化调用记录
 	if err := s.store.CreateInvocation(inv); err != nil {
+		release()
 		return nil, fmt.Errorf("failed to create invocation: %w", err)
 	}
 
@@ -224,6 +280,9 @@ func (s *Scheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeResponse, e
 		function:   fn,
 		version:    versionData,
 		resultCh:   resultCh,
+		envID:      envID,
+		spanCtx:    requestSpanContext(req),
+		release:    release,
 	}
 
 	// 非阻塞方式提交工作项到队列
@@ -232,6 +291,7 @@ func (s *Scheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeResponse, e
 		// 成功提交到队列
 	default:
 		// 队列已满，返回错误
+		release()
 		return nil, fmt.Errorf("work queue is full")
 	}
 
@@ -284,23 +344,52 @@ func (s *Scheduler) InvokeAsync(req *domain.InvokeRequest) (string, error) {
 		return "", err
 	}
 
+	// 拒绝在不满足节点选择器的节点上运行
+	if err := checkNodeSelector(fn, s.cfg); err != nil {
+		return "", err
+	}
+
+	// 拒绝沿调用链形成循环或超过最大长度的函数间调用
+	if err := domain.CheckCallChain(req.CallChain, fn.ID, s.cfg.MaxCallDepth); err != nil {
+		return "", err
+	}
+
 	// 解析版本
 	version, aliasUsed, versionData, err := s.resolveVersion(fn, req)
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve version: %w", err)
 	}
 
+	// 解析调用所在的环境，决定生效的环境变量
+	envID, err := resolveEnvironmentID(s.store, req.Environment)
+	if err != nil {
+		return "", err
+	}
+
+	// 获取函数的并发名额，达到 max_concurrency 时拒绝或等待
+	release, err := acquireInvocationSlot(s.ctx, s.limiter, fn, req)
+	if err != nil {
+		return "", err
+	}
+
 	// 创建调用记录
-	inv := domain.NewInvocation(fn.ID, fn.Name, domain.TriggerHTTP, req.Payload)
+	inv := domain.NewInvocation(fn.ID, fn.Name, req.TriggerType(), req.Payload)
 	inv.ID = uuid.New().String()
+	inv.ReplayOf = req.ReplayOf
+	inv.CallChain = req.CallChain
 	inv.Version = version
 	inv.AliasUsed = aliasUsed
 	inv.SessionKey = req.SessionKey // 设置会话标识（有状态函数）
+	recordVersionDecision(inv, req, version, aliasUsed)
+	recordNodeDecision(inv, fn, s.cfg)
+	applyIdentity(fn, inv, req.Identity)
 
 	// 持久化调用记录
 	if err := s.store.CreateInvocation(inv); err != nil {
+		release()
 		return "", fmt.Errorf("failed to create invocation: %w", err)
 	}
+	recordCallDependency(s.store, s.logger, fn, req.CallChain)
 
 	// 创建工作项，异步调用不需要结果通道
 	item := &workItem{
@@ -308,6 +397,9 @@ func (s *Scheduler) InvokeAsync(req *domain.InvokeRequest) (string, error) {
 		function:   fn,
 		version:    versionData,
 		resultCh:   nil, // 异步调用不需要等待结果
+		envID:      envID,
+		spanCtx:    requestSpanContext(req),
+		release:    release,
 	}
 
 	// 尝试提交到工作队列
@@ -317,7 +409,8 @@ func (s *Scheduler) InvokeAsync(req *domain.InvokeRequest) (string, error) {
 		return inv.ID, nil
 	default:
 		// 队列已满，将调用ID推送到Redis作为备用队列
-		// 后续可由其他工作进程从Redis拉取并处理
+		// 后续可由其他工作进程从Redis拉取并处理，不在本调度器执行，归还名额
+		release()
 		if err := s.redis.PushInvocation(context.Background(), inv.ID); err != nil {
 			return "", fmt.Errorf("work queue is full and failed to push to redis: %w", err)
 		}
@@ -328,44 +421,7 @@ func (s *Scheduler) InvokeAsync(req *domain.InvokeRequest) (string, error) {
 // resolveVersion 解析要执行的版本
 // 优先级：显式指定版本 > 别名 > 默认 latest
 func (s *Scheduler) resolveVersion(fn *domain.Function, req *domain.InvokeRequest) (version int, alias string, versionData *domain.FunctionVersion, err error) {
-	ctx := context.Background()
-
-	// 优先使用显式指定的版本号
-	if req.Version > 0 {
-		versionData, err = s.store.GetFunctionVersion(fn.ID, req.Version)
-		if err != nil {
-			return 0, "", nil, fmt.Errorf("version %d not found: %w", req.Version, err)
-		}
-		return req.Version, "", versionData, nil
-	}
-
-	// 使用别名解析版本
-	aliasName := req.Alias
-	if aliasName == "" {
-		aliasName = "latest" // 默认使用 latest 别名
-	}
-
-	// 尝试通过路由器选择版本
-	version, err = s.router.SelectVersion(ctx, fn.ID, aliasName)
-	if err != nil {
-		// 如果别名不存在，回退到函数当前版本
-		s.logger.WithFields(logrus.Fields{
-			"function_id": fn.ID,
-			"alias":       aliasName,
-			"error":       err.Error(),
-		}).Debug("Alias not found, falling back to current function version")
-
-		// 使用函数当前的代码（不从版本表加载）
-		return fn.Version, "", nil, nil
-	}
-
-	// 加载版本数据
-	versionData, err = s.store.GetFunctionVersion(fn.ID, version)
-	if err != nil {
-		return 0, "", nil, fmt.Errorf("failed to load version %d: %w", version, err)
-	}
-
-	return version, aliasName, versionData, nil
+	return resolveInvokeVersion(s.store, s.router, s.logger, fn, req)
 }
 
 // Router 返回流量路由器实例
@@ -373,9 +429,28 @@ func (s *Scheduler) Router() *TrafficRouter {
 	return s.router
 }
 
-// SetSnapshotManager 设置快照管理器
+// SetSnapshotManager 设置快照管理器，之后的冷启动优先从函数版本的就绪快照恢复虚拟机
 func (s *Scheduler) SetSnapshotManager(mgr *snapshot.Manager) {
 	s.snapshotMgr = mgr
+	s.snapshotPool = nil
+	if mgr != nil {
+		s.snapshotPool = vmpool.NewSnapshotPool(s.pool, mgr)
+	}
+}
+
+// acquireVM 为调用获取虚拟机，设置了快照管理器时冷启动从函数快照恢复
+func (s *Scheduler) acquireVM(ctx context.Context, fn *domain.Function, version int, initKey string) (*vmpool.PooledVM, bool, error) {
+	if s.snapshotPool != nil {
+		return s.snapshotPool.AcquireVMFor(ctx, fn, version, initKey)
+	}
+	return s.pool.AcquireVMFor(ctx, string(fn.Runtime), initKey)
+}
+
+// SetInputStore 启用大输入的引用传递：超过 thresholdBytes 的输入上传到 store，Agent 按引用下载。
+// 需在 Start 之前调用。
+func (s *Scheduler) SetInputStore(store InputStore, thresholdBytes int) {
+	s.inputs = store
+	s.inputRefThreshold = thresholdBytes
 }
 
 // SnapshotManager 返回快照管理器实例
@@ -398,6 +473,100 @@ func (s *Scheduler) OnFunctionDeployed(ctx context.Context, fn *domain.Function,
 	}
 }
 
+// Prewarm 在虚拟机池中为函数创建预热虚拟机。
+// 每个虚拟机按函数默认路由的版本初始化，函数声明的预热函数返回后才进入预热队列，
+// 之后配置相同的调用直接复用，不再初始化。实现 Prewarmer 接口，供 CronManager 执行函数级预热策略。
+func (s *Scheduler) Prewarm(fn *domain.Function, count int) (int, error) {
+	_, _, versionData, err := s.resolveVersion(fn, &domain.InvokeRequest{FunctionID: fn.ID})
+	if err != nil {
+		return 0, err
+	}
+	logger := s.logger.WithField("function_id", fn.ID)
+	envVars := effectiveEnvVars(s.store, fn, &domain.Invocation{}, "", logger)
+	payload := s.buildInitPayload(fn, versionData, envVars, logger)
+	initKey := payload.Fingerprint()
+
+	return s.pool.PrewarmFunction(string(fn.Runtime), count, func(ctx context.Context, pvm *vmpool.PooledVM) (string, error) {
+		initCtx, cancel := context.WithTimeout(ctx, s.cfg.DefaultTimeout+fn.WarmupTimeout())
+		defer cancel()
+		// InitFunction 会填充载荷的 AcceptEncoding，并发初始化的虚拟机各用一份副本
+		vmPayload := *payload
+		if err := pvm.Client.InitFunction(initCtx, &vmPayload); err != nil {
+			return "", err
+		}
+		pvm.VM.BindFunction(fn.ID)
+		return initKey, nil
+	})
+}
+
+// buildInitPayload 构建函数的初始化载荷。
+// versionData 不为空时使用该版本的代码和入口点，否则使用函数当前代码；函数层的内容从存储中加载。
+func (s *Scheduler) buildInitPayload(fn *domain.Function, versionData *domain.FunctionVersion, envVars map[string]string, logger *logrus.Entry) *fc.InitPayload {
+	// 获取函数关联的层
+	functionLayers, err := s.store.GetFunctionLayers(fn.ID)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get function layers")
+		functionLayers = nil
+	}
+
+	// 获取每个层的内容
+	var layerInfos []fc.LayerInfo
+	for _, fl := range functionLayers {
+		content, err := s.store.GetLayerVersionContent(fl.LayerID, fl.LayerVersion)
+		if err != nil {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"layer_id":      fl.LayerID,
+				"layer_version": fl.LayerVersion,
+			}).Error("Failed to get layer content")
+			continue
+		}
+		layerInfos = append(layerInfos, fc.LayerInfo{
+			LayerID:     fl.LayerID,
+			Version:     fl.LayerVersion,
+			Content:     content,
+			Order:       fl.Order,
+			ContentHash: fl.ContentHash,
+		})
+		logger.WithFields(logrus.Fields{
+			"layer_id":      fl.LayerID,
+			"layer_version": fl.LayerVersion,
+			"layer_size":    len(content),
+		}).Debug("Layer content loaded")
+	}
+
+	if versionData != nil {
+		logger.WithField("version", versionData.Version).Debug("Using version-specific code")
+	}
+	return newInitPayload(fn, versionData, envVars, layerInfos)
+}
+
+// newInitPayload 由函数配置构建初始化载荷，versionData 不为空时使用该版本的代码和入口点。
+// 依赖安装和预热函数的配置随载荷发送给 Agent，并计入载荷指纹。
+func newInitPayload(fn *domain.Function, versionData *domain.FunctionVersion, envVars map[string]string, layers []fc.LayerInfo) *fc.InitPayload {
+	payload := &fc.InitPayload{
+		FunctionID:            fn.ID,
+		Handler:               fn.Handler,
+		Code:                  fn.Code,
+		CodeHash:              fn.CodeHash,
+		Runtime:               string(fn.Runtime),
+		EnvVars:               envVars, // 环境变量使用函数级别的（合并环境配置后）
+		MemoryLimitMB:         fn.MemoryMB,
+		TimeoutSec:            fn.TimeoutSec,
+		Layers:                layers,
+		InstallDeps:           fn.InstallDeps,
+		InstallDepsTimeoutSec: fn.InstallDepsTimeoutSec,
+		WarmupHandler:         fn.WarmupHandler,
+		WarmupTimeoutSec:      fn.WarmupTimeoutSec,
+	}
+	// 指定了版本时使用该版本的代码和入口点
+	if versionData != nil {
+		payload.Handler = versionData.Handler
+		payload.Code = versionData.Code
+		payload.CodeHash = versionData.CodeHash
+	}
+	return payload
+}
+
 // OnFunctionUpdated 函数更新后使旧快照失效
 func (s *Scheduler) OnFunctionUpdated(ctx context.Context, fn *domain.Function) {
 	if s.snapshotMgr != nil {
@@ -443,11 +612,16 @@ func (w *worker) run() {
 func (w *worker) process(item *workItem) {
 	inv := item.invocation
 	fn := item.function
+	// 无论成功、失败、超时还是 panic，处理结束时都归还并发名额
+	if item.release != nil {
+		defer item.release()
+	}
 
-	// 启动分布式追踪 span，用于监控函数调用链路
-	tracer := telemetry.GetTracer("function-scheduler")
-	ctx, span := tracer.Start(w.scheduler.ctx, "function.invoke",
+	// 启动分布式追踪 span，用于监控函数调用链路，调用方请求带有追踪上下文时作为其子 span
+	tracer := telemetry.GetTracer(tracerName)
+	ctx, span := tracer.Start(invokeSpanParent(w.scheduler.ctx, item.spanCtx), "function.invoke",
 		trace.WithAttributes(
+			attribute.String("request_id", inv.ID),
 			attribute.String("function.id", fn.ID),
 			attribute.String("function.name", fn.Name),
 			attribute.String("function.runtime", string(fn.Runtime)),
@@ -470,6 +644,12 @@ func (w *worker) process(item *workItem) {
 	})
 	logger = telemetry.EntryWithTraceContext(ctx, logger)
 
+	// 构建函数初始化载荷，其指纹用于优先选取已按相同配置初始化过的虚拟机
+	// 合并环境默认变量、函数变量和环境覆盖变量，并添加调用方身份和调用链
+	envVars := callChainEnvVars(fn, inv, identityEnvVars(fn, inv, effectiveEnvVars(w.scheduler.store, fn, inv, item.envID, logger)))
+	initPayload := w.scheduler.buildInitPayload(fn, item.version, envVars, logger)
+	initKey := initPayload.Fingerprint()
+
 	// ========== 阶段1：获取虚拟机 ==========
 	span.AddEvent("vm.acquire.start")
 	// 创建带超时的上下文，防止无限等待虚拟机
@@ -477,8 +657,8 @@ func (w *worker) process(item *workItem) {
 	defer cancel()
 
 	// 从虚拟机池获取可用虚拟机
-	// coldStart 表示是否是冷启动（新创建的虚拟机）
-	pvm, coldStart, err := w.scheduler.pool.AcquireVM(acquireCtx, string(fn.Runtime))
+	// coldStart 表示是否是冷启动（新创建或从快照恢复的虚拟机）
+	pvm, coldStart, err := w.scheduler.acquireVM(acquireCtx, fn, inv.Version, initKey)
 	if err != nil {
 		// 获取虚拟机失败，记录错误并返回失败响应
 		span.RecordError(err)
@@ -487,94 +667,67 @@ func (w *worker) process(item *workItem) {
 		w.fail(item, fmt.Sprintf("failed to acquire VM: %v", err), 500, "acquire_vm_failed")
 		return
 	}
+	// 按函数的 IO 限速调整虚拟机，失败时函数以原有限速执行，仅记录警告
+	if err := w.scheduler.pool.ApplyIOLimits(acquireCtx, pvm, fn.IOLimits); err != nil {
+		logger.WithError(err).WithField("vm_id", pvm.VM.ID).Warn("Failed to apply IO limits")
+	}
 	span.AddEvent("vm.acquire.complete", trace.WithAttributes(
 		attribute.Bool("cold_start", coldStart),
 		attribute.String("vm.id", pvm.VM.ID),
 	))
 
+	// 记录虚拟机选择：复用预热虚拟机或冷启动新虚拟机
+	if coldStart {
+		inv.RecordDecision(domain.DecisionStageVM, "cold_boot", map[string]interface{}{
+			"vm_id":             pvm.VM.ID,
+			"runtime":           pvm.Runtime,
+			"snapshot_restored": pvm.VM.RestoreDuration > 0,
+		})
+	} else {
+		inv.RecordDecision(domain.DecisionStageVM, "warm_vm", map[string]interface{}{
+			"vm_id":       pvm.VM.ID,
+			"runtime":     pvm.Runtime,
+			"use_count":   pvm.UseCount,
+			"initialized": pvm.InitKey == initKey,
+		})
+	}
+
 	// 更新调用状态为运行中
 	inv.Start(pvm.VM.ID, coldStart)
 	w.scheduler.store.UpdateInvocation(inv)
 
+	// 登记到调用跟踪器，后续阶段使用可取消的上下文，以支持中途取消
+	ctx, invCancel := context.WithCancel(ctx)
+	defer invCancel()
+	w.scheduler.tracker.Track(inv, fn, invCancel)
+	defer w.scheduler.tracker.Untrack(inv.ID)
+
 	logger = logger.WithField("vm_id", pvm.VM.ID)
 	logger.Debug("VM acquired")
 
 	// ========== 阶段2：初始化函数 ==========
-	span.AddEvent("function.init.start")
-
-	// 获取函数关联的层
-	functionLayers, err := w.scheduler.store.GetFunctionLayers(fn.ID)
-	if err != nil {
-		logger.WithError(err).Warn("Failed to get function layers")
-		functionLayers = nil
-	}
-
-	// 获取每个层的内容
-	var layerInfos []fc.LayerInfo
-	for _, fl := range functionLayers {
-		content, err := w.scheduler.store.GetLayerVersionContent(fl.LayerID, fl.LayerVersion)
-		if err != nil {
-			logger.WithError(err).WithFields(logrus.Fields{
-				"layer_id":      fl.LayerID,
-				"layer_version": fl.LayerVersion,
-			}).Error("Failed to get layer content")
-			continue
-		}
-		layerInfos = append(layerInfos, fc.LayerInfo{
-			LayerID: fl.LayerID,
-			Version: fl.LayerVersion,
-			Content: content,
-			Order:   fl.Order,
-		})
-		logger.WithFields(logrus.Fields{
-			"layer_id":      fl.LayerID,
-			"layer_version": fl.LayerVersion,
-			"layer_size":    len(content),
-		}).Debug("Layer content loaded")
-	}
-
-	// 构建函数初始化负载
-	// 如果指定了版本，使用版本数据；否则使用函数当前代码
-	var initPayload *fc.InitPayload
-	if item.version != nil {
-		// 使用指定版本的代码和配置
-		initPayload = &fc.InitPayload{
-			FunctionID:    fn.ID,
-			Handler:       item.version.Handler,
-			Code:          item.version.Code,
-			Runtime:       string(fn.Runtime),
-			EnvVars:       fn.EnvVars, // 环境变量使用函数级别的
-			MemoryLimitMB: fn.MemoryMB,
-			TimeoutSec:    fn.TimeoutSec,
-			Layers:        layerInfos,
-		}
-		logger.WithField("version", item.version.Version).Debug("Using version-specific code")
+	// 虚拟机已按相同载荷初始化过时跳过，函数声明的预热函数因此在每个虚拟机上只调用一次
+	if pvm.InitKey == initKey {
+		span.AddEvent("function.init.skipped")
 	} else {
-		// 使用函数当前代码
-		initPayload = &fc.InitPayload{
-			FunctionID:    fn.ID,
-			Handler:       fn.Handler,
-			Code:          fn.Code,
-			Runtime:       string(fn.Runtime),
-			EnvVars:       fn.EnvVars,
-			MemoryLimitMB: fn.MemoryMB,
-			TimeoutSec:    fn.TimeoutSec,
-			Layers:        layerInfos,
+		span.AddEvent("function.init.start")
+		// 初始化失败时 Agent 中的函数环境不确定，清除指纹和函数绑定使下次使用时重新初始化
+		pvm.InitKey = ""
+		pvm.VM.BindFunction("")
+		if err := pvm.Client.InitFunction(ctx, initPayload); err != nil {
+			// 初始化失败，释放虚拟机并返回错误
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to initialize function")
+			logger.WithError(err).Error("Failed to initialize function")
+			w.scheduler.pool.ReleaseVM(string(fn.Runtime), pvm.VM.ID)
+			w.fail(item, fmt.Sprintf("failed to initialize function: %v", err), 500, "init_failed")
+			return
 		}
+		pvm.InitKey = initKey
+		pvm.VM.BindFunction(fn.ID)
+		span.AddEvent("function.init.complete")
 	}
 
-	// 在虚拟机中初始化函数运行环境
-	if err := pvm.Client.InitFunction(ctx, initPayload); err != nil {
-		// 初始化失败，释放虚拟机并返回错误
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to initialize function")
-		logger.WithError(err).Error("Failed to initialize function")
-		w.scheduler.pool.ReleaseVM(string(fn.Runtime), pvm.VM.ID)
-		w.fail(item, fmt.Sprintf("failed to initialize function: %v", err), 500, "init_failed")
-		return
-	}
-	span.AddEvent("function.init.complete")
-
 	// ========== 阶段3：执行函数 ==========
 	span.AddEvent("function.execute.start")
 	// 创建带函数超时的执行上下文
@@ -582,7 +735,16 @@ func (w *worker) process(item *workItem) {
 	defer execCancel()
 
 	// 调用函数并等待结果
-	resp, err := pvm.Client.Execute(execCtx, inv.ID, inv.Input)
+	runtimeCtx, runtimeSpan := startRuntimeSpan(execCtx, tracer, inv.ID, attribute.String("vm.id", pvm.VM.ID))
+	// Agent 在执行期间按行转发函数输出，写入 logs 表；大输入按引用传递，由 Agent 从对象存储下载
+	var resp *fc.ResponsePayload
+	logSink := functionLogSink(w.scheduler.store, fn, inv, logger)
+	if inputRef := offloadInput(runtimeCtx, w.scheduler.inputs, w.scheduler.inputRefThreshold, inv.Input, logger); inputRef != "" {
+		resp, err = pvm.Client.ExecuteRefWithLogs(runtimeCtx, inv.ID, inputRef, logSink)
+	} else {
+		resp, err = pvm.Client.ExecuteWithLogs(runtimeCtx, inv.ID, inv.Input, logSink)
+	}
+	telemetry.EndSpan(runtimeSpan, err)
 	if err != nil {
 		// 执行失败，处理错误类型
 		span.RecordError(err)
@@ -624,6 +786,12 @@ func (w *worker) process(item *workItem) {
 		inv.Fail(resp.Error)
 	}
 	w.scheduler.store.UpdateInvocation(inv)
+	storeCachedResult(w.scheduler.ctx, w.scheduler.cache, fn, inv, w.scheduler.logger)
+
+	// 异步调用的结果投递到配置的目标
+	if item.resultCh == nil {
+		w.scheduler.destinations.Dispatch(fn, inv)
+	}
 
 	// 记录调用指标
 	if w.scheduler.metrics != nil {
@@ -679,14 +847,24 @@ func (w *worker) process(item *workItem) {
 //   - statusCode: HTTP状态码（500=内部错误，504=超时）
 //   - errorType: 错误类型，用于指标分类
 func (w *worker) fail(item *workItem, errMsg string, statusCode int, errorType string) {
-	// 根据状态码更新调用状态
-	if statusCode == 504 {
+	// 根据状态码更新调用状态，通过取消接口中止的调用不论处于哪个阶段都标记为已取消
+	cancelled := w.scheduler.tracker.Cancelled(item.invocation.ID)
+	switch {
+	case cancelled:
+		item.invocation.Cancel()
+		errMsg, statusCode, errorType = item.invocation.Error, cancelledStatusCode, "cancelled"
+	case statusCode == 504:
 		item.invocation.Timeout() // 超时
-	} else {
+	default:
 		item.invocation.Fail(errMsg) // 其他错误
 	}
 	w.scheduler.store.UpdateInvocation(item.invocation)
 
+	// 异步调用的失败结果投递到 on_failure 目标（默认死信队列），主动取消的调用不投递
+	if item.resultCh == nil && !cancelled {
+		w.scheduler.destinations.Dispatch(item.function, item.invocation)
+	}
+
 	// 记录错误指标
 	if w.scheduler.metrics != nil {
 		w.scheduler.metrics.RecordInvocation(
@@ -716,6 +894,22 @@ func (w *worker) fail(item *workItem, errMsg string, statusCode int, errorType s
 	}
 }
 
+// ListActiveInvocations 返回当前正在执行的调用列表。
+// 数据来自内存中的调用跟踪器，仅包含本调度器实例上的调用。
+//
+// 返回值:
+//   - []domain.ActiveInvocation: 正在执行的调用，按开始时间升序排列
+//   - error: 当前实现始终返回 nil
+func (s *Scheduler) ListActiveInvocations() ([]domain.ActiveInvocation, error) {
+	return s.tracker.List(), nil
+}
+
+// CancelInvocation 取消一个正在执行的调用。
+// 返回 false 表示调用不在本调度器上执行。
+func (s *Scheduler) CancelInvocation(invocationID string) bool {
+	return s.tracker.Cancel(invocationID)
+}
+
 // Stats 返回调度器的当前统计信息。
 // 可用于健康检查和监控。
 //
@@ -0,0 +1,52 @@
+//go:build linux
+// +build linux
+
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/oriys/nimbus/internal/domain"
+	fc "github.com/oriys/nimbus/internal/firecracker"
+)
+
+func TestNewInitPayloadCarriesDepsAndWarmup(t *testing.T) {
+	fn := &domain.Function{
+		ID:                    "fn-1",
+		Runtime:               domain.RuntimePython311,
+		Handler:               "handler.main",
+		Code:                  "current code",
+		CodeHash:              "hash-current",
+		MemoryMB:              256,
+		TimeoutSec:            30,
+		InstallDeps:           true,
+		InstallDepsTimeoutSec: 120,
+		WarmupHandler:         "model.load",
+		WarmupTimeoutSec:      90,
+	}
+	layers := []fc.LayerInfo{{LayerID: "layer-1", Version: 2, ContentHash: "abc"}}
+
+	p := newInitPayload(fn, nil, map[string]string{"A": "1"}, layers)
+	if !p.InstallDeps || p.InstallDepsTimeoutSec != 120 {
+		t.Errorf("install deps = %v/%d, want true/120", p.InstallDeps, p.InstallDepsTimeoutSec)
+	}
+	if p.WarmupHandler != "model.load" || p.WarmupTimeoutSec != 90 {
+		t.Errorf("warmup = %q/%d, want model.load/90", p.WarmupHandler, p.WarmupTimeoutSec)
+	}
+	if p.Code != "current code" || p.CodeHash != "hash-current" || p.Handler != "handler.main" || p.EnvVars["A"] != "1" || len(p.Layers) != 1 {
+		t.Errorf("payload = %+v, want the function's current code, env and layers", p)
+	}
+
+	// 指定版本时使用版本的代码和入口点，依赖安装配置仍来自函数
+	v := newInitPayload(fn, &domain.FunctionVersion{Version: 3, Handler: "v3.main", Code: "v3 code", CodeHash: "hash-v3"}, nil, nil)
+	if v.Code != "v3 code" || v.CodeHash != "hash-v3" || v.Handler != "v3.main" || !v.InstallDeps {
+		t.Errorf("versioned payload = %+v, want version code with install_deps", v)
+	}
+
+	// 关闭依赖安装会改变指纹，已初始化的虚拟机需要重新初始化
+	noDeps := *fn
+	noDeps.InstallDeps = false
+	if newInitPayload(&noDeps, nil, map[string]string{"A": "1"}, layers).Fingerprint() == p.Fingerprint() {
+		t.Error("fingerprint unchanged after install_deps changed")
+	}
+}
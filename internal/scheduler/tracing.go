@@ -0,0 +1,38 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件负责把调用方请求的追踪上下文传递到工作协程，使函数执行的 span 挂在上游请求的链路下。
+package scheduler
+
+import (
+	"context"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName 调度器使用的追踪器名称
+const tracerName = "function-scheduler"
+
+// requestSpanContext 返回调用请求携带的追踪上下文，请求未携带时返回无效的 SpanContext
+func requestSpanContext(req *domain.InvokeRequest) trace.SpanContext {
+	if req.Context == nil {
+		return trace.SpanContext{}
+	}
+	return trace.SpanContextFromContext(req.Context)
+}
+
+// invokeSpanParent 返回启动 function.invoke span 使用的父上下文。
+// 只继承上游的追踪上下文而不继承其取消信号：异步调用在 HTTP 请求返回后才执行，
+// 执行过程的生命周期仍由调度器上下文控制。
+func invokeSpanParent(base context.Context, parent trace.SpanContext) context.Context {
+	if !parent.IsValid() {
+		return base
+	}
+	return trace.ContextWithSpanContext(base, parent)
+}
+
+// startRuntimeSpan 启动覆盖运行时执行函数的 runtime.execute span
+func startRuntimeSpan(ctx context.Context, tracer trace.Tracer, requestID string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append([]attribute.KeyValue{attribute.String("request_id", requestID)}, attrs...)
+	return tracer.Start(ctx, "runtime.execute", trace.WithAttributes(attrs...))
+}
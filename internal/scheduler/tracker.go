@@ -0,0 +1,135 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现了内存中的调用跟踪器，记录正在执行的调用及其取消函数。
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+const (
+	// nearTimeoutRatio 是判定调用"接近超时"的运行时长占比阈值。
+	nearTimeoutRatio = 0.8
+	// cancelledStatusCode 是被取消的同步调用返回的状态码，沿用 nginx 的 499 约定。
+	cancelledStatusCode = 499
+)
+
+// trackedInvocation 是跟踪器中的一条记录。
+type trackedInvocation struct {
+	info      domain.ActiveInvocation // 调用的基本信息
+	cancel    context.CancelFunc      // 取消函数，用于中止正在执行的调用
+	cancelled bool                    // 是否已通过 Cancel 取消
+}
+
+// InvocationTracker 跟踪当前正在执行的函数调用。
+// 调度器在调用开始执行时登记、结束时注销，
+// 登记时一并保存执行上下文的取消函数，以便中途取消调用。
+// 所有方法都是并发安全的。
+type InvocationTracker struct {
+	mu      sync.RWMutex
+	entries map[string]*trackedInvocation
+}
+
+// NewInvocationTracker 创建一个新的调用跟踪器。
+func NewInvocationTracker() *InvocationTracker {
+	return &InvocationTracker{
+		entries: make(map[string]*trackedInvocation),
+	}
+}
+
+// Track 登记一个开始执行的调用。
+//
+// 参数:
+//   - inv: 调用记录，需已调用 Start 设置开始时间和虚拟机ID
+//   - fn: 被调用的函数，用于获取超时配置
+//   - cancel: 执行上下文的取消函数，可为 nil
+func (t *InvocationTracker) Track(inv *domain.Invocation, fn *domain.Function, cancel context.CancelFunc) {
+	startedAt := time.Now()
+	if inv.StartedAt != nil {
+		startedAt = *inv.StartedAt
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries[inv.ID] = &trackedInvocation{
+		info: domain.ActiveInvocation{
+			ID:           inv.ID,
+			FunctionID:   fn.ID,
+			FunctionName: fn.Name,
+			VMID:         inv.VMID,
+			StartedAt:    startedAt,
+			TimeoutSec:   fn.TimeoutSec,
+		},
+		cancel: cancel,
+	}
+}
+
+// Untrack 注销一个已结束的调用。
+func (t *InvocationTracker) Untrack(invocationID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, invocationID)
+}
+
+// Cancel 取消一个正在执行的调用。
+// 返回 false 表示调用不存在（已结束或从未在本节点执行）。
+func (t *InvocationTracker) Cancel(invocationID string) bool {
+	t.mu.Lock()
+	entry, ok := t.entries[invocationID]
+	if ok {
+		entry.cancelled = true
+	}
+	t.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if entry.cancel != nil {
+		entry.cancel()
+	}
+	return true
+}
+
+// Cancelled 返回调用是否已通过 Cancel 取消，调度器据此区分主动取消和执行超时。
+// 需在 Untrack 之前调用。
+func (t *InvocationTracker) Cancelled(invocationID string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	entry, ok := t.entries[invocationID]
+	return ok && entry.cancelled
+}
+
+// List 返回当前所有正在执行的调用，按开始时间升序排列（运行最久的在前）。
+// 每条记录都会计算已运行时长，并标记运行时长超过超时时间 80% 的调用。
+func (t *InvocationTracker) List() []domain.ActiveInvocation {
+	now := time.Now()
+
+	t.mu.RLock()
+	result := make([]domain.ActiveInvocation, 0, len(t.entries))
+	for _, entry := range t.entries {
+		info := entry.info
+		running := now.Sub(info.StartedAt)
+		info.RunningMs = running.Milliseconds()
+		if info.TimeoutSec > 0 {
+			limit := time.Duration(float64(time.Duration(info.TimeoutSec)*time.Second) * nearTimeoutRatio)
+			info.NearTimeout = running >= limit
+		}
+		result = append(result, info)
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.Before(result[j].StartedAt)
+	})
+	return result
+}
+
+// Len 返回当前正在执行的调用数量。
+func (t *InvocationTracker) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.entries)
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+func trackedAt(id string, startedAt time.Time) *domain.Invocation {
+	return &domain.Invocation{ID: id, VMID: "vm-" + id, StartedAt: &startedAt}
+}
+
+func TestInvocationTrackerListsLongestRunningFirst(t *testing.T) {
+	tracker := NewInvocationTracker()
+	fn := &domain.Function{ID: "fn-1", Name: "orders", TimeoutSec: 10}
+	now := time.Now()
+	tracker.Track(trackedAt("recent", now.Add(-time.Second)), fn, nil)
+	tracker.Track(trackedAt("stuck", now.Add(-9*time.Second)), fn, nil)
+
+	active := tracker.List()
+	if len(active) != 2 || tracker.Len() != 2 {
+		t.Fatalf("active = %d, want 2", len(active))
+	}
+	if active[0].ID != "stuck" || active[0].VMID != "vm-stuck" || active[0].FunctionName != "orders" {
+		t.Errorf("first = %+v, want the longest running invocation", active[0])
+	}
+	// 运行时长超过超时时间的 80% 视为接近超时
+	if !active[0].NearTimeout || active[1].NearTimeout {
+		t.Errorf("near timeout = %v/%v, want true/false", active[0].NearTimeout, active[1].NearTimeout)
+	}
+	if active[0].RunningMs < 9000 {
+		t.Errorf("running ms = %d, want at least 9000", active[0].RunningMs)
+	}
+
+	tracker.Untrack("stuck")
+	if active := tracker.List(); len(active) != 1 || active[0].ID != "recent" {
+		t.Errorf("active after untrack = %+v, want only recent", active)
+	}
+}
+
+func TestInvocationTrackerCancel(t *testing.T) {
+	tracker := NewInvocationTracker()
+	fn := &domain.Function{ID: "fn-1", Name: "orders", TimeoutSec: 10}
+	cancelled := 0
+	tracker.Track(trackedAt("inv-1", time.Now()), fn, func() { cancelled++ })
+
+	if tracker.Cancelled("inv-1") {
+		t.Error("invocation reported cancelled before Cancel")
+	}
+	if !tracker.Cancel("inv-1") || cancelled != 1 {
+		t.Fatalf("Cancel = false or cancel func called %d times, want true and 1", cancelled)
+	}
+	if !tracker.Cancelled("inv-1") {
+		t.Error("Cancelled = false after Cancel")
+	}
+
+	// 已结束或从未登记的调用无法取消
+	tracker.Untrack("inv-1")
+	if tracker.Cancel("inv-1") || tracker.Cancel("unknown") || tracker.Cancelled("inv-1") {
+		t.Error("cancelled an invocation that is no longer tracked")
+	}
+	if cancelled != 1 {
+		t.Errorf("cancel func called %d times, want 1", cancelled)
+	}
+}
@@ -0,0 +1,95 @@
+// Package scheduler 提供函数调度器的实现。
+// 本文件实现函数级冷启动预热策略：
+// on_deploy 在函数部署完成后立即预热，scheduled 按 cron 计划预热（如工作时间开始前）。
+// predictive 策略暂未实现，与 none 一样交由预热池按全局配置维护。
+package scheduler
+
+import (
+	"context"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// warmupInstances 每次预热新增的实例数
+const warmupInstances = 1
+
+// Prewarmer 能为函数提前创建预热实例的调度器
+type Prewarmer interface {
+	// Prewarm 为函数创建 count 个预热实例，返回实际创建（或发起创建）的数量
+	Prewarm(fn *domain.Function, count int) (int, error)
+}
+
+// prewarmExecutor 支持预热的执行器（如 Docker 容器管理器）
+type prewarmExecutor interface {
+	Prewarm(ctx context.Context, fn *domain.Function, count int) (int, error)
+}
+
+// SetPrewarmer 设置预热执行者，未设置时忽略所有预热策略。
+// 需在 Start 之前调用，Start 加载函数时会注册 scheduled 预热任务。
+func (cm *CronManager) SetPrewarmer(p Prewarmer) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.prewarmer = p
+}
+
+// OnFunctionDeployed 在函数部署完成后同步定时任务，并按 on_deploy 策略预热
+func (cm *CronManager) OnFunctionDeployed(fn *domain.Function) {
+	cm.AddOrUpdateFunction(fn)
+
+	cm.mu.Lock()
+	p := cm.prewarmer
+	cm.mu.Unlock()
+	if p != nil && fn.WarmupStrategy == domain.WarmupOnDeploy {
+		go cm.warmup(p, fn, "on_deploy")
+	}
+}
+
+// syncWarmup 按函数当前的预热策略注册或移除 scheduled 预热任务。
+// 调用此方法前必须持有 cm.mu 锁
+func (cm *CronManager) syncWarmup(fn *domain.Function) {
+	cm.removeWarmup(fn.ID)
+	if cm.prewarmer == nil || fn.WarmupStrategy != domain.WarmupScheduled ||
+		fn.WarmupSchedule == "" || fn.Status != domain.FunctionStatusActive {
+		return
+	}
+
+	p := cm.prewarmer
+	target := *fn
+	entryID, err := cm.cron.AddFunc(fn.WarmupSchedule, func() {
+		cm.warmup(p, &target, "scheduled")
+	})
+	if err != nil {
+		cm.logger.WithError(err).WithFields(logrus.Fields{
+			"function_id": fn.ID,
+			"schedule":    fn.WarmupSchedule,
+		}).Error("Failed to add warmup schedule")
+		return
+	}
+	cm.warmupEntries[fn.ID] = entryID
+}
+
+// removeWarmup 移除函数的 scheduled 预热任务。
+// 调用此方法前必须持有 cm.mu 锁
+func (cm *CronManager) removeWarmup(functionID string) {
+	if entryID, ok := cm.warmupEntries[functionID]; ok {
+		cm.cron.Remove(entryID)
+		delete(cm.warmupEntries, functionID)
+	}
+}
+
+// warmup 执行一次预热并记录结果
+func (cm *CronManager) warmup(p Prewarmer, fn *domain.Function, reason string) {
+	logger := cm.logger.WithFields(logrus.Fields{
+		"function_id":   fn.ID,
+		"function_name": fn.Name,
+		"runtime":       fn.Runtime,
+		"reason":        reason,
+	})
+	created, err := p.Prewarm(fn, warmupInstances)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to prewarm function")
+		return
+	}
+	logger.WithField("instances", created).Info("Prewarmed function")
+}
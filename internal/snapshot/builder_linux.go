@@ -47,7 +47,8 @@ func (b *FirecrackerBuilder) BuildSnapshot(ctx context.Context, fn *domain.Funct
 	}).Info("Building Firecracker snapshot")
 
 	// 1. 创建临时 VM
-	vm, err := b.machinesMgr.CreateVM(ctx, string(fn.Runtime), int64(fn.MemoryMB), 1)
+	// 限速会随设备状态写入快照，恢复出的虚拟机沿用函数的限速
+	vm, err := b.machinesMgr.CreateVM(ctx, string(fn.Runtime), int64(fn.MemoryMB), 1, functionRateLimits(fn.IOLimits))
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to create temp VM: %w", err)
 	}
@@ -78,6 +79,7 @@ func (b *FirecrackerBuilder) BuildSnapshot(ctx context.Context, fn *domain.Funct
 		FunctionID:    fn.ID,
 		Handler:       fn.Handler,
 		Code:          fn.Code,
+		CodeHash:      fn.CodeHash,
 		Runtime:       string(fn.Runtime),
 		EnvVars:       fn.EnvVars,
 		MemoryLimitMB: fn.MemoryMB,
@@ -124,3 +126,16 @@ func (b *FirecrackerBuilder) BuildSnapshot(ctx context.Context, fn *domain.Funct
 
 	return memInfo.Size(), stateInfo.Size(), nil
 }
+
+// functionRateLimits 将函数的 IO 限速转换为虚拟机限速，未设置时不限速
+func functionRateLimits(limits *domain.IOLimits) fc.RateLimits {
+	if limits == nil {
+		return fc.RateLimits{}
+	}
+	return fc.RateLimits{
+		DiskMBps: limits.DiskMBps,
+		DiskIOPS: limits.DiskIOPS,
+		NetMBps:  limits.NetMBps,
+		NetPPS:   limits.NetPPS,
+	}
+}
@@ -9,15 +9,19 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/oriys/nimbus/internal/config"
 	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/metrics"
 	"github.com/sirupsen/logrus"
 )
 
@@ -29,6 +33,9 @@ const (
 	StatusExpired  = "expired"
 )
 
+// ErrSnapshotNotFound 表示函数版本没有可用的就绪快照
+var ErrSnapshotNotFound = errors.New("no valid snapshot found")
+
 // SnapshotInfo 快照信息
 type SnapshotInfo struct {
 	ID            string    `json:"id"`
@@ -108,6 +115,7 @@ type Manager struct {
 	cfg     config.SnapshotConfig
 	db      DBExecutor
 	builder SnapshotBuilder // 实际的快照构建器（可选）
+	metrics *metrics.Metrics // Prometheus 指标（可选）
 	logger  *logrus.Logger
 
 	// 构建任务队列
@@ -115,11 +123,29 @@ type Manager struct {
 	// 正在构建的快照（防止重复构建）
 	building   map[string]bool
 	buildingMu sync.Mutex
+	// 按内容键加锁（content_key -> *sync.Mutex），同一份快照内容同时只构建一次
+	contentLocks sync.Map
+
+	// 待写入的恢复统计（按快照 ID 聚合），定期批量刷新到数据库
+	pendingStats   map[string]*restoreStats
+	pendingStatsMu sync.Mutex
+	statsDone      chan struct{}
+
+	// 本进程启动以来的快照命中（从快照恢复）和未命中（冷启动）次数
+	restoreHits   atomic.Int64
+	restoreMisses atomic.Int64
 
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
+// restoreStats 单个快照在一个刷新周期内累积的恢复统计
+type restoreStats struct {
+	count      int
+	totalMs    float64
+	lastUsedAt time.Time
+}
+
 // NewManager 创建新的快照管理器
 func NewManager(cfg config.SnapshotConfig, db DBExecutor, logger *logrus.Logger) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -130,6 +156,8 @@ func NewManager(cfg config.SnapshotConfig, db DBExecutor, logger *logrus.Logger)
 		logger:     logger,
 		buildQueue: make(chan *buildTask, 100),
 		building:   make(map[string]bool),
+		pendingStats: make(map[string]*restoreStats),
+		statsDone:  make(chan struct{}),
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -147,6 +175,9 @@ func NewManager(cfg config.SnapshotConfig, db DBExecutor, logger *logrus.Logger)
 	// 启动清理 worker
 	go m.cleanupWorker()
 
+	// 启动恢复统计刷新 worker
+	go m.statsFlushWorker()
+
 	logger.WithFields(logrus.Fields{
 		"snapshot_dir":   cfg.SnapshotDir,
 		"build_workers":  cfg.BuildWorkers,
@@ -162,6 +193,11 @@ func (m *Manager) SetBuilder(builder SnapshotBuilder) {
 	m.builder = builder
 }
 
+// SetMetrics 设置 Prometheus 指标，从快照恢复虚拟机时记录恢复耗时
+func (m *Manager) SetMetrics(collector *metrics.Metrics) {
+	m.metrics = collector
+}
+
 // GetSnapshot 获取函数的有效快照
 func (m *Manager) GetSnapshot(ctx context.Context, fn *domain.Function, version int) (*SnapshotInfo, error) {
 	envVarsHash := m.hashEnvVars(fn.EnvVars)
@@ -194,7 +230,7 @@ func (m *Manager) GetSnapshot(ctx context.Context, fn *domain.Function, version
 		&snap.CreatedAt, &lastUsedAt, &expiresAt)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("no valid snapshot found")
+		return nil, ErrSnapshotNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query snapshot: %w", err)
@@ -314,9 +350,17 @@ func (m *Manager) buildSnapshot(fn *domain.Function, version int) error {
 	snapshotID := uuid.New().String()
 	envVarsHash := m.hashEnvVars(fn.EnvVars)
 
-	// 使用 function_id + code_hash 前 16 字符作为目录名
-	dirName := fmt.Sprintf("%s_%s", fn.ID, fn.CodeHash[:min(16, len(fn.CodeHash))])
-	snapshotPath := filepath.Join(m.cfg.SnapshotDir, dirName)
+	// 快照文件按内容寻址存储：代码、入口点、环境变量、运行时、资源配置和层都相同的函数共享同一个目录
+	layers, err := m.layerDigests(ctx, fn.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load function layers: %w", err)
+	}
+	contentKey := m.contentKey(fn, envVarsHash, layers)
+	snapshotPath := filepath.Join(m.cfg.SnapshotDir, "content", contentKey)
+
+	lock, _ := m.contentLocks.LoadOrStore(contentKey, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
 
 	// 创建快照目录
 	if err := os.MkdirAll(snapshotPath, 0755); err != nil {
@@ -324,10 +368,23 @@ func (m *Manager) buildSnapshot(fn *domain.Function, version int) error {
 	}
 
 	// 创建数据库记录（状态为 building）
-	if err := m.createSnapshotRecord(ctx, snapshotID, fn, version, envVarsHash, snapshotPath); err != nil {
+	if err := m.createSnapshotRecord(ctx, snapshotID, fn, version, envVarsHash, contentKey, snapshotPath); err != nil {
 		return fmt.Errorf("failed to create snapshot record: %w", err)
 	}
 
+	// 已有相同内容的就绪快照时直接引用，不再重复构建
+	if memSize, stateSize, ok := m.findReadyContent(ctx, contentKey); ok {
+		if err := m.updateSnapshotReady(ctx, snapshotID, memSize, stateSize); err != nil {
+			return fmt.Errorf("failed to update snapshot record: %w", err)
+		}
+		m.logger.WithFields(logrus.Fields{
+			"snapshot_id": snapshotID,
+			"function_id": fn.ID,
+			"content_key": contentKey,
+		}).Info("Function snapshot reused existing content")
+		return nil
+	}
+
 	// 保存元数据文件
 	metadata := &SnapshotMetadata{
 		SnapshotID:         snapshotID,
@@ -407,26 +464,19 @@ func (m *Manager) buildSnapshot(fn *domain.Function, version int) error {
 func (m *Manager) InvalidateSnapshots(ctx context.Context, functionID string) error {
 	// 获取所有相关快照
 	query := `
-		SELECT id, snapshot_path FROM function_snapshots
+		SELECT id, content_key, snapshot_path FROM function_snapshots
 		WHERE function_id = $1 AND status = 'ready'`
 
 	rows, err := m.db.QueryContext(ctx, query, functionID)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
+	refs := scanSnapshotRefs(rows)
 
-	for rows.Next() {
-		var id, path string
-		if err := rows.Scan(&id, &path); err != nil {
-			continue
-		}
-
-		// 删除快照文件
-		os.RemoveAll(path)
-
-		// 更新状态
-		m.updateSnapshotStatus(ctx, id, StatusExpired, "Function updated")
+	for _, ref := range refs {
+		// 更新状态后，没有其他函数引用时删除共享的快照文件
+		m.updateSnapshotStatus(ctx, ref.id, StatusExpired, "Function updated")
+		m.releaseContent(ctx, ref.contentKey, ref.path)
 	}
 
 	m.logger.WithField("function_id", functionID).Info("Invalidated all snapshots for function")
@@ -483,26 +533,108 @@ func (m *Manager) ListSnapshots(ctx context.Context, functionID string) ([]*Snap
 
 // DeleteSnapshot 删除指定快照
 func (m *Manager) DeleteSnapshot(ctx context.Context, snapshotID string) error {
-	// 获取快照路径
+	// 获取快照路径和内容键
 	var path string
-	query := `SELECT snapshot_path FROM function_snapshots WHERE id = $1`
-	if err := m.db.QueryRowContext(ctx, query, snapshotID).Scan(&path); err != nil {
+	var contentKey sql.NullString
+	query := `SELECT snapshot_path, content_key FROM function_snapshots WHERE id = $1`
+	if err := m.db.QueryRowContext(ctx, query, snapshotID).Scan(&path, &contentKey); err != nil {
 		return fmt.Errorf("snapshot not found: %w", err)
 	}
 
-	// 删除文件
-	if path != "" {
-		os.RemoveAll(path)
+	if _, err := m.removeSnapshot(ctx, snapshotID, contentKey.String, path); err != nil {
+		return err
 	}
 
+	m.logger.WithField("snapshot_id", snapshotID).Info("Snapshot deleted")
+	return nil
+}
+
+// removeSnapshot 删除快照记录，没有其他记录引用同一内容时一并删除快照文件。
+//
+// 返回值:
+//   - bool: 快照文件是否被删除
+//   - error: 删除记录失败时返回错误
+func (m *Manager) removeSnapshot(ctx context.Context, id, contentKey, path string) (bool, error) {
 	// 删除数据库记录
-	_, err := m.db.ExecContext(ctx, "DELETE FROM function_snapshots WHERE id = $1", snapshotID)
-	if err != nil {
-		return fmt.Errorf("failed to delete snapshot record: %w", err)
+	if _, err := m.db.ExecContext(ctx, "DELETE FROM function_snapshots WHERE id = $1", id); err != nil {
+		return false, fmt.Errorf("failed to delete snapshot record: %w", err)
+	}
+	return m.releaseContent(ctx, contentKey, path), nil
+}
+
+// releaseContent 在快照内容的引用数降为 0 时删除快照文件。
+// 引用数是引用该 content_key 且未过期的快照记录数；
+// 没有 content_key 的旧记录独占自己的目录，直接删除。
+// 查询引用数失败时保留文件，宁可多占磁盘也不删除仍在使用的快照。
+func (m *Manager) releaseContent(ctx context.Context, contentKey, path string) bool {
+	if path == "" {
+		return false
+	}
+	if contentKey != "" {
+		refs, err := m.contentRefCount(ctx, contentKey)
+		if err != nil {
+			m.logger.WithError(err).WithField("content_key", contentKey).Warn("Failed to count snapshot content references")
+			return false
+		}
+		if refs > 0 {
+			return false
+		}
 	}
+	if err := os.RemoveAll(path); err != nil {
+		m.logger.WithError(err).WithField("path", path).Warn("Failed to delete snapshot files")
+		return false
+	}
+	return true
+}
 
-	m.logger.WithField("snapshot_id", snapshotID).Info("Snapshot deleted")
-	return nil
+// contentRefCount 统计引用指定快照内容且未过期的快照记录数
+func (m *Manager) contentRefCount(ctx context.Context, contentKey string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM function_snapshots WHERE content_key = $1 AND status <> 'expired'`
+	err := m.db.QueryRowContext(ctx, query, contentKey).Scan(&count)
+	return count, err
+}
+
+// findReadyContent 查找已就绪且文件仍存在的同内容快照，返回其文件大小
+func (m *Manager) findReadyContent(ctx context.Context, contentKey string) (int64, int64, bool) {
+	query := `
+		SELECT snapshot_path, mem_file_size, state_file_size FROM function_snapshots
+		WHERE content_key = $1 AND status = 'ready'
+		LIMIT 1`
+	var path string
+	var memSize, stateSize int64
+	if err := m.db.QueryRowContext(ctx, query, contentKey).Scan(&path, &memSize, &stateSize); err != nil {
+		return 0, 0, false
+	}
+	if _, err := os.Stat(filepath.Join(path, "mem")); err != nil {
+		return 0, 0, false
+	}
+	return memSize, stateSize, true
+}
+
+// snapshotRef 是快照记录对快照文件的引用
+type snapshotRef struct {
+	id         string
+	contentKey string
+	path       string
+	size       int64
+}
+
+// scanSnapshotRefs 读取 (id, content_key, snapshot_path) 结果行并关闭结果集。
+// 先读完结果集再执行删除，避免占用连接的同时执行写操作。
+func scanSnapshotRefs(rows *sql.Rows) []snapshotRef {
+	defer rows.Close()
+	var refs []snapshotRef
+	for rows.Next() {
+		var ref snapshotRef
+		var contentKey sql.NullString
+		if err := rows.Scan(&ref.id, &contentKey, &ref.path); err != nil {
+			continue
+		}
+		ref.contentKey = contentKey.String
+		refs = append(refs, ref)
+	}
+	return refs
 }
 
 // GetStats 获取快照统计信息
@@ -549,9 +681,27 @@ func (m *Manager) GetStats(ctx context.Context) (*SnapshotStats, error) {
 		WHERE status = 'ready' AND restore_count > 0`
 	m.db.QueryRowContext(ctx, avgQuery).Scan(&stats.AvgRestoreMs)
 
+	// 所有快照的累计恢复次数（已刷新到数据库的部分）
+	m.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(restore_count), 0) FROM function_snapshots`).Scan(&stats.TotalRestores)
+
+	// 命中率基于本进程记录的恢复与冷启动次数
+	stats.RestoreCount = m.restoreHits.Load()
+	stats.MissCount = m.restoreMisses.Load()
+	stats.HitRate = snapshotHitRate(stats.RestoreCount, stats.MissCount)
+
 	return stats, nil
 }
 
+// snapshotHitRate 计算快照命中率：从快照恢复的次数占全部冷启动路径（恢复 + 完整启动）的比例，
+// 没有任何记录时返回 0
+func snapshotHitRate(hits, misses int64) float64 {
+	total := hits + misses
+	if total <= 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
 // SnapshotStats 快照统计信息
 type SnapshotStats struct {
 	TotalSnapshots    int     `json:"total_snapshots"`
@@ -560,6 +710,14 @@ type SnapshotStats struct {
 	FailedSnapshots   int     `json:"failed_snapshots"`
 	TotalSizeBytes    int64   `json:"total_size_bytes"`
 	AvgRestoreMs      float64 `json:"avg_restore_ms"`
+	// TotalRestores 所有快照的累计恢复次数
+	TotalRestores int64 `json:"total_restores"`
+	// RestoreCount 本进程启动以来从快照恢复的次数（命中）
+	RestoreCount int64 `json:"restore_count"`
+	// MissCount 本进程启动以来没有可用快照、完整启动的次数（未命中）
+	MissCount int64 `json:"miss_count"`
+	// HitRate 快照命中率，RestoreCount / (RestoreCount + MissCount)
+	HitRate float64 `json:"hit_rate"`
 }
 
 // cleanupWorker 清理过期快照
@@ -573,6 +731,7 @@ func (m *Manager) cleanupWorker() {
 			return
 		case <-ticker.C:
 			m.cleanupExpiredSnapshots()
+			m.evictOverBudget(context.Background())
 		}
 	}
 }
@@ -582,7 +741,7 @@ func (m *Manager) cleanupExpiredSnapshots() {
 
 	// 查找过期快照
 	query := `
-		SELECT id, snapshot_path FROM function_snapshots
+		SELECT id, content_key, snapshot_path FROM function_snapshots
 		WHERE expires_at < NOW() OR status = 'expired'`
 
 	rows, err := m.db.QueryContext(ctx, query)
@@ -590,22 +749,12 @@ func (m *Manager) cleanupExpiredSnapshots() {
 		m.logger.WithError(err).Error("Failed to query expired snapshots")
 		return
 	}
-	defer rows.Close()
 
 	var cleanedCount int
-	for rows.Next() {
-		var id, path string
-		if err := rows.Scan(&id, &path); err != nil {
+	for _, ref := range scanSnapshotRefs(rows) {
+		if _, err := m.removeSnapshot(ctx, ref.id, ref.contentKey, ref.path); err != nil {
 			continue
 		}
-
-		// 删除快照文件
-		if err := os.RemoveAll(path); err != nil {
-			m.logger.WithError(err).WithField("path", path).Warn("Failed to delete snapshot files")
-		}
-
-		// 删除数据库记录
-		m.db.ExecContext(ctx, "DELETE FROM function_snapshots WHERE id = $1", id)
 		cleanedCount++
 	}
 
@@ -614,15 +763,186 @@ func (m *Manager) cleanupExpiredSnapshots() {
 	}
 }
 
-// UpdateSnapshotStats 更新快照恢复统计（外部调用）
-func (m *Manager) UpdateSnapshotStats(ctx context.Context, snapshotID string, restoreMs float64) {
+// evictOverBudget 在就绪快照总大小超过 MaxTotalSizeBytes 时，
+// 按最近最少使用顺序（从未使用的优先，其次按创建时间）删除快照，直到回到预算以内。
+// 共享同一内容的快照只计算一次大小，最后一个引用被删除时才释放空间。
+// 淘汰前先刷新恢复统计，使 last_used_at 反映最近的使用情况。
+func (m *Manager) evictOverBudget(ctx context.Context) {
+	budget := m.cfg.MaxTotalSizeBytes
+	if budget <= 0 {
+		return
+	}
+	m.FlushSnapshotStats(ctx)
+
 	query := `
-		UPDATE function_snapshots
-		SET restore_count = restore_count + 1,
-		    avg_restore_ms = (avg_restore_ms * restore_count + $1) / (restore_count + 1),
-		    last_used_at = NOW()
-		WHERE id = $2`
-	m.db.ExecContext(ctx, query, restoreMs, snapshotID)
+		SELECT id, content_key, snapshot_path, mem_file_size + state_file_size
+		FROM function_snapshots
+		WHERE status = 'ready'
+		ORDER BY last_used_at NULLS FIRST, created_at`
+
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to query snapshots for eviction")
+		return
+	}
+	var candidates []snapshotRef
+	refs := make(map[string]int) // 内容键 -> 就绪引用数，旧记录以自身 ID 作为内容键
+	var total int64
+	for rows.Next() {
+		var c snapshotRef
+		var contentKey sql.NullString
+		if err := rows.Scan(&c.id, &contentKey, &c.path, &c.size); err != nil {
+			continue
+		}
+		c.contentKey = contentKey.String
+		key := c.contentKey
+		if key == "" {
+			key = c.id
+		}
+		if refs[key] == 0 {
+			total += c.size
+		}
+		refs[key]++
+		candidates = append(candidates, c)
+	}
+	// 先读完结果集再删除，避免占用连接的同时执行写操作
+	rows.Close()
+
+	if total <= budget {
+		return
+	}
+
+	var evicted int
+	var freed int64
+	for _, c := range candidates {
+		if total <= budget {
+			break
+		}
+		if _, err := m.removeSnapshot(ctx, c.id, c.contentKey, c.path); err != nil {
+			m.logger.WithError(err).WithField("snapshot_id", c.id).Warn("Failed to evict snapshot")
+			continue
+		}
+		evicted++
+		key := c.contentKey
+		if key == "" {
+			key = c.id
+		}
+		if refs[key]--; refs[key] == 0 {
+			total -= c.size
+			freed += c.size
+		}
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"evicted":     evicted,
+		"freed_bytes": freed,
+		"total_bytes": total,
+		"budget":      budget,
+	}).Info("Evicted least recently used snapshots over size budget")
+}
+
+// UpdateSnapshotStats 记录一次快照恢复（外部调用）。
+// 统计先在内存中按快照 ID 聚合，由 statsFlushWorker 定期批量写入数据库，
+// 避免热点函数每次恢复都产生一次 UPDATE。
+func (m *Manager) UpdateSnapshotStats(ctx context.Context, snapshotID string, restoreMs float64) {
+	m.pendingStatsMu.Lock()
+	defer m.pendingStatsMu.Unlock()
+
+	st, ok := m.pendingStats[snapshotID]
+	if !ok {
+		st = &restoreStats{}
+		m.pendingStats[snapshotID] = st
+	}
+	st.count++
+	st.totalMs += restoreMs
+	st.lastUsedAt = time.Now()
+	m.restoreHits.Add(1)
+}
+
+// RecordMiss 记录一次快照未命中（外部调用）：函数没有可用快照，只能完整启动 VM。
+// 与 UpdateSnapshotStats 记录的命中次数一起用于计算命中率。
+func (m *Manager) RecordMiss(functionID string) {
+	m.restoreMisses.Add(1)
+	m.logger.WithField("function_id", functionID).Debug("Snapshot miss, cold booting VM")
+}
+
+// statsFlushWorker 定期刷新恢复统计，管理器关闭时退出
+func (m *Manager) statsFlushWorker() {
+	defer close(m.statsDone)
+
+	interval := m.cfg.StatsFlushInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.FlushSnapshotStats(context.Background())
+		}
+	}
+}
+
+// FlushSnapshotStats 将内存中累积的恢复统计批量写入数据库。
+// 每个快照的平均恢复时间按加权方式合并：
+// (avg * count + 本批总耗时) / (count + 本批次数)。
+// 写入失败时统计会合并回缓冲区，等待下次刷新。
+func (m *Manager) FlushSnapshotStats(ctx context.Context) error {
+	m.pendingStatsMu.Lock()
+	if len(m.pendingStats) == 0 {
+		m.pendingStatsMu.Unlock()
+		return nil
+	}
+	batch := m.pendingStats
+	m.pendingStats = make(map[string]*restoreStats)
+	m.pendingStatsMu.Unlock()
+
+	// 使用 VALUES 列表一次性更新所有快照
+	values := make([]string, 0, len(batch))
+	args := make([]interface{}, 0, len(batch)*4)
+	for id, st := range batch {
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d::int, $%d::float8, $%d::timestamptz)", n+1, n+2, n+3, n+4))
+		args = append(args, id, st.count, st.totalMs, st.lastUsedAt)
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE function_snapshots AS s
+		SET avg_restore_ms = (s.avg_restore_ms * s.restore_count + v.total_ms) / (s.restore_count + v.cnt),
+		    restore_count = s.restore_count + v.cnt,
+		    last_used_at = GREATEST(COALESCE(s.last_used_at, v.last_used_at), v.last_used_at)
+		FROM (VALUES %s) AS v(id, cnt, total_ms, last_used_at)
+		WHERE s.id = v.id`, strings.Join(values, ", "))
+
+	if _, err := m.db.ExecContext(ctx, query, args...); err != nil {
+		m.logger.WithError(err).WithField("snapshots", len(batch)).Warn("Failed to flush snapshot stats")
+		m.requeueStats(batch)
+		return err
+	}
+	return nil
+}
+
+// requeueStats 将刷新失败的统计合并回缓冲区
+func (m *Manager) requeueStats(batch map[string]*restoreStats) {
+	m.pendingStatsMu.Lock()
+	defer m.pendingStatsMu.Unlock()
+
+	for id, st := range batch {
+		cur, ok := m.pendingStats[id]
+		if !ok {
+			m.pendingStats[id] = st
+			continue
+		}
+		cur.count += st.count
+		cur.totalMs += st.totalMs
+		if st.lastUsedAt.After(cur.lastUsedAt) {
+			cur.lastUsedAt = st.lastUsedAt
+		}
+	}
 }
 
 // 辅助方法
@@ -636,15 +956,64 @@ func (m *Manager) hashEnvVars(envVars map[string]string) string {
 	return hex.EncodeToString(hash[:])[:16]
 }
 
-func (m *Manager) createSnapshotRecord(ctx context.Context, id string, fn *domain.Function, version int, envVarsHash, path string) error {
+// contentKey 计算快照内容键：快照保存的是函数初始化后的内存和磁盘，内容取决于代码、入口点、
+// 环境变量、运行时、内存和超时配置、是否安装依赖以及按加载顺序排列的层内容，
+// 这些都相同的函数可以共享同一份快照文件
+func (m *Manager) contentKey(fn *domain.Function, envVarsHash string, layerDigests []string) string {
+	data := fmt.Sprintf("%s|%s|%s|%d|%s|%d|%t|%s", fn.CodeHash, envVarsHash, fn.Runtime, fn.MemoryMB,
+		fn.Handler, fn.TimeoutSec, fn.InstallDeps, strings.Join(layerDigests, ","))
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])[:32]
+}
+
+// layerDigests 返回函数关联层的内容哈希，按加载顺序排列；没有记录哈希的层版本用层 ID 和版本号代替
+func (m *Manager) layerDigests(ctx context.Context, functionID string) ([]string, error) {
+	query := `
+		SELECT COALESCE(lv.content_hash, fl.layer_id || ':' || fl.layer_version)
+		FROM function_layers fl
+		LEFT JOIN layer_versions lv ON lv.layer_id = fl.layer_id AND lv.version = fl.layer_version
+		WHERE fl.function_id = $1
+		ORDER BY fl.order_index
+	`
+	rows, err := m.db.QueryContext(ctx, query, functionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var digests []string
+	for rows.Next() {
+		var digest string
+		if err := rows.Scan(&digest); err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+	}
+	return digests, rows.Err()
+}
+
+// snapshotTTL 返回函数快照的保留时长：优先使用函数的 snapshot_ttl_hours，
+// 未设置时使用全局 snapshot_ttl，两者都未设置时为 7 天
+func (m *Manager) snapshotTTL(fn *domain.Function) time.Duration {
+	if fn.SnapshotTTLHours > 0 {
+		return time.Duration(fn.SnapshotTTLHours) * time.Hour
+	}
+	if m.cfg.SnapshotTTL > 0 {
+		return m.cfg.SnapshotTTL
+	}
+	return 7 * 24 * time.Hour
+}
+
+func (m *Manager) createSnapshotRecord(ctx context.Context, id string, fn *domain.Function, version int, envVarsHash, contentKey, path string) error {
 	query := `
 		INSERT INTO function_snapshots
-		(id, function_id, version, code_hash, runtime, memory_mb, env_vars_hash, snapshot_path, status, created_at, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'building', NOW(), NOW() + INTERVAL '7 days')
+		(id, function_id, version, code_hash, runtime, memory_mb, env_vars_hash, snapshot_path, content_key, status, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 'building', NOW(), $10)
 		ON CONFLICT (function_id, version, code_hash, env_vars_hash) DO UPDATE
-		SET status = 'building', snapshot_path = $8, created_at = NOW(), expires_at = NOW() + INTERVAL '7 days'`
+		SET status = 'building', snapshot_path = $8, content_key = $9, created_at = NOW(), expires_at = $10`
 
-	_, err := m.db.ExecContext(ctx, query, id, fn.ID, version, fn.CodeHash, fn.Runtime, fn.MemoryMB, envVarsHash, path)
+	expiresAt := time.Now().Add(m.snapshotTTL(fn))
+	_, err := m.db.ExecContext(ctx, query, id, fn.ID, version, fn.CodeHash, fn.Runtime, fn.MemoryMB, envVarsHash, path, contentKey, expiresAt)
 	return err
 }
 
@@ -670,6 +1039,11 @@ func (m *Manager) markSnapshotExpired(ctx context.Context, id string) {
 // Shutdown 关闭管理器
 func (m *Manager) Shutdown() {
 	m.cancel()
+	// 等待刷新 worker 退出后，写入剩余的恢复统计
+	<-m.statsDone
+	if err := m.FlushSnapshotStats(context.Background()); err != nil {
+		m.logger.WithError(err).Error("Failed to flush snapshot stats on shutdown")
+	}
 	m.logger.Info("Snapshot manager shutdown")
 }
 
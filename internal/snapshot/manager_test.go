@@ -0,0 +1,461 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestManager(t *testing.T, db DBExecutor) *Manager {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.SnapshotConfig{
+		SnapshotDir:        t.TempDir(),
+		CleanupInterval:    time.Hour,
+		StatsFlushInterval: time.Hour,
+	}
+	return NewManager(cfg, db, logger)
+}
+
+// statsArgs 将一次批量刷新的参数按快照 ID 整理
+func statsArgs(call sqltest.Call) map[string][2]driver.Value {
+	result := make(map[string][2]driver.Value)
+	for i := 0; i+3 < len(call.Args); i += 4 {
+		result[call.Args[i].(string)] = [2]driver.Value{call.Args[i+1], call.Args[i+2]}
+	}
+	return result
+}
+
+// deletedIDs 返回按顺序删除的快照记录 ID
+func deletedIDs(db *sqltest.DB) []string {
+	var ids []string
+	for _, c := range db.Find("DELETE FROM function_snapshots WHERE id = $1") {
+		ids = append(ids, c.Args[0].(string))
+	}
+	return ids
+}
+
+// insertedSnapshot 返回为指定函数写入的快照记录，
+// 列清单中 status 和 created_at 是字面量，参数按位置读取
+func insertedSnapshot(t *testing.T, db *sqltest.DB, functionID string) (id, path, contentKey string, expiresAt time.Time) {
+	t.Helper()
+	for _, c := range db.Find("INSERT INTO function_snapshots") {
+		if c.Args[1] == functionID {
+			return c.Args[0].(string), c.Args[7].(string), c.Args[8].(string), c.Args[9].(time.Time)
+		}
+	}
+	t.Fatalf("no snapshot inserted for %s", functionID)
+	return "", "", "", time.Time{}
+}
+
+const (
+	statsFlushQuery = "UPDATE function_snapshots AS s"
+	evictionQuery   = "ORDER BY last_used_at NULLS FIRST, created_at"
+	readyContent    = "WHERE content_key = $1 AND status = 'ready'"
+	contentRefs     = "SELECT COUNT(*) FROM function_snapshots WHERE content_key = $1"
+	snapshotLookup  = "SELECT snapshot_path, content_key FROM function_snapshots WHERE id = $1"
+)
+
+var evictionColumns = []string{"id", "content_key", "snapshot_path", "size"}
+
+func TestUpdateSnapshotStatsBatchesConcurrentRestores(t *testing.T) {
+	db := sqltest.New()
+	m := newTestManager(t, db.Open(t))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.UpdateSnapshotStats(context.Background(), "snap-a", 10)
+		}()
+		go func() {
+			defer wg.Done()
+			m.UpdateSnapshotStats(context.Background(), "snap-b", 4)
+		}()
+	}
+	wg.Wait()
+
+	if err := m.FlushSnapshotStats(context.Background()); err != nil {
+		t.Fatalf("FlushSnapshotStats() error = %v", err)
+	}
+	flushes := db.Find(statsFlushQuery)
+	if len(flushes) != 1 {
+		t.Fatalf("expected 1 batched exec, got %d", len(flushes))
+	}
+	if !strings.Contains(flushes[0].Query, "FROM (VALUES ($1, $2::int, $3::float8, $4::timestamptz)") {
+		t.Errorf("flush query = %q, want typed VALUES list", flushes[0].Query)
+	}
+
+	got := statsArgs(flushes[0])
+	if got["snap-a"] != [2]driver.Value{int64(50), 500.0} {
+		t.Errorf("snap-a stats = %v, want [50 500]", got["snap-a"])
+	}
+	if got["snap-b"] != [2]driver.Value{int64(50), 200.0} {
+		t.Errorf("snap-b stats = %v, want [50 200]", got["snap-b"])
+	}
+
+	// 缓冲区已清空，再次刷新不应写库
+	if err := m.FlushSnapshotStats(context.Background()); err != nil {
+		t.Fatalf("FlushSnapshotStats() error = %v", err)
+	}
+	if n := len(db.Find(statsFlushQuery)); n != 1 {
+		t.Errorf("expected no exec for empty buffer, got %d", n)
+	}
+
+	m.Shutdown()
+}
+
+func TestFlushSnapshotStatsRequeuesOnFailure(t *testing.T) {
+	db := sqltest.New().On(statsFlushQuery, sqltest.Result{Err: errors.New("db down")}, sqltest.Affected(1))
+	m := newTestManager(t, db.Open(t))
+
+	m.UpdateSnapshotStats(context.Background(), "snap-a", 10)
+	if err := m.FlushSnapshotStats(context.Background()); err == nil {
+		t.Fatal("expected flush error")
+	}
+
+	// 数据库恢复后，失败批次与新统计合并写入
+	m.UpdateSnapshotStats(context.Background(), "snap-a", 20)
+
+	// Shutdown 会写入剩余统计
+	m.Shutdown()
+
+	flushes := db.Find(statsFlushQuery)
+	if len(flushes) != 2 {
+		t.Fatalf("expected failed and recovered flushes, got %d", len(flushes))
+	}
+	got := statsArgs(flushes[1])
+	if got["snap-a"] != [2]driver.Value{int64(2), 30.0} {
+		t.Errorf("snap-a stats = %v, want [2 30]", got["snap-a"])
+	}
+}
+
+func TestEvictOverBudgetRemovesLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	snapshotPath := func(id string) string {
+		path := filepath.Join(dir, id)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// 行已按 last_used_at NULLS FIRST, created_at 排序：总计 1000 字节，预算 500 字节
+	db := sqltest.New().On(evictionQuery, sqltest.Rows(evictionColumns,
+		[]driver.Value{"snap-never-used", nil, snapshotPath("snap-never-used"), int64(400)},
+		[]driver.Value{"snap-stale", nil, snapshotPath("snap-stale"), int64(300)},
+		[]driver.Value{"snap-recent", nil, snapshotPath("snap-recent"), int64(300)},
+	))
+	m := newTestManager(t, db.Open(t))
+	m.cfg.MaxTotalSizeBytes = 500
+	defer m.Shutdown()
+
+	m.evictOverBudget(context.Background())
+
+	if q := db.Find(evictionQuery); len(q) != 1 {
+		t.Errorf("eviction queries = %q, want one query with LRU ordering", db.Queries())
+	}
+	if deleted := deletedIDs(db); strings.Join(deleted, ",") != "snap-never-used,snap-stale" {
+		t.Errorf("evicted %v, want [snap-never-used snap-stale]", deleted)
+	}
+	for id, wantExists := range map[string]bool{"snap-never-used": false, "snap-stale": false, "snap-recent": true} {
+		if _, err := os.Stat(filepath.Join(dir, id)); (err == nil) != wantExists {
+			t.Errorf("snapshot dir %s exists = %v, want %v", id, err == nil, wantExists)
+		}
+	}
+}
+
+func TestEvictOverBudgetWithinBudget(t *testing.T) {
+	db := sqltest.New().On(evictionQuery, sqltest.Rows(evictionColumns,
+		[]driver.Value{"snap-a", nil, "", int64(200)},
+		[]driver.Value{"snap-b", nil, "", int64(300)},
+	))
+	m := newTestManager(t, db.Open(t))
+	m.cfg.MaxTotalSizeBytes = 500
+	defer m.Shutdown()
+
+	m.evictOverBudget(context.Background())
+	if deleted := deletedIDs(db); len(deleted) != 0 {
+		t.Errorf("expected no eviction within budget, got %v", deleted)
+	}
+
+	// 未配置预算时不查询
+	m.cfg.MaxTotalSizeBytes = 0
+	m.evictOverBudget(context.Background())
+	if n := len(db.Find(evictionQuery)); n != 1 {
+		t.Errorf("expected no query without budget, got %d queries", n)
+	}
+}
+
+func TestEvictOverBudgetCountsSharedContentOnce(t *testing.T) {
+	shared := t.TempDir()
+
+	// 两条记录共享 400 字节的内容，只计一次：总计 700 字节，预算 500 字节
+	db := sqltest.New().
+		On(evictionQuery, sqltest.Rows(evictionColumns,
+			[]driver.Value{"snap-a", "content-1", shared, int64(400)},
+			[]driver.Value{"snap-b", "content-1", shared, int64(400)},
+			[]driver.Value{"snap-c", "content-2", t.TempDir(), int64(300)},
+		)).
+		On(contentRefs, sqltest.Rows([]string{"count"}, []driver.Value{int64(1)}), sqltest.Rows([]string{"count"}, []driver.Value{int64(0)}))
+	m := newTestManager(t, db.Open(t))
+	m.cfg.MaxTotalSizeBytes = 500
+	defer m.Shutdown()
+
+	m.evictOverBudget(context.Background())
+
+	if deleted := deletedIDs(db); strings.Join(deleted, ",") != "snap-a,snap-b" {
+		t.Errorf("evicted %v, want [snap-a snap-b]", deleted)
+	}
+	refs := db.Find(contentRefs)
+	if len(refs) != 2 || refs[0].Args[0] != "content-1" || refs[1].Args[0] != "content-1" {
+		t.Fatalf("reference count queries = %v, want two for content-1", refs)
+	}
+	// 删除 snap-a 后内容仍被 snap-b 引用，删除 snap-b 后才清理文件
+	if _, err := os.Stat(shared); !os.IsNotExist(err) {
+		t.Errorf("shared content still exists after last reference evicted: %v", err)
+	}
+}
+
+func TestEvictOverBudgetKeepsContentWhenRefCountFails(t *testing.T) {
+	shared := t.TempDir()
+	db := sqltest.New().
+		On(evictionQuery, sqltest.Rows(evictionColumns,
+			[]driver.Value{"snap-a", "content-1", shared, int64(400)},
+			[]driver.Value{"snap-b", "content-2", t.TempDir(), int64(300)},
+		)).
+		On(contentRefs, sqltest.Result{Err: errors.New("db down")})
+	m := newTestManager(t, db.Open(t))
+	m.cfg.MaxTotalSizeBytes = 500
+	defer m.Shutdown()
+
+	m.evictOverBudget(context.Background())
+
+	if deleted := deletedIDs(db); strings.Join(deleted, ",") != "snap-a" {
+		t.Errorf("evicted %v, want [snap-a]", deleted)
+	}
+	if _, err := os.Stat(shared); err != nil {
+		t.Errorf("content removed without a reference count: %v", err)
+	}
+}
+
+func TestSharedSnapshotContentRefCount(t *testing.T) {
+	db := sqltest.New()
+	m := newTestManager(t, db.Open(t))
+	m.cfg.BuildTimeout = time.Minute
+	defer m.Shutdown()
+
+	fnA := &domain.Function{ID: "fn-a", CodeHash: "hash-1", Runtime: domain.RuntimePython311, MemoryMB: 128, EnvVars: map[string]string{"K": "V"}}
+	fnB := &domain.Function{ID: "fn-b", CodeHash: "hash-1", Runtime: domain.RuntimePython311, MemoryMB: 128, EnvVars: map[string]string{"K": "V"}}
+
+	// 尚无同内容的就绪快照，fn-a 写入快照文件
+	if err := m.buildSnapshot(fnA, 1); err != nil {
+		t.Fatalf("build fn-a: %v", err)
+	}
+	idA, pathA, keyA, _ := insertedSnapshot(t, db, "fn-a")
+	readyA := db.Find("SET status = 'ready'")
+	if len(readyA) != 1 || readyA[0].Args[2] != idA {
+		t.Fatalf("ready updates = %v, want one for %s", readyA, idA)
+	}
+	memSize, stateSize := readyA[0].Args[0], readyA[0].Args[1]
+
+	// 构建 fn-b 时复用 fn-a 的快照内容，不再写入文件
+	if err := os.WriteFile(filepath.Join(pathA, "mem"), []byte("built-by-fn-a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db.On(readyContent, sqltest.Rows([]string{"snapshot_path", "mem_file_size", "state_file_size"},
+		[]driver.Value{pathA, memSize, stateSize}))
+	if err := m.buildSnapshot(fnB, 1); err != nil {
+		t.Fatalf("build fn-b: %v", err)
+	}
+	idB, pathB, keyB, _ := insertedSnapshot(t, db, "fn-b")
+
+	if keyA == "" || keyA != keyB || pathA != pathB {
+		t.Fatalf("content not shared: a=(%s %s) b=(%s %s)", keyA, pathA, keyB, pathB)
+	}
+	lookups := db.Find(readyContent)
+	if len(lookups) != 2 || lookups[1].Args[0] != keyA {
+		t.Errorf("ready content lookups = %v, want fn-b to look up %s", lookups, keyA)
+	}
+	readyB := db.Find("SET status = 'ready'")
+	if len(readyB) != 2 || readyB[1].Args[0] != memSize || readyB[1].Args[1] != stateSize || readyB[1].Args[2] != idB {
+		t.Errorf("fn-b ready update = %v, want fn-a sizes for %s", readyB, idB)
+	}
+	if data, _ := os.ReadFile(filepath.Join(pathA, "mem")); string(data) != "built-by-fn-a" {
+		t.Errorf("shared mem file was rebuilt: %q", data)
+	}
+
+	// 删除 fn-a 的快照后内容仍被 fn-b 引用，最后一个引用删除后文件被清理
+	db.On(snapshotLookup, sqltest.Rows([]string{"snapshot_path", "content_key"}, []driver.Value{pathA, keyA})).
+		On(contentRefs, sqltest.Rows([]string{"count"}, []driver.Value{int64(1)}), sqltest.Rows([]string{"count"}, []driver.Value{int64(0)}))
+	if err := m.DeleteSnapshot(context.Background(), idA); err != nil {
+		t.Fatalf("delete fn-a snapshot: %v", err)
+	}
+	if _, err := os.Stat(pathA); err != nil {
+		t.Fatalf("shared content removed while still referenced: %v", err)
+	}
+	if err := m.DeleteSnapshot(context.Background(), idB); err != nil {
+		t.Fatalf("delete fn-b snapshot: %v", err)
+	}
+	if _, err := os.Stat(pathB); !os.IsNotExist(err) {
+		t.Errorf("shared content still exists after last reference deleted: %v", err)
+	}
+	if deleted := deletedIDs(db); strings.Join(deleted, ",") != idA+","+idB {
+		t.Errorf("deleted %v, want [%s %s]", deleted, idA, idB)
+	}
+	for _, c := range db.Find(contentRefs) {
+		if c.Args[0] != keyA {
+			t.Errorf("reference count for %v, want %s", c.Args[0], keyA)
+		}
+	}
+}
+
+func TestSnapshotContentKeyDiffersByMemory(t *testing.T) {
+	m := &Manager{}
+	fn := &domain.Function{CodeHash: "hash-1", Runtime: domain.RuntimePython311, MemoryMB: 128, Handler: "handler.main", TimeoutSec: 30}
+	layers := []string{"layer-hash-1"}
+	key := m.contentKey(fn, "env", layers)
+
+	bigger := *fn
+	bigger.MemoryMB = 256
+	if m.contentKey(&bigger, "env", layers) == key {
+		t.Error("content key should differ when memory differs")
+	}
+	if m.contentKey(fn, "other-env", layers) == key {
+		t.Error("content key should differ when env vars differ")
+	}
+	if other := *fn; m.contentKey(&other, "env", []string{"layer-hash-1"}) != key {
+		t.Error("content key should be stable for identical content")
+	}
+
+	// 入口点、超时、依赖安装和层都会改变初始化后的虚拟机状态
+	variants := map[string]func(f *domain.Function){
+		"handler":      func(f *domain.Function) { f.Handler = "handler.other" },
+		"timeout":      func(f *domain.Function) { f.TimeoutSec = 60 },
+		"install_deps": func(f *domain.Function) { f.InstallDeps = true },
+	}
+	for name, mutate := range variants {
+		other := *fn
+		mutate(&other)
+		if m.contentKey(&other, "env", layers) == key {
+			t.Errorf("content key should differ when %s differs", name)
+		}
+	}
+	if m.contentKey(fn, "env", []string{"layer-hash-2"}) == key {
+		t.Error("content key should differ when layer content differs")
+	}
+	if m.contentKey(fn, "env", nil) == key {
+		t.Error("content key should differ when layers are removed")
+	}
+}
+
+func TestSnapshotContentNotSharedAcrossHandlersOrLayers(t *testing.T) {
+	// 依次构建 fn-a、fn-b、fn-c，只有 fn-c 挂载了层
+	digests := []string{"digest"}
+	db := sqltest.New().On("FROM function_layers",
+		sqltest.Rows(digests), sqltest.Rows(digests), sqltest.Rows(digests, []driver.Value{"layer-hash-1"}))
+	m := newTestManager(t, db.Open(t))
+	m.cfg.BuildTimeout = time.Minute
+	defer m.Shutdown()
+
+	base := domain.Function{CodeHash: "hash-1", Runtime: domain.RuntimePython311, MemoryMB: 128, Handler: "handler.main"}
+	fnA, fnB, fnC := base, base, base
+	fnA.ID, fnB.ID, fnC.ID = "fn-a", "fn-b", "fn-c"
+	fnB.Handler = "handler.other"
+
+	for _, fn := range []*domain.Function{&fnA, &fnB, &fnC} {
+		if err := m.buildSnapshot(fn, 1); err != nil {
+			t.Fatalf("build %s: %v", fn.ID, err)
+		}
+	}
+	layerQueries := db.Find("FROM function_layers")
+	if len(layerQueries) != 3 || layerQueries[2].Args[0] != "fn-c" {
+		t.Fatalf("layer queries = %v, want one per function", layerQueries)
+	}
+	_, pathA, keyA, _ := insertedSnapshot(t, db, "fn-a")
+	_, pathB, keyB, _ := insertedSnapshot(t, db, "fn-b")
+	_, pathC, keyC, _ := insertedSnapshot(t, db, "fn-c")
+	if keyA == keyB || pathA == pathB {
+		t.Error("functions with different handlers share snapshot content")
+	}
+	if keyA == keyC || pathA == pathC {
+		t.Error("functions with different layers share snapshot content")
+	}
+}
+
+func TestSnapshotTTLPerFunction(t *testing.T) {
+	db := sqltest.New()
+	m := newTestManager(t, db.Open(t))
+	m.cfg.BuildTimeout = time.Minute
+	m.cfg.SnapshotTTL = 168 * time.Hour
+	defer m.Shutdown()
+
+	short := &domain.Function{ID: "fn-short", CodeHash: "hash-1", Runtime: domain.RuntimePython311, MemoryMB: 128, SnapshotTTLHours: 1}
+	fallback := &domain.Function{ID: "fn-default", CodeHash: "hash-2", Runtime: domain.RuntimePython311, MemoryMB: 128}
+	for _, fn := range []*domain.Function{short, fallback} {
+		if err := m.buildSnapshot(fn, 1); err != nil {
+			t.Fatalf("build %s: %v", fn.ID, err)
+		}
+	}
+
+	for id, want := range map[string]time.Duration{"fn-short": time.Hour, "fn-default": 168 * time.Hour} {
+		_, _, _, expiresAt := insertedSnapshot(t, db, id)
+		if got := time.Until(expiresAt); got < want-time.Minute || got > want {
+			t.Errorf("%s expires in %v, want ~%v", id, got, want)
+		}
+	}
+}
+
+func TestSnapshotHitRate(t *testing.T) {
+	tests := []struct {
+		hits, misses int64
+		want         float64
+	}{
+		{0, 0, 0},
+		{3, 0, 1},
+		{0, 4, 0},
+		{3, 1, 0.75},
+		{1, 2, 1.0 / 3},
+	}
+	for _, tt := range tests {
+		if got := snapshotHitRate(tt.hits, tt.misses); got != tt.want {
+			t.Errorf("snapshotHitRate(%d, %d) = %v, want %v", tt.hits, tt.misses, got, tt.want)
+		}
+	}
+}
+
+func TestGetStatsIncludesHitRate(t *testing.T) {
+	db := sqltest.New().On("GROUP BY status",
+		sqltest.Rows([]string{"status", "count", "size"}, []driver.Value{"ready", int64(2), int64(100)}))
+	m := newTestManager(t, db.Open(t))
+	defer m.Shutdown()
+
+	m.UpdateSnapshotStats(context.Background(), "snap-a", 10)
+	m.UpdateSnapshotStats(context.Background(), "snap-a", 20)
+	m.UpdateSnapshotStats(context.Background(), "snap-b", 30)
+	m.RecordMiss("fn-cold")
+
+	stats, err := m.GetStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.ReadySnapshots != 2 || stats.RestoreCount != 3 || stats.MissCount != 1 {
+		t.Errorf("stats = %+v, want 2 ready, 3 restores, 1 miss", stats)
+	}
+	if stats.HitRate != 0.75 {
+		t.Errorf("hit rate = %v, want 0.75", stats.HitRate)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build linux
+// +build linux
+
+package snapshot
+
+import (
+	"context"
+
+	fc "github.com/oriys/nimbus/internal/firecracker"
+)
+
+// RestoreSnapshot 从函数快照恢复虚拟机，并将实测的恢复耗时计入该快照的恢复统计。
+// 恢复出的虚拟机由 MachineManager 管理，调用方用完后通过 StopVM 释放。
+//
+// 参数:
+//   - ctx: 上下文
+//   - machinesMgr: Firecracker 虚拟机管理器
+//   - snap: 已就绪的快照（通常来自 GetSnapshot）
+//
+// 返回值:
+//   - *fc.VM: 恢复并运行中的虚拟机
+//   - error: 快照文件缺失或恢复失败时返回错误
+func (m *Manager) RestoreSnapshot(ctx context.Context, machinesMgr *fc.MachineManager, snap *SnapshotInfo) (*fc.VM, error) {
+	vm, err := machinesMgr.RestoreVM(ctx, snap.SnapshotPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if vm.Runtime == "" {
+		vm.Runtime = snap.Runtime
+	}
+	restoreMs := float64(vm.RestoreDuration.Microseconds()) / 1000
+	m.UpdateSnapshotStats(ctx, snap.ID, restoreMs)
+	if m.metrics != nil {
+		m.metrics.RecordSnapshotRestore(vm.Runtime, restoreMs)
+	}
+	return vm, nil
+}
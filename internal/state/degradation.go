@@ -0,0 +1,347 @@
+// Package state 提供有状态函数的状态管理功能。
+// 本文件实现了 Redis 不可用时的降级策略：
+// 读操作返回默认值，写操作按配置拒绝或缓冲到本地有界队列，待 Redis 恢复后重放。
+package state
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrBackendUnavailable 表示状态存储后端（Redis）不可用
+var ErrBackendUnavailable = errors.New("state backend unavailable")
+
+// 状态操作错误码，函数代码可据此区分"后端不可用"和"操作本身出错"
+const (
+	// ErrCodeBackendUnavailable 后端不可用，操作未执行
+	ErrCodeBackendUnavailable = "backend_unavailable"
+	// ErrCodeOperation 操作错误（参数无效、版本冲突、类型错误等）
+	ErrCodeOperation = "operation_error"
+)
+
+// DegradationMode 表示 Redis 不可用时写操作的处理方式
+type DegradationMode string
+
+const (
+	// DegradationReject 直接拒绝写操作，返回 backend_unavailable 错误
+	DegradationReject DegradationMode = "reject"
+	// DegradationBuffer 将写操作缓冲到本地有界队列，Redis 恢复后按序重放
+	DegradationBuffer DegradationMode = "buffer"
+)
+
+// 降级配置默认值
+const (
+	defaultWriteBufferSize     = 1000
+	defaultHealthCheckInterval = 5 * time.Second
+	healthCheckTimeout         = 2 * time.Second
+)
+
+// DegradationConfig 降级配置
+type DegradationConfig struct {
+	// Mode 写操作降级方式，默认 reject
+	Mode DegradationMode
+	// WriteBufferSize buffer 模式下本地写缓冲的最大条数
+	WriteBufferSize int
+	// HealthCheckInterval Redis 健康检查间隔
+	HealthCheckInterval time.Duration
+}
+
+// BackendHealth 状态存储后端的健康状况
+type BackendHealth struct {
+	Available        bool            `json:"available"`
+	Mode             DegradationMode `json:"mode"`
+	BufferedWrites   int             `json:"buffered_writes"`
+	DroppedWrites    int64           `json:"dropped_writes"`
+	LastError        string          `json:"last_error,omitempty"`
+	LastCheckedAt    *time.Time      `json:"last_checked_at,omitempty"`
+	UnavailableSince *time.Time      `json:"unavailable_since,omitempty"`
+}
+
+// bufferedWrite 缓冲中的一条写操作
+type bufferedWrite struct {
+	key string
+	req StateRequest
+}
+
+// degradation 维护后端可用状态和本地写缓冲
+type degradation struct {
+	cfg DegradationConfig
+
+	unavailable atomic.Bool
+	dropped     atomic.Int64
+	probing     atomic.Bool // 是否有健康检查正在进行，避免降级期间的请求重复触发探测
+
+	mu               sync.Mutex
+	buffer           []bufferedWrite
+	lastErr          string
+	lastCheckedAt    *time.Time
+	unavailableSince *time.Time
+}
+
+// newDegradation 创建降级状态，补齐配置默认值
+func newDegradation(cfg DegradationConfig) *degradation {
+	if cfg.Mode != DegradationBuffer {
+		cfg.Mode = DegradationReject
+	}
+	if cfg.WriteBufferSize <= 0 {
+		cfg.WriteBufferSize = defaultWriteBufferSize
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	return &degradation{cfg: cfg}
+}
+
+// markUnavailable 标记后端不可用
+func (d *degradation) markUnavailable(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.lastErr = err.Error()
+	}
+	if !d.unavailable.Swap(true) {
+		now := time.Now()
+		d.unavailableSince = &now
+	}
+}
+
+// markAvailable 标记后端恢复
+func (d *degradation) markAvailable() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.unavailable.Store(false)
+	d.unavailableSince = nil
+	d.lastErr = ""
+}
+
+// enqueue 缓冲一条写操作，缓冲区已满时返回 false
+func (d *degradation) enqueue(key string, req *StateRequest) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.buffer) >= d.cfg.WriteBufferSize {
+		d.dropped.Add(1)
+		return false
+	}
+	d.buffer = append(d.buffer, bufferedWrite{key: key, req: *req})
+	return true
+}
+
+// drain 取出全部缓冲的写操作
+func (d *degradation) drain() []bufferedWrite {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pending := d.buffer
+	d.buffer = nil
+	return pending
+}
+
+// requeueFront 将未重放完的写操作放回缓冲区头部，保持原有顺序
+func (d *degradation) requeueFront(pending []bufferedWrite) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.buffer = append(pending, d.buffer...)
+	if overflow := len(d.buffer) - d.cfg.WriteBufferSize; overflow > 0 {
+		d.buffer = d.buffer[:d.cfg.WriteBufferSize]
+		d.dropped.Add(int64(overflow))
+	}
+}
+
+// health 返回当前健康状况快照
+func (d *degradation) health() *BackendHealth {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &BackendHealth{
+		Available:        !d.unavailable.Load(),
+		Mode:             d.cfg.Mode,
+		BufferedWrites:   len(d.buffer),
+		DroppedWrites:    d.dropped.Load(),
+		LastError:        d.lastErr,
+		LastCheckedAt:    d.lastCheckedAt,
+		UnavailableSince: d.unavailableSince,
+	}
+}
+
+// isBackendUnavailable 判断错误是否表示 Redis 连接层面不可用（而非命令执行错误）
+func isBackendUnavailable(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return false
+	}
+	if errors.Is(err, redis.ErrClosed) || errors.Is(err, redis.ErrPoolTimeout) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isWriteOperation 判断操作是否为写操作
+func isWriteOperation(op string) bool {
+	switch op {
+	case "set", "set_with_version", "delete", "incr", "expire":
+		return true
+	}
+	return false
+}
+
+// isBufferable 判断写操作能否缓冲后重放。
+// incr 和 set_with_version 的结果依赖当前值，无法在不可用期间给出正确返回，只能拒绝。
+func isBufferable(op string) bool {
+	switch op {
+	case "set", "delete", "expire":
+		return true
+	}
+	return false
+}
+
+// ConfigureDegradation 设置 Redis 不可用时的降级策略。
+// 需在 Start 之前调用。
+func (h *Handler) ConfigureDegradation(cfg DegradationConfig) {
+	h.degradation = newDegradation(cfg)
+}
+
+// Start 启动后端健康检查，Redis 恢复后自动重放缓冲的写操作。
+// ctx 取消时停止检查。
+func (h *Handler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(h.degradation.cfg.HealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.checkBackend(ctx)
+			}
+		}
+	}()
+}
+
+// Health 返回状态存储后端的健康状况
+func (h *Handler) Health() *BackendHealth {
+	return h.degradation.health()
+}
+
+// probeIfDue 在降级期间距上次健康检查已超过检查间隔时，于后台发起一次探测。
+// 未调用 Start 时降级状态也能随请求恢复；已有探测进行中时不重复发起。
+func (h *Handler) probeIfDue() {
+	d := h.degradation
+	d.mu.Lock()
+	due := d.lastCheckedAt == nil || time.Since(*d.lastCheckedAt) >= d.cfg.HealthCheckInterval
+	d.mu.Unlock()
+	if !due || !d.probing.CompareAndSwap(false, true) {
+		return
+	}
+	go h.checkBackend(context.Background())
+}
+
+// checkBackend 探测 Redis 是否可用，并在恢复时重放缓冲的写操作
+func (h *Handler) checkBackend(ctx context.Context) {
+	h.degradation.probing.Store(true)
+	defer h.degradation.probing.Store(false)
+
+	pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	err := h.redis.Ping(pingCtx).Err()
+	cancel()
+
+	now := time.Now()
+	h.degradation.mu.Lock()
+	h.degradation.lastCheckedAt = &now
+	h.degradation.mu.Unlock()
+
+	if err != nil {
+		if !h.degradation.unavailable.Load() && h.logger != nil {
+			h.logger.WithError(err).Warn("State backend unavailable, entering degraded mode")
+		}
+		h.degradation.markUnavailable(err)
+		return
+	}
+
+	if h.degradation.unavailable.Load() {
+		h.degradation.markAvailable()
+		if h.logger != nil {
+			h.logger.Info("State backend recovered")
+		}
+	}
+	h.replayBuffered(ctx)
+}
+
+// replayBuffered 按顺序重放缓冲的写操作。
+// 重放过程中 Redis 再次不可用时，剩余操作放回缓冲区等待下次重放。
+func (h *Handler) replayBuffered(ctx context.Context) {
+	pending := h.degradation.drain()
+	for i, w := range pending {
+		req := w.req
+		result := h.execute(ctx, w.key, &req)
+		if result.ErrorCode == ErrCodeBackendUnavailable {
+			h.degradation.requeueFront(pending[i:])
+			return
+		}
+		if !result.Success && h.logger != nil {
+			h.logger.WithField("key", w.key).WithField("operation", req.Operation).
+				Warn("Buffered state write failed on replay: " + result.Error)
+		}
+	}
+	if len(pending) > 0 && h.logger != nil {
+		h.logger.WithField("count", len(pending)).Info("Replayed buffered state writes")
+	}
+}
+
+// degrade 在后端不可用时处理请求：
+// 读操作返回默认值；写操作按配置缓冲或拒绝。
+func (h *Handler) degrade(key string, req *StateRequest) *StateResult {
+	if !isWriteOperation(req.Operation) {
+		switch req.Operation {
+		case "exists":
+			return &StateResult{Success: true, Value: []byte("false"), Degraded: true}
+		case "keys":
+			return &StateResult{Success: true, Value: []byte("[]"), Degraded: true}
+		default:
+			return &StateResult{Success: true, Value: nil, Degraded: true}
+		}
+	}
+
+	if h.degradation.cfg.Mode == DegradationBuffer && isBufferable(req.Operation) {
+		if h.degradation.enqueue(key, req) {
+			return &StateResult{Success: true, Degraded: true}
+		}
+		return &StateResult{
+			Success:   false,
+			Error:     ErrBackendUnavailable.Error() + ": write buffer full",
+			ErrorCode: ErrCodeBackendUnavailable,
+			Degraded:  true,
+		}
+	}
+
+	return &StateResult{
+		Success:   false,
+		Error:     ErrBackendUnavailable.Error(),
+		ErrorCode: ErrCodeBackendUnavailable,
+		Degraded:  true,
+	}
+}
+
+// backendError 将 Redis 错误转换为状态操作结果，并在连接层错误时标记后端不可用
+func (h *Handler) backendError(err error) *StateResult {
+	if isBackendUnavailable(err) {
+		h.degradation.markUnavailable(err)
+		return &StateResult{Success: false, Error: err.Error(), ErrorCode: ErrCodeBackendUnavailable}
+	}
+	return &StateResult{Success: false, Error: err.Error(), ErrorCode: ErrCodeOperation}
+}
+
+// wrapBackendError 为连接层错误包装 ErrBackendUnavailable，便于调用方用 errors.Is 判断
+func (h *Handler) wrapBackendError(err error) error {
+	if isBackendUnavailable(err) {
+		h.degradation.markUnavailable(err)
+		return errors.Join(ErrBackendUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// waitAvailable 等待状态后端被健康检查重新标记为可用
+func waitAvailable(t *testing.T, h *Handler) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !h.Health().Available {
+		if time.Now().After(deadline) {
+			t.Fatalf("backend still degraded after recovery: %+v", h.Health())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStartRecoversAndReplaysBufferedWrites(t *testing.T) {
+	h, mr, _ := newTestHandler(t)
+	h.ConfigureDegradation(DegradationConfig{Mode: DegradationBuffer, HealthCheckInterval: 20 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mr.Close()
+	set := &StateRequest{FunctionID: "fn-1", Operation: "set", Scope: "function", Key: "k", Value: json.RawMessage(`1`)}
+	if r := h.Handle(ctx, set); !r.Success || !r.Degraded {
+		t.Fatalf("set during outage = %+v, want buffered write", r)
+	}
+	if h.Health().Available {
+		t.Fatal("backend reported available during outage")
+	}
+
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("restart miniredis: %v", err)
+	}
+	h.Start(ctx)
+	waitAvailable(t, h)
+
+	r := h.Handle(ctx, &StateRequest{FunctionID: "fn-1", Operation: "get", Scope: "function", Key: "k"})
+	if !r.Success || r.Degraded || string(r.Value) != "1" {
+		t.Fatalf("get after recovery = %+v, want replayed value", r)
+	}
+	if health := h.Health(); health.BufferedWrites != 0 || health.UnavailableSince != nil {
+		t.Errorf("health after recovery = %+v, want empty buffer and no outage", health)
+	}
+}
+
+func TestDegradedRequestsProbeBackendWithoutStart(t *testing.T) {
+	h, mr, _ := newTestHandler(t)
+	h.ConfigureDegradation(DegradationConfig{HealthCheckInterval: 20 * time.Millisecond})
+	ctx := context.Background()
+	get := &StateRequest{FunctionID: "fn-1", Operation: "get", Scope: "function", Key: "k"}
+
+	mr.Close()
+	if r := h.Handle(ctx, get); !r.Degraded {
+		t.Fatalf("get during outage = %+v, want degraded result", r)
+	}
+	if r := h.Handle(ctx, &StateRequest{FunctionID: "fn-1", Operation: "incr", Scope: "function", Key: "n", Delta: 1}); r.Success || r.ErrorCode != ErrCodeBackendUnavailable {
+		t.Fatalf("incr during outage = %+v, want backend_unavailable", r)
+	}
+
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("restart miniredis: %v", err)
+	}
+	mr.Set("state:fn-1:_global:k", "2")
+
+	// 降级期间的请求按检查间隔触发探测，Redis 恢复后不再返回降级结果
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r := h.Handle(ctx, get)
+		if !r.Degraded {
+			if !r.Success || string(r.Value) != "2" {
+				t.Fatalf("get after recovery = %+v, want stored value", r)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("handler still degraded after backend recovered: %+v", h.Health())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
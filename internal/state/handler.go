@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,12 +25,23 @@ const compressionThreshold = 1024 // 1KB
 // 压缩标记前缀
 const compressedPrefix = "\x1f\x8b" // gzip magic number
 
+// keys 操作使用 SCAN 分批遍历，避免 KEYS 在大数据量时阻塞 Redis
+const (
+	// keysDefaultBatch 是 keys 操作每页默认返回的 key 数量，也是每次 SCAN 的 COUNT 提示
+	keysDefaultBatch = 500
+	// keysMaxBatch 是 keys 操作每页允许的最大 key 数量
+	keysMaxBatch = 1000
+	// keysMaxScanRounds 是单次 keys 请求最多执行的 SCAN 次数，匹配稀疏时提前返回游标，限制单次请求耗时
+	keysMaxScanRounds = 10
+)
+
 // Handler 处理状态操作请求
 type Handler struct {
-	redis            *redis.Client
-	logger           *logrus.Logger
-	config           *domain.StateConfig
-	enableCompression bool // 是否启用压缩
+	redis             *redis.Client
+	logger            *logrus.Logger
+	config            *domain.StateConfig
+	enableCompression bool         // 是否启用压缩
+	degradation       *degradation // Redis 不可用时的降级状态
 }
 
 // StateRequest 状态请求
@@ -43,7 +55,15 @@ type StateRequest struct {
 	Value        json.RawMessage `json:"value,omitempty"`
 	TTL          int             `json:"ttl,omitempty"`
 	Delta        int64           `json:"delta,omitempty"`
-	Version      int64           `json:"version,omitempty"`
+	// Version set_with_version 的旧版期望版本号：为 0 时不检查版本，大于 0 时必须与当前版本一致
+	Version int64 `json:"version,omitempty"`
+	// ExpectedVersion set_with_version 的期望版本号，设置后优先于 Version：
+	// 当前版本必须与之相等，为 0 表示键尚不存在
+	ExpectedVersion *int64 `json:"expected_version,omitempty"`
+	// Cursor keys 操作的分页游标，为空表示从头开始遍历
+	Cursor string `json:"cursor,omitempty"`
+	// Count keys 操作每页返回的最大 key 数量，为 0 时使用默认值
+	Count int `json:"count,omitempty"`
 }
 
 // StateResult 状态响应
@@ -52,6 +72,12 @@ type StateResult struct {
 	Value   json.RawMessage `json:"value,omitempty"`
 	Version int64           `json:"version,omitempty"`
 	Error   string          `json:"error,omitempty"`
+	// ErrorCode 错误码：backend_unavailable（Redis 不可用）或 operation_error（操作错误）
+	ErrorCode string `json:"error_code,omitempty"`
+	// Degraded 表示结果来自降级路径（读为默认值，写已缓冲）
+	Degraded bool `json:"degraded,omitempty"`
+	// Cursor keys 操作的下一页游标，为空表示已遍历完成
+	Cursor string `json:"cursor,omitempty"`
 }
 
 // NewHandler 创建新的状态处理器
@@ -64,6 +90,7 @@ func NewHandler(redisClient *redis.Client, config *domain.StateConfig, logger *l
 		logger:            logger,
 		config:            config,
 		enableCompression: true, // 默认启用压缩
+		degradation:       newDegradation(DegradationConfig{}),
 	}
 }
 
@@ -115,9 +142,27 @@ func (h *Handler) Handle(ctx context.Context, req *StateRequest) *StateResult {
 
 	// 验证 key 大小
 	if len(redisKey) > 256 {
-		return &StateResult{Success: false, Error: "key too long"}
+		return &StateResult{Success: false, Error: "key too long", ErrorCode: ErrCodeOperation}
+	}
+
+	// 后端已知不可用时直接走降级路径，避免每个请求都等待连接超时；到期时在后台探测是否已恢复
+	if h.degradation.unavailable.Load() {
+		h.probeIfDue()
+		return h.degrade(redisKey, req)
 	}
 
+	result := h.execute(ctx, redisKey, req)
+	if result.ErrorCode == ErrCodeBackendUnavailable {
+		return h.degrade(redisKey, req)
+	}
+	if !result.Success && result.ErrorCode == "" {
+		result.ErrorCode = ErrCodeOperation
+	}
+	return result
+}
+
+// execute 对 Redis 执行状态操作
+func (h *Handler) execute(ctx context.Context, redisKey string, req *StateRequest) *StateResult {
 	switch req.Operation {
 	case "get":
 		return h.handleGet(ctx, redisKey)
@@ -145,19 +190,22 @@ func (h *Handler) Handle(ctx context.Context, req *StateRequest) *StateResult {
 // buildKey 构建 Redis key
 // 格式: state:{function_id}:{scope_key}:{user_key}
 func (h *Handler) buildKey(req *StateRequest) string {
-	var scopeKey string
+	return fmt.Sprintf("state:%s:%s:%s", req.FunctionID, scopeKey(req), req.Key)
+}
+
+// scopeKey 返回请求作用域在 Redis key 中的标识
+func scopeKey(req *StateRequest) string {
 	switch req.Scope {
 	case "function":
-		scopeKey = "_global"
+		return "_global"
 	case "invocation":
-		scopeKey = req.InvocationID
+		return req.InvocationID
 	default: // session
-		scopeKey = req.SessionKey
-		if scopeKey == "" {
-			scopeKey = "_default"
+		if req.SessionKey == "" {
+			return "_default"
 		}
+		return req.SessionKey
 	}
-	return fmt.Sprintf("state:%s:%s:%s", req.FunctionID, scopeKey, req.Key)
 }
 
 func (h *Handler) handleGet(ctx context.Context, key string) *StateResult {
@@ -166,7 +214,7 @@ func (h *Handler) handleGet(ctx context.Context, key string) *StateResult {
 		return &StateResult{Success: true, Value: nil}
 	}
 	if err != nil {
-		return &StateResult{Success: false, Error: err.Error()}
+		return h.backendError(err)
 	}
 	// 解压数据
 	decompressed := h.decompress(val)
@@ -186,7 +234,7 @@ func (h *Handler) handleGetWithVersion(ctx context.Context, key string) *StateRe
 		return &StateResult{Success: true, Value: nil, Version: 0}
 	}
 	if err != nil {
-		return &StateResult{Success: false, Error: err.Error()}
+		return h.backendError(err)
 	}
 
 	// 解压数据
@@ -195,18 +243,32 @@ func (h *Handler) handleGetWithVersion(ctx context.Context, key string) *StateRe
 	return &StateResult{Success: true, Value: decompressed, Version: version}
 }
 
-func (h *Handler) handleSet(ctx context.Context, key string, req *StateRequest) *StateResult {
-	// 验证值大小
+// checkValueSize 检查写入的值是否超过配置的大小上限，超过时返回错误结果
+func (h *Handler) checkValueSize(req *StateRequest) *StateResult {
 	if h.config != nil && h.config.MaxStateSize > 0 && len(req.Value) > h.config.MaxStateSize {
 		return &StateResult{Success: false, Error: fmt.Sprintf("value too large: %d > %d", len(req.Value), h.config.MaxStateSize)}
 	}
+	return nil
+}
 
-	var ttl time.Duration
+// effectiveTTL 返回写入使用的过期时间：请求未指定时使用配置的默认 TTL，均未设置时为 0（不过期）
+func (h *Handler) effectiveTTL(req *StateRequest) time.Duration {
 	if req.TTL > 0 {
-		ttl = time.Duration(req.TTL) * time.Second
-	} else if h.config != nil && h.config.DefaultTTL > 0 {
-		ttl = time.Duration(h.config.DefaultTTL) * time.Second
+		return time.Duration(req.TTL) * time.Second
+	}
+	if h.config != nil && h.config.DefaultTTL > 0 {
+		return time.Duration(h.config.DefaultTTL) * time.Second
 	}
+	return 0
+}
+
+func (h *Handler) handleSet(ctx context.Context, key string, req *StateRequest) *StateResult {
+	// 验证值大小
+	if result := h.checkValueSize(req); result != nil {
+		return result
+	}
+
+	ttl := h.effectiveTTL(req)
 
 	// 压缩数据
 	compressed := h.compress([]byte(req.Value))
@@ -219,43 +281,63 @@ func (h *Handler) handleSet(ctx context.Context, key string, req *StateRequest)
 	}
 
 	if err != nil {
-		return &StateResult{Success: false, Error: err.Error()}
+		return h.backendError(err)
 	}
 	return &StateResult{Success: true}
 }
 
+// setWithVersionScript 原子地比较版本号并写入值：
+// KEYS[1] 为值，KEYS[2] 为版本号；ARGV[1] 为值，ARGV[2] 为期望版本号（空串表示不检查），ARGV[3] 为过期毫秒数（0 表示不过期）。
+// 当前版本号（不存在时为 0）与期望版本号不一致时返回 {0, 当前版本号}，否则写入并返回 {1, 新版本号}。
+var setWithVersionScript = redis.NewScript(`
+	local current_version = tonumber(redis.call('GET', KEYS[2]) or '0')
+	local expected_version = tonumber(ARGV[2])
+
+	if expected_version ~= nil and current_version ~= expected_version then
+		return {0, current_version}  -- 版本冲突
+	end
+
+	local new_version = current_version + 1
+	local ttl = tonumber(ARGV[3])
+	if ttl > 0 then
+		redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+		redis.call('SET', KEYS[2], new_version, 'PX', ttl)
+	else
+		redis.call('SET', KEYS[1], ARGV[1])
+		redis.call('SET', KEYS[2], new_version)
+	end
+
+	return {1, new_version}
+`)
+
+// expectedVersionArg 返回传给 setWithVersionScript 的期望版本号参数。
+// ExpectedVersion 为 0 表示键尚不存在（get_with_version 对不存在的键返回版本号 0），
+// 因此并发的首次写入也只有一个能成功；未设置时沿用 Version 的旧语义，0 表示不检查版本。
+func expectedVersionArg(req *StateRequest) string {
+	if req.ExpectedVersion != nil {
+		return strconv.FormatInt(*req.ExpectedVersion, 10)
+	}
+	if req.Version > 0 {
+		return strconv.FormatInt(req.Version, 10)
+	}
+	return ""
+}
+
+// handleSetWithVersion 乐观锁写入（compare-and-set）
 func (h *Handler) handleSetWithVersion(ctx context.Context, key string, req *StateRequest) *StateResult {
+	if result := h.checkValueSize(req); result != nil {
+		return result
+	}
 	versionKey := key + ":version"
 
 	// 压缩数据
 	compressed := h.compress([]byte(req.Value))
 
-	// 使用 Lua 脚本实现乐观锁
-	script := redis.NewScript(`
-		local current_version = tonumber(redis.call('GET', KEYS[2]) or '0')
-		local expected_version = tonumber(ARGV[2])
-
-		if expected_version > 0 and current_version ~= expected_version then
-			return {0, current_version}  -- 版本冲突
-		end
-
-		local new_version = current_version + 1
-		redis.call('SET', KEYS[1], ARGV[1])
-		redis.call('SET', KEYS[2], new_version)
-
-		if tonumber(ARGV[3]) > 0 then
-			redis.call('EXPIRE', KEYS[1], ARGV[3])
-			redis.call('EXPIRE', KEYS[2], ARGV[3])
-		end
-
-		return {1, new_version}
-	`)
-
-	result, err := script.Run(ctx, h.redis, []string{key, versionKey},
-		string(compressed), req.Version, req.TTL).Slice()
+	result, err := setWithVersionScript.Run(ctx, h.redis, []string{key, versionKey},
+		string(compressed), expectedVersionArg(req), h.effectiveTTL(req).Milliseconds()).Slice()
 
 	if err != nil {
-		return &StateResult{Success: false, Error: err.Error()}
+		return h.backendError(err)
 	}
 
 	success := result[0].(int64) == 1
@@ -270,7 +352,7 @@ func (h *Handler) handleSetWithVersion(ctx context.Context, key string, req *Sta
 func (h *Handler) handleDelete(ctx context.Context, key string) *StateResult {
 	err := h.redis.Del(ctx, key, key+":version").Err()
 	if err != nil {
-		return &StateResult{Success: false, Error: err.Error()}
+		return h.backendError(err)
 	}
 	return &StateResult{Success: true}
 }
@@ -290,7 +372,7 @@ func (h *Handler) handleIncr(ctx context.Context, key string, delta int64) *Stat
 	}
 
 	if err != nil {
-		return &StateResult{Success: false, Error: err.Error()}
+		return h.backendError(err)
 	}
 
 	valueJSON, _ := json.Marshal(result)
@@ -300,46 +382,82 @@ func (h *Handler) handleIncr(ctx context.Context, key string, delta int64) *Stat
 func (h *Handler) handleExists(ctx context.Context, key string) *StateResult {
 	exists, err := h.redis.Exists(ctx, key).Result()
 	if err != nil {
-		return &StateResult{Success: false, Error: err.Error()}
+		return h.backendError(err)
 	}
 
 	valueJSON, _ := json.Marshal(exists > 0)
 	return &StateResult{Success: true, Value: valueJSON}
 }
 
+// handleKeys 返回一页匹配 req.Key（glob 模式）的用户 key
+// 使用 SCAN 从 req.Cursor 处继续遍历，收集到 req.Count 个 key 或 SCAN 次数达到上限时返回，
+// 结果中的 Cursor 非空时调用方需用它继续请求下一页。与 SCAN 一样，遍历期间被修改的 key
+// 可能重复出现，调用方需自行去重。
 func (h *Handler) handleKeys(ctx context.Context, req *StateRequest) *StateResult {
-	// 构建 pattern
-	var scopeKey string
-	switch req.Scope {
-	case "function":
-		scopeKey = "_global"
-	case "invocation":
-		scopeKey = req.InvocationID
-	default:
-		scopeKey = req.SessionKey
-		if scopeKey == "" {
-			scopeKey = "_default"
+	var cursor uint64
+	if req.Cursor != "" {
+		parsed, err := strconv.ParseUint(req.Cursor, 10, 64)
+		if err != nil {
+			return &StateResult{Success: false, Error: "invalid cursor: " + req.Cursor}
 		}
+		cursor = parsed
 	}
-
-	pattern := fmt.Sprintf("state:%s:%s:%s", req.FunctionID, scopeKey, req.Key)
-
-	keys, err := h.redis.Keys(ctx, pattern).Result()
-	if err != nil {
-		return &StateResult{Success: false, Error: err.Error()}
+	batch := req.Count
+	if batch <= 0 {
+		batch = keysDefaultBatch
+	}
+	if batch > keysMaxBatch {
+		batch = keysMaxBatch
 	}
 
-	// 移除前缀，只返回用户 key
-	prefix := fmt.Sprintf("state:%s:%s:", req.FunctionID, scopeKey)
-	userKeys := make([]string, 0, len(keys))
-	for _, k := range keys {
-		if len(k) > len(prefix) && !strings.HasSuffix(k, ":version") {
-			userKeys = append(userKeys, k[len(prefix):])
+	prefix := fmt.Sprintf("state:%s:%s:", req.FunctionID, scopeKey(req))
+	pattern := prefix + req.Key
+
+	userKeys := make([]string, 0, batch)
+	for round := 0; round < keysMaxScanRounds; round++ {
+		keys, next, err := h.redis.Scan(ctx, cursor, pattern, int64(batch)).Result()
+		if err != nil {
+			return h.backendError(err)
+		}
+		// 移除前缀，只返回用户 key
+		for _, k := range keys {
+			if len(k) > len(prefix) && !strings.HasSuffix(k, ":version") {
+				userKeys = append(userKeys, k[len(prefix):])
+			}
+		}
+		cursor = next
+		if cursor == 0 || len(userKeys) >= batch {
+			break
 		}
 	}
 
 	valueJSON, _ := json.Marshal(userKeys)
-	return &StateResult{Success: true, Value: valueJSON}
+	result := &StateResult{Success: true, Value: valueJSON}
+	if cursor != 0 {
+		result.Cursor = strconv.FormatUint(cursor, 10)
+	}
+	return result
+}
+
+// scanKeys 使用 SCAN 分批遍历匹配 pattern 的所有 key，每批交给 fn 处理
+// 遍历期间被修改的 key 可能在多个批次中重复出现
+func (h *Handler) scanKeys(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := h.redis.Scan(ctx, cursor, pattern, keysDefaultBatch).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
 }
 
 func (h *Handler) handleExpire(ctx context.Context, key string, ttl int) *StateResult {
@@ -348,7 +466,7 @@ func (h *Handler) handleExpire(ctx context.Context, key string, ttl int) *StateR
 	}
 	err := h.redis.Expire(ctx, key, time.Duration(ttl)*time.Second).Err()
 	if err != nil {
-		return &StateResult{Success: false, Error: err.Error()}
+		return h.backendError(err)
 	}
 	return &StateResult{Success: true}
 }
@@ -356,9 +474,19 @@ func (h *Handler) handleExpire(ctx context.Context, key string, ttl int) *StateR
 // GetSessionState 获取会话的所有状态 key 信息
 func (h *Handler) GetSessionState(ctx context.Context, functionID, sessionKey string) ([]*domain.StateKeyInfo, int64, error) {
 	pattern := fmt.Sprintf("state:%s:%s:*", functionID, sessionKey)
-	keys, err := h.redis.Keys(ctx, pattern).Result()
+	var keys []string
+	seen := make(map[string]bool)
+	err := h.scanKeys(ctx, pattern, func(batch []string) error {
+		for _, k := range batch {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, h.wrapBackendError(err)
 	}
 
 	var keyInfos []*domain.StateKeyInfo
@@ -402,13 +530,22 @@ func (h *Handler) GetSessionState(ctx context.Context, functionID, sessionKey st
 // DeleteSessionState 删除会话的所有状态
 func (h *Handler) DeleteSessionState(ctx context.Context, functionID, sessionKey string) error {
 	pattern := fmt.Sprintf("state:%s:%s:*", functionID, sessionKey)
-	keys, err := h.redis.Keys(ctx, pattern).Result()
+	// 先遍历完再删除，避免边遍历边删除时部分 Redis 实现的游标跳过 key
+	var keys []string
+	err := h.scanKeys(ctx, pattern, func(batch []string) error {
+		keys = append(keys, batch...)
+		return nil
+	})
 	if err != nil {
-		return err
+		return h.wrapBackendError(err)
 	}
 
-	if len(keys) > 0 {
-		return h.redis.Del(ctx, keys...).Err()
+	for len(keys) > 0 {
+		n := min(len(keys), keysDefaultBatch)
+		if err := h.redis.Del(ctx, keys[:n]...).Err(); err != nil {
+			return h.wrapBackendError(err)
+		}
+		keys = keys[n:]
 	}
 	return nil
 }
@@ -416,5 +553,5 @@ func (h *Handler) DeleteSessionState(ctx context.Context, functionID, sessionKey
 // DeleteStateKey 删除指定的状态 key
 func (h *Handler) DeleteStateKey(ctx context.Context, functionID, sessionKey, userKey string) error {
 	key := fmt.Sprintf("state:%s:%s:%s", functionID, sessionKey, userKey)
-	return h.redis.Del(ctx, key, key+":version").Err()
+	return h.wrapBackendError(h.redis.Del(ctx, key, key+":version").Err())
 }
@@ -0,0 +1,290 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// commandRecorder 记录客户端发出的 Redis 命令名
+type commandRecorder struct {
+	mu       sync.Mutex
+	commands map[string]int
+}
+
+func (r *commandRecorder) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (r *commandRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		r.mu.Lock()
+		r.commands[strings.ToLower(cmd.Name())]++
+		r.mu.Unlock()
+		return next(ctx, cmd)
+	}
+}
+
+func (r *commandRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		r.mu.Lock()
+		for _, cmd := range cmds {
+			r.commands[strings.ToLower(cmd.Name())]++
+		}
+		r.mu.Unlock()
+		return next(ctx, cmds)
+	}
+}
+
+// newTestHandler 返回连接 miniredis 的状态处理器和命令记录器
+func newTestHandler(t *testing.T) (*Handler, *miniredis.Miniredis, *commandRecorder) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	rec := &commandRecorder{commands: make(map[string]int)}
+	client.AddHook(rec)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewHandler(client, nil, logger), mr, rec
+}
+
+func TestKeysPaginatesLargeKeyspaceWithScan(t *testing.T) {
+	h, mr, rec := newTestHandler(t)
+
+	const total = 3000
+	for i := 0; i < total; i++ {
+		mr.Set(fmt.Sprintf("state:fn-1:sess-1:user:%d", i), "1")
+	}
+	// 版本 key、其他会话和其他前缀的 key 不应出现在结果中
+	mr.Set("state:fn-1:sess-1:user:0:version", "3")
+	mr.Set("state:fn-1:sess-2:user:1", "1")
+	mr.Set("state:fn-1:sess-1:other", "1")
+
+	seen := make(map[string]bool)
+	cursor := ""
+	pages := 0
+	for {
+		result := h.Handle(context.Background(), &StateRequest{
+			FunctionID: "fn-1", SessionKey: "sess-1", Operation: "keys",
+			Key: "user:*", Cursor: cursor, Count: 200,
+		})
+		if !result.Success {
+			t.Fatalf("keys failed: %s", result.Error)
+		}
+		var keys []string
+		if err := json.Unmarshal(result.Value, &keys); err != nil {
+			t.Fatalf("invalid keys value %s: %v", result.Value, err)
+		}
+		for _, k := range keys {
+			if !strings.HasPrefix(k, "user:") || strings.HasSuffix(k, ":version") {
+				t.Fatalf("unexpected key %q", k)
+			}
+			seen[k] = true
+		}
+		pages++
+		cursor = result.Cursor
+		if cursor == "" {
+			break
+		}
+		if pages > total {
+			t.Fatal("keys pagination did not terminate")
+		}
+	}
+
+	if len(seen) != total {
+		t.Errorf("collected %d distinct keys, want %d", len(seen), total)
+	}
+	if pages < total/keysMaxBatch {
+		t.Errorf("pages = %d, want multiple pages for %d keys", pages, total)
+	}
+	if rec.commands["keys"] != 0 {
+		t.Errorf("KEYS issued %d times, want 0", rec.commands["keys"])
+	}
+	if rec.commands["scan"] == 0 {
+		t.Error("SCAN was not used")
+	}
+}
+
+func TestKeysRejectsInvalidCursor(t *testing.T) {
+	h, _, _ := newTestHandler(t)
+	result := h.Handle(context.Background(), &StateRequest{FunctionID: "fn-1", Operation: "keys", Key: "*", Cursor: "abc"})
+	if result.Success || !strings.Contains(result.Error, "invalid cursor") {
+		t.Errorf("result = %+v, want invalid cursor error", result)
+	}
+}
+
+func TestSessionStateUsesScan(t *testing.T) {
+	h, mr, rec := newTestHandler(t)
+	for i := 0; i < 1200; i++ {
+		mr.Set(fmt.Sprintf("state:fn-1:sess-1:k%d", i), "v")
+	}
+	mr.Set("state:fn-1:sess-2:k0", "v")
+
+	infos, _, err := h.GetSessionState(context.Background(), "fn-1", "sess-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1200 {
+		t.Errorf("GetSessionState() returned %d keys, want 1200", len(infos))
+	}
+
+	if err := h.DeleteSessionState(context.Background(), "fn-1", "sess-1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mr.Keys()); n != 1 {
+		t.Errorf("%d keys left after DeleteSessionState, want only the other session", n)
+	}
+	if rec.commands["keys"] != 0 {
+		t.Errorf("KEYS issued %d times, want 0", rec.commands["keys"])
+	}
+}
+
+// incrementWithCAS 以 get_with_version/set_with_version 读-改-写计数器，版本冲突时重新读取后重试，返回冲突次数
+func incrementWithCAS(t *testing.T, h *Handler) int {
+	conflicts := 0
+	for {
+		got := h.Handle(context.Background(), &StateRequest{FunctionID: "fn-1", Scope: "function", Operation: "get_with_version", Key: "counter"})
+		if !got.Success {
+			t.Errorf("get_with_version failed: %s", got.Error)
+			return conflicts
+		}
+		var n int
+		if got.Value != nil {
+			if err := json.Unmarshal(got.Value, &n); err != nil {
+				t.Errorf("invalid counter value %s: %v", got.Value, err)
+				return conflicts
+			}
+		}
+		set := h.Handle(context.Background(), &StateRequest{
+			FunctionID: "fn-1", Scope: "function", Operation: "set_with_version", Key: "counter",
+			Value: json.RawMessage(fmt.Sprint(n + 1)), ExpectedVersion: &got.Version,
+		})
+		if set.Success {
+			return conflicts
+		}
+		if set.Error != "version conflict" {
+			t.Errorf("set_with_version failed: %s", set.Error)
+			return conflicts
+		}
+		conflicts++
+	}
+}
+
+func TestSetWithVersionReadModifyWriteRetriesOnConflict(t *testing.T) {
+	h, _, _ := newTestHandler(t)
+
+	const workers, increments = 8, 25
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conflicts := 0
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				c := incrementWithCAS(t, h)
+				mu.Lock()
+				conflicts += c
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := h.Handle(context.Background(), &StateRequest{FunctionID: "fn-1", Scope: "function", Operation: "get_with_version", Key: "counter"})
+	if string(got.Value) != fmt.Sprint(workers*increments) {
+		t.Errorf("counter = %s, want %d (lost updates)", got.Value, workers*increments)
+	}
+	if got.Version != workers*increments {
+		t.Errorf("version = %d, want %d", got.Version, workers*increments)
+	}
+	t.Logf("%d version conflicts retried", conflicts)
+}
+
+func TestSetWithVersionZeroRequiresAbsentKey(t *testing.T) {
+	h, _, _ := newTestHandler(t)
+	req := func(value string, version int64) *StateResult {
+		return h.Handle(context.Background(), &StateRequest{
+			FunctionID: "fn-1", Scope: "function", Operation: "set_with_version", Key: "k",
+			Value: json.RawMessage(value), ExpectedVersion: &version,
+		})
+	}
+
+	if r := req(`"first"`, 0); !r.Success || r.Version != 1 {
+		t.Fatalf("first create = %+v, want version 1", r)
+	}
+	// 另一个写入者读取时键尚不存在，创建时必须冲突而不能覆盖
+	if r := req(`"second"`, 0); r.Success || r.Error != "version conflict" || r.Version != 1 {
+		t.Errorf("second create = %+v, want conflict at version 1", r)
+	}
+	if r := req(`"stale"`, 5); r.Success || r.Version != 1 {
+		t.Errorf("stale write = %+v, want conflict at version 1", r)
+	}
+}
+
+func TestSetWithVersionLegacyZeroSkipsCheck(t *testing.T) {
+	h, _, _ := newTestHandler(t)
+	req := func(value string, version int64) *StateResult {
+		return h.Handle(context.Background(), &StateRequest{
+			FunctionID: "fn-1", Scope: "function", Operation: "set_with_version", Key: "k",
+			Value: json.RawMessage(value), Version: version,
+		})
+	}
+
+	// 未设置 expected_version 时 version 为 0 不检查版本，已存在的键也会被覆盖
+	for i, value := range []string{`"first"`, `"second"`} {
+		if r := req(value, 0); !r.Success || r.Version != int64(i+1) {
+			t.Fatalf("unchecked write %s = %+v, want version %d", value, r, i+1)
+		}
+	}
+	if r := req(`"stale"`, 1); r.Success || r.Error != "version conflict" || r.Version != 2 {
+		t.Errorf("stale legacy write = %+v, want conflict at version 2", r)
+	}
+	if r := req(`"third"`, 2); !r.Success || r.Version != 3 {
+		t.Errorf("matching legacy write = %+v, want version 3", r)
+	}
+}
+
+func TestSetWithVersionAppliesTTLAndSizeLimit(t *testing.T) {
+	h, mr, _ := newTestHandler(t)
+	h.config = &domain.StateConfig{DefaultTTL: 60, MaxStateSize: 16}
+
+	r := h.Handle(context.Background(), &StateRequest{
+		FunctionID: "fn-1", Scope: "function", Operation: "set_with_version", Key: "k", Value: json.RawMessage(`"v"`),
+	})
+	if !r.Success {
+		t.Fatalf("set_with_version failed: %s", r.Error)
+	}
+	for _, key := range []string{"state:fn-1:_global:k", "state:fn-1:_global:k:version"} {
+		if ttl := mr.TTL(key); ttl != 60*time.Second {
+			t.Errorf("TTL(%s) = %v, want default TTL 60s", key, ttl)
+		}
+	}
+
+	r = h.Handle(context.Background(), &StateRequest{
+		FunctionID: "fn-1", Scope: "function", Operation: "set_with_version", Key: "k",
+		Value: json.RawMessage(`"v"`), Version: 1, TTL: 5,
+	})
+	if !r.Success || mr.TTL("state:fn-1:_global:k") != 5*time.Second {
+		t.Errorf("set_with_version with ttl = %+v, TTL = %v, want 5s", r, mr.TTL("state:fn-1:_global:k"))
+	}
+
+	r = h.Handle(context.Background(), &StateRequest{
+		FunctionID: "fn-1", Scope: "function", Operation: "set_with_version", Key: "k",
+		Value: json.RawMessage(`"this value is too large"`), Version: 2,
+	})
+	if r.Success || !strings.Contains(r.Error, "value too large") {
+		t.Errorf("oversized set_with_version = %+v, want size error", r)
+	}
+}
@@ -4,6 +4,7 @@ package storage
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -99,6 +100,21 @@ func (s *PostgresStore) ListAlerts(status, functionID string) ([]*domain.Alert,
 	return result, nil
 }
 
+// CreateAlert 记录告警实例
+func (s *PostgresStore) CreateAlert(alert *domain.Alert) error {
+	alertsMu.Lock()
+	defer alertsMu.Unlock()
+
+	if alert.ID == "" {
+		alert.ID = uuid.New().String()
+	}
+	if alert.FiredAt.IsZero() {
+		alert.FiredAt = time.Now()
+	}
+	alerts[alert.ID] = alert
+	return nil
+}
+
 // ResolveAlert 解决告警
 func (s *PostgresStore) ResolveAlert(id string) error {
 	alertsMu.Lock()
@@ -171,68 +187,51 @@ func (s *PostgresStore) SaveWarmingPolicy(policy *domain.WarmingPolicy) error {
 
 // ==================== 依赖分析存储 ====================
 
-// GetFunctionCallsTo 获取函数调用的其他函数
-func (s *PostgresStore) GetFunctionCallsTo(functionID string) ([]domain.FunctionDependency, error) {
+// GetFunctionDependencies 获取函数的直接依赖关系。
+//
+// 参数:
+//   - functionID: 函数 ID
+//
+// 返回值:
+//   - upstream: 调用该函数的依赖（target_id 为该函数）
+//   - downstream: 该函数调用的依赖（source_id 为该函数）
+//   - err: 查询失败时返回错误
+func (s *PostgresStore) GetFunctionDependencies(functionID string) (upstream, downstream []domain.FunctionDependency, err error) {
 	query := `
 		SELECT d.source_id, f1.name as source_name, d.target_id, f2.name as target_name,
 		       d.type, d.call_count, d.last_called_at
 		FROM function_dependencies d
 		JOIN functions f1 ON d.source_id = f1.id
 		JOIN functions f2 ON d.target_id = f2.id
-		WHERE d.source_id = $1
+		WHERE d.source_id = $1 OR d.target_id = $1
+		ORDER BY d.call_count DESC
 	`
 	rows, err := s.db.Query(query, functionID)
 	if err != nil {
-		return []domain.FunctionDependency{}, nil
+		return nil, nil, fmt.Errorf("failed to query function dependencies: %w", err)
 	}
 	defer rows.Close()
 
-	var deps []domain.FunctionDependency
+	upstream, downstream = []domain.FunctionDependency{}, []domain.FunctionDependency{}
 	for rows.Next() {
 		var dep domain.FunctionDependency
 		var lastCalled sql.NullTime
 		if err := rows.Scan(&dep.SourceID, &dep.SourceName, &dep.TargetID, &dep.TargetName,
 			&dep.Type, &dep.CallCount, &lastCalled); err != nil {
-			continue
+			return nil, nil, fmt.Errorf("failed to scan function dependency: %w", err)
 		}
 		if lastCalled.Valid {
 			dep.LastCalledAt = &lastCalled.Time
 		}
-		deps = append(deps, dep)
-	}
-	return deps, nil
-}
-
-// GetFunctionCalledBy 获取调用该函数的其他函数
-func (s *PostgresStore) GetFunctionCalledBy(functionID string) ([]domain.FunctionDependency, error) {
-	query := `
-		SELECT d.source_id, f1.name as source_name, d.target_id, f2.name as target_name,
-		       d.type, d.call_count, d.last_called_at
-		FROM function_dependencies d
-		JOIN functions f1 ON d.source_id = f1.id
-		JOIN functions f2 ON d.target_id = f2.id
-		WHERE d.target_id = $1
-	`
-	rows, err := s.db.Query(query, functionID)
-	if err != nil {
-		return []domain.FunctionDependency{}, nil
-	}
-	defer rows.Close()
-
-	var deps []domain.FunctionDependency
-	for rows.Next() {
-		var dep domain.FunctionDependency
-		var lastCalled sql.NullTime
-		if err := rows.Scan(&dep.SourceID, &dep.SourceName, &dep.TargetID, &dep.TargetName,
-			&dep.Type, &dep.CallCount, &lastCalled); err != nil {
-			continue
+		// 自调用同时出现在两侧
+		if dep.TargetID == functionID {
+			upstream = append(upstream, dep)
 		}
-		if lastCalled.Valid {
-			dep.LastCalledAt = &lastCalled.Time
+		if dep.SourceID == functionID {
+			downstream = append(downstream, dep)
 		}
-		deps = append(deps, dep)
 	}
-	return deps, nil
+	return upstream, downstream, rows.Err()
 }
 
 // GetAllDependencyEdges 获取所有依赖边
@@ -244,7 +243,7 @@ func (s *PostgresStore) GetAllDependencyEdges() ([]domain.DependencyEdge, error)
 	`
 	rows, err := s.db.Query(query)
 	if err != nil {
-		return []domain.DependencyEdge{}, nil
+		return nil, fmt.Errorf("failed to query dependency edges: %w", err)
 	}
 	defer rows.Close()
 
@@ -252,15 +251,36 @@ func (s *PostgresStore) GetAllDependencyEdges() ([]domain.DependencyEdge, error)
 	for rows.Next() {
 		var edge domain.DependencyEdge
 		if err := rows.Scan(&edge.Source, &edge.Target, &edge.Type, &edge.CallCount); err != nil {
-			continue
+			return nil, fmt.Errorf("failed to scan dependency edge: %w", err)
 		}
 		edges = append(edges, edge)
 	}
-	return edges, nil
+	return edges, rows.Err()
+}
+
+// DetectCycle 沿已记录的依赖查找从 startID 出发回到自身的调用环。
+//
+// 参数:
+//   - startID: 起点函数 ID
+//
+// 返回值:
+//   - []string: 环上的函数 ID，首尾均为 startID；不存在环时返回 nil
+//   - error: 查询失败时返回错误
+func (s *PostgresStore) DetectCycle(startID string) ([]string, error) {
+	edges, err := s.GetAllDependencyEdges()
+	if err != nil {
+		return nil, err
+	}
+	return domain.FindDependencyCycle(edges, startID), nil
 }
 
-// AddFunctionDependency 添加或更新函数依赖
-func (s *PostgresStore) AddFunctionDependency(sourceID, targetID string, depType domain.DependencyType) error {
+// RecordDependency 记录一次函数间调用：依赖不存在时创建，已存在时调用次数加一并更新最近调用时间。
+//
+// 参数:
+//   - sourceID: 调用方函数 ID
+//   - targetID: 被调用函数 ID
+//   - depType: 依赖类型
+func (s *PostgresStore) RecordDependency(sourceID, targetID string, depType domain.DependencyType) error {
 	query := `
 		INSERT INTO function_dependencies (id, source_id, target_id, type, call_count, last_called_at)
 		VALUES ($1, $2, $3, $4, 1, NOW())
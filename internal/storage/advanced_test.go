@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+)
+
+func TestRecordDependencyIncrementsCallCount(t *testing.T) {
+	db := sqltest.New()
+	store := newSQLTestStore(t, db)
+	if err := store.RecordDependency("fn-a", "fn-b", domain.DependencyTypeDirectCall); err != nil {
+		t.Fatalf("RecordDependency() error = %v", err)
+	}
+
+	// 同一来源、目标和类型的依赖只保留一行，重复调用时累加次数
+	call := db.Calls()[0]
+	for _, want := range []string{
+		"VALUES ($1, $2, $3, $4, 1, NOW())",
+		"ON CONFLICT (source_id, target_id, type) DO UPDATE SET",
+		"call_count = function_dependencies.call_count + 1",
+		"last_called_at = NOW()",
+	} {
+		if !strings.Contains(call.Query, want) {
+			t.Errorf("query missing %q:\n%s", want, call.Query)
+		}
+	}
+	if v := call.Values(); v["source_id"] != "fn-a" || v["target_id"] != "fn-b" || v["type"] != string(domain.DependencyTypeDirectCall) {
+		t.Errorf("insert values = %v, want fn-a -> fn-b direct call", v)
+	}
+}
+
+func TestGetFunctionDependenciesBothDirections(t *testing.T) {
+	cols := []string{"source_id", "source_name", "target_id", "target_name", "type", "call_count", "last_called_at"}
+	now := time.Now()
+	db := sqltest.New().On("WHERE d.source_id = $1 OR d.target_id = $1",
+		sqltest.Rows(cols,
+			[]driver.Value{"fn-a", "orders", "fn-b", "billing", "direct_call", int64(3), now},
+			[]driver.Value{"fn-b", "billing", "fn-c", "email", "direct_call", int64(1), nil},
+		),
+		sqltest.Rows(cols),
+	)
+	store := newSQLTestStore(t, db)
+
+	upstream, downstream, err := store.GetFunctionDependencies("fn-b")
+	if err != nil {
+		t.Fatalf("GetFunctionDependencies() error = %v", err)
+	}
+	if args := db.Calls()[0].Args; len(args) != 1 || args[0] != "fn-b" {
+		t.Errorf("args = %v, want [fn-b]", args)
+	}
+	if len(upstream) != 1 || upstream[0].SourceName != "orders" || upstream[0].CallCount != 3 || upstream[0].LastCalledAt == nil {
+		t.Errorf("upstream = %+v, want orders -> billing", upstream)
+	}
+	if len(downstream) != 1 || downstream[0].TargetName != "email" || downstream[0].LastCalledAt != nil {
+		t.Errorf("downstream = %+v, want billing -> email", downstream)
+	}
+
+	// 没有依赖的函数返回空列表
+	upstream, downstream, err = store.GetFunctionDependencies("fn-x")
+	if err != nil || upstream == nil || downstream == nil || len(upstream)+len(downstream) != 0 {
+		t.Errorf("no dependencies: upstream=%v downstream=%v err=%v, want empty lists", upstream, downstream, err)
+	}
+}
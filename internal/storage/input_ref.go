@@ -0,0 +1,66 @@
+// Package storage 提供数据持久化层的实现。
+// 本文件实现大输入的引用存储：超过阈值的函数输入上传到 S3 兼容对象存储，
+// 执行请求只携带预签名下载 URL，由 Agent 自行下载。
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/oriys/nimbus/internal/config"
+)
+
+// inputRefKeyPrefix 是输入对象键在配置前缀之后的固定前缀，便于为输入单独设置生命周期规则
+const inputRefKeyPrefix = "inputs/"
+
+// S3InputStore 将函数输入保存在 S3 兼容对象存储中，对象键为前缀加输入的 SHA-256 哈希，
+// 相同输入只保存一份。对象不会被主动删除，需在存储桶上为 inputs/ 前缀配置过期规则。
+type S3InputStore struct {
+	client *minio.Client
+	bucket string
+	prefix string
+	expiry time.Duration
+}
+
+// NewS3InputStore 创建基于 S3 兼容对象存储的输入存储，并检查存储桶是否存在。
+//
+// 参数:
+//   - cfg: 对象存储配置
+//   - expiry: 预签名下载 URL 的有效期
+//
+// 返回值:
+//   - *S3InputStore: 输入存储
+//   - error: 配置不完整、无法访问或存储桶不存在时返回错误
+func NewS3InputStore(cfg config.S3Config, expiry time.Duration) (*S3InputStore, error) {
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &S3InputStore{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix + inputRefKeyPrefix, expiry: expiry}, nil
+}
+
+// PutInput 上传输入并返回其预签名下载 URL
+//
+// 参数:
+//   - ctx: 上下文
+//   - input: 函数输入（JSON）
+//
+// 返回值:
+//   - string: 有效期内无需凭证即可下载输入的 URL
+//   - error: 上传或签名失败时返回错误
+func (s *S3InputStore) PutInput(ctx context.Context, input []byte) (string, error) {
+	key := s.prefix + layerContentHash(input)
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(input), int64(len(input)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload input: %w", err)
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.expiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign input url: %w", err)
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,188 @@
+// Package storage 提供数据持久化层的实现。
+// 本文件实现层内容存储：层内容按 SHA-256 哈希寻址，保存在 PostgreSQL 的 layer_blobs 表或 S3 兼容的对象存储中，
+// layer_versions 表只记录哈希和大小，避免大体积的层内容撑大版本表、拖慢查询。
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/oriys/nimbus/internal/config"
+)
+
+// layerBlobTimeout 是读写一份层内容的超时时间，层内容最大可达 100MB
+const layerBlobTimeout = 5 * time.Minute
+
+// ErrLayerBlobNotFound 表示层内容存储中不存在指定哈希的内容
+var ErrLayerBlobNotFound = errors.New("layer blob not found")
+
+// LayerBlobStore 定义按内容哈希存取层内容的存储接口。
+// 内容以哈希寻址，相同内容只保存一份，重复写入是幂等的。
+type LayerBlobStore interface {
+	// Put 保存内容，hash 为内容的 SHA-256 十六进制编码
+	Put(ctx context.Context, hash string, content []byte) error
+	// Get 读取内容，不存在时返回 ErrLayerBlobNotFound
+	Get(ctx context.Context, hash string) ([]byte, error)
+}
+
+// layerContentHash 返回内容的 SHA-256 十六进制编码
+func layerContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// SetLayerBlobStore 设置层内容存储，未设置时使用 PostgreSQL 的 layer_blobs 表。
+// 需在处理请求前调用。
+func (s *PostgresStore) SetLayerBlobStore(blobs LayerBlobStore) {
+	s.layerBlobs = blobs
+}
+
+// layerBlobStore 返回当前使用的层内容存储
+func (s *PostgresStore) layerBlobStore() LayerBlobStore {
+	if s.layerBlobs == nil {
+		return NewPostgresLayerBlobStore(s.db)
+	}
+	return s.layerBlobs
+}
+
+// NewLayerBlobStore 根据配置创建层内容存储。
+//
+// 参数:
+//   - cfg: 层内容存储配置
+//   - db: PostgreSQL 连接，postgres 后端使用
+//
+// 返回值:
+//   - LayerBlobStore: 层内容存储
+//   - error: 后端未知或对象存储不可用时返回错误
+func NewLayerBlobStore(cfg config.LayerBlobConfig, db *sql.DB) (LayerBlobStore, error) {
+	switch cfg.Backend {
+	case "", "postgres":
+		return NewPostgresLayerBlobStore(db), nil
+	case "s3":
+		return NewS3LayerBlobStore(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown layer blob backend: %s", cfg.Backend)
+	}
+}
+
+// ==================== PostgreSQL 实现 ====================
+
+// PostgresLayerBlobStore 将层内容保存在 PostgreSQL 的 layer_blobs 表中
+type PostgresLayerBlobStore struct {
+	db *sql.DB
+}
+
+// NewPostgresLayerBlobStore 创建基于 layer_blobs 表的层内容存储
+func NewPostgresLayerBlobStore(db *sql.DB) *PostgresLayerBlobStore {
+	return &PostgresLayerBlobStore{db: db}
+}
+
+// Put 保存内容，相同哈希的内容已存在时不重复写入
+func (b *PostgresLayerBlobStore) Put(ctx context.Context, hash string, content []byte) error {
+	_, err := b.db.ExecContext(ctx, `
+		INSERT INTO layer_blobs (content_hash, content, size_bytes)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (content_hash) DO NOTHING
+	`, hash, content, len(content))
+	return err
+}
+
+// Get 读取内容
+func (b *PostgresLayerBlobStore) Get(ctx context.Context, hash string) ([]byte, error) {
+	var content []byte
+	err := b.db.QueryRowContext(ctx, "SELECT content FROM layer_blobs WHERE content_hash = $1", hash).Scan(&content)
+	if err == sql.ErrNoRows {
+		return nil, ErrLayerBlobNotFound
+	}
+	return content, err
+}
+
+// ==================== S3 实现 ====================
+
+// S3LayerBlobStore 将层内容保存在 S3 兼容的对象存储中，对象键为前缀加内容哈希
+type S3LayerBlobStore struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3LayerBlobStore 创建基于 S3 兼容对象存储的层内容存储，并检查存储桶是否存在。
+//
+// 参数:
+//   - cfg: 对象存储配置
+//
+// 返回值:
+//   - *S3LayerBlobStore: 层内容存储
+//   - error: 配置不完整、无法访问或存储桶不存在时返回错误
+func NewS3LayerBlobStore(cfg config.S3Config) (*S3LayerBlobStore, error) {
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &S3LayerBlobStore{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+// newS3Client 创建 S3 兼容对象存储的客户端，并检查存储桶是否存在
+func newS3Client(cfg config.S3Config) (*minio.Client, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check s3 bucket: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("s3 bucket %s does not exist", cfg.Bucket)
+	}
+	return client, nil
+}
+
+// key 返回内容哈希对应的对象键
+func (b *S3LayerBlobStore) key(hash string) string {
+	return b.prefix + hash
+}
+
+// Put 上传内容，对象以哈希命名，重复上传会覆盖为相同内容
+func (b *S3LayerBlobStore) Put(ctx context.Context, hash string, content []byte) error {
+	_, err := b.client.PutObject(ctx, b.bucket, b.key(hash), bytes.NewReader(content), int64(len(content)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return err
+}
+
+// Get 下载内容
+func (b *S3LayerBlobStore) Get(ctx context.Context, hash string) ([]byte, error) {
+	obj, err := b.client.GetObject(ctx, b.bucket, b.key(hash), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	content, err := io.ReadAll(obj)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrLayerBlobNotFound
+		}
+		return nil, err
+	}
+	return content, nil
+}
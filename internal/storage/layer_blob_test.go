@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+)
+
+// layerContentColumns 是读取层版本内容时选择的列
+var layerContentColumns = []string{"content_hash", "content"}
+
+// newLayerTestStore 返回使用脚本化 SQL 驱动和指定层内容存储的 PostgresStore
+func newLayerTestStore(t *testing.T, db *sqltest.DB, blobs LayerBlobStore) *PostgresStore {
+	t.Helper()
+	store := newSQLTestStore(t, db)
+	store.SetLayerBlobStore(blobs)
+	return store
+}
+
+// mockLayerBlobStore 是内存中的层内容存储，记录读写的哈希
+type mockLayerBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	gets  []string
+}
+
+func newMockLayerBlobStore() *mockLayerBlobStore {
+	return &mockLayerBlobStore{blobs: make(map[string][]byte)}
+}
+
+func (m *mockLayerBlobStore) Put(ctx context.Context, hash string, content []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[hash] = append([]byte(nil), content...)
+	return nil
+}
+
+func (m *mockLayerBlobStore) Get(ctx context.Context, hash string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gets = append(m.gets, hash)
+	content, ok := m.blobs[hash]
+	if !ok {
+		return nil, ErrLayerBlobNotFound
+	}
+	return content, nil
+}
+
+func TestLayerVersionContentRoundTripsThroughBlobStore(t *testing.T) {
+	blobs := newMockLayerBlobStore()
+	db := sqltest.New()
+	store := newLayerTestStore(t, db, blobs)
+
+	content := []byte("layer archive bytes")
+	lv := &domain.LayerVersion{LayerID: "layer-1", Version: 1}
+	if err := store.CreateLayerVersion(lv, content); err != nil {
+		t.Fatalf("CreateLayerVersion() error = %v", err)
+	}
+	hash := layerContentHash(content)
+	if lv.ContentHash != hash || lv.SizeBytes != int64(len(content)) {
+		t.Errorf("layer version = (%s, %d), want (%s, %d)", lv.ContentHash, lv.SizeBytes, hash, len(content))
+	}
+	if got := string(blobs.blobs[hash]); got != string(content) {
+		t.Errorf("blob store content = %q, want %q", got, content)
+	}
+	// 内容只写入层内容存储，数据库只保存哈希和大小
+	insert := db.Find("INSERT INTO layer_versions")[0].Values()
+	if _, ok := insert["content"]; ok || insert["content_hash"] != hash || insert["size_bytes"] != int64(len(content)) {
+		t.Errorf("insert values = %v, want the hash and size without inline content", insert)
+	}
+
+	db.On("FROM layer_versions WHERE layer_id = $1 AND version = $2",
+		sqltest.Rows(layerContentColumns, []driver.Value{hash, nil}),
+		sqltest.Rows(layerContentColumns),
+	)
+	got, err := store.GetLayerVersionContent("layer-1", 1)
+	if err != nil {
+		t.Fatalf("GetLayerVersionContent() error = %v", err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("content = %q, want %q", got, content)
+	}
+	if args := db.Find("FROM layer_versions WHERE layer_id = $1")[0].Args; args[0] != "layer-1" || args[1] != int64(1) {
+		t.Errorf("content query args = %v, want [layer-1 1]", args)
+	}
+	if len(blobs.gets) != 1 || blobs.gets[0] != hash {
+		t.Errorf("blob store gets = %v, want [%s]", blobs.gets, hash)
+	}
+
+	if _, err := store.GetLayerVersionContent("layer-1", 2); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing version error = %v", err)
+	}
+}
+
+func TestLayerVersionContentRejectsCorruptBlob(t *testing.T) {
+	blobs := newMockLayerBlobStore()
+	content := []byte("original")
+	hash := layerContentHash(content)
+	blobs.blobs[hash] = []byte("tampered")
+	db := sqltest.New().On("FROM layer_versions WHERE layer_id = $1 AND version = $2",
+		sqltest.Rows(layerContentColumns, []driver.Value{hash, nil}))
+
+	if _, err := newLayerTestStore(t, db, blobs).GetLayerVersionContent("layer-1", 1); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Errorf("expected hash mismatch error, got %v", err)
+	}
+}
+
+func TestLayerVersionContentReadsLegacyInlineContent(t *testing.T) {
+	blobs := newMockLayerBlobStore()
+	// 迁移前写入的版本内容保存在 content 列中
+	content := []byte("legacy")
+	db := sqltest.New().On("FROM layer_versions WHERE layer_id = $1 AND version = $2",
+		sqltest.Rows(layerContentColumns, []driver.Value{layerContentHash(content), content}))
+
+	got, err := newLayerTestStore(t, db, blobs).GetLayerVersionContent("layer-1", 1)
+	if err != nil || string(got) != "legacy" {
+		t.Fatalf("GetLayerVersionContent() = %q, %v", got, err)
+	}
+	if len(blobs.gets) != 0 {
+		t.Errorf("blob store should not be read for inline content, gets = %v", blobs.gets)
+	}
+}
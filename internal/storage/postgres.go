@@ -14,10 +14,12 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq" // PostgreSQL 驱动
+	"github.com/oriys/nimbus/internal/auth"
 	"github.com/oriys/nimbus/internal/config"
 	"github.com/oriys/nimbus/internal/domain"
 )
@@ -25,7 +27,11 @@ import (
 // PostgresStore 是 PostgreSQL 存储的封装结构体。
 // 提供函数、调用记录和 API 密钥的持久化存储功能。
 type PostgresStore struct {
-	db *sql.DB // 数据库连接池
+	db         *sql.DB        // 数据库连接池
+	layerBlobs LayerBlobStore // 层内容存储，为空时使用 layer_blobs 表
+
+	aliasMu           sync.RWMutex
+	aliasChangedHooks []func(functionID, aliasName string) // 别名路由配置变更后的回调
 }
 
 // NewPostgresStore 创建并初始化一个新的 PostgreSQL 存储实例。
@@ -557,6 +563,11 @@ func (s *PostgresStore) migrate() error {
 		`ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS paused_input JSONB`,
 		`ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS paused_at TIMESTAMP WITH TIME ZONE`,
 
+		// 添加 Wait 状态定时唤醒字段到 workflow_executions
+		`ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS wait_until TIMESTAMP WITH TIME ZONE`,
+		`ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS wait_input JSONB`,
+		`CREATE INDEX IF NOT EXISTS idx_workflow_executions_wait_until ON workflow_executions(wait_until) WHERE status = 'waiting'`,
+
 		// 创建 function_snapshots 表 - 存储函数级快照
 		`CREATE TABLE IF NOT EXISTS function_snapshots (
 			id VARCHAR(36) PRIMARY KEY,
@@ -581,6 +592,10 @@ func (s *PostgresStore) migrate() error {
 		`CREATE INDEX IF NOT EXISTS idx_snapshots_function_id ON function_snapshots(function_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_snapshots_status ON function_snapshots(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_snapshots_expires_at ON function_snapshots(expires_at)`,
+		// 内容寻址：代码、环境变量、运行时和内存相同的快照共享同一份文件，
+		// 记录通过 content_key 引用共享内容，引用数降为 0 时才删除文件
+		`ALTER TABLE function_snapshots ADD COLUMN IF NOT EXISTS content_key VARCHAR(64)`,
+		`CREATE INDEX IF NOT EXISTS idx_snapshots_content_key ON function_snapshots(content_key)`,
 
 		// 创建 function_dependencies 表 - 存储函数依赖关系
 		`CREATE TABLE IF NOT EXISTS function_dependencies (
@@ -595,6 +610,180 @@ func (s *PostgresStore) migrate() error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_deps_source_id ON function_dependencies(source_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_deps_target_id ON function_dependencies(target_id)`,
+
+		// ==================== 调用结果目标 ====================
+		// 创建 function_destinations 表 - 存储函数的结果投递配置
+		`CREATE TABLE IF NOT EXISTS function_destinations (
+			function_id VARCHAR(36) PRIMARY KEY REFERENCES functions(id) ON DELETE CASCADE,
+			on_success JSONB,
+			on_failure JSONB,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// ==================== 环境默认变量 ====================
+		// 为 environments 表添加环境级默认环境变量
+		`ALTER TABLE environments ADD COLUMN IF NOT EXISTS default_env_vars JSONB NOT NULL DEFAULT '{}'`,
+
+		// ==================== 节点选择 ====================
+		// 为 functions 表添加节点选择器
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS node_selector JSONB`,
+		// 创建 nodes 表 - 记录已注册的节点及其标签
+		`CREATE TABLE IF NOT EXISTS nodes (
+			name VARCHAR(255) PRIMARY KEY,
+			labels JSONB NOT NULL DEFAULT '{}',
+			last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// ==================== 调用决策记录 ====================
+		// 为 invocations 表添加执行路径决策记录
+		`ALTER TABLE invocations ADD COLUMN IF NOT EXISTS decision_trace JSONB`,
+
+		// ==================== 函数所有者 ====================
+		// 为 functions 表添加所有者，用于按所有者的访问控制和"我的函数"视图
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS owner_id VARCHAR(255)`,
+		`CREATE INDEX IF NOT EXISTS idx_functions_owner_id ON functions(owner_id)`,
+
+		// ==================== 冷启动预热策略 ====================
+		// 为 functions 表添加预热策略和 scheduled 策略的预热计划
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS warmup_strategy VARCHAR(32) NOT NULL DEFAULT 'none'`,
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS warmup_schedule VARCHAR(100) NOT NULL DEFAULT ''`,
+
+		// ==================== 调用记录条数上限 ====================
+		// 为 functions 表添加单函数调用记录保留条数上限，0 表示不限制
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS max_invocation_records INTEGER NOT NULL DEFAULT 0`,
+		// 按条数裁剪时是否优先保留失败的调用记录
+		`INSERT INTO system_settings (key, value, description)
+		 SELECT 'invocation_trim_preserve_failed', 'false', '按条数裁剪调用记录时优先保留失败记录'
+		 WHERE NOT EXISTS (SELECT 1 FROM system_settings WHERE key = 'invocation_trim_preserve_failed')`,
+
+		// ==================== 金丝雀发布 ====================
+		// 记录调用实际执行的版本，用于按版本对比指标
+		`ALTER TABLE invocations ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_invocations_function_version ON invocations(function_id, version, created_at)`,
+		// 创建 canary_deployments 表 - 存储金丝雀发布的状态和决策记录
+		`CREATE TABLE IF NOT EXISTS canary_deployments (
+			id VARCHAR(36) PRIMARY KEY,
+			function_id VARCHAR(36) NOT NULL REFERENCES functions(id) ON DELETE CASCADE,
+			alias_name VARCHAR(64) NOT NULL,
+			stable_version INTEGER NOT NULL,
+			canary_version INTEGER NOT NULL,
+			weight INTEGER NOT NULL,
+			step_weight INTEGER NOT NULL,
+			healthy_duration_sec INTEGER NOT NULL,
+			min_invocations INTEGER NOT NULL,
+			max_error_rate_delta DOUBLE PRECISION NOT NULL,
+			max_latency_ratio DOUBLE PRECISION NOT NULL,
+			status VARCHAR(32) NOT NULL,
+			step_started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			healthy_since TIMESTAMP WITH TIME ZONE,
+			decisions JSONB NOT NULL DEFAULT '[]',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_canary_deployments_function_id ON canary_deployments(function_id)`,
+		// 同一别名同时只允许一个进行中的金丝雀发布
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_canary_deployments_running ON canary_deployments(function_id, alias_name) WHERE status = 'running'`,
+
+		// ==================== 调用方身份传递 ====================
+		// 为 functions 表添加身份传递开关，为 invocations 表记录调用方身份引用（不存储令牌）
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS propagate_identity BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE invocations ADD COLUMN IF NOT EXISTS caller_id VARCHAR(255)`,
+		`ALTER TABLE invocations ADD COLUMN IF NOT EXISTS caller_role VARCHAR(64)`,
+
+		// ==================== 调用重放 ====================
+		// 重放产生的调用记录指向被重放的原始调用
+		`ALTER TABLE invocations ADD COLUMN IF NOT EXISTS replay_of VARCHAR(36)`,
+
+		// ==================== 函数间调用链 ====================
+		// 记录发起调用的函数链，异步调用和结果投递据此继续传递并拒绝循环调用
+		`ALTER TABLE invocations ADD COLUMN IF NOT EXISTS call_chain TEXT[]`,
+
+		// ==================== 调用记录游标分页 ====================
+		// 函数调用记录按 (created_at, id) 倒序做键集分页，id 使创建时间相同的记录也有确定的顺序
+		`CREATE INDEX IF NOT EXISTS idx_invocations_function_created_id ON invocations(function_id, created_at DESC, id DESC)`,
+
+		// ==================== 死信自动重试 ====================
+		// 第 n 次自动重试在上次尝试后 base * 2^n 秒执行，超过最大次数后丢弃，0 表示关闭自动重试
+		`INSERT INTO system_settings (key, value, description)
+		 SELECT 'dlq_retry_base_delay_seconds', '60', '死信自动重试的基础退避时间（秒）'
+		 WHERE NOT EXISTS (SELECT 1 FROM system_settings WHERE key = 'dlq_retry_base_delay_seconds')`,
+		`INSERT INTO system_settings (key, value, description)
+		 SELECT 'dlq_retry_max_attempts', '5', '死信最大重试次数，0 表示关闭自动重试'
+		 WHERE NOT EXISTS (SELECT 1 FROM system_settings WHERE key = 'dlq_retry_max_attempts')`,
+
+		// ==================== 函数快照保留时长 ====================
+		// 为 functions 表添加单函数快照保留时长（小时），0 表示使用全局 snapshot_ttl
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS snapshot_ttl_hours INTEGER NOT NULL DEFAULT 0`,
+
+		// ==================== 函数 IO 限速 ====================
+		// 为 functions 表添加虚拟机磁盘和网络限速配置，NULL 表示使用运行时默认值
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS io_limits JSONB`,
+
+		// ==================== 函数输入 Schema ====================
+		// 为 functions 表添加调用输入的 JSON Schema，NULL 表示不校验输入
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS input_schema JSONB`,
+
+		// ==================== API 密钥权限范围 ====================
+		// 为 api_keys 表添加权限范围（如 functions:read），NULL 或空数组表示不受限制
+		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[]`,
+
+		// ==================== 层内容存储 ====================
+		// 层内容改为按哈希保存在 LayerBlobStore 中，layer_versions 只记录哈希和大小；
+		// 之前写入的版本内容仍保留在 content 列中并可读取
+		`ALTER TABLE layer_versions ALTER COLUMN content DROP NOT NULL`,
+		`CREATE TABLE IF NOT EXISTS layer_blobs (
+			content_hash VARCHAR(64) PRIMARY KEY,
+			content BYTEA NOT NULL,
+			size_bytes BIGINT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// ==================== 保留策略自动清理 ====================
+		// 保留策略管理器按该间隔自动清理过期数据，0 表示关闭自动清理
+		`INSERT INTO system_settings (key, value, description)
+		 SELECT 'retention_cleanup_interval_hours', '24', '自动清理过期数据的间隔（小时），0 表示关闭自动清理'
+		 WHERE NOT EXISTS (SELECT 1 FROM system_settings WHERE key = 'retention_cleanup_interval_hours')`,
+		`INSERT INTO system_settings (key, value, description)
+		 SELECT 'audit_retention_days', '180', '审计日志保留天数'
+		 WHERE NOT EXISTS (SELECT 1 FROM system_settings WHERE key = 'audit_retention_days')`,
+
+		// ==================== 调用限流 ====================
+		// 每个调用方对每个函数一个令牌桶，函数可通过 rate_limit 列覆盖，rps 为 0 表示不限流
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS rate_limit JSONB`,
+
+		// ==================== 定时任务时区 ====================
+		// cron_timezone 为空表示网关所在时区；cron_last_fired_at 记录最近一次触发的计划时间，
+		// 多个网关实例或重启后对同一计划时间只触发一次
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS cron_timezone VARCHAR(64) NOT NULL DEFAULT ''`,
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS cron_last_fired_at TIMESTAMPTZ`,
+
+		// ==================== 结果缓存 ====================
+		// cacheable 的函数按版本和输入缓存成功结果，cache_ttl_sec 为 0 表示使用默认有效期；
+		// cache_hit 标记直接由缓存返回、没有实际执行的调用
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS cacheable BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS cache_ttl_sec INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE invocations ADD COLUMN IF NOT EXISTS cache_hit BOOLEAN NOT NULL DEFAULT FALSE`,
+
+		// ==================== 预热函数 ====================
+		// warmup_handler 在虚拟机初始化函数后调用一次，为空表示不预热；warmup_timeout_sec 为 0 表示使用默认超时
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS warmup_handler VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS warmup_timeout_sec INTEGER NOT NULL DEFAULT 0`,
+
+		// ==================== 依赖安装 ====================
+		// install_deps 的函数在虚拟机初始化时安装代码包声明的依赖，install_deps_timeout_sec 为 0 表示使用 Agent 的默认超时
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS install_deps BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS install_deps_timeout_sec INTEGER NOT NULL DEFAULT 0`,
+		`INSERT INTO system_settings (key, value, description)
+		 SELECT 'rate_limit_rps', '0', '每个调用方对每个函数的每秒调用数上限，0 表示不限流'
+		 WHERE NOT EXISTS (SELECT 1 FROM system_settings WHERE key = 'rate_limit_rps')`,
+		`INSERT INTO system_settings (key, value, description)
+		 SELECT 'rate_limit_burst', '0', '限流令牌桶容量（允许的突发调用数），0 表示取 rate_limit_rps 向上取整'
+		 WHERE NOT EXISTS (SELECT 1 FROM system_settings WHERE key = 'rate_limit_burst')`,
+
+		// ==================== 创建函数幂等键 ====================
+		// 记录创建函数请求的 Idempotency-Key，客户端重试同一请求时返回已创建的函数
+		`ALTER TABLE functions ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(128)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_functions_idempotency_key ON functions(idempotency_key) WHERE idempotency_key IS NOT NULL`,
 	}
 
 	// 依次执行所有迁移语句
@@ -624,6 +813,16 @@ func (s *PostgresStore) DB() *sql.DB {
 
 // ==================== 函数仓库实现 ====================
 
+// functionColumns 是查询函数时选择的列，顺序与 scanFunction/scanFunctionRow 的扫描目标一致。
+// 所有函数查询共用该列表，新增列时只需同时修改这里和扫描函数。
+const functionColumns = `id, name, description, tags, pinned, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, max_concurrency, env_vars, status, status_message, task_id, version, cron_expression, http_path, http_methods, webhook_enabled, webhook_key, last_deployed_at, state_config, node_selector, warmup_strategy, warmup_schedule, owner_id, max_invocation_records, propagate_identity, snapshot_ttl_hours, io_limits, input_schema, rate_limit, cron_timezone, cacheable, cache_ttl_sec, warmup_handler, warmup_timeout_sec, install_deps, install_deps_timeout_sec, created_at, updated_at`
+
+// sqlExecutor 是 *sql.DB 和 *sql.Tx 共有的执行和查询方法，使写入语句可以在事务内外复用
+type sqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // CreateFunction 创建一个新的函数记录。
 // 如果未提供 ID，将自动生成 UUID。
 //
@@ -633,37 +832,129 @@ func (s *PostgresStore) DB() *sql.DB {
 // 返回值:
 //   - error: 创建失败时返回错误信息（如名称重复）
 func (s *PostgresStore) CreateFunction(fn *domain.Function) error {
+	_, err := s.insertFunction(s.db, fn, "")
+	return err
+}
+
+// insertFunction 使用 db 插入函数记录，conflict 为追加在 INSERT 语句后的冲突处理子句（可为空）。
+// 返回值中的 sql.Result 可用于判断冲突时是否跳过了插入。
+func (s *PostgresStore) insertFunction(db sqlExecutor, fn *domain.Function, conflict string) (sql.Result, error) {
 	// 自动生成 ID（如果未提供）
 	if fn.ID == "" {
 		fn.ID = uuid.New().String()
 	}
 	fn.CreatedAt = time.Now()
 	fn.UpdatedAt = fn.CreatedAt
+	if fn.WarmupStrategy == "" {
+		fn.WarmupStrategy = domain.WarmupNone
+	}
 
 	// 将环境变量序列化为 JSON
 	envVarsJSON, _ := json.Marshal(fn.EnvVars)
 	httpMethodsJSON, _ := json.Marshal(fn.HTTPMethods)
+	nodeSelectorJSON := nodeSelectorValue(fn.NodeSelector)
+	ioLimitsJSON := ioLimitsValue(fn.IOLimits)
+	inputSchemaJSON := inputSchemaValue(fn.InputSchema)
+	rateLimitJSON := rateLimitValue(fn.RateLimit)
 
-	// 处理 WebhookKey：空字符串转为 NULL，避免 UNIQUE 约束冲突
-	var webhookKey interface{}
+	// 未指定所有者时写入 NULL
+	var ownerID interface{}
+	if fn.OwnerID != "" {
+		ownerID = fn.OwnerID
+	}
+
+	// 处理 WebhookKey 和幂等键：空字符串转为 NULL，避免 UNIQUE 约束冲突
+	var webhookKey, idempotencyKey interface{}
 	if fn.WebhookKey != "" {
 		webhookKey = fn.WebhookKey
 	}
+	if fn.IdempotencyKey != "" {
+		idempotencyKey = fn.IdempotencyKey
+	}
 
 	// SQL: 插入函数记录到 functions 表
 	query := `
-		INSERT INTO functions (id, name, description, tags, pinned, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, max_concurrency, env_vars, status, status_message, task_id, version, cron_expression, http_path, http_methods, webhook_enabled, webhook_key, last_deployed_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)
-	`
-	_, err := s.db.Exec(query,
+		INSERT INTO functions (id, name, description, tags, pinned, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, max_concurrency, env_vars, status, status_message, task_id, version, cron_expression, http_path, http_methods, webhook_enabled, webhook_key, last_deployed_at, node_selector, warmup_strategy, warmup_schedule, owner_id, max_invocation_records, propagate_identity, snapshot_ttl_hours, io_limits, input_schema, rate_limit, cron_timezone, cacheable, cache_ttl_sec, warmup_handler, warmup_timeout_sec, install_deps, install_deps_timeout_sec, idempotency_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38, $39, $40, $41, $42, $43, $44)
+	` + conflict
+	result, err := db.Exec(query,
 		fn.ID, fn.Name, fn.Description, pq.Array(fn.Tags), fn.Pinned, fn.Runtime, fn.Handler, fn.Code, fn.Binary, fn.CodeHash,
 		fn.MemoryMB, fn.TimeoutSec, fn.MaxConcurrency, envVarsJSON, fn.Status, fn.StatusMessage, fn.TaskID, fn.Version,
-		fn.CronExpression, fn.HTTPPath, httpMethodsJSON, fn.WebhookEnabled, webhookKey, fn.LastDeployedAt, fn.CreatedAt, fn.UpdatedAt,
+		fn.CronExpression, fn.HTTPPath, httpMethodsJSON, fn.WebhookEnabled, webhookKey, fn.LastDeployedAt, nodeSelectorJSON,
+		fn.WarmupStrategy, fn.WarmupSchedule, ownerID, fn.MaxInvocationRecords, fn.PropagateIdentity, fn.SnapshotTTLHours, ioLimitsJSON, inputSchemaJSON, rateLimitJSON, fn.CronTimezone, fn.Cacheable, fn.CacheTTLSec, fn.WarmupHandler, fn.WarmupTimeoutSec, fn.InstallDeps, fn.InstallDepsTimeoutSec, idempotencyKey, fn.CreatedAt, fn.UpdatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create function: %w", err)
+		return nil, fmt.Errorf("failed to create function: %w", err)
 	}
-	return nil
+	return result, nil
+}
+
+// UpsertFunction 按名称幂等地创建或更新函数，适用于 GitOps/CI 等重复部署的场景。
+// 名称不存在时插入新记录；已存在时在同一事务中锁定该行，将 fn 的可修改字段
+// （处理程序、代码、环境变量、资源限制等）合并到已有记录并递增版本号。
+// 代码或处理程序发生变化时，与 UpdateFunction 接口一样在同一事务中保存一条版本记录，
+// 之后可按版本号调用或回滚。成功后 fn 被填充为写入后的完整记录。
+//
+// 参数:
+//   - fn: 函数对象，按 Name 匹配已有记录
+//
+// 返回值:
+//   - bool: true 表示新建，false 表示更新了已有函数
+//   - error: 运行时与已有函数不一致时返回 domain.ErrInvalidRuntime，其他错误返回相应信息
+func (s *PostgresStore) UpsertFunction(fn *domain.Function) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// 名称冲突时跳过插入，由并发的另一方插入的记录在下面的加锁查询中可见
+	result, err := s.insertFunction(tx, fn, "ON CONFLICT (name) DO NOTHING")
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if inserted == 0 {
+		// SQL: 锁定已存在的同名函数，防止并发更新互相覆盖
+		query := `
+			SELECT ` + functionColumns + `
+			FROM functions WHERE name = $1 FOR UPDATE
+		`
+		existing, err := s.scanFunction(tx.QueryRow(query, fn.Name))
+		if err != nil {
+			return false, err
+		}
+		if err := MergeUpsertFunction(existing, fn); err != nil {
+			return false, err
+		}
+		if err := s.updateFunction(tx, fn); err != nil {
+			return false, err
+		}
+		if codeChanged(existing, fn) {
+			latest, err := latestFunctionVersion(tx, fn.ID)
+			if err != nil {
+				return false, err
+			}
+			if err := createFunctionVersion(tx, &domain.FunctionVersion{
+				FunctionID:  fn.ID,
+				Version:     latest + 1,
+				Handler:     fn.Handler,
+				Code:        fn.Code,
+				Binary:      fn.Binary,
+				CodeHash:    fn.CodeHash,
+				Description: "Auto-saved version",
+			}); err != nil {
+				return false, fmt.Errorf("failed to create function version: %w", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
 }
 
 // GetFunctionByID 根据函数 ID 获取函数详情。
@@ -677,7 +968,7 @@ func (s *PostgresStore) CreateFunction(fn *domain.Function) error {
 func (s *PostgresStore) GetFunctionByID(id string) (*domain.Function, error) {
 	// SQL: 根据 ID 查询函数的所有字段
 	query := `
-		SELECT id, name, description, tags, pinned, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, max_concurrency, env_vars, status, status_message, task_id, version, cron_expression, http_path, http_methods, webhook_enabled, webhook_key, last_deployed_at, state_config, created_at, updated_at
+		SELECT ` + functionColumns + `
 		FROM functions WHERE id = $1
 	`
 	return s.scanFunction(s.db.QueryRow(query, id))
@@ -694,7 +985,7 @@ func (s *PostgresStore) GetFunctionByID(id string) (*domain.Function, error) {
 func (s *PostgresStore) GetFunctionByName(name string) (*domain.Function, error) {
 	// SQL: 根据名称查询函数的所有字段
 	query := `
-		SELECT id, name, description, tags, pinned, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, max_concurrency, env_vars, status, status_message, task_id, version, cron_expression, http_path, http_methods, webhook_enabled, webhook_key, last_deployed_at, state_config, created_at, updated_at
+		SELECT ` + functionColumns + `
 		FROM functions WHERE name = $1
 	`
 	return s.scanFunction(s.db.QueryRow(query, name))
@@ -711,12 +1002,29 @@ func (s *PostgresStore) GetFunctionByName(name string) (*domain.Function, error)
 func (s *PostgresStore) GetFunctionByWebhookKey(webhookKey string) (*domain.Function, error) {
 	// SQL: 根据 Webhook 密钥查询函数的所有字段
 	query := `
-		SELECT id, name, description, tags, pinned, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, max_concurrency, env_vars, status, status_message, task_id, version, cron_expression, http_path, http_methods, webhook_enabled, webhook_key, last_deployed_at, state_config, created_at, updated_at
+		SELECT ` + functionColumns + `
 		FROM functions WHERE webhook_key = $1 AND webhook_enabled = TRUE
 	`
 	return s.scanFunction(s.db.QueryRow(query, webhookKey))
 }
 
+// GetFunctionByIdempotencyKey 根据创建请求的幂等键获取函数详情。
+//
+// 参数:
+//   - key: 创建函数时携带的 Idempotency-Key
+//
+// 返回值:
+//   - *domain.Function: 用该幂等键创建的函数
+//   - error: 函数不存在时返回 ErrFunctionNotFound，其他错误返回相应信息
+func (s *PostgresStore) GetFunctionByIdempotencyKey(key string) (*domain.Function, error) {
+	// SQL: 根据幂等键查询函数的所有字段
+	query := `
+		SELECT ` + functionColumns + `
+		FROM functions WHERE idempotency_key = $1
+	`
+	return s.scanFunction(s.db.QueryRow(query, key))
+}
+
 // ListFunctions 分页查询函数列表。
 //
 // 参数:
@@ -737,7 +1045,7 @@ func (s *PostgresStore) ListFunctions(offset, limit int) ([]*domain.Function, in
 
 	// SQL: 分页查询函数列表，置顶函数优先，按创建时间倒序排列
 	query := `
-		SELECT id, name, description, tags, pinned, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, max_concurrency, env_vars, status, status_message, task_id, version, cron_expression, http_path, http_methods, webhook_enabled, webhook_key, last_deployed_at, state_config, created_at, updated_at
+		SELECT ` + functionColumns + `
 		FROM functions ORDER BY pinned DESC, created_at DESC LIMIT $1 OFFSET $2
 	`
 	rows, err := s.db.Query(query, limit, offset)
@@ -819,7 +1127,7 @@ func (s *PostgresStore) ListFunctionsWithFilter(filter *domain.FunctionFilter, o
 
 	// SQL: 分页查询函数列表，置顶函数优先，按更新时间倒序排列
 	selectQuery := fmt.Sprintf(`
-		SELECT id, name, description, tags, pinned, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, max_concurrency, env_vars, status, status_message, task_id, version, cron_expression, http_path, http_methods, webhook_enabled, webhook_key, last_deployed_at, created_at, updated_at
+		SELECT ` + functionColumns + `
 		FROM functions %s ORDER BY pinned DESC, updated_at DESC LIMIT $%d OFFSET $%d
 	`, whereClause, argIndex, argIndex+1)
 	args = append(args, limit, offset)
@@ -850,6 +1158,11 @@ func (s *PostgresStore) ListFunctionsWithFilter(filter *domain.FunctionFilter, o
 // 返回值:
 //   - error: 函数不存在时返回 ErrFunctionNotFound，其他错误返回相应信息
 func (s *PostgresStore) UpdateFunction(fn *domain.Function) error {
+	return s.updateFunction(s.db, fn)
+}
+
+// updateFunction 使用 db 执行 UpdateFunction 的更新语句，供事务内复用
+func (s *PostgresStore) updateFunction(db sqlExecutor, fn *domain.Function) error {
 	fn.UpdatedAt = time.Now()
 	fn.Version++ // 递增版本号
 
@@ -861,6 +1174,13 @@ func (s *PostgresStore) UpdateFunction(fn *domain.Function) error {
 	if fn.StateConfig != nil {
 		stateConfigJSON, _ = json.Marshal(fn.StateConfig)
 	}
+	nodeSelectorJSON := nodeSelectorValue(fn.NodeSelector)
+	ioLimitsJSON := ioLimitsValue(fn.IOLimits)
+	inputSchemaJSON := inputSchemaValue(fn.InputSchema)
+	rateLimitJSON := rateLimitValue(fn.RateLimit)
+	if fn.WarmupStrategy == "" {
+		fn.WarmupStrategy = domain.WarmupNone
+	}
 
 	// 处理 WebhookKey：空字符串转为 NULL，避免 UNIQUE 约束冲突
 	var webhookKey interface{}
@@ -873,13 +1193,19 @@ func (s *PostgresStore) UpdateFunction(fn *domain.Function) error {
 		UPDATE functions SET
 			description = $2, tags = $3, pinned = $4, handler = $5, code = $6, "binary" = $7, code_hash = $8,
 			memory_mb = $9, timeout_sec = $10, max_concurrency = $11, env_vars = $12, status = $13, status_message = $14, task_id = $15,
-			version = $16, cron_expression = $17, http_path = $18, http_methods = $19, webhook_enabled = $20, webhook_key = $21, last_deployed_at = $22, state_config = $23, updated_at = $24
+			version = $16, cron_expression = $17, http_path = $18, http_methods = $19, webhook_enabled = $20, webhook_key = $21, last_deployed_at = $22, state_config = $23, updated_at = $24,
+			node_selector = $25, warmup_strategy = $26, warmup_schedule = $27, max_invocation_records = $28,
+			propagate_identity = $29, snapshot_ttl_hours = $30, io_limits = $31, input_schema = $32, rate_limit = $33, cron_timezone = $34,
+			cacheable = $35, cache_ttl_sec = $36, warmup_handler = $37, warmup_timeout_sec = $38,
+			install_deps = $39, install_deps_timeout_sec = $40
 		WHERE id = $1
 	`
-	result, err := s.db.Exec(query,
+	result, err := db.Exec(query,
 		fn.ID, fn.Description, pq.Array(fn.Tags), fn.Pinned, fn.Handler, fn.Code, fn.Binary, fn.CodeHash,
 		fn.MemoryMB, fn.TimeoutSec, fn.MaxConcurrency, envVarsJSON, fn.Status, fn.StatusMessage, fn.TaskID,
 		fn.Version, fn.CronExpression, fn.HTTPPath, httpMethodsJSON, fn.WebhookEnabled, webhookKey, fn.LastDeployedAt, stateConfigJSON, fn.UpdatedAt,
+		nodeSelectorJSON, fn.WarmupStrategy, fn.WarmupSchedule, fn.MaxInvocationRecords, fn.PropagateIdentity, fn.SnapshotTTLHours,
+		ioLimitsJSON, inputSchemaJSON, rateLimitJSON, fn.CronTimezone, fn.Cacheable, fn.CacheTTLSec, fn.WarmupHandler, fn.WarmupTimeoutSec, fn.InstallDeps, fn.InstallDepsTimeoutSec,
 	)
 	if err != nil {
 		return err
@@ -935,7 +1261,7 @@ func (s *PostgresStore) GetFunctionsByStatuses(statuses []string) ([]*domain.Fun
 	}
 
 	query := `
-		SELECT id, name, description, tags, pinned, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, max_concurrency, env_vars, status, status_message, task_id, version, cron_expression, http_path, http_methods, webhook_enabled, webhook_key, last_deployed_at, created_at, updated_at
+		SELECT ` + functionColumns + `
 		FROM functions WHERE status = ANY($1)
 	`
 	rows, err := s.db.Query(query, pq.Array(statuses))
@@ -976,6 +1302,7 @@ func (s *PostgresStore) DeleteFunction(id string) error {
 	if affected == 0 {
 		return domain.ErrFunctionNotFound
 	}
+	s.notifyAliasChanged(id, "") // 别名随函数级联删除
 	return nil
 }
 
@@ -983,12 +1310,36 @@ func (s *PostgresStore) DeleteFunction(id string) error {
 func (s *PostgresStore) GetFunctionByPath(path string) (*domain.Function, error) {
 	// SQL: 根据 http_path 查询函数
 	query := `
-		SELECT id, name, description, runtime, handler, code, "binary", code_hash, memory_mb, timeout_sec, env_vars, status, version, cron_expression, http_path, http_methods, created_at, updated_at
+		SELECT ` + functionColumns + `
 		FROM functions WHERE http_path = $1
 	`
 	return s.scanFunction(s.db.QueryRow(query, path))
 }
 
+// GetFunctionByPathAndMethod 根据自定义 HTTP 路径和请求方法获取函数。
+// 函数设置了 http_methods 时，方法必须在列表中（不区分大小写）；未设置时允许任意方法。
+//
+// 参数:
+//   - path: 自定义 HTTP 路径
+//   - method: HTTP 请求方法，如 GET、POST
+//
+// 返回值:
+//   - *domain.Function: 函数对象
+//   - error: 路径不存在或方法不被允许时返回 ErrFunctionNotFound，其他错误返回相应信息
+func (s *PostgresStore) GetFunctionByPathAndMethod(path, method string) (*domain.Function, error) {
+	// SQL: 根据 http_path 查询函数，并检查方法是否包含在 http_methods 数组中
+	// http_methods 可能为 NULL 或 JSON null（非数组），用 CASE 保证只对数组展开
+	query := `
+		SELECT ` + functionColumns + `
+		FROM functions WHERE http_path = $1 AND CASE
+			WHEN jsonb_typeof(http_methods) = 'array' AND jsonb_array_length(http_methods) > 0
+			THEN EXISTS (SELECT 1 FROM jsonb_array_elements_text(http_methods) AS m WHERE upper(m) = upper($2))
+			ELSE TRUE
+		END
+	`
+	return s.scanFunction(s.db.QueryRow(query, path, method))
+}
+
 // UpdateFunctionPin 更新函数的置顶状态。
 //
 // 参数:
@@ -1024,13 +1375,13 @@ func (s *PostgresStore) UpdateFunctionPin(id string, pinned bool) error {
 //   - error: 扫描失败或记录不存在时返回错误
 func (s *PostgresStore) scanFunction(row *sql.Row) (*domain.Function, error) {
 	fn := &domain.Function{}
-	var envVarsJSON, httpMethodsJSON, stateConfigJSON []byte
-	var description, code, binary, codeHash, cronExpression, httpPath, statusMessage, taskID, webhookKey sql.NullString
+	var envVarsJSON, httpMethodsJSON, stateConfigJSON, nodeSelectorJSON, ioLimitsJSON, inputSchemaJSON, rateLimitJSON []byte
+	var description, code, binary, codeHash, cronExpression, httpPath, statusMessage, taskID, webhookKey, ownerID sql.NullString
 	var lastDeployedAt sql.NullTime
 	err := row.Scan(
 		&fn.ID, &fn.Name, &description, pq.Array(&fn.Tags), &fn.Pinned, &fn.Runtime, &fn.Handler, &code, &binary, &codeHash,
 		&fn.MemoryMB, &fn.TimeoutSec, &fn.MaxConcurrency, &envVarsJSON, &fn.Status, &statusMessage, &taskID, &fn.Version,
-		&cronExpression, &httpPath, &httpMethodsJSON, &fn.WebhookEnabled, &webhookKey, &lastDeployedAt, &stateConfigJSON, &fn.CreatedAt, &fn.UpdatedAt,
+		&cronExpression, &httpPath, &httpMethodsJSON, &fn.WebhookEnabled, &webhookKey, &lastDeployedAt, &stateConfigJSON, &nodeSelectorJSON, &fn.WarmupStrategy, &fn.WarmupSchedule, &ownerID, &fn.MaxInvocationRecords, &fn.PropagateIdentity, &fn.SnapshotTTLHours, &ioLimitsJSON, &inputSchemaJSON, &rateLimitJSON, &fn.CronTimezone, &fn.Cacheable, &fn.CacheTTLSec, &fn.WarmupHandler, &fn.WarmupTimeoutSec, &fn.InstallDeps, &fn.InstallDepsTimeoutSec, &fn.CreatedAt, &fn.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, domain.ErrFunctionNotFound
@@ -1075,6 +1426,21 @@ func (s *PostgresStore) scanFunction(row *sql.Row) (*domain.Function, error) {
 	if len(stateConfigJSON) > 0 {
 		json.Unmarshal(stateConfigJSON, &fn.StateConfig)
 	}
+	if len(nodeSelectorJSON) > 0 {
+		json.Unmarshal(nodeSelectorJSON, &fn.NodeSelector)
+	}
+	if len(ioLimitsJSON) > 0 {
+		json.Unmarshal(ioLimitsJSON, &fn.IOLimits)
+	}
+	if len(inputSchemaJSON) > 0 {
+		fn.InputSchema = json.RawMessage(inputSchemaJSON)
+	}
+	if len(rateLimitJSON) > 0 {
+		json.Unmarshal(rateLimitJSON, &fn.RateLimit)
+	}
+	if ownerID.Valid {
+		fn.OwnerID = ownerID.String
+	}
 	return fn, nil
 }
 
@@ -1089,13 +1455,13 @@ func (s *PostgresStore) scanFunction(row *sql.Row) (*domain.Function, error) {
 //   - error: 扫描失败时返回错误
 func (s *PostgresStore) scanFunctionRow(rows *sql.Rows) (*domain.Function, error) {
 	fn := &domain.Function{}
-	var envVarsJSON, httpMethodsJSON, stateConfigJSON []byte
-	var description, code, binary, codeHash, cronExpression, httpPath, statusMessage, taskID, webhookKey sql.NullString
+	var envVarsJSON, httpMethodsJSON, stateConfigJSON, nodeSelectorJSON, ioLimitsJSON, inputSchemaJSON, rateLimitJSON []byte
+	var description, code, binary, codeHash, cronExpression, httpPath, statusMessage, taskID, webhookKey, ownerID sql.NullString
 	var lastDeployedAt sql.NullTime
 	err := rows.Scan(
 		&fn.ID, &fn.Name, &description, pq.Array(&fn.Tags), &fn.Pinned, &fn.Runtime, &fn.Handler, &code, &binary, &codeHash,
 		&fn.MemoryMB, &fn.TimeoutSec, &fn.MaxConcurrency, &envVarsJSON, &fn.Status, &statusMessage, &taskID, &fn.Version,
-		&cronExpression, &httpPath, &httpMethodsJSON, &fn.WebhookEnabled, &webhookKey, &lastDeployedAt, &stateConfigJSON, &fn.CreatedAt, &fn.UpdatedAt,
+		&cronExpression, &httpPath, &httpMethodsJSON, &fn.WebhookEnabled, &webhookKey, &lastDeployedAt, &stateConfigJSON, &nodeSelectorJSON, &fn.WarmupStrategy, &fn.WarmupSchedule, &ownerID, &fn.MaxInvocationRecords, &fn.PropagateIdentity, &fn.SnapshotTTLHours, &ioLimitsJSON, &inputSchemaJSON, &rateLimitJSON, &fn.CronTimezone, &fn.Cacheable, &fn.CacheTTLSec, &fn.WarmupHandler, &fn.WarmupTimeoutSec, &fn.InstallDeps, &fn.InstallDepsTimeoutSec, &fn.CreatedAt, &fn.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -1137,6 +1503,21 @@ func (s *PostgresStore) scanFunctionRow(rows *sql.Rows) (*domain.Function, error
 	if len(stateConfigJSON) > 0 {
 		json.Unmarshal(stateConfigJSON, &fn.StateConfig)
 	}
+	if len(nodeSelectorJSON) > 0 {
+		json.Unmarshal(nodeSelectorJSON, &fn.NodeSelector)
+	}
+	if len(ioLimitsJSON) > 0 {
+		json.Unmarshal(ioLimitsJSON, &fn.IOLimits)
+	}
+	if len(inputSchemaJSON) > 0 {
+		fn.InputSchema = json.RawMessage(inputSchemaJSON)
+	}
+	if len(rateLimitJSON) > 0 {
+		json.Unmarshal(rateLimitJSON, &fn.RateLimit)
+	}
+	if ownerID.Valid {
+		fn.OwnerID = ownerID.String
+	}
 	return fn, nil
 }
 
@@ -1158,12 +1539,15 @@ func (s *PostgresStore) CreateInvocation(inv *domain.Invocation) error {
 
 	// SQL: 插入调用记录的初始信息
 	query := `
-		INSERT INTO invocations (id, function_id, function_name, trigger_type, status, input, cold_start, retry_count, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO invocations (id, function_id, function_name, trigger_type, status, input, cold_start, retry_count, version, caller_id, caller_role, replay_of, call_chain, cache_hit, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 	`
 	_, err := s.db.Exec(query,
 		inv.ID, inv.FunctionID, inv.FunctionName, inv.TriggerType, inv.Status,
-		inv.Input, inv.ColdStart, inv.RetryCount, inv.CreatedAt,
+		inv.Input, inv.ColdStart, inv.RetryCount, inv.Version,
+		sql.NullString{String: inv.CallerID, Valid: inv.CallerID != ""}, sql.NullString{String: inv.CallerRole, Valid: inv.CallerRole != ""},
+		sql.NullString{String: inv.ReplayOf, Valid: inv.ReplayOf != ""}, pq.Array(inv.CallChain),
+		inv.CacheHit, inv.CreatedAt,
 	)
 	return err
 }
@@ -1181,19 +1565,19 @@ func (s *PostgresStore) GetInvocationByID(id string) (*domain.Invocation, error)
 	query := `
 		SELECT id, function_id, function_name, trigger_type, status, input, output, error,
 		       cold_start, vm_id, started_at, completed_at, duration_ms, billed_time_ms,
-		       memory_used_mb, retry_count, created_at
+		       memory_used_mb, retry_count, version, caller_id, caller_role, replay_of, call_chain, cache_hit, created_at
 		FROM invocations WHERE id = $1
 	`
 	inv := &domain.Invocation{}
 	// 处理可能为空的字段
 	var vmID sql.NullString
 	var input, output []byte
-	var errStr sql.NullString
+	var errStr, callerID, callerRole, replayOf sql.NullString
 	err := s.db.QueryRow(query, id).Scan(
 		&inv.ID, &inv.FunctionID, &inv.FunctionName, &inv.TriggerType, &inv.Status,
 		&input, &output, &errStr, &inv.ColdStart, &vmID,
 		&inv.StartedAt, &inv.CompletedAt, &inv.DurationMs, &inv.BilledTimeMs,
-		&inv.MemoryUsedMB, &inv.RetryCount, &inv.CreatedAt,
+		&inv.MemoryUsedMB, &inv.RetryCount, &inv.Version, &callerID, &callerRole, &replayOf, pq.Array(&inv.CallChain), &inv.CacheHit, &inv.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, domain.ErrInvocationNotFound
@@ -1214,6 +1598,9 @@ func (s *PostgresStore) GetInvocationByID(id string) (*domain.Invocation, error)
 	if errStr.Valid {
 		inv.Error = errStr.String
 	}
+	inv.CallerID = callerID.String
+	inv.CallerRole = callerRole.String
+	inv.ReplayOf = replayOf.String
 	return inv, nil
 }
 
@@ -1249,6 +1636,63 @@ func (s *PostgresStore) ListInvocationsByFunction(functionID string, offset, lim
 	}
 	defer rows.Close()
 
+	invocations, err := scanInvocationRows(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return invocations, total, nil
+}
+
+// ListInvocationsByFunctionCursor 按游标分页查询指定函数的调用记录（按创建时间和 ID 倒序）。
+// 使用 idx_invocations_function_created_id (function_id, created_at DESC, id DESC) 索引做键集分页，
+// 查询代价与翻页深度无关；不统计总数，避免对调用量大的函数执行 COUNT(*)。
+// 游标同时包含创建时间和 ID，创建时间相同的记录跨页时既不会被跳过也不会重复。
+//
+// 参数:
+//   - functionID: 函数唯一标识符
+//   - before: 游标，只返回排在游标之后（更早）的记录；空游标表示从最新的记录开始
+//   - limit: 返回的最大记录数
+//
+// 返回值:
+//   - []*domain.Invocation: 调用记录列表
+//   - domain.InvocationCursor: 下一页的游标（本页最后一条记录），没有更多记录时为空游标
+//   - error: 查询失败时返回错误信息
+func (s *PostgresStore) ListInvocationsByFunctionCursor(functionID string, before domain.InvocationCursor, limit int) ([]*domain.Invocation, domain.InvocationCursor, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	// SQL: 键集分页，按 (created_at, id) 行比较定位游标，游标为空时从最新的记录开始
+	query := `
+		SELECT id, function_id, function_name, trigger_type, status, input, output, error,
+		       cold_start, vm_id, started_at, completed_at, duration_ms, billed_time_ms,
+		       memory_used_mb, retry_count, created_at
+		FROM invocations WHERE function_id = $1
+	`
+	args := []interface{}{functionID}
+	if !before.IsZero() {
+		query += " AND (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT $4"
+		args = append(args, before.CreatedAt, before.ID, limit)
+	} else {
+		query += " ORDER BY created_at DESC, id DESC LIMIT $2"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, domain.InvocationCursor{}, err
+	}
+	defer rows.Close()
+
+	invocations, err := scanInvocationRows(rows)
+	if err != nil {
+		return nil, domain.InvocationCursor{}, err
+	}
+	return invocations, domain.NextInvocationCursor(invocations, limit), nil
+}
+
+// scanInvocationRows 扫描调用记录列表查询的结果行（不含调用方字段）
+func scanInvocationRows(rows *sql.Rows) ([]*domain.Invocation, error) {
 	var invocations []*domain.Invocation
 	for rows.Next() {
 		inv := &domain.Invocation{}
@@ -1262,7 +1706,7 @@ func (s *PostgresStore) ListInvocationsByFunction(functionID string, offset, lim
 			&inv.MemoryUsedMB, &inv.RetryCount, &inv.CreatedAt,
 		)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
 		if vmID.Valid {
 			inv.VMID = vmID.String
@@ -1278,7 +1722,97 @@ func (s *PostgresStore) ListInvocationsByFunction(functionID string, offset, lim
 		}
 		invocations = append(invocations, inv)
 	}
-	return invocations, total, nil
+	return invocations, rows.Err()
+}
+
+// 按输出内容查询调用记录的限制
+const (
+	// maxOutputSearchPeriodHours 是按输出内容查询的最大时间窗口（7 天）
+	maxOutputSearchPeriodHours = 168
+	// defaultOutputSearchLimit 是按输出内容查询的默认返回条数
+	defaultOutputSearchLimit = 100
+	// maxOutputSearchLimit 是按输出内容查询的最大返回条数
+	maxOutputSearchLimit = 500
+)
+
+// FindInvocationsByOutputField 按输出 JSON 中指定字段的值查询调用记录。
+// 用于排查问题，例如查找响应体中 status 为 error 的调用。
+// output 列没有针对任意路径的索引，因此必须限定函数和时间窗口，
+// 借助 (function_id, created_at) 索引缩小扫描范围，避免全表扫描。
+//
+// 参数:
+//   - functionID: 函数 ID，必填
+//   - jsonPath: 点分隔的字段路径，如 "status" 或 "error.code"，数组下标直接写数字，如 "items.0.id"
+//   - value: 字段的期望值，按文本比较（数字、布尔值也按其文本形式比较）
+//   - periodHours: 查询最近多少小时内的调用，取值 1 ~ 168
+//   - limit: 最大返回条数，<= 0 时使用默认值 100，最大 500
+//
+// 返回值:
+//   - []*domain.Invocation: 匹配的调用记录，按创建时间倒序排列
+//   - error: 参数无效或查询失败时返回错误
+func (s *PostgresStore) FindInvocationsByOutputField(functionID, jsonPath string, value string, periodHours, limit int) ([]*domain.Invocation, error) {
+	if functionID == "" {
+		return nil, errors.New("function id is required")
+	}
+	if periodHours <= 0 || periodHours > maxOutputSearchPeriodHours {
+		return nil, fmt.Errorf("period hours must be between 1 and %d", maxOutputSearchPeriodHours)
+	}
+	path := strings.Split(strings.Trim(jsonPath, "."), ".")
+	for _, p := range path {
+		if p == "" {
+			return nil, fmt.Errorf("invalid json path: %q", jsonPath)
+		}
+	}
+	if limit <= 0 {
+		limit = defaultOutputSearchLimit
+	}
+	if limit > maxOutputSearchLimit {
+		limit = maxOutputSearchLimit
+	}
+
+	// SQL: 在函数和时间窗口内按 output #>> path 过滤
+	query := `
+		SELECT id, function_id, function_name, trigger_type, status, input, output, error,
+		       cold_start, vm_id, started_at, completed_at, duration_ms, billed_time_ms,
+		       memory_used_mb, retry_count, created_at
+		FROM invocations
+		WHERE function_id = $1
+		  AND created_at >= NOW() - make_interval(hours => $2)
+		  AND output #>> $3 = $4
+		ORDER BY created_at DESC
+		LIMIT $5
+	`
+	rows, err := s.db.Query(query, functionID, periodHours, pq.Array(path), value, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find invocations by output field: %w", err)
+	}
+	defer rows.Close()
+
+	var invocations []*domain.Invocation
+	for rows.Next() {
+		inv := &domain.Invocation{}
+		var vmID sql.NullString
+		var input, output []byte
+		var errStr sql.NullString
+		if err := rows.Scan(
+			&inv.ID, &inv.FunctionID, &inv.FunctionName, &inv.TriggerType, &inv.Status,
+			&input, &output, &errStr, &inv.ColdStart, &vmID,
+			&inv.StartedAt, &inv.CompletedAt, &inv.DurationMs, &inv.BilledTimeMs,
+			&inv.MemoryUsedMB, &inv.RetryCount, &inv.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan invocation: %w", err)
+		}
+		if vmID.Valid {
+			inv.VMID = vmID.String
+		}
+		inv.Input = input
+		inv.Output = output
+		if errStr.Valid {
+			inv.Error = errStr.String
+		}
+		invocations = append(invocations, inv)
+	}
+	return invocations, rows.Err()
 }
 
 // UpdateInvocation 更新调用记录。
@@ -1298,19 +1832,25 @@ func (s *PostgresStore) UpdateInvocation(inv *domain.Invocation) error {
 	} else {
 		output = inv.Output
 	}
+	// 未携带决策记录时（如从数据库重新加载的调用）保留已有记录
+	var decisionTrace sql.NullString
+	if len(inv.DecisionTrace) > 0 {
+		data, _ := json.Marshal(inv.DecisionTrace)
+		decisionTrace = sql.NullString{String: string(data), Valid: true}
+	}
 
 	// SQL: 更新调用记录的执行结果相关字段
 	query := `
 		UPDATE invocations SET
 			status = $2, output = $3, error = $4, cold_start = $5, vm_id = $6,
 			started_at = $7, completed_at = $8, duration_ms = $9, billed_time_ms = $10,
-			memory_used_mb = $11, retry_count = $12
+			memory_used_mb = $11, retry_count = $12, decision_trace = COALESCE($13, decision_trace)
 		WHERE id = $1
 	`
 	result, err := s.db.Exec(query,
 		inv.ID, inv.Status, output, inv.Error, inv.ColdStart, inv.VMID,
 		inv.StartedAt, inv.CompletedAt, inv.DurationMs, inv.BilledTimeMs,
-		inv.MemoryUsedMB, inv.RetryCount,
+		inv.MemoryUsedMB, inv.RetryCount, decisionTrace,
 	)
 	if err != nil {
 		return err
@@ -1325,6 +1865,46 @@ func (s *PostgresStore) UpdateInvocation(inv *domain.Invocation) error {
 	return nil
 }
 
+// GetInvocationExplain 获取调用的执行路径说明，包括调用路径上的决策记录。
+//
+// 参数:
+//   - id: 调用记录 ID
+//
+// 返回值:
+//   - *domain.InvocationExplain: 执行路径说明，未记录决策时 DecisionTrace 为空列表
+//   - error: 调用不存在时返回 ErrInvocationNotFound
+func (s *PostgresStore) GetInvocationExplain(id string) (*domain.InvocationExplain, error) {
+	query := `
+		SELECT id, function_id, function_name, status, cold_start, vm_id, duration_ms, decision_trace, created_at
+		FROM invocations WHERE id = $1
+	`
+	explain := &domain.InvocationExplain{}
+	var vmID sql.NullString
+	var traceJSON []byte
+	err := s.db.QueryRow(query, id).Scan(
+		&explain.InvocationID, &explain.FunctionID, &explain.FunctionName, &explain.Status,
+		&explain.ColdStart, &vmID, &explain.DurationMs, &traceJSON, &explain.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrInvocationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invocation explain: %w", err)
+	}
+	if vmID.Valid {
+		explain.VMID = vmID.String
+	}
+	if len(traceJSON) > 0 {
+		if err := json.Unmarshal(traceJSON, &explain.DecisionTrace); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal decision trace: %w", err)
+		}
+	}
+	if explain.DecisionTrace == nil {
+		explain.DecisionTrace = []domain.DecisionStep{}
+	}
+	return explain, nil
+}
+
 // ==================== 健康检查和统计方法 ====================
 
 // Ping 检查数据库连接是否正常。
@@ -1385,13 +1965,14 @@ func (s *PostgresStore) CountInvocations() (int, error) {
 //   - keyHash: 密钥的哈希值
 //   - userID: 关联的用户 ID
 //   - role: 角色权限（如 user, admin）
+//   - scopes: 权限范围（如 functions:read），为空表示不受限制
 //
 // 返回值:
 //   - error: 创建失败时返回错误信息（如哈希值重复）
-func (s *PostgresStore) CreateAPIKey(id, name, keyHash, userID, role string) error {
+func (s *PostgresStore) CreateAPIKey(id, name, keyHash, userID, role string, scopes []string) error {
 	// SQL: 插入 API 密钥记录
-	query := `INSERT INTO api_keys (id, name, key_hash, user_id, role) VALUES ($1, $2, $3, $4, $5)`
-	_, err := s.db.Exec(query, id, name, keyHash, userID, role)
+	query := `INSERT INTO api_keys (id, name, key_hash, user_id, role, scopes) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := s.db.Exec(query, id, name, keyHash, userID, role, pq.Array(scopes))
 	return err
 }
 
@@ -1405,17 +1986,19 @@ func (s *PostgresStore) CreateAPIKey(id, name, keyHash, userID, role string) err
 //   - string: 密钥记录 ID
 //   - string: 关联的用户 ID
 //   - string: 角色权限
+//   - []string: 权限范围，为空表示不受限制
 //   - error: 密钥不存在或已过期时返回错误信息
-func (s *PostgresStore) GetAPIKeyByHash(keyHash string) (string, string, string, error) {
+func (s *PostgresStore) GetAPIKeyByHash(keyHash string) (string, string, string, []string, error) {
 	var userID, role, id string
+	var scopes pq.StringArray
 	// SQL: 根据哈希值查询未过期的 API 密钥
 	// expires_at IS NULL 表示永不过期，或 expires_at > NOW() 表示尚未过期
-	query := `SELECT id, user_id, role FROM api_keys WHERE key_hash = $1 AND (expires_at IS NULL OR expires_at > NOW())`
-	err := s.db.QueryRow(query, keyHash).Scan(&id, &userID, &role)
+	query := `SELECT id, user_id, role, scopes FROM api_keys WHERE key_hash = $1 AND (expires_at IS NULL OR expires_at > NOW())`
+	err := s.db.QueryRow(query, keyHash).Scan(&id, &userID, &role, &scopes)
 	if err == sql.ErrNoRows {
-		return "", "", "", errors.New("api key not found")
+		return "", "", "", nil, errors.New("api key not found")
 	}
-	return id, userID, role, err
+	return id, userID, role, scopes, err
 }
 
 // DeleteAPIKey 删除指定的 API 密钥。
@@ -1431,12 +2014,68 @@ func (s *PostgresStore) DeleteAPIKey(id string) error {
 	return err
 }
 
+// RotateAPIKey 轮换 API 密钥：为旧密钥的用户以相同的名称、角色和权限范围生成新密钥，
+// 并把旧密钥的过期时间设为当前时间加宽限期，宽限期内新旧密钥都有效。
+// 新密钥继承旧密钥原有的过期时间，宽限期也不会推迟旧密钥原有的过期时间。
+// 插入新密钥和缩短旧密钥有效期在同一事务中完成。
+//
+// 参数:
+//   - id: 旧密钥记录唯一标识符
+//   - graceDuration: 旧密钥继续有效的宽限期，为 0 时旧密钥立即失效
+//
+// 返回值:
+//   - string: 新密钥明文（只在此时返回一次，数据库只保存哈希值）
+//   - error: 旧密钥不存在或已过期时返回 auth.ErrAPIKeyNotFound
+func (s *PostgresStore) RotateAPIKey(id string, graceDuration time.Duration) (string, error) {
+	if graceDuration < 0 {
+		return "", fmt.Errorf("grace duration must not be negative: %s", graceDuration)
+	}
+	key, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// 锁定旧密钥行，避免与并发的删除或轮换交错
+	var name, userID, role string
+	var scopes pq.StringArray
+	var expiresAt sql.NullTime
+	err = tx.QueryRow(`SELECT name, user_id, role, scopes, expires_at FROM api_keys WHERE id = $1 AND (expires_at IS NULL OR expires_at > NOW()) FOR UPDATE`, id).
+		Scan(&name, &userID, &role, &scopes, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", auth.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO api_keys (id, name, key_hash, user_id, role, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		uuid.New().String(), name, hash, userID, role, scopes, expiresAt); err != nil {
+		return "", err
+	}
+	// LEAST 忽略 NULL：永不过期的旧密钥直接设为宽限期结束时间
+	if _, err := tx.Exec(`UPDATE api_keys SET expires_at = LEAST(expires_at, NOW() + $2 * INTERVAL '1 millisecond') WHERE id = $1`,
+		id, graceDuration.Milliseconds()); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
 // APIKeyInfo 表示 API 密钥的基本信息（不包含敏感的哈希值）
 type APIKeyInfo struct {
 	ID        string     `json:"id"`
 	Name      string     `json:"name"`
 	UserID    string     `json:"user_id"`
 	Role      string     `json:"role"`
+	Scopes    []string   `json:"scopes,omitempty"`
 	CreatedAt time.Time  `json:"created_at"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
@@ -1450,7 +2089,7 @@ type APIKeyInfo struct {
 //   - []APIKeyInfo: API 密钥信息列表
 //   - error: 查询失败时返回错误信息
 func (s *PostgresStore) ListAPIKeysByUser(userID string) ([]APIKeyInfo, error) {
-	query := `SELECT id, name, user_id, role, created_at, expires_at FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
+	query := `SELECT id, name, user_id, role, scopes, created_at, expires_at FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
 	rows, err := s.db.Query(query, userID)
 	if err != nil {
 		return nil, err
@@ -1460,7 +2099,7 @@ func (s *PostgresStore) ListAPIKeysByUser(userID string) ([]APIKeyInfo, error) {
 	var keys []APIKeyInfo
 	for rows.Next() {
 		var key APIKeyInfo
-		if err := rows.Scan(&key.ID, &key.Name, &key.UserID, &key.Role, &key.CreatedAt, &key.ExpiresAt); err != nil {
+		if err := rows.Scan(&key.ID, &key.Name, &key.UserID, &key.Role, (*pq.StringArray)(&key.Scopes), &key.CreatedAt, &key.ExpiresAt); err != nil {
 			return nil, err
 		}
 		keys = append(keys, key)
@@ -1686,49 +2325,82 @@ func (s *PostgresStore) GetRecentInvocations(limit int) ([]RecentInvocation, err
 	return invocations, nil
 }
 
-// ListAllInvocations 分页查询所有调用记录
+// ListAllInvocations 分页查询所有调用记录，status 为空时不过滤
 func (s *PostgresStore) ListAllInvocations(status string, offset, limit int) ([]*domain.Invocation, int, error) {
-	var total int
-	var countQuery, listQuery string
-	var countArgs, listArgs []interface{}
+	return s.ListAllInvocationsWithFilter(&domain.InvocationFilter{Status: domain.InvocationStatus(status)}, offset, limit)
+}
 
-	if status != "" {
-		countQuery = "SELECT COUNT(*) FROM invocations WHERE status = $1"
-		countArgs = []interface{}{status}
-		listQuery = `
-			SELECT id, function_id, function_name, trigger_type, status, input, output, error,
-			       cold_start, vm_id, started_at, completed_at, duration_ms, billed_time_ms,
-			       memory_used_mb, retry_count, created_at
-			FROM invocations WHERE status = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3
-		`
-		listArgs = []interface{}{status, limit, offset}
-	} else {
-		countQuery = "SELECT COUNT(*) FROM invocations"
-		countArgs = nil
-		listQuery = `
-			SELECT id, function_id, function_name, trigger_type, status, input, output, error,
-			       cold_start, vm_id, started_at, completed_at, duration_ms, billed_time_ms,
-			       memory_used_mb, retry_count, created_at
-			FROM invocations ORDER BY created_at DESC LIMIT $1 OFFSET $2
-		`
-		listArgs = []interface{}{limit, offset}
+// invocationFilterWhere 根据筛选条件构建调用记录查询的 WHERE 子句。
+// 条件按 status、created_at 的顺序排列，以便使用 idx_invocations_status_created 索引。
+//
+// 参数:
+//   - filter: 筛选条件，为 nil 时不过滤
+//
+// 返回值:
+//   - string: WHERE 子句（含前导空格），无条件时为空字符串
+//   - []interface{}: 与占位符 $1、$2... 对应的参数
+func invocationFilterWhere(filter *domain.InvocationFilter) (string, []interface{}) {
+	if filter == nil {
+		return "", nil
 	}
-
-	if countArgs != nil {
-		err := s.db.QueryRow(countQuery, countArgs...).Scan(&total)
-		if err != nil {
-			return nil, 0, err
-		}
-	} else {
-		err := s.db.QueryRow(countQuery).Scan(&total)
-		if err != nil {
-			return nil, 0, err
-		}
+	var conditions []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, fmt.Sprintf("$%d", len(args))))
 	}
 
-	rows, err := s.db.Query(listQuery, listArgs...)
-	if err != nil {
-		return nil, 0, err
+	if filter.Status != "" {
+		add("status = %s", string(filter.Status))
+	}
+	// 时间统一转换为 UTC，与数据库中的时间戳按同一时区比较
+	if filter.After != nil {
+		add("created_at >= %s", filter.After.UTC())
+	}
+	if filter.Before != nil {
+		add("created_at < %s", filter.Before.UTC())
+	}
+	if filter.TriggerType != "" {
+		add("trigger_type = %s", string(filter.TriggerType))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// ListAllInvocationsWithFilter 按筛选条件分页查询所有调用记录，按创建时间倒序排列。
+//
+// 参数:
+//   - filter: 筛选条件（状态、触发类型、创建时间范围），为 nil 时不过滤
+//   - offset: 跳过的记录数（用于分页）
+//   - limit: 返回的最大记录数
+//
+// 返回值:
+//   - []*domain.Invocation: 调用记录列表
+//   - int: 符合条件的记录总数（用于分页计算）
+//   - error: 查询失败时返回错误信息
+func (s *PostgresStore) ListAllInvocationsWithFilter(filter *domain.InvocationFilter, offset, limit int) ([]*domain.Invocation, int, error) {
+	where, args := invocationFilterWhere(filter)
+
+	// SQL: 查询符合条件的调用记录总数
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM invocations"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// SQL: 分页查询调用记录，按创建时间倒序排列
+	listQuery := `
+		SELECT id, function_id, function_name, trigger_type, status, input, output, error,
+		       cold_start, vm_id, started_at, completed_at, duration_ms, billed_time_ms,
+		       memory_used_mb, retry_count, created_at
+		FROM invocations` + where + ` ORDER BY created_at DESC LIMIT ` + fmt.Sprintf("$%d OFFSET $%d", len(args)+1, len(args)+2)
+	listArgs := append(args, limit, offset)
+
+	rows, err := s.db.Query(listQuery, listArgs...)
+	if err != nil {
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -1982,12 +2654,15 @@ type FunctionStats struct {
 	ColdStartCount   int64   `json:"cold_start_count"`
 	ColdStartRate    float64 `json:"cold_start_rate"`
 	AvgColdStartMs   float64 `json:"avg_cold_start_ms"`
+	ColdStartP99Ms   float64 `json:"cold_start_p99_ms"`
+	WarmCount        int64   `json:"warm_count"`
+	AvgWarmLatencyMs float64 `json:"avg_warm_latency_ms"`
 	TotalDurationMs  int64   `json:"total_duration_ms"`
 	ErrorRate        float64 `json:"error_rate"`
 	TimeoutCount     int64   `json:"timeout_count"`
 }
 
-// GetFunctionStats 获取单个函数的统计数据
+// GetFunctionStats 获取单个函数的统计数据，冷启动和热启动调用的延迟分别统计，便于衡量冷启动开销
 func (s *PostgresStore) GetFunctionStats(functionID string, periodHours int) (*FunctionStats, error) {
 	stats := &FunctionStats{}
 
@@ -2005,7 +2680,10 @@ func (s *PostgresStore) GetFunctionStats(functionID string, periodHours int) (*F
 			COALESCE(MIN(duration_ms), 0) as min_latency,
 			COALESCE(MAX(duration_ms), 0) as max_latency,
 			COALESCE(SUM(duration_ms), 0) as total_duration,
-			COALESCE(AVG(duration_ms) FILTER (WHERE cold_start = true), 0) as avg_cold_start
+			COALESCE(AVG(duration_ms) FILTER (WHERE cold_start = true), 0) as avg_cold_start,
+			COALESCE(PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY duration_ms) FILTER (WHERE cold_start = true), 0) as cold_start_p99,
+			COUNT(*) FILTER (WHERE cold_start = false) as warm_count,
+			COALESCE(AVG(duration_ms) FILTER (WHERE cold_start = false), 0) as avg_warm_latency
 		FROM invocations
 		WHERE function_id = $1 AND created_at >= NOW() - INTERVAL '1 hour' * $2
 	`
@@ -2023,6 +2701,9 @@ func (s *PostgresStore) GetFunctionStats(functionID string, periodHours int) (*F
 		&stats.MaxLatencyMs,
 		&stats.TotalDurationMs,
 		&stats.AvgColdStartMs,
+		&stats.ColdStartP99Ms,
+		&stats.WarmCount,
+		&stats.AvgWarmLatencyMs,
 	)
 	if err != nil {
 		return stats, nil
@@ -2126,10 +2807,134 @@ func (s *PostgresStore) GetFunctionLatencyDistribution(functionID string, period
 	return dist, nil
 }
 
+// 延迟采样的条数限制
+const (
+	// DefaultLatencySamples 未指定采样条数时返回的最大样本数
+	DefaultLatencySamples = 1000
+	// MaxLatencySamples 单次请求允许的最大样本数
+	MaxLatencySamples = 10000
+)
+
+// GetFunctionLatencySamples 随机抽取函数在时间窗口内已完成调用的耗时，按升序返回，
+// 供控制台按任意分桶绘制直方图或计算自定义百分位。
+//
+// 参数:
+//   - functionID: 函数唯一标识符
+//   - periodHours: 时间窗口（小时）
+//   - maxSamples: 最大样本数，不大于 0 时取 DefaultLatencySamples，超过 MaxLatencySamples 时取上限
+//
+// 返回值:
+//   - []int64: 调用耗时（毫秒），调用数不超过 maxSamples 时为全部调用
+//   - error: 查询失败时返回错误信息
+func (s *PostgresStore) GetFunctionLatencySamples(functionID string, periodHours, maxSamples int) ([]int64, error) {
+	query := `
+		SELECT duration_ms FROM (
+			SELECT duration_ms
+			FROM invocations
+			WHERE function_id = $1 AND created_at >= NOW() - INTERVAL '1 hour' * $2
+			  AND completed_at IS NOT NULL AND duration_ms IS NOT NULL
+			ORDER BY random()
+			LIMIT $3
+		) sampled
+		ORDER BY duration_ms
+	`
+	rows, err := s.db.Query(query, functionID, periodHours, latencySampleLimit(maxSamples))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []int64{}
+	for rows.Next() {
+		var d int64
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		samples = append(samples, d)
+	}
+	return samples, rows.Err()
+}
+
+// latencySampleLimit 将请求的采样条数限制在 (0, MaxLatencySamples] 内
+func latencySampleLimit(maxSamples int) int {
+	if maxSamples <= 0 {
+		return DefaultLatencySamples
+	}
+	if maxSamples > MaxLatencySamples {
+		return MaxLatencySamples
+	}
+	return maxSamples
+}
+
+// CostlyInvocation 单次调用的资源消耗（按 GB-秒计）
+type CostlyInvocation struct {
+	InvocationID string    `json:"invocation_id"`
+	FunctionID   string    `json:"function_id"`
+	FunctionName string    `json:"function_name"`
+	Status       string    `json:"status"`
+	DurationMs   int64     `json:"duration_ms"`
+	BilledTimeMs int64     `json:"billed_time_ms"`
+	MemoryMB     int       `json:"memory_mb"`
+	GBSeconds    float64   `json:"gb_seconds"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// GetTopCostInvocations 获取时间范围内资源消耗最高的单次调用。
+// 消耗按 billed_time_ms × memory_mb 换算为 GB-秒，用于发现跑满超时、
+// 占用大内存的异常调用，这类调用在平均值中往往被掩盖。
+// 内存取函数当前配置；时间范围过滤使用 created_at 索引。
+//
+// 参数:
+//   - from: 起始时间（包含）
+//   - to: 结束时间（不包含）
+//   - limit: 返回条数，<= 0 时默认 10
+//
+// 返回值:
+//   - []CostlyInvocation: 按 GB-秒降序排列的调用列表
+//   - error: 查询失败时返回错误
+func (s *PostgresStore) GetTopCostInvocations(from, to time.Time, limit int) ([]CostlyInvocation, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+		SELECT i.id, i.function_id, i.function_name, i.status,
+			COALESCE(i.duration_ms, 0), COALESCE(i.billed_time_ms, 0), f.memory_mb,
+			COALESCE(i.billed_time_ms, 0)::float8 / 1000.0 * f.memory_mb::float8 / 1024.0 AS gb_seconds,
+			i.created_at
+		FROM invocations i
+		JOIN functions f ON f.id = i.function_id
+		WHERE i.created_at >= $1 AND i.created_at < $2
+		ORDER BY gb_seconds DESC, i.created_at DESC
+		LIMIT $3
+	`
+	rows, err := s.db.Query(query, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top cost invocations: %w", err)
+	}
+	defer rows.Close()
+
+	var result []CostlyInvocation
+	for rows.Next() {
+		var c CostlyInvocation
+		if err := rows.Scan(&c.InvocationID, &c.FunctionID, &c.FunctionName, &c.Status,
+			&c.DurationMs, &c.BilledTimeMs, &c.MemoryMB, &c.GBSeconds, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cost invocation: %w", err)
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
 // ==================== 函数版本管理方法 ====================
 
 // CreateFunctionVersion 创建函数版本记录。
 func (s *PostgresStore) CreateFunctionVersion(v *domain.FunctionVersion) error {
+	return createFunctionVersion(s.db, v)
+}
+
+// createFunctionVersion 在 db（连接或事务）上插入版本记录
+func createFunctionVersion(db sqlExecutor, v *domain.FunctionVersion) error {
 	if v.ID == "" {
 		v.ID = uuid.New().String()
 	}
@@ -2139,7 +2944,7 @@ func (s *PostgresStore) CreateFunctionVersion(v *domain.FunctionVersion) error {
 		INSERT INTO function_versions (id, function_id, version, handler, code, "binary", code_hash, description, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
-	_, err := s.db.Exec(query, v.ID, v.FunctionID, v.Version, v.Handler, v.Code, v.Binary, v.CodeHash, v.Description, v.CreatedAt)
+	_, err := db.Exec(query, v.ID, v.FunctionID, v.Version, v.Handler, v.Code, v.Binary, v.CodeHash, v.Description, v.CreatedAt)
 	return err
 }
 
@@ -2180,6 +2985,11 @@ func (s *PostgresStore) ListFunctionVersions(functionID string) ([]*domain.Funct
 
 // GetFunctionVersion 获取指定版本。
 func (s *PostgresStore) GetFunctionVersion(functionID string, version int) (*domain.FunctionVersion, error) {
+	return getFunctionVersion(s.db, functionID, version)
+}
+
+// getFunctionVersion 在 db（连接或事务）上查询函数的指定版本
+func getFunctionVersion(db sqlExecutor, functionID string, version int) (*domain.FunctionVersion, error) {
 	query := `
 		SELECT id, function_id, version, handler, code, "binary", code_hash, description, created_at
 		FROM function_versions
@@ -2187,7 +2997,7 @@ func (s *PostgresStore) GetFunctionVersion(functionID string, version int) (*dom
 	`
 	v := &domain.FunctionVersion{}
 	var code, binary, description sql.NullString
-	err := s.db.QueryRow(query, functionID, version).Scan(&v.ID, &v.FunctionID, &v.Version, &v.Handler, &code, &binary, &v.CodeHash, &description, &v.CreatedAt)
+	err := db.QueryRow(query, functionID, version).Scan(&v.ID, &v.FunctionID, &v.Version, &v.Handler, &code, &binary, &v.CodeHash, &description, &v.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, domain.ErrFunctionNotFound
 	}
@@ -2206,15 +3016,99 @@ func (s *PostgresStore) GetFunctionVersion(functionID string, version int) (*dom
 	return v, nil
 }
 
+// RollbackFunction 在同一事务中将函数的代码和处理程序恢复为指定版本的内容，递增函数版本号，
+// 并保存一条新的版本记录，使回滚本身出现在版本历史中，之后仍可回滚到回滚前的版本。
+//
+// 参数:
+//   - functionID: 函数 ID
+//   - version: 目标版本号
+//
+// 返回值:
+//   - *domain.Function: 回滚后的函数
+//   - error: 函数或版本不存在时返回 ErrFunctionNotFound
+func (s *PostgresStore) RollbackFunction(functionID string, version int) (*domain.Function, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// SQL: 锁定函数，防止与并发的更新或回滚交错
+	query := `
+		SELECT ` + functionColumns + `
+		FROM functions WHERE id = $1 FOR UPDATE
+	`
+	fn, err := s.scanFunction(tx.QueryRow(query, functionID))
+	if err != nil {
+		return nil, err
+	}
+	target, err := getFunctionVersion(tx, functionID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	fn.Handler = target.Handler
+	fn.Code = target.Code
+	fn.Binary = target.Binary
+	fn.CodeHash = target.CodeHash
+	if err := s.updateFunction(tx, fn); err != nil {
+		return nil, err
+	}
+
+	latest, err := latestFunctionVersion(tx, functionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := createFunctionVersion(tx, &domain.FunctionVersion{
+		FunctionID:  functionID,
+		Version:     latest + 1,
+		Handler:     fn.Handler,
+		Code:        fn.Code,
+		Binary:      fn.Binary,
+		CodeHash:    fn.CodeHash,
+		Description: fmt.Sprintf("Rolled back to version %d", version),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create function version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
 // GetLatestFunctionVersion 获取函数的最新版本号。
 func (s *PostgresStore) GetLatestFunctionVersion(functionID string) (int, error) {
+	return latestFunctionVersion(s.db, functionID)
+}
+
+// latestFunctionVersion 在 db（连接或事务）上查询函数的最新版本号，没有版本记录时为 0
+func latestFunctionVersion(db sqlExecutor, functionID string) (int, error) {
 	var version int
-	err := s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM function_versions WHERE function_id = $1", functionID).Scan(&version)
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM function_versions WHERE function_id = $1", functionID).Scan(&version)
 	return version, err
 }
 
 // ==================== 函数别名管理方法 ====================
 
+// OnAliasChanged 注册别名变更回调，别名创建、更新、删除或金丝雀发布调整权重后调用，
+// aliasName 为空表示该函数的所有别名（如函数被删除）。用于使本进程内的别名缓存立即失效，
+// 其他网关实例仍依赖缓存过期时间。
+func (s *PostgresStore) OnAliasChanged(fn func(functionID, aliasName string)) {
+	s.aliasMu.Lock()
+	s.aliasChangedHooks = append(s.aliasChangedHooks, fn)
+	s.aliasMu.Unlock()
+}
+
+// notifyAliasChanged 调用已注册的别名变更回调
+func (s *PostgresStore) notifyAliasChanged(functionID, aliasName string) {
+	s.aliasMu.RLock()
+	hooks := s.aliasChangedHooks
+	s.aliasMu.RUnlock()
+	for _, fn := range hooks {
+		fn(functionID, aliasName)
+	}
+}
+
 // CreateFunctionAlias 创建函数别名。
 func (s *PostgresStore) CreateFunctionAlias(a *domain.FunctionAlias) error {
 	if a.ID == "" {
@@ -2228,8 +3122,11 @@ func (s *PostgresStore) CreateFunctionAlias(a *domain.FunctionAlias) error {
 		INSERT INTO function_aliases (id, function_id, name, description, routing_config, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
-	_, err := s.db.Exec(query, a.ID, a.FunctionID, a.Name, a.Description, routingJSON, a.CreatedAt, a.UpdatedAt)
-	return err
+	if _, err := s.db.Exec(query, a.ID, a.FunctionID, a.Name, a.Description, routingJSON, a.CreatedAt, a.UpdatedAt); err != nil {
+		return err
+	}
+	s.notifyAliasChanged(a.FunctionID, a.Name)
+	return nil
 }
 
 // GetFunctionAlias 获取函数别名。
@@ -2306,6 +3203,7 @@ func (s *PostgresStore) UpdateFunctionAlias(a *domain.FunctionAlias) error {
 	if affected == 0 {
 		return domain.ErrFunctionNotFound
 	}
+	s.notifyAliasChanged(a.FunctionID, a.Name)
 	return nil
 }
 
@@ -2322,6 +3220,7 @@ func (s *PostgresStore) DeleteFunctionAlias(functionID, name string) error {
 	if affected == 0 {
 		return domain.ErrFunctionNotFound
 	}
+	s.notifyAliasChanged(functionID, name)
 	return nil
 }
 
@@ -2456,17 +3355,29 @@ func (s *PostgresStore) DeleteLayer(id string) error {
 }
 
 // CreateLayerVersion 创建层版本。
+// 内容先按哈希写入层内容存储，再插入只含哈希和大小的版本记录；
+// 未设置 ContentHash 时按内容计算。
 func (s *PostgresStore) CreateLayerVersion(lv *domain.LayerVersion, content []byte) error {
 	if lv.ID == "" {
 		lv.ID = uuid.New().String()
 	}
+	if lv.ContentHash == "" {
+		lv.ContentHash = layerContentHash(content)
+	}
+	lv.SizeBytes = int64(len(content))
 	lv.CreatedAt = time.Now()
 
+	ctx, cancel := context.WithTimeout(context.Background(), layerBlobTimeout)
+	defer cancel()
+	if err := s.layerBlobStore().Put(ctx, lv.ContentHash, content); err != nil {
+		return fmt.Errorf("failed to store layer content: %w", err)
+	}
+
 	query := `
-		INSERT INTO layer_versions (id, layer_id, version, content, content_hash, size_bytes, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO layer_versions (id, layer_id, version, content_hash, size_bytes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 	`
-	_, err := s.db.Exec(query, lv.ID, lv.LayerID, lv.Version, content, lv.ContentHash, lv.SizeBytes, lv.CreatedAt)
+	_, err := s.db.Exec(query, lv.ID, lv.LayerID, lv.Version, lv.ContentHash, lv.SizeBytes, lv.CreatedAt)
 	return err
 }
 
@@ -2486,13 +3397,31 @@ func (s *PostgresStore) GetLayerVersion(layerID string, version int) (*domain.La
 }
 
 // GetLayerVersionContent 获取层版本内容。
+// 迁移前创建的版本内容保存在 content 列中，直接返回；其余版本按哈希从层内容存储读取并校验。
 func (s *PostgresStore) GetLayerVersionContent(layerID string, version int) ([]byte, error) {
+	var hash string
 	var content []byte
-	err := s.db.QueryRow("SELECT content FROM layer_versions WHERE layer_id = $1 AND version = $2", layerID, version).Scan(&content)
+	err := s.db.QueryRow("SELECT content_hash, content FROM layer_versions WHERE layer_id = $1 AND version = $2", layerID, version).Scan(&hash, &content)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("layer version not found")
 	}
-	return content, err
+	if err != nil {
+		return nil, err
+	}
+	if content != nil {
+		return content, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), layerBlobTimeout)
+	defer cancel()
+	content, err = s.layerBlobStore().Get(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch layer content %s: %w", hash, err)
+	}
+	if layerContentHash(content) != hash {
+		return nil, fmt.Errorf("layer content hash mismatch for %s", hash)
+	}
+	return content, nil
 }
 
 // ListLayerVersions 获取层的所有版本。
@@ -2551,9 +3480,10 @@ func (s *PostgresStore) SetFunctionLayers(functionID string, layers []domain.Fun
 // GetFunctionLayers 获取函数的层。
 func (s *PostgresStore) GetFunctionLayers(functionID string) ([]domain.FunctionLayer, error) {
 	query := `
-		SELECT fl.layer_id, l.name, fl.layer_version, fl.order_index
+		SELECT fl.layer_id, l.name, fl.layer_version, fl.order_index, COALESCE(lv.content_hash, '')
 		FROM function_layers fl
 		JOIN layers l ON fl.layer_id = l.id
+		LEFT JOIN layer_versions lv ON lv.layer_id = fl.layer_id AND lv.version = fl.layer_version
 		WHERE fl.function_id = $1
 		ORDER BY fl.order_index
 	`
@@ -2566,7 +3496,7 @@ func (s *PostgresStore) GetFunctionLayers(functionID string) ([]domain.FunctionL
 	var layers []domain.FunctionLayer
 	for rows.Next() {
 		fl := domain.FunctionLayer{}
-		if err := rows.Scan(&fl.LayerID, &fl.LayerName, &fl.LayerVersion, &fl.Order); err != nil {
+		if err := rows.Scan(&fl.LayerID, &fl.LayerName, &fl.LayerVersion, &fl.Order, &fl.ContentHash); err != nil {
 			return nil, err
 		}
 		layers = append(layers, fl)
@@ -2591,20 +3521,26 @@ func (s *PostgresStore) CreateEnvironment(e *domain.Environment) error {
 		}
 	}
 
+	defaultEnvVarsJSON, _ := json.Marshal(e.DefaultEnvVars)
+	if e.DefaultEnvVars == nil {
+		defaultEnvVarsJSON = []byte("{}")
+	}
+
 	query := `
-		INSERT INTO environments (id, name, description, is_default, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO environments (id, name, description, is_default, default_env_vars, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 	`
-	_, err := s.db.Exec(query, e.ID, e.Name, e.Description, e.IsDefault, e.CreatedAt)
+	_, err := s.db.Exec(query, e.ID, e.Name, e.Description, e.IsDefault, defaultEnvVarsJSON, e.CreatedAt)
 	return err
 }
 
 // GetEnvironmentByID 根据 ID 获取环境。
 func (s *PostgresStore) GetEnvironmentByID(id string) (*domain.Environment, error) {
-	query := `SELECT id, name, description, is_default, created_at FROM environments WHERE id = $1`
+	query := `SELECT id, name, description, is_default, default_env_vars, created_at FROM environments WHERE id = $1`
 	e := &domain.Environment{}
 	var description sql.NullString
-	err := s.db.QueryRow(query, id).Scan(&e.ID, &e.Name, &description, &e.IsDefault, &e.CreatedAt)
+	var defaultEnvVarsJSON []byte
+	err := s.db.QueryRow(query, id).Scan(&e.ID, &e.Name, &description, &e.IsDefault, &defaultEnvVarsJSON, &e.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("environment not found")
 	}
@@ -2614,15 +3550,17 @@ func (s *PostgresStore) GetEnvironmentByID(id string) (*domain.Environment, erro
 	if description.Valid {
 		e.Description = description.String
 	}
+	json.Unmarshal(defaultEnvVarsJSON, &e.DefaultEnvVars)
 	return e, nil
 }
 
 // GetEnvironmentByName 根据名称获取环境。
 func (s *PostgresStore) GetEnvironmentByName(name string) (*domain.Environment, error) {
-	query := `SELECT id, name, description, is_default, created_at FROM environments WHERE name = $1`
+	query := `SELECT id, name, description, is_default, default_env_vars, created_at FROM environments WHERE name = $1`
 	e := &domain.Environment{}
 	var description sql.NullString
-	err := s.db.QueryRow(query, name).Scan(&e.ID, &e.Name, &description, &e.IsDefault, &e.CreatedAt)
+	var defaultEnvVarsJSON []byte
+	err := s.db.QueryRow(query, name).Scan(&e.ID, &e.Name, &description, &e.IsDefault, &defaultEnvVarsJSON, &e.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("environment not found")
 	}
@@ -2632,15 +3570,17 @@ func (s *PostgresStore) GetEnvironmentByName(name string) (*domain.Environment,
 	if description.Valid {
 		e.Description = description.String
 	}
+	json.Unmarshal(defaultEnvVarsJSON, &e.DefaultEnvVars)
 	return e, nil
 }
 
 // GetDefaultEnvironment 获取默认环境。
 func (s *PostgresStore) GetDefaultEnvironment() (*domain.Environment, error) {
-	query := `SELECT id, name, description, is_default, created_at FROM environments WHERE is_default = TRUE LIMIT 1`
+	query := `SELECT id, name, description, is_default, default_env_vars, created_at FROM environments WHERE is_default = TRUE LIMIT 1`
 	e := &domain.Environment{}
 	var description sql.NullString
-	err := s.db.QueryRow(query).Scan(&e.ID, &e.Name, &description, &e.IsDefault, &e.CreatedAt)
+	var defaultEnvVarsJSON []byte
+	err := s.db.QueryRow(query).Scan(&e.ID, &e.Name, &description, &e.IsDefault, &defaultEnvVarsJSON, &e.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("default environment not found")
 	}
@@ -2650,12 +3590,13 @@ func (s *PostgresStore) GetDefaultEnvironment() (*domain.Environment, error) {
 	if description.Valid {
 		e.Description = description.String
 	}
+	json.Unmarshal(defaultEnvVarsJSON, &e.DefaultEnvVars)
 	return e, nil
 }
 
 // ListEnvironments 获取所有环境。
 func (s *PostgresStore) ListEnvironments() ([]*domain.Environment, error) {
-	query := `SELECT id, name, description, is_default, created_at FROM environments ORDER BY created_at`
+	query := `SELECT id, name, description, is_default, default_env_vars, created_at FROM environments ORDER BY created_at`
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -2666,12 +3607,14 @@ func (s *PostgresStore) ListEnvironments() ([]*domain.Environment, error) {
 	for rows.Next() {
 		e := &domain.Environment{}
 		var description sql.NullString
-		if err := rows.Scan(&e.ID, &e.Name, &description, &e.IsDefault, &e.CreatedAt); err != nil {
+		var defaultEnvVarsJSON []byte
+		if err := rows.Scan(&e.ID, &e.Name, &description, &e.IsDefault, &defaultEnvVarsJSON, &e.CreatedAt); err != nil {
 			return nil, err
 		}
 		if description.Valid {
 			e.Description = description.String
 		}
+		json.Unmarshal(defaultEnvVarsJSON, &e.DefaultEnvVars)
 		envs = append(envs, e)
 	}
 	return envs, nil
@@ -2706,6 +3649,57 @@ func (s *PostgresStore) DeleteEnvironment(id string) error {
 	return nil
 }
 
+// UpdateEnvironmentDefaultEnvVars 整体替换环境的默认环境变量。
+func (s *PostgresStore) UpdateEnvironmentDefaultEnvVars(id string, envVars map[string]string) error {
+	envVarsJSON, _ := json.Marshal(envVars)
+	if envVars == nil {
+		envVarsJSON = []byte("{}")
+	}
+	result, err := s.db.Exec("UPDATE environments SET default_env_vars = $2 WHERE id = $1", id, envVarsJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update environment env vars: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if affected == 0 {
+		return errors.New("environment not found")
+	}
+	return nil
+}
+
+// GetEffectiveEnvVars 计算函数在指定环境下调用时生效的环境变量。
+// 合并顺序见 domain.MergeEnvVars：环境默认变量 < 函数级变量 < 函数环境覆盖变量。
+// environmentID 为空时使用默认环境；不存在默认环境或函数在该环境下没有配置时，
+// 对应层级视为空。
+func (s *PostgresStore) GetEffectiveEnvVars(functionID, environmentID string) (map[string]string, error) {
+	query := `
+		SELECT f.env_vars, COALESCE(e.default_env_vars, '{}'), COALESCE(fec.env_vars, '{}')
+		FROM functions f
+		LEFT JOIN environments e
+			ON (CASE WHEN $2::text = '' THEN e.is_default ELSE e.id = $2::text END)
+		LEFT JOIN function_environment_configs fec
+			ON fec.function_id = f.id AND fec.environment_id = e.id
+		WHERE f.id = $1
+		LIMIT 1
+	`
+	var fnVarsJSON, defaultsJSON, overridesJSON []byte
+	err := s.db.QueryRow(query, functionID, environmentID).Scan(&fnVarsJSON, &defaultsJSON, &overridesJSON)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrFunctionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get effective env vars: %w", err)
+	}
+
+	var fnVars, defaults, overrides map[string]string
+	json.Unmarshal(fnVarsJSON, &fnVars)
+	json.Unmarshal(defaultsJSON, &defaults)
+	json.Unmarshal(overridesJSON, &overrides)
+	return domain.MergeEnvVars(defaults, fnVars, overrides), nil
+}
+
 // GetFunctionEnvConfig 获取函数的环境配置。
 func (s *PostgresStore) GetFunctionEnvConfig(functionID, environmentID string) (*domain.FunctionEnvConfig, error) {
 	query := `
@@ -2816,6 +3810,114 @@ func (s *PostgresStore) UpsertFunctionEnvConfig(cfg *domain.FunctionEnvConfig) e
 	return err
 }
 
+// PromoteFunction 将函数在 fromEnv 环境下的别名和资源配置（active_alias、memory_mb、timeout_sec）
+// 复制到 toEnv 环境，并在同一事务中写入审计日志。目标环境的环境变量保持不变，
+// 环境变量通常指向各环境自己的依赖，不随晋升复制。
+//
+// 参数:
+//   - functionID: 函数 ID
+//   - fromEnv: 源环境名称
+//   - toEnv: 目标环境名称
+//   - audit: 审计日志的操作者信息（可为 nil），操作类型、资源和详情由本方法填写
+//
+// 返回值:
+//   - *domain.FunctionEnvConfig: 晋升后目标环境的配置
+//   - error: 任一环境不存在时返回 domain.ErrEnvironmentNotFound，
+//     函数在源环境下没有配置时返回 domain.ErrEnvConfigNotFound
+func (s *PostgresStore) PromoteFunction(functionID, fromEnv, toEnv string, audit *AuditLog) (*domain.FunctionEnvConfig, error) {
+	if fromEnv == "" || toEnv == "" || fromEnv == toEnv {
+		return nil, domain.ErrInvalidPromotion
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	envIDs := make(map[string]string, 2)
+	for _, name := range []string{fromEnv, toEnv} {
+		var id string
+		err := tx.QueryRow("SELECT id FROM environments WHERE name = $1", name).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", domain.ErrEnvironmentNotFound, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		envIDs[name] = id
+	}
+
+	var memoryMB, timeoutSec sql.NullInt32
+	var activeAlias sql.NullString
+	err = tx.QueryRow(`
+		SELECT memory_mb, timeout_sec, active_alias FROM function_environment_configs
+		WHERE function_id = $1 AND environment_id = $2
+		FOR SHARE
+	`, functionID, envIDs[fromEnv]).Scan(&memoryMB, &timeoutSec, &activeAlias)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", domain.ErrEnvConfigNotFound, fromEnv)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cfg := &domain.FunctionEnvConfig{
+		FunctionID:      functionID,
+		EnvironmentID:   envIDs[toEnv],
+		EnvironmentName: toEnv,
+		ActiveAlias:     activeAlias.String,
+		UpdatedAt:       now,
+	}
+	if memoryMB.Valid {
+		val := int(memoryMB.Int32)
+		cfg.MemoryMB = &val
+	}
+	if timeoutSec.Valid {
+		val := int(timeoutSec.Int32)
+		cfg.TimeoutSec = &val
+	}
+
+	var envVarsJSON []byte
+	err = tx.QueryRow(`
+		INSERT INTO function_environment_configs (function_id, environment_id, env_vars, memory_mb, timeout_sec, active_alias, created_at, updated_at)
+		VALUES ($1, $2, '{}', $3, $4, $5, $6, $6)
+		ON CONFLICT (function_id, environment_id) DO UPDATE SET
+			memory_mb = EXCLUDED.memory_mb,
+			timeout_sec = EXCLUDED.timeout_sec,
+			active_alias = EXCLUDED.active_alias,
+			updated_at = EXCLUDED.updated_at
+		RETURNING env_vars, created_at
+	`, functionID, cfg.EnvironmentID, memoryMB, timeoutSec, activeAlias, now).Scan(&envVarsJSON, &cfg.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to promote env config: %w", err)
+	}
+	json.Unmarshal(envVarsJSON, &cfg.EnvVars)
+
+	if audit == nil {
+		audit = &AuditLog{Actor: "system"}
+	}
+	audit.Action = "function.promote"
+	audit.ResourceType = "function"
+	audit.ResourceID = functionID
+	audit.Details = map[string]interface{}{
+		"from":         fromEnv,
+		"to":           toEnv,
+		"active_alias": cfg.ActiveAlias,
+		"memory_mb":    cfg.MemoryMB,
+		"timeout_sec":  cfg.TimeoutSec,
+	}
+	if err := insertAuditLog(tx, audit); err != nil {
+		return nil, fmt.Errorf("failed to record audit log: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // ==================== 函数任务管理 ====================
 
 // CreateFunctionTask 创建一个新的函数任务。
@@ -2933,6 +4035,33 @@ func (s *PostgresStore) UpdateFunctionStatus(id string, status domain.FunctionSt
 	return err
 }
 
+// BulkUpdateFunctionStatus 使用一条语句批量更新函数状态。
+// 用于发布编排等需要同时切换大量函数状态的场景，单个函数仍使用 UpdateFunctionStatus。
+//
+// 参数:
+//   - ids: 函数 ID 列表，不存在的 ID 会被忽略
+//   - status: 目标状态
+//   - message: 状态消息
+//
+// 返回值:
+//   - int64: 实际更新的函数数量
+//   - error: 更新失败时返回错误
+func (s *PostgresStore) BulkUpdateFunctionStatus(ids []string, status domain.FunctionStatus, message string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	query := `UPDATE functions SET status = $2, status_message = $3, updated_at = $4 WHERE id = ANY($1)`
+	result, err := s.db.Exec(query, pq.Array(ids), status, message, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to bulk update function status: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return affected, nil
+}
+
 // SetFunctionDeployed 标记函数部署成功。
 func (s *PostgresStore) SetFunctionDeployed(id string) error {
 	now := time.Now()
@@ -3070,25 +4199,128 @@ func (s *PostgresStore) ListDLQMessages(functionID, status string, offset, limit
 func (s *PostgresStore) UpdateDLQMessage(msg *domain.DeadLetterMessage) error {
 	query := `
 		UPDATE dead_letter_queue
-		SET retry_count = $2, status = $3, last_retry_at = $4, resolved_at = $5
+		SET retry_count = $2, status = $3, last_retry_at = $4, resolved_at = $5, error = $6
 		WHERE id = $1
 	`
-	_, err := s.db.Exec(query, msg.ID, msg.RetryCount, msg.Status, msg.LastRetryAt, msg.ResolvedAt)
+	_, err := s.db.Exec(query, msg.ID, msg.RetryCount, msg.Status, msg.LastRetryAt, msg.ResolvedAt, msg.Error)
 	return err
 }
 
-// DeleteDLQMessage 删除死信消息。
-func (s *PostgresStore) DeleteDLQMessage(id string) error {
-	_, err := s.db.Exec("DELETE FROM dead_letter_queue WHERE id = $1", id)
-	return err
-}
+// ListRetryableDLQMessages 查询已到达退避时间、可以自动重试的待处理死信消息。
+// 第 n 次重试（retry_count = n）在上次尝试（从未重试时为入队时间）后 baseDelay * 2^n 执行，
+// 指数不超过 maxExponent，按到期时间从早到晚返回。
+//
+// 参数:
+//   - baseDelay: 基础退避时间
+//   - maxExponent: 退避指数上限
+//   - limit: 最大返回条数
+//
+// 返回值:
+//   - []*domain.DeadLetterMessage: 可重试的死信消息
+//   - error: 查询失败时返回错误
+func (s *PostgresStore) ListRetryableDLQMessages(baseDelay time.Duration, maxExponent, limit int) ([]*domain.DeadLetterMessage, error) {
+	query := `
+		SELECT d.id, d.function_id, f.name, d.original_request_id, d.payload, d.error, d.retry_count, d.status, d.created_at, d.last_retry_at, d.resolved_at
+		FROM dead_letter_queue d
+		LEFT JOIN functions f ON d.function_id = f.id
+		WHERE d.status = $1
+		  AND COALESCE(d.last_retry_at, d.created_at) + make_interval(secs => $2 * power(2, LEAST(d.retry_count, $3))) <= NOW()
+		ORDER BY COALESCE(d.last_retry_at, d.created_at) + make_interval(secs => $2 * power(2, LEAST(d.retry_count, $3)))
+		LIMIT $4
+	`
+	rows, err := s.db.Query(query, domain.DLQStatusPending, baseDelay.Seconds(), maxExponent, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retryable dlq messages: %w", err)
+	}
+	defer rows.Close()
 
-// PurgeDLQMessages 清空指定函数的死信消息。
-func (s *PostgresStore) PurgeDLQMessages(functionID string) (int64, error) {
-	var result sql.Result
-	var err error
-	if functionID != "" {
-		result, err = s.db.Exec("DELETE FROM dead_letter_queue WHERE function_id = $1", functionID)
+	var messages []*domain.DeadLetterMessage
+	for rows.Next() {
+		msg := &domain.DeadLetterMessage{}
+		var functionName sql.NullString
+		var lastRetryAt, resolvedAt sql.NullTime
+		if err := rows.Scan(&msg.ID, &msg.FunctionID, &functionName, &msg.OriginalRequestID, &msg.Payload, &msg.Error,
+			&msg.RetryCount, &msg.Status, &msg.CreatedAt, &lastRetryAt, &resolvedAt); err != nil {
+			return nil, err
+		}
+		msg.FunctionName = functionName.String
+		if lastRetryAt.Valid {
+			msg.LastRetryAt = &lastRetryAt.Time
+		}
+		if resolvedAt.Valid {
+			msg.ResolvedAt = &resolvedAt.Time
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// ClaimDLQMessage 将待处理的死信消息标记为重试中，并递增重试次数、记录重试时间。
+// 只有状态仍为 pending 时才会更新，多个网关实例同时重试时只有一个能认领成功。
+//
+// 参数:
+//   - msg: 死信消息，认领成功时同步更新其状态、重试次数和重试时间
+//
+// 返回值:
+//   - bool: 是否认领成功
+//   - error: 更新失败时返回错误
+func (s *PostgresStore) ClaimDLQMessage(msg *domain.DeadLetterMessage) (bool, error) {
+	now := time.Now()
+	query := `
+		UPDATE dead_letter_queue
+		SET status = $2, retry_count = retry_count + 1, last_retry_at = $3
+		WHERE id = $1 AND status = $4
+		RETURNING retry_count
+	`
+	var retryCount int
+	err := s.db.QueryRow(query, msg.ID, domain.DLQStatusRetrying, now, domain.DLQStatusPending).Scan(&retryCount)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	msg.Status = domain.DLQStatusRetrying
+	msg.RetryCount = retryCount
+	msg.LastRetryAt = &now
+	return true, nil
+}
+
+// ReleaseStaleDLQClaims 将认领超过 olderThan 仍处于重试中的死信消息放回待处理状态。
+// 认领后网关崩溃或重试结果未能写回时，消息不会永远停留在 retrying，
+// 下一轮按已递增的重试次数继续退避。
+//
+// 参数:
+//   - olderThan: 认领超时时间，应大于一次重试调用的最长耗时
+//
+// 返回值:
+//   - int64: 放回待处理状态的消息数
+//   - error: 更新失败时返回错误
+func (s *PostgresStore) ReleaseStaleDLQClaims(olderThan time.Duration) (int64, error) {
+	query := `
+		UPDATE dead_letter_queue
+		SET status = $1
+		WHERE status = $2 AND last_retry_at < NOW() - make_interval(secs => $3)
+	`
+	result, err := s.db.Exec(query, domain.DLQStatusPending, domain.DLQStatusRetrying, olderThan.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to release stale dlq claims: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteDLQMessage 删除死信消息。
+func (s *PostgresStore) DeleteDLQMessage(id string) error {
+	_, err := s.db.Exec("DELETE FROM dead_letter_queue WHERE id = $1", id)
+	return err
+}
+
+// PurgeDLQMessages 清空指定函数的死信消息。
+func (s *PostgresStore) PurgeDLQMessages(functionID string) (int64, error) {
+	var result sql.Result
+	var err error
+	if functionID != "" {
+		result, err = s.db.Exec("DELETE FROM dead_letter_queue WHERE function_id = $1", functionID)
 	} else {
 		result, err = s.db.Exec("DELETE FROM dead_letter_queue")
 	}
@@ -3207,6 +4439,125 @@ func (s *PostgresStore) CleanupOldTasks(retentionDays int) (int64, error) {
 	return result.RowsAffected()
 }
 
+// ListInvocationRecordCaps 获取设置了调用记录条数上限的函数。
+//
+// 返回值:
+//   - map[string]int: 函数 ID 到保留条数上限的映射
+//   - error: 查询失败时返回错误
+func (s *PostgresStore) ListInvocationRecordCaps() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT id, max_invocation_records FROM functions WHERE max_invocation_records > 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	caps := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var limit int
+		if err := rows.Scan(&id, &limit); err != nil {
+			return nil, err
+		}
+		caps[id] = limit
+	}
+	return caps, rows.Err()
+}
+
+// TrimFunctionInvocations 只保留函数最近的 keepLatest 条调用记录，删除其余记录。
+//
+// 参数:
+//   - functionID: 函数 ID
+//   - keepLatest: 保留的最新记录条数，必须大于 0
+//
+// 返回值:
+//   - int64: 删除的记录数
+//   - error: 删除失败时返回错误
+func (s *PostgresStore) TrimFunctionInvocations(functionID string, keepLatest int) (int64, error) {
+	return s.trimFunctionInvocations(functionID, keepLatest, false)
+}
+
+// TrimFunctionInvocationsPreferFailed 与 TrimFunctionInvocations 相同，
+// 但在保留名额内优先保留失败和超时的调用记录，便于事后排查。
+func (s *PostgresStore) TrimFunctionInvocationsPreferFailed(functionID string, keepLatest int) (int64, error) {
+	return s.trimFunctionInvocations(functionID, keepLatest, true)
+}
+
+// trimFunctionInvocations 删除函数排名在 keepLatest 之后的调用记录。
+// preferFailed 为 true 时失败和超时的记录排在成功记录之前。
+func (s *PostgresStore) trimFunctionInvocations(functionID string, keepLatest int, preferFailed bool) (int64, error) {
+	if keepLatest <= 0 {
+		return 0, fmt.Errorf("keepLatest must be positive, got %d", keepLatest)
+	}
+	// id 作为排序兜底，使创建时间相同的记录也有确定的保留顺序
+	order := "created_at DESC, id DESC"
+	if preferFailed {
+		order = "(status IN ('failed', 'timeout')) DESC, created_at DESC, id DESC"
+	}
+
+	// SQL: 删除不在保留名额内的调用记录
+	query := `
+		DELETE FROM invocations
+		WHERE function_id = $1 AND id NOT IN (
+			SELECT id FROM invocations WHERE function_id = $1 ORDER BY ` + order + ` LIMIT $2
+		)
+	`
+	result, err := s.db.Exec(query, functionID, keepLatest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim invocations: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// staleInvocationStatuses 是可能因宿主崩溃而永远停留的非终态调用状态
+var staleInvocationStatuses = []string{string(domain.InvocationStatusPending), string(domain.InvocationStatusRunning)}
+
+// MarkStaleInvocationsFailed 将停留在非终态（pending、running）超过 olderThan 的调用标记为 failed，
+// 每次最多处理 limit 条，按创建时间从早到晚处理。
+// 这类记录通常是宿主在执行中途崩溃留下的，不会再被更新，会使统计和计费失真。
+// 停滞调用的实际执行时长未知，不计入计费时长（billed_time_ms 保持不变）。
+//
+// 参数:
+//   - olderThan: 创建时间距今超过该时长的非终态调用视为停滞
+//   - errorMsg: 写入调用记录的错误信息
+//   - limit: 本批最多标记的调用数
+//
+// 返回值:
+//   - []*domain.Invocation: 本批实际标记的调用（只含 ID、函数信息和标记前的状态）
+//   - error: 更新失败时返回错误
+func (s *PostgresStore) MarkStaleInvocationsFailed(olderThan time.Duration, errorMsg string, limit int) ([]*domain.Invocation, error) {
+	// SQL: 借助 (status, created_at) 索引锁定一批停滞调用，SKIP LOCKED 跳过正在被写回结果的记录，
+	// 只有更新成功的行才会返回，并带回标记前的状态用于统计
+	query := `
+		WITH stale AS (
+			SELECT id, status FROM invocations
+			WHERE status = ANY($3) AND created_at < $4
+			ORDER BY created_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE invocations i SET status = $1, error = $2, completed_at = NOW()
+		FROM stale
+		WHERE i.id = stale.id
+		RETURNING i.id, i.function_id, i.function_name, stale.status
+	`
+	rows, err := s.db.Query(query, domain.InvocationStatusFailed, errorMsg,
+		pq.Array(staleInvocationStatuses), time.Now().Add(-olderThan), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark stale invocations failed: %w", err)
+	}
+	defer rows.Close()
+
+	var invocations []*domain.Invocation
+	for rows.Next() {
+		inv := &domain.Invocation{}
+		if err := rows.Scan(&inv.ID, &inv.FunctionID, &inv.FunctionName, &inv.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan invocation: %w", err)
+		}
+		invocations = append(invocations, inv)
+	}
+	return invocations, rows.Err()
+}
+
 // RetentionStats 保留策略统计信息
 type RetentionStats struct {
 	TotalInvocations    int64 `json:"total_invocations"`
@@ -3264,6 +4615,11 @@ type AuditLog struct {
 
 // CreateAuditLog 创建审计日志。
 func (s *PostgresStore) CreateAuditLog(log *AuditLog) error {
+	return insertAuditLog(s.db, log)
+}
+
+// insertAuditLog 写入一条审计日志，db 可以是事务，使审计日志与被审计的修改一起提交
+func insertAuditLog(db sqlExecutor, log *AuditLog) error {
 	if log.ID == "" {
 		log.ID = uuid.New().String()
 	}
@@ -3275,7 +4631,7 @@ func (s *PostgresStore) CreateAuditLog(log *AuditLog) error {
 		INSERT INTO audit_logs (id, action, resource_type, resource_id, resource_name, actor, actor_ip, details, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
-	_, err := s.db.Exec(query, log.ID, log.Action, log.ResourceType, log.ResourceID, log.ResourceName, log.Actor, log.ActorIP, detailsJSON, log.CreatedAt)
+	_, err := db.Exec(query, log.ID, log.Action, log.ResourceType, log.ResourceID, log.ResourceName, log.Actor, log.ActorIP, detailsJSON, log.CreatedAt)
 	return err
 }
 
@@ -3661,8 +5017,8 @@ func (s *PostgresStore) CreateExecution(exec *domain.WorkflowExecution) error {
 	}
 
 	query := `
-		INSERT INTO workflow_executions (id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		INSERT INTO workflow_executions (id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, wait_until, wait_input, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
 	`
 	definition := exec.WorkflowDefinition
 	if len(definition) == 0 {
@@ -3674,6 +5030,11 @@ func (s *PostgresStore) CreateExecution(exec *domain.WorkflowExecution) error {
 		s := string(exec.PausedInput)
 		pausedInputStr = &s
 	}
+	var waitInputStr *string
+	if len(exec.WaitInput) > 0 {
+		s := string(exec.WaitInput)
+		waitInputStr = &s
+	}
 
 	_, err := s.db.Exec(query,
 		exec.ID, exec.WorkflowID, exec.WorkflowName, exec.WorkflowVersion, string(definition), exec.Status,
@@ -3681,6 +5042,7 @@ func (s *PostgresStore) CreateExecution(exec *domain.WorkflowExecution) error {
 		exec.StartedAt, exec.CompletedAt, exec.TimeoutAt,
 		sql.NullString{String: exec.PausedAtState, Valid: exec.PausedAtState != ""},
 		pausedInputStr, exec.PausedAt,
+		exec.WaitUntil, waitInputStr,
 		exec.CreatedAt, exec.UpdatedAt,
 	)
 	if err != nil {
@@ -3692,7 +5054,7 @@ func (s *PostgresStore) CreateExecution(exec *domain.WorkflowExecution) error {
 // GetExecutionByID 根据 ID 获取执行实例。
 func (s *PostgresStore) GetExecutionByID(id string) (*domain.WorkflowExecution, error) {
 	query := `
-		SELECT id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, created_at, updated_at
+		SELECT id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, wait_until, wait_input, created_at, updated_at
 		FROM workflow_executions WHERE id = $1
 	`
 	return s.scanExecution(s.db.QueryRow(query, id))
@@ -3701,15 +5063,16 @@ func (s *PostgresStore) GetExecutionByID(id string) (*domain.WorkflowExecution,
 // scanExecution 扫描执行实例行
 func (s *PostgresStore) scanExecution(row *sql.Row) (*domain.WorkflowExecution, error) {
 	exec := &domain.WorkflowExecution{}
-	var input, output, definition, pausedInput []byte
+	var input, output, definition, pausedInput, waitInput []byte
 	var errorMsg, errorCode, currentState, pausedAtState sql.NullString
-	var startedAt, completedAt, timeoutAt, pausedAt sql.NullTime
+	var startedAt, completedAt, timeoutAt, pausedAt, waitUntil sql.NullTime
 
 	err := row.Scan(
 		&exec.ID, &exec.WorkflowID, &exec.WorkflowName, &exec.WorkflowVersion, &definition, &exec.Status,
 		&input, &output, &errorMsg, &errorCode, &currentState,
 		&startedAt, &completedAt, &timeoutAt,
 		&pausedAtState, &pausedInput, &pausedAt,
+		&waitUntil, &waitInput,
 		&exec.CreatedAt, &exec.UpdatedAt,
 	)
 	if err != nil {
@@ -3723,6 +5086,7 @@ func (s *PostgresStore) scanExecution(row *sql.Row) (*domain.WorkflowExecution,
 	exec.Output = output
 	exec.WorkflowDefinition = definition
 	exec.PausedInput = pausedInput
+	exec.WaitInput = waitInput
 	if errorMsg.Valid {
 		exec.Error = errorMsg.String
 	}
@@ -3747,6 +5111,9 @@ func (s *PostgresStore) scanExecution(row *sql.Row) (*domain.WorkflowExecution,
 	if pausedAt.Valid {
 		exec.PausedAt = &pausedAt.Time
 	}
+	if waitUntil.Valid {
+		exec.WaitUntil = &waitUntil.Time
+	}
 
 	return exec, nil
 }
@@ -3760,7 +5127,7 @@ func (s *PostgresStore) ListExecutions(workflowID string, offset, limit int) ([]
 	}
 
 	query := `
-		SELECT id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, created_at, updated_at
+		SELECT id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, wait_until, wait_input, created_at, updated_at
 		FROM workflow_executions
 		WHERE workflow_id = $1
 		ORDER BY created_at DESC LIMIT $2 OFFSET $3
@@ -3783,7 +5150,7 @@ func (s *PostgresStore) ListAllExecutions(offset, limit int) ([]*domain.Workflow
 	}
 
 	query := `
-		SELECT id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, created_at, updated_at
+		SELECT id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, wait_until, wait_input, created_at, updated_at
 		FROM workflow_executions
 		ORDER BY created_at DESC LIMIT $1 OFFSET $2
 	`
@@ -3801,15 +5168,16 @@ func (s *PostgresStore) scanExecutions(rows *sql.Rows, total int) ([]*domain.Wor
 	var executions []*domain.WorkflowExecution
 	for rows.Next() {
 		exec := &domain.WorkflowExecution{}
-		var input, output, definition, pausedInput []byte
+		var input, output, definition, pausedInput, waitInput []byte
 		var errorMsg, errorCode, currentState, pausedAtState sql.NullString
-		var startedAt, completedAt, timeoutAt, pausedAt sql.NullTime
+		var startedAt, completedAt, timeoutAt, pausedAt, waitUntil sql.NullTime
 
 		err := rows.Scan(
 			&exec.ID, &exec.WorkflowID, &exec.WorkflowName, &exec.WorkflowVersion, &definition, &exec.Status,
 			&input, &output, &errorMsg, &errorCode, &currentState,
 			&startedAt, &completedAt, &timeoutAt,
 			&pausedAtState, &pausedInput, &pausedAt,
+			&waitUntil, &waitInput,
 			&exec.CreatedAt, &exec.UpdatedAt,
 		)
 		if err != nil {
@@ -3820,6 +5188,7 @@ func (s *PostgresStore) scanExecutions(rows *sql.Rows, total int) ([]*domain.Wor
 		exec.Output = output
 		exec.WorkflowDefinition = definition
 		exec.PausedInput = pausedInput
+		exec.WaitInput = waitInput
 		if errorMsg.Valid {
 			exec.Error = errorMsg.String
 		}
@@ -3844,6 +5213,9 @@ func (s *PostgresStore) scanExecutions(rows *sql.Rows, total int) ([]*domain.Wor
 		if pausedAt.Valid {
 			exec.PausedAt = &pausedAt.Time
 		}
+		if waitUntil.Valid {
+			exec.WaitUntil = &waitUntil.Time
+		}
 		executions = append(executions, exec)
 	}
 
@@ -3866,7 +5238,7 @@ func (s *PostgresStore) UpdateExecution(exec *domain.WorkflowExecution) error {
 
 	query := `
 		UPDATE workflow_executions
-		SET status = $2, input = $3, output = $4, error = $5, error_code = $6, current_state = $7, started_at = $8, completed_at = $9, timeout_at = $10, paused_at_state = $11, paused_input = $12, paused_at = $13, updated_at = $14
+		SET status = $2, input = $3, output = $4, error = $5, error_code = $6, current_state = $7, started_at = $8, completed_at = $9, timeout_at = $10, paused_at_state = $11, paused_input = $12, paused_at = $13, wait_until = $14, wait_input = $15, updated_at = $16
 		WHERE id = $1
 	`
 	// Convert []byte to string for JSONB columns (pq driver requires string for JSONB)
@@ -3875,11 +5247,17 @@ func (s *PostgresStore) UpdateExecution(exec *domain.WorkflowExecution) error {
 		s := string(exec.PausedInput)
 		pausedInputStr = &s
 	}
+	var waitInputStr *string
+	if len(exec.WaitInput) > 0 {
+		s := string(exec.WaitInput)
+		waitInputStr = &s
+	}
 	result, err := s.db.Exec(query,
 		exec.ID, exec.Status, string(input), string(output), exec.Error, exec.ErrorCode, exec.CurrentState,
 		exec.StartedAt, exec.CompletedAt, exec.TimeoutAt,
 		sql.NullString{String: exec.PausedAtState, Valid: exec.PausedAtState != ""},
 		pausedInputStr, exec.PausedAt,
+		exec.WaitUntil, waitInputStr,
 		exec.UpdatedAt,
 	)
 	if err != nil {
@@ -3899,9 +5277,9 @@ func (s *PostgresStore) UpdateExecution(exec *domain.WorkflowExecution) error {
 // ListPendingExecutions 列出待处理的执行实例（用于恢复）。
 func (s *PostgresStore) ListPendingExecutions(limit int) ([]*domain.WorkflowExecution, error) {
 	query := `
-		SELECT id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, created_at, updated_at
+		SELECT id, workflow_id, workflow_name, workflow_version, workflow_definition, status, input, output, error, error_code, current_state, started_at, completed_at, timeout_at, paused_at_state, paused_input, paused_at, wait_until, wait_input, created_at, updated_at
 		FROM workflow_executions
-		WHERE status IN ('pending', 'running')
+		WHERE status IN ('pending', 'running', 'waiting')
 		ORDER BY created_at ASC LIMIT $1
 	`
 	rows, err := s.db.Query(query, limit)
@@ -4080,6 +5458,89 @@ func (s *PostgresStore) UpdateStateExecution(stateExec *domain.StateExecution) e
 	return nil
 }
 
+// GetWorkflowLatencyBreakdown 计算工作流执行的端到端延迟分解。
+// 将每个状态的 invocation_id 关联到 invocations 表，把状态墙钟耗时拆分为
+// 函数执行耗时和编排开销（排队、冷启动、状态转换）。
+//
+// 参数:
+//   - executionID: 工作流执行 ID
+//
+// 返回值:
+//   - *domain.WorkflowLatencyBreakdown: 延迟分解结果
+//   - error: 执行不存在时返回 domain.ErrExecutionNotFound
+func (s *PostgresStore) GetWorkflowLatencyBreakdown(executionID string) (*domain.WorkflowLatencyBreakdown, error) {
+	exec, err := s.GetExecutionByID(executionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQL: 关联状态执行与其函数调用记录，非 Task 状态没有匹配的调用
+	query := `
+		SELECT se.state_name, se.state_type, se.status, se.invocation_id, se.started_at, se.completed_at,
+			i.created_at, i.started_at, i.duration_ms, i.cold_start
+		FROM state_executions se
+		LEFT JOIN invocations i ON i.id = se.invocation_id
+		WHERE se.execution_id = $1
+		ORDER BY se.created_at ASC
+	`
+	rows, err := s.db.Query(query, executionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workflow latency: %w", err)
+	}
+	defer rows.Close()
+
+	breakdown := &domain.WorkflowLatencyBreakdown{
+		ExecutionID: executionID,
+		States:      []domain.StateLatency{},
+	}
+	var statesWallMs int64
+	for rows.Next() {
+		var sl domain.StateLatency
+		var invocationID sql.NullString
+		var stateStartedAt, stateCompletedAt, invCreatedAt, invStartedAt sql.NullTime
+		var durationMs sql.NullInt64
+		var coldStart sql.NullBool
+		if err := rows.Scan(
+			&sl.StateName, &sl.StateType, &sl.Status, &invocationID, &stateStartedAt, &stateCompletedAt,
+			&invCreatedAt, &invStartedAt, &durationMs, &coldStart,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan workflow latency: %w", err)
+		}
+
+		sl.InvocationID = invocationID.String
+		if stateStartedAt.Valid && stateCompletedAt.Valid {
+			sl.WallTimeMs = stateCompletedAt.Time.Sub(stateStartedAt.Time).Milliseconds()
+		}
+		sl.FunctionMs = durationMs.Int64
+		if overhead := sl.WallTimeMs - sl.FunctionMs; overhead > 0 {
+			sl.OverheadMs = overhead
+		}
+		if invCreatedAt.Valid && invStartedAt.Valid {
+			sl.QueueMs = invStartedAt.Time.Sub(invCreatedAt.Time).Milliseconds()
+		}
+		sl.ColdStart = coldStart.Bool
+
+		breakdown.FunctionMs += sl.FunctionMs
+		breakdown.OverheadMs += sl.OverheadMs
+		if sl.ColdStart {
+			breakdown.ColdStarts++
+		}
+		statesWallMs += sl.WallTimeMs
+		breakdown.States = append(breakdown.States, sl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate workflow latency: %w", err)
+	}
+
+	if exec.StartedAt != nil && exec.CompletedAt != nil {
+		breakdown.TotalMs = exec.CompletedAt.Sub(*exec.StartedAt).Milliseconds()
+		if transition := breakdown.TotalMs - statesWallMs; transition > 0 {
+			breakdown.TransitionMs = transition
+		}
+	}
+	return breakdown, nil
+}
+
 // ==================== 模板仓库实现 ====================
 
 // CreateTemplate 创建一个新的模板记录。
@@ -4430,3 +5891,472 @@ func (s *PostgresStore) DeleteBreakpoint(executionID, beforeState string) error
 	}
 	return nil
 }
+
+// ==================== 调用结果目标存储方法 ====================
+
+// GetFunctionDestinations 获取函数的结果投递配置。
+// 未配置时返回仅包含 FunctionID 的空配置。
+func (s *PostgresStore) GetFunctionDestinations(functionID string) (*domain.DestinationConfig, error) {
+	query := `
+		SELECT function_id, on_success, on_failure, updated_at
+		FROM function_destinations
+		WHERE function_id = $1
+	`
+	cfg := &domain.DestinationConfig{}
+	var onSuccessJSON, onFailureJSON []byte
+	err := s.db.QueryRow(query, functionID).Scan(&cfg.FunctionID, &onSuccessJSON, &onFailureJSON, &cfg.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &domain.DestinationConfig{FunctionID: functionID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get function destinations: %w", err)
+	}
+	if len(onSuccessJSON) > 0 {
+		if err := json.Unmarshal(onSuccessJSON, &cfg.OnSuccess); err != nil {
+			return nil, fmt.Errorf("invalid on_success destination for function %s: %w", functionID, err)
+		}
+	}
+	if len(onFailureJSON) > 0 {
+		if err := json.Unmarshal(onFailureJSON, &cfg.OnFailure); err != nil {
+			return nil, fmt.Errorf("invalid on_failure destination for function %s: %w", functionID, err)
+		}
+	}
+	return cfg, nil
+}
+
+// SetFunctionDestinations 保存函数的结果投递配置（存在则覆盖）。
+func (s *PostgresStore) SetFunctionDestinations(cfg *domain.DestinationConfig) error {
+	cfg.UpdatedAt = time.Now()
+
+	var onSuccessJSON, onFailureJSON sql.NullString
+	if cfg.OnSuccess != nil {
+		data, _ := json.Marshal(cfg.OnSuccess)
+		onSuccessJSON = sql.NullString{String: string(data), Valid: true}
+	}
+	if cfg.OnFailure != nil {
+		data, _ := json.Marshal(cfg.OnFailure)
+		onFailureJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	query := `
+		INSERT INTO function_destinations (function_id, on_success, on_failure, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (function_id) DO UPDATE SET
+			on_success = EXCLUDED.on_success,
+			on_failure = EXCLUDED.on_failure,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := s.db.Exec(query, cfg.FunctionID, onSuccessJSON, onFailureJSON, cfg.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set function destinations: %w", err)
+	}
+	return nil
+}
+
+// ==================== 节点注册存储方法 ====================
+
+// RegisterNode 注册节点或刷新已注册节点的标签和最近注册时间。
+func (s *PostgresStore) RegisterNode(node *domain.Node) error {
+	node.LastSeenAt = time.Now()
+	labelsJSON, _ := json.Marshal(node.Labels)
+	if node.Labels == nil {
+		labelsJSON = []byte("{}")
+	}
+
+	query := `
+		INSERT INTO nodes (name, labels, last_seen_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET
+			labels = EXCLUDED.labels,
+			last_seen_at = EXCLUDED.last_seen_at
+	`
+	if _, err := s.db.Exec(query, node.Name, labelsJSON, node.LastSeenAt); err != nil {
+		return fmt.Errorf("failed to register node: %w", err)
+	}
+	return nil
+}
+
+// ListNodes 获取所有已注册的节点，按名称排序。
+func (s *PostgresStore) ListNodes() ([]*domain.Node, error) {
+	rows, err := s.db.Query(`SELECT name, labels, last_seen_at FROM nodes ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	defer rows.Close()
+
+	var nodes []*domain.Node
+	for rows.Next() {
+		node := &domain.Node{}
+		var labelsJSON []byte
+		if err := rows.Scan(&node.Name, &labelsJSON, &node.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan node: %w", err)
+		}
+		json.Unmarshal(labelsJSON, &node.Labels)
+		nodes = append(nodes, node)
+	}
+	return nodes, rows.Err()
+}
+
+// nodeSelectorValue 将节点选择器转换为可写入 JSONB 列的值，空选择器写入 NULL
+func nodeSelectorValue(selector map[string]string) sql.NullString {
+	if len(selector) == 0 {
+		return sql.NullString{}
+	}
+	data, _ := json.Marshal(selector)
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// ioLimitsValue 将函数 IO 限速转换为可写入 JSONB 列的值，未设置限速时写入 NULL
+func ioLimitsValue(limits *domain.IOLimits) sql.NullString {
+	if limits.IsZero() {
+		return sql.NullString{}
+	}
+	data, _ := json.Marshal(limits)
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// rateLimitValue 将函数调用限流转换为可写入 JSONB 列的值，未设置限流时写入 NULL
+func rateLimitValue(limit *domain.InvokeRateLimit) sql.NullString {
+	if limit.IsZero() {
+		return sql.NullString{}
+	}
+	data, _ := json.Marshal(limit)
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// inputSchemaValue 将函数输入 schema 转换为可写入 JSONB 列的值，未设置时写入 NULL
+func inputSchemaValue(schema json.RawMessage) sql.NullString {
+	if !domain.HasInputSchema(schema) {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(schema), Valid: true}
+}
+
+// ClaimCronFire 认领函数定时任务在 scheduledAt 的一次触发。
+// 只有 scheduledAt 晚于已记录的最近触发时间时才认领成功，多个网关实例或重启后对同一计划时间只有一次认领成功。
+//
+// 参数:
+//   - functionID: 函数 ID
+//   - scheduledAt: 本次触发的计划时间
+//
+// 返回值:
+//   - bool: 是否认领成功，false 表示该计划时间已被触发过（或函数不存在）
+//   - error: 更新失败时返回错误信息
+func (s *PostgresStore) ClaimCronFire(functionID string, scheduledAt time.Time) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE functions SET cron_last_fired_at = $2
+		WHERE id = $1 AND (cron_last_fired_at IS NULL OR cron_last_fired_at < $2)
+	`, functionID, scheduledAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim cron fire: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ==================== 函数所有者存储方法 ====================
+
+// TransferFunctionOwnership 将函数转移给新的所有者。
+// 读取原所有者和更新在同一条语句中完成，并发转移时返回的原所有者与实际被替换的值一致。
+//
+// 参数:
+//   - id: 函数 ID
+//   - newOwnerID: 新所有者的用户 ID
+//
+// 返回值:
+//   - string: 转移前的所有者，为空表示此前没有所有者
+//   - error: 函数不存在时返回 domain.ErrFunctionNotFound
+func (s *PostgresStore) TransferFunctionOwnership(id, newOwnerID string) (string, error) {
+	query := `
+		UPDATE functions f SET owner_id = $2, updated_at = $3
+		FROM (SELECT id, owner_id FROM functions WHERE id = $1 FOR UPDATE) old
+		WHERE f.id = old.id
+		RETURNING old.owner_id
+	`
+	var oldOwnerID sql.NullString
+	err := s.db.QueryRow(query, id, newOwnerID, time.Now()).Scan(&oldOwnerID)
+	if err == sql.ErrNoRows {
+		return "", domain.ErrFunctionNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to transfer function ownership: %w", err)
+	}
+	return oldOwnerID.String, nil
+}
+
+// ListFunctionsByOwner 分页获取指定所有者的函数列表，置顶函数优先，按创建时间倒序排列。
+//
+// 参数:
+//   - ownerID: 所有者的用户 ID
+//   - offset: 跳过的记录数（用于分页）
+//   - limit: 返回的最大记录数
+//
+// 返回值:
+//   - []*domain.Function: 函数列表
+//   - int: 该所有者的函数总数（用于分页计算）
+//   - error: 查询失败时返回错误信息
+func (s *PostgresStore) ListFunctionsByOwner(ownerID string, offset, limit int) ([]*domain.Function, int, error) {
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM functions WHERE owner_id = $1`, ownerID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count functions by owner: %w", err)
+	}
+
+	query := `
+		SELECT ` + functionColumns + `
+		FROM functions WHERE owner_id = $1
+		ORDER BY pinned DESC, created_at DESC LIMIT $2 OFFSET $3
+	`
+	rows, err := s.db.Query(query, ownerID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list functions by owner: %w", err)
+	}
+	defer rows.Close()
+
+	functions := make([]*domain.Function, 0, limit)
+	for rows.Next() {
+		fn, err := s.scanFunctionRow(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		functions = append(functions, fn)
+	}
+	return functions, total, rows.Err()
+}
+
+// ==================== 金丝雀发布存储方法 ====================
+
+// canaryColumns 是查询金丝雀发布时使用的列
+const canaryColumns = `id, function_id, alias_name, stable_version, canary_version, weight, step_weight, healthy_duration_sec,
+	min_invocations, max_error_rate_delta, max_latency_ratio, status, step_started_at, healthy_since, decisions, created_at, updated_at`
+
+// CreateCanaryDeployment 创建金丝雀发布记录。
+//
+// 参数:
+//   - c: 金丝雀发布对象，未提供 ID 时自动生成
+//
+// 返回值:
+//   - error: 创建失败时返回错误信息
+func (s *PostgresStore) CreateCanaryDeployment(c *domain.CanaryDeployment) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = c.CreatedAt
+
+	decisionsJSON, _ := json.Marshal(c.Decisions)
+	query := `INSERT INTO canary_deployments (` + canaryColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
+	_, err := s.db.Exec(query,
+		c.ID, c.FunctionID, c.AliasName, c.StableVersion, c.CanaryVersion, c.Weight, c.StepWeight, c.HealthyDurationSec,
+		c.MinInvocations, c.MaxErrorRateDelta, c.MaxLatencyRatio, c.Status, c.StepStartedAt, c.HealthySince, decisionsJSON,
+		c.CreatedAt, c.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create canary deployment: %w", err)
+	}
+	return nil
+}
+
+// GetCanaryDeployment 根据 ID 获取金丝雀发布。
+//
+// 返回值:
+//   - *domain.CanaryDeployment: 金丝雀发布对象
+//   - error: 不存在时返回 domain.ErrCanaryNotFound
+func (s *PostgresStore) GetCanaryDeployment(id string) (*domain.CanaryDeployment, error) {
+	row := s.db.QueryRow(`SELECT `+canaryColumns+` FROM canary_deployments WHERE id = $1`, id)
+	return scanCanaryDeployment(row)
+}
+
+// GetRunningCanaryDeployment 获取别名上进行中的金丝雀发布。
+//
+// 返回值:
+//   - *domain.CanaryDeployment: 金丝雀发布对象
+//   - error: 没有进行中的发布时返回 domain.ErrCanaryNotFound
+func (s *PostgresStore) GetRunningCanaryDeployment(functionID, aliasName string) (*domain.CanaryDeployment, error) {
+	row := s.db.QueryRow(`SELECT `+canaryColumns+` FROM canary_deployments
+		WHERE function_id = $1 AND alias_name = $2 AND status = 'running'`, functionID, aliasName)
+	return scanCanaryDeployment(row)
+}
+
+// ListCanaryDeployments 获取金丝雀发布列表，按创建时间倒序排列。
+//
+// 参数:
+//   - functionID: 函数 ID，为空表示所有函数
+//   - status: 发布状态，为空表示所有状态
+func (s *PostgresStore) ListCanaryDeployments(functionID string, status domain.CanaryStatus) ([]*domain.CanaryDeployment, error) {
+	query := `SELECT ` + canaryColumns + ` FROM canary_deployments
+		WHERE ($1 = '' OR function_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC`
+	rows, err := s.db.Query(query, functionID, string(status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list canary deployments: %w", err)
+	}
+	defer rows.Close()
+
+	deployments := make([]*domain.CanaryDeployment, 0)
+	for rows.Next() {
+		c, err := scanCanaryDeployment(rows)
+		if err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, c)
+	}
+	return deployments, rows.Err()
+}
+
+// UpdateCanaryDeployment 保存金丝雀发布的权重、状态和决策记录。
+// 只有数据库中仍在进行中的发布才会被更新，已结束的发布不会被并发的评估或终止操作改写。
+//
+// 返回值:
+//   - error: 不存在时返回 domain.ErrCanaryNotFound，已结束时返回 domain.ErrCanaryNotRunning
+func (s *PostgresStore) UpdateCanaryDeployment(c *domain.CanaryDeployment) error {
+	return s.updateCanaryDeployment(s.db, c)
+}
+
+// ApplyCanaryDeployment 在一个事务中保存金丝雀发布并按其当前权重更新别名的路由配置。
+// 发布记录的更新以其仍在进行中为条件并锁定该行，并发的评估和终止操作按顺序生效，
+// 别名权重始终与最后一次生效的发布记录一致。
+//
+// 返回值:
+//   - error: 发布不存在时返回 domain.ErrCanaryNotFound，已结束时返回 domain.ErrCanaryNotRunning，
+//     别名不存在时返回 domain.ErrAliasNotFound
+func (s *PostgresStore) ApplyCanaryDeployment(c *domain.CanaryDeployment) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.updateCanaryDeployment(tx, c); err != nil {
+		return err
+	}
+	routingJSON, _ := json.Marshal(c.RoutingConfig())
+	result, err := tx.Exec(`UPDATE function_aliases SET routing_config = $3, updated_at = $4 WHERE function_id = $1 AND name = $2`,
+		c.FunctionID, c.AliasName, routingJSON, c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update alias %s: %w", c.AliasName, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrAliasNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.notifyAliasChanged(c.FunctionID, c.AliasName)
+	return nil
+}
+
+// updateCanaryDeployment 以发布仍在进行中为条件更新发布记录
+func (s *PostgresStore) updateCanaryDeployment(db sqlExecutor, c *domain.CanaryDeployment) error {
+	c.UpdatedAt = time.Now()
+	decisionsJSON, _ := json.Marshal(c.Decisions)
+	query := `
+		UPDATE canary_deployments SET
+			weight = $2, status = $3, step_started_at = $4, healthy_since = $5, decisions = $6, updated_at = $7
+		WHERE id = $1 AND status = 'running'
+	`
+	result, err := db.Exec(query, c.ID, c.Weight, c.Status, c.StepStartedAt, c.HealthySince, decisionsJSON, c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update canary deployment: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// 没有更新任何行：区分发布不存在和已结束
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM canary_deployments WHERE id = $1)`, c.ID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check canary deployment: %w", err)
+	}
+	if !exists {
+		return domain.ErrCanaryNotFound
+	}
+	return domain.ErrCanaryNotRunning
+}
+
+// scanCanaryDeployment 从查询结果中扫描金丝雀发布
+func scanCanaryDeployment(row interface{ Scan(...interface{}) error }) (*domain.CanaryDeployment, error) {
+	c := &domain.CanaryDeployment{}
+	var healthySince sql.NullTime
+	var decisionsJSON []byte
+	err := row.Scan(
+		&c.ID, &c.FunctionID, &c.AliasName, &c.StableVersion, &c.CanaryVersion, &c.Weight, &c.StepWeight, &c.HealthyDurationSec,
+		&c.MinInvocations, &c.MaxErrorRateDelta, &c.MaxLatencyRatio, &c.Status, &c.StepStartedAt, &healthySince, &decisionsJSON,
+		&c.CreatedAt, &c.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrCanaryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan canary deployment: %w", err)
+	}
+	if healthySince.Valid {
+		c.HealthySince = &healthySince.Time
+	}
+	json.Unmarshal(decisionsJSON, &c.Decisions)
+	return c, nil
+}
+
+// CompareVersionStats 对比函数两个版本自 since 起已完成调用的错误率和耗时。
+//
+// 参数:
+//   - functionID: 函数 ID
+//   - baselineVersion: 基线版本号
+//   - candidateVersion: 候选版本号
+//   - since: 统计窗口起始时间
+//
+// 返回值:
+//   - *domain.VersionComparison: 版本指标对比，没有调用的版本指标为 0
+//   - error: 查询失败时返回错误
+func (s *PostgresStore) CompareVersionStats(functionID string, baselineVersion, candidateVersion int, since time.Time) (*domain.VersionComparison, error) {
+	// SQL: 按版本统计已完成调用的次数、错误数和耗时
+	query := `
+		SELECT version,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status IN ('failed', 'timeout')),
+			COALESCE(AVG(duration_ms), 0),
+			COALESCE(PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY duration_ms), 0)
+		FROM invocations
+		WHERE function_id = $1 AND version IN ($2, $3) AND created_at >= $4
+			AND status IN ('success', 'failed', 'timeout')
+		GROUP BY version
+	`
+	rows, err := s.db.Query(query, functionID, baselineVersion, candidateVersion, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare version stats: %w", err)
+	}
+	defer rows.Close()
+
+	baseline := domain.VersionStats{Version: baselineVersion}
+	candidate := domain.VersionStats{Version: candidateVersion}
+	for rows.Next() {
+		var st domain.VersionStats
+		if err := rows.Scan(&st.Version, &st.Invocations, &st.Errors, &st.AvgDurationMs, &st.P95DurationMs); err != nil {
+			return nil, fmt.Errorf("failed to scan version stats: %w", err)
+		}
+		if st.Invocations > 0 {
+			st.ErrorRate = float64(st.Errors) / float64(st.Invocations)
+		}
+		switch st.Version {
+		case baselineVersion:
+			baseline = st
+		case candidateVersion:
+			candidate = st
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return domain.NewVersionComparison(baseline, candidate, since), nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/auth"
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+)
+
+func TestRotateAPIKey(t *testing.T) {
+	cols := []string{"name", "user_id", "role", "scopes", "expires_at"}
+	expiry := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	db := sqltest.New().On("FROM api_keys WHERE id = $1 AND (expires_at IS NULL OR expires_at > NOW()) FOR UPDATE",
+		sqltest.Rows(cols, []driver.Value{"ci", "user-1", "admin", "{functions:read}", nil}),
+		sqltest.Rows(cols, []driver.Value{"ci", "user-1", "admin", "{functions:read}", expiry}),
+		sqltest.Rows(cols),
+	)
+	store := newSQLTestStore(t, db)
+
+	newKey, err := store.RotateAPIKey("key-1", time.Hour)
+	if err != nil {
+		t.Fatalf("RotateAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(newKey, "fn_") {
+		t.Fatalf("RotateAPIKey() = %q, want a new fn_ key", newKey)
+	}
+	queries := db.Queries()
+	if len(queries) != 5 || queries[0] != sqltest.Begin || queries[4] != sqltest.Commit {
+		t.Fatalf("queries = %q, want the lookup, insert and update in one transaction", queries)
+	}
+	if args := db.Calls()[1].Args; len(args) != 1 || args[0] != "key-1" {
+		t.Errorf("lookup args = %v, want [key-1]", args)
+	}
+
+	// 新密钥属于同一用户、角色和权限范围，数据库只保存哈希
+	insert := db.Calls()[2].Values()
+	if insert["key_hash"] != auth.HashAPIKey(newKey) || insert["id"] == "key-1" || insert["name"] != "ci" ||
+		insert["user_id"] != "user-1" || insert["role"] != "admin" || !strings.Contains(insert["scopes"].(string), "functions:read") {
+		t.Errorf("insert values = %v, want a copy of key-1 with the new hash", insert)
+	}
+	if insert["expires_at"] != nil {
+		t.Errorf("new key expires_at = %v, want NULL inherited from a key without expiry", insert["expires_at"])
+	}
+
+	// 旧密钥的过期时间缩短到宽限期结束，LEAST 保证不推迟原有的过期时间
+	update := db.Calls()[3]
+	if !strings.Contains(update.Query, "SET expires_at = LEAST(expires_at, NOW() + $2 * INTERVAL '1 millisecond') WHERE id = $1") {
+		t.Errorf("update query = %s, want the grace period capped by the original expiry", update.Query)
+	}
+	if len(update.Args) != 2 || update.Args[0] != "key-1" || update.Args[1] != int64(time.Hour/time.Millisecond) {
+		t.Errorf("update args = %v, want [key-1 3600000]", update.Args)
+	}
+
+	// 新密钥继承原有的过期时间
+	if _, err := store.RotateAPIKey("key-1", 0); err != nil {
+		t.Fatalf("RotateAPIKey() error = %v", err)
+	}
+	inserts := db.Find("INSERT INTO api_keys")
+	if got, ok := inserts[1].Values()["expires_at"].(time.Time); !ok || !got.Equal(expiry) {
+		t.Errorf("new key expires_at = %v, want %v", inserts[1].Values()["expires_at"], expiry)
+	}
+
+	// 旧密钥不存在或已过期时不写入
+	if _, err := store.RotateAPIKey("key-1", time.Hour); !errors.Is(err, auth.ErrAPIKeyNotFound) {
+		t.Errorf("rotating expired key error = %v, want ErrAPIKeyNotFound", err)
+	}
+	if len(db.Find("INSERT INTO api_keys")) != 2 || len(db.Find(sqltest.Commit)) != 2 {
+		t.Errorf("rotating an expired key wrote data: %q", db.Queries())
+	}
+}
+
+func TestGetAPIKeyByHashSkipsExpiredKeys(t *testing.T) {
+	db := sqltest.New().On("FROM api_keys WHERE key_hash = $1",
+		sqltest.Rows([]string{"id", "user_id", "role", "scopes"}, []driver.Value{"key-2", "user-1", "admin", "{functions:read}"}),
+		sqltest.Rows([]string{"id", "user_id", "role", "scopes"}),
+	)
+	store := newSQLTestStore(t, db)
+
+	id, userID, role, scopes, err := store.GetAPIKeyByHash("hash-1")
+	if err != nil || id != "key-2" || userID != "user-1" || role != "admin" || len(scopes) != 1 || scopes[0] != "functions:read" {
+		t.Fatalf("GetAPIKeyByHash() = %q %q %q %v %v", id, userID, role, scopes, err)
+	}
+	call := db.Calls()[0]
+	if !strings.Contains(call.Query, "AND (expires_at IS NULL OR expires_at > NOW())") || call.Args[0] != "hash-1" {
+		t.Errorf("query = %s args = %v, want only unexpired keys for hash-1", call.Query, call.Args)
+	}
+	if _, _, _, _, err := store.GetAPIKeyByHash("hash-1"); err == nil {
+		t.Error("GetAPIKeyByHash() without rows succeeded, want not found")
+	}
+}
+
+func TestRotateAPIKeyRejectsNegativeGrace(t *testing.T) {
+	db := sqltest.New()
+	if _, err := newSQLTestStore(t, db).RotateAPIKey("key-1", -time.Second); err == nil {
+		t.Errorf("RotateAPIKey(-1s) error = %v, want validation error", err)
+	}
+	if len(db.Calls()) != 0 {
+		t.Errorf("queries = %q, want none for a rejected grace", db.Queries())
+	}
+}
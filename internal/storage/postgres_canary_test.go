@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+)
+
+// canaryTestDB 返回按语句预设影响行数的脚本化数据库：canaryRows 为有条件更新发布记录影响的行数，
+// aliasRows 为更新别名影响的行数，exists 为未更新任何行时发布记录是否存在
+func canaryTestDB(canaryRows, aliasRows int64, exists bool) *sqltest.DB {
+	return sqltest.New().
+		On("UPDATE canary_deployments", sqltest.Affected(canaryRows)).
+		On("UPDATE function_aliases", sqltest.Affected(aliasRows)).
+		On("SELECT EXISTS", sqltest.Rows([]string{"exists"}, []driver.Value{exists}))
+}
+
+func testCanaryDeployment() *domain.CanaryDeployment {
+	return &domain.CanaryDeployment{
+		ID: "canary-1", FunctionID: "fn-1", AliasName: "live",
+		StableVersion: 1, CanaryVersion: 2, Weight: 30, Status: domain.CanaryStatusRunning,
+	}
+}
+
+func TestUpdateCanaryDeploymentGuardsOnRunningStatus(t *testing.T) {
+	db := canaryTestDB(1, 0, false)
+	if err := newSQLTestStore(t, db).UpdateCanaryDeployment(testCanaryDeployment()); err != nil {
+		t.Fatalf("UpdateCanaryDeployment: %v", err)
+	}
+	calls := db.Calls()
+	if len(calls) != 1 || !strings.Contains(calls[0].Query, "WHERE id = $1 AND status = 'running'") {
+		t.Fatalf("queries = %q, want one update guarded on running status", db.Queries())
+	}
+	if v := calls[0].Values(); v["id"] != "canary-1" || v["weight"] != int64(30) || v["status"] != string(domain.CanaryStatusRunning) {
+		t.Errorf("update values = %v, want canary-1 at weight 30", v)
+	}
+
+	tests := []struct {
+		name   string
+		exists bool
+		want   error
+	}{
+		{"ended", true, domain.ErrCanaryNotRunning},
+		{"deleted", false, domain.ErrCanaryNotFound},
+	}
+	for _, tt := range tests {
+		db := canaryTestDB(0, 0, tt.exists)
+		if err := newSQLTestStore(t, db).UpdateCanaryDeployment(testCanaryDeployment()); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+		if exists := db.Find("SELECT EXISTS"); len(exists) != 1 || exists[0].Args[0] != "canary-1" {
+			t.Errorf("%s: existence checks = %v, want one for canary-1", tt.name, exists)
+		}
+	}
+}
+
+func TestApplyCanaryDeploymentUpdatesAliasInTransaction(t *testing.T) {
+	db := canaryTestDB(1, 1, false)
+	if err := newSQLTestStore(t, db).ApplyCanaryDeployment(testCanaryDeployment()); err != nil {
+		t.Fatalf("ApplyCanaryDeployment: %v", err)
+	}
+	queries := db.Queries()
+	if len(queries) != 4 || queries[0] != sqltest.Begin || !strings.Contains(queries[1], "UPDATE canary_deployments") ||
+		!strings.Contains(queries[2], "UPDATE function_aliases") || queries[3] != sqltest.Commit {
+		t.Fatalf("queries = %q, want canary and alias updates committed together", queries)
+	}
+	if args := db.Calls()[2].Args; args[0] != "fn-1" || args[1] != "live" {
+		t.Errorf("alias update args = %v, want fn-1/live", args)
+	}
+
+	// 发布已结束时不改动别名
+	db = canaryTestDB(0, 1, true)
+	if err := newSQLTestStore(t, db).ApplyCanaryDeployment(testCanaryDeployment()); !errors.Is(err, domain.ErrCanaryNotRunning) {
+		t.Fatalf("ApplyCanaryDeployment on ended canary: err = %v, want ErrCanaryNotRunning", err)
+	}
+	if len(db.Find("function_aliases")) != 0 || len(db.Find(sqltest.Commit)) != 0 {
+		t.Errorf("ended canary touched the alias: %q", db.Queries())
+	}
+
+	// 别名被删除时回滚
+	db = canaryTestDB(1, 0, false)
+	if err := newSQLTestStore(t, db).ApplyCanaryDeployment(testCanaryDeployment()); !errors.Is(err, domain.ErrAliasNotFound) || len(db.Find(sqltest.Commit)) != 0 {
+		t.Errorf("ApplyCanaryDeployment without alias: err = %v queries = %q, want ErrAliasNotFound and no commit", err, db.Queries())
+	}
+}
+
+func TestAliasChangesNotifyHooks(t *testing.T) {
+	var changed []string
+	record := func(functionID, aliasName string) { changed = append(changed, functionID+":"+aliasName) }
+
+	store := newSQLTestStore(t, sqltest.New().On("", sqltest.Affected(1)))
+	store.OnAliasChanged(record)
+	alias := &domain.FunctionAlias{FunctionID: "fn-1", Name: "live"}
+	if err := store.UpdateFunctionAlias(alias); err != nil {
+		t.Fatalf("UpdateFunctionAlias: %v", err)
+	}
+	if err := store.DeleteFunctionAlias("fn-1", "live"); err != nil {
+		t.Fatalf("DeleteFunctionAlias: %v", err)
+	}
+	if err := store.DeleteFunction("fn-1"); err != nil {
+		t.Fatalf("DeleteFunction: %v", err)
+	}
+
+	canaryStore := newSQLTestStore(t, canaryTestDB(1, 1, false))
+	canaryStore.OnAliasChanged(record)
+	if err := canaryStore.ApplyCanaryDeployment(testCanaryDeployment()); err != nil {
+		t.Fatalf("ApplyCanaryDeployment: %v", err)
+	}
+	want := []string{"fn-1:live", "fn-1:live", "fn-1:", "fn-1:live"}
+	if strings.Join(changed, ",") != strings.Join(want, ",") {
+		t.Errorf("alias change notifications = %q, want %q", changed, want)
+	}
+
+	// 未生效的修改不触发回调
+	changed = nil
+	store = newSQLTestStore(t, sqltest.New())
+	store.OnAliasChanged(record)
+	if err := store.UpdateFunctionAlias(alias); !errors.Is(err, domain.ErrFunctionNotFound) {
+		t.Fatalf("UpdateFunctionAlias on missing alias: err = %v", err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("alias change notifications = %q, want none for a failed update", changed)
+	}
+}
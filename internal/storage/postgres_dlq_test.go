@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+)
+
+func TestReleaseStaleDLQClaims(t *testing.T) {
+	db := sqltest.New().On("UPDATE dead_letter_queue", sqltest.Affected(2))
+	released, err := newSQLTestStore(t, db).ReleaseStaleDLQClaims(15 * time.Minute)
+	if err != nil {
+		t.Fatalf("ReleaseStaleDLQClaims: %v", err)
+	}
+	if released != 2 {
+		t.Errorf("released = %d, want 2", released)
+	}
+	if queries := db.Queries(); len(queries) != 1 || !strings.Contains(queries[0], "WHERE status = $2 AND last_retry_at < NOW() - make_interval(secs => $3)") {
+		t.Fatalf("queries = %q, want a guarded update on stale retrying rows", queries)
+	}
+	// 只把超时的 retrying 消息放回 pending
+	if args := db.Calls()[0].Args; args[0] != "pending" || args[1] != "retrying" || args[2] != float64(900) {
+		t.Errorf("args = %v, want [pending retrying 900]", args)
+	}
+}
+
+func TestGetFunctionDestinationsRejectsMalformedConfig(t *testing.T) {
+	cols := []string{"function_id", "on_success", "on_failure", "updated_at"}
+	db := sqltest.New().On("FROM function_destinations",
+		sqltest.Rows(cols, []driver.Value{"fn-1", []byte(`{"type":"webhook","target":"https://example.com"}`), []byte(`{"type":`), time.Now()}),
+		sqltest.Rows(cols, []driver.Value{"fn-1", []byte(`{"type":"webhook","target":"https://example.com"}`), nil, time.Now()}),
+	)
+	store := newSQLTestStore(t, db)
+	_, err := store.GetFunctionDestinations("fn-1")
+	if err == nil || !strings.Contains(err.Error(), "invalid on_failure destination") {
+		t.Fatalf("err = %v, want an invalid on_failure destination error", err)
+	}
+
+	cfg, err := store.GetFunctionDestinations("fn-1")
+	if err != nil {
+		t.Fatalf("GetFunctionDestinations: %v", err)
+	}
+	if cfg.OnSuccess == nil || cfg.OnSuccess.Target != "https://example.com" || cfg.OnFailure != nil {
+		t.Errorf("cfg = %+v, want webhook on_success only", cfg)
+	}
+}
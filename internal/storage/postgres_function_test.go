@@ -0,0 +1,379 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+)
+
+// functionColumnList 是 functionColumns 中按扫描顺序排列的列名
+var functionColumnList = sqltest.Columns(functionColumns)
+
+// newSQLTestStore 返回使用脚本化 SQL 驱动的 PostgresStore
+func newSQLTestStore(t *testing.T, db *sqltest.DB) *PostgresStore {
+	t.Helper()
+	return &PostgresStore{db: db.Open(t)}
+}
+
+// storedFunctionRow 按顺序回放 db 收到的 functions 插入和更新语句的参数，返回按 functionColumns 排列的函数行，
+// 用于验证写入的列能被查询语句原样读回
+func storedFunctionRow(db *sqltest.DB) []driver.Value {
+	values := make(map[string]driver.Value)
+	for _, c := range db.Calls() {
+		if strings.Contains(c.Query, "INSERT INTO functions") || strings.Contains(c.Query, "UPDATE functions SET") {
+			for col, v := range c.Values() {
+				values[col] = v
+			}
+		}
+	}
+	return sqltest.Row(functionColumnList, values)
+}
+
+// functionRow 创建 fn 并返回写入的函数行
+func functionRow(t *testing.T, fn *domain.Function) []driver.Value {
+	t.Helper()
+	db := sqltest.New()
+	if err := newSQLTestStore(t, db).CreateFunction(fn); err != nil {
+		t.Fatalf("CreateFunction() error = %v", err)
+	}
+	return storedFunctionRow(db)
+}
+
+func TestGetFunctionByPath(t *testing.T) {
+	db := sqltest.New().On("UPDATE functions SET", sqltest.Affected(1))
+	store := newSQLTestStore(t, db)
+
+	fn := &domain.Function{
+		Name:        "orders",
+		Tags:        []string{"team-a", "http"},
+		Runtime:     domain.RuntimePython311,
+		Handler:     "handler.handler",
+		Code:        "def handler(event):\n    return event\n",
+		MemoryMB:    256,
+		TimeoutSec:  30,
+		Status:      domain.FunctionStatusActive,
+		HTTPPath:    "/orders-by-path",
+		HTTPMethods: []string{"GET", "POST"},
+	}
+	if err := store.CreateFunction(fn); err != nil {
+		t.Fatalf("CreateFunction() error = %v", err)
+	}
+	// state_config 只能通过更新写入
+	fn.StateConfig = &domain.StateConfig{Enabled: true, DefaultTTL: 60}
+	if err := store.UpdateFunction(fn); err != nil {
+		t.Fatalf("UpdateFunction() error = %v", err)
+	}
+
+	row := storedFunctionRow(db)
+	db.On("FROM functions WHERE http_path = $1", sqltest.Rows(functionColumnList, row), sqltest.Rows(functionColumnList))
+	db.On("FROM functions WHERE id = $1", sqltest.Rows(functionColumnList, row))
+
+	got, err := store.GetFunctionByPath("/orders-by-path")
+	if err != nil {
+		t.Fatalf("GetFunctionByPath() error = %v", err)
+	}
+	if args := db.Find("FROM functions WHERE http_path = $1")[0].Args; len(args) != 1 || args[0] != "/orders-by-path" {
+		t.Errorf("GetFunctionByPath() args = %v, want [/orders-by-path]", args)
+	}
+	if got.ID != fn.ID || got.Name != fn.Name || got.HTTPPath != fn.HTTPPath || got.MemoryMB != 256 || got.Version != fn.Version {
+		t.Errorf("GetFunctionByPath() = %+v", got)
+	}
+	if !reflect.DeepEqual(got.Tags, fn.Tags) || !reflect.DeepEqual(got.HTTPMethods, fn.HTTPMethods) {
+		t.Errorf("GetFunctionByPath() tags=%v methods=%v, want %v %v", got.Tags, got.HTTPMethods, fn.Tags, fn.HTTPMethods)
+	}
+	if got.StateConfig == nil || !got.StateConfig.Enabled || got.StateConfig.DefaultTTL != 60 {
+		t.Errorf("GetFunctionByPath() state_config = %+v", got.StateConfig)
+	}
+
+	// 与按 ID 查询结果一致
+	byID, err := store.GetFunctionByID(fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunctionByID() error = %v", err)
+	}
+	if !reflect.DeepEqual(byID, got) {
+		t.Errorf("GetFunctionByPath() = %+v, want same as GetFunctionByID() %+v", got, byID)
+	}
+
+	if _, err := store.GetFunctionByPath("/missing"); !errors.Is(err, domain.ErrFunctionNotFound) {
+		t.Errorf("GetFunctionByPath(missing) error = %v, want ErrFunctionNotFound", err)
+	}
+}
+
+func TestGetFunctionByPathAndMethod(t *testing.T) {
+	// 方法过滤在 SQL 中完成：设置了 http_methods 时不区分大小写匹配，未设置或为 JSON null 时允许任意方法
+	db := sqltest.New()
+	_, err := newSQLTestStore(t, db).GetFunctionByPathAndMethod("/orders", "delete")
+	if !errors.Is(err, domain.ErrFunctionNotFound) {
+		t.Fatalf("GetFunctionByPathAndMethod() without rows error = %v, want ErrFunctionNotFound", err)
+	}
+	call := db.Calls()[0]
+	for _, want := range []string{
+		"WHERE http_path = $1 AND CASE",
+		"WHEN jsonb_typeof(http_methods) = 'array' AND jsonb_array_length(http_methods) > 0",
+		"jsonb_array_elements_text(http_methods) AS m WHERE upper(m) = upper($2)",
+		"ELSE TRUE",
+	} {
+		if !strings.Contains(call.Query, want) {
+			t.Errorf("query missing %q:\n%s", want, call.Query)
+		}
+	}
+	if len(call.Args) != 2 || call.Args[0] != "/orders" || call.Args[1] != "delete" {
+		t.Errorf("args = %v, want [/orders delete]", call.Args)
+	}
+
+	// 查询列与扫描目标一致
+	fn := &domain.Function{Name: "orders", Runtime: domain.RuntimePython311, Handler: "handler.handler", HTTPPath: "/orders", HTTPMethods: []string{"GET", "post"}}
+	db = sqltest.New().On("WHERE http_path = $1 AND CASE", sqltest.Rows(functionColumnList, functionRow(t, fn)))
+	got, err := newSQLTestStore(t, db).GetFunctionByPathAndMethod("/orders", "POST")
+	if err != nil || got.ID != fn.ID || !reflect.DeepEqual(got.HTTPMethods, fn.HTTPMethods) {
+		t.Errorf("GetFunctionByPathAndMethod() = %+v, %v, want %s", got, err, fn.ID)
+	}
+}
+
+// upsertConflictDB 返回名称已存在时的脚本化数据库：插入被 ON CONFLICT 跳过，
+// 加锁查询读到 existing，函数已有的最新版本号为 latest
+func upsertConflictDB(existing []driver.Value, latest int64) *sqltest.DB {
+	return sqltest.New().
+		On("INSERT INTO functions", sqltest.Affected(0)).
+		On("FROM functions WHERE name = $1 FOR UPDATE", sqltest.Rows(functionColumnList, existing)).
+		On("UPDATE functions SET", sqltest.Affected(1)).
+		On("SELECT COALESCE(MAX(version), 0) FROM function_versions", sqltest.Rows([]string{"max"}, []driver.Value{latest}))
+}
+
+func TestUpsertFunction(t *testing.T) {
+	db := sqltest.New().On("INSERT INTO functions", sqltest.Affected(1))
+	fn := &domain.Function{Name: "deploy-me", Runtime: domain.RuntimePython311, Handler: "handler.handler", Code: "v1"}
+	created, err := newSQLTestStore(t, db).UpsertFunction(fn)
+	if err != nil {
+		t.Fatalf("UpsertFunction(new) error = %v", err)
+	}
+	if !created || fn.ID == "" {
+		t.Fatalf("UpsertFunction(new) created = %v, id = %q, want a new function", created, fn.ID)
+	}
+	queries := db.Queries()
+	if len(queries) != 3 || !strings.Contains(queries[1], "ON CONFLICT (name) DO NOTHING") || queries[2] != sqltest.Commit {
+		t.Errorf("UpsertFunction(new) queries = %q, want a conflict-tolerant insert committed alone", queries)
+	}
+
+	// 名称冲突时更新可修改字段并递增版本号，ID、置顶状态和创建时间保持不变
+	original := &domain.Function{
+		Name:       "deploy-me",
+		Runtime:    domain.RuntimePython311,
+		Handler:    "handler.handler",
+		Code:       "v1",
+		MemoryMB:   128,
+		TimeoutSec: 30,
+		EnvVars:    map[string]string{"STAGE": "dev"},
+		Status:     domain.FunctionStatusActive,
+		Pinned:     true,
+	}
+	existing := functionRow(t, original)
+	again := &domain.Function{
+		Name:        "deploy-me",
+		Runtime:     domain.RuntimePython311,
+		Handler:     "main.handler",
+		Code:        "v2",
+		MemoryMB:    512,
+		TimeoutSec:  60,
+		EnvVars:     map[string]string{"STAGE": "prod"},
+		InputSchema: json.RawMessage(`{"type":"object","required":["id"]}`),
+	}
+	db = upsertConflictDB(existing, 0)
+	created, err = newSQLTestStore(t, db).UpsertFunction(again)
+	if err != nil {
+		t.Fatalf("UpsertFunction(existing) error = %v", err)
+	}
+	if created {
+		t.Error("UpsertFunction(existing) created = true, want an update")
+	}
+	if args := db.Find("FROM functions WHERE name = $1 FOR UPDATE")[0].Args; len(args) != 1 || args[0] != "deploy-me" {
+		t.Errorf("locking query args = %v, want [deploy-me]", args)
+	}
+	update := db.Find("UPDATE functions SET")[0].Values()
+	if update["id"] != original.ID || update["handler"] != "main.handler" || update["code"] != "v2" ||
+		update["memory_mb"] != int64(512) || update["timeout_sec"] != int64(60) || string(update["env_vars"].([]byte)) != `{"STAGE":"prod"}` {
+		t.Errorf("update = %v, want the new handler, code, env and limits", update)
+	}
+	if update["input_schema"] != `{"type":"object","required":["id"]}` {
+		t.Errorf("updated input_schema = %v, want the new schema", update["input_schema"])
+	}
+	if update["version"] != int64(original.Version+1) || again.Version != original.Version+1 {
+		t.Errorf("version = %v (returned %d), want %d", update["version"], again.Version, original.Version+1)
+	}
+	if again.ID != original.ID || update["pinned"] != true || update["status"] != string(domain.FunctionStatusActive) || !again.CreatedAt.Equal(original.CreatedAt) {
+		t.Errorf("upsert overwrote preserved fields: %+v (update %v)", again, update)
+	}
+
+	// 代码变化时在同一事务中保存一条版本记录
+	versions := db.Find("INSERT INTO function_versions")
+	if len(versions) != 1 {
+		t.Fatalf("function versions after code change = %d, want 1", len(versions))
+	}
+	if v := versions[0].Values(); v["function_id"] != original.ID || v["version"] != int64(1) || v["code"] != "v2" || v["handler"] != "main.handler" {
+		t.Errorf("function version after code change = %v, want version 1 with the new code", v)
+	}
+	if queries := db.Queries(); queries[len(queries)-1] != sqltest.Commit {
+		t.Errorf("UpsertFunction(existing) queries = %q, want the transaction committed", queries)
+	}
+
+	// 代码不变的重复部署不产生新版本
+	deployed := *original
+	deployed.Handler, deployed.Code = "main.handler", "v2"
+	db = upsertConflictDB(functionRow(t, &deployed), 1)
+	same := &domain.Function{Name: "deploy-me", Runtime: domain.RuntimePython311, Handler: "main.handler", Code: "v2", MemoryMB: 256}
+	if _, err := newSQLTestStore(t, db).UpsertFunction(same); err != nil {
+		t.Fatalf("UpsertFunction(same code) error = %v", err)
+	}
+	if versions := db.Find("INSERT INTO function_versions"); len(versions) != 0 || len(db.Find("UPDATE functions SET")) != 1 {
+		t.Errorf("config-only upsert wrote %d versions, want an update without a version", len(versions))
+	}
+
+	// 已有函数的运行时不可修改
+	db = upsertConflictDB(existing, 0)
+	_, err = newSQLTestStore(t, db).UpsertFunction(&domain.Function{Name: "deploy-me", Runtime: domain.RuntimeNodeJS20, Handler: "index.handler"})
+	if !errors.Is(err, domain.ErrInvalidRuntime) {
+		t.Errorf("UpsertFunction(runtime change) error = %v, want ErrInvalidRuntime", err)
+	}
+	if len(db.Find("UPDATE functions SET")) != 0 || len(db.Find(sqltest.Commit)) != 0 {
+		t.Errorf("runtime change wrote data: %q", db.Queries())
+	}
+}
+
+func TestRollbackFunction(t *testing.T) {
+	fn := &domain.Function{
+		Name:       "rollback-me",
+		Runtime:    domain.RuntimePython311,
+		Handler:    "main.handler",
+		Code:       "v2",
+		CodeHash:   "hash-v2",
+		MemoryMB:   128,
+		TimeoutSec: 30,
+		Status:     domain.FunctionStatusActive,
+	}
+	row := functionRow(t, fn)
+	versionColumns := sqltest.Columns(`id, function_id, version, handler, code, "binary", code_hash, description, created_at`)
+	v1 := []driver.Value{"ver-1", fn.ID, int64(1), "handler.handler", "v1", nil, "hash-v1", nil, time.Now()}
+	db := sqltest.New().
+		On("FROM functions WHERE id = $1 FOR UPDATE", sqltest.Rows(functionColumnList, row)).
+		On("WHERE function_id = $1 AND version = $2", sqltest.Rows(versionColumns, v1), sqltest.Rows(versionColumns)).
+		On("UPDATE functions SET", sqltest.Affected(1)).
+		On("SELECT COALESCE(MAX(version), 0) FROM function_versions", sqltest.Rows([]string{"max"}, []driver.Value{int64(2)}))
+	store := newSQLTestStore(t, db)
+
+	// 回滚恢复目标版本的代码，递增函数版本号并记录一条新版本
+	rolled, err := store.RollbackFunction(fn.ID, 1)
+	if err != nil {
+		t.Fatalf("RollbackFunction() error = %v", err)
+	}
+	if args := db.Find("WHERE function_id = $1 AND version = $2")[0].Args; args[0] != fn.ID || args[1] != int64(1) {
+		t.Errorf("version query args = %v, want [%s 1]", args, fn.ID)
+	}
+	update := db.Find("UPDATE functions SET")[0].Values()
+	if update["code"] != "v1" || update["handler"] != "handler.handler" || update["code_hash"] != "hash-v1" {
+		t.Errorf("rollback update = %v, want version 1's code and handler", update)
+	}
+	if update["version"] != int64(fn.Version+1) || rolled.Version != fn.Version+1 {
+		t.Errorf("function version = %v (returned %d), want %d", update["version"], rolled.Version, fn.Version+1)
+	}
+	versions := db.Find("INSERT INTO function_versions")
+	if len(versions) != 1 {
+		t.Fatalf("function versions after rollback = %d, want 1", len(versions))
+	}
+	if v := versions[0].Values(); v["function_id"] != fn.ID || v["version"] != int64(3) || v["code"] != "v1" || v["description"] != "Rolled back to version 1" {
+		t.Errorf("rollback version = %v, want version 3 with version 1's code", v)
+	}
+	if len(db.Find(sqltest.Commit)) != 1 {
+		t.Errorf("queries = %q, want the rollback committed", db.Queries())
+	}
+
+	// 目标版本不存在时不提交
+	if _, err := store.RollbackFunction(fn.ID, 9); !errors.Is(err, domain.ErrFunctionNotFound) {
+		t.Errorf("RollbackFunction(missing version) error = %v, want ErrFunctionNotFound", err)
+	}
+	if len(db.Find("UPDATE functions SET")) != 1 || len(db.Find(sqltest.Commit)) != 1 {
+		t.Errorf("rollback to a missing version wrote data: %q", db.Queries())
+	}
+}
+
+func TestFunctionInputSchemaRoundTrip(t *testing.T) {
+	db := sqltest.New().On("UPDATE functions SET", sqltest.Affected(1))
+	store := newSQLTestStore(t, db)
+
+	schema := json.RawMessage(`{"type":"object","required":["order_id"]}`)
+	fn := &domain.Function{
+		Name:        "validated",
+		Runtime:     domain.RuntimePython311,
+		Handler:     "handler.handler",
+		Code:        "def handler(event):\n    return event\n",
+		MemoryMB:    128,
+		TimeoutSec:  30,
+		Status:      domain.FunctionStatusActive,
+		InputSchema: schema,
+	}
+	if err := store.CreateFunction(fn); err != nil {
+		t.Fatalf("CreateFunction() error = %v", err)
+	}
+	db.On("FROM functions WHERE id = $1", sqltest.Rows(functionColumnList, storedFunctionRow(db)))
+	got, err := store.GetFunctionByID(fn.ID)
+	if err != nil {
+		t.Fatalf("GetFunctionByID() error = %v", err)
+	}
+	if string(got.InputSchema) != string(schema) {
+		t.Errorf("input_schema = %s, want %s", got.InputSchema, schema)
+	}
+
+	// 清除 schema 后写入 NULL，读回为空
+	got.InputSchema = nil
+	if err := store.UpdateFunction(got); err != nil {
+		t.Fatalf("UpdateFunction() error = %v", err)
+	}
+	if v := db.Find("UPDATE functions SET")[0].Values()["input_schema"]; v != nil {
+		t.Errorf("updated input_schema = %v, want NULL", v)
+	}
+	db.On("FROM functions WHERE name = $1", sqltest.Rows(functionColumnList, storedFunctionRow(db)))
+	got, err = store.GetFunctionByName("validated")
+	if err != nil {
+		t.Fatalf("GetFunctionByName() error = %v", err)
+	}
+	if got.InputSchema != nil {
+		t.Errorf("input_schema after clearing = %s, want nil", got.InputSchema)
+	}
+}
+
+func TestGetFunctionByIdempotencyKey(t *testing.T) {
+	db := sqltest.New()
+	store := newSQLTestStore(t, db)
+	for _, fn := range []*domain.Function{
+		{Name: "keyed", Runtime: domain.RuntimePython311, Handler: "handler.handler", IdempotencyKey: "key-1"},
+		{Name: "unkeyed", Runtime: domain.RuntimePython311, Handler: "handler.handler"},
+	} {
+		if err := store.CreateFunction(fn); err != nil {
+			t.Fatalf("CreateFunction() error = %v", err)
+		}
+	}
+	inserts := db.Find("INSERT INTO functions")
+	// 未携带幂等键的函数写入 NULL，不占用唯一索引
+	if keyed, unkeyed := inserts[0].Values()["idempotency_key"], inserts[1].Values()["idempotency_key"]; keyed != "key-1" || unkeyed != nil {
+		t.Errorf("idempotency_key = %v, %v; want key-1 and NULL", keyed, unkeyed)
+	}
+
+	keyed := sqltest.Row(functionColumnList, inserts[0].Values())
+	db.On("FROM functions WHERE idempotency_key = $1", sqltest.Rows(functionColumnList, keyed), sqltest.Rows(functionColumnList))
+	got, err := store.GetFunctionByIdempotencyKey("key-1")
+	if err != nil || got.Name != "keyed" {
+		t.Fatalf("GetFunctionByIdempotencyKey() = %+v, %v, want keyed", got, err)
+	}
+	if args := db.Find("WHERE idempotency_key = $1")[0].Args; len(args) != 1 || args[0] != "key-1" {
+		t.Errorf("GetFunctionByIdempotencyKey() args = %v, want [key-1]", args)
+	}
+	if _, err := store.GetFunctionByIdempotencyKey("key-2"); !errors.Is(err, domain.ErrFunctionNotFound) {
+		t.Errorf("GetFunctionByIdempotencyKey(unknown) error = %v, want ErrFunctionNotFound", err)
+	}
+}
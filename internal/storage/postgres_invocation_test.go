@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+)
+
+func TestInvocationFilterWhere(t *testing.T) {
+	after := time.Date(2026, 1, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	before := after.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		filter    *domain.InvocationFilter
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{"nil", nil, "", nil},
+		{"empty", &domain.InvocationFilter{}, "", nil},
+		{"status", &domain.InvocationFilter{Status: domain.InvocationStatusFailed}, " WHERE status = $1", []interface{}{"failed"}},
+		{
+			"combined",
+			&domain.InvocationFilter{Status: domain.InvocationStatusFailed, TriggerType: domain.TriggerCron, After: &after, Before: &before},
+			" WHERE status = $1 AND created_at >= $2 AND created_at < $3 AND trigger_type = $4",
+			[]interface{}{"failed", after.UTC(), before.UTC(), "cron"},
+		},
+		{
+			"trigger and after",
+			&domain.InvocationFilter{TriggerType: domain.TriggerHTTP, After: &after},
+			" WHERE created_at >= $1 AND trigger_type = $2",
+			[]interface{}{after.UTC(), "http"},
+		},
+	}
+	for _, tt := range tests {
+		where, args := invocationFilterWhere(tt.filter)
+		if where != tt.wantWhere {
+			t.Errorf("%s: where = %q, want %q", tt.name, where, tt.wantWhere)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: args = %v, want %v", tt.name, args, tt.wantArgs)
+		}
+	}
+
+}
+
+func TestMarkStaleInvocationsFailed(t *testing.T) {
+	db := sqltest.New().On("UPDATE invocations", sqltest.Rows([]string{"id", "function_id", "function_name", "status"},
+		[]driver.Value{"inv-1", "fn-1", "orders", "running"}, []driver.Value{"inv-2", "fn-1", "orders", "pending"}))
+	marked, err := newSQLTestStore(t, db).MarkStaleInvocationsFailed(time.Hour, "abandoned", 100)
+	if err != nil {
+		t.Fatalf("MarkStaleInvocationsFailed: %v", err)
+	}
+	if len(marked) != 2 || marked[0].Status != domain.InvocationStatusRunning || marked[1].Status != domain.InvocationStatusPending {
+		t.Fatalf("marked = %+v, want both rows with their previous status", marked)
+	}
+
+	// 一条语句完成锁定和更新，按批次上限截断，只返回实际更新的行
+	query := db.Queries()[0]
+	for _, want := range []string{"LIMIT $5", "FOR UPDATE SKIP LOCKED", "RETURNING i.id, i.function_id, i.function_name, stale.status"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if args := db.Calls()[0].Args; len(args) != 5 || args[0] != "failed" || args[1] != "abandoned" || args[4] != int64(100) {
+		t.Errorf("args = %v, want status, error message and batch limit", args)
+	}
+}
+
+func TestListInvocationsByFunctionCursor(t *testing.T) {
+	createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cols := []string{"id", "function_id", "function_name", "trigger_type", "status", "input", "output", "error",
+		"cold_start", "vm_id", "started_at", "completed_at", "duration_ms", "billed_time_ms",
+		"memory_used_mb", "retry_count", "created_at"}
+	db := sqltest.New().On("FROM invocations",
+		sqltest.Rows(cols, []driver.Value{"inv-8", "fn-1", "orders", "http", "success", nil, nil, nil,
+			false, nil, nil, nil, int64(0), int64(0), int64(0), int64(0), createdAt}),
+		sqltest.Rows(cols),
+	)
+	store := newSQLTestStore(t, db)
+	before := domain.InvocationCursor{CreatedAt: createdAt, ID: "inv-9"}
+	list, next, err := store.ListInvocationsByFunctionCursor("fn-1", before, 1)
+	if err != nil {
+		t.Fatalf("ListInvocationsByFunctionCursor: %v", err)
+	}
+	// 满页时下一页游标指向本页最后一条记录
+	if len(list) != 1 || next.ID != "inv-8" || !next.CreatedAt.Equal(createdAt) {
+		t.Errorf("list = %d, next = %+v, want cursor at inv-8", len(list), next)
+	}
+
+	// 游标和排序都使用 (created_at, id)，创建时间相同的记录跨页时不会被跳过
+	query := db.Queries()[0]
+	for _, want := range []string{"(created_at, id) < ($2, $3)", "ORDER BY created_at DESC, id DESC LIMIT $4"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if args := db.Calls()[0].Args; len(args) != 4 || args[0] != "fn-1" || args[2] != "inv-9" || args[3] != int64(1) {
+		t.Errorf("args = %v, want function id, cursor and limit", args)
+	}
+
+	// 空游标从最新的记录开始，排序同样以 id 兜底
+	if _, next, err = store.ListInvocationsByFunctionCursor("fn-1", domain.InvocationCursor{}, 20); err != nil || !next.IsZero() {
+		t.Fatalf("first page next = %+v, err = %v, want empty cursor", next, err)
+	}
+	if query := db.Queries()[1]; !strings.Contains(query, "ORDER BY created_at DESC, id DESC LIMIT $2") {
+		t.Errorf("first page query = %s", query)
+	}
+}
+
+func TestTrimFunctionInvocations(t *testing.T) {
+	db := sqltest.New().On("DELETE FROM invocations", sqltest.Affected(7))
+	store := newSQLTestStore(t, db)
+
+	deleted, err := store.TrimFunctionInvocations("fn-1", 100)
+	if err != nil || deleted != 7 {
+		t.Fatalf("TrimFunctionInvocations = %d, %v, want 7", deleted, err)
+	}
+	if _, err := store.TrimFunctionInvocationsPreferFailed("fn-1", 100); err != nil {
+		t.Fatalf("TrimFunctionInvocationsPreferFailed: %v", err)
+	}
+
+	// 默认只按时间保留最新的记录，优先保留失败记录时失败和超时排在前面
+	wants := []string{
+		"ORDER BY created_at DESC, id DESC LIMIT $2",
+		"ORDER BY (status IN ('failed', 'timeout')) DESC, created_at DESC, id DESC LIMIT $2",
+	}
+	for i, want := range wants {
+		if query := db.Queries()[i]; !strings.Contains(query, "WHERE function_id = $1 AND id NOT IN") || !strings.Contains(query, want) {
+			t.Errorf("query %d = %s, want keep set ordered by %q", i, query, want)
+		}
+		if args := db.Calls()[i].Args; args[0] != "fn-1" || args[1] != int64(100) {
+			t.Errorf("args %d = %v, want [fn-1 100]", i, args)
+		}
+	}
+
+	// 上限必须为正数，否则会删除函数的全部记录
+	if _, err := store.TrimFunctionInvocations("fn-1", 0); err == nil {
+		t.Error("TrimFunctionInvocations accepted keepLatest 0")
+	}
+	if queries := db.Queries(); len(queries) != 2 {
+		t.Errorf("queries = %d, want no statement for an invalid limit", len(queries))
+	}
+}
+
+func TestListInvocationRecordCaps(t *testing.T) {
+	db := sqltest.New().On("FROM functions", sqltest.Rows([]string{"id", "max_invocation_records"},
+		[]driver.Value{"fn-1", int64(100)}, []driver.Value{"fn-2", int64(5)}))
+	caps, err := newSQLTestStore(t, db).ListInvocationRecordCaps()
+	if err != nil {
+		t.Fatalf("ListInvocationRecordCaps: %v", err)
+	}
+	if !reflect.DeepEqual(caps, map[string]int{"fn-1": 100, "fn-2": 5}) {
+		t.Errorf("caps = %v", caps)
+	}
+	if query := db.Queries()[0]; !strings.Contains(query, "WHERE max_invocation_records > 0") {
+		t.Errorf("query = %s, want only functions with a cap", query)
+	}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+)
+
+func TestGetFunctionLatencySamplesBounded(t *testing.T) {
+	db := sqltest.New().On("SELECT duration_ms FROM (",
+		sqltest.Rows([]string{"duration_ms"}, []driver.Value{int64(5)}, []driver.Value{int64(40)}, []driver.Value{int64(900)}))
+	store := newSQLTestStore(t, db)
+
+	samples, err := store.GetFunctionLatencySamples("fn-1", 24, 50)
+	if err != nil {
+		t.Fatalf("GetFunctionLatencySamples() error = %v", err)
+	}
+	if len(samples) != 3 || samples[0] != 5 || samples[1] != 40 || samples[2] != 900 {
+		t.Errorf("samples = %v, want [5 40 900]", samples)
+	}
+
+	// 在数据库中随机抽样后按耗时升序返回，不把整段时间的调用读入内存
+	query := db.Calls()[0].Query
+	for _, clause := range []string{
+		"WHERE function_id = $1 AND created_at >= NOW() - INTERVAL '1 hour' * $2",
+		"AND completed_at IS NOT NULL AND duration_ms IS NOT NULL",
+		"ORDER BY random()",
+		"LIMIT $3",
+		") sampled",
+	} {
+		if !strings.Contains(query, clause) {
+			t.Errorf("query missing %q:\n%s", clause, query)
+		}
+	}
+
+	// 未指定时取默认值，超过上限时截断
+	for _, n := range []int{0, MaxLatencySamples * 10} {
+		if _, err := store.GetFunctionLatencySamples("fn-1", 24, n); err != nil {
+			t.Fatalf("GetFunctionLatencySamples(%d) error = %v", n, err)
+		}
+	}
+	want := []int64{50, DefaultLatencySamples, MaxLatencySamples}
+	for i, call := range db.Calls() {
+		if len(call.Args) != 3 || call.Args[0] != "fn-1" || call.Args[1] != int64(24) || call.Args[2] != want[i] {
+			t.Errorf("query %d args = %v, want [fn-1 24 %d]", i, call.Args, want[i])
+		}
+	}
+}
+
+func TestGetFunctionStatsSplitsColdAndWarm(t *testing.T) {
+	cols := []string{"total", "success", "failed", "timeout", "cold_starts", "avg_latency", "p50_latency", "p95_latency", "p99_latency",
+		"min_latency", "max_latency", "total_duration", "avg_cold_start", "cold_start_p99", "warm_count", "avg_warm_latency"}
+	db := sqltest.New().On("FROM invocations", sqltest.Rows(cols, []driver.Value{int64(10), int64(7), int64(2), int64(1), int64(4), 120.0, 80.0, 400.0, 900.0,
+		int64(10), int64(950), int64(1200), 300.0, 940.0, int64(6), 40.0}))
+	stats, err := newSQLTestStore(t, db).GetFunctionStats("fn-1", 24)
+	if err != nil {
+		t.Fatalf("GetFunctionStats: %v", err)
+	}
+
+	// 冷启动和热启动调用分别按 cold_start 过滤聚合
+	for _, clause := range []string{
+		"AVG(duration_ms) FILTER (WHERE cold_start = true), 0) as avg_cold_start",
+		"PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY duration_ms) FILTER (WHERE cold_start = true), 0) as cold_start_p99",
+		"COUNT(*) FILTER (WHERE cold_start = false) as warm_count",
+		"AVG(duration_ms) FILTER (WHERE cold_start = false), 0) as avg_warm_latency",
+		"WHERE function_id = $1 AND created_at >= NOW() - INTERVAL '1 hour' * $2",
+	} {
+		if queries := db.Queries(); len(queries) != 1 || !strings.Contains(queries[0], clause) {
+			t.Errorf("query missing %q:\n%s", clause, queries)
+		}
+	}
+	if args := db.Calls()[0].Args; len(args) != 2 || args[0] != "fn-1" || args[1] != int64(24) {
+		t.Errorf("args = %v, want [fn-1 24]", args)
+	}
+
+	if stats.ColdStartCount != 4 || stats.AvgColdStartMs != 300 || stats.ColdStartP99Ms != 940 {
+		t.Errorf("cold = count %d avg %v p99 %v, want 4/300/940", stats.ColdStartCount, stats.AvgColdStartMs, stats.ColdStartP99Ms)
+	}
+	if stats.WarmCount != 6 || stats.AvgWarmLatencyMs != 40 {
+		t.Errorf("warm = count %d avg %v, want 6/40", stats.WarmCount, stats.AvgWarmLatencyMs)
+	}
+	if stats.ColdStartRate != 40 || stats.SuccessRate != 70 || stats.ErrorRate != 30 {
+		t.Errorf("rates = cold %v success %v error %v, want 40/70/30", stats.ColdStartRate, stats.SuccessRate, stats.ErrorRate)
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage/sqltest"
+)
+
+var (
+	promoteEnvColumns    = []string{"id"}
+	promoteSourceColumns = []string{"memory_mb", "timeout_sec", "active_alias"}
+)
+
+// promoteTestDB 返回环境按顺序解析为 envIDs 的脚本化数据库，没有更多环境时查询为空；
+// source 为源环境的配置行，为 nil 时源环境没有配置
+func promoteTestDB(source []driver.Value, envIDs ...string) *sqltest.DB {
+	var envs []sqltest.Result
+	for _, id := range envIDs {
+		envs = append(envs, sqltest.Rows(promoteEnvColumns, []driver.Value{id}))
+	}
+	envs = append(envs, sqltest.Rows(promoteEnvColumns))
+	db := sqltest.New().On("SELECT id FROM environments WHERE name = $1", envs...)
+	if source != nil {
+		db.On("SELECT memory_mb, timeout_sec, active_alias FROM function_environment_configs", sqltest.Rows(promoteSourceColumns, source))
+	}
+	return db.On("INSERT INTO function_environment_configs",
+		sqltest.Rows([]string{"env_vars", "created_at"}, []driver.Value{[]byte(`{"DB_HOST":"staging-db"}`), time.Now()}))
+}
+
+func TestPromoteFunctionDevToStaging(t *testing.T) {
+	db := promoteTestDB([]driver.Value{int64(512), nil, "canary"}, "env-dev", "env-staging")
+
+	cfg, err := newSQLTestStore(t, db).PromoteFunction("fn-1", "dev", "staging", &AuditLog{Actor: "alice"})
+	if err != nil {
+		t.Fatalf("PromoteFunction() error = %v", err)
+	}
+	if cfg.EnvironmentID != "env-staging" || cfg.ActiveAlias != "canary" || cfg.MemoryMB == nil || *cfg.MemoryMB != 512 || cfg.TimeoutSec != nil {
+		t.Errorf("promoted config = %+v, want staging with alias canary, 512MB and no timeout override", cfg)
+	}
+	if envs := db.Find("FROM environments WHERE name = $1"); len(envs) != 2 || envs[0].Args[0] != "dev" || envs[1].Args[0] != "staging" {
+		t.Errorf("environment lookups = %v, want dev then staging", envs)
+	}
+	if source := db.Find("FROM function_environment_configs")[0]; !strings.Contains(source.Query, "FOR SHARE") ||
+		source.Args[0] != "fn-1" || source.Args[1] != "env-dev" {
+		t.Errorf("source config query = %s %v, want a shared lock on fn-1 in env-dev", source.Query, source.Args)
+	}
+
+	// 目标环境只覆盖内存、超时和活动别名，自己的环境变量不随晋升复制
+	upsert := db.Find("INSERT INTO function_environment_configs")[0]
+	if !strings.Contains(upsert.Query, "ON CONFLICT (function_id, environment_id) DO UPDATE SET") || strings.Contains(upsert.Query, "env_vars = EXCLUDED") {
+		t.Errorf("upsert query = %s, want the target's env vars preserved", upsert.Query)
+	}
+	if args := upsert.Args; args[0] != "fn-1" || args[1] != "env-staging" || args[2] != int64(512) || args[3] != nil || args[4] != "canary" {
+		t.Errorf("upsert args = %v, want [fn-1 env-staging 512 <nil> canary ...]", args)
+	}
+	if cfg.EnvVars["DB_HOST"] != "staging-db" {
+		t.Errorf("staging env vars = %v, want unchanged", cfg.EnvVars)
+	}
+
+	audits := db.Find("INSERT INTO audit_logs")
+	if len(audits) != 1 {
+		t.Fatalf("audit entries = %d, want 1", len(audits))
+	}
+	audit := audits[0].Values()
+	var details map[string]interface{}
+	json.Unmarshal(audit["details"].([]byte), &details)
+	if audit["action"] != "function.promote" || audit["resource_id"] != "fn-1" || audit["actor"] != "alice" ||
+		details["from"] != "dev" || details["to"] != "staging" || details["active_alias"] != "canary" {
+		t.Errorf("audit entry = %v (details %v)", audit, details)
+	}
+	if queries := db.Queries(); queries[0] != sqltest.Begin || queries[len(queries)-1] != sqltest.Commit {
+		t.Errorf("queries = %q, want the promotion in one transaction", queries)
+	}
+}
+
+func TestPromoteFunctionMissingEnvironment(t *testing.T) {
+	source := []driver.Value{int64(512), nil, "canary"}
+	tests := []struct {
+		name     string
+		db       *sqltest.DB
+		from, to string
+		want     error
+	}{
+		{"missing target", promoteTestDB(source, "env-dev"), "dev", "prod", domain.ErrEnvironmentNotFound},
+		{"no source config", promoteTestDB(nil, "env-dev", "env-staging"), "dev", "staging", domain.ErrEnvConfigNotFound},
+		{"same environment", promoteTestDB(source, "env-dev", "env-dev"), "dev", "dev", domain.ErrInvalidPromotion},
+	}
+	for _, tt := range tests {
+		if _, err := newSQLTestStore(t, tt.db).PromoteFunction("fn-1", tt.from, tt.to, nil); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+		if len(tt.db.Find("INSERT INTO")) != 0 || len(tt.db.Find(sqltest.Commit)) != 0 {
+			t.Errorf("%s: failed promotion wrote data: %q", tt.name, tt.db.Queries())
+		}
+	}
+}
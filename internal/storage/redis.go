@@ -4,6 +4,7 @@
 //   - 分布式锁的实现
 //   - 函数代码缓存
 //   - 函数调用队列管理
+//   - 调用限流的令牌桶
 package storage
 
 import (
@@ -79,6 +80,8 @@ const (
 	vmLockKeyPrefix    = "vm:lock:"        // VM 锁键前缀，用于实现分布式锁
 	functionCacheKey   = "function:cache:" // 函数缓存键前缀，用于缓存函数代码
 	invocationQueueKey = "invocation:queue" // 函数调用队列键，用于异步调用排队
+	rateLimitKeyPrefix = "ratelimit:"       // 限流令牌桶键前缀
+	resultCacheKeyPrefix = "result:cache:"  // 函数结果缓存键前缀
 )
 
 // VMState 表示虚拟机的状态信息。
@@ -380,6 +383,40 @@ func (s *RedisStore) GetCachedFunction(ctx context.Context, functionID string) (
 	return data, err
 }
 
+// ==================== 结果缓存相关 ====================
+
+// SetCachedResult 缓存一次成功调用的输出结果。
+//
+// 参数:
+//   - ctx: 上下文
+//   - key: 缓存键，由调用方根据函数 ID、版本和输入生成
+//   - output: 函数输出
+//   - ttl: 缓存过期时间
+//
+// 返回值:
+//   - error: 操作失败时返回错误信息
+func (s *RedisStore) SetCachedResult(ctx context.Context, key string, output []byte, ttl time.Duration) error {
+	// SET result:cache:<key> <output> EX <ttl>
+	return s.client.Set(ctx, resultCacheKeyPrefix+key, output, ttl).Err()
+}
+
+// GetCachedResult 获取缓存的调用输出结果。
+//
+// 参数:
+//   - ctx: 上下文
+//   - key: 缓存键
+//
+// 返回值:
+//   - []byte: 缓存的输出，缓存不存在或已过期时返回 nil
+//   - error: 操作失败时返回错误信息
+func (s *RedisStore) GetCachedResult(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.client.Get(ctx, resultCacheKeyPrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil // 缓存未命中
+	}
+	return data, err
+}
+
 // ==================== 调用队列相关 ====================
 
 // PushInvocation 将函数调用 ID 推入队列尾部。
@@ -434,3 +471,69 @@ func (s *RedisStore) InvocationQueueLen(ctx context.Context) (int64, error) {
 	// LLEN invocation:queue - 获取列表长度
 	return s.client.LLen(ctx, invocationQueueKey).Result()
 }
+
+// ==================== 调用限流相关 ====================
+
+// tokenBucketScript 原子地补充并消耗令牌桶中的一个令牌。
+// 令牌桶保存为哈希 {tokens, ts}，ts 为上次补充的时间（毫秒），当前时间由调用方传入，
+// 多个网关实例共享同一个桶。桶在空闲到足以补满后过期。
+//
+// KEYS[1]: 令牌桶键；ARGV[1]: 每秒补充的令牌数；ARGV[2]: 桶容量；ARGV[3]: 当前时间（毫秒）；ARGV[4]: 取出的令牌数
+// 返回 {是否允许（1/0）, 不允许时距离令牌补足的毫秒数}
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+  ts = now
+end
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+  tokens = tokens - cost
+  allowed = 1
+else
+  retry = math.ceil((cost - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, retry}
+`)
+
+// TakeTokens 从令牌桶中取出 n 个令牌，桶不存在时按满桶创建；令牌不足时不取出任何令牌。
+//
+// 参数:
+//   - ctx: 上下文
+//   - key: 令牌桶键名（不含前缀），如 "<function_id>:<caller>"
+//   - n: 取出的令牌数，必须大于 0（批量调用按输入数计）
+//   - rps: 每秒补充的令牌数，必须大于 0
+//   - burst: 桶容量，必须大于 0
+//   - now: 当前时间，由调用方传入以便测试使用假时钟
+//
+// 返回值:
+//   - bool: 是否取到令牌，false 表示应被限流
+//   - time.Duration: 未取到令牌时距离补足 n 个令牌的时间
+//   - error: 操作失败时返回错误信息
+func (s *RedisStore) TakeTokens(ctx context.Context, key string, n int, rps float64, burst int, now time.Time) (bool, time.Duration, error) {
+	if n <= 0 || rps <= 0 || burst <= 0 {
+		return false, 0, fmt.Errorf("invalid token bucket: n=%d rps=%v burst=%d", n, rps, burst)
+	}
+	// EVALSHA（脚本未缓存时回退到 EVAL）ratelimit:<key> <rps> <burst> <now_ms> <n>
+	result, err := tokenBucketScript.Run(ctx, s.client, []string{rateLimitKeyPrefix + key}, rps, burst, now.UnixMilli(), n).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket result: %v", result)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisStore 返回连接 miniredis 的 RedisStore
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &RedisStore{client: client}, mr
+}
+
+func TestTakeTokensExhaustsAndRefills(t *testing.T) {
+	store, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	take := func() (bool, time.Duration) {
+		t.Helper()
+		ok, retry, err := store.TakeTokens(ctx, "fn-1:key-a", 1, 2, 3, now)
+		if err != nil {
+			t.Fatalf("TakeTokens() error = %v", err)
+		}
+		return ok, retry
+	}
+
+	// 满桶允许 burst 次突发调用
+	for i := 0; i < 3; i++ {
+		if ok, _ := take(); !ok {
+			t.Fatalf("call %d throttled within burst", i+1)
+		}
+	}
+	ok, retry := take()
+	if ok || retry != 500*time.Millisecond {
+		t.Fatalf("exhausted bucket: ok=%v retry=%v, want throttled with 500ms", ok, retry)
+	}
+
+	// 每秒补充 2 个令牌：250ms 后仍不足一个，500ms 后恰好补充一个
+	now = now.Add(250 * time.Millisecond)
+	if ok, retry := take(); ok || retry != 250*time.Millisecond {
+		t.Fatalf("after 250ms: ok=%v retry=%v, want throttled with 250ms", ok, retry)
+	}
+	now = now.Add(250 * time.Millisecond)
+	if ok, _ := take(); !ok {
+		t.Fatal("after 500ms: throttled, want one refilled token")
+	}
+	if ok, _ := take(); ok {
+		t.Fatal("second call after 500ms allowed, want throttled")
+	}
+
+	// 长时间空闲后最多补满到 burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := take(); !ok {
+			t.Fatalf("call %d after idle throttled", i+1)
+		}
+	}
+	if ok, _ := take(); ok {
+		t.Fatal("refill exceeded burst")
+	}
+
+	// 其他调用方使用独立的令牌桶，空闲的桶会过期
+	if ok, _, _ := store.TakeTokens(ctx, "fn-1:key-b", 1, 2, 3, now); !ok {
+		t.Error("separate bucket throttled")
+	}
+	if ttl := mr.TTL(rateLimitKeyPrefix + "fn-1:key-b"); ttl <= 0 || ttl > 3*time.Second {
+		t.Errorf("bucket TTL = %v, want the time to refill plus a margin", ttl)
+	}
+}
+
+func TestTakeTokensChargesBatchCost(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// 一次取出 3 个令牌后桶中只剩 2 个，不足 3 个时整批拒绝且不扣减
+	if ok, _, err := store.TakeTokens(ctx, "fn-1:key-a", 3, 2, 5, now); err != nil || !ok {
+		t.Fatalf("TakeTokens(3) = %v, %v, want allowed", ok, err)
+	}
+	ok, retry, err := store.TakeTokens(ctx, "fn-1:key-a", 3, 2, 5, now)
+	if err != nil || ok || retry != 500*time.Millisecond {
+		t.Fatalf("TakeTokens(3) with 2 left = %v, %v, %v, want throttled with 500ms", ok, retry, err)
+	}
+	if ok, _, _ := store.TakeTokens(ctx, "fn-1:key-a", 2, 2, 5, now); !ok {
+		t.Error("TakeTokens(2) after a rejected batch throttled, want the remaining tokens kept")
+	}
+}
+
+func TestTakeTokensRejectsInvalidBucket(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	if _, _, err := store.TakeTokens(context.Background(), "fn-1:key-a", 1, 0, 1, time.Now()); err == nil {
+		t.Error("TakeTokens(rps=0) error = nil, want error")
+	}
+	if _, _, err := store.TakeTokens(context.Background(), "fn-1:key-a", 0, 1, 1, time.Now()); err == nil {
+		t.Error("TakeTokens(n=0) error = nil, want error")
+	}
+}
+
+func TestCachedResultHitMissAndExpiry(t *testing.T) {
+	store, mr := newTestRedisStore(t)
+	ctx := context.Background()
+
+	if data, err := store.GetCachedResult(ctx, "fn-1:1:abc"); err != nil || data != nil {
+		t.Fatalf("miss: GetCachedResult() = %q, %v; want nil, nil", data, err)
+	}
+	if err := store.SetCachedResult(ctx, "fn-1:1:abc", []byte(`{"sum":3}`), time.Minute); err != nil {
+		t.Fatalf("SetCachedResult() error = %v", err)
+	}
+	if data, err := store.GetCachedResult(ctx, "fn-1:1:abc"); err != nil || string(data) != `{"sum":3}` {
+		t.Fatalf("hit: GetCachedResult() = %q, %v", data, err)
+	}
+	if data, _ := store.GetCachedResult(ctx, "fn-1:2:abc"); data != nil {
+		t.Errorf("other version: GetCachedResult() = %q, want miss", data)
+	}
+
+	mr.FastForward(time.Minute + time.Second)
+	if data, err := store.GetCachedResult(ctx, "fn-1:1:abc"); err != nil || data != nil {
+		t.Errorf("expired: GetCachedResult() = %q, %v; want nil, nil", data, err)
+	}
+}
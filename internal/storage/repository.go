@@ -0,0 +1,71 @@
+// Package storage 提供数据持久化层的实现。
+// 本文件包含 PostgresStore 和测试用的 storagetest.MemoryStore 共用的函数写入规则，
+// 两者均实现 domain.FunctionRepository 和 domain.InvocationRepository。
+package storage
+
+import (
+	"fmt"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+// MergeUpsertFunction 将 fn 的可修改字段合并到已存在的同名函数 existing 上，并把结果写回 fn。
+// ID、运行时、置顶、Webhook、所有者、部署时间和版本号等沿用已有记录；fn.Status 为空时状态也沿用已有记录。
+// 已有函数的运行时不可修改，fn 指定了不同的运行时时返回 domain.ErrInvalidRuntime。
+func MergeUpsertFunction(existing, fn *domain.Function) error {
+	if fn.Runtime != "" && fn.Runtime != existing.Runtime {
+		return fmt.Errorf("%w: function %s already uses runtime %s, cannot change to %s",
+			domain.ErrInvalidRuntime, existing.Name, existing.Runtime, fn.Runtime)
+	}
+
+	merged := *existing
+	merged.Description = fn.Description
+	merged.Tags = fn.Tags
+	merged.Handler = fn.Handler
+	merged.Code = fn.Code
+	merged.Binary = fn.Binary
+	merged.CodeHash = fn.CodeHash
+	merged.MemoryMB = fn.MemoryMB
+	merged.TimeoutSec = fn.TimeoutSec
+	merged.MaxConcurrency = fn.MaxConcurrency
+	merged.EnvVars = fn.EnvVars
+	merged.CronExpression = fn.CronExpression
+	merged.HTTPPath = fn.HTTPPath
+	merged.HTTPMethods = fn.HTTPMethods
+	merged.StateConfig = fn.StateConfig
+	merged.NodeSelector = fn.NodeSelector
+	merged.CronTimezone = fn.CronTimezone
+	merged.WarmupStrategy = fn.WarmupStrategy
+	merged.WarmupSchedule = fn.WarmupSchedule
+	merged.MaxInvocationRecords = fn.MaxInvocationRecords
+	merged.PropagateIdentity = fn.PropagateIdentity
+	merged.SnapshotTTLHours = fn.SnapshotTTLHours
+	merged.Cacheable = fn.Cacheable
+	merged.CacheTTLSec = fn.CacheTTLSec
+	merged.WarmupHandler = fn.WarmupHandler
+	merged.WarmupTimeoutSec = fn.WarmupTimeoutSec
+	merged.InstallDeps = fn.InstallDeps
+	merged.InstallDepsTimeoutSec = fn.InstallDepsTimeoutSec
+	merged.IOLimits = fn.IOLimits
+	merged.InputSchema = fn.InputSchema
+	merged.RateLimit = fn.RateLimit
+	if fn.Status != "" {
+		merged.Status = fn.Status
+		merged.StatusMessage = fn.StatusMessage
+		merged.TaskID = fn.TaskID
+	}
+	*fn = merged
+	return nil
+}
+
+// codeChanged 判断更新后的函数 fn 相对 existing 是否改变了代码或处理程序，需要保存新的版本记录
+func codeChanged(existing, fn *domain.Function) bool {
+	return existing.Handler != fn.Handler || existing.Code != fn.Code ||
+		existing.Binary != fn.Binary || existing.CodeHash != fn.CodeHash
+}
+
+// 编译期检查：确保 PostgresStore 满足仓库接口
+var (
+	_ domain.FunctionRepository   = (*PostgresStore)(nil)
+	_ domain.InvocationRepository = (*PostgresStore)(nil)
+)
@@ -0,0 +1,269 @@
+// Package storage 提供数据持久化层的实现。
+// 本文件实现数据库结构漂移检测：将实际表结构与代码依赖的关键列和索引逐一比对，
+// 用于发现手工修改数据库后幂等 DDL 迁移无法修复的差异（如列类型被改动、索引被删除）。
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// SchemaIssueKind 表示表结构差异的类型
+type SchemaIssueKind string
+
+// 表结构差异类型常量
+const (
+	// SchemaIssueMissingColumn 缺少代码依赖的列
+	SchemaIssueMissingColumn SchemaIssueKind = "missing_column"
+	// SchemaIssueWrongType 列存在但类型与预期不一致
+	SchemaIssueWrongType SchemaIssueKind = "wrong_type"
+	// SchemaIssueMissingIndex 缺少代码依赖的索引
+	SchemaIssueMissingIndex SchemaIssueKind = "missing_index"
+)
+
+// SchemaIssue 表示实际表结构与预期之间的一处差异
+type SchemaIssue struct {
+	Kind     SchemaIssueKind `json:"kind"`
+	Table    string          `json:"table"`
+	Column   string          `json:"column,omitempty"`
+	Index    string          `json:"index,omitempty"`
+	Expected string          `json:"expected,omitempty"` // 预期的列类型
+	Actual   string          `json:"actual,omitempty"`   // 实际的列类型
+}
+
+// String 返回差异的可读描述
+func (i SchemaIssue) String() string {
+	switch i.Kind {
+	case SchemaIssueMissingColumn:
+		return fmt.Sprintf("missing column %s.%s (%s)", i.Table, i.Column, i.Expected)
+	case SchemaIssueWrongType:
+		return fmt.Sprintf("column %s.%s has type %s, expected %s", i.Table, i.Column, i.Actual, i.Expected)
+	case SchemaIssueMissingIndex:
+		return fmt.Sprintf("missing index %s on %s", i.Index, i.Table)
+	default:
+		return string(i.Kind)
+	}
+}
+
+// expectedColumn 描述一个代码依赖的列，DataType 取 information_schema.columns.data_type 的值
+type expectedColumn struct {
+	Table    string
+	Column   string
+	DataType string
+}
+
+// expectedIndex 描述一个代码依赖的索引
+type expectedIndex struct {
+	Table string
+	Name  string
+}
+
+// expectedColumns 是代码依赖的关键列。
+// 新增迁移改变了查询依赖的列时，需要同步更新此列表。
+var expectedColumns = []expectedColumn{
+	// functions：函数查询的完整列表
+	{"functions", "id", "character varying"},
+	{"functions", "name", "character varying"},
+	{"functions", "description", "text"},
+	{"functions", "tags", "ARRAY"},
+	{"functions", "pinned", "boolean"},
+	{"functions", "runtime", "character varying"},
+	{"functions", "handler", "character varying"},
+	{"functions", "code", "text"},
+	{"functions", "binary", "text"},
+	{"functions", "code_hash", "character varying"},
+	{"functions", "memory_mb", "integer"},
+	{"functions", "timeout_sec", "integer"},
+	{"functions", "max_concurrency", "integer"},
+	{"functions", "env_vars", "jsonb"},
+	{"functions", "status", "character varying"},
+	{"functions", "status_message", "text"},
+	{"functions", "task_id", "character varying"},
+	{"functions", "version", "integer"},
+	{"functions", "cron_expression", "character varying"},
+	{"functions", "http_path", "character varying"},
+	{"functions", "http_methods", "jsonb"},
+	{"functions", "webhook_enabled", "boolean"},
+	{"functions", "webhook_key", "character varying"},
+	{"functions", "last_deployed_at", "timestamp with time zone"},
+	{"functions", "state_config", "jsonb"},
+	{"functions", "node_selector", "jsonb"},
+	{"functions", "warmup_strategy", "character varying"},
+	{"functions", "warmup_schedule", "character varying"},
+	{"functions", "owner_id", "character varying"},
+	{"functions", "max_invocation_records", "integer"},
+	{"functions", "propagate_identity", "boolean"},
+	{"functions", "snapshot_ttl_hours", "integer"},
+	{"functions", "io_limits", "jsonb"},
+	{"functions", "input_schema", "jsonb"},
+	{"functions", "rate_limit", "jsonb"},
+	{"functions", "cron_timezone", "character varying"},
+	{"functions", "cron_last_fired_at", "timestamp with time zone"},
+	{"functions", "cacheable", "boolean"},
+	{"functions", "cache_ttl_sec", "integer"},
+	{"functions", "warmup_handler", "character varying"},
+	{"functions", "warmup_timeout_sec", "integer"},
+	{"functions", "install_deps", "boolean"},
+	{"functions", "install_deps_timeout_sec", "integer"},
+	{"functions", "idempotency_key", "character varying"},
+	{"functions", "created_at", "timestamp with time zone"},
+	{"functions", "updated_at", "timestamp with time zone"},
+
+	// invocations：调用记录及统计查询依赖的列
+	{"invocations", "id", "character varying"},
+	{"invocations", "function_id", "character varying"},
+	{"invocations", "status", "character varying"},
+	{"invocations", "input", "jsonb"},
+	{"invocations", "output", "jsonb"},
+	{"invocations", "cold_start", "boolean"},
+	{"invocations", "started_at", "timestamp with time zone"},
+	{"invocations", "completed_at", "timestamp with time zone"},
+	{"invocations", "duration_ms", "bigint"},
+	{"invocations", "decision_trace", "jsonb"},
+	{"invocations", "version", "integer"},
+	{"invocations", "caller_id", "character varying"},
+	{"invocations", "replay_of", "character varying"},
+	{"invocations", "call_chain", "ARRAY"},
+	{"invocations", "cache_hit", "boolean"},
+	{"invocations", "created_at", "timestamp with time zone"},
+
+	// 工作流执行
+	{"workflow_executions", "paused_at_state", "character varying"},
+	{"workflow_executions", "paused_input", "jsonb"},
+	{"workflow_executions", "paused_at", "timestamp with time zone"},
+	{"state_executions", "execution_id", "character varying"},
+	{"state_executions", "invocation_id", "character varying"},
+	{"state_executions", "started_at", "timestamp with time zone"},
+	{"state_executions", "completed_at", "timestamp with time zone"},
+
+	// API 密钥
+	{"api_keys", "scopes", "ARRAY"},
+
+	// 环境与节点
+	{"environments", "default_env_vars", "jsonb"},
+	{"nodes", "labels", "jsonb"},
+	{"nodes", "last_seen_at", "timestamp with time zone"},
+}
+
+// expectedIndexes 是热点查询依赖的关键索引
+var expectedIndexes = []expectedIndex{
+	{"functions", "idx_functions_name"},
+	{"functions", "idx_functions_status"},
+	{"functions", "idx_functions_http_path"},
+	{"functions", "idx_functions_owner_id"},
+	{"functions", "idx_functions_idempotency_key"},
+	{"invocations", "idx_invocations_function_id"},
+	{"invocations", "idx_invocations_status"},
+	{"invocations", "idx_invocations_created_at"},
+	{"invocations", "idx_invocations_function_created"},
+	{"invocations", "idx_invocations_function_created_id"},
+	{"invocations", "idx_invocations_status_created"},
+	{"state_executions", "idx_state_executions_execution_id"},
+	{"invocations", "idx_invocations_function_version"},
+	{"canary_deployments", "idx_canary_deployments_running"},
+}
+
+// VerifySchema 检查代码依赖的关键列和索引是否存在且类型正确。
+// 只读取 information_schema 和 pg_indexes，不修改数据库。
+//
+// 参数:
+//   - ctx: 上下文，用于超时控制
+//
+// 返回值:
+//   - []SchemaIssue: 发现的差异，按表名排序，为空表示结构符合预期
+//   - error: 查询系统表失败时返回错误
+func (s *PostgresStore) VerifySchema(ctx context.Context) ([]SchemaIssue, error) {
+	tables := expectedTables()
+
+	// SQL: 查询关键表的实际列类型
+	columns := make(map[string]string)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT table_name, column_name, data_type
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ANY($1)
+	`, pq.Array(tables))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	for rows.Next() {
+		var table, column, dataType string
+		if err := rows.Scan(&table, &column, &dataType); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns[table+"."+column] = dataType
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate columns: %w", err)
+	}
+
+	// SQL: 查询关键表的实际索引
+	indexes := make(map[string]bool)
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT tablename, indexname
+		FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = ANY($1)
+	`, pq.Array(tables))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, index string
+		if err := rows.Scan(&table, &index); err != nil {
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		indexes[table+"."+index] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate indexes: %w", err)
+	}
+
+	return diffSchema(columns, indexes), nil
+}
+
+// diffSchema 将实际列类型（键为 "表.列"）和索引（键为 "表.索引"）与预期描述比对
+func diffSchema(columns map[string]string, indexes map[string]bool) []SchemaIssue {
+	var issues []SchemaIssue
+	for _, c := range expectedColumns {
+		actual, ok := columns[c.Table+"."+c.Column]
+		switch {
+		case !ok:
+			issues = append(issues, SchemaIssue{Kind: SchemaIssueMissingColumn, Table: c.Table, Column: c.Column, Expected: c.DataType})
+		case actual != c.DataType:
+			issues = append(issues, SchemaIssue{Kind: SchemaIssueWrongType, Table: c.Table, Column: c.Column, Expected: c.DataType, Actual: actual})
+		}
+	}
+	for _, idx := range expectedIndexes {
+		if !indexes[idx.Table+"."+idx.Name] {
+			issues = append(issues, SchemaIssue{Kind: SchemaIssueMissingIndex, Table: idx.Table, Index: idx.Name})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Table < issues[j].Table
+	})
+	return issues
+}
+
+// expectedTables 返回预期描述涉及的表名
+func expectedTables() []string {
+	seen := make(map[string]bool)
+	var tables []string
+	for _, c := range expectedColumns {
+		if !seen[c.Table] {
+			seen[c.Table] = true
+			tables = append(tables, c.Table)
+		}
+	}
+	for _, idx := range expectedIndexes {
+		if !seen[idx.Table] {
+			seen[idx.Table] = true
+			tables = append(tables, idx.Table)
+		}
+	}
+	return tables
+}
@@ -0,0 +1,50 @@
+package storage
+
+import "testing"
+
+// expectedSchema 返回完全符合预期描述的列和索引
+func expectedSchema() (map[string]string, map[string]bool) {
+	columns := make(map[string]string)
+	for _, c := range expectedColumns {
+		columns[c.Table+"."+c.Column] = c.DataType
+	}
+	indexes := make(map[string]bool)
+	for _, idx := range expectedIndexes {
+		indexes[idx.Table+"."+idx.Name] = true
+	}
+	return columns, indexes
+}
+
+func TestDiffSchemaMatches(t *testing.T) {
+	columns, indexes := expectedSchema()
+	// 额外的列和索引不算差异
+	columns["functions.extra"] = "text"
+	indexes["functions.idx_extra"] = true
+
+	if issues := diffSchema(columns, indexes); len(issues) != 0 {
+		t.Errorf("diffSchema() = %v, want no issues", issues)
+	}
+}
+
+func TestDiffSchemaDetectsDrift(t *testing.T) {
+	columns, indexes := expectedSchema()
+	delete(columns, "functions.owner_id")
+	columns["invocations.duration_ms"] = "integer"
+	delete(indexes, "invocations.idx_invocations_function_id")
+
+	issues := diffSchema(columns, indexes)
+	if len(issues) != 3 {
+		t.Fatalf("diffSchema() returned %d issues, want 3: %v", len(issues), issues)
+	}
+
+	want := map[SchemaIssueKind]SchemaIssue{
+		SchemaIssueMissingColumn: {Kind: SchemaIssueMissingColumn, Table: "functions", Column: "owner_id", Expected: "character varying"},
+		SchemaIssueWrongType:     {Kind: SchemaIssueWrongType, Table: "invocations", Column: "duration_ms", Expected: "bigint", Actual: "integer"},
+		SchemaIssueMissingIndex:  {Kind: SchemaIssueMissingIndex, Table: "invocations", Index: "idx_invocations_function_id"},
+	}
+	for _, issue := range issues {
+		if issue != want[issue.Kind] {
+			t.Errorf("issue %s = %+v, want %+v", issue.Kind, issue, want[issue.Kind])
+		}
+	}
+}
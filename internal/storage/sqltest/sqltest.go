@@ -0,0 +1,243 @@
+// Package sqltest 提供访问数据库的单元测试使用的脚本化 database/sql 驱动。
+//
+// 驱动不解析也不执行 SQL：每条语句按注册的片段匹配预设结果，同时记录语句文本、参数和事务边界，
+// 测试据此断言发出的 SQL 和参数，并验证预设结果行能被正确扫描。查询本身的语义（过滤、冲突处理等）
+// 由断言 SQL 文本的测试覆盖，需要在真实数据库上验证时使用 PostgreSQL 集成测试。
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 事务边界在调用记录中的语句文本
+const (
+	Begin    = "BEGIN"
+	Commit   = "COMMIT"
+	Rollback = "ROLLBACK"
+)
+
+// Result 是一条语句的预设结果：查询返回 Columns 和 Rows，写语句返回 RowsAffected，Err 非空时语句失败
+type Result struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+	Err          error
+}
+
+// Rows 返回包含指定列和结果行的查询结果
+func Rows(columns []string, rows ...[]driver.Value) Result {
+	return Result{Columns: columns, Rows: rows}
+}
+
+// Affected 返回影响 n 行的写语句结果
+func Affected(n int64) Result {
+	return Result{RowsAffected: n}
+}
+
+// Row 按列名从 values 中取值组成一行，缺少的列为 NULL
+func Row(columns []string, values map[string]driver.Value) []driver.Value {
+	row := make([]driver.Value, len(columns))
+	for i, col := range columns {
+		row[i] = values[col]
+	}
+	return row
+}
+
+// Columns 拆分逗号分隔的列名列表，去掉空白和标识符引号
+func Columns(list string) []string {
+	var cols []string
+	for _, c := range strings.Split(list, ",") {
+		cols = append(cols, strings.Trim(strings.TrimSpace(c), `"`))
+	}
+	return cols
+}
+
+// Call 是驱动收到的一条语句
+type Call struct {
+	Query string
+	Args  []driver.Value
+}
+
+var (
+	insertColumnsRE = regexp.MustCompile(`(?s)INSERT INTO \S+ \((.*?)\)`)
+	assignRE        = regexp.MustCompile(`("?\w+"?) = \$(\d+)`)
+)
+
+// Values 按列名返回语句写入的参数：INSERT 语句按列清单与参数一一对应，
+// 其余语句按 "列 = $n" 形式的赋值和条件取对应的参数
+func (c Call) Values() map[string]driver.Value {
+	values := make(map[string]driver.Value)
+	if m := insertColumnsRE.FindStringSubmatch(c.Query); m != nil {
+		for i, col := range Columns(m[1]) {
+			if i < len(c.Args) {
+				values[col] = c.Args[i]
+			}
+		}
+		return values
+	}
+	for _, m := range assignRE.FindAllStringSubmatch(c.Query, -1) {
+		if n, _ := strconv.Atoi(m[2]); n >= 1 && n <= len(c.Args) {
+			values[strings.Trim(m[1], `"`)] = c.Args[n-1]
+		}
+	}
+	return values
+}
+
+// rule 是一条按语句片段匹配的预设结果，results 依次返回，用完后重复最后一个
+type rule struct {
+	fragment string
+	results  []Result
+	next     int
+}
+
+func (r *rule) result() Result {
+	res := r.results[r.next]
+	if r.next < len(r.results)-1 {
+		r.next++
+	}
+	return res
+}
+
+// DB 是脚本化的数据库，同时实现 driver.Connector。并发安全。
+type DB struct {
+	mu    sync.Mutex
+	rules []*rule
+	calls []Call
+}
+
+// New 创建没有任何规则的数据库：写语句影响 0 行，查询返回空结果
+func New() *DB {
+	return &DB{}
+}
+
+// On 注册一条规则：包含 fragment 的语句依次得到 results，用完后重复最后一个。
+// 语句按注册顺序匹配第一条规则，没有规则匹配时写语句影响 0 行，查询返回空结果。
+func (d *DB) On(fragment string, results ...Result) *DB {
+	if len(results) == 0 {
+		results = []Result{{}}
+	}
+	d.mu.Lock()
+	d.rules = append(d.rules, &rule{fragment: fragment, results: results})
+	d.mu.Unlock()
+	return d
+}
+
+// Open 返回使用该数据库的连接池，测试结束时关闭
+func (d *DB) Open(t testing.TB) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Calls 返回收到的所有语句，事务边界记为 Begin、Commit 和 Rollback
+func (d *DB) Calls() []Call {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Call(nil), d.calls...)
+}
+
+// Queries 返回收到的所有语句文本
+func (d *DB) Queries() []string {
+	var queries []string
+	for _, c := range d.Calls() {
+		queries = append(queries, c.Query)
+	}
+	return queries
+}
+
+// Find 返回包含 fragment 的语句
+func (d *DB) Find(fragment string) []Call {
+	var found []Call
+	for _, c := range d.Calls() {
+		if strings.Contains(c.Query, fragment) {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// record 记录一条语句并返回匹配的预设结果
+func (d *DB) record(query string, args []driver.Value) Result {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, Call{Query: query, Args: args})
+	for _, r := range d.rules {
+		if strings.Contains(query, r.fragment) {
+			return r.result()
+		}
+	}
+	return Result{}
+}
+
+// Connect 实现 driver.Connector
+func (d *DB) Connect(context.Context) (driver.Conn, error) { return conn{d}, nil }
+
+// Driver 实现 driver.Connector
+func (d *DB) Driver() driver.Driver { return nil }
+
+type conn struct{ d *DB }
+
+func (c conn) Prepare(query string) (driver.Stmt, error) { return stmt{d: c.d, query: query}, nil }
+func (c conn) Close() error                              { return nil }
+
+func (c conn) Begin() (driver.Tx, error) {
+	if res := c.d.record(Begin, nil); res.Err != nil {
+		return nil, res.Err
+	}
+	return tx{c.d}, nil
+}
+
+type tx struct{ d *DB }
+
+func (t tx) Commit() error   { return t.d.record(Commit, nil).Err }
+func (t tx) Rollback() error { return t.d.record(Rollback, nil).Err }
+
+type stmt struct {
+	d     *DB
+	query string
+}
+
+func (s stmt) Close() error  { return nil }
+func (s stmt) NumInput() int { return -1 }
+
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	res := s.d.record(s.query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return driver.RowsAffected(res.RowsAffected), nil
+}
+
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	res := s.d.record(s.query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return &rows{cols: res.Columns, values: res.Rows}, nil
+}
+
+type rows struct {
+	cols   []string
+	values [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.cols }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,69 @@
+package sqltest
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestRulesReturnResultsInOrder(t *testing.T) {
+	d := New().
+		On("UPDATE jobs", Affected(2), Result{Err: errors.New("boom")}).
+		On("SELECT", Rows([]string{"n"}, []driver.Value{int64(7)}))
+	db := d.Open(t)
+
+	for i, want := range []int64{2, -1, -1} {
+		res, err := db.Exec("UPDATE jobs SET status = $2 WHERE id = $1", "job-1", "done")
+		if want < 0 {
+			if err == nil {
+				t.Errorf("exec %d: want the repeated error result", i)
+			}
+			continue
+		}
+		if n, _ := res.RowsAffected(); err != nil || n != want {
+			t.Errorf("exec %d: rows = %d, err = %v, want %d", i, n, err, want)
+		}
+	}
+	var n int
+	if err := db.QueryRow("SELECT n FROM jobs").Scan(&n); err != nil || n != 7 {
+		t.Errorf("query = %d, %v, want 7", n, err)
+	}
+	// 没有规则匹配的写语句影响 0 行
+	if res, err := db.Exec("DELETE FROM jobs"); err != nil {
+		t.Errorf("unmatched exec error = %v", err)
+	} else if n, _ := res.RowsAffected(); n != 0 {
+		t.Errorf("unmatched exec rows = %d, want 0", n)
+	}
+
+	if calls := d.Find("UPDATE jobs"); len(calls) != 3 || calls[0].Args[0] != "job-1" {
+		t.Errorf("recorded updates = %v", calls)
+	}
+	if got := d.Find("UPDATE jobs")[0].Values(); got["status"] != "done" || got["id"] != "job-1" {
+		t.Errorf("update values = %v, want status and id by name", got)
+	}
+}
+
+func TestInsertValuesAndTransactions(t *testing.T) {
+	d := New()
+	db := d.Open(t)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(`INSERT INTO jobs (id, "binary", created_at) VALUES ($1, $2, NOW())`, "job-1", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := d.Queries()
+	if len(queries) != 3 || queries[0] != Begin || queries[2] != Commit {
+		t.Errorf("queries = %q, want the insert between BEGIN and COMMIT", queries)
+	}
+	values := d.Find("INSERT INTO jobs")[0].Values()
+	if values["id"] != "job-1" || string(values["binary"].([]byte)) != "x" || values["created_at"] != nil {
+		t.Errorf("insert values = %v, want id and binary by column", values)
+	}
+}
@@ -0,0 +1,372 @@
+// Package storagetest 提供存储接口的测试实现。
+// 本文件实现了基于内存的仓库，供单元测试在没有 PostgreSQL 的情况下使用。
+package storagetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/oriys/nimbus/internal/storage"
+)
+
+// 编译期检查：确保 MemoryStore 满足仓库接口
+var (
+	_ domain.FunctionRepository   = (*MemoryStore)(nil)
+	_ domain.InvocationRepository = (*MemoryStore)(nil)
+)
+
+// MemoryStore 是 domain.FunctionRepository 和 domain.InvocationRepository 的内存实现。
+// 语义与 PostgresStore 保持一致（ID 自动生成、名称唯一、不存在时返回领域错误、
+// 删除函数时级联删除调用记录），仅用于测试，不具备持久化能力。
+// 存取时均复制对象，调用方修改返回值不会影响存储内容。所有方法都是并发安全的。
+type MemoryStore struct {
+	mu          sync.RWMutex
+	functions   map[string]*domain.Function
+	invocations map[string]*domain.Invocation
+}
+
+// NewMemoryStore 创建一个空的内存存储。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		functions:   make(map[string]*domain.Function),
+		invocations: make(map[string]*domain.Invocation),
+	}
+}
+
+// ==================== 函数仓库实现 ====================
+
+// CreateFunction 创建函数。名称重复时返回 domain.ErrFunctionExists。
+func (m *MemoryStore) CreateFunction(fn *domain.Function) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.functions {
+		if existing.Name == fn.Name {
+			return fmt.Errorf("failed to create function: %w", domain.ErrFunctionExists)
+		}
+	}
+	if fn.ID == "" {
+		fn.ID = uuid.New().String()
+	}
+	fn.CreatedAt = time.Now()
+	fn.UpdatedAt = fn.CreatedAt
+	m.functions[fn.ID] = copyFunction(fn)
+	return nil
+}
+
+// GetFunctionByID 根据 ID 获取函数。
+func (m *MemoryStore) GetFunctionByID(id string) (*domain.Function, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	fn, ok := m.functions[id]
+	if !ok {
+		return nil, domain.ErrFunctionNotFound
+	}
+	return copyFunction(fn), nil
+}
+
+// GetFunctionByName 根据名称获取函数。
+func (m *MemoryStore) GetFunctionByName(name string) (*domain.Function, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, fn := range m.functions {
+		if fn.Name == name {
+			return copyFunction(fn), nil
+		}
+	}
+	return nil, domain.ErrFunctionNotFound
+}
+
+// ListFunctions 分页获取函数列表，置顶函数优先，按创建时间倒序排列。
+func (m *MemoryStore) ListFunctions(offset, limit int) ([]*domain.Function, int, error) {
+	m.mu.RLock()
+	all := make([]*domain.Function, 0, len(m.functions))
+	for _, fn := range m.functions {
+		all = append(all, copyFunction(fn))
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Pinned != all[j].Pinned {
+			return all[i].Pinned
+		}
+		return all[i].CreatedAt.After(all[j].CreatedAt)
+	})
+	return paginate(all, offset, limit), len(all), nil
+}
+
+// GetFunctionsByStatuses 获取处于指定状态的函数。
+func (m *MemoryStore) GetFunctionsByStatuses(statuses []string) ([]*domain.Function, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	wanted := make(map[string]bool, len(statuses))
+	for _, s := range statuses {
+		wanted[s] = true
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*domain.Function
+	for _, fn := range m.functions {
+		if wanted[string(fn.Status)] {
+			result = append(result, copyFunction(fn))
+		}
+	}
+	return result, nil
+}
+
+// UpdateFunction 更新函数并递增版本号。
+func (m *MemoryStore) UpdateFunction(fn *domain.Function) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.functions[fn.ID]
+	if !ok {
+		return domain.ErrFunctionNotFound
+	}
+	fn.UpdatedAt = time.Now()
+	fn.Version++
+	// 名称、运行时和创建时间不可修改，与 PostgresStore 的 UPDATE 语句一致
+	updated := copyFunction(fn)
+	updated.Name = existing.Name
+	updated.Runtime = existing.Runtime
+	updated.CreatedAt = existing.CreatedAt
+	m.functions[fn.ID] = updated
+	return nil
+}
+
+// UpsertFunction 按名称创建或更新函数，返回是否为新建。
+func (m *MemoryStore) UpsertFunction(fn *domain.Function) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.functions {
+		if existing.Name != fn.Name {
+			continue
+		}
+		if err := storage.MergeUpsertFunction(copyFunction(existing), fn); err != nil {
+			return false, err
+		}
+		fn.UpdatedAt = time.Now()
+		fn.Version++
+		if fn.WarmupStrategy == "" {
+			fn.WarmupStrategy = domain.WarmupNone
+		}
+		m.functions[fn.ID] = copyFunction(fn)
+		return false, nil
+	}
+
+	if fn.ID == "" {
+		fn.ID = uuid.New().String()
+	}
+	fn.CreatedAt = time.Now()
+	fn.UpdatedAt = fn.CreatedAt
+	if fn.WarmupStrategy == "" {
+		fn.WarmupStrategy = domain.WarmupNone
+	}
+	m.functions[fn.ID] = copyFunction(fn)
+	return true, nil
+}
+
+// DeleteFunction 删除函数及其调用记录。
+func (m *MemoryStore) DeleteFunction(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.functions[id]; !ok {
+		return domain.ErrFunctionNotFound
+	}
+	delete(m.functions, id)
+	for invID, inv := range m.invocations {
+		if inv.FunctionID == id {
+			delete(m.invocations, invID)
+		}
+	}
+	return nil
+}
+
+// ==================== 调用记录仓库实现 ====================
+
+// CreateInvocation 创建调用记录。关联的函数不存在时返回 domain.ErrFunctionNotFound。
+func (m *MemoryStore) CreateInvocation(inv *domain.Invocation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.functions[inv.FunctionID]; !ok {
+		return domain.ErrFunctionNotFound
+	}
+	if inv.ID == "" {
+		inv.ID = uuid.New().String()
+	}
+	if inv.CreatedAt.IsZero() {
+		inv.CreatedAt = time.Now()
+	}
+	m.invocations[inv.ID] = copyInvocation(inv)
+	return nil
+}
+
+// GetInvocationByID 根据 ID 获取调用记录。
+func (m *MemoryStore) GetInvocationByID(id string) (*domain.Invocation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	inv, ok := m.invocations[id]
+	if !ok {
+		return nil, domain.ErrInvocationNotFound
+	}
+	return copyInvocation(inv), nil
+}
+
+// ListInvocationsByFunction 分页获取函数的调用记录，按创建时间倒序排列。
+func (m *MemoryStore) ListInvocationsByFunction(functionID string, offset, limit int) ([]*domain.Invocation, int, error) {
+	all := m.filterInvocations(func(inv *domain.Invocation) bool {
+		return inv.FunctionID == functionID
+	})
+	return paginate(all, offset, limit), len(all), nil
+}
+
+// ListInvocationsByFunctionCursor 按游标获取函数的调用记录，按创建时间和 ID 倒序排列。
+func (m *MemoryStore) ListInvocationsByFunctionCursor(functionID string, before domain.InvocationCursor, limit int) ([]*domain.Invocation, domain.InvocationCursor, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	all := m.filterInvocations(func(inv *domain.Invocation) bool {
+		return inv.FunctionID == functionID && (before.IsZero() || invocationBefore(inv, before))
+	})
+	page := paginate(all, 0, limit)
+	return page, domain.NextInvocationCursor(page, limit), nil
+}
+
+// invocationBefore 判断调用记录按 (created_at, id) 倒序是否排在游标之后
+func invocationBefore(inv *domain.Invocation, cursor domain.InvocationCursor) bool {
+	if inv.CreatedAt.Equal(cursor.CreatedAt) {
+		return inv.ID < cursor.ID
+	}
+	return inv.CreatedAt.Before(cursor.CreatedAt)
+}
+
+// ListAllInvocations 分页获取所有调用记录，status 为空时不过滤。
+func (m *MemoryStore) ListAllInvocations(status string, offset, limit int) ([]*domain.Invocation, int, error) {
+	return m.ListAllInvocationsWithFilter(&domain.InvocationFilter{Status: domain.InvocationStatus(status)}, offset, limit)
+}
+
+// ListAllInvocationsWithFilter 按筛选条件分页获取所有调用记录，filter 为 nil 时不过滤。
+func (m *MemoryStore) ListAllInvocationsWithFilter(filter *domain.InvocationFilter, offset, limit int) ([]*domain.Invocation, int, error) {
+	all := m.filterInvocations(func(inv *domain.Invocation) bool {
+		return filter == nil || filter.Matches(inv)
+	})
+	return paginate(all, offset, limit), len(all), nil
+}
+
+// UpdateInvocation 更新调用记录。
+func (m *MemoryStore) UpdateInvocation(inv *domain.Invocation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.invocations[inv.ID]; !ok {
+		return domain.ErrInvocationNotFound
+	}
+	m.invocations[inv.ID] = copyInvocation(inv)
+	return nil
+}
+
+// filterInvocations 返回满足条件的调用记录副本，按创建时间倒序排列
+func (m *MemoryStore) filterInvocations(match func(*domain.Invocation) bool) []*domain.Invocation {
+	m.mu.RLock()
+	var result []*domain.Invocation
+	for _, inv := range m.invocations {
+		if match(inv) {
+			result = append(result, copyInvocation(inv))
+		}
+	}
+	m.mu.RUnlock()
+
+	// 与 PostgreSQL 一致按 (created_at, id) 倒序排列
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// ==================== 辅助函数 ====================
+
+// paginate 按 offset/limit 截取切片，limit <= 0 表示不限制
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := len(items)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return items[offset:end]
+}
+
+// copyFunction 复制函数对象，切片和映射字段也会被复制
+func copyFunction(fn *domain.Function) *domain.Function {
+	c := *fn
+	if fn.Tags != nil {
+		c.Tags = append([]string(nil), fn.Tags...)
+	}
+	if fn.HTTPMethods != nil {
+		c.HTTPMethods = append([]string(nil), fn.HTTPMethods...)
+	}
+	if fn.EnvVars != nil {
+		c.EnvVars = make(map[string]string, len(fn.EnvVars))
+		for k, v := range fn.EnvVars {
+			c.EnvVars[k] = v
+		}
+	}
+	if fn.StateConfig != nil {
+		sc := *fn.StateConfig
+		c.StateConfig = &sc
+	}
+	if fn.NodeSelector != nil {
+		c.NodeSelector = make(map[string]string, len(fn.NodeSelector))
+		for k, v := range fn.NodeSelector {
+			c.NodeSelector[k] = v
+		}
+	}
+	if fn.IOLimits != nil {
+		limits := *fn.IOLimits
+		c.IOLimits = &limits
+	}
+	if fn.RateLimit != nil {
+		limit := *fn.RateLimit
+		c.RateLimit = &limit
+	}
+	if fn.InputSchema != nil {
+		c.InputSchema = append(json.RawMessage(nil), fn.InputSchema...)
+	}
+	return &c
+}
+
+// copyInvocation 复制调用记录对象
+func copyInvocation(inv *domain.Invocation) *domain.Invocation {
+	c := *inv
+	if inv.Input != nil {
+		c.Input = append([]byte(nil), inv.Input...)
+	}
+	if inv.Output != nil {
+		c.Output = append([]byte(nil), inv.Output...)
+	}
+	if inv.DecisionTrace != nil {
+		c.DecisionTrace = append([]domain.DecisionStep(nil), inv.DecisionTrace...)
+	}
+	return &c
+}
@@ -0,0 +1,225 @@
+package storagetest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+)
+
+func TestMemoryStoreFunctions(t *testing.T) {
+	store := NewMemoryStore()
+
+	fn := &domain.Function{Name: "hello", Runtime: domain.RuntimePython311, Status: domain.FunctionStatusActive, EnvVars: map[string]string{"A": "1"}}
+	if err := store.CreateFunction(fn); err != nil {
+		t.Fatalf("CreateFunction() error = %v", err)
+	}
+	if fn.ID == "" {
+		t.Fatal("CreateFunction() did not assign an ID")
+	}
+
+	if err := store.CreateFunction(&domain.Function{Name: "hello"}); !errors.Is(err, domain.ErrFunctionExists) {
+		t.Errorf("CreateFunction() duplicate name error = %v, want ErrFunctionExists", err)
+	}
+
+	// 返回值是副本，修改不影响存储
+	got, err := store.GetFunctionByName("hello")
+	if err != nil {
+		t.Fatalf("GetFunctionByName() error = %v", err)
+	}
+	got.EnvVars["A"] = "changed"
+	again, _ := store.GetFunctionByID(fn.ID)
+	if again.EnvVars["A"] != "1" {
+		t.Errorf("stored function was mutated through returned copy")
+	}
+
+	got.Description = "updated"
+	if err := store.UpdateFunction(got); err != nil {
+		t.Fatalf("UpdateFunction() error = %v", err)
+	}
+	again, _ = store.GetFunctionByID(fn.ID)
+	if again.Description != "updated" || again.Version != got.Version {
+		t.Errorf("UpdateFunction() not applied: %+v", again)
+	}
+
+	if err := store.DeleteFunction(fn.ID); err != nil {
+		t.Fatalf("DeleteFunction() error = %v", err)
+	}
+	if _, err := store.GetFunctionByID(fn.ID); !errors.Is(err, domain.ErrFunctionNotFound) {
+		t.Errorf("GetFunctionByID() after delete error = %v, want ErrFunctionNotFound", err)
+	}
+	if err := store.DeleteFunction(fn.ID); !errors.Is(err, domain.ErrFunctionNotFound) {
+		t.Errorf("DeleteFunction() missing error = %v, want ErrFunctionNotFound", err)
+	}
+}
+
+func TestMemoryStoreListFunctionsOrdering(t *testing.T) {
+	store := NewMemoryStore()
+	for _, name := range []string{"a", "b", "c"} {
+		fn := &domain.Function{Name: name, Pinned: name == "a"}
+		if err := store.CreateFunction(fn); err != nil {
+			t.Fatalf("CreateFunction() error = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	fns, total, err := store.ListFunctions(0, 2)
+	if err != nil {
+		t.Fatalf("ListFunctions() error = %v", err)
+	}
+	if total != 3 || len(fns) != 2 {
+		t.Fatalf("ListFunctions() total=%d len=%d, want 3 and 2", total, len(fns))
+	}
+	// 置顶优先，其余按创建时间倒序
+	if fns[0].Name != "a" || fns[1].Name != "c" {
+		t.Errorf("ListFunctions() order = [%s %s], want [a c]", fns[0].Name, fns[1].Name)
+	}
+
+	fns, _, _ = store.ListFunctions(5, 10)
+	if len(fns) != 0 {
+		t.Errorf("ListFunctions() past end returned %d items", len(fns))
+	}
+}
+
+func TestMemoryStoreInvocations(t *testing.T) {
+	store := NewMemoryStore()
+	fn := &domain.Function{Name: "hello"}
+	store.CreateFunction(fn)
+
+	if err := store.CreateInvocation(domain.NewInvocation("missing", "x", domain.TriggerHTTP, nil)); !errors.Is(err, domain.ErrFunctionNotFound) {
+		t.Errorf("CreateInvocation() for missing function error = %v", err)
+	}
+
+	inv := domain.NewInvocation(fn.ID, fn.Name, domain.TriggerHTTP, []byte(`{}`))
+	if err := store.CreateInvocation(inv); err != nil {
+		t.Fatalf("CreateInvocation() error = %v", err)
+	}
+	inv.Start("vm-1", true)
+	inv.Complete([]byte(`{"ok":true}`), 32)
+	if err := store.UpdateInvocation(inv); err != nil {
+		t.Fatalf("UpdateInvocation() error = %v", err)
+	}
+
+	got, err := store.GetInvocationByID(inv.ID)
+	if err != nil {
+		t.Fatalf("GetInvocationByID() error = %v", err)
+	}
+	if got.Status != domain.InvocationStatusSuccess || got.VMID != "vm-1" {
+		t.Errorf("GetInvocationByID() = %+v", got)
+	}
+
+	list, total, _ := store.ListAllInvocations(string(domain.InvocationStatusFailed), 0, 10)
+	if total != 0 || len(list) != 0 {
+		t.Errorf("ListAllInvocations(failed) total=%d, want 0", total)
+	}
+	list, total, _ = store.ListInvocationsByFunction(fn.ID, 0, 10)
+	if total != 1 || len(list) != 1 {
+		t.Errorf("ListInvocationsByFunction() total=%d, want 1", total)
+	}
+
+	// 删除函数时级联删除调用记录
+	store.DeleteFunction(fn.ID)
+	if _, err := store.GetInvocationByID(inv.ID); !errors.Is(err, domain.ErrInvocationNotFound) {
+		t.Errorf("GetInvocationByID() after cascade error = %v", err)
+	}
+}
+
+func TestMemoryStoreInvocationsCursor(t *testing.T) {
+	store := NewMemoryStore()
+	fn := &domain.Function{Name: "hello"}
+	store.CreateFunction(fn)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 后三条记录的创建时间相同，且恰好跨越页边界
+	for i := 0; i < 5; i++ {
+		inv := domain.NewInvocation(fn.ID, fn.Name, domain.TriggerHTTP, nil)
+		inv.CreatedAt = base.Add(time.Duration(min(i, 2)) * time.Second)
+		store.CreateInvocation(inv)
+	}
+
+	// 逐页翻完：2 + 2 + 1，最后一页不足 limit 时游标为空
+	seen := map[string]bool{}
+	var order []*domain.Invocation
+	var cursor domain.InvocationCursor
+	for page := 0; ; page++ {
+		list, next, err := store.ListInvocationsByFunctionCursor(fn.ID, cursor, 2)
+		if err != nil {
+			t.Fatalf("ListInvocationsByFunctionCursor() error = %v", err)
+		}
+		for _, inv := range list {
+			if seen[inv.ID] {
+				t.Errorf("invocation %s returned twice", inv.ID)
+			}
+			seen[inv.ID] = true
+			order = append(order, inv)
+		}
+		if next.IsZero() {
+			break
+		}
+		if last := list[len(list)-1]; next.ID != last.ID || !next.CreatedAt.Equal(last.CreatedAt) {
+			t.Errorf("page %d next cursor = %+v, want last invocation %s", page, next, last.ID)
+		}
+		cursor = next
+	}
+	if len(seen) != 5 {
+		t.Fatalf("paged through %d invocations, want 5", len(seen))
+	}
+	for i := 1; i < len(order); i++ {
+		prev, cur := order[i-1], order[i]
+		if cur.CreatedAt.After(prev.CreatedAt) || (cur.CreatedAt.Equal(prev.CreatedAt) && cur.ID > prev.ID) {
+			t.Errorf("invocations not in descending (created_at, id) order at %d", i)
+		}
+	}
+}
+
+func TestMemoryStoreListAllInvocationsWithFilter(t *testing.T) {
+	store := NewMemoryStore()
+	fn := &domain.Function{Name: "hello"}
+	store.CreateFunction(fn)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 每分钟一条记录，触发类型在 http/cron 间交替，每 3 条中有 1 条失败
+	for i := 0; i < 12; i++ {
+		trigger := domain.TriggerHTTP
+		if i%2 == 1 {
+			trigger = domain.TriggerCron
+		}
+		inv := domain.NewInvocation(fn.ID, fn.Name, trigger, nil)
+		inv.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if i%3 == 0 {
+			inv.Status = domain.InvocationStatusFailed
+		}
+		store.CreateInvocation(inv)
+	}
+
+	after, before := base.Add(2*time.Minute), base.Add(10*time.Minute)
+	tests := []struct {
+		name   string
+		filter *domain.InvocationFilter
+		want   []int // 满足条件的记录序号，按创建时间倒序
+	}{
+		{"none", nil, []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}},
+		{"status", &domain.InvocationFilter{Status: domain.InvocationStatusFailed}, []int{9, 6, 3, 0}},
+		{"time range", &domain.InvocationFilter{After: &after, Before: &before}, []int{9, 8, 7, 6, 5, 4, 3, 2}},
+		{"trigger and time", &domain.InvocationFilter{TriggerType: domain.TriggerCron, After: &after}, []int{11, 9, 7, 5, 3}},
+		{"status, trigger and time", &domain.InvocationFilter{
+			Status: domain.InvocationStatusFailed, TriggerType: domain.TriggerCron, After: &after, Before: &before,
+		}, []int{9, 3}},
+	}
+	for _, tt := range tests {
+		list, total, err := store.ListAllInvocationsWithFilter(tt.filter, 0, 3)
+		if err != nil {
+			t.Fatalf("%s: ListAllInvocationsWithFilter() error = %v", tt.name, err)
+		}
+		if total != len(tt.want) {
+			t.Errorf("%s: total = %d, want %d", tt.name, total, len(tt.want))
+		}
+		for i, inv := range list {
+			if want := base.Add(time.Duration(tt.want[i]) * time.Minute); !inv.CreatedAt.Equal(want) {
+				t.Errorf("%s: list[%d] created at %v, want %v", tt.name, i, inv.CreatedAt, want)
+			}
+		}
+		if wantLen := min(len(tt.want), 3); len(list) != wantLen {
+			t.Errorf("%s: page size = %d, want %d", tt.name, len(list), wantLen)
+		}
+	}
+}
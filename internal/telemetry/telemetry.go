@@ -15,6 +15,7 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -31,7 +32,7 @@ import (
 type Config struct {
 	// Enabled 控制是否启用遥测功能，设为 false 时将跳过追踪器初始化
 	Enabled bool `yaml:"enabled"`
-	// Endpoint 指定 OTLP 接收器的 gRPC 端点地址，例如 "tempo:4317"
+	// Endpoint 指定 OTLP 接收器的 gRPC 端点地址，例如 "tempo:4317"；为空时不导出追踪数据
 	Endpoint string `yaml:"endpoint"` // e.g., tempo:4317
 	// ServiceName 标识当前服务的名称，将作为追踪数据的服务标识
 	ServiceName string `yaml:"service_name"` // e.g., function-gateway
@@ -55,8 +56,8 @@ type Telemetry struct {
 
 // New 根据给定配置创建新的 Telemetry 实例。
 // 该函数执行以下操作：
-//  1. 如果未启用遥测，返回仅包含空操作追踪器的实例
-//  2. 设置配置默认值（服务名、采样率、端点）
+//  1. 如果未启用遥测或未配置 OTLP 端点，返回仅包含空操作追踪器的实例
+//  2. 设置配置默认值（服务名、采样率）
 //  3. 建立到 OTLP 接收器的 gRPC 连接
 //  4. 创建资源对象，包含服务信息和环境属性
 //  5. 配置采样器和追踪提供者
@@ -70,8 +71,8 @@ type Telemetry struct {
 //   - *Telemetry: 初始化完成的遥测实例
 //   - error: 初始化过程中的错误
 func New(ctx context.Context, cfg Config) (*Telemetry, error) {
-	// 如果遥测功能未启用，返回一个仅包含空操作追踪器的实例
-	if !cfg.Enabled {
+	// 如果遥测功能未启用或没有导出目标，返回一个仅包含空操作追踪器的实例
+	if !cfg.Enabled || cfg.Endpoint == "" {
 		return &Telemetry{
 			config: cfg,
 			tracer: otel.Tracer(cfg.ServiceName),
@@ -88,9 +89,6 @@ func New(ctx context.Context, cfg Config) (*Telemetry, error) {
 	if cfg.SampleRate > 1 {
 		cfg.SampleRate = 1.0 // 采样率上限为 100%
 	}
-	if cfg.Endpoint == "" {
-		cfg.Endpoint = "tempo:4317" // 默认 Tempo gRPC 端点
-	}
 
 	// 创建带超时的上下文，限制 gRPC 连接建立时间为 10 秒
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -186,10 +184,10 @@ func (t *Telemetry) Shutdown(ctx context.Context) error {
 	return t.tracerProvider.Shutdown(ctx)
 }
 
-// IsEnabled 返回遥测功能是否已启用。
+// IsEnabled 返回遥测功能是否已启用（已配置导出器）。
 // 可用于在代码中条件性地执行追踪相关逻辑。
 func (t *Telemetry) IsEnabled() bool {
-	return t.config.Enabled
+	return t.tracerProvider != nil
 }
 
 // GetTracer 返回具有指定名称的追踪器。
@@ -286,3 +284,17 @@ func RecordError(ctx context.Context, err error) {
 	span := trace.SpanFromContext(ctx)
 	span.RecordError(err)
 }
+
+// EndSpan 结束 Span，err 不为空时先记录错误并将 Span 标记为失败。
+// 便于在有多个返回路径的操作中统一结束 Span。
+//
+// 参数：
+//   - span: 要结束的 Span
+//   - err: 操作返回的错误，为 nil 表示成功
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
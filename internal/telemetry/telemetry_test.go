@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewWithoutEndpointIsNoop(t *testing.T) {
+	// 未配置端点时不应尝试连接 OTLP 接收器
+	tel, err := New(context.Background(), Config{Enabled: true, ServiceName: "test"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if tel.IsEnabled() {
+		t.Error("telemetry without endpoint should be disabled")
+	}
+	_, span := tel.Tracer().Start(context.Background(), "op")
+	if span.IsRecording() {
+		t.Error("no-op tracer should not record spans")
+	}
+	span.End()
+	if err := tel.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
+
+func TestEndSpanRecordsError(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test")
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	EndSpan(ok, nil)
+	_, failed := tracer.Start(context.Background(), "failed")
+	EndSpan(failed, errors.New("boom"))
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("ok span status = %v, want unset", spans[0].Status().Code)
+	}
+	if spans[1].Status().Code != codes.Error || len(spans[1].Events()) != 1 {
+		t.Errorf("failed span status = %v events = %d, want error with one event", spans[1].Status().Code, len(spans[1].Events()))
+	}
+}
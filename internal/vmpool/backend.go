@@ -0,0 +1,119 @@
+//go:build linux
+// +build linux
+
+package vmpool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	fc "github.com/oriys/nimbus/internal/firecracker"
+	"github.com/sirupsen/logrus"
+)
+
+// machineBackend 负责为池创建和销毁虚拟机。
+// 默认实现基于 Firecracker MachineManager 和 vsock 客户端，测试中可替换为假实现。
+type machineBackend interface {
+	// create 创建并启动一个虚拟机，返回已连接 agent 的池化虚拟机
+	create(ctx context.Context, runtime string, memoryMB, vcpus int64, limits fc.RateLimits) (*PooledVM, error)
+	// destroy 断开与 agent 的连接并销毁虚拟机
+	destroy(ctx context.Context, pvm *PooledVM) error
+	// reclaimMemory 虚拟机进入预热队列时调用，回收空闲内存
+	reclaimMemory(ctx context.Context, pvm *PooledVM) error
+	// restoreMemory 虚拟机被取出复用前调用，归还回收的内存
+	restoreMemory(ctx context.Context, pvm *PooledVM) error
+	// setRateLimits 调整虚拟机的磁盘和网络限速
+	setRateLimits(ctx context.Context, pvm *PooledVM, limits fc.RateLimits) error
+	// markInUse 虚拟机被取出执行调用时调用，返回错误表示后端拒绝交付（如正在排空）
+	markInUse(pvm *PooledVM) error
+	// markIdle 虚拟机执行完调用回到池中时调用，返回 true 表示复用次数已达上限需要回收
+	markIdle(pvm *PooledVM) bool
+	// ping 探测虚拟机内的 agent 是否仍有响应
+	ping(ctx context.Context, pvm *PooledVM) error
+}
+
+// firecrackerBackend 是基于 Firecracker 的虚拟机后端
+type firecrackerBackend struct {
+	machinesMgr *fc.MachineManager
+	logger      *logrus.Logger
+}
+
+// create 创建 Firecracker 虚拟机并建立 vsock 连接。
+func (b *firecrackerBackend) create(ctx context.Context, runtime string, memoryMB, vcpus int64, limits fc.RateLimits) (*PooledVM, error) {
+	// 创建 Firecracker 虚拟机
+	vm, err := b.machinesMgr.CreateVM(ctx, runtime, memoryMB, vcpus, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建 vsock 客户端并连接
+	client := fc.NewVsockClient(vm.VsockCID, b.logger)
+	if err := client.Connect(ctx); err != nil {
+		b.machinesMgr.StopVM(ctx, vm.ID)
+		return nil, fmt.Errorf("failed to connect vsock: %w", err)
+	}
+
+	// 发送心跳验证连接
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx); err != nil {
+		client.Close()
+		b.machinesMgr.StopVM(ctx, vm.ID)
+		return nil, fmt.Errorf("failed to ping agent: %w", err)
+	}
+
+	return &PooledVM{
+		VM:        vm,
+		Client:    client,
+		Runtime:   runtime,
+		Status:    "warm",
+		CreatedAt: time.Now(),
+		LastUsed:  time.Now(),
+		UseCount:  0,
+	}, nil
+}
+
+// destroy 关闭 vsock 连接并停止虚拟机。
+func (b *firecrackerBackend) destroy(ctx context.Context, pvm *PooledVM) error {
+	if pvm.Client != nil {
+		pvm.Client.Close()
+	}
+	return b.machinesMgr.StopVM(ctx, pvm.VM.ID)
+}
+
+// reclaimMemory 为空闲虚拟机的 balloon 充气，未启用 balloon 时不做任何事。
+func (b *firecrackerBackend) reclaimMemory(ctx context.Context, pvm *PooledVM) error {
+	if !b.machinesMgr.BalloonEnabled() {
+		return nil
+	}
+	return b.machinesMgr.SetBalloonTarget(ctx, pvm.VM.ID, b.machinesMgr.IdleBalloonTargetMB())
+}
+
+// restoreMemory 在虚拟机复用前为 balloon 放气，未启用 balloon 时不做任何事。
+func (b *firecrackerBackend) restoreMemory(ctx context.Context, pvm *PooledVM) error {
+	if !b.machinesMgr.BalloonEnabled() {
+		return nil
+	}
+	return b.machinesMgr.SetBalloonTarget(ctx, pvm.VM.ID, 0)
+}
+
+// setRateLimits 通过 Firecracker API 调整运行中虚拟机的限速。
+func (b *firecrackerBackend) setRateLimits(ctx context.Context, pvm *PooledVM, limits fc.RateLimits) error {
+	return b.machinesMgr.UpdateRateLimits(ctx, pvm.VM.ID, limits)
+}
+
+// markInUse 通知 MachineManager 虚拟机正在使用，空闲回收会跳过它，排空时会等待它。
+func (b *firecrackerBackend) markInUse(pvm *PooledVM) error {
+	return b.machinesMgr.MarkVMInUse(pvm.VM.ID)
+}
+
+// markIdle 通知 MachineManager 虚拟机已空闲，返回是否达到 max_vm_reuses。
+func (b *firecrackerBackend) markIdle(pvm *PooledVM) bool {
+	return b.machinesMgr.MarkVMIdle(pvm.VM.ID)
+}
+
+// ping 通过 MachineManager 新建 vsock 连接完成一次 Ping/Pong 往返。
+func (b *firecrackerBackend) ping(ctx context.Context, pvm *PooledVM) error {
+	return b.machinesMgr.PingVM(ctx, pvm.VM.ID)
+}
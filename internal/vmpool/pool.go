@@ -8,18 +8,26 @@ package vmpool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/domain"
 	fc "github.com/oriys/nimbus/internal/firecracker"
 	"github.com/oriys/nimbus/internal/metrics"
+	"github.com/oriys/nimbus/internal/snapshot"
 	"github.com/oriys/nimbus/internal/storage"
+	"github.com/oriys/nimbus/internal/telemetry"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracerName 虚拟机池使用的追踪器名称
+const tracerName = "vmpool"
+
 // PooledVM 表示池中的一个虚拟机实例。
 // 包装了底层的 VM 和 vsock 客户端，添加了池管理所需的元数据。
 type PooledVM struct {
@@ -30,13 +38,22 @@ type PooledVM struct {
 	CreatedAt time.Time       // 创建时间
 	LastUsed  time.Time       // 最后使用时间
 	UseCount  int             // 使用次数
+
+	// InitKey 是虚拟机内 Agent 最近一次成功初始化的载荷指纹（fc.InitPayload.Fingerprint），为空表示尚未初始化。
+	// 由持有虚拟机的调用方在初始化后设置，指纹相同时无需重新初始化
+	InitKey string
 }
 
+// Initializer 在预热虚拟机进入预热队列前为其初始化函数（包括调用函数声明的预热函数），
+// 返回初始化载荷的指纹，返回错误时虚拟机被销毁
+type Initializer func(ctx context.Context, pvm *PooledVM) (string, error)
+
 // Pool 是虚拟机池的主结构。
 // 管理多个运行时的虚拟机池，提供获取和释放虚拟机的接口。
 type Pool struct {
 	cfg         config.PoolConfig    // 池配置
 	machinesMgr *fc.MachineManager   // Firecracker 虚拟机管理器
+	backend     machineBackend       // 虚拟机创建/销毁后端
 	redis       *storage.RedisStore  // Redis 存储（用于分布式场景）
 	metrics     *metrics.Metrics     // 指标收集器
 	logger      *logrus.Logger       // 日志记录器
@@ -77,6 +94,7 @@ func NewPool(
 	p := &Pool{
 		cfg:         cfg,
 		machinesMgr: machinesMgr,
+		backend:     &firecrackerBackend{machinesMgr: machinesMgr, logger: logger},
 		redis:       redis,
 		metrics:     m,
 		logger:      logger,
@@ -94,6 +112,10 @@ func NewPool(
 			allVMs:  make(map[string]*PooledVM),
 		}
 	}
+	// MachineManager 空闲回收停止的虚拟机需要同时从池中移除，否则仍会被计入总数并交付
+	if machinesMgr != nil {
+		machinesMgr.OnVMReaped(p.removeVM)
+	}
 
 	return p
 }
@@ -107,7 +129,7 @@ func (p *Pool) Start() error {
 		// 并发创建预热虚拟机
 		for i := 0; i < pool.config.MinWarm; i++ {
 			go func(rt string) {
-				if _, err := p.createWarmVM(rt); err != nil {
+				if _, err := p.createWarmVM(rt, nil); err != nil {
 					p.logger.WithError(err).WithField("runtime", rt).Error("Failed to pre-warm VM")
 				}
 			}(runtime)
@@ -156,8 +178,7 @@ func (p *Pool) Stop() error {
 	for _, pool := range p.pools {
 		pool.mu.Lock()
 		for _, pvm := range pool.allVMs {
-			pvm.Client.Close()
-			p.machinesMgr.StopVM(context.Background(), pvm.VM.ID)
+			p.backend.destroy(context.Background(), pvm)
 		}
 		pool.mu.Unlock()
 	}
@@ -176,20 +197,47 @@ func (p *Pool) Stop() error {
 //   - bool: 是否为冷启动（true 表示冷启动）
 //   - error: 错误信息
 func (p *Pool) AcquireVM(ctx context.Context, runtime string) (*PooledVM, bool, error) {
+	return p.AcquireVMFor(ctx, runtime, "")
+}
+
+// AcquireVMFor 与 AcquireVM 相同，但优先返回已按 initKey 初始化过的预热虚拟机，
+// 调用方可以跳过对这类虚拟机的重复初始化（以及预热函数）。
+// 参数：
+//   - ctx: 上下文，用于超时控制
+//   - runtime: 运行时类型
+//   - initKey: 调用方将要发送的初始化载荷的指纹，为空时不区分
+//
+// 返回：
+//   - *PooledVM: 获取到的虚拟机，InitKey 与 initKey 相同时表示已初始化
+//   - bool: 是否为冷启动（true 表示冷启动）
+//   - error: 错误信息
+func (p *Pool) AcquireVMFor(ctx context.Context, runtime, initKey string) (*PooledVM, bool, error) {
+	return p.acquireVM(ctx, runtime, initKey, nil)
+}
+
+// restoreFunc 从快照恢复一个已连接 agent 的虚拟机，没有可用快照时返回 snapshot.ErrSnapshotNotFound
+type restoreFunc func(ctx context.Context) (*PooledVM, error)
+
+// acquireVM 实现 AcquireVMFor：需要冷启动时先尝试 restore（非 nil 时）从快照恢复，
+// 恢复失败或没有快照时回退到新建虚拟机。
+func (p *Pool) acquireVM(ctx context.Context, runtime, initKey string, restore restoreFunc) (*PooledVM, bool, error) {
 	pool, ok := p.pools[runtime]
 	if !ok {
 		return nil, false, fmt.Errorf("unknown runtime: %s", runtime)
 	}
 
-	// 尝试获取预热虚拟机（非阻塞）
-	select {
-	case pvm := <-pool.warmVMs:
+	// 尝试获取预热虚拟机（非阻塞），agent 无响应的虚拟机被丢弃
+	if pvm := p.takeWarmVM(ctx, pool, initKey); pvm != nil {
+		if err := p.claimVM(pool, pvm); err != nil {
+			return nil, false, err
+		}
 		// 获取到预热虚拟机，更新状态
 		pool.mu.Lock()
 		pvm.Status = "busy"
 		pvm.LastUsed = time.Now()
 		pvm.UseCount++
 		pool.mu.Unlock()
+		p.restoreMemory(ctx, pvm)
 
 		p.logger.WithFields(logrus.Fields{
 			"vm_id":   pvm.VM.ID,
@@ -197,8 +245,6 @@ func (p *Pool) AcquireVM(ctx context.Context, runtime string) (*PooledVM, bool,
 		}).Debug("Acquired warm VM")
 
 		return pvm, false, nil // false = 热启动
-	default:
-		// 没有预热虚拟机可用
 	}
 
 	// 检查是否可以创建新虚拟机
@@ -210,27 +256,55 @@ func (p *Pool) AcquireVM(ctx context.Context, runtime string) (*PooledVM, bool,
 		// 池已满，等待预热虚拟机
 		select {
 		case pvm := <-pool.warmVMs:
-			pool.mu.Lock()
-			pvm.Status = "busy"
-			pvm.LastUsed = time.Now()
-			pvm.UseCount++
-			pool.mu.Unlock()
-			return pvm, false, nil
+			if p.probeWarmVM(ctx, pool, pvm) {
+				if err := p.claimVM(pool, pvm); err != nil {
+					return nil, false, err
+				}
+				pool.mu.Lock()
+				pvm.Status = "busy"
+				pvm.LastUsed = time.Now()
+				pvm.UseCount++
+				pool.mu.Unlock()
+				p.restoreMemory(ctx, pvm)
+				return pvm, false, nil
+			}
+			// 探测失败的虚拟机已被移除，腾出的名额用于冷启动
 		case <-ctx.Done():
 			return nil, false, ctx.Err()
 		}
 	}
 
-	// 创建新虚拟机（冷启动）
-	pvm, err := p.createVM(ctx, runtime)
-	if err != nil {
-		return nil, false, err
+	// 创建新虚拟机（冷启动），cold_start span 覆盖快照恢复或虚拟机启动，以及 vsock 连接建立
+	coldCtx, span := telemetry.GetTracer(tracerName).Start(ctx, "cold_start",
+		trace.WithAttributes(attribute.String("runtime", runtime)))
+	var pvm *PooledVM
+	if restore != nil {
+		restored, err := restore(coldCtx)
+		if err == nil {
+			pvm = restored
+		} else if !errors.Is(err, snapshot.ErrSnapshotNotFound) {
+			p.logger.WithError(err).WithField("runtime", runtime).Warn("Failed to restore VM from snapshot, booting a new VM")
+		}
+	}
+	span.SetAttributes(attribute.Bool("snapshot.restored", pvm != nil))
+	if pvm == nil {
+		created, err := p.createVM(coldCtx, runtime)
+		if err != nil {
+			telemetry.EndSpan(span, err)
+			return nil, false, err
+		}
+		pvm = created
 	}
+	span.SetAttributes(attribute.String("vm.id", pvm.VM.ID))
+	span.End()
 
 	pool.mu.Lock()
 	pvm.Status = "busy"
 	pool.allVMs[pvm.VM.ID] = pvm
 	pool.mu.Unlock()
+	if err := p.claimVM(pool, pvm); err != nil {
+		return nil, false, err
+	}
 
 	p.logger.WithFields(logrus.Fields{
 		"vm_id":   pvm.VM.ID,
@@ -240,6 +314,95 @@ func (p *Pool) AcquireVM(ctx context.Context, runtime string) (*PooledVM, bool,
 	return pvm, true, nil // true = 冷启动
 }
 
+// takeWarmVM 非阻塞地从预热队列取出一个 agent 仍有响应的虚拟机。
+// initKey 非空时优先取已按该指纹初始化的虚拟机，没有时取队首的虚拟机。
+// 探测失败的虚拟机被移除后继续取下一个，队列为空时返回 nil。
+func (p *Pool) takeWarmVM(ctx context.Context, pool *RuntimePool, initKey string) *PooledVM {
+	if initKey != "" {
+		if pvm := p.takeInitializedVM(pool, initKey); pvm != nil && p.probeWarmVM(ctx, pool, pvm) {
+			return pvm
+		}
+	}
+	for {
+		select {
+		case pvm := <-pool.warmVMs:
+			if p.probeWarmVM(ctx, pool, pvm) {
+				return pvm
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// takeInitializedVM 非阻塞地在预热队列中查找 InitKey 为 initKey 的虚拟机并取出，
+// 查找时取出的其他虚拟机放回队列尾部，没有找到时返回 nil。
+func (p *Pool) takeInitializedVM(pool *RuntimePool, initKey string) *PooledVM {
+	var found *PooledVM
+	var skipped []*PooledVM
+	for n := len(pool.warmVMs); n > 0 && found == nil; n-- {
+		select {
+		case pvm := <-pool.warmVMs:
+			if pvm.InitKey == initKey {
+				found = pvm
+			} else {
+				skipped = append(skipped, pvm)
+			}
+		default:
+			n = 0
+		}
+	}
+	for _, pvm := range skipped {
+		p.enqueueWarmVM(pool, pvm)
+	}
+	return found
+}
+
+// enqueueWarmVM 将虚拟机放入预热队列，队列已满时从池中移除并销毁。
+func (p *Pool) enqueueWarmVM(pool *RuntimePool, pvm *PooledVM) error {
+	select {
+	case pool.warmVMs <- pvm:
+		return nil
+	default:
+		pool.mu.Lock()
+		delete(pool.allVMs, pvm.VM.ID)
+		pool.mu.Unlock()
+		return p.backend.destroy(context.Background(), pvm)
+	}
+}
+
+// claimVM 在交付虚拟机前通知后端其开始使用。
+// 后端拒绝时（如 MachineManager 正在排空）虚拟机从池中移除并销毁，返回后端的错误。
+func (p *Pool) claimVM(pool *RuntimePool, pvm *PooledVM) error {
+	if err := p.backend.markInUse(pvm); err != nil {
+		pool.mu.Lock()
+		delete(pool.allVMs, pvm.VM.ID)
+		pool.mu.Unlock()
+		p.backend.destroy(context.Background(), pvm)
+		return err
+	}
+	return nil
+}
+
+// probeWarmVM 在交付预热虚拟机前探测其 agent，避免请求落到已静默失效的虚拟机上。
+// 探测失败的虚拟机从池中移除并销毁，返回 false。
+func (p *Pool) probeWarmVM(ctx context.Context, pool *RuntimePool, pvm *PooledVM) bool {
+	err := p.backend.ping(ctx, pvm)
+	if err == nil {
+		return true
+	}
+	p.logger.WithError(err).WithFields(logrus.Fields{
+		"vm_id":   pvm.VM.ID,
+		"runtime": pool.runtime,
+	}).Warn("Discarding unresponsive warm VM")
+
+	pool.mu.Lock()
+	delete(pool.allVMs, pvm.VM.ID)
+	pool.mu.Unlock()
+	p.backend.destroy(context.Background(), pvm)
+	return false
+}
+
 // ReleaseVM 释放虚拟机回池中。
 // 根据虚拟机的使用情况决定是回收还是销毁。
 func (p *Pool) ReleaseVM(runtime, vmID string) error {
@@ -258,19 +421,23 @@ func (p *Pool) ReleaseVM(runtime, vmID string) error {
 	// 检查是否应该销毁虚拟机：
 	// 1. 使用次数超过限制
 	// 2. 存活时间超过限制
-	if pvm.UseCount >= p.cfg.MaxInvocations || time.Since(pvm.CreatedAt) > p.cfg.MaxVMAge {
+	// 3. 复用次数达到 MachineManager 的 max_vm_reuses
+	reusesExhausted := p.backend.markIdle(pvm)
+	if pvm.UseCount >= p.cfg.MaxInvocations || time.Since(pvm.CreatedAt) > p.cfg.MaxVMAge || reusesExhausted {
 		delete(pool.allVMs, vmID)
 		pool.mu.Unlock()
 
 		// 销毁虚拟机
-		pvm.Client.Close()
-		return p.machinesMgr.StopVM(context.Background(), vmID)
+		return p.backend.destroy(context.Background(), pvm)
 	}
 
-	// 标记为预热状态
+	// 标记为预热状态。虚拟机不销毁重建，保留 InitKey：下次调用的初始化载荷指纹相同时
+	// 调度器跳过初始化（预热函数不会再次调用），不同时重新发送 InitPayload 覆盖上一个函数留下的状态
 	pvm.Status = "warm"
 	pool.mu.Unlock()
 
+	p.reclaimMemory(pvm)
+
 	// 尝试放回预热队列
 	select {
 	case pool.warmVMs <- pvm:
@@ -280,52 +447,125 @@ func (p *Pool) ReleaseVM(runtime, vmID string) error {
 		pool.mu.Lock()
 		delete(pool.allVMs, vmID)
 		pool.mu.Unlock()
-		pvm.Client.Close()
-		return p.machinesMgr.StopVM(context.Background(), vmID)
+		return p.backend.destroy(context.Background(), pvm)
 	}
 
 	return nil
 }
 
-// createVM 创建一个新的虚拟机并建立 vsock 连接。
-func (p *Pool) createVM(ctx context.Context, runtime string) (*PooledVM, error) {
-	pool := p.pools[runtime]
-
-	// 创建 Firecracker 虚拟机
-	vm, err := p.machinesMgr.CreateVM(ctx, runtime, int64(pool.config.MemoryMB), int64(pool.config.VCPUs))
-	if err != nil {
-		return nil, err
+// removeVM 将已在池外停止的虚拟机（如被 MachineManager 空闲回收）从池中移除并关闭 vsock 连接。
+// 虚拟机仍在预热队列中时一并取出，队列中的其他虚拟机按原顺序放回。
+func (p *Pool) removeVM(vmID, runtime string) {
+	pool, ok := p.pools[runtime]
+	if !ok {
+		return
+	}
+	pool.mu.Lock()
+	pvm, ok := pool.allVMs[vmID]
+	delete(pool.allVMs, vmID)
+	pool.mu.Unlock()
+	if !ok {
+		return
 	}
 
-	// 创建 vsock 客户端并连接
-	client := fc.NewVsockClient(vm.VsockCID, p.logger)
-	if err := client.Connect(ctx); err != nil {
-		p.machinesMgr.StopVM(ctx, vm.ID)
-		return nil, fmt.Errorf("failed to connect vsock: %w", err)
+	var kept []*PooledVM
+	for n := len(pool.warmVMs); n > 0; n-- {
+		select {
+		case queued := <-pool.warmVMs:
+			if queued.VM.ID != vmID {
+				kept = append(kept, queued)
+			}
+		default:
+			n = 0
+		}
+	}
+	for _, queued := range kept {
+		p.enqueueWarmVM(pool, queued)
+	}
+	if pvm.Client != nil {
+		pvm.Client.Close()
 	}
+	p.logger.WithFields(logrus.Fields{
+		"vm_id":   vmID,
+		"runtime": runtime,
+	}).Debug("Removed reaped VM from pool")
+}
 
-	// 发送心跳验证连接
-	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+// reclaimMemory 回收即将进入预热队列的虚拟机的空闲内存。
+// 失败只影响内存密度，不影响虚拟机可用性，因此仅记录警告。
+func (p *Pool) reclaimMemory(pvm *PooledVM) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := client.Ping(pingCtx); err != nil {
-		client.Close()
-		p.machinesMgr.StopVM(ctx, vm.ID)
-		return nil, fmt.Errorf("failed to ping agent: %w", err)
+	if err := p.backend.reclaimMemory(ctx, pvm); err != nil {
+		p.logger.WithError(err).WithField("vm_id", pvm.VM.ID).Warn("Failed to reclaim idle VM memory")
 	}
+}
 
-	return &PooledVM{
-		VM:        vm,
-		Client:    client,
-		Runtime:   runtime,
-		Status:    "warm",
-		CreatedAt: time.Now(),
-		LastUsed:  time.Now(),
-		UseCount:  0,
-	}, nil
+// restoreMemory 在复用预热虚拟机前归还回收的内存。
+// balloon 开启了 deflate_on_oom，放气失败时 guest 仍能在内存紧张时自行放气，因此仅记录警告。
+func (p *Pool) restoreMemory(ctx context.Context, pvm *PooledVM) {
+	if err := p.backend.restoreMemory(ctx, pvm); err != nil {
+		p.logger.WithError(err).WithField("vm_id", pvm.VM.ID).Warn("Failed to restore VM memory before reuse")
+	}
+}
+
+// createVM 按运行时池的配置创建一个新的虚拟机并建立 vsock 连接。
+func (p *Pool) createVM(ctx context.Context, runtime string) (*PooledVM, error) {
+	pool := p.pools[runtime]
+	return p.backend.create(ctx, runtime, int64(pool.config.MemoryMB), int64(pool.config.VCPUs), effectiveRateLimits(pool.config, nil))
+}
+
+// effectiveRateLimits 计算函数实际使用的限速：函数设置的项覆盖运行时默认值，未设置的项沿用运行时配置。
+// 参数：
+//   - rtCfg: 运行时配置，提供默认限速
+//   - fnLimits: 函数的 IO 限速，可为 nil
+func effectiveRateLimits(rtCfg config.RuntimeConfig, fnLimits *domain.IOLimits) fc.RateLimits {
+	limits := fc.RateLimits{
+		DiskMBps: rtCfg.DiskMBps,
+		DiskIOPS: rtCfg.DiskIOPS,
+		NetMBps:  rtCfg.NetMBps,
+		NetPPS:   rtCfg.NetPPS,
+	}
+	if fnLimits == nil {
+		return limits
+	}
+	if fnLimits.DiskMBps > 0 {
+		limits.DiskMBps = fnLimits.DiskMBps
+	}
+	if fnLimits.DiskIOPS > 0 {
+		limits.DiskIOPS = fnLimits.DiskIOPS
+	}
+	if fnLimits.NetMBps > 0 {
+		limits.NetMBps = fnLimits.NetMBps
+	}
+	if fnLimits.NetPPS > 0 {
+		limits.NetPPS = fnLimits.NetPPS
+	}
+	return limits
+}
+
+// ApplyIOLimits 按函数的 IO 限速调整已取出的虚拟机。
+// 池中的虚拟机按运行时默认限速创建，复用时可能残留上一个函数的限速，因此每次取出后都需调用；
+// 限速未变化时后端不会调用 Firecracker API。
+// 参数：
+//   - ctx: 上下文
+//   - pvm: 通过 AcquireVM 取出的虚拟机
+//   - fnLimits: 函数的 IO 限速，nil 表示使用运行时默认值
+//
+// 返回：
+//   - error: 运行时不存在或调整失败时返回错误
+func (p *Pool) ApplyIOLimits(ctx context.Context, pvm *PooledVM, fnLimits *domain.IOLimits) error {
+	pool, ok := p.pools[pvm.Runtime]
+	if !ok {
+		return fmt.Errorf("unknown runtime: %s", pvm.Runtime)
+	}
+	return p.backend.setRateLimits(ctx, pvm, effectiveRateLimits(pool.config, fnLimits))
 }
 
 // createWarmVM 创建一个预热虚拟机并加入池中。
-func (p *Pool) createWarmVM(runtime string) (*PooledVM, error) {
+// init 不为空时虚拟机先以 cold 状态注册，初始化（包括预热函数）成功后才标记为预热并进入预热队列，
+// 初始化期间不会被调用取走或被健康检查探测；初始化失败时虚拟机被销毁。
+func (p *Pool) createWarmVM(runtime string, init Initializer) (*PooledVM, error) {
 	ctx, cancel := context.WithTimeout(p.ctx, p.cfg.HealthCheckInterval)
 	defer cancel()
 
@@ -337,9 +577,29 @@ func (p *Pool) createWarmVM(runtime string) (*PooledVM, error) {
 	// 注册到池中
 	pool := p.pools[runtime]
 	pool.mu.Lock()
+	if init != nil {
+		pvm.Status = "cold"
+	}
 	pool.allVMs[pvm.VM.ID] = pvm
 	pool.mu.Unlock()
 
+	if init != nil {
+		initKey, err := init(p.ctx, pvm)
+		if err != nil {
+			pool.mu.Lock()
+			delete(pool.allVMs, pvm.VM.ID)
+			pool.mu.Unlock()
+			p.backend.destroy(context.Background(), pvm)
+			return nil, err
+		}
+		pool.mu.Lock()
+		pvm.InitKey = initKey
+		pvm.Status = "warm"
+		pool.mu.Unlock()
+	}
+
+	p.reclaimMemory(pvm)
+
 	// 放入预热队列
 	select {
 	case pool.warmVMs <- pvm:
@@ -350,6 +610,55 @@ func (p *Pool) createWarmVM(runtime string) (*PooledVM, error) {
 	return pvm, nil
 }
 
+// Prewarm 为指定运行时额外创建预热虚拟机，供函数级预热策略提前消除冷启动。
+// 创建数量受 MaxTotal 限制，虚拟机在后台并发创建。
+// 参数：
+//   - runtime: 运行时类型
+//   - count: 期望新增的预热虚拟机数量
+//
+// 返回：
+//   - int: 实际发起创建的数量
+//   - error: 运行时不存在时返回错误
+func (p *Pool) Prewarm(runtime string, count int) (int, error) {
+	return p.PrewarmFunction(runtime, count, nil)
+}
+
+// PrewarmFunction 与 Prewarm 相同，但每个虚拟机在进入预热队列前先由 init 初始化函数，
+// 函数声明的预热函数返回后虚拟机才被视为预热完成，之后相同配置的调用无需再初始化。
+// 参数：
+//   - runtime: 运行时类型
+//   - count: 期望新增的预热虚拟机数量
+//   - init: 初始化函数，为 nil 时只启动虚拟机
+//
+// 返回：
+//   - int: 实际发起创建的数量
+//   - error: 运行时不存在时返回错误
+func (p *Pool) PrewarmFunction(runtime string, count int, init Initializer) (int, error) {
+	pool, ok := p.pools[runtime]
+	if !ok {
+		return 0, fmt.Errorf("unknown runtime: %s", runtime)
+	}
+
+	pool.mu.Lock()
+	available := pool.config.MaxTotal - len(pool.allVMs)
+	pool.mu.Unlock()
+	if count > available {
+		count = available
+	}
+	if count <= 0 {
+		return 0, nil
+	}
+
+	for i := 0; i < count; i++ {
+		go func() {
+			if _, err := p.createWarmVM(runtime, init); err != nil {
+				p.logger.WithError(err).WithField("runtime", runtime).Error("Failed to prewarm VM")
+			}
+		}()
+	}
+	return count, nil
+}
+
 // healthCheckWorker 定期执行健康检查。
 // 移除不健康或过期的虚拟机。
 func (p *Pool) healthCheckWorker() {
@@ -378,10 +687,10 @@ func (p *Pool) runHealthChecks() {
 				continue
 			}
 
-			// 发送心跳检测
+			// 通过新的 vsock 连接探测 agent
 			ctx, cancel := context.WithTimeout(p.ctx, 2*time.Second)
-			if err := pvm.Client.Ping(ctx); err != nil {
-				p.logger.WithFields(logrus.Fields{
+			if err := p.backend.ping(ctx, pvm); err != nil {
+				p.logger.WithError(err).WithFields(logrus.Fields{
 					"vm_id":   vmID,
 					"runtime": runtime,
 				}).Warn("VM health check failed")
@@ -397,10 +706,12 @@ func (p *Pool) runHealthChecks() {
 
 		// 移除不健康或过期的虚拟机
 		for _, vmID := range toRemove {
-			pvm := pool.allVMs[vmID]
+			pvm, ok := pool.allVMs[vmID]
+			if !ok {
+				continue
+			}
 			delete(pool.allVMs, vmID)
-			pvm.Client.Close()
-			p.machinesMgr.StopVM(context.Background(), vmID)
+			p.backend.destroy(context.Background(), pvm)
 		}
 
 		pool.mu.Unlock()
@@ -443,7 +754,7 @@ func (p *Pool) checkScaling() {
 			// 并发创建虚拟机
 			for i := 0; i < toCreate; i++ {
 				go func(rt string) {
-					if _, err := p.createWarmVM(rt); err != nil {
+					if _, err := p.createWarmVM(rt, nil); err != nil {
 						p.logger.WithError(err).WithField("runtime", rt).Error("Failed to scale up VM")
 					}
 				}(runtime)
@@ -452,6 +763,15 @@ func (p *Pool) checkScaling() {
 	}
 }
 
+// MinWarm 返回每个运行时配置的最小预热虚拟机数，用于让 MachineManager 的空闲回收保留这些虚拟机。
+func (p *Pool) MinWarm() map[string]int {
+	minWarm := make(map[string]int, len(p.pools))
+	for runtime, pool := range p.pools {
+		minWarm[runtime] = pool.config.MinWarm
+	}
+	return minWarm
+}
+
 // GetStats 获取所有运行时的池状态统计。
 func (p *Pool) GetStats() map[string]PoolStats {
 	stats := make(map[string]PoolStats)
@@ -539,59 +859,41 @@ func (p *Pool) GetAllVMIDs(runtime string) []string {
 	return vmIDs
 }
 
-// SnapshotPool 是基于快照的虚拟机池。
-// 通过预先创建的快照快速恢复虚拟机，实现更快的冷启动。
+// SnapshotPool 是基于函数快照的虚拟机池。
+// 与 Pool 一样优先复用预热虚拟机，需要冷启动时从快照管理器中函数版本的就绪快照恢复，
+// 恢复出的虚拟机登记在底层池中，与新建的虚拟机一样释放和回收。
 type SnapshotPool struct {
 	pool      *Pool             // 底层虚拟机池
-	snapshots map[string]string // 运行时到快照 ID 的映射
-	mu        sync.RWMutex      // 保护 snapshots 的读写锁
+	snapshots *snapshot.Manager // 快照管理器，负责查找快照、恢复虚拟机并记录恢复统计
 }
 
 // NewSnapshotPool 创建基于快照的虚拟机池。
-func NewSnapshotPool(pool *Pool) *SnapshotPool {
+func NewSnapshotPool(pool *Pool, snapshots *snapshot.Manager) *SnapshotPool {
 	return &SnapshotPool{
 		pool:      pool,
-		snapshots: make(map[string]string),
+		snapshots: snapshots,
 	}
 }
 
-// CreateSnapshot 为指定运行时创建快照。
-// 创建一个新虚拟机，然后对其创建快照。
-func (sp *SnapshotPool) CreateSnapshot(ctx context.Context, runtime string) (string, error) {
-	// 创建一个新的虚拟机
-	pvm, err := sp.pool.createVM(ctx, runtime)
-	if err != nil {
-		return "", err
-	}
-	defer sp.pool.ReleaseVM(runtime, pvm.VM.ID)
-
-	// 创建快照
-	snapshotID := uuid.New().String()
-	if err := sp.pool.machinesMgr.CreateSnapshot(ctx, pvm.VM.ID, snapshotID); err != nil {
-		return "", err
-	}
-
-	// 记录快照
-	sp.mu.Lock()
-	sp.snapshots[runtime] = snapshotID
-	sp.mu.Unlock()
-
-	return snapshotID, nil
+// AcquireVMFor 为函数的指定版本获取虚拟机，参数和返回值与 Pool.AcquireVMFor 相同。
+// 冷启动时若该版本有就绪的快照则从快照恢复，没有快照或恢复失败时新建虚拟机。
+func (sp *SnapshotPool) AcquireVMFor(ctx context.Context, fn *domain.Function, version int, initKey string) (*PooledVM, bool, error) {
+	return sp.pool.acquireVM(ctx, string(fn.Runtime), initKey, func(ctx context.Context) (*PooledVM, error) {
+		return sp.AcquireFromSnapshot(ctx, fn, version)
+	})
 }
 
-// AcquireFromSnapshot 从快照恢复一个虚拟机。
-// 比从头创建虚拟机更快。
-func (sp *SnapshotPool) AcquireFromSnapshot(ctx context.Context, runtime string) (*PooledVM, error) {
-	sp.mu.RLock()
-	snapshotID, ok := sp.snapshots[runtime]
-	sp.mu.RUnlock()
-
-	if !ok {
-		return nil, fmt.Errorf("no snapshot for runtime: %s", runtime)
+// AcquireFromSnapshot 从函数版本的就绪快照恢复一个虚拟机并建立 vsock 连接。
+// 恢复经由快照管理器进行，实测的恢复耗时计入该快照的统计。
+// 返回的虚拟机尚未登记到池中，通常经由 AcquireVMFor 调用。
+func (sp *SnapshotPool) AcquireFromSnapshot(ctx context.Context, fn *domain.Function, version int) (*PooledVM, error) {
+	snap, err := sp.snapshots.GetSnapshot(ctx, fn, version)
+	if err != nil {
+		return nil, err
 	}
 
 	// 从快照恢复虚拟机
-	vm, err := sp.pool.machinesMgr.RestoreFromSnapshot(ctx, snapshotID, runtime)
+	vm, err := sp.snapshots.RestoreSnapshot(ctx, sp.pool.machinesMgr, snap)
 	if err != nil {
 		return nil, err
 	}
@@ -600,14 +902,14 @@ func (sp *SnapshotPool) AcquireFromSnapshot(ctx context.Context, runtime string)
 	client := fc.NewVsockClient(vm.VsockCID, sp.pool.logger)
 	if err := client.Connect(ctx); err != nil {
 		sp.pool.machinesMgr.StopVM(ctx, vm.ID)
-		return nil, err
+		return nil, fmt.Errorf("failed to connect vsock: %w", err)
 	}
 
 	return &PooledVM{
 		VM:        vm,
 		Client:    client,
-		Runtime:   runtime,
-		Status:    "busy",
+		Runtime:   string(fn.Runtime),
+		Status:    "cold",
 		CreatedAt: time.Now(),
 		LastUsed:  time.Now(),
 		UseCount:  0,
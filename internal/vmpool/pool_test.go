@@ -0,0 +1,561 @@
+//go:build linux
+// +build linux
+
+package vmpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/config"
+	"github.com/oriys/nimbus/internal/domain"
+	fc "github.com/oriys/nimbus/internal/firecracker"
+	"github.com/oriys/nimbus/internal/snapshot"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeBackend 在内存中创建虚拟机，记录创建和销毁次数、内存是否被回收、限速调整次数以及创建时所在的 span，
+// unresponsive 中的虚拟机探测失败
+type fakeBackend struct {
+	mu           sync.Mutex
+	created      int
+	destroyed    []string
+	reclaimed    map[string]bool
+	limitUpdate  int
+	createSpan   trace.SpanContext
+	unresponsive map[string]bool
+	pings        int
+}
+
+func (b *fakeBackend) create(ctx context.Context, runtime string, memoryMB, vcpus int64, limits fc.RateLimits) (*PooledVM, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.created++
+	b.createSpan = trace.SpanContextFromContext(ctx)
+	return &PooledVM{
+		VM:        &fc.VM{ID: fmt.Sprintf("vm-%d", b.created), Runtime: runtime, MemoryMB: memoryMB, VCPUs: vcpus, Limits: limits},
+		Runtime:   runtime,
+		Status:    "warm",
+		CreatedAt: time.Now(),
+		LastUsed:  time.Now(),
+	}, nil
+}
+
+func (b *fakeBackend) destroy(ctx context.Context, pvm *PooledVM) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.destroyed = append(b.destroyed, pvm.VM.ID)
+	return nil
+}
+
+func (b *fakeBackend) reclaimMemory(ctx context.Context, pvm *PooledVM) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.reclaimed == nil {
+		b.reclaimed = make(map[string]bool)
+	}
+	b.reclaimed[pvm.VM.ID] = true
+	return nil
+}
+
+func (b *fakeBackend) restoreMemory(ctx context.Context, pvm *PooledVM) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.reclaimed, pvm.VM.ID)
+	return nil
+}
+
+func (b *fakeBackend) setRateLimits(ctx context.Context, pvm *PooledVM, limits fc.RateLimits) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if pvm.VM.Limits != limits {
+		pvm.VM.Limits = limits
+		b.limitUpdate++
+	}
+	return nil
+}
+
+func (b *fakeBackend) markInUse(pvm *PooledVM) error { return nil }
+
+func (b *fakeBackend) markIdle(pvm *PooledVM) bool { return false }
+
+func (b *fakeBackend) ping(ctx context.Context, pvm *PooledVM) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pings++
+	if b.unresponsive[pvm.VM.ID] {
+		return fmt.Errorf("agent of vm %s is unresponsive", pvm.VM.ID)
+	}
+	return nil
+}
+
+func (b *fakeBackend) setUnresponsive(vmID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.unresponsive == nil {
+		b.unresponsive = make(map[string]bool)
+	}
+	b.unresponsive[vmID] = true
+}
+
+func (b *fakeBackend) isReclaimed(vmID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reclaimed[vmID]
+}
+
+func newTestPool(maxTotal, maxInvocations int) (*Pool, *fakeBackend) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.PoolConfig{
+		MaxVMAge:       time.Hour,
+		MaxInvocations: maxInvocations,
+		Runtimes: []config.RuntimeConfig{
+			{Runtime: "python3.11", MaxTotal: maxTotal, MemoryMB: 128, VCPUs: 1},
+		},
+	}
+	backend := &fakeBackend{}
+	p := NewPool(cfg, nil, nil, nil, logger)
+	p.backend = backend
+	return p, backend
+}
+
+func TestAcquireReleaseReusesVM(t *testing.T) {
+	p, backend := newTestPool(2, 100)
+	ctx := context.Background()
+
+	first, cold, err := p.AcquireVM(ctx, "python3.11")
+	if err != nil || !cold {
+		t.Fatalf("first acquire: cold=%v err=%v, want cold start", cold, err)
+	}
+	if st := p.GetStats()["python3.11"]; st.BusyVMs != 1 || st.WarmVMs != 0 || st.TotalVMs != 1 || st.MaxVMs != 2 {
+		t.Errorf("stats after acquire = %+v", st)
+	}
+
+	if err := p.ReleaseVM("python3.11", first.VM.ID); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if st := p.GetStats()["python3.11"]; st.BusyVMs != 0 || st.WarmVMs != 1 || st.TotalVMs != 1 {
+		t.Errorf("stats after release = %+v", st)
+	}
+
+	second, cold, err := p.AcquireVM(ctx, "python3.11")
+	if err != nil || cold {
+		t.Fatalf("second acquire: cold=%v err=%v, want warm VM", cold, err)
+	}
+	if second.VM.ID != first.VM.ID || second.UseCount != 1 {
+		t.Errorf("second acquire got %s (use count %d), want reused %s", second.VM.ID, second.UseCount, first.VM.ID)
+	}
+	if backend.created != 1 || len(backend.destroyed) != 0 {
+		t.Errorf("created %d destroyed %v, want one VM reused", backend.created, backend.destroyed)
+	}
+}
+
+func TestAcquireDiscardsUnresponsiveWarmVM(t *testing.T) {
+	p, backend := newTestPool(3, 100)
+	ctx := context.Background()
+
+	// 两个虚拟机回到预热队列，其中先入队的 agent 已失效
+	dead, _, _ := p.AcquireVM(ctx, "python3.11")
+	alive, _, _ := p.AcquireVM(ctx, "python3.11")
+	p.ReleaseVM("python3.11", dead.VM.ID)
+	p.ReleaseVM("python3.11", alive.VM.ID)
+	backend.setUnresponsive(dead.VM.ID)
+
+	got, cold, err := p.AcquireVM(ctx, "python3.11")
+	if err != nil || cold {
+		t.Fatalf("acquire: cold=%v err=%v, want warm VM", cold, err)
+	}
+	if got.VM.ID != alive.VM.ID {
+		t.Errorf("acquired %s, want responsive %s", got.VM.ID, alive.VM.ID)
+	}
+	if len(backend.destroyed) != 1 || backend.destroyed[0] != dead.VM.ID {
+		t.Errorf("destroyed %v, want only %s", backend.destroyed, dead.VM.ID)
+	}
+	if st := p.GetStats()["python3.11"]; st.TotalVMs != 1 || st.BusyVMs != 1 {
+		t.Errorf("stats = %+v, want only the acquired VM left", st)
+	}
+
+	// 预热队列中只剩失效虚拟机时改为冷启动
+	p.ReleaseVM("python3.11", got.VM.ID)
+	backend.setUnresponsive(got.VM.ID)
+	if _, cold, err := p.AcquireVM(ctx, "python3.11"); err != nil || !cold {
+		t.Errorf("acquire with only dead warm VMs: cold=%v err=%v, want cold start", cold, err)
+	}
+}
+
+func TestHealthCheckRemovesUnresponsiveVM(t *testing.T) {
+	p, backend := newTestPool(2, 100)
+	ctx := context.Background()
+
+	a, _, _ := p.AcquireVM(ctx, "python3.11")
+	b, _, _ := p.AcquireVM(ctx, "python3.11")
+	p.ReleaseVM("python3.11", a.VM.ID)
+	backend.setUnresponsive(a.VM.ID)
+	backend.setUnresponsive(b.VM.ID) // 忙碌中的虚拟机不探测
+
+	p.runHealthChecks()
+	if backend.pings != 1 {
+		t.Errorf("pings = %d, want only the warm VM probed", backend.pings)
+	}
+	if len(backend.destroyed) != 1 || backend.destroyed[0] != a.VM.ID {
+		t.Errorf("destroyed %v, want %s", backend.destroyed, a.VM.ID)
+	}
+	if st := p.GetStats()["python3.11"]; st.TotalVMs != 1 || st.BusyVMs != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestAcquireRespectsMaxTotal(t *testing.T) {
+	p, backend := newTestPool(2, 100)
+	ctx := context.Background()
+
+	var held []*PooledVM
+	for i := 0; i < 2; i++ {
+		pvm, _, err := p.AcquireVM(ctx, "python3.11")
+		if err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+		held = append(held, pvm)
+	}
+
+	// 池已满时等待空闲虚拟机，不再创建新的
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := p.AcquireVM(waitCtx, "python3.11"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire over cap: err=%v, want deadline exceeded", err)
+	}
+	if backend.created != 2 {
+		t.Errorf("created %d VMs, want cap of 2", backend.created)
+	}
+
+	// 归还后等待中的获取拿到被释放的虚拟机
+	done := make(chan *PooledVM, 1)
+	go func() {
+		pvm, _, err := p.AcquireVM(ctx, "python3.11")
+		if err != nil {
+			t.Errorf("acquire after release: %v", err)
+		}
+		done <- pvm
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := p.ReleaseVM("python3.11", held[0].VM.ID); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	select {
+	case pvm := <-done:
+		if pvm == nil || pvm.VM.ID != held[0].VM.ID {
+			t.Errorf("waiting acquire got %v, want released VM %s", pvm, held[0].VM.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting acquire did not receive released VM")
+	}
+	if st := p.GetStats()["python3.11"]; st.TotalVMs != 2 || st.BusyVMs != 2 {
+		t.Errorf("stats = %+v, want 2 busy of 2", st)
+	}
+}
+
+func TestReleaseDestroysVMAfterMaxInvocations(t *testing.T) {
+	p, backend := newTestPool(2, 1)
+	ctx := context.Background()
+
+	pvm, _, _ := p.AcquireVM(ctx, "python3.11")
+	p.ReleaseVM("python3.11", pvm.VM.ID)
+
+	// 第二次使用后达到调用次数上限，归还时销毁而不是放回池中
+	pvm, cold, _ := p.AcquireVM(ctx, "python3.11")
+	if cold {
+		t.Fatal("expected warm VM on second acquire")
+	}
+	if err := p.ReleaseVM("python3.11", pvm.VM.ID); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if len(backend.destroyed) != 1 || backend.destroyed[0] != pvm.VM.ID {
+		t.Errorf("destroyed %v, want [%s]", backend.destroyed, pvm.VM.ID)
+	}
+	if st := p.GetStats()["python3.11"]; st.TotalVMs != 0 {
+		t.Errorf("stats = %+v, want empty pool", st)
+	}
+}
+
+func TestWarmVMMemoryReclaimedWhileIdle(t *testing.T) {
+	p, backend := newTestPool(2, 100)
+	ctx := context.Background()
+
+	pvm, _, _ := p.AcquireVM(ctx, "python3.11")
+	if backend.isReclaimed(pvm.VM.ID) {
+		t.Fatal("busy VM should keep its full memory")
+	}
+
+	// 回到预热池时充气回收内存
+	p.ReleaseVM("python3.11", pvm.VM.ID)
+	if !backend.isReclaimed(pvm.VM.ID) {
+		t.Error("idle warm VM memory was not reclaimed")
+	}
+
+	// 复用前放气
+	reused, _, _ := p.AcquireVM(ctx, "python3.11")
+	if reused.VM.ID != pvm.VM.ID {
+		t.Fatalf("acquired %s, want reused %s", reused.VM.ID, pvm.VM.ID)
+	}
+	if backend.isReclaimed(reused.VM.ID) {
+		t.Error("reused VM memory was not restored before handing it out")
+	}
+}
+
+func TestApplyIOLimitsOverridesRuntimeDefaults(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.PoolConfig{
+		MaxVMAge:       time.Hour,
+		MaxInvocations: 100,
+		Runtimes: []config.RuntimeConfig{
+			{Runtime: "python3.11", MaxTotal: 1, MemoryMB: 128, VCPUs: 1, DiskMBps: 50, NetMBps: 10, NetPPS: 1000},
+		},
+	}
+	backend := &fakeBackend{}
+	p := NewPool(cfg, nil, nil, nil, logger)
+	p.backend = backend
+	ctx := context.Background()
+
+	pvm, _, err := p.AcquireVM(ctx, "python3.11")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defaults := fc.RateLimits{DiskMBps: 50, NetMBps: 10, NetPPS: 1000}
+	if pvm.VM.Limits != defaults {
+		t.Fatalf("created VM limits = %+v, want runtime defaults %+v", pvm.VM.Limits, defaults)
+	}
+
+	// 未设置限速的函数沿用运行时默认值，不需要调整
+	if err := p.ApplyIOLimits(ctx, pvm, nil); err != nil {
+		t.Fatalf("apply default limits: %v", err)
+	}
+	if backend.limitUpdate != 0 {
+		t.Errorf("limit updates = %d, want 0 for unchanged limits", backend.limitUpdate)
+	}
+
+	// 函数设置的项覆盖默认值，未设置的项保留默认值
+	if err := p.ApplyIOLimits(ctx, pvm, &domain.IOLimits{DiskMBps: 5, DiskIOPS: 200}); err != nil {
+		t.Fatalf("apply function limits: %v", err)
+	}
+	want := fc.RateLimits{DiskMBps: 5, DiskIOPS: 200, NetMBps: 10, NetPPS: 1000}
+	if pvm.VM.Limits != want {
+		t.Errorf("limits = %+v, want %+v", pvm.VM.Limits, want)
+	}
+	if backend.limitUpdate != 1 {
+		t.Errorf("limit updates = %d, want 1", backend.limitUpdate)
+	}
+}
+
+func TestColdStartSpanWrapsVMCreation(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(prev)
+
+	p, backend := newTestPool(2, 100)
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "function.invoke")
+	pvm, _, err := p.AcquireVM(ctx, "python3.11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ReleaseVM("python3.11", pvm.VM.ID)
+	// 复用预热虚拟机不是冷启动，不应产生新的 cold_start span
+	if _, cold, err := p.AcquireVM(ctx, "python3.11"); err != nil || cold {
+		t.Fatalf("second acquire: cold=%v err=%v, want warm VM", cold, err)
+	}
+	parent.End()
+
+	var coldSpans []sdktrace.ReadOnlySpan
+	for _, span := range sr.Ended() {
+		if span.Name() == "cold_start" {
+			coldSpans = append(coldSpans, span)
+		}
+	}
+	if len(coldSpans) != 1 {
+		t.Fatalf("recorded %d cold_start spans, want 1", len(coldSpans))
+	}
+	span := coldSpans[0]
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("cold_start parent = %s, want %s", span.Parent().SpanID(), parent.SpanContext().SpanID())
+	}
+	// 创建虚拟机时的上下文应携带 cold_start span，使后续 vsock 调用挂在其下
+	if backend.createSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("create ran under span %s, want cold_start %s", backend.createSpan.SpanID(), span.SpanContext().SpanID())
+	}
+}
+
+func TestColdStartRestoresFromSnapshot(t *testing.T) {
+	p, backend := newTestPool(3, 100)
+	ctx := context.Background()
+
+	restores := 0
+	restore := func(ctx context.Context) (*PooledVM, error) {
+		restores++
+		return &PooledVM{
+			VM:        &fc.VM{ID: "restored-1", Runtime: "python3.11", RestoreDuration: time.Millisecond},
+			Runtime:   "python3.11",
+			Status:    "cold",
+			CreatedAt: time.Now(),
+		}, nil
+	}
+	pvm, cold, err := p.acquireVM(ctx, "python3.11", "", restore)
+	if err != nil || !cold || pvm.VM.ID != "restored-1" {
+		t.Fatalf("acquire: vm=%+v cold=%v err=%v, want the restored VM as a cold start", pvm, cold, err)
+	}
+	if backend.created != 0 {
+		t.Errorf("created %d VMs, want none when the snapshot restore succeeds", backend.created)
+	}
+
+	// 恢复出的虚拟机登记在池中，释放后像其他虚拟机一样被复用，不再恢复
+	if err := p.ReleaseVM("python3.11", pvm.VM.ID); err != nil {
+		t.Fatalf("release restored VM: %v", err)
+	}
+	again, cold, err := p.acquireVM(ctx, "python3.11", "", restore)
+	if err != nil || cold || again.VM.ID != "restored-1" || restores != 1 {
+		t.Fatalf("reacquire: vm=%s cold=%v err=%v restores=%d, want the warm restored VM", again.VM.ID, cold, err, restores)
+	}
+
+	// 没有快照或恢复失败时回退到新建虚拟机
+	for _, restoreErr := range []error{snapshot.ErrSnapshotNotFound, errors.New("load snapshot: bad state")} {
+		failing := func(ctx context.Context) (*PooledVM, error) { return nil, restoreErr }
+		created, cold, err := p.acquireVM(ctx, "python3.11", "", failing)
+		if err != nil || !cold || created.VM.ID == "restored-1" {
+			t.Fatalf("acquire after %v: vm=%+v cold=%v err=%v, want a newly booted VM", restoreErr, created, cold, err)
+		}
+	}
+	if backend.created != 2 {
+		t.Errorf("created %d VMs, want 2 after the restore fallbacks", backend.created)
+	}
+}
+
+func TestAcquireVMForPrefersInitializedVM(t *testing.T) {
+	p, _ := newTestPool(3, 100)
+	ctx := context.Background()
+
+	// 三个虚拟机回到预热队列，只有中间的一个已按 key-b 初始化
+	var vms []*PooledVM
+	for _, key := range []string{"key-a", "key-b", ""} {
+		pvm, _, _ := p.AcquireVM(ctx, "python3.11")
+		pvm.InitKey = key
+		vms = append(vms, pvm)
+	}
+	for _, pvm := range vms {
+		p.ReleaseVM("python3.11", pvm.VM.ID)
+	}
+
+	got, cold, err := p.AcquireVMFor(ctx, "python3.11", "key-b")
+	if err != nil || cold {
+		t.Fatalf("acquire: cold=%v err=%v, want warm VM", cold, err)
+	}
+	if got.VM.ID != vms[1].VM.ID {
+		t.Errorf("acquired %s (init key %q), want %s initialized with key-b", got.VM.ID, got.InitKey, vms[1].VM.ID)
+	}
+
+	// 没有匹配的虚拟机时取其他预热虚拟机，跳过的虚拟机仍在队列中
+	other, cold, err := p.AcquireVMFor(ctx, "python3.11", "key-c")
+	if err != nil || cold || other.InitKey == "key-b" {
+		t.Fatalf("acquire without match: vm=%+v cold=%v err=%v, want another warm VM", other, cold, err)
+	}
+	if st := p.GetStats()["python3.11"]; st.TotalVMs != 3 || st.BusyVMs != 2 || st.WarmVMs != 1 || len(p.pools["python3.11"].warmVMs) != 1 {
+		t.Errorf("stats = %+v, want the skipped VM back in the warm queue", st)
+	}
+}
+
+func TestPrewarmFunctionQueuesVMOnlyAfterInit(t *testing.T) {
+	p, _ := newTestPool(2, 100)
+	pool := p.pools["python3.11"]
+	release := make(chan struct{})
+	started := make(chan string, 1)
+
+	n, err := p.PrewarmFunction("python3.11", 1, func(ctx context.Context, pvm *PooledVM) (string, error) {
+		started <- pvm.VM.ID
+		<-release
+		return "key-a", nil
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("PrewarmFunction = %d, %v, want 1 VM", n, err)
+	}
+
+	// 初始化（预热函数）完成前虚拟机不进入预热队列，也不计为预热
+	vmID := <-started
+	if st := p.GetStats()["python3.11"]; st.TotalVMs != 1 || st.WarmVMs != 0 || len(pool.warmVMs) != 0 {
+		t.Errorf("stats during init = %+v, want VM registered but not warm", st)
+	}
+
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(pool.warmVMs) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("VM never entered the warm queue after init")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	got, cold, err := p.AcquireVMFor(context.Background(), "python3.11", "key-a")
+	if err != nil || cold || got.VM.ID != vmID || got.InitKey != "key-a" {
+		t.Errorf("acquire = %+v cold=%v err=%v, want initialized warm VM %s", got, cold, err, vmID)
+	}
+}
+
+func TestPrewarmFunctionDestroysVMWhenInitFails(t *testing.T) {
+	p, backend := newTestPool(2, 100)
+	pool := p.pools["python3.11"]
+
+	if _, err := p.createWarmVM("python3.11", func(ctx context.Context, pvm *PooledVM) (string, error) {
+		return "", errors.New("warmup timed out")
+	}); err == nil {
+		t.Fatal("createWarmVM succeeded, want init error")
+	}
+	if len(backend.destroyed) != 1 || len(pool.warmVMs) != 0 {
+		t.Errorf("destroyed %v, warm queue %d, want the VM destroyed and not queued", backend.destroyed, len(pool.warmVMs))
+	}
+	if st := p.GetStats()["python3.11"]; st.TotalVMs != 0 {
+		t.Errorf("stats = %+v, want no VMs left", st)
+	}
+}
+
+func TestRemoveReapedVM(t *testing.T) {
+	p, backend := newTestPool(3, 100)
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		pvm, _, err := p.AcquireVM(ctx, "python3.11")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, pvm.VM.ID)
+	}
+	for _, id := range ids {
+		if err := p.ReleaseVM("python3.11", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// MachineManager 回收了第一个虚拟机：池中不再计入，也不会从预热队列交付
+	p.removeVM(ids[0], "python3.11")
+	if st := p.GetStats()["python3.11"]; st.TotalVMs != 1 || st.WarmVMs != 1 {
+		t.Errorf("stats after removing reaped VM = %+v, want 1 warm VM", st)
+	}
+	pvm, cold, err := p.AcquireVM(ctx, "python3.11")
+	if err != nil || cold || pvm.VM.ID != ids[1] {
+		t.Errorf("acquire after reap = %v cold=%v err=%v, want warm %s", pvm, cold, err, ids[1])
+	}
+	if len(backend.destroyed) != 0 {
+		t.Errorf("destroyed = %v, want the reaped VM left to MachineManager", backend.destroyed)
+	}
+
+	// 未知虚拟机和运行时不报错
+	p.removeVM("missing", "python3.11")
+	p.removeVM(ids[1], "nodejs20")
+}
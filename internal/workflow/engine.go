@@ -11,7 +11,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/oriys/nimbus/internal/domain"
-	"github.com/oriys/nimbus/internal/storage"
 	"github.com/sirupsen/logrus"
 )
 
@@ -31,7 +30,7 @@ type Config struct {
 	DefaultTimeout int
 	// RecoveryEnabled 是否启用执行恢复
 	RecoveryEnabled bool
-	// RecoveryInterval 恢复检查间隔
+	// RecoveryInterval 恢复检查间隔，也决定 Wait 状态到期后多久被唤醒
 	RecoveryInterval time.Duration
 }
 
@@ -48,16 +47,19 @@ func DefaultConfig() Config {
 
 // executionTask 执行任务
 type executionTask struct {
-	execution   *domain.WorkflowExecution
-	workflow    *domain.Workflow
+	execution *domain.WorkflowExecution
+	workflow  *domain.Workflow
+	// resumeState 非空时从断点恢复：从该状态继续执行，且不再在该状态的断点处暂停
 	resumeState string
 	resumeInput json.RawMessage
+	// waitElapsed 为 true 时 resumeState 是已到唤醒时间的 Wait 状态，恢复后不再等待
+	waitElapsed bool
 }
 
 // Engine 工作流引擎
 type Engine struct {
 	config    Config
-	store     *storage.PostgresStore
+	store     Store
 	scheduler Scheduler
 	logger    *logrus.Logger
 
@@ -70,10 +72,13 @@ type Engine struct {
 
 	// 执行器
 	executor *Executor
+
+	// now 返回当前时间，用于超时和 Wait 状态唤醒判断，测试中可替换
+	now func() time.Time
 }
 
 // NewEngine 创建工作流引擎实例
-func NewEngine(config Config, store *storage.PostgresStore, scheduler Scheduler, logger *logrus.Logger) *Engine {
+func NewEngine(config Config, store Store, scheduler Scheduler, logger *logrus.Logger) *Engine {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	if config.Workers <= 0 {
@@ -95,6 +100,7 @@ func NewEngine(config Config, store *storage.PostgresStore, scheduler Scheduler,
 		workers:        config.Workers,
 		ctx:            ctx,
 		cancel:         cancel,
+		now:            time.Now,
 	}
 
 	// 创建执行器
@@ -195,6 +201,7 @@ func (e *Engine) recoveryLoop() {
 }
 
 // recoverPendingExecutions 恢复待处理的执行
+// 处于 waiting 状态的执行在到达唤醒时间后从其 Wait 状态继续，未到时间的继续等待
 func (e *Engine) recoverPendingExecutions() {
 	executions, err := e.store.ListPendingExecutions(100)
 	if err != nil {
@@ -204,11 +211,19 @@ func (e *Engine) recoverPendingExecutions() {
 
 	for _, exec := range executions {
 		// 检查是否超时
-		if exec.TimeoutAt != nil && time.Now().After(*exec.TimeoutAt) {
+		if exec.TimeoutAt != nil && e.now().After(*exec.TimeoutAt) {
 			e.markExecutionTimeout(exec)
 			continue
 		}
 
+		if exec.Status == domain.ExecutionStatusWaiting {
+			if exec.WaitUntil != nil && e.now().Before(*exec.WaitUntil) {
+				continue
+			}
+			e.wakeExecution(exec)
+			continue
+		}
+
 		// 获取工作流定义
 		workflow, err := e.store.GetWorkflowByID(exec.WorkflowID)
 		if err != nil {
@@ -262,7 +277,7 @@ func (e *Engine) StartExecution(workflowID string, input json.RawMessage) (*doma
 	if timeout <= 0 {
 		timeout = e.config.DefaultTimeout
 	}
-	timeoutAt := time.Now().Add(time.Duration(timeout) * time.Second)
+	timeoutAt := e.now().Add(time.Duration(timeout) * time.Second)
 
 	// 序列化工作流定义快照
 	definitionJSON, err := json.Marshal(workflow.Definition)
@@ -348,7 +363,20 @@ func (e *Engine) executeWorkflowTask(task *executionTask) {
 		"workflow":     workflow.Name,
 	})
 
-	// 判断是否从暂停状态恢复
+	// Wait 状态唤醒前确认执行仍在等待，避免重复唤醒或唤醒已取消的执行
+	if task.waitElapsed {
+		latest, err := e.store.GetExecutionByID(exec.ID)
+		if err != nil {
+			log.WithError(err).Error("Failed to reload waiting execution")
+			return
+		}
+		if latest.Status != domain.ExecutionStatusWaiting {
+			log.WithField("status", latest.Status).Debug("Execution is no longer waiting, skipping wake-up")
+			return
+		}
+	}
+
+	// 判断是否从暂停状态或 Wait 状态恢复
 	isResume := task.resumeState != ""
 
 	if isResume {
@@ -358,6 +386,8 @@ func (e *Engine) executeWorkflowTask(task *executionTask) {
 		exec.PausedAtState = ""
 		exec.PausedInput = nil
 		exec.PausedAt = nil
+		exec.WaitUntil = nil
+		exec.WaitInput = nil
 		exec.CurrentState = task.resumeState
 		if err := e.store.UpdateExecution(exec); err != nil {
 			log.WithError(err).Error("Failed to update execution status on resume")
@@ -382,9 +412,18 @@ func (e *Engine) executeWorkflowTask(task *executionTask) {
 	var currentState string
 	var currentInput json.RawMessage
 
+	// skipBreakpoint 是刚恢复的断点所在状态，第一次进入时不再暂停
+	var skipBreakpoint string
+	// waitElapsedState 是已到唤醒时间的 Wait 状态，第一次进入时不再等待
+	var waitElapsedState string
+
 	if isResume {
 		currentState = task.resumeState
 		currentInput = task.resumeInput
+		skipBreakpoint = task.resumeState
+		if task.waitElapsed {
+			waitElapsedState = task.resumeState
+		}
 	} else {
 		currentState = workflow.Definition.StartAt
 		currentInput = exec.Input
@@ -409,13 +448,15 @@ func (e *Engine) executeWorkflowTask(task *executionTask) {
 		}
 
 		// 检查超时
-		if exec.TimeoutAt != nil && time.Now().After(*exec.TimeoutAt) {
+		if exec.TimeoutAt != nil && e.now().After(*exec.TimeoutAt) {
 			e.completeExecution(exec, nil, domain.ErrorTypeTimeout, "execution timed out", domain.ExecutionStatusTimeout)
 			return
 		}
 
-		// 检查断点（在进入状态之前）
-		if bp, _ := e.store.GetBreakpoint(exec.ID, currentState); bp != nil && bp.Enabled {
+		// 检查断点（在进入状态之前），已禁用的断点不会暂停
+		if currentState == skipBreakpoint {
+			skipBreakpoint = ""
+		} else if bp, _ := e.store.GetBreakpoint(exec.ID, currentState); bp != nil && bp.Enabled {
 			log.WithField("state", currentState).Info("Breakpoint hit, pausing execution")
 			e.pauseExecution(exec, currentState, currentInput)
 			return // Worker 退出，等待通过 API 恢复
@@ -428,6 +469,18 @@ func (e *Engine) executeWorkflowTask(task *executionTask) {
 			return
 		}
 
+		// Wait 状态不占用 worker 等待：记录唤醒时间后让出，由恢复循环到期唤醒，引擎重启也不会丢失
+		if state.Type == domain.StateTypeWait {
+			if currentState == waitElapsedState {
+				waitElapsedState = ""
+				state.Seconds = 0
+				state.Timestamp = ""
+			} else if wakeAt, err := waitDeadline(&state, e.now()); err == nil && wakeAt.After(e.now()) {
+				e.waitExecution(exec, currentState, currentInput, wakeAt)
+				return
+			}
+		}
+
 		// 更新当前状态
 		exec.CurrentState = currentState
 		e.store.UpdateExecution(exec)
@@ -448,12 +501,8 @@ func (e *Engine) executeWorkflowTask(task *executionTask) {
 					"error_code":  result.ErrorCode,
 				}).Info("Error caught, transitioning to catch state")
 				currentState = result.CaughtByState
-				// 构造错误信息作为下一个状态的输入
-				errorData := map[string]interface{}{
-					"Error": result.ErrorCode,
-					"Cause": result.Error.Error(),
-				}
-				currentInput, _ = json.Marshal(errorData)
+				// 执行器已按 Catch 的 ResultPath 构造好下一个状态的输入
+				currentInput = result.Output
 				continue
 			}
 
@@ -524,8 +573,73 @@ func (e *Engine) pauseExecution(exec *domain.WorkflowExecution, state string, in
 	}).Info("Workflow execution paused at breakpoint")
 }
 
-// ResumeExecution 恢复暂停的执行
-func (e *Engine) ResumeExecution(executionID string, modifiedInput json.RawMessage) error {
+// waitExecution 让执行在 Wait 状态等待到 wakeAt（Wait 状态到达时调用）
+func (e *Engine) waitExecution(exec *domain.WorkflowExecution, state string, input json.RawMessage, wakeAt time.Time) {
+	exec.Status = domain.ExecutionStatusWaiting
+	exec.CurrentState = state
+	exec.WaitUntil = &wakeAt
+	exec.WaitInput = input
+
+	if err := e.store.UpdateExecution(exec); err != nil {
+		e.logger.WithError(err).WithField("execution_id", exec.ID).Error("Failed to park waiting execution")
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"execution_id": exec.ID,
+		"state":        state,
+		"wait_until":   wakeAt,
+	}).Info("Workflow execution waiting")
+}
+
+// wakeExecution 将已到唤醒时间的执行重新入队，从其 Wait 状态继续
+func (e *Engine) wakeExecution(exec *domain.WorkflowExecution) {
+	log := e.logger.WithFields(logrus.Fields{
+		"execution_id": exec.ID,
+		"state":        exec.CurrentState,
+	})
+
+	workflow, err := e.executionWorkflow(exec)
+	if err != nil {
+		log.WithError(err).Error("Failed to get workflow for waiting execution")
+		return
+	}
+
+	input := exec.WaitInput
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+
+	select {
+	case e.executionQueue <- &executionTask{execution: exec, workflow: workflow, resumeState: exec.CurrentState, resumeInput: input, waitElapsed: true}:
+		log.Info("Waking waiting execution")
+	default:
+		log.Warn("Execution queue full, waking execution on next recovery")
+	}
+}
+
+// executionWorkflow 返回执行使用的工作流，定义优先使用执行开始时保存的快照
+func (e *Engine) executionWorkflow(exec *domain.WorkflowExecution) (*domain.Workflow, error) {
+	workflow, err := e.store.GetWorkflowByID(exec.WorkflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow: %w", err)
+	}
+	if len(exec.WorkflowDefinition) > 0 {
+		var definition domain.WorkflowDefinition
+		if err := json.Unmarshal(exec.WorkflowDefinition, &definition); err != nil {
+			return nil, fmt.Errorf("failed to parse workflow definition snapshot: %w", err)
+		}
+		snapshot := *workflow
+		snapshot.Definition = definition
+		workflow = &snapshot
+	}
+	return workflow, nil
+}
+
+// ResumeExecution 恢复在断点处暂停的执行
+// 执行从 paused_at_state 继续：先执行断点所在的状态（不会再次在该断点暂停），之后的断点照常生效。
+// overrideInput 非空时替换暂停时保存的输入，用于在调试时修改状态的输入；暂停字段在恢复执行时清除。
+// 恢复使用执行开始时保存的工作流定义快照，暂停期间对工作流的修改不影响本次执行。
+func (e *Engine) ResumeExecution(executionID string, overrideInput json.RawMessage) error {
 	exec, err := e.store.GetExecutionByID(executionID)
 	if err != nil {
 		return err
@@ -539,16 +653,26 @@ func (e *Engine) ResumeExecution(executionID string, modifiedInput json.RawMessa
 		return fmt.Errorf("execution has no paused state information")
 	}
 
-	// 获取工作流定义
-	workflow, err := e.store.GetWorkflowByID(exec.WorkflowID)
+	if len(overrideInput) > 0 && !json.Valid(overrideInput) {
+		return fmt.Errorf("override input is not valid JSON")
+	}
+
+	// 获取工作流定义，优先使用执行快照
+	workflow, err := e.executionWorkflow(exec)
 	if err != nil {
-		return fmt.Errorf("failed to get workflow: %w", err)
+		return err
+	}
+	if _, ok := workflow.Definition.States[exec.PausedAtState]; !ok {
+		return fmt.Errorf("paused state %s not found in workflow definition", exec.PausedAtState)
 	}
 
 	// 确定恢复使用的输入
 	resumeInput := exec.PausedInput
-	if modifiedInput != nil {
-		resumeInput = modifiedInput
+	if len(overrideInput) > 0 {
+		resumeInput = overrideInput
+	}
+	if len(resumeInput) == 0 {
+		resumeInput = json.RawMessage("{}")
 	}
 
 	// 创建恢复任务并入队
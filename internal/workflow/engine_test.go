@@ -0,0 +1,379 @@
+package workflow
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// memStore 是 Store 的内存实现，读写都复制执行实例，模拟数据库行
+type memStore struct {
+	mu          sync.Mutex
+	workflows   map[string]*domain.Workflow
+	executions  map[string]domain.WorkflowExecution
+	states      []*domain.StateExecution
+	breakpoints map[string]*domain.Breakpoint
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		workflows:   map[string]*domain.Workflow{},
+		executions:  map[string]domain.WorkflowExecution{},
+		breakpoints: map[string]*domain.Breakpoint{},
+	}
+}
+
+func (s *memStore) GetWorkflowByID(id string) (*domain.Workflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if wf, ok := s.workflows[id]; ok {
+		return wf, nil
+	}
+	return nil, domain.ErrWorkflowNotFound
+}
+
+func (s *memStore) GetWorkflowByName(name string) (*domain.Workflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, wf := range s.workflows {
+		if wf.Name == name {
+			return wf, nil
+		}
+	}
+	return nil, domain.ErrWorkflowNotFound
+}
+
+func (s *memStore) CreateWorkflow(wf *domain.Workflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workflows[wf.ID] = wf
+	return nil
+}
+
+func (s *memStore) CreateExecution(exec *domain.WorkflowExecution) error {
+	return s.UpdateExecution(exec)
+}
+
+func (s *memStore) GetExecutionByID(id string) (*domain.WorkflowExecution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exec, ok := s.executions[id]
+	if !ok {
+		return nil, domain.ErrExecutionNotFound
+	}
+	return &exec, nil
+}
+
+func (s *memStore) UpdateExecution(exec *domain.WorkflowExecution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executions[exec.ID] = *exec
+	return nil
+}
+
+func (s *memStore) ListPendingExecutions(limit int) ([]*domain.WorkflowExecution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*domain.WorkflowExecution
+	for _, exec := range s.executions {
+		switch exec.Status {
+		case domain.ExecutionStatusPending, domain.ExecutionStatusRunning, domain.ExecutionStatusWaiting:
+			exec := exec
+			out = append(out, &exec)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) CreateStateExecution(stateExec *domain.StateExecution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = append(s.states, stateExec)
+	return nil
+}
+
+func (s *memStore) UpdateStateExecution(stateExec *domain.StateExecution) error { return nil }
+
+func (s *memStore) ListStateExecutions(executionID string) ([]*domain.StateExecution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*domain.StateExecution
+	for _, st := range s.states {
+		if st.ExecutionID == executionID {
+			out = append(out, st)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) GetBreakpoint(executionID, beforeState string) (*domain.Breakpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.breakpoints[executionID+"/"+beforeState], nil
+}
+
+func (s *memStore) setBreakpoint(executionID, beforeState string, enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakpoints[executionID+"/"+beforeState] = &domain.Breakpoint{ExecutionID: executionID, BeforeState: beforeState, Enabled: enabled}
+}
+
+func (s *memStore) GetFunctionByName(name string) (*domain.Function, error) {
+	return nil, domain.ErrFunctionNotFound
+}
+
+func (s *memStore) CreateFunction(fn *domain.Function) error { return nil }
+
+// echoScheduler 把调用输入原样作为函数输出，并记录每次调用的输入
+type echoScheduler struct {
+	mu       sync.Mutex
+	payloads []string
+}
+
+func (s *echoScheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payloads = append(s.payloads, string(req.Payload))
+	return &domain.InvokeResponse{RequestID: "req", StatusCode: 200, Body: req.Payload}, nil
+}
+
+func (s *echoScheduler) InvokeAsync(req *domain.InvokeRequest) (string, error) {
+	return "", nil
+}
+
+// runQueued 在当前 goroutine 中执行一个已入队的任务，代替 worker
+func runQueued(t *testing.T, e *Engine) {
+	t.Helper()
+	select {
+	case task := <-e.executionQueue:
+		e.executeWorkflowTask(task)
+	default:
+		t.Fatal("no execution task queued")
+	}
+}
+
+func TestResumeExecutionFromBreakpoint(t *testing.T) {
+	store := newMemStore()
+	sched := &echoScheduler{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	engine := NewEngine(Config{Workers: 1, RecoveryEnabled: false}, store, sched, logger)
+
+	store.CreateWorkflow(&domain.Workflow{
+		ID:     "wf-1",
+		Name:   "debug-me",
+		Status: domain.WorkflowStatusActive,
+		Definition: domain.WorkflowDefinition{
+			StartAt: "Prepare",
+			States: map[string]domain.State{
+				"Prepare": {Type: domain.StateTypePass, Result: json.RawMessage(`{"name":"prepared"}`), Next: "Invoke"},
+				"Invoke":  {Type: domain.StateTypeTask, FunctionID: "fn-echo", Next: "Finish"},
+				"Finish":  {Type: domain.StateTypeSucceed},
+			},
+		},
+	})
+
+	exec, err := engine.StartExecution("wf-1", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("StartExecution() error = %v", err)
+	}
+	store.setBreakpoint(exec.ID, "Invoke", true)
+	store.setBreakpoint(exec.ID, "Finish", false) // 已禁用的断点不暂停
+	runQueued(t, engine)
+
+	// 在进入 Invoke 之前暂停，函数尚未调用
+	paused, _ := store.GetExecutionByID(exec.ID)
+	if paused.Status != domain.ExecutionStatusPaused || paused.PausedAtState != "Invoke" || paused.CurrentState != "Invoke" {
+		t.Fatalf("execution = status %s, paused at %q, current %q; want paused at Invoke", paused.Status, paused.PausedAtState, paused.CurrentState)
+	}
+	if string(paused.PausedInput) != `{"name":"prepared"}` {
+		t.Errorf("paused input = %s, want Prepare's output", paused.PausedInput)
+	}
+	if len(sched.payloads) != 0 {
+		t.Fatalf("function invoked %d times before resume", len(sched.payloads))
+	}
+
+	if err := engine.ResumeExecution(exec.ID, json.RawMessage(`{"name`)); err == nil {
+		t.Error("ResumeExecution() with invalid override input succeeded")
+	}
+	if err := engine.ResumeExecution(exec.ID, json.RawMessage(`{"name":"debug"}`)); err != nil {
+		t.Fatalf("ResumeExecution() error = %v", err)
+	}
+	runQueued(t, engine)
+
+	done, _ := store.GetExecutionByID(exec.ID)
+	if done.Status != domain.ExecutionStatusSucceeded {
+		t.Fatalf("execution status after resume = %s (%s), want succeeded", done.Status, done.Error)
+	}
+	if done.PausedAtState != "" || done.PausedInput != nil || done.PausedAt != nil {
+		t.Errorf("paused fields not cleared: %q %s %v", done.PausedAtState, done.PausedInput, done.PausedAt)
+	}
+	if len(sched.payloads) != 1 || sched.payloads[0] != `{"name":"debug"}` {
+		t.Errorf("function payloads = %v, want the override input once", sched.payloads)
+	}
+	if string(done.Output) != `{"name":"debug"}` {
+		t.Errorf("execution output = %s", done.Output)
+	}
+
+	history, _ := store.ListStateExecutions(exec.ID)
+	var names []string
+	for _, st := range history {
+		names = append(names, st.StateName)
+	}
+	if len(names) != 3 || names[0] != "Prepare" || names[1] != "Invoke" || names[2] != "Finish" {
+		t.Errorf("state history = %v, want each state once", names)
+	}
+
+	if err := engine.ResumeExecution(exec.ID, nil); err == nil {
+		t.Error("ResumeExecution() of a finished execution succeeded")
+	}
+}
+
+func TestCatchRoutesToFallbackState(t *testing.T) {
+	store := newMemStore()
+	sched := &flakyScheduler{failures: 10}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	engine := NewEngine(Config{Workers: 1, RecoveryEnabled: false}, store, sched, logger)
+
+	store.CreateWorkflow(&domain.Workflow{
+		ID:     "wf-1",
+		Name:   "with-fallback",
+		Status: domain.WorkflowStatusActive,
+		Definition: domain.WorkflowDefinition{
+			StartAt: "Call",
+			States: map[string]domain.State{
+				"Call": {
+					Type:       domain.StateTypeTask,
+					FunctionID: "fn-flaky",
+					Retry:      &domain.RetryPolicy{ErrorEquals: []string{domain.ErrorTypeTaskFailed}, MaxAttempts: 1},
+					Catch:      []domain.CatchConfig{{ErrorEquals: []string{domain.ErrorTypeAllErrors}, Next: "Fallback", ResultPath: "$.error"}},
+					Next:       "Finish",
+				},
+				"Fallback": {Type: domain.StateTypePass, End: true},
+				"Finish":   {Type: domain.StateTypeSucceed},
+			},
+		},
+	})
+
+	exec, err := engine.StartExecution("wf-1", json.RawMessage(`{"order":7}`))
+	if err != nil {
+		t.Fatalf("StartExecution() error = %v", err)
+	}
+	runQueued(t, engine)
+
+	done, _ := store.GetExecutionByID(exec.ID)
+	if done.Status != domain.ExecutionStatusSucceeded {
+		t.Fatalf("execution status = %s (%s), want succeeded via fallback", done.Status, done.Error)
+	}
+	var output struct {
+		Order int `json:"order"`
+		Error struct {
+			Error string `json:"Error"`
+			Cause string `json:"Cause"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(done.Output, &output); err != nil {
+		t.Fatalf("execution output %s: %v", done.Output, err)
+	}
+	if output.Order != 7 || output.Error.Error != domain.ErrorTypeTaskFailed || output.Error.Cause == "" {
+		t.Errorf("fallback output = %s, want original input with error at $.error", done.Output)
+	}
+
+	history, _ := store.ListStateExecutions(exec.ID)
+	if len(history) != 2 || history[0].StateName != "Call" || history[1].StateName != "Fallback" {
+		t.Fatalf("state history = %d entries", len(history))
+	}
+	if call := history[0]; call.Status != domain.StateExecutionStatusCaught || call.RetryCount != 1 || call.ErrorCode != domain.ErrorTypeTaskFailed {
+		t.Errorf("Call state execution = status %s, retry_count %d, error_code %q", call.Status, call.RetryCount, call.ErrorCode)
+	}
+	if sched.calls != 2 {
+		t.Errorf("calls = %d, want 1 attempt + 1 retry", sched.calls)
+	}
+}
+
+func TestWaitStateParksAndWakesOnSchedule(t *testing.T) {
+	store := newMemStore()
+	sched := &echoScheduler{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	clock := time.Now()
+	engine := NewEngine(Config{Workers: 1, RecoveryEnabled: false}, store, sched, logger)
+	engine.now = func() time.Time { return clock }
+
+	store.CreateWorkflow(&domain.Workflow{
+		ID:     "wf-1",
+		Name:   "delayed",
+		Status: domain.WorkflowStatusActive,
+		Definition: domain.WorkflowDefinition{
+			StartAt: "Prepare",
+			States: map[string]domain.State{
+				"Prepare": {Type: domain.StateTypePass, Result: json.RawMessage(`{"name":"later"}`), Next: "Delay"},
+				"Delay":   {Type: domain.StateTypeWait, Seconds: 600, Next: "Invoke"},
+				"Invoke":  {Type: domain.StateTypeTask, FunctionID: "fn-echo", End: true},
+			},
+		},
+	})
+
+	exec, err := engine.StartExecution("wf-1", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("StartExecution() error = %v", err)
+	}
+	runQueued(t, engine)
+
+	// 到达 Wait 状态后记录唤醒时间并让出 worker
+	waiting, _ := store.GetExecutionByID(exec.ID)
+	if waiting.Status != domain.ExecutionStatusWaiting || waiting.CurrentState != "Delay" {
+		t.Fatalf("execution = status %s at %q, want waiting at Delay", waiting.Status, waiting.CurrentState)
+	}
+	if waiting.WaitUntil == nil || !waiting.WaitUntil.Equal(clock.Add(600*time.Second)) {
+		t.Fatalf("wait_until = %v, want start + 600s", waiting.WaitUntil)
+	}
+	if string(waiting.WaitInput) != `{"name":"later"}` {
+		t.Errorf("wait_input = %s", waiting.WaitInput)
+	}
+
+	clock = clock.Add(599 * time.Second)
+	engine.recoverPendingExecutions()
+	if len(engine.executionQueue) != 0 {
+		t.Fatal("execution woken before its wait time")
+	}
+
+	// 模拟重启：新的引擎只依赖存储中的唤醒时间
+	restarted := NewEngine(Config{Workers: 1, RecoveryEnabled: false}, store, sched, logger)
+	restarted.now = func() time.Time { return clock.Add(time.Second) }
+	restarted.recoverPendingExecutions()
+	runQueued(t, restarted)
+
+	done, _ := store.GetExecutionByID(exec.ID)
+	if done.Status != domain.ExecutionStatusSucceeded {
+		t.Fatalf("execution status after wake-up = %s (%s), want succeeded", done.Status, done.Error)
+	}
+	if done.WaitUntil != nil || done.WaitInput != nil {
+		t.Errorf("wait fields not cleared: %v %s", done.WaitUntil, done.WaitInput)
+	}
+	if len(sched.payloads) != 1 || sched.payloads[0] != `{"name":"later"}` {
+		t.Errorf("function payloads = %v", sched.payloads)
+	}
+
+	history, _ := store.ListStateExecutions(exec.ID)
+	var names []string
+	for _, st := range history {
+		names = append(names, st.StateName)
+	}
+	if strings.Join(names, ",") != "Prepare,Delay,Invoke" {
+		t.Errorf("state history = %v", names)
+	}
+
+	// 已唤醒的执行不会被再次唤醒
+	restarted.recoverPendingExecutions()
+	if len(restarted.executionQueue) != 0 {
+		t.Error("finished execution queued again")
+	}
+}
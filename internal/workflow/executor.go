@@ -5,18 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/oriys/nimbus/internal/domain"
-	"github.com/oriys/nimbus/internal/storage"
 	"github.com/sirupsen/logrus"
 )
 
 // Executor 状态执行器
 type Executor struct {
-	store     *storage.PostgresStore
+	store     Store
 	scheduler Scheduler
 	logger    *logrus.Logger
 	evaluator *Evaluator
@@ -24,7 +24,7 @@ type Executor struct {
 }
 
 // NewExecutor 创建执行器实例
-func NewExecutor(store *storage.PostgresStore, scheduler Scheduler, logger *logrus.Logger) *Executor {
+func NewExecutor(store Store, scheduler Scheduler, logger *logrus.Logger) *Executor {
 	return &Executor{
 		store:     store,
 		scheduler: scheduler,
@@ -73,7 +73,13 @@ func (e *Executor) ExecuteState(ctx context.Context, exec *domain.WorkflowExecut
 	case domain.StateTypeWait:
 		result = e.executeWaitState(ctx, state, processedInput)
 	case domain.StateTypeParallel:
-		result = e.executeParallelState(ctx, exec, stateName, state, processedInput)
+		result = e.retryState(ctx, state, stateExec, func() *domain.StateResult {
+			return e.executeParallelState(ctx, exec, stateName, state, processedInput)
+		})
+	case domain.StateTypeMap:
+		result = e.retryState(ctx, state, stateExec, func() *domain.StateResult {
+			return e.executeMapState(ctx, exec, stateName, state, processedInput)
+		})
 	case domain.StateTypePass:
 		result = e.executePassState(state, processedInput)
 	case domain.StateTypeFail:
@@ -98,6 +104,11 @@ func (e *Executor) ExecuteState(ctx context.Context, exec *domain.WorkflowExecut
 		}
 	}
 
+	// 被 Catch 捕获时，输出即 Catch 目标状态的输入
+	if result.Error != nil && result.CaughtByState != "" {
+		result.Output = e.catchOutput(state, input, result)
+	}
+
 	// 完成状态执行记录
 	if result.Error != nil {
 		e.completeStateExecution(stateExec, result.Output, result.ErrorCode, result.Error, result.CaughtByState)
@@ -129,26 +140,8 @@ func (e *Executor) executeTaskState(ctx context.Context, exec *domain.WorkflowEx
 
 	for attempt := 0; attempt < maxAttempts; attempt++ {
 		if attempt > 0 {
-			// 计算重试间隔
-			interval := e.calculateRetryInterval(retryPolicy, attempt)
-			log.WithFields(logrus.Fields{
-				"attempt":  attempt,
-				"interval": interval,
-			}).Info("Retrying task")
-
-			// 更新状态执行记录
-			stateExec.RetryCount = attempt
-			stateExec.Status = domain.StateExecutionStatusRetrying
-			e.store.UpdateStateExecution(stateExec)
-
-			// 等待重试间隔
-			select {
-			case <-ctx.Done():
-				return &domain.StateResult{
-					Error:     ctx.Err(),
-					ErrorCode: domain.ErrorTypeTimeout,
-				}
-			case <-time.After(interval):
+			if result := e.waitForRetry(ctx, retryPolicy, attempt, stateExec, lastError, lastErrorCode); result != nil {
+				return result
 			}
 		}
 
@@ -156,6 +149,7 @@ func (e *Executor) executeTaskState(ctx context.Context, exec *domain.WorkflowEx
 		resp, err := e.scheduler.Invoke(&domain.InvokeRequest{
 			FunctionID: state.FunctionID,
 			Payload:    input,
+			Context:    ctx,
 		})
 
 		if err != nil {
@@ -270,26 +264,36 @@ func (e *Executor) executeChoiceState(state *domain.State, input json.RawMessage
 	}
 }
 
-// executeWaitState 执行 Wait 状态
-func (e *Executor) executeWaitState(ctx context.Context, state *domain.State, input json.RawMessage) *domain.StateResult {
-	var waitDuration time.Duration
-
+// waitDeadline 计算 Wait 状态从 now 开始等待的结束时间，未配置等待时返回 now
+func waitDeadline(state *domain.State, now time.Time) (time.Time, error) {
 	if state.Seconds > 0 {
-		waitDuration = time.Duration(state.Seconds) * time.Second
-	} else if state.Timestamp != "" {
+		return now.Add(time.Duration(state.Seconds) * time.Second), nil
+	}
+	if state.Timestamp != "" {
 		// 解析 ISO 8601 时间戳
 		t, err := time.Parse(time.RFC3339, state.Timestamp)
 		if err != nil {
-			return &domain.StateResult{
-				Error:     fmt.Errorf("invalid timestamp: %w", err),
-				ErrorCode: "States.InvalidTimestamp",
-			}
+			return now, fmt.Errorf("invalid timestamp: %w", err)
 		}
-		waitDuration = time.Until(t)
-		if waitDuration < 0 {
-			waitDuration = 0
+		return t, nil
+	}
+	return now, nil
+}
+
+// executeWaitState 执行 Wait 状态
+// 引擎在顶层自行处理 Wait 状态的定时唤醒，这里阻塞等待的只有 Parallel 分支和 Map 迭代中的 Wait 状态
+func (e *Executor) executeWaitState(ctx context.Context, state *domain.State, input json.RawMessage) *domain.StateResult {
+	deadline, err := waitDeadline(state, time.Now())
+	if err != nil {
+		return &domain.StateResult{
+			Error:     err,
+			ErrorCode: "States.InvalidTimestamp",
 		}
 	}
+	waitDuration := time.Until(deadline)
+	if waitDuration < 0 {
+		waitDuration = 0
+	}
 
 	// 等待
 	select {
@@ -395,6 +399,12 @@ func (e *Executor) executeParallelState(ctx context.Context, exec *domain.Workfl
 
 // executeBranch 执行并行分支
 func (e *Executor) executeBranch(ctx context.Context, exec *domain.WorkflowExecution, parentState string, branchIndex int, branch *domain.Branch, input json.RawMessage) *domain.ParallelBranchResult {
+	return e.runBranch(ctx, exec, fmt.Sprintf("%s.Branch[%d]", parentState, branchIndex), branchIndex, branch, input)
+}
+
+// runBranch 从分支的起始状态开始依次执行，直到终止状态或出错
+// prefix 是分支内状态执行记录名称的前缀，branchIndex 写入结果的 BranchIndex
+func (e *Executor) runBranch(ctx context.Context, exec *domain.WorkflowExecution, prefix string, branchIndex int, branch *domain.Branch, input json.RawMessage) *domain.ParallelBranchResult {
 	currentState := branch.StartAt
 	currentInput := input
 
@@ -413,13 +423,20 @@ func (e *Executor) executeBranch(ctx context.Context, exec *domain.WorkflowExecu
 		if !ok {
 			return &domain.ParallelBranchResult{
 				BranchIndex: branchIndex,
-				Error:       fmt.Errorf("state %s not found in branch %d", currentState, branchIndex),
+				Error:       fmt.Errorf("state %s not found in %s", currentState, prefix),
 				ErrorCode:   "States.InvalidState",
 			}
 		}
 
 		// 执行状态
-		result := e.ExecuteState(ctx, exec, fmt.Sprintf("%s.Branch[%d].%s", parentState, branchIndex, currentState), &state, currentInput)
+		result := e.ExecuteState(ctx, exec, prefix+"."+currentState, &state, currentInput)
+
+		// 被 Catch 捕获时在分支内转到 Catch 目标状态
+		if result.Error != nil && result.CaughtByState != "" {
+			currentState = result.CaughtByState
+			currentInput = result.Output
+			continue
+		}
 
 		if result.Error != nil {
 			return &domain.ParallelBranchResult{
@@ -442,6 +459,130 @@ func (e *Executor) executeBranch(ctx context.Context, exec *domain.WorkflowExecu
 	}
 }
 
+// defaultMapMaxConcurrency 是 Map 状态未配置 MaxConcurrency 时同时执行的迭代数
+const defaultMapMaxConcurrency = 10
+
+// executeMapState 执行 Map 状态
+// 对 ItemsPath 选出的数组的每个元素执行一次迭代分支，最多同时执行 MaxConcurrency 个，
+// 输出为与元素顺序一致的结果数组。失败的迭代数在容忍范围内时，对应位置为 {"Error": ..., "Cause": ...}；
+// 超出容忍范围时以 States.ExceedToleratedFailureThreshold 失败，错误信息汇总各个失败的迭代。
+// 每个迭代中的状态以 "<Map 状态名>.Iteration[<下标>].<状态名>" 记录状态执行。
+func (e *Executor) executeMapState(ctx context.Context, exec *domain.WorkflowExecution, stateName string, state *domain.State, input json.RawMessage) *domain.StateResult {
+	if state.Iterator == nil || state.Iterator.StartAt == "" {
+		return &domain.StateResult{
+			Error:     fmt.Errorf("map state %s requires an iterator", stateName),
+			ErrorCode: "States.InvalidState",
+		}
+	}
+
+	// 取出要遍历的数组
+	var data interface{}
+	if err := json.Unmarshal(input, &data); err != nil {
+		return &domain.StateResult{Error: fmt.Errorf("failed to unmarshal map input: %w", err), ErrorCode: domain.ErrorTypeParameterPathFailure}
+	}
+	if state.ItemsPath != "" {
+		value, err := getJSONPathValue(data, state.ItemsPath)
+		if err != nil {
+			return &domain.StateResult{Error: fmt.Errorf("failed to apply ItemsPath: %w", err), ErrorCode: domain.ErrorTypeParameterPathFailure}
+		}
+		data = value
+	}
+	items, ok := data.([]interface{})
+	if !ok {
+		return &domain.StateResult{Error: fmt.Errorf("map items must be an array, got %T", data), ErrorCode: domain.ErrorTypeParameterPathFailure}
+	}
+	if len(items) == 0 {
+		return &domain.StateResult{Output: json.RawMessage("[]"), NextState: e.getNextState(state)}
+	}
+
+	maxConcurrency := state.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultMapMaxConcurrency
+	}
+
+	// 按并发上限执行迭代，信号量在启动 goroutine 之前获取，避免一次创建所有 goroutine
+	results := make([]*domain.ParallelBranchResult, len(items))
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		itemInput, err := json.Marshal(item)
+		if err != nil {
+			results[i] = &domain.ParallelBranchResult{BranchIndex: i, Error: err, ErrorCode: domain.ErrorTypeParameterPathFailure}
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = &domain.ParallelBranchResult{BranchIndex: i, Error: ctx.Err(), ErrorCode: domain.ErrorTypeTimeout}
+			continue
+		}
+		wg.Add(1)
+		go func(index int, itemInput json.RawMessage) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[index] = e.runBranch(ctx, exec, fmt.Sprintf("%s.Iteration[%d]", stateName, index), index, state.Iterator, itemInput)
+		}(i, itemInput)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return &domain.StateResult{
+			Error:     ctx.Err(),
+			ErrorCode: domain.ErrorTypeTimeout,
+		}
+	}
+
+	// 收集结果，失败的迭代用错误对象占位
+	outputs := make([]interface{}, len(items))
+	var failures []string
+	for i, result := range results {
+		if result.Error == nil {
+			outputs[i] = result.Output
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("[%d] %s: %s", i, result.ErrorCode, result.Error.Error()))
+		outputs[i] = map[string]interface{}{
+			"Error": result.ErrorCode,
+			"Cause": result.Error.Error(),
+		}
+	}
+
+	if len(failures) > 0 && !mapFailureTolerated(state, len(failures), len(items)) {
+		err := fmt.Errorf("%d of %d map iterations failed: %s", len(failures), len(items), strings.Join(failures, "; "))
+		if catchNext := e.findCatch(state.Catch, domain.ErrorTypeExceedToleratedFailureThreshold); catchNext != "" {
+			return &domain.StateResult{
+				Error:         err,
+				ErrorCode:     domain.ErrorTypeExceedToleratedFailureThreshold,
+				CaughtByState: catchNext,
+			}
+		}
+		return &domain.StateResult{
+			Error:     err,
+			ErrorCode: domain.ErrorTypeExceedToleratedFailureThreshold,
+		}
+	}
+
+	output, err := json.Marshal(outputs)
+	if err != nil {
+		return &domain.StateResult{
+			Error:     err,
+			ErrorCode: domain.ErrorTypeBranchFailed,
+		}
+	}
+	return &domain.StateResult{
+		Output:    output,
+		NextState: e.getNextState(state),
+	}
+}
+
+// mapFailureTolerated 判断 Map 状态失败的迭代数是否在容忍范围内
+func mapFailureTolerated(state *domain.State, failed, total int) bool {
+	if failed <= state.ToleratedFailureCount {
+		return true
+	}
+	return state.ToleratedFailurePercentage > 0 && float64(failed)*100 <= state.ToleratedFailurePercentage*float64(total)
+}
+
 // executePassState 执行 Pass 状态
 func (e *Executor) executePassState(state *domain.State, input json.RawMessage) *domain.StateResult {
 	output := input
@@ -494,9 +635,10 @@ func (e *Executor) calculateRetryInterval(policy *domain.RetryPolicy, attempt in
 		return time.Second
 	}
 
+	// 第 1 次重试等待 IntervalSeconds，之后每次乘以 BackoffRate
 	interval := float64(policy.IntervalSeconds)
 	if policy.BackoffRate > 1 {
-		for i := 0; i < attempt; i++ {
+		for i := 1; i < attempt; i++ {
 			interval *= policy.BackoffRate
 		}
 	}
@@ -525,16 +667,97 @@ func (e *Executor) shouldRetry(policy *domain.RetryPolicy, errorCode string, att
 	return false
 }
 
-// findCatch 查找匹配的 Catch 配置
+// waitForRetry 记录第 attempt 次重试及上一次失败的错误，并等待重试间隔
+// 等待期间上下文被取消时返回超时结果，否则返回 nil
+func (e *Executor) waitForRetry(ctx context.Context, policy *domain.RetryPolicy, attempt int, stateExec *domain.StateExecution, lastError error, lastErrorCode string) *domain.StateResult {
+	interval := e.calculateRetryInterval(policy, attempt)
+	e.logger.WithFields(logrus.Fields{
+		"execution_id": stateExec.ExecutionID,
+		"state":        stateExec.StateName,
+		"attempt":      attempt,
+		"interval":     interval,
+		"error_code":   lastErrorCode,
+	}).Info("Retrying state")
+
+	// 更新状态执行记录
+	stateExec.RetryCount = attempt
+	stateExec.Status = domain.StateExecutionStatusRetrying
+	if lastError != nil {
+		stateExec.Error = lastError.Error()
+	}
+	stateExec.ErrorCode = lastErrorCode
+	e.store.UpdateStateExecution(stateExec)
+
+	// 等待重试间隔
+	select {
+	case <-ctx.Done():
+		return &domain.StateResult{
+			Error:     ctx.Err(),
+			ErrorCode: domain.ErrorTypeTimeout,
+		}
+	case <-time.After(interval):
+	}
+	return nil
+}
+
+// retryState 按状态的 Retry 策略重复执行 run，直到成功、错误不匹配或重试次数用尽
+// Task 状态在 executeTaskState 中逐次调用函数重试，Parallel、Map 状态通过这里整体重试
+func (e *Executor) retryState(ctx context.Context, state *domain.State, stateExec *domain.StateExecution, run func() *domain.StateResult) *domain.StateResult {
+	for attempt := 0; ; attempt++ {
+		result := run()
+		if result.Error == nil || !e.shouldRetry(state.Retry, result.ErrorCode, attempt) {
+			return result
+		}
+		if waitResult := e.waitForRetry(ctx, state.Retry, attempt+1, stateExec, result.Error, result.ErrorCode); waitResult != nil {
+			return waitResult
+		}
+	}
+}
+
+// findCatch 查找匹配的 Catch 配置，返回捕获后转到的状态
 func (e *Executor) findCatch(catches []domain.CatchConfig, errorCode string) string {
-	for _, catch := range catches {
-		for _, errType := range catch.ErrorEquals {
+	if catch := e.matchCatch(catches, errorCode); catch != nil {
+		return catch.Next
+	}
+	return ""
+}
+
+// matchCatch 返回第一个匹配错误代码的 Catch 配置，没有匹配时返回 nil
+func (e *Executor) matchCatch(catches []domain.CatchConfig, errorCode string) *domain.CatchConfig {
+	for i := range catches {
+		for _, errType := range catches[i].ErrorEquals {
 			if errType == domain.ErrorTypeAllErrors || errType == errorCode {
-				return catch.Next
+				return &catches[i]
 			}
 		}
 	}
-	return ""
+	return nil
+}
+
+// catchOutput 构造被 Catch 捕获后传给目标状态的输入
+// 错误信息为 {"Error": 错误代码, "Cause": 错误描述}；Catch 配置了 ResultPath 时合并到状态原始输入的该路径，否则直接作为输入
+func (e *Executor) catchOutput(state *domain.State, input json.RawMessage, result *domain.StateResult) json.RawMessage {
+	errorData := map[string]interface{}{
+		"Error": result.ErrorCode,
+		"Cause": result.Error.Error(),
+	}
+
+	resultPath := ""
+	if catch := e.matchCatch(state.Catch, result.ErrorCode); catch != nil {
+		resultPath = catch.ResultPath
+	}
+
+	var value interface{} = errorData
+	if resultPath != "" {
+		var data interface{}
+		if err := json.Unmarshal(input, &data); err == nil {
+			if merged, err := e.jsonpath.mergeAtPath(data, errorData, resultPath); err == nil {
+				value = merged
+			}
+		}
+	}
+	output, _ := json.Marshal(value)
+	return output
 }
 
 // completeStateExecution 完成状态执行记录（失败）
@@ -564,6 +787,9 @@ func (e *Executor) completeStateExecutionSuccess(stateExec *domain.StateExecutio
 	now := time.Now()
 	stateExec.Status = domain.StateExecutionStatusSucceeded
 	stateExec.Output = output
+	// 重试后成功时清除之前失败留下的错误
+	stateExec.Error = ""
+	stateExec.ErrorCode = ""
 	stateExec.CompletedAt = &now
 	e.store.UpdateStateExecution(stateExec)
 }
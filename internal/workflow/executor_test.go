@@ -0,0 +1,254 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oriys/nimbus/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// mapScheduler 模拟函数调用：输入 {"n": x} 返回 {"double": 2x}，"fail" 为 true 时调用失败；
+// 每次调用持续 delay，并记录同时进行的最大调用数
+type mapScheduler struct {
+	delay    time.Duration
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	calls    int
+}
+
+func (s *mapScheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeResponse, error) {
+	s.mu.Lock()
+	s.calls++
+	s.inFlight++
+	if s.inFlight > s.peak {
+		s.peak = s.inFlight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+	time.Sleep(s.delay)
+
+	var in struct {
+		N    int  `json:"n"`
+		Fail bool `json:"fail"`
+	}
+	json.Unmarshal(req.Payload, &in)
+	if in.Fail {
+		return &domain.InvokeResponse{RequestID: "req", StatusCode: 500, Error: fmt.Sprintf("item %d failed", in.N)}, nil
+	}
+	return &domain.InvokeResponse{RequestID: "req", StatusCode: 200, Body: json.RawMessage(fmt.Sprintf(`{"double":%d}`, in.N*2))}, nil
+}
+
+func (s *mapScheduler) InvokeAsync(req *domain.InvokeRequest) (string, error) {
+	return "", nil
+}
+
+func newMapState(maxConcurrency int) *domain.State {
+	return &domain.State{
+		Type:      domain.StateTypeMap,
+		ItemsPath: "$.items",
+		Iterator: &domain.Branch{
+			StartAt: "Double",
+			States: map[string]domain.State{
+				"Double": {Type: domain.StateTypeTask, FunctionID: "fn-double", End: true},
+			},
+		},
+		MaxConcurrency: maxConcurrency,
+		Next:           "Done",
+	}
+}
+
+func runMapState(t *testing.T, sched *mapScheduler, state *domain.State, input string) (*domain.StateResult, *memStore) {
+	t.Helper()
+	store := newMemStore()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	executor := NewExecutor(store, sched, logger)
+	exec := &domain.WorkflowExecution{ID: "exec-1"}
+	return executor.ExecuteState(context.Background(), exec, "Fan", state, json.RawMessage(input)), store
+}
+
+func TestMapStateEmptyArray(t *testing.T) {
+	sched := &mapScheduler{}
+	result, _ := runMapState(t, sched, newMapState(0), `{"items":[]}`)
+	if result.Error != nil {
+		t.Fatalf("Map over empty array error = %v", result.Error)
+	}
+	if string(result.Output) != "[]" || result.NextState != "Done" || sched.calls != 0 {
+		t.Errorf("Map over empty array = output %s, next %q, %d calls", result.Output, result.NextState, sched.calls)
+	}
+
+	result, _ = runMapState(t, sched, newMapState(0), `{"items":"not-an-array"}`)
+	if result.Error == nil || result.ErrorCode != domain.ErrorTypeParameterPathFailure {
+		t.Errorf("Map over non-array = %v (%s), want parameter path failure", result.Error, result.ErrorCode)
+	}
+}
+
+func TestMapStateCapsConcurrencyAndKeepsOrder(t *testing.T) {
+	sched := &mapScheduler{delay: 20 * time.Millisecond}
+	result, store := runMapState(t, sched, newMapState(2), `{"items":[{"n":1},{"n":2},{"n":3},{"n":4},{"n":5},{"n":6}]}`)
+	if result.Error != nil {
+		t.Fatalf("Map error = %v", result.Error)
+	}
+	if sched.calls != 6 || sched.peak != 2 {
+		t.Errorf("Map made %d calls with peak concurrency %d, want 6 calls capped at 2", sched.calls, sched.peak)
+	}
+	want := `[{"double":2},{"double":4},{"double":6},{"double":8},{"double":10},{"double":12}]`
+	if string(result.Output) != want {
+		t.Errorf("Map output = %s, want %s", result.Output, want)
+	}
+
+	// 每个迭代都有自己的状态执行记录，外加 Map 状态本身
+	history, _ := store.ListStateExecutions("exec-1")
+	names := map[string]bool{}
+	for _, st := range history {
+		names[st.StateName] = true
+	}
+	if len(history) != 7 || !names["Fan"] || !names["Fan.Iteration[0].Double"] || !names["Fan.Iteration[5].Double"] {
+		t.Errorf("state executions = %v", names)
+	}
+}
+
+func TestMapStateFailureTolerance(t *testing.T) {
+	input := `{"items":[{"n":1},{"n":2,"fail":true},{"n":3},{"n":4,"fail":true}]}`
+
+	// 超出容忍数：汇总每个失败的迭代
+	state := newMapState(0)
+	state.ToleratedFailureCount = 1
+	result, _ := runMapState(t, &mapScheduler{}, state, input)
+	if result.Error == nil || result.ErrorCode != domain.ErrorTypeExceedToleratedFailureThreshold {
+		t.Fatalf("Map with 2 failures, 1 tolerated = %v (%s), want threshold error", result.Error, result.ErrorCode)
+	}
+	msg := result.Error.Error()
+	if !strings.HasPrefix(msg, "2 of 4 map iterations failed") || !strings.Contains(msg, "[1] States.TaskFailed") || !strings.Contains(msg, "item 4 failed") {
+		t.Errorf("aggregated error = %q", msg)
+	}
+
+	// Catch 可以捕获超出阈值的错误
+	state.Catch = []domain.CatchConfig{{ErrorEquals: []string{domain.ErrorTypeExceedToleratedFailureThreshold}, Next: "Recover"}}
+	result, _ = runMapState(t, &mapScheduler{}, state, input)
+	if result.CaughtByState != "Recover" {
+		t.Errorf("CaughtByState = %q, want Recover", result.CaughtByState)
+	}
+
+	// 在容忍百分比内：成功，失败的位置为错误对象
+	state = newMapState(0)
+	state.ToleratedFailurePercentage = 50
+	result, _ = runMapState(t, &mapScheduler{}, state, input)
+	if result.Error != nil {
+		t.Fatalf("Map with 50%% failures tolerated error = %v", result.Error)
+	}
+	var outputs []map[string]interface{}
+	if err := json.Unmarshal(result.Output, &outputs); err != nil || len(outputs) != 4 {
+		t.Fatalf("Map output = %s (%v)", result.Output, err)
+	}
+	if outputs[0]["double"] != float64(2) || outputs[1]["Error"] != domain.ErrorTypeTaskFailed || outputs[3]["Cause"] == nil {
+		t.Errorf("Map output = %s, want results with error objects at failed positions", result.Output)
+	}
+}
+
+// flakyScheduler 前 failures 次调用失败，之后把输入原样返回
+type flakyScheduler struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (s *flakyScheduler) Invoke(req *domain.InvokeRequest) (*domain.InvokeResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.failures {
+		return &domain.InvokeResponse{RequestID: "req", StatusCode: 502, Error: fmt.Sprintf("attempt %d failed", s.calls)}, nil
+	}
+	return &domain.InvokeResponse{RequestID: "req", StatusCode: 200, Body: req.Payload}, nil
+}
+
+func (s *flakyScheduler) InvokeAsync(req *domain.InvokeRequest) (string, error) {
+	return "", nil
+}
+
+func newRetryTaskState(maxAttempts int) *domain.State {
+	return &domain.State{
+		Type:       domain.StateTypeTask,
+		FunctionID: "fn-flaky",
+		Retry: &domain.RetryPolicy{
+			ErrorEquals: []string{domain.ErrorTypeTaskFailed},
+			MaxAttempts: maxAttempts,
+			BackoffRate: 2,
+		},
+		Next: "Done",
+	}
+}
+
+func TestTaskRetryTransientThenSuccess(t *testing.T) {
+	store := newMemStore()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	sched := &flakyScheduler{failures: 2}
+	executor := NewExecutor(store, sched, logger)
+
+	result := executor.ExecuteState(context.Background(), &domain.WorkflowExecution{ID: "exec-1"}, "Call", newRetryTaskState(3), json.RawMessage(`{"n":1}`))
+	if result.Error != nil {
+		t.Fatalf("ExecuteState() error = %v", result.Error)
+	}
+	if sched.calls != 3 || string(result.Output) != `{"n":1}` || result.NextState != "Done" {
+		t.Errorf("calls = %d, result = %s -> %q", sched.calls, result.Output, result.NextState)
+	}
+	st := store.states[0]
+	if st.Status != domain.StateExecutionStatusSucceeded || st.RetryCount != 2 || st.ErrorCode != "" {
+		t.Errorf("state execution = status %s, retry_count %d, error_code %q", st.Status, st.RetryCount, st.ErrorCode)
+	}
+}
+
+func TestTaskRetryExhausted(t *testing.T) {
+	store := newMemStore()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	sched := &flakyScheduler{failures: 10}
+	executor := NewExecutor(store, sched, logger)
+
+	result := executor.ExecuteState(context.Background(), &domain.WorkflowExecution{ID: "exec-1"}, "Call", newRetryTaskState(2), json.RawMessage(`{}`))
+	if result.Error == nil || result.ErrorCode != domain.ErrorTypeTaskFailed || result.CaughtByState != "" {
+		t.Fatalf("result = %v (%s), caught by %q; want uncaught TaskFailed", result.Error, result.ErrorCode, result.CaughtByState)
+	}
+	if sched.calls != 3 {
+		t.Errorf("calls = %d, want 1 attempt + 2 retries", sched.calls)
+	}
+	st := store.states[0]
+	if st.Status != domain.StateExecutionStatusFailed || st.RetryCount != 2 || st.ErrorCode != domain.ErrorTypeTaskFailed || !strings.Contains(st.Error, "attempt 3 failed") {
+		t.Errorf("state execution = status %s, retry_count %d, error %s: %s", st.Status, st.RetryCount, st.ErrorCode, st.Error)
+	}
+}
+
+func TestMapStateRetriedAsWhole(t *testing.T) {
+	state := newMapState(0)
+	state.Iterator.States["Double"] = domain.State{Type: domain.StateTypeTask, FunctionID: "fn-flaky", End: true}
+	state.Retry = &domain.RetryPolicy{ErrorEquals: []string{domain.ErrorTypeAllErrors}, MaxAttempts: 1}
+
+	store := newMemStore()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	sched := &flakyScheduler{failures: 1}
+	result := NewExecutor(store, sched, logger).ExecuteState(context.Background(), &domain.WorkflowExecution{ID: "exec-1"}, "Fan", state, json.RawMessage(`{"items":[1]}`))
+	if result.Error != nil {
+		t.Fatalf("ExecuteState() error = %v", result.Error)
+	}
+	if string(result.Output) != `[1]` {
+		t.Errorf("output = %s", result.Output)
+	}
+	if fan := store.states[0]; fan.StateName != "Fan" || fan.RetryCount != 1 {
+		t.Errorf("map state execution = %s retry_count %d", fan.StateName, fan.RetryCount)
+	}
+}
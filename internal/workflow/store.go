@@ -0,0 +1,29 @@
+// Package workflow 实现了工作流编排引擎。
+package workflow
+
+import "github.com/oriys/nimbus/internal/domain"
+
+// Store 定义了工作流引擎和执行器使用的存储接口
+// *storage.PostgresStore 实现了该接口；只列出引擎实际用到的方法，便于在测试中替换为内存实现。
+type Store interface {
+	// 工作流定义
+	GetWorkflowByID(id string) (*domain.Workflow, error)
+	GetWorkflowByName(name string) (*domain.Workflow, error)
+	CreateWorkflow(workflow *domain.Workflow) error
+
+	// 执行实例与状态执行记录
+	CreateExecution(exec *domain.WorkflowExecution) error
+	GetExecutionByID(id string) (*domain.WorkflowExecution, error)
+	UpdateExecution(exec *domain.WorkflowExecution) error
+	ListPendingExecutions(limit int) ([]*domain.WorkflowExecution, error)
+	CreateStateExecution(stateExec *domain.StateExecution) error
+	UpdateStateExecution(stateExec *domain.StateExecution) error
+	ListStateExecutions(executionID string) ([]*domain.StateExecution, error)
+
+	// 断点
+	GetBreakpoint(executionID, beforeState string) (*domain.Breakpoint, error)
+
+	// 默认工作流依赖的函数
+	GetFunctionByName(name string) (*domain.Function, error)
+	CreateFunction(fn *domain.Function) error
+}
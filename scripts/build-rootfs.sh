@@ -101,10 +101,21 @@ EOF
                 apk add --root /rootfs --no-cache nodejs npm
             '
             ;;
+        ruby3.2)
+            docker run --rm -v "$mountpoint:/rootfs" alpine:3.19 sh -c '
+                apk add --root /rootfs --no-cache ruby ruby-json
+            '
+            ;;
         go1.24)
             # Go functions are pre-compiled, minimal runtime needed
             echo "Go runtime: minimal rootfs (pre-compiled binaries)"
             ;;
+        java17)
+            # JDK is required: the agent compiles its small bootstrap class with javac
+            docker run --rm -v "$mountpoint:/rootfs" alpine:3.19 sh -c '
+                apk add --root /rootfs --no-cache openjdk17-jdk
+            '
+            ;;
     esac
 
     # Cleanup
@@ -115,7 +126,7 @@ EOF
 }
 
 # Build all runtimes
-for runtime in python3.11 nodejs20 go1.24; do
+for runtime in python3.11 nodejs20 ruby3.2 go1.24 java17; do
     build_rootfs "$runtime" 512
 done
 
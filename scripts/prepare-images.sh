@@ -90,6 +90,26 @@ else
     error "Failed to build nimbus-runtime-nodejs20:latest"
 fi
 
+# Ruby runtime
+info "Building nimbus-runtime-ruby3.2:latest..."
+if docker build -t nimbus-runtime-ruby3.2:latest \
+    -f "$RUNTIME_DIR/Dockerfile.ruby3.2" \
+    "$RUNTIME_DIR" > /dev/null 2>&1; then
+    success "nimbus-runtime-ruby3.2:latest"
+else
+    error "Failed to build nimbus-runtime-ruby3.2:latest"
+fi
+
+# Java runtime
+info "Building nimbus-runtime-java17:latest..."
+if docker build -t nimbus-runtime-java17:latest \
+    -f "$RUNTIME_DIR/Dockerfile.java17" \
+    "$RUNTIME_DIR" > /dev/null 2>&1; then
+    success "nimbus-runtime-java17:latest"
+else
+    error "Failed to build nimbus-runtime-java17:latest"
+fi
+
 # Go runtime
 info "Building nimbus-runtime-go1.24:latest..."
 if docker build -t nimbus-runtime-go1.24:latest \
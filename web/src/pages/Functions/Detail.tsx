@@ -786,7 +786,6 @@ export default function FunctionDetail() {
   // 调用记录状态
   const [invocations, setInvocations] = useState<Invocation[]>([])
   const [invocationsLoading, setInvocationsLoading] = useState(false)
-  const [invocationsTotal, setInvocationsTotal] = useState(0)
   const [replayingId, setReplayingId] = useState<string | null>(null)
   const toast = useToast()
 
@@ -919,9 +918,8 @@ export default function FunctionDetail() {
     if (!id) return
     try {
       setInvocationsLoading(true)
-      const result = await invocationService.list({ function_id: id, limit: 50 })
+      const result = await invocationService.listByFunction(id, { limit: 50 })
       setInvocations(result.invocations)
-      setInvocationsTotal(result.total)
     } catch (error) {
       console.error('Failed to load invocations:', error)
     } finally {
@@ -1716,6 +1714,14 @@ export default function FunctionDetail() {
                       <p className="text-xs text-muted-foreground">平均冷启动耗时</p>
                       <p className="text-lg font-bold text-foreground">{formatLatency(analyticsStats?.avg_cold_start_ms || 0)}</p>
                     </div>
+                    <div>
+                      <p className="text-xs text-muted-foreground">冷启动 P99</p>
+                      <p className="text-lg font-bold text-foreground">{formatLatency(analyticsStats?.cold_start_p99_ms || 0)}</p>
+                    </div>
+                    <div>
+                      <p className="text-xs text-muted-foreground">平均热启动耗时</p>
+                      <p className="text-lg font-bold text-foreground">{formatLatency(analyticsStats?.avg_warm_latency_ms || 0)}</p>
+                    </div>
                   </div>
                 </div>
                 <div className="bg-card rounded-xl border border-border p-4">
@@ -2073,7 +2079,7 @@ export default function FunctionDetail() {
           <div className="px-6 py-4 border-b border-border flex items-center justify-between">
             <div>
               <h3 className="text-lg font-semibold text-foreground">调用记录</h3>
-              <p className="text-sm text-muted-foreground mt-1">共 {invocationsTotal} 条记录</p>
+              <p className="text-sm text-muted-foreground mt-1">最近 {invocations.length} 条记录</p>
             </div>
             <button
               onClick={loadInvocations}
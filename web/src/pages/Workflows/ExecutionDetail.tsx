@@ -177,6 +177,8 @@ export default function ExecutionDetail() {
         return <StopCircle className="w-5 h-5 text-yellow-500" />
       case 'paused':
         return <Pause className="w-5 h-5 text-purple-500" />
+      case 'waiting':
+        return <Clock className="w-5 h-5 text-cyan-500" />
       default:
         return <Clock className="w-5 h-5 text-gray-400" />
     }
@@ -108,14 +108,14 @@ export const functionService = {
     return api.post(`/v1/functions/${id}/pin`)
   },
 
-  // 导出函数配置
+  // 导出函数及其层、别名、环境配置和版本
   export: async (id: string): Promise<unknown> => {
     return api.get(`/v1/functions/${id}/export`)
   },
 
-  // 导入函数配置
-  import: async (data: unknown): Promise<AsyncFunctionResponse> => {
-    return api.post('/v1/functions/import', data)
+  // 导入函数导出包，rename 为 true 时名称冲突自动改名
+  import: async (data: unknown, params?: { name?: string; rename?: boolean }): Promise<AsyncFunctionResponse> => {
+    return api.post('/v1/functions/import', data, { params })
   },
 
   // 同步调用函数
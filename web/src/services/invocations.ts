@@ -6,9 +6,23 @@ interface ListInvocationsResponse {
   total: number
 }
 
+interface ListFunctionInvocationsResponse {
+  invocations: Invocation[]
+  next_cursor: string
+  limit: number
+}
+
+interface ListFunctionInvocationsParams {
+  before?: string
+  limit?: number
+}
+
 interface ListInvocationsParams {
   function_id?: string
   status?: string
+  trigger_type?: string
+  after?: string
+  before?: string
   page?: number
   limit?: number
 }
@@ -25,15 +39,9 @@ export const invocationService = {
     return api.get('/v1/invocations', { params: apiParams })
   },
 
-  // 获取函数的调用列表
-  listByFunction: async (functionId: string, params?: Omit<ListInvocationsParams, 'function_id'>): Promise<ListInvocationsResponse> => {
-    // Convert page to offset for backend API
-    const apiParams: Record<string, unknown> = { ...params }
-    if (params?.page && params?.limit) {
-      apiParams.offset = (params.page - 1) * params.limit
-      delete apiParams.page
-    }
-    return api.get(`/v1/functions/${functionId}/invocations`, { params: apiParams })
+  // 获取函数的调用列表（游标分页，before 传入上一页的 next_cursor）
+  listByFunction: async (functionId: string, params?: ListFunctionInvocationsParams): Promise<ListFunctionInvocationsResponse> => {
+    return api.get(`/v1/functions/${functionId}/invocations`, { params })
   },
 
   // 获取单个调用详情
@@ -67,4 +67,12 @@ export const metricsService = {
     })
     return response.data || response
   },
+
+  // 获取函数调用耗时的随机样本（毫秒，升序），用于自定义分桶的直方图和百分位
+  getFunctionLatencySamples: async (functionId: string, period: string = '24h', limit?: number): Promise<number[]> => {
+    const response = await api.get(`/console/functions/${functionId}/latency-samples`, {
+      params: { period, limit },
+    })
+    return response.data || response
+  },
 }
@@ -110,6 +110,26 @@ export const settingGroups: SettingGroup[] = [
       },
     ],
   },
+  {
+    id: 'dlq',
+    label: '死信队列',
+    description: '死信消息自动重试策略',
+    settings: [
+      {
+        key: 'dlq_retry_base_delay_seconds',
+        label: '基础退避时间',
+        description: '第 n 次自动重试在上次尝试后等待 基础退避时间 × 2^n',
+        type: 'number',
+        unit: '秒',
+      },
+      {
+        key: 'dlq_retry_max_attempts',
+        label: '最大重试次数',
+        description: '超过后消息被丢弃，0 表示关闭自动重试',
+        type: 'number',
+      },
+    ],
+  },
   {
     id: 'retention',
     label: '数据保留',
@@ -136,6 +156,26 @@ export const settingGroups: SettingGroup[] = [
         type: 'number',
         unit: '天',
       },
+      {
+        key: 'audit_retention_days',
+        label: '审计日志保留天数',
+        description: '审计日志的保留天数',
+        type: 'number',
+        unit: '天',
+      },
+      {
+        key: 'retention_cleanup_interval_hours',
+        label: '自动清理间隔',
+        description: '按保留天数自动清理过期数据的间隔，0 表示关闭自动清理',
+        type: 'number',
+        unit: '小时',
+      },
+      {
+        key: 'invocation_trim_preserve_failed',
+        label: '优先保留失败记录',
+        description: '按函数调用记录条数上限裁剪时，优先保留失败和超时的调用记录',
+        type: 'boolean',
+      },
     ],
   },
 ]
@@ -152,7 +192,12 @@ export const defaultSettings: Record<string, string> = {
   workflow_max_history: '100',
   log_retention_days: '30',
   dlq_retention_days: '90',
+  dlq_retry_base_delay_seconds: '60',
+  dlq_retry_max_attempts: '5',
   invocation_retention_days: '30',
+  invocation_trim_preserve_failed: 'false',
+  audit_retention_days: '180',
+  retention_cleanup_interval_hours: '24',
 }
 
 export const settingsService = {
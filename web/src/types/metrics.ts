@@ -65,6 +65,9 @@ export interface FunctionStats {
   cold_start_count: number
   cold_start_rate: number
   avg_cold_start_ms: number
+  cold_start_p99_ms: number
+  warm_count: number
+  avg_warm_latency_ms: number
   total_duration_ms: number
   error_rate: number
   timeout_count: number
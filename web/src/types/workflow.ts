@@ -4,13 +4,13 @@
 export type WorkflowStatus = 'active' | 'inactive'
 
 // 执行状态
-export type ExecutionStatus = 'pending' | 'running' | 'succeeded' | 'failed' | 'timeout' | 'cancelled' | 'paused'
+export type ExecutionStatus = 'pending' | 'running' | 'succeeded' | 'failed' | 'timeout' | 'cancelled' | 'paused' | 'waiting'
 
 // 状态执行状态
 export type StateExecutionStatus = 'pending' | 'running' | 'succeeded' | 'failed' | 'skipped'
 
 // 状态类型
-export type StateType = 'Task' | 'Choice' | 'Wait' | 'Parallel' | 'Pass' | 'Fail' | 'Succeed'
+export type StateType = 'Task' | 'Choice' | 'Wait' | 'Parallel' | 'Map' | 'Pass' | 'Fail' | 'Succeed'
 
 // 重试策略
 export interface RetryPolicy {
@@ -75,7 +75,7 @@ export interface ChoiceRule {
   next: string
 }
 
-// 分支 (Parallel 用)
+// 分支 (Parallel 分支和 Map 迭代分支用)
 export interface Branch {
   start_at: string
   states: Record<string, State>
@@ -103,6 +103,12 @@ export interface State {
   timestamp_path?: string
   // Parallel 字段
   branches?: Branch[]
+  // Map 字段
+  items_path?: string
+  iterator?: Branch
+  max_concurrency?: number
+  tolerated_failure_count?: number
+  tolerated_failure_percentage?: number
   // Pass/Fail 字段
   result?: unknown
   result_path?: string
@@ -154,6 +160,9 @@ export interface WorkflowExecution {
   paused_at_state?: string
   paused_input?: unknown
   paused_at?: string
+  // Wait 状态定时唤醒字段
+  wait_until?: string
+  wait_input?: unknown
 }
 
 // 断点定义
@@ -230,6 +239,7 @@ export const STATE_TYPE_COLORS: Record<StateType, string> = {
   'Choice': 'bg-yellow-500',
   'Wait': 'bg-purple-500',
   'Parallel': 'bg-green-500',
+  'Map': 'bg-teal-500',
   'Pass': 'bg-gray-500',
   'Fail': 'bg-red-500',
   'Succeed': 'bg-emerald-500',
@@ -241,6 +251,7 @@ export const STATE_TYPE_LABELS: Record<StateType, string> = {
   'Choice': '条件',
   'Wait': '等待',
   'Parallel': '并行',
+  'Map': '映射',
   'Pass': '透传',
   'Fail': '失败',
   'Succeed': '成功',
@@ -267,6 +278,7 @@ export const EXECUTION_STATUS_COLORS: Record<ExecutionStatus, string> = {
   'timeout': 'bg-orange-100 text-orange-800 dark:bg-orange-900/30 dark:text-orange-400',
   'cancelled': 'bg-yellow-100 text-yellow-800 dark:bg-yellow-900/30 dark:text-yellow-400',
   'paused': 'bg-purple-100 text-purple-800 dark:bg-purple-900/30 dark:text-purple-400',
+  'waiting': 'bg-cyan-100 text-cyan-800 dark:bg-cyan-900/30 dark:text-cyan-400',
 }
 
 // 执行状态标签
@@ -278,6 +290,7 @@ export const EXECUTION_STATUS_LABELS: Record<ExecutionStatus, string> = {
   'timeout': '超时',
   'cancelled': '已取消',
   'paused': '已暂停',
+  'waiting': '定时等待',
 }
 
 // 状态执行状态颜色